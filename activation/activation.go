@@ -3,21 +3,36 @@
 package activation
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/spacemeshos/ed25519"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 	"github.com/spacemeshos/post/shared"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // AtxProtocol is the protocol id for broadcasting atxs over gossip
 const AtxProtocol = "AtxGossip"
 
-var activesetCache = NewActivesetCache(1000)
+// AtxHeaderProtocol is the protocol id for broadcasting atxs without their NIPST over gossip, once the
+// header-first-atx-gossip upgrade is active
+const AtxHeaderProtocol = "AtxHeaderGossip"
+
+var activesetCache = NewActivesetCache(DefaultActivesetCacheSize)
+
+// SetActivesetCacheSize replaces the active set size cache with an empty cache of size entries. It must be called
+// before atxs are processed.
+func SetActivesetCacheSize(size int) {
+	activesetCache = NewActivesetCache(size)
+}
 
 type meshProvider interface {
 	GetOrphanBlocksBefore(l types.LayerID) ([]types.BlockID, error)
@@ -36,7 +51,7 @@ func (provider *poetNumberOfTickProvider) NumOfTicks() uint64 {
 }
 
 type nipstBuilder interface {
-	BuildNIPST(challenge *types.Hash32, timeout chan struct{}, stop chan struct{}) (*types.NIPST, error)
+	BuildNIPST(challenge *types.Hash32, deadline time.Time, timeout chan struct{}, stop chan struct{}) (*types.NIPST, error)
 }
 
 type idStore interface {
@@ -47,11 +62,14 @@ type idStore interface {
 type nipstValidator interface {
 	Validate(id signing.PublicKey, nipst *types.NIPST, expectedChallenge types.Hash32) error
 	VerifyPost(id signing.PublicKey, proof *types.PostProof, space uint64) error
+	NumOfTicks(nipst *types.NIPST) (uint64, error)
+	NumOfSpaceUnits(nipst *types.NIPST) uint32
 }
 
 type atxDBProvider interface {
 	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
-	CalcActiveSetFromView(view []types.BlockID, pubEpoch types.EpochID) (uint32, error)
+	CalcActiveSet(ctx context.Context, view []types.BlockID, pubEpoch types.EpochID) (uint32, error)
+	ActiveSetLayer(pubEpoch types.EpochID) types.LayerID
 	GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error)
 	GetPosAtxID() (types.ATXID, error)
 	AwaitAtx(id types.ATXID) chan struct{}
@@ -100,12 +118,17 @@ type Builder struct {
 	accountLock     sync.RWMutex
 	initStatus      int32
 	initDone        chan struct{}
+	upgrades        *upgrade.Schedule
+	posAtxs         PositioningAtxProvider
+	publishedLock   sync.RWMutex
+	published       *types.ActivationTx // the last atx gossiped without its NIPST, whose NIPST the builder serves
 	log             log.Log
 }
 
 type layerClock interface {
 	AwaitLayer(layerID types.LayerID) chan struct{}
 	GetCurrentLayer() types.LayerID
+	LayerToTime(types.LayerID) time.Time
 }
 
 type syncer interface {
@@ -114,7 +137,7 @@ type syncer interface {
 
 // NewBuilder returns an atx builder that will start a routine that will attempt to create an atx upon each new layer.
 func NewBuilder(nodeID types.NodeID, coinbaseAccount types.Address, signer signer, db atxDBProvider, net broadcaster, mesh meshProvider, layersPerEpoch uint16, nipstBuilder nipstBuilder, postProver PostProverClient, layerClock layerClock, syncer syncer, store bytesStore, log log.Log) *Builder {
-	return &Builder{
+	b := &Builder{
 		signer:          signer,
 		nodeID:          nodeID,
 		coinbaseAccount: coinbaseAccount,
@@ -132,6 +155,20 @@ func NewBuilder(nodeID types.NodeID, coinbaseAccount types.Address, signer signe
 		initDone:        make(chan struct{}),
 		log:             log,
 	}
+	b.loadCoinbaseAccount()
+	return b
+}
+
+// SetUpgrades sets the schedule of the protocol upgrades that atx creation branches on. It must be called before the
+// builder starts.
+func (b *Builder) SetUpgrades(upgrades *upgrade.Schedule) {
+	b.upgrades = upgrades
+}
+
+// SetPositioningAtxProvider sets the policy that selects the atx the builder positions its atxs on. Without one, the
+// builder positions them on the atx with the highest layer. It must be called before the builder starts.
+func (b *Builder) SetPositioningAtxProvider(provider PositioningAtxProvider) {
+	b.posAtxs = provider
 }
 
 // Start is the main entry point of the atx builder. it runs the main loop of the builder and shouldn't be called more than once
@@ -149,11 +186,19 @@ func (b *Builder) Stop() {
 }
 
 // SignAtx signs the atx and assigns the signature into atx.Sig
-// this function returns an error if atx could not be converted to bytes
+// this function returns an error if atx could not be converted to bytes. Once the header-first-atx-gossip upgrade is
+// active in the atx's publication epoch, the atx signs the hash of its NIPST instead of the NIPST.
 func (b *Builder) SignAtx(atx *types.ActivationTx) error {
+	if b.headerFirst(atx.ActivationTxHeader) {
+		return SignAtxHeader(b, atx)
+	}
 	return SignAtx(b, atx)
 }
 
+func (b *Builder) headerFirst(atx *types.ActivationTxHeader) bool {
+	return b.upgrades.Active(upgrade.HeaderFirstAtxGossip, atx.PubLayerID.GetEpoch(b.layersPerEpoch))
+}
+
 // StopRequestedError is a specific type of error the indicated a user has stopped mining
 type StopRequestedError struct{}
 
@@ -173,6 +218,8 @@ func (b *Builder) loop() {
 	err := b.loadChallenge()
 	if err != nil {
 		log.Info("challenge not loaded: %s", err)
+	} else if b.challenge != nil {
+		b.resumeChallenge()
 	}
 	if err := b.waitOrStop(b.initDone); err != nil {
 		return
@@ -199,10 +246,23 @@ func (b *Builder) loop() {
 
 func (b *Builder) buildNipstChallenge() error {
 	<-b.syncer.Await()
+	challenge, err := b.newNipstChallenge()
+	if err != nil {
+		return err
+	}
+	b.challenge = challenge
+	if err := b.storeChallenge(b.challenge); err != nil {
+		return fmt.Errorf("failed to store nipst challenge: %v", err)
+	}
+	return nil
+}
+
+// newNipstChallenge returns the challenge of the next atx, built on the latest atxs in the database.
+func (b *Builder) newNipstChallenge() (*types.NIPSTChallenge, error) {
 	challenge := &types.NIPSTChallenge{NodeID: b.nodeID}
 	if posAtx, err := b.GetPositioningAtx(); err != nil {
 		if !b.currentEpoch().IsGenesis() {
-			return fmt.Errorf("failed to get positioning ATX: %v", err)
+			return nil, fmt.Errorf("failed to get positioning ATX: %v", err)
 		}
 		challenge.EndTick = b.tickProvider.NumOfTicks()
 	} else {
@@ -217,11 +277,7 @@ func (b *Builder) buildNipstChallenge() error {
 		challenge.PrevATXID = prevAtx.ID()
 		challenge.Sequence = prevAtx.Sequence + 1
 	}
-	b.challenge = challenge
-	if err := b.storeChallenge(b.challenge); err != nil {
-		return fmt.Errorf("failed to store nipst challenge: %v", err)
-	}
-	return nil
+	return challenge, nil
 }
 
 // StartPost initiates post commitment generation process. It returns an error if a process is already in progress or
@@ -239,7 +295,7 @@ func (b *Builder) StartPost(rewardAddress types.Address, dataDir string, space u
 	if err := b.postProver.SetParams(dataDir, space); err != nil {
 		return err
 	}
-	b.SetCoinbaseAccount(rewardAddress)
+	b.setCoinbaseAccount(rewardAddress)
 
 	initialized, _, err := b.postProver.IsInitialized()
 	if err != nil {
@@ -296,7 +352,7 @@ func (b *Builder) StartPost(rewardAddress types.Address, dataDir string, space u
 
 // MiningStats returns state of post init, coinbase reward account and data directory path for post commitment
 func (b *Builder) MiningStats() (int, uint64, string, string) {
-	acc := b.getCoinbaseAccount()
+	acc := b.CoinbaseAccount()
 	initStatus := atomic.LoadInt32(&b.initStatus)
 	remainingBytes := uint64(0)
 	if initStatus == InitInProgress {
@@ -311,20 +367,59 @@ func (b *Builder) MiningStats() (int, uint64, string, string) {
 }
 
 // SetCoinbaseAccount sets the address rewardAddress to be the coinbase account written into the activation transaction
-// the rewards for blocks made by this miner will go to this address
-func (b *Builder) SetCoinbaseAccount(rewardAddress types.Address) {
+// the rewards for blocks made by this miner will go to this address. The account can be changed while the builder runs,
+// the next published atx carries it, even if its challenge was built before. It's stored, so it overrides the account
+// the builder is created with after a restart.
+func (b *Builder) SetCoinbaseAccount(rewardAddress types.Address) error {
+	if rewardAddress == (types.Address{}) {
+		return errors.New("coinbase account is empty")
+	}
 	b.accountLock.Lock()
+	defer b.accountLock.Unlock()
+	if err := b.store.Put(getCoinbaseKey(), rewardAddress.Bytes()); err != nil {
+		return fmt.Errorf("failed to store coinbase account: %v", err)
+	}
+	prev := b.coinbaseAccount
 	b.coinbaseAccount = rewardAddress
-	b.accountLock.Unlock()
+	b.log.With().Info("coinbase account changed, the next published atx carries it",
+		log.String("prev_coinbase", prev.Short()), log.String("coinbase", rewardAddress.Short()))
+	return nil
 }
 
-func (b *Builder) getCoinbaseAccount() types.Address {
+// CoinbaseAccount returns the coinbase account that the next published atx carries.
+func (b *Builder) CoinbaseAccount() types.Address {
 	b.accountLock.RLock()
 	acc := b.coinbaseAccount
 	b.accountLock.RUnlock()
 	return acc
 }
 
+// setCoinbaseAccount sets the coinbase account without storing it, it's used when PoST is started with the account
+// that the node is configured with or the caller passes.
+func (b *Builder) setCoinbaseAccount(rewardAddress types.Address) {
+	b.accountLock.Lock()
+	b.coinbaseAccount = rewardAddress
+	b.accountLock.Unlock()
+}
+
+func getCoinbaseKey() []byte {
+	return []byte("Coinbase")
+}
+
+// loadCoinbaseAccount restores the coinbase account set by SetCoinbaseAccount before the node restarted.
+func (b *Builder) loadCoinbaseAccount() {
+	bts, err := b.store.Get(getCoinbaseKey())
+	if err != nil || len(bts) == 0 {
+		return
+	}
+	acc := types.BytesToAddress(bts)
+	if acc != b.coinbaseAccount {
+		b.log.With().Info("using the coinbase account set through the api instead of the configured one",
+			log.String("configured_coinbase", b.coinbaseAccount.Short()), log.String("coinbase", acc.Short()))
+	}
+	b.coinbaseAccount = acc
+}
+
 func (b *Builder) getNipstKey() []byte {
 	return []byte("Nipst")
 }
@@ -338,21 +433,32 @@ func (b *Builder) storeChallenge(ch *types.NIPSTChallenge) error {
 }
 
 func (b *Builder) loadChallenge() error {
-	bts, err := b.store.Get(b.getNipstKey())
+	challenge, err := b.readChallenge()
 	if err != nil {
 		return err
 	}
-	if len(bts) > 0 {
-		tp := &types.NIPSTChallenge{}
-		err = types.BytesToInterface(bts, tp)
-		if err != nil {
-			return err
-		}
-		b.challenge = tp
+	if challenge != nil {
+		b.challenge = challenge
 	}
 	return nil
 }
 
+// readChallenge returns the stored challenge, or nil if there's no challenge in flight.
+func (b *Builder) readChallenge() (*types.NIPSTChallenge, error) {
+	bts, err := b.store.Get(b.getNipstKey())
+	if err != nil {
+		return nil, err
+	}
+	if len(bts) == 0 {
+		return nil, nil
+	}
+	tp := &types.NIPSTChallenge{}
+	if err := types.BytesToInterface(bts, tp); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
 // PublishActivationTx attempts to publish an atx, it returns an error if an atx cannot be created.
 func (b *Builder) PublishActivationTx() error {
 	b.discardChallengeIfStale()
@@ -373,7 +479,9 @@ func (b *Builder) PublishActivationTx() error {
 	}
 	// ⏳ the following method waits for a PoET proof, which should take ~1 epoch
 	atxExpired := b.layerClock.AwaitLayer((pubEpoch + 2).FirstLayer(b.layersPerEpoch)) // this fires when the target epoch is over
-	nipst, err := b.nipstBuilder.BuildNIPST(hash, atxExpired, b.stop)
+	// the nipst should be ready before the publication epoch is over
+	deadline := b.layerClock.LayerToTime((pubEpoch + 1).FirstLayer(b.layersPerEpoch))
+	nipst, err := b.nipstBuilder.BuildNIPST(hash, deadline, atxExpired, b.stop)
 	if err != nil {
 		if _, stopRequested := err.(StopRequestedError); stopRequested {
 			return err
@@ -398,13 +506,22 @@ func (b *Builder) PublishActivationTx() error {
 	// we've completed the sequential work, now before publishing the atx,
 	// we need to provide number of atx seen in the epoch of the positioning atx.
 
+	// after the first-seen-active-set upgrade the active set is counted from the blocks of the grace period too, and the
+	// atx carries no view
+	firstSeen := b.upgrades.Active(upgrade.FirstSeenActiveSet, pubEpoch)
+	if firstSeen {
+		if err := b.waitOrStop(b.layerClock.AwaitLayer(b.db.ActiveSetLayer(pubEpoch))); err != nil {
+			return err
+		}
+	}
+
 	// ensure we are synced before generating the ATX's view
 	if err := b.waitOrStop(b.syncer.Await()); err != nil {
 		return err
 	}
 	viewLayer := pubEpoch.FirstLayer(b.layersPerEpoch)
 	var view []types.BlockID
-	if viewLayer > 0 {
+	if viewLayer > 0 && !firstSeen {
 		var err error
 		view, err = b.mesh.GetOrphanBlocksBefore(viewLayer)
 		if err != nil {
@@ -416,7 +533,9 @@ func (b *Builder) PublishActivationTx() error {
 	if pubEpoch > 0 {
 		var err error
 		b.log.With().Info("calculating active ids")
-		activeSetSize, err = b.db.CalcActiveSetFromView(view, pubEpoch)
+		ctx, cancel := util.StopContext(b.stop)
+		activeSetSize, err = b.db.CalcActiveSet(ctx, view, pubEpoch)
+		cancel()
 		if err != nil {
 			return fmt.Errorf("failed to calculate activeset: %v", err)
 		}
@@ -431,7 +550,9 @@ func (b *Builder) PublishActivationTx() error {
 		commitment = b.commitment
 	}
 
-	atx := types.NewActivationTx(*b.challenge, b.getCoinbaseAccount(), activeSetSize, view, nipst, commitment)
+	atx := types.NewActivationTx(*b.challenge, b.CoinbaseAccount(), activeSetSize, view, nipst, commitment)
+	atx.SpaceUnits = spaceUnits(nipst.Space, b.postProver.Cfg().SpacePerUnit)
+	atx.CalcAndSetID()
 
 	b.log.With().Info("active ids seen for epoch", log.Uint64("atx_pub_epoch", uint64(pubEpoch)),
 		log.Uint32("view_cnt", activeSetSize))
@@ -469,6 +590,24 @@ func (b *Builder) PublishActivationTx() error {
 	return nil
 }
 
+// resumeChallenge resumes the challenge that was in flight when the node stopped. A challenge whose atx was already
+// published, because the node stopped before discarding it, is discarded so that its sequence number isn't used twice.
+func (b *Builder) resumeChallenge() {
+	targetEpoch := b.challenge.PubLayerID.GetEpoch(b.layersPerEpoch) + 1
+	if prev, err := b.GetPrevAtx(b.nodeID); err == nil && prev.Sequence >= b.challenge.Sequence {
+		b.log.With().Info("discarding atx challenge that was already published",
+			log.Uint64("sequence", b.challenge.Sequence), log.AtxID(prev.ShortString()))
+		b.discardChallenge()
+		return
+	}
+	b.log.With().Info("resuming atx challenge",
+		log.Uint64("sequence", b.challenge.Sequence), log.Uint64("target_epoch", uint64(targetEpoch)))
+	if atomic.LoadInt32(&b.initStatus) == InitIdle {
+		b.log.With().Warning("the atx challenge waits for PoST to be started, it's lost if the target epoch ends first",
+			log.Uint64("target_epoch", uint64(targetEpoch)))
+	}
+}
+
 func (b *Builder) currentEpoch() types.EpochID {
 	return b.layerClock.GetCurrentLayer().GetEpoch(b.layersPerEpoch)
 }
@@ -484,6 +623,9 @@ func (b *Builder) signAndBroadcast(atx *types.ActivationTx) (int, error) {
 	if err := b.SignAtx(atx); err != nil {
 		return 0, fmt.Errorf("failed to sign ATX: %v", err)
 	}
+	if b.headerFirst(atx.ActivationTxHeader) {
+		return b.broadcastHeader(atx)
+	}
 	buf, err := types.InterfaceToBytes(atx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to serialize ATX: %v", err)
@@ -494,9 +636,47 @@ func (b *Builder) signAndBroadcast(atx *types.ActivationTx) (int, error) {
 	return len(buf), nil
 }
 
-// GetPositioningAtx return the latest atx to be used as a positioning atx
+// broadcastHeader gossips the signed atx without its NIPST, and keeps the atx to serve its NIPST to the peers the
+// header reaches first.
+func (b *Builder) broadcastHeader(atx *types.ActivationTx) (int, error) {
+	nipstHash, err := atx.Nipst.Hash()
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash NIPST: %v", err)
+	}
+	header := *atx
+	inner := *atx.InnerActivationTx
+	inner.Nipst = nil
+	header.InnerActivationTx = &inner
+	buf, err := types.InterfaceToBytes(&types.AtxHeader{Atx: &header, NipstHash: nipstHash})
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize ATX header: %v", err)
+	}
+	b.publishedLock.Lock()
+	b.published = atx
+	b.publishedLock.Unlock()
+	if err := b.net.Broadcast(AtxHeaderProtocol, buf); err != nil {
+		return 0, fmt.Errorf("failed to broadcast ATX header: %v", err)
+	}
+	return len(buf), nil
+}
+
+// GetNipst returns the NIPST of the atx with id if it's the last atx the builder gossiped without its NIPST.
+func (b *Builder) GetNipst(id types.ATXID) (*types.NIPST, error) {
+	b.publishedLock.RLock()
+	defer b.publishedLock.RUnlock()
+	if b.published == nil || b.published.ID() != id {
+		return nil, errNipstNotFound
+	}
+	return b.published.Nipst, nil
+}
+
+// GetPositioningAtx return the atx to be used as a positioning atx, selected by the positioning atx provider
 func (b *Builder) GetPositioningAtx() (*types.ActivationTxHeader, error) {
-	if id, err := b.db.GetPosAtxID(); err != nil {
+	getPosAtxID := b.db.GetPosAtxID
+	if b.posAtxs != nil {
+		getPosAtxID = func() (types.ATXID, error) { return b.posAtxs.PositioningAtx(b.nodeID) }
+	}
+	if id, err := getPosAtxID(); err != nil {
 		return nil, fmt.Errorf("cannot find pos atx: %v", err)
 	} else if atx, err := b.db.GetAtxHeader(id); err != nil {
 		return nil, fmt.Errorf("inconsistent state: failed to get atx header: %v", err)
@@ -556,3 +736,35 @@ func SignAtx(signer signer, atx *types.ActivationTx) error {
 	atx.Sig = signer.Sign(bts)
 	return nil
 }
+
+// ExtractHeaderPublicKey extracts the public key from the signature of an atx gossiped without its NIPST, which signs
+// nipstHash instead of the NIPST.
+func ExtractHeaderPublicKey(signedAtx *types.ActivationTx, nipstHash types.Hash32) (*signing.PublicKey, error) {
+	bts, err := signedAtx.HeaderBytes(nipstHash)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := ed25519.ExtractPublicKey(bts, signedAtx.Sig)
+	if err != nil {
+		return nil, err
+	}
+	return signing.NewPublicKey(pubKey), nil
+}
+
+// SignAtxHeader signs the atx with signer so that it can be gossiped without its NIPST: the signature covers the hash
+// of the NIPST instead of the NIPST. The atx must have its NIPST.
+func SignAtxHeader(signer signer, atx *types.ActivationTx) error {
+	if atx.Nipst == nil {
+		return errors.New("atx has no NIPST")
+	}
+	nipstHash, err := atx.Nipst.Hash()
+	if err != nil {
+		return err
+	}
+	bts, err := atx.HeaderBytes(nipstHash)
+	if err != nil {
+		return err
+	}
+	atx.Sig = signer.Sign(bts)
+	return nil
+}
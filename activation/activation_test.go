@@ -2,6 +2,7 @@ package activation
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/spacemeshos/ed25519"
@@ -17,6 +18,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -122,7 +124,7 @@ type NipstBuilderMock struct {
 	SleepTime      int
 }
 
-func (np *NipstBuilderMock) BuildNIPST(challenge *types.Hash32, _ chan struct{}, _ chan struct{}) (*types.NIPST, error) {
+func (np *NipstBuilderMock) BuildNIPST(challenge *types.Hash32, _ time.Time, _ chan struct{}, _ chan struct{}) (*types.NIPST, error) {
 	if np.buildNipstFunc != nil {
 		return np.buildNipstFunc(challenge)
 	}
@@ -131,7 +133,7 @@ func (np *NipstBuilderMock) BuildNIPST(challenge *types.Hash32, _ chan struct{},
 
 type NipstErrBuilderMock struct{}
 
-func (np *NipstErrBuilderMock) BuildNIPST(*types.Hash32, chan struct{}, chan struct{}) (*types.NIPST, error) {
+func (np *NipstErrBuilderMock) BuildNIPST(*types.Hash32, time.Time, chan struct{}, chan struct{}) (*types.NIPST, error) {
 	return nil, fmt.Errorf("nipst builder error")
 }
 
@@ -155,6 +157,14 @@ func (*ValidatorMock) VerifyPost(signing.PublicKey, *types.PostProof, uint64) er
 	return nil
 }
 
+func (*ValidatorMock) NumOfTicks(*types.NIPST) (uint64, error) {
+	return 0, nil
+}
+
+func (*ValidatorMock) NumOfSpaceUnits(*types.NIPST) uint32 {
+	return 0
+}
+
 func NewMockDB() *MockDB {
 	return &MockDB{
 		make(map[string][]byte),
@@ -234,6 +244,10 @@ func (l *LayerClockMock) GetCurrentLayer() types.LayerID {
 	return l.currentLayer
 }
 
+func (l *LayerClockMock) LayerToTime(types.LayerID) time.Time {
+	return time.Time{}
+}
+
 func (l *LayerClockMock) AwaitLayer(types.LayerID) chan struct{} {
 	ch := make(chan struct{})
 	go func() {
@@ -261,7 +275,7 @@ func setActivesetSizeInCache(t *testing.T, activesetSize uint32) {
 		return bytes.Compare(view[i].Bytes(), view[j].Bytes()) < 0
 	})
 	h := types.CalcBlocksHash12(view)
-	activesetCache.Add(h, activesetSize)
+	activesetCache.Add(h, activesetSize, uint64(activesetSize))
 }
 
 func lastTransmittedAtx(t *testing.T) types.ActivationTx {
@@ -348,6 +362,45 @@ func TestBuilder_PublishActivationTx_HappyFlow(t *testing.T) {
 	assertLastAtx(r, publishedAtx.ActivationTxHeader, publishedAtx.ActivationTxHeader, layersPerEpoch)
 }
 
+func TestBuilder_SetCoinbaseAccount(t *testing.T) {
+	r := require.New(t)
+
+	activationDb := newActivationDb()
+	net.atxDb = activationDb
+	store := NewMockDB()
+	b := NewBuilder(nodeID, coinbase, &MockSigning{}, activationDb, net, meshProviderMock, layersPerEpoch, nipstBuilderMock, postProver, layerClockMock, &mockSyncer{}, store, lg.WithName("atxBuilder"))
+	b.commitment = commitment
+	setActivesetSizeInCache(t, defaultActiveSetSize)
+	defer activesetCache.Purge()
+
+	challenge := newChallenge(nodeID, 1, prevAtxID, prevAtxID, postGenesisEpochLayer)
+	prevAtx := newAtx(challenge, 5, defaultView, npst)
+	storeAtx(r, activationDb, prevAtx, log.NewDefault("storeAtx"))
+
+	r.EqualError(b.SetCoinbaseAccount(types.Address{}), "coinbase account is empty")
+	r.Equal(coinbase, b.CoinbaseAccount())
+
+	// the account is changed while the nipst is built, the atx carries the new one
+	rotated := types.HexToAddress("bbbb")
+	net.lastTransmission = nil
+	meshProviderMock.latestLayer = postGenesisEpochLayer + 1
+	nipstBuilderMock.buildNipstFunc = func(challenge *types.Hash32) (*types.NIPST, error) {
+		r.NoError(b.SetCoinbaseAccount(rotated))
+		meshProviderMock.latestLayer = meshProviderMock.latestLayer.Add(layersPerEpoch)
+		layerClockMock.currentLayer = layerClockMock.currentLayer.Add(layersPerEpoch)
+		return NewNIPSTWithChallenge(challenge, poetRef), nil
+	}
+	defer func() { nipstBuilderMock.buildNipstFunc = nil }()
+	layerClockMock.currentLayer = types.EpochID(postGenesisEpoch).FirstLayer(layersPerEpoch) + 3
+	r.NoError(b.PublishActivationTx())
+	atx := lastTransmittedAtx(t)
+	r.Equal(rotated, atx.Coinbase)
+
+	// a restarted builder keeps the account instead of the one it's created with
+	b = NewBuilder(nodeID, coinbase, &MockSigning{}, activationDb, net, meshProviderMock, layersPerEpoch, nipstBuilderMock, postProver, layerClockMock, &mockSyncer{}, store, lg.WithName("atxBuilder"))
+	r.Equal(rotated, b.CoinbaseAccount())
+}
+
 func TestBuilder_PublishActivationTx_FaultyNet(t *testing.T) {
 	r := require.New(t)
 
@@ -689,6 +742,85 @@ func TestBuilder_NipstPublishRecovery(t *testing.T) {
 	assert.True(t, db.hadNone)
 }
 
+func TestBuilder_ResumeChallenge(t *testing.T) {
+	r := require.New(t)
+	id := types.NodeID{Key: "aaaaaa", VRFPublicKey: []byte("bbbbb")}
+	coinbase := types.HexToAddress("0xaaa")
+	layersPerEpoch := uint16(10)
+	lg := log.NewDefault(id.Key[:5])
+	db := NewMockDB()
+	activationDb := NewDB(database.NewMemDatabase(), &MockIDStore{}, mesh.NewMemMeshDB(lg.WithName("meshDB")), layersPerEpoch, &ValidatorMock{}, lg.WithName("atxDB"))
+	b := NewBuilder(id, coinbase, &MockSigning{}, activationDb, &NetMock{}, &MeshProviderMock{}, layersPerEpoch, &NipstBuilderMock{}, postProver, layerClockMock, &mockSyncer{}, db, lg.WithName("atxBuilder"))
+
+	prevAtx := types.ATXID(types.HexToHash32("0x111"))
+	atx := newActivationTx(id, 1, prevAtx, 15, 1, prevAtx, coinbase, 5, []types.BlockID{}, &types.NIPST{})
+	r.NoError(activationDb.StoreAtx(atx.PubLayerID.GetEpoch(layersPerEpoch), atx))
+
+	// the atx of the challenge was published before the node stopped
+	r.NoError(b.storeChallenge(&types.NIPSTChallenge{NodeID: id, Sequence: 1, PrevATXID: prevAtx, PubLayerID: 15}))
+	r.NoError(b.loadChallenge())
+	b.resumeChallenge()
+	r.Nil(b.challenge)
+	r.NoError(b.loadChallenge())
+	r.Nil(b.challenge)
+
+	// the challenge is still in flight
+	challenge := &types.NIPSTChallenge{NodeID: id, Sequence: 2, PrevATXID: atx.ID(), PubLayerID: 25}
+	r.NoError(b.storeChallenge(challenge))
+	r.NoError(b.loadChallenge())
+	b.resumeChallenge()
+	r.Equal(challenge, b.challenge)
+}
+
+func TestBuilder_NextAtx(t *testing.T) {
+	r := require.New(t)
+
+	activationDb := newActivationDb()
+	b := newBuilder(activationDb)
+	setActivesetSizeInCache(t, defaultActiveSetSize)
+	defer activesetCache.Purge()
+
+	challenge := newChallenge(nodeID, 1, prevAtxID, prevAtxID, postGenesisEpochLayer)
+	prevAtx := newAtx(challenge, 5, defaultView, npst)
+	storeAtx(r, activationDb, prevAtx, log.NewDefault("storeAtx"))
+
+	net.lastTransmission = nil
+	meshProviderMock.latestLayer = postGenesisEpochLayer + 1
+	layerClockMock.currentLayer = types.EpochID(postGenesisEpoch).FirstLayer(layersPerEpoch) + 3
+
+	_, err := b.NextAtx()
+	r.EqualError(err, "PoST is not initialized")
+	atomic.StoreInt32(&b.initStatus, InitDone)
+
+	next, err := b.NextAtx()
+	r.NoError(err)
+	r.False(next.InFlight)
+	r.Equal(prevAtx.ID(), next.Atx.PrevATXID)
+	r.Equal(prevAtx.ID(), next.Atx.PositioningATX)
+	r.Equal(prevAtx.Sequence+1, next.Atx.Sequence)
+	r.Equal(prevAtx.PubLayerID.Add(layersPerEpoch), next.Atx.PubLayerID)
+	r.Equal(prevAtx.TargetEpoch(layersPerEpoch)+1, next.TargetEpoch)
+	r.Equal(defaultActiveSetSize, next.Atx.ActiveSetSize)
+	r.Equal(coinbase, next.Atx.Coinbase)
+	r.Nil(next.Atx.Sig)
+	r.NotZero(next.Size)
+
+	// the dry run neither publishes the atx nor stores its challenge
+	r.Nil(net.lastTransmission)
+	stored, err := b.readChallenge()
+	r.NoError(err)
+	r.Nil(stored)
+
+	// the challenge in flight is the one the next atx is built on
+	inFlight := newChallenge(nodeID, 7, prevAtxID, prevAtxID, postGenesisEpochLayer)
+	r.NoError(b.storeChallenge(&inFlight))
+	next, err = b.NextAtx()
+	r.NoError(err)
+	r.True(next.InFlight)
+	r.Equal(uint64(7), next.Atx.Sequence)
+	r.Equal(prevAtxID, next.Atx.PrevATXID)
+}
+
 func TestStartPost(t *testing.T) {
 	id := types.NodeID{Key: "aaaaaa", VRFPublicKey: []byte("bbbbb")}
 	coinbase := types.HexToAddress("0xaaa")
@@ -811,7 +943,7 @@ func TestActivationDb_CalcActiveSetFromViewHighConcurrency(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
 	for i := 0; i < ff; i++ {
 		go func() {
-			num, err := atxdb.CalcActiveSetFromView(genView(), atx.PubLayerID.GetEpoch(layersPerEpochBig))
+			num, err := atxdb.CalcActiveSetFromView(context.Background(), genView(), atx.PubLayerID.GetEpoch(layersPerEpochBig))
 			assert.NoError(t, err)
 			assert.Equal(t, 3, int(num))
 			assert.NoError(t, err)
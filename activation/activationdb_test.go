@@ -2,19 +2,24 @@ package activation
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/rand"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"math/big"
 	"os"
+	"reflect"
 	"sort"
 	"sync"
 	"testing"
@@ -59,10 +64,18 @@ func (MockState) LoadState(types.LayerID) error {
 	panic("implement me")
 }
 
+func (MockState) RevertTransactions(types.LayerID, []*types.Transaction) error {
+	panic("implement me")
+}
+
 func (MockState) GetStateRoot() types.Hash32 {
 	panic("implement me")
 }
 
+func (MockState) GetLayerStateRoot(types.LayerID) (types.Hash32, error) {
+	panic("implement me")
+}
+
 func (MockState) ValidateNonceAndBalance(*types.Transaction) error {
 	panic("implement me")
 }
@@ -89,7 +102,7 @@ type ATXDBMock struct {
 	activeSet   uint32
 }
 
-func (mock *ATXDBMock) CalcActiveSetSize(types.EpochID, map[types.BlockID]struct{}) (map[string]struct{}, error) {
+func (mock *ATXDBMock) CalcActiveSetSize(context.Context, types.EpochID, map[types.BlockID]struct{}) (map[string]struct{}, error) {
 	log.Debug("waiting lock")
 	mock.workSymLock.Lock()
 	defer mock.workSymLock.Unlock()
@@ -221,6 +234,124 @@ func TestATX_ActiveSetForLayerView(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestATX_ActiveSetForOverlappingViews(t *testing.T) {
+	r := require.New(t)
+	atxdb, layers, _ := getAtxDb(t.Name())
+	atxdb.LayersPerEpoch = 6
+	coinbase := types.HexToAddress("aaaa")
+	poetRef := []byte{0xba, 0xb0}
+	var atxs []*types.ActivationTx
+	for i := 0; i < 4; i++ {
+		id := types.NodeID{Key: rndStr(), VRFPublicKey: []byte("anton")}
+		atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 0, []types.BlockID{}, &types.NIPST{})
+		hash, err := atx.NIPSTChallenge.Hash()
+		r.NoError(err)
+		atx.Nipst = NewNIPSTWithChallenge(hash, poetRef)
+		atxs = append(atxs, atx)
+	}
+
+	// two layers of blocks in epoch 0 include the atxs, the layers of epoch 1 view all the blocks of the layer before
+	blocks := createLayerWithAtx2(t, layers, 1, 2, atxs[:2], []types.BlockID{}, []types.BlockID{})
+	blocks = createLayerWithAtx2(t, layers, 2, 2, atxs[2:], blocks, blocks)
+	for i := 3; i <= 8; i++ {
+		blocks = createLayerWithAtx2(t, layers, types.LayerID(i), 2, []*types.ActivationTx{}, blocks, blocks)
+	}
+	toView := func(ids []types.BlockID) map[types.BlockID]struct{} {
+		view := make(map[types.BlockID]struct{})
+		for _, id := range ids {
+			view[id] = struct{}{}
+		}
+		return view
+	}
+
+	actives, err := atxdb.CalcActiveSetSize(1, toView(blocks))
+	r.NoError(err)
+	r.Len(actives, 4)
+	// every block from the first layer of epoch 0 was traversed and memoized
+	r.Equal(2*8, atxdb.blockAtxs.Len())
+
+	// a view that shares all but its newest layer only traverses the new blocks
+	next := createLayerWithAtx2(t, layers, 9, 2, []*types.ActivationTx{}, blocks, blocks)
+	actives, err = atxdb.CalcActiveSetSize(1, toView(append(next, blocks[:1]...)))
+	r.NoError(err)
+	r.Len(actives, 4)
+	r.Equal(2*9, atxdb.blockAtxs.Len())
+
+	// blocks that don't introduce new atxs share the set of the blocks they view
+	first, found := atxdb.blockAtxs.Get(1, blocks[0])
+	r.True(found)
+	second, found := atxdb.blockAtxs.Get(1, next[1])
+	r.True(found)
+	r.Len(first, 4)
+	r.Equal(reflect.ValueOf(first).Pointer(), reflect.ValueOf(second).Pointer())
+}
+
+func TestATX_ActiveSetFromViewCanceled(t *testing.T) {
+	r := require.New(t)
+	atxdb, layers, _ := getAtxDb(t.Name())
+	blocks := createLayerWithAtx2(t, layers, 1, 2, []*types.ActivationTx{}, []types.BlockID{}, []types.BlockID{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := atxdb.CalcActiveSetFromView(ctx, blocks, 1)
+	r.Equal(context.Canceled, err)
+	r.Zero(atxdb.blockAtxs.Len())
+
+	_, err = atxdb.CalcActiveSetFromView(context.Background(), blocks, 1)
+	r.NoError(err)
+}
+
+type atxFetcherMock struct {
+	atxdb   *DB
+	atxs    map[types.ATXID]*types.ActivationTx
+	fetched int
+}
+
+func (f *atxFetcherMock) FetchAtx(ctx context.Context, id types.ATXID) error {
+	f.fetched++
+	atx, found := f.atxs[id]
+	if !found {
+		return database.ErrNotFound
+	}
+	return f.atxdb.ProcessAtx(atx)
+}
+
+func TestATX_ActiveSetFromViewFetchesMissingAtxs(t *testing.T) {
+	r := require.New(t)
+	activesetCache.Purge()
+	atxdb, layers, _ := getAtxDb(t.Name())
+
+	id1 := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("anton")}
+	id2 := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("anton")}
+	stored := newActivationTx(id1, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 0, []types.BlockID{}, &types.NIPST{})
+	missing := newActivationTx(id2, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 0, []types.BlockID{}, &types.NIPST{})
+	r.NoError(atxdb.ProcessAtx(stored))
+
+	// the block references an atx that was never stored, e.g. after an interrupted sync
+	block := types.NewExistingBlock(1, []byte(rand.String(8)))
+	block.ATXIDs = []types.ATXID{stored.ID(), missing.ID()}
+	r.NoError(layers.AddBlockWithTxs(block, nil, nil))
+	view := []types.BlockID{block.ID()}
+
+	_, err := atxdb.CalcActiveSetFromView(context.Background(), view, 1)
+	r.Error(err)
+
+	// a fetcher that can't find the atx fails the calculation
+	fetcher := &atxFetcherMock{atxdb: atxdb, atxs: map[types.ATXID]*types.ActivationTx{}}
+	atxdb.SetAtxFetcher(fetcher)
+	_, err = atxdb.CalcActiveSetFromView(context.Background(), view, 1)
+	r.Error(err)
+	r.Equal(1, fetcher.fetched)
+
+	fetcher.atxs[missing.ID()] = missing
+	size, err := atxdb.CalcActiveSetFromView(context.Background(), view, 1)
+	r.NoError(err)
+	r.Equal(uint32(2), size)
+	r.Equal(2, fetcher.fetched)
+	_, err = atxdb.GetAtxHeader(missing.ID())
+	r.NoError(err)
+}
+
 func TestMesh_ActiveSetForLayerView2(t *testing.T) {
 	atxdb, _, _ := getAtxDb(t.Name())
 	actives, err := atxdb.CalcActiveSetSize(0, nil)
@@ -264,7 +395,7 @@ func TestActivationDb_CalcActiveSetFromViewWithCache(t *testing.T) {
 	mck.workSymLock.Lock()
 	for i := 0; i < 100; i++ {
 		go func() {
-			num, err := atxdb.CalcActiveSetFromView(atx.View, atx.PubLayerID.GetEpoch(layersPerEpochBig))
+			num, err := atxdb.CalcActiveSetFromView(context.Background(), atx.View, atx.PubLayerID.GetEpoch(layersPerEpochBig))
 			assert.NoError(t, err)
 			assert.Equal(t, 3, int(num))
 			assert.NoError(t, err)
@@ -291,6 +422,8 @@ func Test_CalcActiveSetFromView(t *testing.T) {
 		newActivationTx(id2, 0, *types.EmptyATXID, 300, 0, *types.EmptyATXID, coinbase2, 0, []types.BlockID{}, &types.NIPST{}),
 		newActivationTx(id3, 0, *types.EmptyATXID, 435, 0, *types.EmptyATXID, coinbase3, 0, []types.BlockID{}, &types.NIPST{}),
 	}
+	atxs[1].SpaceUnits = 4
+	atxs[1].CalcAndSetID()
 
 	poetRef := []byte{0xba, 0xb0}
 	for _, atx := range atxs {
@@ -304,10 +437,15 @@ func Test_CalcActiveSetFromView(t *testing.T) {
 	blocks = createLayerWithAtx(t, layers, 100, 10, []*types.ActivationTx{}, blocks, blocks)
 
 	atx := newActivationTx(id1, 1, atxs[0].ID(), 1000, 0, atxs[0].ID(), coinbase1, 3, blocks, &types.NIPST{})
-	num, err := atxdb.CalcActiveSetFromView(atx.View, atx.PubLayerID.GetEpoch(layersPerEpochBig))
+	num, err := atxdb.CalcActiveSetFromView(context.Background(), atx.View, atx.PubLayerID.GetEpoch(layersPerEpochBig))
 	assert.NoError(t, err)
 	assert.Equal(t, 3, int(num))
 
+	// id1 and id3 count for a single space unit each, id2 committed 4 units
+	spaceUnits, err := atxdb.CalcActiveSetSpaceUnitsFromView(context.Background(), atx.View, atx.PubLayerID.GetEpoch(layersPerEpochBig))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, int(spaceUnits))
+
 	// check that further atxs dont affect current epoch count
 	atxs2 := []*types.ActivationTx{
 		newActivationTx(types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("anton")}, 0, *types.EmptyATXID, 1012, 0, atxs[0].ID(), coinbase1, 0, []types.BlockID{}, &types.NIPST{}),
@@ -343,16 +481,16 @@ func Test_CalcActiveSetFromView(t *testing.T) {
 		return bytes.Compare(view[i].Bytes(), view[j].Bytes()) > 0 // sort view in wrong order
 	})
 	atx2 := newActivationTx(id3, 0, *types.EmptyATXID, 1435, 0, *types.EmptyATXID, coinbase3, 6, view, &types.NIPST{})
-	num, err = atxdb.CalcActiveSetFromView(atx2.View, atx2.PubLayerID.GetEpoch(layersPerEpochBig))
+	num, err = atxdb.CalcActiveSetFromView(context.Background(), atx2.View, atx2.PubLayerID.GetEpoch(layersPerEpochBig))
 	assert.NoError(t, err)
 	assert.Equal(t, 3, int(num))
 
 	// put a fake value in the cache and ensure that it's used
 	viewHash := types.CalcBlocksHash12(atx2.View)
 	activesetCache.Purge()
-	activesetCache.Add(viewHash, 8)
+	activesetCache.Add(viewHash, 8, 8)
 
-	num, err = atxdb.CalcActiveSetFromView(atx2.View, atx2.PubLayerID.GetEpoch(layersPerEpochBig))
+	num, err = atxdb.CalcActiveSetFromView(context.Background(), atx2.View, atx2.PubLayerID.GetEpoch(layersPerEpochBig))
 	assert.NoError(t, err)
 	assert.Equal(t, 8, int(num))
 
@@ -363,13 +501,119 @@ func Test_CalcActiveSetFromView(t *testing.T) {
 	assert.NoError(t, err)
 	viewHash = types.CalcHash12(viewBytes)
 	activesetCache.Purge()
-	activesetCache.Add(viewHash, 8)
+	activesetCache.Add(viewHash, 8, 8)
 
-	num, err = atxdb.CalcActiveSetFromView(atx2.View, atx2.PubLayerID.GetEpoch(layersPerEpochBig))
+	num, err = atxdb.CalcActiveSetFromView(context.Background(), atx2.View, atx2.PubLayerID.GetEpoch(layersPerEpochBig))
 	assert.NoError(t, err)
 	assert.Equal(t, 3, int(num))
 }
 
+func TestActivationDb_CalcActiveSetFromFirstSeen(t *testing.T) {
+	r := require.New(t)
+	activesetCache.Purge()
+	atxdb, layers, _ := getAtxDb("t6")
+	upgrades, err := upgrade.NewSchedule(map[string]int{string(upgrade.FirstSeenActiveSet): 2})
+	r.NoError(err)
+	atxdb.SetUpgrades(upgrades)
+	atxdb.SetActiveSetGracePeriod(3)
+
+	coinbase := types.HexToAddress("aaaa")
+	atxs := make([]*types.ActivationTx, 3)
+	for i := range atxs {
+		id := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("anton")}
+		atxs[i] = newActivationTx(id, 0, *types.EmptyATXID, 1001, 0, *types.EmptyATXID, coinbase, 0, []types.BlockID{}, &types.NIPST{})
+	}
+	atxs[1].SpaceUnits = 4
+	atxs[1].CalcAndSetID()
+
+	// first seen in the previous epoch, in the grace period, and after it
+	createLayerWithAtx(t, layers, 1005, 2, atxs[:1], []types.BlockID{}, []types.BlockID{})
+	createLayerWithAtx(t, layers, 2001, 1, atxs[1:2], []types.BlockID{}, []types.BlockID{})
+	createLayerWithAtx(t, layers, 2004, 1, atxs[2:], []types.BlockID{}, []types.BlockID{})
+
+	r.Equal(types.LayerID(1000), atxdb.ActiveSetLayer(1))
+	r.Equal(types.LayerID(2003), atxdb.ActiveSetLayer(2))
+	num, err := atxdb.CalcActiveSet(context.Background(), nil, 2)
+	r.NoError(err)
+	r.Equal(2, int(num))
+
+	atx := newActivationTx(atxs[0].NodeID, 1, atxs[0].ID(), 2010, 0, atxs[0].ID(), coinbase, 2, nil, &types.NIPST{})
+	r.NoError(atxdb.StoreAtx(2, atx))
+	spaceUnits, err := atxdb.GetActiveSetSpaceUnits(atx.ID())
+	r.NoError(err)
+	r.Equal(5, int(spaceUnits))
+
+	// a block of the grace period that arrives late is counted
+	createLayerWithAtx(t, layers, 2002, 1, atxs[2:], []types.BlockID{}, []types.BlockID{})
+	num, err = atxdb.CalcActiveSet(context.Background(), nil, 2)
+	r.NoError(err)
+	r.Equal(3, int(num))
+
+	// before the upgrade the active set is counted from the view
+	num, err = atxdb.CalcActiveSet(context.Background(), nil, 1)
+	r.NoError(err)
+	r.Zero(num)
+}
+
+type failingBatchDB struct {
+	database.Database
+}
+
+func (db failingBatchDB) NewBatch() database.Batch {
+	return failingBatch{db.Database.NewBatch()}
+}
+
+type failingBatch struct {
+	database.Batch
+}
+
+func (failingBatch) Write() error {
+	return fmt.Errorf("disk full")
+}
+
+func TestActivationDb_StoreAtxAtomic(t *testing.T) {
+	r := require.New(t)
+	lg := log.NewDefault("t6")
+	store := database.NewMemDatabase()
+	atxdb := NewDB(failingBatchDB{store}, NewIdentityStore(database.NewMemDatabase()), mesh.NewMemMeshDB(lg), layersPerEpochBig, &ValidatorMock{}, lg)
+
+	id := types.NodeID{Key: uuid.New().String()}
+	atx := newActivationTx(id, 0, *types.EmptyATXID, 1001, 0, *types.EmptyATXID, types.HexToAddress("aaaa"), 3, []types.BlockID{}, &types.NIPST{})
+	received := atxdb.AwaitAtx(atx.ID())
+	r.Error(atxdb.StoreAtx(1, atx))
+
+	// nothing of the atx was written, and subscribers weren't notified
+	_, err := atxdb.GetAtxHeader(atx.ID())
+	r.Error(err)
+	_, err = atxdb.GetNodeLastAtxID(id)
+	r.Error(err)
+	ids, err := atxdb.EpochAtxIDs(2, nil, 10)
+	r.NoError(err)
+	r.Empty(ids)
+	r.False(atxdb.MayHaveAtxForEpoch(id, 2))
+	_, err = atxdb.GetPosAtxID()
+	r.Error(err)
+	select {
+	case <-received:
+		r.Fail("atx subscribers notified of an atx that wasn't stored")
+	default:
+	}
+
+	// the atx is stored in full once writing succeeds
+	atxdb.atxs = store
+	r.NoError(atxdb.StoreAtx(1, atx))
+	<-received
+	_, err = atxdb.GetAtxHeader(atx.ID())
+	r.NoError(err)
+	last, err := atxdb.GetNodeLastAtxID(id)
+	r.NoError(err)
+	r.Equal(atx.ID(), last)
+	r.True(atxdb.MayHaveAtxForEpoch(id, 2))
+	posAtx, err := atxdb.GetPosAtxID()
+	r.NoError(err)
+	r.Equal(atx.ID(), posAtx)
+}
+
 func TestActivationDb_GetNodeLastAtxId(t *testing.T) {
 	r := require.New(t)
 
@@ -391,6 +635,38 @@ func TestActivationDb_GetNodeLastAtxId(t *testing.T) {
 	r.Equal(atx2.ShortString(), id.ShortString(), "atx1.ShortString(): %v", atx1.ShortString())
 }
 
+func TestActivationDb_GetNodeAtxIDs(t *testing.T) {
+	r := require.New(t)
+
+	atxdb, _, _ := getAtxDb(t.Name())
+	id1 := types.NodeID{Key: uuid.New().String()}
+	id2 := types.NodeID{Key: uuid.New().String()}
+	coinbase := types.HexToAddress("aaaa")
+
+	_, err := atxdb.GetNodeAtxIDs(id1)
+	r.Error(err)
+
+	var expected []types.ATXID
+	prevAtx := *types.EmptyATXID
+	// sequence numbers above 255 fail if they're encoded using LittleEndian
+	for i, seq := range []uint64{0, 1, 255, 256, 1000} {
+		epoch := types.EpochID(i + 1)
+		atx := types.NewActivationTx(newChallenge(id1, seq, prevAtx, prevAtx, epoch.FirstLayer(atxdb.LayersPerEpoch)), coinbase, 3, []types.BlockID{}, &types.NIPST{}, nil)
+		r.NoError(atxdb.StoreAtx(epoch, atx))
+		expected = append(expected, atx.ID())
+		prevAtx = atx.ID()
+	}
+	other := types.NewActivationTx(newChallenge(id2, 0, *types.EmptyATXID, *types.EmptyATXID, types.EpochID(1).FirstLayer(atxdb.LayersPerEpoch)), coinbase, 3, []types.BlockID{}, &types.NIPST{}, nil)
+	r.NoError(atxdb.StoreAtx(1, other))
+
+	ids, err := atxdb.GetNodeAtxIDs(id1)
+	r.NoError(err)
+	r.Equal(expected, ids)
+	ids, err = atxdb.GetNodeAtxIDs(id2)
+	r.NoError(err)
+	r.Equal([]types.ATXID{other.ID()}, ids)
+}
+
 func Test_DBSanity(t *testing.T) {
 	atxdb, _, _ := getAtxDb("t6")
 
@@ -405,14 +681,13 @@ func Test_DBSanity(t *testing.T) {
 	atx2 := newActivationTx(id1, 0, *types.EmptyATXID, 1001, 0, *types.EmptyATXID, coinbase2, 3, []types.BlockID{}, &types.NIPST{})
 	atx3 := newActivationTx(id1, 0, *types.EmptyATXID, 2001, 0, *types.EmptyATXID, coinbase3, 3, []types.BlockID{}, &types.NIPST{})
 
-	err := atxdb.storeAtxUnlocked(atx1)
-	assert.NoError(t, err)
-	err = atxdb.storeAtxUnlocked(atx2)
-	assert.NoError(t, err)
-	err = atxdb.storeAtxUnlocked(atx3)
-	assert.NoError(t, err)
+	for _, atx := range []*types.ActivationTx{atx1, atx2, atx3} {
+		key, err := types.NewAtxKey(atx.ID())
+		assert.NoError(t, err)
+		assert.NoError(t, atxdb.storeAtxUnlocked(atxdb.atxs, key, atx))
+	}
 
-	err = atxdb.addAtxToNodeID(id1, atx1)
+	_, err := atxdb.addAtxToNodeID(atxdb.atxs, id1, atx1)
 	assert.NoError(t, err)
 	id, err := atxdb.GetNodeLastAtxID(id1)
 	assert.NoError(t, err)
@@ -422,10 +697,10 @@ func Test_DBSanity(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, atx1.ID(), id)
 
-	err = atxdb.addAtxToNodeID(id2, atx2)
+	_, err = atxdb.addAtxToNodeID(atxdb.atxs, id2, atx2)
 	assert.NoError(t, err)
 
-	err = atxdb.addAtxToNodeID(id1, atx3)
+	_, err = atxdb.addAtxToNodeID(atxdb.atxs, id1, atx3)
 	assert.NoError(t, err)
 
 	id, err = atxdb.GetNodeLastAtxID(id2)
@@ -469,7 +744,7 @@ func Test_Wrong_CalcActiveSetFromView(t *testing.T) {
 	blocks = createLayerWithAtx(t, layers, 100, 10, []*types.ActivationTx{}, blocks, blocks)
 
 	atx := newActivationTx(id1, 1, atxs[0].ID(), 1000, 0, atxs[0].ID(), coinbase1, 20, blocks, &types.NIPST{})
-	num, err := atxdb.CalcActiveSetFromView(atx.View, atx.PubLayerID.GetEpoch(layersPerEpoch))
+	num, err := atxdb.CalcActiveSetFromView(context.Background(), atx.View, atx.PubLayerID.GetEpoch(layersPerEpoch))
 	assert.NoError(t, err)
 	assert.NotEqual(t, 20, int(num))
 
@@ -564,11 +839,152 @@ func TestActivationDB_ValidateAtx(t *testing.T) {
 	err = atxdb.StoreAtx(1, prevAtx)
 	assert.NoError(t, err)
 
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.NoError(t, err)
 
 	err = atxdb.ContextuallyValidateAtx(atx.ActivationTxHeader)
 	assert.NoError(t, err)
+
+	// the NIPST of an atx that was found valid isn't verified again, unless it was found valid by another version
+	validator := &countingValidator{}
+	atxdb.nipstValidators = NewValidatorRegistry(validator)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, validator.validated)
+	signedHash, err := signedAtxHash(atx)
+	assert.NoError(t, err)
+	assert.NoError(t, atxdb.atxs.Put(getValidAtxKey(signedHash), util.Uint64ToBytes(atxValidationVersion+1)))
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, validator.validated)
+	atxdb.nipstValidators = NewValidatorRegistry(&ValidatorMock{})
+
+	// declares more ticks than the PoET proof attests to
+	atx = newActivationTx(idx1, 1, prevAtx.ID(), 1012, 0, prevAtx.ID(), coinbase1, 3, blocks, &types.NIPST{})
+	atx.EndTick = 1
+	atx.CalcAndSetID()
+	hash, err = atx.NIPSTChallenge.Hash()
+	assert.NoError(t, err)
+	atx.Nipst = NewNIPSTWithChallenge(hash, poetRef)
+	err = SignAtx(signer, atx)
+	assert.NoError(t, err)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	assert.EqualError(t, err, "atx declares 1 ticks but its PoET proof attests to 0")
+
+	// declares more space units than the PoST commits
+	atx = newActivationTx(idx1, 1, prevAtx.ID(), 1012, 0, prevAtx.ID(), coinbase1, 3, blocks, &types.NIPST{})
+	atx.SpaceUnits = 2
+	atx.CalcAndSetID()
+	hash, err = atx.NIPSTChallenge.Hash()
+	assert.NoError(t, err)
+	atx.Nipst = NewNIPSTWithChallenge(hash, poetRef)
+	err = SignAtx(signer, atx)
+	assert.NoError(t, err)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	assert.EqualError(t, err, "atx declares 2 space units but its PoST commits 0")
+
+	// validated by the validator of the atx's target epoch
+	assert.NoError(t, atxdb.RegisterNipstValidator(1, atx.TargetEpoch(atxdb.LayersPerEpoch)+1, &spaceUnitsValidator{units: 1}))
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	assert.EqualError(t, err, "atx declares 2 space units but its PoST commits 0")
+	assert.NoError(t, atxdb.RegisterNipstValidator(2, atx.TargetEpoch(atxdb.LayersPerEpoch)+2, &spaceUnitsValidator{units: 2}))
+	atxdb.nipstValidators = NewValidatorRegistry(&ValidatorMock{})
+	assert.NoError(t, atxdb.RegisterNipstValidator(1, atx.TargetEpoch(atxdb.LayersPerEpoch), &spaceUnitsValidator{units: 2}))
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	assert.NoError(t, err)
+}
+
+type spaceUnitsValidator struct {
+	ValidatorMock
+	units uint32
+}
+
+func (v *spaceUnitsValidator) NumOfSpaceUnits(*types.NIPST) uint32 {
+	return v.units
+}
+
+type countingValidator struct {
+	ValidatorMock
+	validated int
+}
+
+func (v *countingValidator) Validate(signing.PublicKey, *types.NIPST, types.Hash32) error {
+	v.validated++
+	return nil
+}
+
+func TestActivationDB_ProcessAtxRecordsTicks(t *testing.T) {
+	atxdb, _, _ := getAtxDb("t8")
+	id := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("anton")}
+	atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, types.HexToAddress("aaaa"), 0, []types.BlockID{}, &types.NIPST{})
+
+	_, err := atxdb.GetAtxTicks(atx.ID())
+	assert.Error(t, err)
+
+	err = atxdb.ProcessAtx(atx)
+	assert.NoError(t, err)
+
+	ticks, err := atxdb.GetAtxTicks(atx.ID())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), ticks)
+}
+
+type ticksErrValidator struct {
+	ValidatorMock
+}
+
+func (*ticksErrValidator) NumOfTicks(*types.NIPST) (uint64, error) {
+	return 0, errors.New("no poet proof")
+}
+
+func TestActivationDB_ProcessAtxTicksError(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb("t8")
+	atxdb.nipstValidators = NewValidatorRegistry(&ticksErrValidator{})
+	id := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("anton")}
+	atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, types.HexToAddress("aaaa"), 0, []types.BlockID{}, &types.NIPST{})
+
+	r.Error(atxdb.ProcessAtx(atx))
+	_, err := atxdb.GetAtxHeader(atx.ID())
+	r.Error(err)
+	_, err = atxdb.GetAtxTicks(atx.ID())
+	r.Error(err)
+}
+
+func TestActivationDB_ReplayIntents(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb("t8")
+	id := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("anton")}
+	atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, types.HexToAddress("aaaa"), 0, []types.BlockID{}, &types.NIPST{})
+
+	// processing an atx clears its intent
+	r.NoError(atxdb.ProcessAtx(atx))
+	_, err := atxdb.atxs.Get(append([]byte(atxIntentPrefix), atx.ID().Bytes()...))
+	r.Equal(database.ErrNotFound, err)
+
+	// the node crashed after storing the header of the atx
+	atx = newActivationTx(id, 1, atx.ID(), types.LayerID(1).Add(atxdb.LayersPerEpoch), 0, atx.ID(), types.HexToAddress("aaaa"), 0, []types.BlockID{}, &types.NIPST{})
+	atxBytes, err := types.InterfaceToBytes(atx)
+	r.NoError(err)
+	r.NoError(atxdb.intents.Begin(atx.ID().Bytes(), atxBytes))
+	headerBytes, err := types.InterfaceToBytes(atx.ActivationTxHeader)
+	r.NoError(err)
+	key, err := types.NewAtxKey(atx.ID())
+	r.NoError(err)
+	r.NoError(atxdb.atxs.Put(getAtxHeaderKey(key), headerBytes))
+	_, err = atxdb.GetFullAtx(atx.ID())
+	r.Error(err)
+
+	r.NoError(atxdb.ReplayIntents())
+	_, err = atxdb.GetFullAtx(atx.ID())
+	r.NoError(err)
+	_, err = atxdb.GetAtxTicks(atx.ID())
+	r.NoError(err)
+	lastAtx, err := atxdb.GetNodeLastAtxID(id)
+	r.NoError(err)
+	r.Equal(atx.ID(), lastAtx)
+	_, err = atxdb.atxs.Get(append([]byte(atxIntentPrefix), atx.ID().Bytes()...))
+	r.Equal(database.ErrNotFound, err)
 }
 
 func TestActivationDB_ValidateAtxErrors(t *testing.T) {
@@ -607,28 +1023,39 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx := newActivationTx(idx1, 0, prevAtx.ID(), 1012, 0, posAtx.ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "sequence number is not one more than prev sequence number")
+	assert.Equal(t, failedPrevAtx, atxValidationFailure(err))
+
+	// Start tick is not the positioning atx end tick.
+	atx = newActivationTx(idx1, 1, prevAtx.ID(), 1012, 5, posAtx.ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	err = SignAtx(signer, atx)
+	assert.NoError(t, err)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	assert.EqualError(t, err, "start tick (5) is not the positioning atx end tick (0)")
+	assert.Equal(t, failedTicks, atxValidationFailure(err))
 
 	// Wrong active set.
 	atx = newActivationTx(idx1, 1, prevAtx.ID(), 1012, 0, posAtx.ID(), coinbase, 10, []types.BlockID{}, &types.NIPST{})
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "atx contains view with unequal active ids (10) than seen (0)")
+	assert.Equal(t, ActiveSetDivergence{Checked: 1, Mismatched: 1}, atxdb.ActiveSetDivergence(1))
+	assert.Equal(t, ActiveSetDivergence{}, atxdb.ActiveSetDivergence(2))
 
 	// Wrong positioning atx.
 	atx = newActivationTx(idx1, 1, prevAtx.ID(), 1012, 0, atxs[0].ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "expected distance of one epoch (1000 layers) from pos ATX but found 1011")
 
 	// Wrong prevATx.
 	atx = newActivationTx(idx1, 1, atxs[0].ID(), 1012, 0, posAtx.ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, fmt.Sprintf("previous ATX belongs to different miner. atx.ID: %v, atx.NodeID: %v, prevAtx.NodeID: %v", atx.ShortString(), atx.NodeID.Key, atxs[0].NodeID.Key))
 
 	// Wrong layerId.
@@ -642,7 +1069,7 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx = newActivationTx(idx1, 1, prevAtx.ID(), 1012, 0, posAtx2.ID(), coinbase, 3, []types.BlockID{}, npst)
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "atx layer (1012) must be after positioning atx layer (1020)")
 
 	// Atx already exists.
@@ -656,7 +1083,9 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	err = atxdb.StoreAtx(1, atx)
 	assert.NoError(t, err)
 	atx = newActivationTx(idx1, 1, prevAtx.ID(), 12, 0, posAtx.ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
-	iter := atxdb.atxs.Find(getNodeAtxPrefix(atx.NodeID))
+	node, err := types.NewNodeKey(atx.NodeID)
+	assert.NoError(t, err)
+	iter := atxdb.atxs.Find(getNodeAtxPrefix(node))
 	for iter.Next() {
 		err = atxdb.atxs.Delete(iter.Key())
 		assert.NoError(t, err)
@@ -671,7 +1100,7 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx = newActivationTx(idx1, 0, *types.EmptyATXID, 1012, 0, posAtx.ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "no prevATX declared, but commitment proof is not included")
 
 	// Prev atx not declared but commitment merkle root not included.
@@ -679,7 +1108,7 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx.Commitment = commitment
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "no prevATX declared, but commitment merkle root is not included in challenge")
 
 	// Challenge and commitment merkle root mismatch.
@@ -689,7 +1118,7 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx.CommitmentMerkleRoot[0]++
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "commitment merkle root included in challenge is not equal to the merkle root included in the proof")
 
 	// Prev atx declared but commitment is included.
@@ -697,7 +1126,7 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx.Commitment = commitment
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "prevATX declared, but commitment proof is included")
 
 	// Prev atx declared but commitment merkle root is included.
@@ -705,14 +1134,14 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx.CommitmentMerkleRoot = commitment.MerkleRoot
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "prevATX declared, but commitment merkle root is included in challenge")
 
 	// Prev atx has publication layer in the same epoch as the atx.
 	atx = newActivationTx(idx1, 1, prevAtx.ID(), 100, 0, posAtx.ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "prevAtx epoch (0, layer 100) isn't older than current atx epoch (0, layer 100)")
 
 	// NodeID and etracted pubkey dont match
@@ -721,8 +1150,80 @@ func TestActivationDB_ValidateAtxErrors(t *testing.T) {
 	atx.CommitmentMerkleRoot = append([]byte{}, commitment.MerkleRoot...)
 	err = SignAtx(signer, atx)
 	assert.NoError(t, err)
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "node ids don't match")
+	assert.Equal(t, failedSignature, atxValidationFailure(err))
+}
+
+func TestActivationDB_ValidateAtxUpgrades(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	signer := signing.NewEdSigner()
+	id := types.NodeID{Key: signer.PublicKey().String(), VRFPublicKey: []byte("vrf")}
+
+	challenge := newChallenge(id, 0, *types.EmptyATXID, *types.EmptyATXID, 1)
+	challenge.CommitmentMerkleRoot = []byte("commitment")
+	hash, err := challenge.Hash()
+	r.NoError(err)
+	atx := types.NewActivationTx(challenge, types.Address{}, 0, []types.BlockID{}, NewNIPSTWithChallenge(hash, []byte{0xba, 0xbe}),
+		&types.PostProof{MerkleRoot: challenge.CommitmentMerkleRoot})
+	r.NoError(SignAtx(signer, atx))
+
+	// without a schedule, and before the upgrade activates, atxs without a coinbase are valid
+	r.NoError(atxdb.SyntacticallyValidateAtx(context.Background(), atx))
+	upgrades, err := upgrade.NewSchedule(map[string]int{string(upgrade.AtxCoinbaseRequired): 1})
+	r.NoError(err)
+	atxdb.SetUpgrades(upgrades)
+	r.NoError(atxdb.SyntacticallyValidateAtx(context.Background(), atx))
+
+	upgrades, err = upgrade.NewSchedule(map[string]int{string(upgrade.AtxCoinbaseRequired): 0})
+	r.NoError(err)
+	atxdb.SetUpgrades(upgrades)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	r.Error(err)
+	r.Contains(err.Error(), "declares no coinbase")
+	r.Equal(failedCoinbase, atxValidationFailure(err))
+}
+
+func TestActivationDB_ValidateAtxHeader(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	signer := signing.NewEdSigner()
+	id := types.NodeID{Key: signer.PublicKey().String(), VRFPublicKey: []byte("vrf")}
+
+	challenge := newChallenge(id, 0, *types.EmptyATXID, *types.EmptyATXID, 1)
+	challenge.CommitmentMerkleRoot = []byte("commitment")
+	hash, err := challenge.Hash()
+	r.NoError(err)
+	atx := types.NewActivationTx(challenge, types.HexToAddress("aaaa"), 0, []types.BlockID{}, NewNIPSTWithChallenge(hash, []byte{0xba, 0xbe}),
+		&types.PostProof{MerkleRoot: challenge.CommitmentMerkleRoot})
+	r.NoError(SignAtxHeader(signer, atx))
+	nipstHash, err := atx.Nipst.Hash()
+	r.NoError(err)
+	header := *atx
+	inner := *atx.InnerActivationTx
+	inner.Nipst = nil
+	header.InnerActivationTx = &inner
+
+	// before the upgrade, atxs sign their NIPST and aren't gossiped without it
+	err = atxdb.SyntacticallyValidateAtxHeader(context.Background(), &header, nipstHash)
+	r.Error(err)
+	r.Equal(failedSignature, atxValidationFailure(err))
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	r.EqualError(err, "node ids don't match")
+
+	upgrades, err := upgrade.NewSchedule(map[string]int{string(upgrade.HeaderFirstAtxGossip): 0})
+	r.NoError(err)
+	atxdb.SetUpgrades(upgrades)
+	r.NoError(atxdb.SyntacticallyValidateAtxHeader(context.Background(), &header, nipstHash))
+	r.NoError(atxdb.SyntacticallyValidateAtx(context.Background(), atx))
+
+	// the signature commits to the NIPST
+	err = atxdb.SyntacticallyValidateAtxHeader(context.Background(), &header, types.CalcHash32([]byte("other")))
+	r.EqualError(err, "node ids don't match")
+	atx.Nipst = NewNIPSTWithChallenge(hash, []byte{0xca, 0xfe})
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
+	r.EqualError(err, "node ids don't match")
 }
 
 func TestActivationDB_ValidateAndInsertSorted(t *testing.T) {
@@ -778,7 +1279,7 @@ func TestActivationDB_ValidateAndInsertSorted(t *testing.T) {
 	err = atxdb.StoreNodeIdentity(idx1)
 	assert.NoError(t, err)
 
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	assert.EqualError(t, err, "sequence number is not one more than prev sequence number")
 
 	err = atxdb.StoreAtx(1, atx)
@@ -892,12 +1393,12 @@ func BenchmarkActivationDb_SyntacticallyValidateAtx(b *testing.B) {
 	r.NoError(err)
 
 	start := time.Now()
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	fmt.Printf("\nSyntactic validation took %v\n", time.Since(start))
 	r.NoError(err)
 
 	start = time.Now()
-	err = atxdb.SyntacticallyValidateAtx(atx)
+	err = atxdb.SyntacticallyValidateAtx(context.Background(), atx)
 	fmt.Printf("\nSecond syntactic validation took %v\n", time.Since(start))
 	r.NoError(err)
 
@@ -968,6 +1469,80 @@ func BenchmarkNewActivationDb(b *testing.B) {
 	r.NoError(err)
 }
 
+// createActiveSetMesh adds layers of blocks to the mesh, each block voting for and viewing all the blocks of the layer
+// before it. The blocks of the first layer include the atxs of activeSetSize miners, published in layer 1. It returns
+// the blocks of the last layer.
+func createActiveSetMesh(b *testing.B, layers *mesh.Mesh, activeSetSize, blocksPerLayer, numberOfLayers int) []types.BlockID {
+	coinbase := types.HexToAddress("c012ba5e")
+	poetRef := []byte{0x12, 0x21}
+	var atxs []*types.ActivationTx
+	for i := 0; i < activeSetSize; i++ {
+		id := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("vrf")}
+		atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 0, []types.BlockID{}, &types.NIPST{})
+		hash, err := atx.NIPSTChallenge.Hash()
+		require.NoError(b, err)
+		atx.Nipst = NewNIPSTWithChallenge(hash, poetRef)
+		atxs = append(atxs, atx)
+	}
+
+	blocks := createLayerWithAtx2(b, layers, 0, blocksPerLayer, atxs, []types.BlockID{}, []types.BlockID{})
+	for i := 1; i < numberOfLayers; i++ {
+		blocks = createLayerWithAtx2(b, layers, types.LayerID(i), blocksPerLayer, []*types.ActivationTx{}, blocks, blocks)
+	}
+	return blocks
+}
+
+func BenchmarkDB_CalcActiveSetSize(b *testing.B) {
+	const activeSetSize = 300
+	nopLogger := log.NewDefault("").WithOptions(log.Nop)
+	atxdb, layers, _ := getAtxDb(b.Name())
+	atxdb.log = nopLogger
+	layers.Log = nopLogger
+
+	view := make(map[types.BlockID]struct{})
+	for _, id := range createActiveSetMesh(b, layers, activeSetSize, 50, 20) {
+		view[id] = struct{}{}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		actives, err := atxdb.CalcActiveSetSize(1, view)
+		require.NoError(b, err)
+		require.Len(b, actives, activeSetSize)
+	}
+}
+
+func BenchmarkDB_SyntacticallyValidateAtxUncached(b *testing.B) {
+	const activeSetSize = 300
+	nopLogger := log.NewDefault("").WithOptions(log.Nop)
+	atxdb, layers, _ := getAtxDb(b.Name())
+	atxdb.log = nopLogger
+	layers.Log = nopLogger
+	view := createActiveSetMesh(b, layers, activeSetSize, 50, 20)
+
+	posAtx, err := atxdb.GetPosAtxID()
+	require.NoError(b, err)
+	signer := signing.NewEdSigner()
+	id := types.NodeID{Key: signer.PublicKey().String(), VRFPublicKey: []byte("vrf")}
+	challenge := newChallenge(id, 0, *types.EmptyATXID, posAtx, layersPerEpochBig)
+	challenge.CommitmentMerkleRoot = []byte("commitment")
+	hash, err := challenge.Hash()
+	require.NoError(b, err)
+	atx := types.NewActivationTx(challenge, coinbase, activeSetSize, view, NewNIPSTWithChallenge(hash, []byte{0x12, 0x21}),
+		&types.PostProof{MerkleRoot: challenge.CommitmentMerkleRoot})
+	require.NoError(b, SignAtx(signer, atx))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// drop the active set sizes cached by earlier iterations, so that every validation traverses the view
+		b.StopTimer()
+		SetActivesetCacheSize(DefaultActivesetCacheSize)
+		b.StartTimer()
+
+		require.NoError(b, atxdb.SyntacticallyValidateAtx(context.Background(), atx))
+	}
+}
+
 func TestActivationDb_TopAtx(t *testing.T) {
 	r := require.New(t)
 
@@ -1093,6 +1668,36 @@ func TestActivationDb_AwaitAtx(t *testing.T) {
 	r.Len(atxdb.atxChannels, 0) // last unsubscribe clears the channel
 }
 
+func TestActivationDb_SubscribeAtx(t *testing.T) {
+	r := require.New(t)
+
+	lg := log.NewDefault("sigValidation")
+	idStore := NewIdentityStore(database.NewMemDatabase())
+	memesh := mesh.NewMemMeshDB(lg.WithName("meshDB"))
+	atxdb := NewDB(database.NewMemDatabase(), idStore, memesh, layersPerEpochBig, &ValidatorMock{}, lg.WithName("atxDB"))
+	id := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("vrf")}
+	atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+
+	ch, unsubscribe := atxdb.SubscribeAtx()
+	r.NoError(atxdb.StoreAtx(atx.TargetEpoch(layersPerEpoch), atx))
+	select {
+	case stored := <-ch:
+		r.Equal(atx.ID(), stored)
+	default:
+		r.Fail("not notified after ATX was stored")
+	}
+
+	// storing the atx again doesn't notify
+	r.NoError(atxdb.StoreAtx(atx.TargetEpoch(layersPerEpoch), atx))
+	r.Len(ch, 0)
+
+	unsubscribe()
+	r.Len(atxdb.atxSubs, 0)
+	other := newActivationTx(id, 1, atx.ID(), 2, 0, atx.ID(), coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	r.NoError(atxdb.StoreAtx(other.TargetEpoch(layersPerEpoch), other))
+	r.Len(ch, 0)
+}
+
 func TestActivationDb_ContextuallyValidateAtx(t *testing.T) {
 	r := require.New(t)
 
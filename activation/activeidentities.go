@@ -0,0 +1,56 @@
+package activation
+
+import (
+	"sync"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// we expect lookups for the current and next epochs, as in the epoch filter cache
+const activeIdentitiesCacheSize = 4
+
+// activeIdentities caches the identities with an atx targeting an epoch, as read from the epoch index. An epoch's
+// entry is built once and dropped whenever an atx targeting the epoch is stored, so that callers that check many
+// identities, e.g. hare for every message, don't read the database for each of them.
+type activeIdentities struct {
+	sync.Mutex
+	cache *lru.Cache
+}
+
+func newActiveIdentities() *activeIdentities {
+	cache, err := lru.New(activeIdentitiesCacheSize)
+	if err != nil {
+		log.Panic("could not initialize active identities cache: %v", err)
+	}
+	return &activeIdentities{cache: cache}
+}
+
+// ActiveIdentities returns the node keys of the identities that published an atx targeting epoch, with the ids of
+// their atxs. The map is shared by all callers, it must not be modified.
+func (db *DB) ActiveIdentities(epoch types.EpochID) map[string]types.ATXID {
+	db.identities.Lock()
+	defer db.identities.Unlock()
+	if ids, ok := db.identities.cache.Get(epoch); ok {
+		return ids.(map[string]types.ATXID)
+	}
+	ids := make(map[string]types.ATXID)
+	db.forEachEpochAtx(epoch, func(nodeKey string, id types.ATXID) bool {
+		ids[nodeKey] = id
+		return true
+	})
+	// read-only databases don't store the atxs, so they can't tell when an entry is stale
+	if !db.readOnly {
+		db.identities.cache.Add(epoch, ids)
+	}
+	return ids
+}
+
+// invalidateActiveIdentities drops the cached identities of epoch, it's called after an atx targeting epoch is written.
+// Entries are built under the lock, so an entry built while the atx was written, which may miss it, is dropped too.
+func (db *DB) invalidateActiveIdentities(epoch types.EpochID) {
+	db.identities.Lock()
+	db.identities.cache.Remove(epoch)
+	db.identities.Unlock()
+}
@@ -2,6 +2,7 @@ package activation
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
@@ -11,30 +12,25 @@ import (
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 	"sync"
 	"time"
 )
 
-const topAtxKey = "topAtxKey"
+var errInvalidSig = fmt.Errorf("identity not found when validating signature, invalid atx")
 
-func getNodeAtxKey(nodeID types.NodeID, targetEpoch types.EpochID) []byte {
-	return append(getNodeAtxPrefix(nodeID), util.Uint64ToBytesBigEndian(uint64(targetEpoch))...)
-}
+// atxFetchTimeout bounds the time spent fetching an atx that a block references but is missing from the database.
+const atxFetchTimeout = 30 * time.Second
 
-func getNodeAtxPrefix(nodeID types.NodeID) []byte {
-	return []byte(fmt.Sprintf("n_%v_", nodeID.Key))
-}
-
-func getAtxHeaderKey(atxID types.ATXID) []byte {
-	return []byte(fmt.Sprintf("h_%v", atxID.Bytes()))
-}
+// atxValidationVersion is the version of the syntactic atx validation. Atxs that were found valid by another version
+// are validated again, so it must be bumped whenever the validation rules change.
+const atxValidationVersion = 1
 
-func getAtxBodyKey(atxID types.ATXID) []byte {
-	return []byte(fmt.Sprintf("b_%v", atxID.Bytes()))
+// AtxFetcher fetches an atx from peers, validates and stores it.
+type AtxFetcher interface {
+	FetchAtx(ctx context.Context, id types.ATXID) error
 }
 
-var errInvalidSig = fmt.Errorf("identity not found when validating signature, invalid atx")
-
 type atxChan struct {
 	ch        chan struct{}
 	listeners int
@@ -47,16 +43,28 @@ type DB struct {
 	// todo: think about whether we need one db or several(#1922)
 	idStore
 	atxs              database.Database
+	intents           *database.IntentLog
 	atxHeaderCache    AtxCache
+	blockAtxs         blockAtxsCache
 	meshDb            *mesh.DB
 	LayersPerEpoch    uint16
-	nipstValidator    nipstValidator
+	nipstValidators   *ValidatorRegistry
 	pendingActiveSet  map[types.Hash12]*sync.Mutex
 	log               log.Log
-	calcActiveSetFunc func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error)
+	calcActiveSetFunc func(ctx context.Context, epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error)
 	processAtxMutex   sync.Mutex
 	assLock           sync.Mutex
 	atxChannels       map[types.ATXID]*atxChan
+	atxSubs           map[chan types.ATXID]struct{}
+	filters           *epochFilters
+	identities        *activeIdentities
+	upgrades          *upgrade.Schedule
+	fetcher           AtxFetcher
+	divergence        *activeSetDivergence
+	readOnly          bool                    // see OpenActivationDbReadOnly
+	stores            []*database.LDBDatabase // stores opened by OpenActivationDbReadOnly, closed by Close
+
+	activeSetGraceLayers uint16
 }
 
 // NewDB creates a new struct of type DB, this struct will hold the atxs received from all nodes and
@@ -65,18 +73,52 @@ func NewDB(dbStore database.Database, idStore idStore, meshDb *mesh.DB, layersPe
 	db := &DB{
 		idStore:          idStore,
 		atxs:             dbStore,
-		atxHeaderCache:   NewAtxCache(600),
+		intents:          database.NewIntentLog(dbStore, atxIntentPrefix),
+		atxHeaderCache:   NewAtxCache(DefaultAtxCacheSize),
+		blockAtxs:        newBlockAtxsCache(DefaultBlockAtxsCacheSize),
 		meshDb:           meshDb,
 		LayersPerEpoch:   layersPerEpoch,
-		nipstValidator:   nipstValidator,
+		nipstValidators:  NewValidatorRegistry(nipstValidator),
 		pendingActiveSet: make(map[types.Hash12]*sync.Mutex),
 		log:              log,
 		atxChannels:      make(map[types.ATXID]*atxChan),
+		atxSubs:          make(map[chan types.ATXID]struct{}),
+		filters:          newEpochFilters(),
+		identities:       newActiveIdentities(),
+		divergence:       newActiveSetDivergence(),
 	}
-	db.calcActiveSetFunc = db.CalcActiveSetSize
+	db.calcActiveSetFunc = db.calcActiveSetSize
 	return db
 }
 
+// RegisterNipstValidator registers the NIPST validator of a new PoST parameter version, which validates ATXs that
+// target epoch or later epochs. See ValidatorRegistry.
+func (db *DB) RegisterNipstValidator(version uint32, epoch types.EpochID, validator nipstValidator) error {
+	if err := db.nipstValidators.Register(version, epoch, validator); err != nil {
+		return err
+	}
+	db.log.With().Info("registered NIPST validator version", log.Uint32("version", version), epoch)
+	return nil
+}
+
+// validatorFor returns the NIPST validator of the PoST parameter version of the atx's target epoch.
+func (db *DB) validatorFor(atx *types.ActivationTxHeader) nipstValidator {
+	_, validator := db.nipstValidators.ForEpoch(atx.TargetEpoch(db.LayersPerEpoch))
+	return validator
+}
+
+// SetUpgrades sets the schedule of the protocol upgrades that atx validation branches on. It must be called before
+// atxs are validated, without a schedule atxs are validated by the original protocol.
+func (db *DB) SetUpgrades(upgrades *upgrade.Schedule) {
+	db.upgrades = upgrades
+}
+
+// SetHeaderCacheSize replaces the atx header cache with an empty cache of size entries. It must be called before atxs
+// are processed.
+func (db *DB) SetHeaderCacheSize(size int) {
+	db.atxHeaderCache = NewAtxCache(size)
+}
+
 var closedChan = make(chan struct{})
 
 func init() {
@@ -88,8 +130,10 @@ func (db *DB) AwaitAtx(id types.ATXID) chan struct{} {
 	db.Lock()
 	defer db.Unlock()
 
-	if _, err := db.atxs.Get(getAtxHeaderKey(id)); err == nil {
-		return closedChan
+	if key, err := types.NewAtxKey(id); err == nil {
+		if _, err := db.atxs.Get(getAtxHeaderKey(key)); err == nil {
+			return closedChan
+		}
 	}
 
 	ch, found := db.atxChannels[id]
@@ -119,6 +163,21 @@ func (db *DB) UnsubscribeAtx(id types.ATXID) {
 	}
 }
 
+// SubscribeAtx returns a channel that receives the id of every atx stored from now on, once it's written. Unlike
+// AwaitAtx, it doesn't wait for a specific atx. Ids are dropped if the subscriber doesn't keep up. Unsubscribe by calling
+// the returned func.
+func (db *DB) SubscribeAtx() (<-chan types.ATXID, func()) {
+	ch := make(chan types.ATXID, 100)
+	db.Lock()
+	db.atxSubs[ch] = struct{}{}
+	db.Unlock()
+	return ch, func() {
+		db.Lock()
+		delete(db.atxSubs, ch)
+		db.Unlock()
+	}
+}
+
 // ProcessAtxs processes the list of given atxs using ProcessAtx method
 func (db *DB) ProcessAtxs(atxs []*types.ActivationTx) error {
 	seenMinerIds := map[string]struct{}{}
@@ -144,6 +203,9 @@ func (db *DB) ProcessAtxs(atxs []*types.ActivationTx) error {
 //
 // ATXs received as input must be already syntactically valid. Only contextual validation is performed.
 func (db *DB) ProcessAtx(atx *types.ActivationTx) error {
+	if db.readOnly {
+		return errReadOnly
+	}
 	db.processAtxMutex.Lock()
 	defer db.processAtxMutex.Unlock()
 
@@ -151,103 +213,170 @@ func (db *DB) ProcessAtx(atx *types.ActivationTx) error {
 	if existingATX != nil { // Already processed
 		return nil
 	}
+	return db.processAtx(atx)
+}
+
+// processAtx processes the atx, whether it was stored already or not. An intent to process the atx is recorded while
+// it's processed, so that the atx is processed again on startup if the node crashes between the writes.
+func (db *DB) processAtx(atx *types.ActivationTx) error {
+	atxBytes, err := types.InterfaceToBytes(atx)
+	if err != nil {
+		return fmt.Errorf("cannot encode atx %s: %v", atx.ShortString(), err)
+	}
+	if err := db.intents.Begin(atx.ID().Bytes(), atxBytes); err != nil {
+		return fmt.Errorf("cannot record intent to process atx %s: %v", atx.ShortString(), err)
+	}
+
 	epoch := atx.PubLayerID.GetEpoch(db.LayersPerEpoch)
 	db.log.With().Info("processing atx", log.AtxID(atx.ShortString()), log.EpochID(uint64(epoch)),
 		log.String("atx_node_id", atx.NodeID.Key[:5]), log.LayerID(uint64(atx.PubLayerID)))
-	err := db.ContextuallyValidateAtx(atx.ActivationTxHeader)
+	err = db.ContextuallyValidateAtx(atx.ActivationTxHeader)
 	if err != nil {
 		db.log.With().Error("ATX failed contextual validation", log.AtxID(atx.ShortString()), log.Err(err))
 		// TODO: Blacklist this miner
 	} else {
 		db.log.With().Info("ATX is valid", log.AtxID(atx.ShortString()))
 	}
+	ticks, err := db.validatorFor(atx.ActivationTxHeader).NumOfTicks(atx.Nipst)
+	if err != nil {
+		return fmt.Errorf("cannot calculate atx %s tick count: %v", atx.ShortString(), err)
+	}
 	err = db.StoreAtx(epoch, atx)
 	if err != nil {
 		return fmt.Errorf("cannot store atx %s: %v", atx.ShortString(), err)
 	}
+	if err := db.storeAtxTicks(atx.ID(), ticks); err != nil {
+		return fmt.Errorf("cannot store atx %s tick count: %v", atx.ShortString(), err)
+	}
 
 	err = db.StoreNodeIdentity(atx.NodeID)
 	if err != nil {
 		db.log.With().Error("cannot store node identity", log.String("atx_node_id", atx.NodeID.ShortString()), log.AtxID(atx.ShortString()), log.Err(err))
 	}
-	return nil
+	return db.intents.Done(atx.ID().Bytes())
 }
 
-func (db *DB) createTraversalActiveSetCounterFunc(countedAtxs map[string]types.ATXID, penalties map[string]struct{}, layersPerEpoch uint16, epoch types.EpochID) func(b *types.Block) (bool, error) {
-
-	traversalFunc := func(b *types.Block) (stop bool, err error) {
+// ReplayIntents processes again the atxs whose processing was interrupted, e.g. by a crash between the writes of
+// StoreAtx. It should be called on startup, before atxs are received.
+func (db *DB) ReplayIntents() error {
+	if db.readOnly {
+		return errReadOnly
+	}
+	db.processAtxMutex.Lock()
+	defer db.processAtxMutex.Unlock()
 
-		// don't count ATXs in blocks that are not destined to the prev epoch
-		if b.LayerIndex.GetEpoch(db.LayersPerEpoch) != epoch-1 {
-			return false, nil
+	return db.intents.Replay(func(id, data []byte) {
+		atx, err := types.BytesToAtx(data)
+		if err != nil {
+			db.log.With().Error("cannot decode atx of interrupted intent", log.String("atx_id", util.Bytes2Hex(id)), log.Err(err))
+			return
+		}
+		atx.CalcAndSetID()
+		db.log.With().Info("processing interrupted atx again", log.AtxID(atx.ShortString()))
+		key, err := types.NewAtxKey(atx.ID())
+		if err != nil {
+			db.log.With().Error("cannot process interrupted atx", log.AtxID(atx.ShortString()), log.Err(err))
+			return
 		}
+		// the atx header may have been stored already, delete it so the rest of the atx is stored too
+		if err := db.atxs.Delete(getAtxHeaderKey(key)); err != nil {
+			db.log.With().Error("cannot delete header of interrupted atx", log.AtxID(atx.ShortString()), log.Err(err))
+			return
+		}
+		if err := db.processAtx(atx); err != nil {
+			db.log.With().Error("cannot process interrupted atx", log.AtxID(atx.ShortString()), log.Err(err))
+		}
+	})
+}
 
-		// count unique ATXs
-		for _, id := range b.ATXIDs {
-			atx, err := db.GetAtxHeader(id)
-			if err != nil {
-				log.Panic("error fetching atx %v from database -- inconsistent state", id.ShortString()) // TODO: handle inconsistent state
-				return false, fmt.Errorf("error fetching atx %v from database -- inconsistent state", id.ShortString())
-			}
+// countBlockAtxs adds the ATXs targeting epoch that block b includes to countedAtxs, by node. Nodes with two ATXs
+// targeting epoch are added to penalties and removed from countedAtxs.
+func (db *DB) countBlockAtxs(ctx context.Context, b *types.Block, countedAtxs map[string]types.ATXID, penalties map[string]struct{}, epoch types.EpochID) error {
+	// count unique ATXs
+	for _, id := range b.ATXIDs {
+		atx, err := db.getOrFetchAtxHeader(ctx, id)
+		if err != nil {
+			return fmt.Errorf("error fetching atx %v of block %v -- inconsistent state: %v",
+				id.ShortString(), b.ID(), err)
+		}
 
-			// make sure the target epoch is our epoch
-			if atx.TargetEpoch(layersPerEpoch) != epoch {
-				db.log.With().Debug("atx found, but targeting epoch doesn't match publication epoch",
-					log.String("atx_id", atx.ShortString()),
-					log.Uint64("atx_target_epoch", uint64(atx.TargetEpoch(layersPerEpoch))),
-					log.Uint64("actual_epoch", uint64(epoch)))
-				continue
-			}
+		// make sure the target epoch is our epoch
+		if atx.TargetEpoch(db.LayersPerEpoch) != epoch {
+			db.log.With().Debug("atx found, but targeting epoch doesn't match publication epoch",
+				log.String("atx_id", atx.ShortString()),
+				log.Uint64("atx_target_epoch", uint64(atx.TargetEpoch(db.LayersPerEpoch))),
+				log.Uint64("actual_epoch", uint64(epoch)))
+			continue
+		}
 
-			// ignore atx from nodes in penalty
-			if _, exist := penalties[atx.NodeID.Key]; exist {
-				db.log.With().Debug("ignoring atx from node in penalty",
-					log.String("node_id", atx.NodeID.Key), log.String("atx_id", atx.ShortString()))
-				continue
-			}
+		// ignore atx from nodes in penalty
+		if _, exist := penalties[atx.NodeID.Key]; exist {
+			db.log.With().Debug("ignoring atx from node in penalty",
+				log.String("node_id", atx.NodeID.Key), log.String("atx_id", atx.ShortString()))
+			continue
+		}
 
-			if prevID, exist := countedAtxs[atx.NodeID.Key]; exist { // same miner
+		if prevID, exist := countedAtxs[atx.NodeID.Key]; exist { // same miner
 
-				if prevID != id { // different atx for same epoch
-					db.log.With().Error("Encountered second atx for the same miner on the same epoch",
-						log.String("first_atx", prevID.ShortString()), log.String("second_atx", id.ShortString()))
+			if prevID != id { // different atx for same epoch
+				db.log.With().Error("Encountered second atx for the same miner on the same epoch",
+					log.String("first_atx", prevID.ShortString()), log.String("second_atx", id.ShortString()))
 
-					penalties[atx.NodeID.Key] = struct{}{} // mark node in penalty
-					delete(countedAtxs, atx.NodeID.Key)    // remove the penalized node from counted
-				}
-				continue
+				penalties[atx.NodeID.Key] = struct{}{} // mark node in penalty
+				delete(countedAtxs, atx.NodeID.Key)    // remove the penalized node from counted
 			}
-
-			countedAtxs[atx.NodeID.Key] = id
+			continue
 		}
 
-		return false, nil
+		countedAtxs[atx.NodeID.Key] = id
 	}
-
-	return traversalFunc
+	return nil
 }
 
 // CalcActiveSetSize - returns the active set size that matches the view of the contextually valid blocks in the provided layer
 func (db *DB) CalcActiveSetSize(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+	return db.calcActiveSetSize(context.Background(), epoch, blocks)
+}
 
+// calcActiveSetSize is CalcActiveSetSize, with a context that cancels the traversal of blocks.
+func (db *DB) calcActiveSetSize(ctx context.Context, epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
 	if epoch == 0 {
 		return nil, errors.New("tried to retrieve active set for epoch 0")
 	}
 
 	firstLayerOfPrevEpoch := (epoch - 1).FirstLayer(db.LayersPerEpoch)
 
+	startTime := time.Now()
+	visited := make(map[types.BlockID]blockAtxs)
+	sets := make([]blockAtxs, 0, len(blocks))
+	for id := range blocks {
+		atxs, err := db.getBlockAtxs(ctx, epoch, firstLayerOfPrevEpoch, id, visited)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, atxs)
+	}
+
+	// count unique ATXs, nodes with two ATXs targeting epoch are in penalty and not counted
 	countedAtxs := make(map[string]types.ATXID)
 	penalties := make(map[string]struct{})
+	for id, node := range mergeBlockAtxs(sets) {
+		if _, exist := penalties[node]; exist {
+			continue
+		}
+		if prevID, exist := countedAtxs[node]; exist {
+			db.log.With().Error("Encountered second atx for the same miner on the same epoch",
+				log.String("first_atx", prevID.ShortString()), log.String("second_atx", id.ShortString()))
 
-	traversalFunc := db.createTraversalActiveSetCounterFunc(countedAtxs, penalties, db.LayersPerEpoch, epoch)
-
-	startTime := time.Now()
-	err := db.meshDb.ForBlockInView(blocks, firstLayerOfPrevEpoch, traversalFunc)
-	if err != nil {
-		return nil, err
+			penalties[node] = struct{}{}
+			delete(countedAtxs, node)
+			continue
+		}
+		countedAtxs[node] = id
 	}
 	db.log.With().Info("done calculating active set size",
 		log.Int("size", len(countedAtxs)),
+		log.Int("traversed_blocks", len(visited)),
 		log.String("duration", time.Now().Sub(startTime).String()))
 
 	result := make(map[string]struct{}, len(countedAtxs))
@@ -258,58 +387,312 @@ func (db *DB) CalcActiveSetSize(epoch types.EpochID, blocks map[types.BlockID]st
 	return result, nil
 }
 
+// getBlockAtxs returns the ATXs targeting epoch that the block with the given id introduces, in itself or in the blocks
+// it transitively views, down to firstLayer. ATXs only count in blocks of the previous epoch. The sets are memoized
+// per block, so views that share most of their blocks don't traverse the history they share again. visited holds the
+// sets of the blocks traversed in this calculation, which the lru cache might have already evicted. The traversal stops
+// with ctx's error once ctx is done.
+func (db *DB) getBlockAtxs(ctx context.Context, epoch types.EpochID, firstLayer types.LayerID, id types.BlockID, visited map[types.BlockID]blockAtxs) (blockAtxs, error) {
+	if atxs, found := visited[id]; found {
+		return atxs, nil
+	}
+	if atxs, found := db.blockAtxs.Get(epoch, id); found {
+		return atxs, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if db.meshDb == nil {
+		return nil, errNoMesh
+	}
+
+	block, err := db.meshDb.GetBlock(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// catch blocks that were referenced after more than one layer, and slipped through the stop condition
+	if block.LayerIndex < firstLayer {
+		visited[id] = nil
+		return nil, nil
+	}
+
+	var sets []blockAtxs
+	// stop condition: referenced blocks must be in lower layers, so we don't traverse them
+	if block.LayerIndex > firstLayer {
+		for _, viewed := range block.View() {
+			atxs, err := db.getBlockAtxs(ctx, epoch, firstLayer, viewed, visited)
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, atxs)
+		}
+	}
+
+	// don't count ATXs in blocks that are not destined to the prev epoch
+	if block.LayerIndex.GetEpoch(db.LayersPerEpoch) == epoch-1 && len(block.ATXIDs) > 0 {
+		own := make(blockAtxs, len(block.ATXIDs))
+		for _, atxID := range block.ATXIDs {
+			atx, err := db.getOrFetchAtxHeader(ctx, atxID)
+			if err != nil {
+				return nil, fmt.Errorf("error fetching atx %v of block %v -- inconsistent state: %v",
+					atxID.ShortString(), block.ID(), err)
+			}
+
+			// make sure the target epoch is our epoch
+			if atx.TargetEpoch(db.LayersPerEpoch) != epoch {
+				continue
+			}
+			own[atxID] = atx.NodeID.Key
+		}
+		sets = append(sets, own)
+	}
+
+	atxs := mergeBlockAtxs(sets)
+	visited[id] = atxs
+	db.blockAtxs.Add(epoch, id, atxs)
+	return atxs, nil
+}
+
+// mergeBlockAtxs returns the union of sets. When the largest set contains all the others it's returned as is, which
+// keeps the sets of blocks that don't introduce new ATXs shared with the blocks they view.
+func mergeBlockAtxs(sets []blockAtxs) blockAtxs {
+	var largest blockAtxs
+	for _, set := range sets {
+		if len(set) > len(largest) {
+			largest = set
+		}
+	}
+	for _, set := range sets {
+		for id := range set {
+			if _, found := largest[id]; !found {
+				return unionBlockAtxs(sets)
+			}
+		}
+	}
+	return largest
+}
+
+func unionBlockAtxs(sets []blockAtxs) blockAtxs {
+	union := make(blockAtxs)
+	for _, set := range sets {
+		for id, node := range set {
+			union[id] = node
+		}
+	}
+	return union
+}
+
 // CalcActiveSetFromView traverses the view found in a - the activation tx and counts number of active ids published
 // in the epoch prior to the epoch that a was published at, this number is the number of active ids in the next epoch
-// the function returns error if the view is not found
-func (db *DB) CalcActiveSetFromView(view []types.BlockID, pubEpoch types.EpochID) (uint32, error) {
+// the function returns error if the view is not found, or ctx's error if ctx is done before the traversal ends
+func (db *DB) CalcActiveSetFromView(ctx context.Context, view []types.BlockID, pubEpoch types.EpochID) (uint32, error) {
+	count, _, err := db.calcActiveSetWeightFromView(ctx, view, pubEpoch)
+	return count, err
+}
+
+// CalcActiveSetSpaceUnitsFromView traverses the view like CalcActiveSetFromView, but returns the total number of space
+// units committed by the active ids instead of their number.
+func (db *DB) CalcActiveSetSpaceUnitsFromView(ctx context.Context, view []types.BlockID, pubEpoch types.EpochID) (uint64, error) {
+	_, spaceUnits, err := db.calcActiveSetWeightFromView(ctx, view, pubEpoch)
+	return spaceUnits, err
+}
+
+// CalcActiveSet returns the number of active ids that ATXs published in pubEpoch declare: the active ids in view, as
+// counted by CalcActiveSetFromView, or, from the epoch the first-seen-active-set upgrade activates at, the active ids
+// first seen in blocks, as counted by CalcActiveSetFromFirstSeen, which ignores view.
+func (db *DB) CalcActiveSet(ctx context.Context, view []types.BlockID, pubEpoch types.EpochID) (uint32, error) {
+	if db.upgrades.Active(upgrade.FirstSeenActiveSet, pubEpoch) {
+		return db.CalcActiveSetFromFirstSeen(pubEpoch)
+	}
+	return db.CalcActiveSetFromView(ctx, view, pubEpoch)
+}
+
+// GetActiveSetSpaceUnits returns the total number of space units committed by the active set declared in the ATX with
+// the given ID, as calculated from the ATX's view, or from the blocks the active ids were first seen in after the
+// first-seen-active-set upgrade.
+func (db *DB) GetActiveSetSpaceUnits(id types.ATXID) (uint64, error) {
+	atx, err := db.GetFullAtx(id)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get atx %v: %v", id.ShortString(), err)
+	}
+	pubEpoch := atx.PubLayerID.GetEpoch(db.LayersPerEpoch)
+	if db.upgrades.Active(upgrade.FirstSeenActiveSet, pubEpoch) {
+		_, spaceUnits, err := db.calcActiveSetWeightFromFirstSeen(pubEpoch)
+		return spaceUnits, err
+	}
+	return db.CalcActiveSetSpaceUnitsFromView(context.Background(), atx.View, pubEpoch)
+}
+
+// SetActiveSetGracePeriod sets the number of layers at the start of an epoch whose blocks still count towards the
+// active set of the epoch in the first-seen mode, see CalcActiveSetFromFirstSeen. ATXs published at the end of the
+// previous epoch are only included in blocks of the next layers. It must be called before atxs are validated.
+func (db *DB) SetActiveSetGracePeriod(layers uint16) {
+	db.activeSetGraceLayers = layers
+}
+
+// SetAtxFetcher sets the fetcher of atxs that blocks reference but are missing from the database, e.g. after an
+// interrupted sync. Without one, counting an active set over such a block fails. It must be called before atxs are
+// validated.
+func (db *DB) SetAtxFetcher(fetcher AtxFetcher) {
+	db.fetcher = fetcher
+}
+
+// getOrFetchAtxHeader returns the header of the atx id, and fetches the atx from peers if it's missing and a fetcher is
+// set. Only the fetch is bounded by atxFetchTimeout, the caller's ctx still applies.
+func (db *DB) getOrFetchAtxHeader(ctx context.Context, id types.ATXID) (*types.ActivationTxHeader, error) {
+	atx, err := db.GetAtxHeader(id)
+	if err == nil || db.fetcher == nil {
+		return atx, err
+	}
+	db.log.With().Warning("atx is missing from the database, fetching it from peers", log.AtxID(id.ShortString()),
+		log.Err(err))
+	fetchCtx, cancel := context.WithTimeout(ctx, atxFetchTimeout)
+	defer cancel()
+	if err := db.fetcher.FetchAtx(fetchCtx, id); err != nil {
+		return nil, fmt.Errorf("could not fetch missing atx %v: %v", id.ShortString(), err)
+	}
+	return db.GetAtxHeader(id)
+}
+
+// ActiveSetLayer returns the first layer whose blocks don't count towards the active set declared by ATXs published in
+// pubEpoch. ATX builders wait for it, and for the mesh to sync, before counting the active set.
+func (db *DB) ActiveSetLayer(pubEpoch types.EpochID) types.LayerID {
+	if db.upgrades.Active(upgrade.FirstSeenActiveSet, pubEpoch) {
+		return pubEpoch.FirstLayer(db.LayersPerEpoch).Add(db.activeSetGraceLayers)
+	}
+	return pubEpoch.FirstLayer(db.LayersPerEpoch)
+}
+
+// CalcActiveSetFromFirstSeen counts the active ids of pubEpoch: the nodes that published ATXs targeting pubEpoch
+// which were first seen in the blocks of the previous epoch or of the grace period at the start of pubEpoch. Unlike
+// CalcActiveSetFromView it reads the blocks of these layers only, instead of traversing the view of every ATX.
+func (db *DB) CalcActiveSetFromFirstSeen(pubEpoch types.EpochID) (uint32, error) {
+	count, _, err := db.calcActiveSetWeightFromFirstSeen(pubEpoch)
+	return count, err
+}
+
+func (db *DB) calcActiveSetWeightFromView(ctx context.Context, view []types.BlockID, pubEpoch types.EpochID) (uint32, uint64, error) {
 	if pubEpoch < 1 {
-		return 0, fmt.Errorf("publication epoch cannot be less than 1, found %v", pubEpoch)
+		return 0, 0, fmt.Errorf("publication epoch cannot be less than 1, found %v", pubEpoch)
 	}
-	viewHash := types.CalcBlocksHash12(view)
-	count, found := activesetCache.Get(viewHash)
+	return db.calcActiveSetWeight(types.CalcBlocksHash12(view), pubEpoch, func() (map[string]struct{}, error) {
+		mp := map[types.BlockID]struct{}{}
+		for _, blk := range view {
+			mp[blk] = struct{}{}
+		}
+		return db.calcActiveSetFunc(ctx, pubEpoch, mp)
+	})
+}
+
+// firstSeenHashPrefix prefixes the hashes of the blocks the first-seen active set is counted from, so that they don't
+// collide with view hashes in the active set cache.
+const firstSeenHashPrefix = "first-seen"
+
+func (db *DB) calcActiveSetWeightFromFirstSeen(pubEpoch types.EpochID) (uint32, uint64, error) {
+	if pubEpoch < 1 {
+		return 0, 0, fmt.Errorf("publication epoch cannot be less than 1, found %v", pubEpoch)
+	}
+	if db.meshDb == nil {
+		return 0, 0, errNoMesh
+	}
+	var blocks []types.BlockID
+	for layer := (pubEpoch - 1).FirstLayer(db.LayersPerEpoch); layer < db.ActiveSetLayer(pubEpoch); layer++ {
+		ids, err := db.meshDb.LayerBlockIds(layer)
+		if err != nil {
+			db.log.With().Debug("no blocks to count active set from", log.LayerID(uint64(layer)), log.Err(err))
+			continue
+		}
+		blocks = append(blocks, ids...)
+	}
+	hash := types.CalcBlocksHash32(blocks, append([]byte(firstSeenHashPrefix), util.Uint64ToBytes(uint64(pubEpoch))...))
+	var key types.Hash12
+	copy(key[:], hash[:])
+	return db.calcActiveSetWeight(key, pubEpoch, func() (map[string]struct{}, error) {
+		countedAtxs := make(map[string]types.ATXID)
+		penalties := make(map[string]struct{})
+		startTime := time.Now()
+		for _, id := range blocks {
+			blk, err := db.meshDb.GetBlock(id)
+			if err != nil {
+				return nil, fmt.Errorf("cannot get block %v: %v", id, err)
+			}
+			if err := db.countBlockAtxs(context.Background(), blk, countedAtxs, penalties, pubEpoch); err != nil {
+				return nil, err
+			}
+		}
+		db.log.With().Info("done counting active set from first seen atxs",
+			log.EpochID(uint64(pubEpoch)),
+			log.Int("blocks", len(blocks)),
+			log.Int("size", len(countedAtxs)),
+			log.String("duration", time.Now().Sub(startTime).String()))
+		result := make(map[string]struct{}, len(countedAtxs))
+		for k := range countedAtxs {
+			result[k] = struct{}{}
+		}
+		return result, nil
+	})
+}
+
+// calcActiveSetWeight returns the size and total space units of the active set of pubEpoch counted by count, or cached
+// by key. Concurrent calls with the same key count the active set once.
+func (db *DB) calcActiveSetWeight(key types.Hash12, pubEpoch types.EpochID, count func() (map[string]struct{}, error)) (uint32, uint64, error) {
+	size, spaceUnits, found := activesetCache.Get(key)
 	if found {
-		return count, nil
+		return size, spaceUnits, nil
 	}
 	// check if we have a running calculation for this hash
 	db.assLock.Lock()
-	mu, alreadyRunning := db.pendingActiveSet[viewHash]
+	mu, alreadyRunning := db.pendingActiveSet[key]
 	if alreadyRunning {
 		db.assLock.Unlock()
 		// if there is a running calculation, wait for it to end and get the result
 		mu.Lock()
-		count, found := activesetCache.Get(viewHash)
+		size, spaceUnits, found := activesetCache.Get(key)
 		if found {
 			mu.Unlock()
-			return count, nil
+			return size, spaceUnits, nil
 		}
 		// if not found, keep running mutex and calculate active set size
 	} else {
 		// if no running calc, insert new one
 		mu = &sync.Mutex{}
-		db.pendingActiveSet[viewHash] = mu
-		db.pendingActiveSet[viewHash].Lock()
+		db.pendingActiveSet[key] = mu
+		db.pendingActiveSet[key].Lock()
 		db.assLock.Unlock()
 	}
 
-	mp := map[types.BlockID]struct{}{}
-	for _, blk := range view {
-		mp[blk] = struct{}{}
-	}
-
-	countedAtxs, err := db.calcActiveSetFunc(pubEpoch, mp)
+	countedAtxs, err := count()
 	if err != nil {
 		mu.Unlock()
-		db.deleteLock(viewHash)
-		return 0, err
+		db.deleteLock(key)
+		return 0, 0, err
 	}
-	activesetCache.Add(viewHash, uint32(len(countedAtxs)))
+	spaceUnits = db.sumSpaceUnits(countedAtxs, pubEpoch)
+	activesetCache.Add(key, uint32(len(countedAtxs)), spaceUnits)
 	mu.Unlock()
-	db.deleteLock(viewHash)
+	db.deleteLock(key)
 
-	return uint32(len(countedAtxs)), nil
+	return uint32(len(countedAtxs)), spaceUnits, nil
 
 }
 
+// sumSpaceUnits returns the total number of space units committed by the ATXs that the given identities published
+// targeting targetEpoch. An identity whose ATX can't be found is counted as a single space unit.
+func (db *DB) sumSpaceUnits(ids map[string]struct{}, targetEpoch types.EpochID) uint64 {
+	total := uint64(0)
+	for key := range ids {
+		units := uint32(1)
+		if id, err := db.GetNodeAtxIDForEpoch(types.NodeID{Key: key}, targetEpoch); err == nil {
+			if atx, err := db.GetAtxHeader(id); err == nil {
+				units = atx.EffectiveSpaceUnits()
+			}
+		}
+		total += uint64(units)
+	}
+	return total
+}
+
 func (db *DB) deleteLock(viewHash types.Hash12) {
 	db.assLock.Lock()
 	if _, exist := db.pendingActiveSet[viewHash]; exist {
@@ -318,7 +701,8 @@ func (db *DB) deleteLock(viewHash types.Hash12) {
 	db.assLock.Unlock()
 }
 
-// SyntacticallyValidateAtx ensures the following conditions apply, otherwise it returns an error.
+// SyntacticallyValidateAtx ensures the following conditions apply, otherwise it returns an error. It returns ctx's
+// error if ctx is done before the traversal of the ATX's view ends.
 //
 // - If the sequence number is non-zero: PrevATX points to a syntactically valid ATX whose sequence number is one less
 //   than the current ATX's sequence number.
@@ -328,108 +712,237 @@ func (db *DB) deleteLock(viewHash types.Hash12) {
 //   NodeID, SequenceNumber, PrevATXID, LayerID, StartTick, PositioningATX.
 // - The NIPST is valid.
 // - ATX LayerID is NipstLayerTime or less after the PositioningATX LayerID.
-// - The ATX view of the previous epoch contains ActiveSetSize activations.
-func (db *DB) SyntacticallyValidateAtx(atx *types.ActivationTx) error {
+// - StartTick is the PositioningATX EndTick (or zero when there is no PositioningATX).
+// - EndTick is not before StartTick and the declared number of ticks is not more than the PoET proof attests to.
+// - The ATX view of the previous epoch contains ActiveSetSize activations. From the epoch the first-seen-active-set
+//   upgrade activates at, the ATX has no view and ActiveSetSize activations were first seen in the blocks of the
+//   previous epoch and the grace period after it.
+// - SpaceUnits is the number of space units committed by the NIPST's PoST.
+// - Coinbase isn't empty, from the epoch the atx-coinbase-required upgrade activates at.
+func (db *DB) SyntacticallyValidateAtx(ctx context.Context, atx *types.ActivationTx) error {
+	if db.readOnly {
+		return errReadOnly
+	}
+	err := db.syntacticallyValidateAtx(ctx, atx)
+	if err != nil {
+		atxValidationFailures.With("reason", atxValidationFailure(err)).Add(1)
+	}
+	return err
+}
+
+// SyntacticallyValidateAtxHeader validates the fields of an atx gossiped without its NIPST, that don't depend on the
+// NIPST: its signature, which covers nipstHash instead of the NIPST, its previous and positioning atxs, its ticks and
+// its active set. The atx is fully validated by SyntacticallyValidateAtx once its NIPST is fetched.
+func (db *DB) SyntacticallyValidateAtxHeader(ctx context.Context, atx *types.ActivationTx, nipstHash types.Hash32) error {
+	if db.readOnly {
+		return errReadOnly
+	}
+	err := db.syntacticallyValidateAtxHeader(ctx, atx, nipstHash)
+	if err != nil {
+		atxValidationFailures.With("reason", atxValidationFailure(err)).Add(1)
+	}
+	return err
+}
+
+func (db *DB) syntacticallyValidateAtxHeader(ctx context.Context, atx *types.ActivationTx, nipstHash types.Hash32) error {
+	if !db.headerFirst(atx.ActivationTxHeader) {
+		return invalidAtx(failedSignature, fmt.Errorf("atx %v is gossiped without its NIPST before the %v upgrade",
+			atx.ShortString(), upgrade.HeaderFirstAtxGossip))
+	}
+	pub, err := ExtractHeaderPublicKey(atx, nipstHash)
+	if err != nil {
+		return invalidAtx(failedSignature, fmt.Errorf("cannot validate atx sig atx id %v err %v", atx.ShortString(), err))
+	}
+	return db.validateAtxHeader(ctx, atx, pub)
+}
+
+// headerFirst returns true if atx signs the hash of its NIPST instead of the NIPST.
+func (db *DB) headerFirst(atx *types.ActivationTxHeader) bool {
+	return db.upgrades.Active(upgrade.HeaderFirstAtxGossip, atx.PubLayerID.GetEpoch(db.LayersPerEpoch))
+}
+
+func (db *DB) syntacticallyValidateAtx(ctx context.Context, atx *types.ActivationTx) error {
 	events.Publish(events.NewAtx{ID: atx.ShortString(), LayerID: uint64(atx.PubLayerID.GetEpoch(db.LayersPerEpoch))})
-	pub, err := ExtractPublicKey(atx)
+	if atx.Nipst == nil {
+		return invalidAtx(failedNipst, fmt.Errorf("atx %v has no NIPST", atx.ShortString()))
+	}
+	var pub *signing.PublicKey
+	var err error
+	if db.headerFirst(atx.ActivationTxHeader) {
+		var nipstHash types.Hash32
+		if nipstHash, err = atx.Nipst.Hash(); err != nil {
+			return fmt.Errorf("cannot hash NIPST of atx %v: %v", atx.ShortString(), err)
+		}
+		pub, err = ExtractHeaderPublicKey(atx, nipstHash)
+	} else {
+		pub, err = ExtractPublicKey(atx)
+	}
 	if err != nil {
-		return fmt.Errorf("cannot validate atx sig atx id %v err %v", atx.ShortString(), err)
+		return invalidAtx(failedSignature, fmt.Errorf("cannot validate atx sig atx id %v err %v", atx.ShortString(), err))
+	}
+	if err := db.validateAtxHeader(ctx, atx, pub); err != nil {
+		return err
+	}
+	if atx.PrevATXID == *types.EmptyATXID {
+		if err := db.validatorFor(atx.ActivationTxHeader).VerifyPost(*pub, atx.Commitment, atx.Nipst.Space); err != nil {
+			return invalidAtx(failedCommitment, fmt.Errorf("invalid commitment proof: %v", err))
+		}
 	}
+	return db.validateAtxNipst(atx)
+}
+
+// validateAtxHeader validates the fields of atx that don't depend on its NIPST, pub is the key that signed it.
+func (db *DB) validateAtxHeader(ctx context.Context, atx *types.ActivationTx, pub *signing.PublicKey) error {
+	var err error
 	if atx.NodeID.Key != pub.String() {
-		return fmt.Errorf("node ids don't match")
+		return invalidAtx(failedSignature, fmt.Errorf("node ids don't match"))
+	}
+	if db.upgrades.Active(upgrade.AtxCoinbaseRequired, atx.PubLayerID.GetEpoch(db.LayersPerEpoch)) &&
+		atx.Coinbase == (types.Address{}) {
+		return invalidAtx(failedCoinbase, fmt.Errorf("atx %v declares no coinbase", atx.ShortString()))
 	}
 	if atx.PrevATXID != *types.EmptyATXID {
 		err = db.ValidateSignedAtx(*pub, atx)
 		if err != nil { // means there is no such identity
-			return fmt.Errorf("no id found %v err %v", atx.ShortString(), err)
+			return invalidAtx(failedSignature, fmt.Errorf("no id found %v err %v", atx.ShortString(), err))
 		}
+		start := time.Now()
 		prevATX, err := db.GetAtxHeader(atx.PrevATXID)
+		observeAtxValidation(prevAtxStep, start)
 		if err != nil {
-			return fmt.Errorf("validation failed: prevATX not found: %v", err)
+			return invalidAtx(failedPrevAtx, fmt.Errorf("validation failed: prevATX not found: %v", err))
 		}
 
 		if prevATX.NodeID.Key != atx.NodeID.Key {
-			return fmt.Errorf("previous ATX belongs to different miner. atx.ID: %v, atx.NodeID: %v, prevAtx.NodeID: %v",
-				atx.ShortString(), atx.NodeID.Key, prevATX.NodeID.Key)
+			return invalidAtx(failedPrevAtx, fmt.Errorf("previous ATX belongs to different miner. atx.ID: %v, atx.NodeID: %v, prevAtx.NodeID: %v",
+				atx.ShortString(), atx.NodeID.Key, prevATX.NodeID.Key))
 		}
 
 		prevEp := prevATX.PubLayerID.GetEpoch(db.LayersPerEpoch)
 		curEp := atx.PubLayerID.GetEpoch(db.LayersPerEpoch)
 		if prevEp >= curEp {
-			return fmt.Errorf(
+			return invalidAtx(failedPrevAtx, fmt.Errorf(
 				"prevAtx epoch (%v, layer %v) isn't older than current atx epoch (%v, layer %v)",
-				prevEp, prevATX.PubLayerID, curEp, atx.PubLayerID)
+				prevEp, prevATX.PubLayerID, curEp, atx.PubLayerID))
 		}
 
 		if prevATX.Sequence+1 != atx.Sequence {
-			return fmt.Errorf("sequence number is not one more than prev sequence number")
+			return invalidAtx(failedPrevAtx, fmt.Errorf("sequence number is not one more than prev sequence number"))
 		}
 
 		if atx.Commitment != nil {
-			return fmt.Errorf("prevATX declared, but commitment proof is included")
+			return invalidAtx(failedCommitment, fmt.Errorf("prevATX declared, but commitment proof is included"))
 		}
 
 		if atx.CommitmentMerkleRoot != nil {
-			return fmt.Errorf("prevATX declared, but commitment merkle root is included in challenge")
+			return invalidAtx(failedCommitment, fmt.Errorf("prevATX declared, but commitment merkle root is included in challenge"))
 		}
 	} else {
 		if atx.Sequence != 0 {
-			return fmt.Errorf("no prevATX declared, but sequence number not zero")
+			return invalidAtx(failedPrevAtx, fmt.Errorf("no prevATX declared, but sequence number not zero"))
 		}
 		if atx.Commitment == nil {
-			return fmt.Errorf("no prevATX declared, but commitment proof is not included")
+			return invalidAtx(failedCommitment, fmt.Errorf("no prevATX declared, but commitment proof is not included"))
 		}
 		if atx.CommitmentMerkleRoot == nil {
-			return fmt.Errorf("no prevATX declared, but commitment merkle root is not included in challenge")
+			return invalidAtx(failedCommitment, fmt.Errorf("no prevATX declared, but commitment merkle root is not included in challenge"))
 		}
 		if !bytes.Equal(atx.Commitment.MerkleRoot, atx.CommitmentMerkleRoot) {
-			return errors.New("commitment merkle root included in challenge is not equal to the merkle root included in the proof")
-		}
-		if err := db.nipstValidator.VerifyPost(*pub, atx.Commitment, atx.Nipst.Space); err != nil {
-			return fmt.Errorf("invalid commitment proof: %v", err)
+			return invalidAtx(failedCommitment, errors.New("commitment merkle root included in challenge is not equal to the merkle root included in the proof"))
 		}
 	}
 
 	if atx.PositioningATX != *types.EmptyATXID {
+		start := time.Now()
 		posAtx, err := db.GetAtxHeader(atx.PositioningATX)
+		observeAtxValidation(posAtxStep, start)
 		if err != nil {
-			return fmt.Errorf("positioning atx not found")
+			return invalidAtx(failedPosAtx, fmt.Errorf("positioning atx not found"))
 		}
 		if atx.PubLayerID <= posAtx.PubLayerID {
-			return fmt.Errorf("atx layer (%v) must be after positioning atx layer (%v)",
-				atx.PubLayerID, posAtx.PubLayerID)
+			return invalidAtx(failedPosAtx, fmt.Errorf("atx layer (%v) must be after positioning atx layer (%v)",
+				atx.PubLayerID, posAtx.PubLayerID))
 		}
 		if uint64(atx.PubLayerID-posAtx.PubLayerID) > uint64(db.LayersPerEpoch) {
-			return fmt.Errorf("expected distance of one epoch (%v layers) from pos ATX but found %v",
-				db.LayersPerEpoch, atx.PubLayerID-posAtx.PubLayerID)
+			return invalidAtx(failedPosAtx, fmt.Errorf("expected distance of one epoch (%v layers) from pos ATX but found %v",
+				db.LayersPerEpoch, atx.PubLayerID-posAtx.PubLayerID))
+		}
+		if atx.StartTick != posAtx.EndTick {
+			return invalidAtx(failedTicks, fmt.Errorf("start tick (%v) is not the positioning atx end tick (%v)", atx.StartTick, posAtx.EndTick))
 		}
 	} else {
 		publicationEpoch := atx.PubLayerID.GetEpoch(db.LayersPerEpoch)
 		if !publicationEpoch.IsGenesis() {
-			return fmt.Errorf("no positioning atx found")
+			return invalidAtx(failedPosAtx, fmt.Errorf("no positioning atx found"))
+		}
+		if atx.StartTick != 0 {
+			return invalidAtx(failedTicks, fmt.Errorf("no positioning atx declared, but start tick (%v) is not zero", atx.StartTick))
 		}
 	}
 
-	activeSet, err := db.CalcActiveSetFromView(atx.View, atx.PubLayerID.GetEpoch(db.LayersPerEpoch))
+	if atx.EndTick < atx.StartTick {
+		return invalidAtx(failedTicks, fmt.Errorf("end tick (%v) is before start tick (%v)", atx.EndTick, atx.StartTick))
+	}
+
+	if db.upgrades.Active(upgrade.FirstSeenActiveSet, atx.PubLayerID.GetEpoch(db.LayersPerEpoch)) && len(atx.View) > 0 {
+		return invalidAtx(failedActiveSet, fmt.Errorf("atx %v declares a view of %v blocks", atx.ShortString(), len(atx.View)))
+	}
+	start := time.Now()
+	activeSet, err := db.CalcActiveSet(ctx, atx.View, atx.PubLayerID.GetEpoch(db.LayersPerEpoch))
+	observeAtxValidation(activeSetStep, start)
 	if err != nil && !atx.PubLayerID.GetEpoch(db.LayersPerEpoch).IsGenesis() {
-		return fmt.Errorf("could not calculate active set for ATX %v %s", atx.ShortString(), err)
+		return invalidAtx(failedActiveSet, fmt.Errorf("could not calculate active set for ATX %v %s", atx.ShortString(), err))
+	}
+	if err == nil {
+		db.divergence.record(atx.PubLayerID.GetEpoch(db.LayersPerEpoch), atx.ActiveSetSize, activeSet)
 	}
 
 	if atx.ActiveSetSize != activeSet {
-		return fmt.Errorf("atx contains view with unequal active ids (%v) than seen (%v)", atx.ActiveSetSize, activeSet)
+		return invalidAtx(failedActiveSet, fmt.Errorf("atx contains view with unequal active ids (%v) than seen (%v)", atx.ActiveSetSize, activeSet))
 	}
+	return nil
+}
 
+// validateAtxNipst validates the NIPST of atx against its challenge, and the ticks and space units it declares.
+func (db *DB) validateAtxNipst(atx *types.ActivationTx) error {
 	hash, err := atx.NIPSTChallenge.Hash()
 	if err != nil {
 		return fmt.Errorf("cannot get NIPST Challenge hash: %v", err)
 	}
 	db.log.With().Info("Validated NIPST", log.String("challenge_hash", hash.String()), log.AtxID(atx.ShortString()))
 
-	pubKey := signing.NewPublicKey(util.Hex2Bytes(atx.NodeID.Key))
-	if err = db.nipstValidator.Validate(*pubKey, atx.Nipst, *hash); err != nil {
-		return fmt.Errorf("NIPST not valid: %v", err)
+	signedHash, err := signedAtxHash(atx)
+	if err != nil {
+		return fmt.Errorf("cannot hash atx %v: %v", atx.ShortString(), err)
+	}
+	if db.isValidAtx(signedHash) {
+		// the same atx was received before, e.g. via both gossip and sync, and its NIPST was verified then
+		db.log.With().Debug("skipping NIPST validation of atx found valid before", log.AtxID(atx.ShortString()))
+	} else {
+		pubKey := signing.NewPublicKey(util.Hex2Bytes(atx.NodeID.Key))
+		start := time.Now()
+		err = db.validatorFor(atx.ActivationTxHeader).Validate(*pubKey, atx.Nipst, *hash)
+		observeAtxValidation(nipstStep, start)
+		if err != nil {
+			return invalidAtx(failedNipst, fmt.Errorf("NIPST not valid: %v", err))
+		}
 	}
 
+	ticks, err := db.validatorFor(atx.ActivationTxHeader).NumOfTicks(atx.Nipst)
+	if err != nil {
+		return invalidAtx(failedTicks, fmt.Errorf("cannot get NIPST tick count: %v", err))
+	}
+	if declared := atx.EndTick - atx.StartTick; declared > ticks {
+		return invalidAtx(failedTicks, fmt.Errorf("atx declares %v ticks but its PoET proof attests to %v", declared, ticks))
+	}
+
+	if units := db.validatorFor(atx.ActivationTxHeader).NumOfSpaceUnits(atx.Nipst); atx.SpaceUnits != units {
+		return invalidAtx(failedSpaceUnits, fmt.Errorf("atx declares %v space units but its PoST commits %v", atx.SpaceUnits, units))
+	}
+
+	if err := db.markValidAtx(signedHash); err != nil {
+		db.log.With().Warning("failed to mark atx as valid", log.AtxID(atx.ShortString()), log.Err(err))
+	}
 	return nil
 }
 
@@ -465,41 +978,79 @@ func (db *DB) ContextuallyValidateAtx(atx *types.ActivationTxHeader) error {
 // StoreAtx stores an atx for epoch ech, it stores atx for the current epoch and adds the atx for the nodeID that
 // created it in a sorted manner by the sequence id. This function does not validate the atx and assumes all data is
 // correct and that all associated atx exist in the db. Will return error if writing to db failed.
+//
+// The atx header and body, the top atx and positioning atx history, the node and epoch indexes and the epoch filter
+// are written in one batch, so either all of them are stored or, if writing fails, none of them.
 func (db *DB) StoreAtx(ech types.EpochID, atx *types.ActivationTx) error {
+	if db.readOnly {
+		return errReadOnly
+	}
 	db.Lock()
 	defer db.Unlock()
 
-	// todo: maybe cleanup DB if failed by using defer (#1921)
-	if _, err := db.atxs.Get(getAtxHeaderKey(atx.ID())); err == nil {
+	key, err := types.NewAtxKey(atx.ID())
+	if err != nil {
+		return err
+	}
+	if _, err := db.atxs.Get(getAtxHeaderKey(key)); err == nil {
 		// exists - how should we handle this?
 		return nil
 	}
 
-	err := db.storeAtxUnlocked(atx)
-	if err != nil {
+	batch := db.atxs.NewBatch()
+	if err := db.storeAtxUnlocked(batch, key, atx); err != nil {
 		return err
 	}
-
-	err = db.updateTopAtxIfNeeded(atx)
+	topChanged, err := db.updateTopAtxIfNeeded(batch, atx)
 	if err != nil {
 		return err
 	}
-
-	err = db.addAtxToNodeID(atx.NodeID, atx)
+	filter, err := db.addAtxToNodeID(batch, atx.NodeID, atx)
 	if err != nil {
 		return err
 	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to write atx %v: %v", atx.ShortString(), err)
+	}
+
+	// the in memory state follows the database only once the batch is written
+	if filter != nil {
+		db.filters.Lock()
+		db.filters.cache.Add(atx.TargetEpoch(db.LayersPerEpoch), filter)
+		db.filters.Unlock()
+	}
+	db.invalidateActiveIdentities(atx.TargetEpoch(db.LayersPerEpoch))
+	if topChanged {
+		db.log.With().Info("positioning atx changed", log.AtxID(atx.ShortString()), log.LayerID(uint64(atx.PubLayerID)))
+	}
+	// notify subscribers
+	if ch, found := db.atxChannels[atx.ID()]; found {
+		close(ch.ch)
+		delete(db.atxChannels, atx.ID())
+	}
+	for ch := range db.atxSubs {
+		select {
+		case ch <- atx.ID():
+		default: // the subscriber is too slow, drop the id rather than block storing atxs
+		}
+	}
 	db.log.Debug("finished storing atx %v, in epoch %v", atx.ShortString(), ech)
 
 	return nil
 }
 
-func (db *DB) storeAtxUnlocked(atx *types.ActivationTx) error {
+// atxWriter writes to the atxs store, or to a batch of writes to it.
+type atxWriter interface {
+	database.Putter
+	database.Deleter
+}
+
+func (db *DB) storeAtxUnlocked(w atxWriter, key types.AtxKey, atx *types.ActivationTx) error {
 	atxHeaderBytes, err := types.InterfaceToBytes(atx.ActivationTxHeader)
 	if err != nil {
 		return err
 	}
-	err = db.atxs.Put(getAtxHeaderKey(atx.ID()), atxHeaderBytes)
+	err = w.Put(getAtxHeaderKey(key), atxHeaderBytes)
 	if err != nil {
 		return err
 	}
@@ -508,18 +1059,49 @@ func (db *DB) storeAtxUnlocked(atx *types.ActivationTx) error {
 	if err != nil {
 		return err
 	}
-	err = db.atxs.Put(getAtxBodyKey(atx.ID()), atxBodyBytes)
+	return w.Put(getAtxBodyKey(key), atxBodyBytes)
+}
+
+func (db *DB) storeAtxTicks(id types.ATXID, ticks uint64) error {
+	key, err := types.NewAtxKey(id)
 	if err != nil {
 		return err
 	}
+	return db.atxs.Put(getAtxTicksKey(key), util.Uint64ToBytes(ticks))
+}
 
-	// notify subscribers
-	if ch, found := db.atxChannels[atx.ID()]; found {
-		close(ch.ch)
-		delete(db.atxChannels, atx.ID())
+// signedAtxHash returns the hash of the whole signed atx. Unlike its id, it covers the NIPST and the signature, so
+// atxs that share an id but carry another proof don't share it.
+func signedAtxHash(atx *types.ActivationTx) (types.Hash32, error) {
+	b, err := types.InterfaceToBytes(atx)
+	if err != nil {
+		return types.Hash32{}, err
 	}
+	return types.CalcHash32(b), nil
+}
 
-	return nil
+// isValidAtx returns whether the signed atx with the hash was found syntactically valid by the current validation.
+func (db *DB) isValidAtx(hash types.Hash32) bool {
+	b, err := db.atxs.Get(getValidAtxKey(hash))
+	return err == nil && util.BytesToUint64(b) == atxValidationVersion
+}
+
+func (db *DB) markValidAtx(hash types.Hash32) error {
+	return db.atxs.Put(getValidAtxKey(hash), util.Uint64ToBytes(atxValidationVersion))
+}
+
+// GetAtxTicks returns the number of ticks recorded for the ATX, as attested to by its PoET proof. This is the number
+// of ticks that should be used when weighing the ATX, regardless of the ticks it declares.
+func (db *DB) GetAtxTicks(id types.ATXID) (uint64, error) {
+	key, err := types.NewAtxKey(id)
+	if err != nil {
+		return 0, err
+	}
+	b, err := db.atxs.Get(getAtxTicksKey(key))
+	if err != nil {
+		return 0, fmt.Errorf("cannot get tick count for atx %v: %v", id.ShortString(), err)
+	}
+	return util.BytesToUint64(b), nil
 }
 
 func getAtxBody(atx *types.ActivationTx) *types.ActivationTx {
@@ -539,15 +1121,15 @@ type atxIDAndLayer struct {
 	LayerID types.LayerID
 }
 
-// updateTopAtxIfNeeded replaces the top ATX (positioning ATX candidate) if the latest ATX has a higher layer ID.
-// This function is not thread safe and needs to be called under a global lock.
-func (db *DB) updateTopAtxIfNeeded(atx *types.ActivationTx) error {
+// updateTopAtxIfNeeded replaces the top ATX (positioning ATX candidate) if the latest ATX has a higher layer ID, and
+// reports whether it did. This function is not thread safe and needs to be called under a global lock.
+func (db *DB) updateTopAtxIfNeeded(w atxWriter, atx *types.ActivationTx) (bool, error) {
 	currentTopAtx, err := db.getTopAtx()
 	if err != nil && err != database.ErrNotFound {
-		return fmt.Errorf("failed to get current ATX: %v", err)
+		return false, fmt.Errorf("failed to get current ATX: %v", err)
 	}
 	if err == nil && currentTopAtx.LayerID >= atx.PubLayerID {
-		return nil
+		return false, nil
 	}
 
 	newTopAtx := atxIDAndLayer{
@@ -556,14 +1138,14 @@ func (db *DB) updateTopAtxIfNeeded(atx *types.ActivationTx) error {
 	}
 	topAtxBytes, err := types.InterfaceToBytes(&newTopAtx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal top ATX: %v", err)
+		return false, fmt.Errorf("failed to marshal top ATX: %v", err)
 	}
 
-	err = db.atxs.Put([]byte(topAtxKey), topAtxBytes)
+	err = w.Put([]byte(topAtxKey), topAtxBytes)
 	if err != nil {
-		return fmt.Errorf("failed to store top ATX: %v", err)
+		return false, fmt.Errorf("failed to store top ATX: %v", err)
 	}
-	return nil
+	return true, db.recordPosAtxChange(w, newTopAtx)
 }
 
 func (db *DB) getTopAtx() (atxIDAndLayer, error) {
@@ -579,12 +1161,35 @@ func (db *DB) getTopAtx() (atxIDAndLayer, error) {
 	return topAtx, nil
 }
 
-// addAtxToNodeID inserts activation atx id by node
-func (db *DB) addAtxToNodeID(nodeID types.NodeID, atx *types.ActivationTx) error {
-	err := db.atxs.Put(getNodeAtxKey(nodeID, atx.TargetEpoch(db.LayersPerEpoch)), atx.ID().Bytes())
+// addAtxToNodeID inserts activation atx id by node, and indexes it by target epoch. It returns the epoch filter of the
+// target epoch with the node added, or nil if the filter has the node already, to be cached once w is written.
+func (db *DB) addAtxToNodeID(w atxWriter, nodeID types.NodeID, atx *types.ActivationTx) (epochFilter, error) {
+	if err := db.indexAtx(w, nodeID, atx); err != nil {
+		return nil, err
+	}
+	filter, err := db.addToEpochFilter(w, nodeID, atx.TargetEpoch(db.LayersPerEpoch))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add node to epoch filter: %v", err)
+	}
+	return filter, nil
+}
+
+// indexAtx writes the node atx index, the epoch index and the node chain entries of atx to w.
+func (db *DB) indexAtx(w atxWriter, nodeID types.NodeID, atx *types.ActivationTx) error {
+	node, err := types.NewNodeKey(nodeID)
+	if err != nil {
+		return err
+	}
+	err = w.Put(getNodeAtxKey(node, atx.TargetEpoch(db.LayersPerEpoch)), atx.ID().Bytes())
 	if err != nil {
 		return fmt.Errorf("failed to store ATX ID for node: %v", err)
 	}
+	if err := w.Put(getEpochAtxKey(atx.TargetEpoch(db.LayersPerEpoch), atx.ID()), []byte(node.String())); err != nil {
+		return fmt.Errorf("failed to index ATX by epoch: %v", err)
+	}
+	if err := w.Put(getNodeChainKey(node, atx.Sequence), atx.ID().Bytes()); err != nil {
+		return fmt.Errorf("failed to index ATX in node chain: %v", err)
+	}
 	return nil
 }
 
@@ -593,7 +1198,11 @@ type ErrAtxNotFound error
 
 // GetNodeLastAtxID returns the last atx id that was received for node nodeID
 func (db *DB) GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error) {
-	nodeAtxsIterator := db.atxs.Find(getNodeAtxPrefix(nodeID))
+	node, err := types.NewNodeKey(nodeID)
+	if err != nil {
+		return *types.EmptyATXID, ErrAtxNotFound(err)
+	}
+	nodeAtxsIterator := db.atxs.Find(getNodeAtxPrefix(node))
 	// ATX syntactic validation ensures that each ATX is at least one epoch after a referenced previous ATX.
 	// Contextual validation ensures that the previous ATX referenced matches what this method returns, so the next ATX
 	// added will always be the next ATX returned by this method.
@@ -610,7 +1219,15 @@ func (db *DB) GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error) {
 // GetNodeAtxIDForEpoch returns an atx published by the provided nodeID for the specified targetEpoch. meaning the atx
 // that the requested nodeID has published. it returns an error if no atx was found for provided nodeID
 func (db *DB) GetNodeAtxIDForEpoch(nodeID types.NodeID, targetEpoch types.EpochID) (types.ATXID, error) {
-	id, err := db.atxs.Get(getNodeAtxKey(nodeID, targetEpoch))
+	if !db.MayHaveAtxForEpoch(nodeID, targetEpoch) {
+		return *types.EmptyATXID, fmt.Errorf("atx for node %v targeting epoch %v: %v",
+			nodeID.ShortString(), targetEpoch, database.ErrNotFound)
+	}
+	node, err := types.NewNodeKey(nodeID)
+	if err != nil {
+		return *types.EmptyATXID, err
+	}
+	id, err := db.atxs.Get(getNodeAtxKey(node, targetEpoch))
 	if err != nil {
 		return *types.EmptyATXID, fmt.Errorf("atx for node %v targeting epoch %v: %v",
 			nodeID.ShortString(), targetEpoch, err)
@@ -627,18 +1244,41 @@ func (db *DB) GetPosAtxID() (types.ATXID, error) {
 	return idAndLayer.AtxID, nil
 }
 
+// GetNodeAtxIDs returns the ids of all atxs that were received for node nodeID, ordered by their sequence number. It
+// returns ErrAtxNotFound if there are none.
+func (db *DB) GetNodeAtxIDs(nodeID types.NodeID) ([]types.ATXID, error) {
+	node, err := types.NewNodeKey(nodeID)
+	if err != nil {
+		return nil, ErrAtxNotFound(err)
+	}
+	it := db.atxs.Find(getNodeChainPrefix(node))
+	defer it.Release()
+	var ids []types.ATXID
+	for it.Next() {
+		ids = append(ids, types.ATXID(types.BytesToHash(it.Value())))
+	}
+	if err := it.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read atx chain of node %v: %v", nodeID.ShortString(), err)
+	}
+	if len(ids) == 0 {
+		return nil, ErrAtxNotFound(fmt.Errorf("atx for node %v does not exist", nodeID.ShortString()))
+	}
+	return ids, nil
+}
+
 // GetAtxHeader returns the ATX header by the given ID. This function is thread safe and will return an error if the ID
 // is not found in the ATX DB.
 func (db *DB) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error) {
-	if id == *types.EmptyATXID {
-		return nil, errors.New("trying to fetch empty atx id")
+	key, err := types.NewAtxKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("trying to fetch atx: %v", err)
 	}
 
 	if atxHeader, gotIt := db.atxHeaderCache.Get(id); gotIt {
 		return atxHeader, nil
 	}
 	db.RLock()
-	atxHeaderBytes, err := db.atxs.Get(getAtxHeaderKey(id))
+	atxHeaderBytes, err := db.atxs.Get(getAtxHeaderKey(key))
 	db.RUnlock()
 	if err != nil {
 		return nil, err
@@ -649,19 +1289,22 @@ func (db *DB) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error) {
 		return nil, err
 	}
 	atxHeader.SetID(&id)
-	db.atxHeaderCache.Add(id, &atxHeader)
+	if !db.readOnly {
+		db.atxHeaderCache.Add(id, &atxHeader)
+	}
 	return &atxHeader, nil
 }
 
 // GetFullAtx returns the full atx struct of the given atxId id, it returns an error if the full atx cannot be found
 // in all databases
 func (db *DB) GetFullAtx(id types.ATXID) (*types.ActivationTx, error) {
-	if id == *types.EmptyATXID {
-		return nil, errors.New("trying to fetch empty atx id")
+	key, err := types.NewAtxKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("trying to fetch atx: %v", err)
 	}
 
 	db.RLock()
-	atxBytes, err := db.atxs.Get(getAtxBodyKey(id))
+	atxBytes, err := db.atxs.Get(getAtxBodyKey(key))
 	db.RUnlock()
 	if err != nil {
 		return nil, err
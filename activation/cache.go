@@ -6,11 +6,25 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 )
 
-// ActivesetCache holds an lru cache of the active set size for a view hash.
+const (
+	// DefaultActivesetCacheSize is the default number of views whose active set size is cached.
+	DefaultActivesetCacheSize = 1000
+	// DefaultAtxCacheSize is the default number of atx headers cached by DB.
+	DefaultAtxCacheSize = 600
+	// DefaultBlockAtxsCacheSize is the default number of blocks whose transitively introduced atxs are cached by DB.
+	DefaultBlockAtxsCacheSize = 5000
+)
+
+// ActivesetCache holds an lru cache of the active set size and total committed space units for a view hash.
 type ActivesetCache struct {
 	*lru.Cache
 }
 
+type activeSetWeight struct {
+	size       uint32
+	spaceUnits uint64
+}
+
 // NewActivesetCache creates a cache for Active set size
 func NewActivesetCache(size int) ActivesetCache {
 	cache, err := lru.New(size)
@@ -20,19 +34,19 @@ func NewActivesetCache(size int) ActivesetCache {
 	return ActivesetCache{Cache: cache}
 }
 
-// Add adds a view hash and a set size that was calculated for this view
-func (bc *ActivesetCache) Add(view types.Hash12, setSize uint32) {
-	bc.Cache.Add(view, setSize)
+// Add adds a view hash and the set size and total space units that were calculated for this view
+func (bc *ActivesetCache) Add(view types.Hash12, setSize uint32, spaceUnits uint64) {
+	bc.Cache.Add(view, activeSetWeight{size: setSize, spaceUnits: spaceUnits})
 }
 
-// Get returns the stored active set size for the provided view hash
-func (bc ActivesetCache) Get(view types.Hash12) (uint32, bool) {
+// Get returns the stored active set size and total space units for the provided view hash
+func (bc ActivesetCache) Get(view types.Hash12) (uint32, uint64, bool) {
 	item, found := bc.Cache.Get(view)
 	if !found {
-		return 0, false
+		return 0, 0, false
 	}
-	blk := item.(uint32)
-	return blk, true
+	weight := item.(activeSetWeight)
+	return weight.size, weight.spaceUnits, true
 }
 
 // AtxCache holds an lru cache of ActivationTxHeader structs of recent atx used to calculate active set size
@@ -66,3 +80,40 @@ func (bc AtxCache) Get(id types.ATXID) (*types.ActivationTxHeader, bool) {
 	atxHeader := item.(*types.ActivationTxHeader)
 	return atxHeader, true
 }
+
+// blockAtxs maps the atxs targeting an epoch that a block introduces, in itself or in the blocks of its view, to the
+// ids of the nodes that published them.
+type blockAtxs map[types.ATXID]string
+
+type blockAtxsKey struct {
+	epoch types.EpochID
+	block types.BlockID
+}
+
+// blockAtxsCache holds an lru cache of the atxs targeting an epoch that blocks transitively introduce. Blocks whose
+// views overlap share the sets of the blocks they both view, so the sets held are mostly shared.
+type blockAtxsCache struct {
+	*lru.Cache
+}
+
+func newBlockAtxsCache(size int) blockAtxsCache {
+	cache, err := lru.New(size)
+	if err != nil {
+		log.Fatal("could not initialize cache ", err)
+	}
+	return blockAtxsCache{Cache: cache}
+}
+
+// Add adds the atxs targeting epoch that the block with the given id transitively introduces
+func (bc *blockAtxsCache) Add(epoch types.EpochID, id types.BlockID, atxs blockAtxs) {
+	bc.Cache.Add(blockAtxsKey{epoch: epoch, block: id}, atxs)
+}
+
+// Get returns the atxs targeting epoch that the block with the given id transitively introduces, if they're cached
+func (bc blockAtxsCache) Get(epoch types.EpochID, id types.BlockID) (blockAtxs, bool) {
+	item, found := bc.Cache.Get(blockAtxsKey{epoch: epoch, block: id})
+	if !found {
+		return nil, false
+	}
+	return item.(blockAtxs), true
+}
@@ -0,0 +1,224 @@
+package activation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// CheckpointVersion is the version of the epoch checkpoint format written by ExportEpoch.
+const CheckpointVersion = 1
+
+// maxCheckpointRecord bounds the size of a checkpoint record, so that a corrupt length doesn't allocate without bound.
+const maxCheckpointRecord = 64 << 20
+
+// EpochSummary is the summary of an epoch's atxs that a checkpoint starts with. Nodes that bootstrap from a checkpoint
+// compare its AtxsHash with one they trust, e.g. one published with the node release, before importing it.
+type EpochSummary struct {
+	Version    uint32
+	Epoch      types.EpochID // the target epoch of the atxs
+	AtxCount   uint32
+	SpaceUnits uint64       // the total number of space units the atxs commit, the weight of the active set
+	AtxsHash   types.Hash32 // the hash of the ids of the atxs, in order
+}
+
+// checkpointAtx is an atx of a checkpoint. The atx has no NIPST, its id is derived from its header and doesn't cover
+// the NIPST, so it's still verified. The tick count is the one the exporting node recorded from the atx's PoET proof.
+type checkpointAtx struct {
+	Atx      *types.ActivationTx
+	Ticks    uint64
+	HasTicks bool
+}
+
+func atxsHash(ids []types.ATXID) types.Hash32 {
+	buf := make([]byte, 0, len(ids)*types.Hash32Length)
+	for _, id := range ids {
+		buf = append(buf, id.Bytes()...)
+	}
+	return types.CalcHash32(buf)
+}
+
+func writeCheckpointRecord(w io.Writer, v interface{}) error {
+	b, err := types.InterfaceToBytes(v)
+	if err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(b)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func readCheckpointRecord(r io.Reader, v interface{}) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxCheckpointRecord {
+		return fmt.Errorf("record of %v bytes exceeds the maximum of %v", n, maxCheckpointRecord)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	return types.BytesToInterface(b, v)
+}
+
+// ExportEpoch writes a checkpoint of the atxs targeting epoch to w: a summary of the epoch, followed by its atxs in the
+// order of their ids, without their NIPSTs. A new node imports it with ImportEpoch instead of syncing the mesh up to
+// the epoch to learn its active set.
+func (db *DB) ExportEpoch(epoch types.EpochID, w io.Writer) (*EpochSummary, error) {
+	ids, err := db.GetEpochAtxs(epoch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read atxs of epoch %v: %v", epoch, err)
+	}
+	summary := &EpochSummary{Version: CheckpointVersion, Epoch: epoch, AtxCount: uint32(len(ids)), AtxsHash: atxsHash(ids)}
+	for _, id := range ids {
+		atx, err := db.GetAtxHeader(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read atx %v of epoch %v: %v", id.ShortString(), epoch, err)
+		}
+		summary.SpaceUnits += uint64(atx.EffectiveSpaceUnits())
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeCheckpointRecord(bw, summary); err != nil {
+		return nil, fmt.Errorf("failed to write epoch summary: %v", err)
+	}
+	for _, id := range ids {
+		atx, err := db.GetFullAtx(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read atx %v of epoch %v: %v", id.ShortString(), epoch, err)
+		}
+		atx.Nipst = nil
+		record := checkpointAtx{Atx: atx}
+		if record.Ticks, err = db.GetAtxTicks(id); err == nil {
+			record.HasTicks = true
+		}
+		if err := writeCheckpointRecord(bw, &record); err != nil {
+			return nil, fmt.Errorf("failed to write atx %v: %v", id.ShortString(), err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	db.log.With().Info("exported epoch checkpoint", log.EpochID(uint64(epoch)),
+		log.Uint32("atx_count", summary.AtxCount), log.String("atxs_hash", summary.AtxsHash.String()))
+	return summary, nil
+}
+
+// ImportEpoch reads a checkpoint that ExportEpoch wrote and stores its atxs, and returns its summary. The atxs aren't
+// validated, their NIPSTs aren't in the checkpoint: the checkpoint is trusted, so callers must check the summary's
+// AtxsHash against a trusted one first, or discard the database if it doesn't match. Every atx is checked against the
+// summary, and either all of them are stored or, on any error, none of them. Atxs the database has already are skipped.
+func (db *DB) ImportEpoch(r io.Reader) (*EpochSummary, error) {
+	if db.readOnly {
+		return nil, errReadOnly
+	}
+	br := bufio.NewReader(r)
+	var summary EpochSummary
+	if err := readCheckpointRecord(br, &summary); err != nil {
+		return nil, fmt.Errorf("failed to read epoch summary: %v", err)
+	}
+	if summary.Version != CheckpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %v, expected %v", summary.Version, CheckpointVersion)
+	}
+
+	db.Lock()
+	defer db.Unlock()
+	batch := db.atxs.NewBatch()
+	ids := make([]types.ATXID, 0, summary.AtxCount)
+	var spaceUnits uint64
+	var top *types.ActivationTx
+	var imported []types.NodeID
+	for i := uint32(0); i < summary.AtxCount; i++ {
+		var record checkpointAtx
+		if err := readCheckpointRecord(br, &record); err != nil {
+			return nil, fmt.Errorf("failed to read atx %v of %v: %v", i+1, summary.AtxCount, err)
+		}
+		atx := record.Atx
+		if atx == nil || atx.InnerActivationTx == nil || atx.ActivationTxHeader == nil {
+			return nil, fmt.Errorf("atx %v of %v is empty", i+1, summary.AtxCount)
+		}
+		atx.CalcAndSetID()
+		if target := atx.TargetEpoch(db.LayersPerEpoch); target != summary.Epoch {
+			return nil, fmt.Errorf("atx %v targets epoch %v, not the checkpoint's epoch %v", atx.ShortString(), target,
+				summary.Epoch)
+		}
+		ids = append(ids, atx.ID())
+		spaceUnits += uint64(atx.EffectiveSpaceUnits())
+		if top == nil || atx.PubLayerID > top.PubLayerID {
+			top = atx
+		}
+
+		key, err := types.NewAtxKey(atx.ID())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := db.atxs.Get(getAtxHeaderKey(key)); err == nil {
+			continue
+		} else if err != database.ErrNotFound {
+			return nil, err
+		}
+		if err := db.storeAtxUnlocked(batch, key, atx); err != nil {
+			return nil, err
+		}
+		if err := db.indexAtx(batch, atx.NodeID, atx); err != nil {
+			return nil, err
+		}
+		if record.HasTicks {
+			if err := batch.Put(getAtxTicksKey(key), util.Uint64ToBytes(record.Ticks)); err != nil {
+				return nil, err
+			}
+		}
+		imported = append(imported, atx.NodeID)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return nil, errors.New("checkpoint has data after its last atx")
+	}
+	if hash := atxsHash(ids); hash != summary.AtxsHash {
+		return nil, fmt.Errorf("atxs hash %v doesn't match the summary's hash %v", hash.ShortString(),
+			summary.AtxsHash.ShortString())
+	}
+	if spaceUnits != summary.SpaceUnits {
+		return nil, fmt.Errorf("atxs commit %v space units, the summary declares %v", spaceUnits, summary.SpaceUnits)
+	}
+	if top != nil {
+		if _, err := db.updateTopAtxIfNeeded(batch, top); err != nil {
+			return nil, err
+		}
+	}
+
+	// the epoch's filter is rebuilt from the epoch index when it's next read
+	if err := batch.Delete(getEpochFilterKey(summary.Epoch)); err != nil {
+		return nil, err
+	}
+	db.filters.Lock()
+	err := batch.Write()
+	db.filters.cache.Remove(summary.Epoch)
+	db.filters.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write atxs of epoch %v: %v", summary.Epoch, err)
+	}
+	db.invalidateActiveIdentities(summary.Epoch)
+	for _, node := range imported {
+		if err := db.StoreNodeIdentity(node); err != nil {
+			db.log.With().Error("cannot store node identity", log.String("atx_node_id", node.ShortString()), log.Err(err))
+		}
+	}
+	db.log.With().Info("imported epoch checkpoint", log.EpochID(uint64(summary.Epoch)),
+		log.Uint32("atx_count", summary.AtxCount), log.Int("imported", len(imported)),
+		log.String("atxs_hash", summary.AtxsHash.String()))
+	return &summary, nil
+}
@@ -0,0 +1,111 @@
+package activation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_ExportImportEpoch(t *testing.T) {
+	r := require.New(t)
+	exporter, _, _ := getAtxDb(t.Name() + "_exporter")
+	coinbase := types.HexToAddress("aaaa")
+
+	var nodes []types.NodeID
+	for i := 0; i < 4; i++ {
+		node := types.NodeID{Key: uuid.New().String()}
+		atx := newActivationTx(node, 0, *types.EmptyATXID, layersPerEpochBig+types.LayerID(i), 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		atx.SpaceUnits = uint32(i)
+		atx.CalcAndSetID()
+		r.NoError(exporter.StoreAtx(1, atx))
+		r.NoError(exporter.storeAtxTicks(atx.ID(), uint64(10+i)))
+		nodes = append(nodes, node)
+	}
+	// an atx of another epoch isn't exported
+	other := newActivationTx(types.NodeID{Key: uuid.New().String()}, 0, *types.EmptyATXID, 2*layersPerEpochBig, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	r.NoError(exporter.StoreAtx(2, other))
+
+	var buf bytes.Buffer
+	summary, err := exporter.ExportEpoch(2, &buf)
+	r.NoError(err)
+	r.Equal(uint32(4), summary.AtxCount)
+	r.Equal(uint64(1+1+2+3), summary.SpaceUnits)
+	checkpoint := buf.Bytes()
+
+	importer, _, _ := getAtxDb(t.Name() + "_importer")
+	imported, err := importer.ImportEpoch(bytes.NewReader(checkpoint))
+	r.NoError(err)
+	r.Equal(summary, imported)
+
+	ids, err := importer.GetEpochAtxs(2)
+	r.NoError(err)
+	exported, err := exporter.GetEpochAtxs(2)
+	r.NoError(err)
+	r.Equal(exported, ids)
+	for _, id := range ids {
+		atx, err := importer.GetFullAtx(id)
+		r.NoError(err)
+		r.Nil(atx.Nipst)
+		want, err := exporter.GetAtxTicks(id)
+		r.NoError(err)
+		ticks, err := importer.GetAtxTicks(id)
+		r.NoError(err)
+		r.Equal(want, ticks)
+	}
+	size, weight, err := importer.ActiveSetSize(2)
+	r.NoError(err)
+	r.Equal(summary.AtxCount, size)
+	r.Equal(summary.SpaceUnits, weight)
+	for i, node := range nodes {
+		r.True(importer.MayHaveAtxForEpoch(node, 2))
+		id, err := importer.GetNodeLastAtxID(node)
+		r.NoError(err)
+		r.Contains(ids, id)
+		_, err = importer.GetIdentity(nodes[i].Key)
+		r.NoError(err)
+	}
+	top, err := importer.GetPosAtxID()
+	r.NoError(err)
+	topAtx, err := importer.GetAtxHeader(top)
+	r.NoError(err)
+	r.Equal(types.LayerID(layersPerEpochBig+3), topAtx.PubLayerID)
+
+	// importing again skips the stored atxs
+	_, err = importer.ImportEpoch(bytes.NewReader(checkpoint))
+	r.NoError(err)
+}
+
+func TestDB_ImportEpochCorrupt(t *testing.T) {
+	r := require.New(t)
+	exporter, _, _ := getAtxDb(t.Name() + "_exporter")
+	coinbase := types.HexToAddress("aaaa")
+	for i := 0; i < 2; i++ {
+		atx := newActivationTx(types.NodeID{Key: uuid.New().String()}, 0, *types.EmptyATXID, layersPerEpochBig, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		r.NoError(exporter.StoreAtx(1, atx))
+	}
+	var buf bytes.Buffer
+	_, err := exporter.ExportEpoch(2, &buf)
+	r.NoError(err)
+	checkpoint := buf.Bytes()
+
+	// a corrupt atx fails the import, and nothing is stored
+	corrupt := append([]byte{}, checkpoint...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	importer, _, _ := getAtxDb(t.Name() + "_importer")
+	_, err = importer.ImportEpoch(bytes.NewReader(corrupt))
+	r.Error(err)
+	ids, err := importer.GetEpochAtxs(2)
+	r.NoError(err)
+	r.Empty(ids)
+
+	// a truncated checkpoint
+	_, err = importer.ImportEpoch(bytes.NewReader(checkpoint[:len(checkpoint)-10]))
+	r.Error(err)
+
+	// trailing data
+	_, err = importer.ImportEpoch(bytes.NewReader(append(append([]byte{}, checkpoint...), 0)))
+	r.EqualError(err, "checkpoint has data after its last atx")
+}
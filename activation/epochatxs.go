@@ -0,0 +1,103 @@
+package activation
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// forEachEpochAtx calls f with the node key and atx id of every atx targeting epoch, in the order of their ids, until
+// f returns false. It reads only the epoch's part of the epoch index.
+func (db *DB) forEachEpochAtx(epoch types.EpochID, f func(nodeKey string, id types.ATXID) bool) {
+	prefix := getEpochAtxPrefix(epoch)
+	it := db.atxs.Find(prefix)
+	defer it.Release()
+	for it.Next() {
+		id := types.ATXID(types.BytesToHash(it.Key()[len(prefix):]))
+		node, err := types.ParseNodeKey(string(it.Value()))
+		if err != nil {
+			db.log.With().Error("malformed node key in epoch index", log.AtxID(id.ShortString()), log.Err(err))
+			continue
+		}
+		if !f(node.Key(), id) {
+			return
+		}
+	}
+}
+
+// IterateEpochAtxs calls f with every atx targeting epoch, in the order of their ids, until f returns false. Atxs are
+// read one at a time, so epochs of any size are traversed without loading all of their atxs.
+func (db *DB) IterateEpochAtxs(epoch types.EpochID, f func(*types.ActivationTx) bool) error {
+	var err error
+	db.forEachEpochAtx(epoch, func(_ string, id types.ATXID) bool {
+		var atx *types.ActivationTx
+		if atx, err = db.GetFullAtx(id); err != nil {
+			err = fmt.Errorf("failed to read atx %v of epoch %v: %v", id.ShortString(), epoch, err)
+			return false
+		}
+		return f(atx)
+	})
+	return err
+}
+
+// EpochAtxIDs returns the ids of up to limit atxs targeting epoch, in order, starting after the id after, or from the
+// first atx of the epoch if after is nil. Passing the last id of a page as after returns the next page, and a page
+// shorter than limit is the last one.
+func (db *DB) EpochAtxIDs(epoch types.EpochID, after *types.ATXID, limit int) ([]types.ATXID, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid page limit %v", limit)
+	}
+	prefix := getEpochAtxPrefix(epoch)
+	it := db.atxs.Find(prefix)
+	defer it.Release()
+
+	ok := it.First()
+	if after != nil {
+		start := getEpochAtxKey(epoch, *after)
+		ok = it.Seek(start)
+		if ok && bytes.Equal(it.Key(), start) {
+			ok = it.Next()
+		}
+	}
+	ids := make([]types.ATXID, 0, limit)
+	for ; ok && len(ids) < limit; ok = it.Next() {
+		ids = append(ids, types.ATXID(types.BytesToHash(it.Key()[len(prefix):])))
+	}
+	return ids, it.Error()
+}
+
+// GetEpochAtxs returns the ids of all the atxs targeting epoch, in order. They're read from the epoch index, which
+// StoreAtx writes with every atx, so the epoch's atxs don't have to be re-derived from block views.
+func (db *DB) GetEpochAtxs(epoch types.EpochID) ([]types.ATXID, error) {
+	prefix := getEpochAtxPrefix(epoch)
+	it := db.atxs.Find(prefix)
+	defer it.Release()
+	var ids []types.ATXID
+	for it.Next() {
+		ids = append(ids, types.ATXID(types.BytesToHash(it.Key()[len(prefix):])))
+	}
+	return ids, it.Error()
+}
+
+// ActiveSetSize returns the number of atxs targeting epoch and the total number of space units they commit, the
+// weight of the epoch's active set, as read from the epoch index.
+func (db *DB) ActiveSetSize(epoch types.EpochID) (uint32, uint64, error) {
+	size, weight := uint32(0), uint64(0)
+	var err error
+	db.forEachEpochAtx(epoch, func(_ string, id types.ATXID) bool {
+		var atx *types.ActivationTxHeader
+		if atx, err = db.GetAtxHeader(id); err != nil {
+			err = fmt.Errorf("failed to read atx %v of epoch %v: %v", id.ShortString(), epoch, err)
+			return false
+		}
+		size++
+		weight += uint64(atx.EffectiveSpaceUnits())
+		return true
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return size, weight, nil
+}
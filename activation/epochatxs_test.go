@@ -0,0 +1,142 @@
+package activation
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_IterateEpochAtxs(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	var ids []types.ATXID
+	for i := 0; i < 7; i++ {
+		pub := types.LayerID(layersPerEpochBig) // targets epoch 2
+		if i%3 == 0 {
+			pub = 2 * layersPerEpochBig // targets epoch 3
+		}
+		atx := newActivationTx(types.NodeID{Key: uuid.New().String()}, 0, *types.EmptyATXID, pub, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		r.NoError(atxdb.StoreAtx(1, atx))
+		if i%3 != 0 {
+			ids = append(ids, atx.ID())
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i].Bytes(), ids[j].Bytes()) < 0 })
+
+	var iterated []types.ATXID
+	r.NoError(atxdb.IterateEpochAtxs(2, func(atx *types.ActivationTx) bool {
+		r.Equal(types.EpochID(2), atx.TargetEpoch(layersPerEpochBig))
+		iterated = append(iterated, atx.ID())
+		return true
+	}))
+	r.Equal(ids, iterated)
+
+	iterated = iterated[:0]
+	r.NoError(atxdb.IterateEpochAtxs(2, func(atx *types.ActivationTx) bool {
+		iterated = append(iterated, atx.ID())
+		return len(iterated) < 2
+	}))
+	r.Equal(ids[:2], iterated)
+
+	r.NoError(atxdb.IterateEpochAtxs(5, func(atx *types.ActivationTx) bool {
+		r.Fail("no atxs target epoch 5")
+		return true
+	}))
+}
+
+func TestDB_EpochAtxIDs(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	var ids []types.ATXID
+	for i := 0; i < 5; i++ {
+		atx := newActivationTx(types.NodeID{Key: uuid.New().String()}, 0, *types.EmptyATXID, layersPerEpochBig, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		r.NoError(atxdb.StoreAtx(1, atx))
+		ids = append(ids, atx.ID())
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i].Bytes(), ids[j].Bytes()) < 0 })
+
+	var paged []types.ATXID
+	var after *types.ATXID
+	for {
+		page, err := atxdb.EpochAtxIDs(2, after, 2)
+		r.NoError(err)
+		paged = append(paged, page...)
+		if len(page) < 2 {
+			break
+		}
+		after = &page[len(page)-1]
+	}
+	r.Equal(ids, paged)
+
+	page, err := atxdb.EpochAtxIDs(3, nil, 2)
+	r.NoError(err)
+	r.Empty(page)
+	_, err = atxdb.EpochAtxIDs(2, nil, 0)
+	r.Error(err)
+}
+
+func TestDB_GetEpochAtxs(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	var ids []types.ATXID
+	for i := 0; i < 4; i++ {
+		atx := newActivationTx(types.NodeID{Key: uuid.New().String()}, 0, *types.EmptyATXID, layersPerEpochBig, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		atx.SpaceUnits = uint32(i) // 0 counts as a single space unit
+		atx.CalcAndSetID()
+		r.NoError(atxdb.StoreAtx(1, atx))
+		ids = append(ids, atx.ID())
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i].Bytes(), ids[j].Bytes()) < 0 })
+
+	epochAtxs, err := atxdb.GetEpochAtxs(2)
+	r.NoError(err)
+	r.Equal(ids, epochAtxs)
+	size, weight, err := atxdb.ActiveSetSize(2)
+	r.NoError(err)
+	r.Equal(uint32(4), size)
+	r.Equal(uint64(1+1+2+3), weight)
+
+	epochAtxs, err = atxdb.GetEpochAtxs(3)
+	r.NoError(err)
+	r.Empty(epochAtxs)
+	size, weight, err = atxdb.ActiveSetSize(3)
+	r.NoError(err)
+	r.Zero(size)
+	r.Zero(weight)
+}
+
+func TestDB_ActiveIdentities(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	storeNodeAtx := func(node types.NodeID) types.ATXID {
+		atx := newActivationTx(node, 0, *types.EmptyATXID, layersPerEpochBig, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		r.NoError(atxdb.StoreAtx(1, atx))
+		return atx.ID()
+	}
+	first, second := types.NodeID{Key: uuid.New().String()}, types.NodeID{Key: uuid.New().String()}
+	firstAtx := storeNodeAtx(first)
+
+	ids := atxdb.ActiveIdentities(2)
+	r.Equal(map[string]types.ATXID{first.Key: firstAtx}, ids)
+	r.Empty(atxdb.ActiveIdentities(3))
+	_, cached := atxdb.identities.cache.Get(types.EpochID(2))
+	r.True(cached)
+
+	// storing an atx targeting the epoch drops the cached identities
+	secondAtx := storeNodeAtx(second)
+	_, cached = atxdb.identities.cache.Get(types.EpochID(2))
+	r.False(cached)
+	r.Equal(map[string]types.ATXID{first.Key: firstAtx, second.Key: secondAtx}, atxdb.ActiveIdentities(2))
+}
@@ -0,0 +1,123 @@
+package activation
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+const (
+	// epochFilterBits is the size of an epoch filter, it keeps false positives under 1% for up to ~10k identities
+	epochFilterBits   = 1 << 17
+	epochFilterHashes = 3
+	// we expect lookups for the current, next and previous epochs only
+	epochFilterCacheSize = 4
+)
+
+// epochFilter is a bloom filter of the identities that published an atx targeting an epoch.
+type epochFilter []byte
+
+func (f epochFilter) positions(nodeKey string) [epochFilterHashes]uint32 {
+	h := fnv.New64a()
+	h.Write([]byte(nodeKey))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)
+	var pos [epochFilterHashes]uint32
+	for i := range pos {
+		pos[i] = (h1 + uint32(i)*h2) % epochFilterBits
+	}
+	return pos
+}
+
+func (f epochFilter) add(nodeKey string) {
+	for _, p := range f.positions(nodeKey) {
+		f[p/8] |= 1 << (p % 8)
+	}
+}
+
+func (f epochFilter) mayContain(nodeKey string) bool {
+	for _, p := range f.positions(nodeKey) {
+		if f[p/8]&(1<<(p%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// epochFilters caches the epoch filters read from the database.
+type epochFilters struct {
+	sync.Mutex
+	cache *lru.Cache
+}
+
+func newEpochFilters() *epochFilters {
+	cache, err := lru.New(epochFilterCacheSize)
+	if err != nil {
+		log.Panic("could not initialize epoch filter cache: %v", err)
+	}
+	return &epochFilters{cache: cache}
+}
+
+// epochFilter returns the filter of targetEpoch. A filter missing in the database, e.g. of an epoch whose atxs were
+// stored before filters were, is built from the node atx index and persisted. It must be called under db.filters lock.
+func (db *DB) epochFilter(targetEpoch types.EpochID) (epochFilter, error) {
+	if f, ok := db.filters.cache.Get(targetEpoch); ok {
+		return f.(epochFilter), nil
+	}
+	b, err := db.atxs.Get(getEpochFilterKey(targetEpoch))
+	switch {
+	case err == nil && len(b) == epochFilterBits/8:
+		db.filters.cache.Add(targetEpoch, epochFilter(b))
+		return b, nil
+	case err != nil && err != database.ErrNotFound:
+		return nil, err
+	}
+
+	f := make(epochFilter, epochFilterBits/8)
+	db.forEachEpochAtx(targetEpoch, func(nodeKey string, _ types.ATXID) bool {
+		f.add(nodeKey)
+		return true
+	})
+	if err := db.atxs.Put(getEpochFilterKey(targetEpoch), f); err != nil {
+		return nil, err
+	}
+	db.filters.cache.Add(targetEpoch, f)
+	return f, nil
+}
+
+// addToEpochFilter writes the filter of targetEpoch with the node added to w, and returns it. The cached filter is left
+// as is, it returns nil if the filter has the node already.
+func (db *DB) addToEpochFilter(w database.Putter, nodeID types.NodeID, targetEpoch types.EpochID) (epochFilter, error) {
+	db.filters.Lock()
+	defer db.filters.Unlock()
+	f, err := db.epochFilter(targetEpoch)
+	if err != nil {
+		return nil, err
+	}
+	if f.mayContain(nodeID.Key) {
+		return nil, nil
+	}
+	f = append(epochFilter(nil), f...)
+	f.add(nodeID.Key)
+	if err := w.Put(getEpochFilterKey(targetEpoch), f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// MayHaveAtxForEpoch returns false if the node didn't publish an atx targeting targetEpoch, and true if it might have.
+// It doesn't read the node atx index, so it's a cheap check to run before looking an atx up.
+func (db *DB) MayHaveAtxForEpoch(nodeID types.NodeID, targetEpoch types.EpochID) bool {
+	db.filters.Lock()
+	defer db.filters.Unlock()
+	f, err := db.epochFilter(targetEpoch)
+	if err != nil {
+		db.log.With().Warning("failed to read epoch filter", log.Uint64("epoch_id", uint64(targetEpoch)), log.Err(err))
+		return true
+	}
+	return f.mayContain(nodeID.Key)
+}
@@ -0,0 +1,57 @@
+package activation
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_MayHaveAtxForEpoch(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	id := types.NodeID{Key: uuid.New().String()}
+	atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	r.NoError(atxdb.StoreAtx(1, atx))
+	target := atx.TargetEpoch(atxdb.LayersPerEpoch)
+
+	r.True(atxdb.MayHaveAtxForEpoch(id, target))
+	r.False(atxdb.MayHaveAtxForEpoch(id, target+1))
+	other := types.NodeID{Key: uuid.New().String()}
+	r.False(atxdb.MayHaveAtxForEpoch(other, target))
+	_, err := atxdb.GetNodeAtxIDForEpoch(other, target)
+	r.Error(err)
+	atxID, err := atxdb.GetNodeAtxIDForEpoch(id, target)
+	r.NoError(err)
+	r.Equal(atx.ID(), atxID)
+
+	// the filter is persisted
+	b, err := atxdb.atxs.Get(getEpochFilterKey(target))
+	r.NoError(err)
+	r.True(epochFilter(b).mayContain(id.Key))
+}
+
+func TestDB_epochFilterRebuild(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	id := types.NodeID{Key: uuid.New().String()}
+	atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	r.NoError(atxdb.StoreAtx(1, atx))
+	target := atx.TargetEpoch(atxdb.LayersPerEpoch)
+
+	// as if the atx was stored before epoch filters were
+	r.NoError(atxdb.atxs.Delete(getEpochFilterKey(target)))
+	atxdb.filters = newEpochFilters()
+	_, err := atxdb.atxs.Get(getEpochFilterKey(target))
+	r.Equal(database.ErrNotFound, err)
+
+	r.True(atxdb.MayHaveAtxForEpoch(id, target))
+	_, err = atxdb.atxs.Get(getEpochFilterKey(target))
+	r.NoError(err)
+}
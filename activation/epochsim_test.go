@@ -0,0 +1,182 @@
+package activation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/rand"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+const simLayersPerEpoch = 3
+
+type simMiner struct {
+	signer  *signing.EdSigner
+	id      types.NodeID
+	lastAtx *types.ActivationTx
+}
+
+// epochSimulator drives an atx db and a mesh through synthetic epochs, as seen by a single node. Every epoch the
+// publishing miners publish an atx in the epoch's first layer, chained to their previous atx and positioned on the top
+// atx, and one block is added to every layer, voting for and viewing the block of the layer before it.
+type epochSimulator struct {
+	t         *testing.T
+	r         *require.Assertions
+	atxdb     *DB
+	msh       *mesh.Mesh
+	miners    []*simMiner
+	epoch     types.EpochID
+	tip       []types.BlockID
+	published map[types.EpochID]map[string]types.ATXID
+}
+
+func newEpochSimulator(t *testing.T, miners int) *epochSimulator {
+	atxdb, msh, _ := getAtxDb(t.Name())
+	atxdb.LayersPerEpoch = simLayersPerEpoch
+	s := &epochSimulator{
+		t:         t,
+		r:         require.New(t),
+		atxdb:     atxdb,
+		msh:       msh,
+		published: make(map[types.EpochID]map[string]types.ATXID),
+	}
+	for i := 0; i < miners; i++ {
+		signer := signing.NewEdSigner()
+		s.miners = append(s.miners, &simMiner{
+			signer: signer,
+			id:     types.NodeID{Key: signer.PublicKey().String(), VRFPublicKey: []byte("vrf")},
+		})
+	}
+	return s
+}
+
+// publishAtx returns the signed atx miner m publishes in layer, after checking that it's syntactically valid.
+func (s *epochSimulator) publishAtx(m *simMiner, layer types.LayerID) *types.ActivationTx {
+	challenge := types.NIPSTChallenge{
+		NodeID:     m.id,
+		PubLayerID: layer,
+	}
+	var commitment *types.PostProof
+	if m.lastAtx != nil {
+		challenge.Sequence = m.lastAtx.Sequence + 1
+		challenge.PrevATXID = m.lastAtx.ID()
+	} else {
+		commitment = &types.PostProof{MerkleRoot: []byte("commitment")}
+		challenge.CommitmentMerkleRoot = commitment.MerkleRoot
+	}
+	if posAtx, err := s.atxdb.GetPosAtxID(); err == nil {
+		challenge.PositioningATX = posAtx
+	}
+
+	hash, err := challenge.Hash()
+	s.r.NoError(err)
+	activeSetSize := uint32(0)
+	if s.epoch > 0 {
+		activeSetSize = uint32(len(s.published[s.epoch-1]))
+	}
+	atx := types.NewActivationTx(challenge, types.HexToAddress("aaaa"), activeSetSize, s.tip,
+		NewNIPSTWithChallenge(hash, []byte("poet")), commitment)
+	s.r.NoError(SignAtx(m.signer, atx))
+	atx.CalcAndSetID()
+	s.r.NoError(s.atxdb.SyntacticallyValidateAtx(context.Background(), atx), "epoch %v miner %v", s.epoch, m.id.ShortString())
+	return atx
+}
+
+// runEpoch runs the next epoch, in which the miners of the given indices publish atxs, and checks the atx db's view
+// of the miners, the positioning atx and the active set at its end.
+func (s *epochSimulator) runEpoch(publishers ...int) {
+	first := s.epoch.FirstLayer(simLayersPerEpoch)
+	var atxs []*types.ActivationTx
+	published := make(map[string]types.ATXID)
+	for _, i := range publishers {
+		atx := s.publishAtx(s.miners[i], first)
+		atxs = append(atxs, atx)
+		published[atx.NodeID.Key] = atx.ID()
+	}
+	s.published[s.epoch] = published
+
+	for layer := first; layer < first+simLayersPerEpoch; layer++ {
+		block := types.NewExistingBlock(layer, []byte(rand.String(8)))
+		block.BlockVotes = append(block.BlockVotes, s.tip...)
+		block.ViewEdges = append(block.ViewEdges, s.tip...)
+		var blockAtxs []*types.ActivationTx
+		if layer == first {
+			for _, atx := range atxs {
+				block.ATXIDs = append(block.ATXIDs, atx.ID())
+			}
+			blockAtxs = atxs
+		}
+		block.Initialize()
+		s.r.NoError(s.msh.AddBlockWithTxs(block, []*types.Transaction{}, blockAtxs))
+		s.tip = []types.BlockID{block.ID()}
+	}
+
+	for _, atx := range atxs {
+		m := s.minerOf(atx.NodeID)
+		last, err := s.atxdb.GetNodeLastAtxID(m.id)
+		s.r.NoError(err)
+		s.r.Equal(atx.ID(), last)
+		id, err := s.atxdb.GetNodeAtxIDForEpoch(m.id, s.epoch+1)
+		s.r.NoError(err)
+		s.r.Equal(atx.ID(), id)
+		header, err := s.atxdb.GetAtxHeader(last)
+		s.r.NoError(err)
+		if m.lastAtx != nil {
+			s.r.Equal(m.lastAtx.Sequence+1, header.Sequence)
+		} else {
+			s.r.Zero(header.Sequence)
+		}
+		m.lastAtx = atx
+	}
+
+	if len(atxs) > 0 {
+		posAtx, err := s.atxdb.GetPosAtxID()
+		s.r.NoError(err)
+		header, err := s.atxdb.GetAtxHeader(posAtx)
+		s.r.NoError(err)
+		s.r.Equal(first, header.PubLayerID)
+	}
+
+	view := make(map[types.BlockID]struct{})
+	for _, id := range s.tip {
+		view[id] = struct{}{}
+	}
+	actives, err := s.atxdb.CalcActiveSetSize(s.epoch+1, view)
+	s.r.NoError(err)
+	s.r.Len(actives, len(published))
+	for key := range published {
+		s.r.Contains(actives, key)
+	}
+	s.epoch++
+}
+
+func (s *epochSimulator) minerOf(id types.NodeID) *simMiner {
+	for _, m := range s.miners {
+		if m.id.Key == id.Key {
+			return m
+		}
+	}
+	s.t.Fatalf("unknown miner %v", id.ShortString())
+	return nil
+}
+
+func TestEpochSimulator(t *testing.T) {
+	s := newEpochSimulator(t, 4)
+	s.runEpoch(0, 1)
+	s.runEpoch(0, 1, 2)
+	s.runEpoch(1, 2, 3)
+	s.runEpoch(0, 3)
+	s.runEpoch(0, 1, 2, 3)
+	s.runEpoch(2)
+	s.runEpoch(0, 1, 2, 3)
+
+	for _, m := range s.miners {
+		last, err := s.atxdb.GetNodeLastAtxID(m.id)
+		require.NoError(t, err)
+		require.Equal(t, m.lastAtx.ID(), last)
+	}
+	require.Equal(t, uint64(4), s.miners[2].lastAtx.Sequence)
+}
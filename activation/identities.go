@@ -1,38 +1,56 @@
 package activation
 
 import (
+	"github.com/hashicorp/golang-lru"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/database"
-	"github.com/spacemeshos/sha256-simd"
+	"github.com/spacemeshos/go-spacemesh/log"
 )
 
+// identityCacheSize is the number of identities IdentityStore caches.
+const identityCacheSize = 1000
+
 // IdentityStore stores couples of identities and used to retrieve bls identity by provided ed25519 identity
 type IdentityStore struct {
 	//todo: think about whether we need one db or several(#1922)
-	ids database.Database
+	ids   database.Database
+	cache *lru.Cache // VRF public keys by ed25519 identity
 }
 
 // NewIdentityStore creates a new identity store
 func NewIdentityStore(db database.Database) *IdentityStore {
-	return &IdentityStore{db}
+	cache, err := lru.New(identityCacheSize)
+	if err != nil {
+		log.Panic("could not initialize identity cache: %v", err)
+	}
+	return &IdentityStore{ids: db, cache: cache}
 }
 
-func getKey(key string) [32]byte {
-	return sha256.Sum256(util.Hex2Bytes(key))
+func getKey(key string) types.Hash32 {
+	return types.CalcHash32(util.Hex2Bytes(key))
 }
 
 // StoreNodeIdentity stores a NodeID type, which consists of 2 identities: BLS and ed25519
 func (s *IdentityStore) StoreNodeIdentity(id types.NodeID) error {
 	key := getKey(id.Key)
-	err := s.ids.Put(key[:], id.VRFPublicKey)
-	return err
+	if err := s.ids.Put(key[:], id.VRFPublicKey); err != nil {
+		return err
+	}
+	s.cache.Add(id.Key, id.VRFPublicKey)
+	return nil
 }
 
 // GetIdentity gets the identity by the provided ed25519 string id, it returns a NodeID struct or an error if id
 // was not found
 func (s *IdentityStore) GetIdentity(id string) (types.NodeID, error) {
+	if vrf, ok := s.cache.Get(id); ok {
+		return types.NodeID{Key: id, VRFPublicKey: vrf.([]byte)}, nil
+	}
 	key := getKey(id)
 	bytes, err := s.ids.Get(key[:])
+	if err == nil {
+		s.cache.Add(id, bytes)
+	}
 	return types.NodeID{Key: id, VRFPublicKey: bytes}, err
 }
@@ -0,0 +1,305 @@
+package activation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// The atxs store holds the keys below, each kind under its own two byte prefix. No prefix is a prefix of another and
+// the parts after the prefix have a fixed length or, for node keys, end at a separator node keys can't contain, so
+// every key decodes to exactly one kind:
+//
+//	h_<atx id>                   header of an atx
+//	b_<atx id>                   body of an atx
+//	t_<atx id>                   tick count of an atx
+//	n_<node key>_<target epoch>  id of the atx a node published targeting an epoch
+//	e_<target epoch><atx id>     node key of an atx targeting an epoch, the epoch index
+//	c_<node key>_<sequence>      id of the atx a node published with a sequence number, the node's atx chain
+//	f_<target epoch>             bloom filter of the nodes that published atxs targeting an epoch
+//	i_<atx id>                   intent to process an atx
+//	x_<signed atx hash>          version of the validation that found a signed atx syntactically valid
+//	s_<sequence number>          change of the top atx, in the positioning atx history
+//	p_top                        id and layer of the top atx, the positioning atx candidate
+//	v_keys                       version of the key scheme
+//
+// Atx ids are their 32 bytes, and epochs and sequence numbers are 8 big endian bytes, so a node's keys sort by epoch,
+// its atx chain by sequence number, the epoch index by epoch and then atx id, and the positioning atx history by
+// sequence number.
+const (
+	atxHeaderPrefix     = "h_"
+	atxBodyPrefix       = "b_"
+	atxTicksPrefix      = "t_"
+	nodeAtxPrefix       = "n_"
+	epochAtxPrefix      = "e_"
+	nodeChainPrefix     = "c_"
+	epochFilterPrefix   = "f_"
+	atxIntentPrefix     = "i_"
+	validAtxPrefix      = "x_"
+	posAtxHistoryPrefix = "s_"
+	topAtxKey           = "p_top"
+	keysVersionKey      = "v_keys"
+)
+
+// keysVersion is the version of the key scheme, DB.MigrateKeys migrates the keys of older versions to it.
+const keysVersion = 3
+
+func getNodeAtxKey(node types.NodeKey, targetEpoch types.EpochID) []byte {
+	return append(getNodeAtxPrefix(node), util.Uint64ToBytesBigEndian(uint64(targetEpoch))...)
+}
+
+func getNodeAtxPrefix(node types.NodeKey) []byte {
+	return []byte(nodeAtxPrefix + node.String() + types.NodeKeySeparator)
+}
+
+func getNodeChainKey(node types.NodeKey, sequence uint64) []byte {
+	return append(getNodeChainPrefix(node), util.Uint64ToBytesBigEndian(sequence)...)
+}
+
+func getNodeChainPrefix(node types.NodeKey) []byte {
+	return []byte(nodeChainPrefix + node.String() + types.NodeKeySeparator)
+}
+
+func getEpochAtxKey(targetEpoch types.EpochID, atx types.ATXID) []byte {
+	return append(getEpochAtxPrefix(targetEpoch), atx.Bytes()...)
+}
+
+func getEpochAtxPrefix(targetEpoch types.EpochID) []byte {
+	return append([]byte(epochAtxPrefix), util.Uint64ToBytesBigEndian(uint64(targetEpoch))...)
+}
+
+func getAtxHeaderKey(atx types.AtxKey) []byte {
+	return append([]byte(atxHeaderPrefix), atx.Bytes()...)
+}
+
+func getAtxBodyKey(atx types.AtxKey) []byte {
+	return append([]byte(atxBodyPrefix), atx.Bytes()...)
+}
+
+func getAtxTicksKey(atx types.AtxKey) []byte {
+	return append([]byte(atxTicksPrefix), atx.Bytes()...)
+}
+
+func getEpochFilterKey(targetEpoch types.EpochID) []byte {
+	return append([]byte(epochFilterPrefix), util.Uint64ToBytesBigEndian(uint64(targetEpoch))...)
+}
+
+func getValidAtxKey(hash types.Hash32) []byte {
+	return append([]byte(validAtxPrefix), hash.Bytes()...)
+}
+
+func getPosAtxHistoryKey(seq uint64) []byte {
+	return append([]byte(posAtxHistoryPrefix), util.Uint64ToBytesBigEndian(seq)...)
+}
+
+// atxStoreKey is a decoded key of the atxs store. Only the fields of its kind are set.
+type atxStoreKey struct {
+	prefix string
+	atx    types.ATXID
+	hash   types.Hash32 // hash of a signed atx, see signedAtxHash
+	node   string
+	epoch  types.EpochID
+	seq    uint64 // sequence number of a positioning atx history change, or of an atx in a node's chain
+}
+
+var errUnknownKey = errors.New("unknown key")
+
+// decodeAtxStoreKey decodes a key of the atxs store, it returns errUnknownKey for keys that aren't in the key scheme.
+func decodeAtxStoreKey(key []byte) (atxStoreKey, error) {
+	switch string(key) {
+	case topAtxKey, keysVersionKey:
+		return atxStoreKey{prefix: string(key)}, nil
+	}
+	if len(key) < 2 {
+		return atxStoreKey{}, errUnknownKey
+	}
+	prefix, rest := string(key[:2]), key[2:]
+	switch prefix {
+	case atxHeaderPrefix, atxBodyPrefix, atxTicksPrefix, atxIntentPrefix:
+		if len(rest) != types.Hash32Length {
+			return atxStoreKey{}, fmt.Errorf("%v key of %v bytes", prefix, len(rest))
+		}
+		return atxStoreKey{prefix: prefix, atx: types.ATXID(types.BytesToHash(rest))}, nil
+	case validAtxPrefix:
+		if len(rest) != types.Hash32Length {
+			return atxStoreKey{}, fmt.Errorf("%v key of %v bytes", prefix, len(rest))
+		}
+		return atxStoreKey{prefix: prefix, hash: types.BytesToHash(rest)}, nil
+	case epochFilterPrefix:
+		if len(rest) != 8 {
+			return atxStoreKey{}, fmt.Errorf("%v key of %v bytes", prefix, len(rest))
+		}
+		return atxStoreKey{prefix: prefix, epoch: types.EpochID(binary.BigEndian.Uint64(rest))}, nil
+	case epochAtxPrefix:
+		if len(rest) != 8+types.Hash32Length {
+			return atxStoreKey{}, fmt.Errorf("%v key of %v bytes", prefix, len(rest))
+		}
+		return atxStoreKey{prefix: prefix, epoch: types.EpochID(binary.BigEndian.Uint64(rest[:8])),
+			atx: types.ATXID(types.BytesToHash(rest[8:]))}, nil
+	case posAtxHistoryPrefix:
+		if len(rest) != 8 {
+			return atxStoreKey{}, fmt.Errorf("%v key of %v bytes", prefix, len(rest))
+		}
+		return atxStoreKey{prefix: prefix, seq: binary.BigEndian.Uint64(rest)}, nil
+	case nodeAtxPrefix:
+		sep := bytes.Index(rest, []byte(types.NodeKeySeparator))
+		if sep < 1 || len(rest) != sep+1+8 {
+			return atxStoreKey{}, fmt.Errorf("malformed %v key", prefix)
+		}
+		return atxStoreKey{prefix: prefix, node: string(rest[:sep]),
+			epoch: types.EpochID(binary.BigEndian.Uint64(rest[sep+1:]))}, nil
+	case nodeChainPrefix:
+		sep := bytes.Index(rest, []byte(types.NodeKeySeparator))
+		if sep < 1 || len(rest) != sep+1+8 {
+			return atxStoreKey{}, fmt.Errorf("malformed %v key", prefix)
+		}
+		return atxStoreKey{prefix: prefix, node: string(rest[:sep]), seq: binary.BigEndian.Uint64(rest[sep+1:])}, nil
+	}
+	return atxStoreKey{}, errUnknownKey
+}
+
+// MigrateKeys migrates the keys of the atxs store to the current key scheme. It should be called on startup, before
+// atxs are processed.
+func (db *DB) MigrateKeys() error {
+	db.Lock()
+	defer db.Unlock()
+
+	version := uint64(0)
+	if b, err := db.atxs.Get([]byte(keysVersionKey)); err == nil {
+		version = util.BytesToUint64(b)
+	} else if err != database.ErrNotFound {
+		return fmt.Errorf("failed to read key scheme version: %v", err)
+	}
+	if version >= keysVersion {
+		return nil
+	}
+	if db.readOnly {
+		return fmt.Errorf("the keys of the atxs store are of version %v, the node must migrate them to version %v first",
+			version, keysVersion)
+	}
+
+	batch := db.atxs.NewBatch()
+	migrated := 0
+	// version 0 wrote atx ids in keys as the text of their bytes, e.g. h_[1 2 3 ...], and had no prefix for the top atx
+	for _, prefix := range []string{atxHeaderPrefix, atxBodyPrefix, atxTicksPrefix} {
+		it := db.atxs.Find([]byte(prefix + "["))
+		for it.Next() {
+			id, err := parseBytesText(string(it.Key()[len(prefix):]))
+			if err != nil || len(id) != types.Hash32Length {
+				it.Release()
+				return fmt.Errorf("cannot migrate key %q: %v", it.Key(), err)
+			}
+			if err := batch.Put(append([]byte(prefix), id...), append([]byte{}, it.Value()...)); err != nil {
+				it.Release()
+				return err
+			}
+			if err := batch.Delete(append([]byte{}, it.Key()...)); err != nil {
+				it.Release()
+				return err
+			}
+			migrated++
+		}
+		it.Release()
+	}
+	if top, err := db.atxs.Get([]byte("topAtxKey")); err == nil {
+		if err := batch.Put([]byte(topAtxKey), top); err != nil {
+			return err
+		}
+		if err := batch.Delete([]byte("topAtxKey")); err != nil {
+			return err
+		}
+		migrated++
+	}
+	// versions before 2 had no epoch index, it's built from the node atx index
+	it := db.atxs.Find([]byte(nodeAtxPrefix))
+	for it.Next() {
+		key, err := decodeAtxStoreKey(it.Key())
+		if err != nil {
+			it.Release()
+			return fmt.Errorf("cannot index key %q: %v", it.Key(), err)
+		}
+		id := types.ATXID(types.BytesToHash(it.Value()))
+		if err := batch.Put(getEpochAtxKey(key.epoch, id), []byte(key.node)); err != nil {
+			it.Release()
+			return err
+		}
+		migrated++
+	}
+	it.Release()
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to migrate keys: %v", err)
+	}
+
+	// versions before 3 had no node atx chains, they're built from the node atx index and the headers, which the batch
+	// above moved to their current keys. Migrating again after a crash here rewrites the same keys.
+	batch = db.atxs.NewBatch()
+	it = db.atxs.Find([]byte(nodeAtxPrefix))
+	for it.Next() {
+		key, err := decodeAtxStoreKey(it.Key())
+		if err != nil {
+			it.Release()
+			return fmt.Errorf("cannot index key %q: %v", it.Key(), err)
+		}
+		id := types.ATXID(types.BytesToHash(it.Value()))
+		node, err := types.ParseNodeKey(key.node)
+		if err != nil {
+			it.Release()
+			return err
+		}
+		atxKey, err := types.NewAtxKey(id)
+		if err != nil {
+			it.Release()
+			return err
+		}
+		// the header cache isn't used, it may hold headers that aren't stored
+		var header types.ActivationTxHeader
+		b, err := db.atxs.Get(getAtxHeaderKey(atxKey))
+		if err == nil {
+			err = types.BytesToInterface(b, &header)
+		}
+		if err != nil {
+			it.Release()
+			return fmt.Errorf("cannot index atx %v in node chain: %v", id.ShortString(), err)
+		}
+		if err := batch.Put(getNodeChainKey(node, header.Sequence), id.Bytes()); err != nil {
+			it.Release()
+			return err
+		}
+		migrated++
+	}
+	it.Release()
+	if err := batch.Put([]byte(keysVersionKey), util.Uint64ToBytes(keysVersion)); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to migrate keys: %v", err)
+	}
+	db.log.With().Info("migrated atx store keys", log.Uint64("from_version", version),
+		log.Uint64("to_version", keysVersion), log.Int("keys", migrated))
+	return nil
+}
+
+// parseBytesText parses the text fmt prints a byte slice as, e.g. [1 2 3].
+func parseBytesText(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("not a byte slice: %v", s)
+	}
+	fields := strings.Fields(s[1 : len(s)-1])
+	b := make([]byte, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseUint(f, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		b[i] = byte(v)
+	}
+	return b, nil
+}
@@ -0,0 +1,148 @@
+package activation
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeAtxStoreKey(k atxStoreKey) []byte {
+	switch k.prefix {
+	case atxHeaderPrefix, atxBodyPrefix, atxTicksPrefix, atxIntentPrefix:
+		return append([]byte(k.prefix), k.atx.Bytes()...)
+	case validAtxPrefix:
+		return getValidAtxKey(k.hash)
+	case epochFilterPrefix:
+		return getEpochFilterKey(k.epoch)
+	case posAtxHistoryPrefix:
+		return getPosAtxHistoryKey(k.seq)
+	case epochAtxPrefix:
+		return getEpochAtxKey(k.epoch, k.atx)
+	case nodeAtxPrefix:
+		node, _ := types.ParseNodeKey(k.node)
+		return getNodeAtxKey(node, k.epoch)
+	case nodeChainPrefix:
+		node, _ := types.ParseNodeKey(k.node)
+		return getNodeChainKey(node, k.seq)
+	}
+	return []byte(k.prefix)
+}
+
+func TestAtxStoreKeys_Unambiguous(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, store := getAtxDb(t.Name())
+	r.NoError(atxdb.MigrateKeys())
+	coinbase := types.HexToAddress("aaaa")
+
+	for i := 0; i < 5; i++ {
+		id := types.NodeID{Key: uuid.New().String()}
+		atx := newActivationTx(id, 0, *types.EmptyATXID, types.LayerID(i*1000), 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		r.NoError(atxdb.StoreAtx(1, atx))
+		r.NoError(atxdb.storeAtxTicks(atx.ID(), 10))
+		r.NoError(atxdb.intents.Begin(atx.ID().Bytes(), []byte("atx")))
+		hash, err := signedAtxHash(atx)
+		r.NoError(err)
+		r.NoError(atxdb.markValidAtx(hash))
+	}
+
+	prefixes := []string{atxHeaderPrefix, atxBodyPrefix, atxTicksPrefix, nodeAtxPrefix, epochFilterPrefix, atxIntentPrefix,
+		validAtxPrefix, posAtxHistoryPrefix, epochAtxPrefix, nodeChainPrefix}
+	fixed := []string{topAtxKey, keysVersionKey}
+	kinds := make(map[string]int)
+	it := store.Find(nil)
+	defer it.Release()
+	for it.Next() {
+		key := it.Key()
+		matches := 0
+		for _, p := range prefixes {
+			if bytes.HasPrefix(key, []byte(p)) {
+				matches++
+			}
+		}
+		for _, k := range fixed {
+			if string(key) == k {
+				matches++
+			}
+		}
+		r.Equal(1, matches, "key %q", key)
+
+		decoded, err := decodeAtxStoreKey(key)
+		r.NoError(err, "key %q", key)
+		r.Equal(key, encodeAtxStoreKey(decoded), "key %q", key)
+		kinds[decoded.prefix]++
+	}
+	r.Equal(5, kinds[atxHeaderPrefix])
+	r.Equal(5, kinds[atxBodyPrefix])
+	r.Equal(5, kinds[atxTicksPrefix])
+	r.Equal(5, kinds[nodeAtxPrefix])
+	r.Equal(5, kinds[epochAtxPrefix])
+	r.Equal(5, kinds[nodeChainPrefix])
+	r.Equal(5, kinds[atxIntentPrefix])
+	r.Equal(5, kinds[validAtxPrefix])
+	r.Equal(5, kinds[epochFilterPrefix])
+	r.Equal(5, kinds[posAtxHistoryPrefix])
+	r.Equal(1, kinds[topAtxKey])
+	r.Equal(1, kinds[keysVersionKey])
+}
+
+func TestDB_MigrateKeys(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, store := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	atx := newActivationTx(types.NodeID{Key: uuid.New().String()}, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	r.NoError(atxdb.StoreAtx(1, atx))
+	r.NoError(atxdb.storeAtxTicks(atx.ID(), 10))
+
+	// rewrite the keys as version 0 wrote them, without the epoch index and the node atx chain
+	key, err := types.NewAtxKey(atx.ID())
+	r.NoError(err)
+	node, err := types.NewNodeKey(atx.NodeID)
+	r.NoError(err)
+	r.NoError(store.Delete(getEpochAtxKey(atx.TargetEpoch(atxdb.LayersPerEpoch), atx.ID())))
+	r.NoError(store.Delete(getNodeChainKey(node, atx.Sequence)))
+	for _, k := range [][]byte{getAtxHeaderKey(key), getAtxBodyKey(key), getAtxTicksKey(key), []byte(topAtxKey)} {
+		v, err := store.Get(k)
+		r.NoError(err)
+		r.NoError(store.Delete(k))
+		old := []byte("topAtxKey")
+		if string(k) != topAtxKey {
+			old = []byte(fmt.Sprintf("%v%v", string(k[:2]), atx.ID().Bytes()))
+		}
+		r.NoError(store.Put(old, v))
+	}
+	atxdb.atxHeaderCache = NewAtxCache(DefaultAtxCacheSize)
+
+	r.NoError(atxdb.MigrateKeys())
+	header, err := atxdb.GetAtxHeader(atx.ID())
+	r.NoError(err)
+	r.Equal(atx.PubLayerID, header.PubLayerID)
+	_, err = atxdb.GetFullAtx(atx.ID())
+	r.NoError(err)
+	ticks, err := atxdb.GetAtxTicks(atx.ID())
+	r.NoError(err)
+	r.Equal(uint64(10), ticks)
+	posAtx, err := atxdb.GetPosAtxID()
+	r.NoError(err)
+	r.Equal(atx.ID(), posAtx)
+	ids, err := atxdb.EpochAtxIDs(atx.TargetEpoch(atxdb.LayersPerEpoch), nil, 10)
+	r.NoError(err)
+	r.Equal([]types.ATXID{atx.ID()}, ids)
+	ids, err = atxdb.GetNodeAtxIDs(atx.NodeID)
+	r.NoError(err)
+	r.Equal([]types.ATXID{atx.ID()}, ids)
+
+	_, err = store.Get([]byte("topAtxKey"))
+	r.Equal(database.ErrNotFound, err)
+	it := store.Find([]byte(atxHeaderPrefix + "["))
+	r.False(it.Next())
+	it.Release()
+
+	// migrating again is a no-op
+	r.NoError(atxdb.MigrateKeys())
+}
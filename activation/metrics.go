@@ -0,0 +1,130 @@
+package activation
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	prmkit "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+const (
+	namespace = "spacemesh"
+	subsystem = "activation"
+)
+
+func newCounter(name, help string, labels []string) metrics.Counter {
+	return prmkit.NewCounterFrom(prometheus.CounterOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help}, labels)
+}
+
+func newHistogram(name, help string, labels []string, buckets []float64) metrics.Histogram {
+	return prmkit.NewHistogramFrom(prometheus.HistogramOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, Buckets: buckets}, labels)
+}
+
+var (
+	activeSetChecks     = newCounter("active_set_checks", "Number of atxs whose declared active set size was checked, by publication epoch", []string{"epoch"})
+	activeSetMismatches = newCounter("active_set_mismatches", "Number of atxs whose declared active set size differs from the computed one, by publication epoch", []string{"epoch"})
+
+	atxValidationDuration = newHistogram("atx_validation_duration_seconds", "Duration of the steps of atx syntactic validation, by step", []string{"step"}, prometheus.ExponentialBuckets(0.001, 4, 10))
+	atxValidationFailures = newCounter("atx_validation_failures", "Number of atxs that failed syntactic validation, by reason", []string{"reason"})
+)
+
+// The steps of atx syntactic validation whose duration is measured.
+const (
+	prevAtxStep   = "prev_atx"   // reading the previous atx
+	posAtxStep    = "pos_atx"    // reading the positioning atx
+	activeSetStep = "active_set" // counting the active set
+	nipstStep     = "nipst"      // verifying the NIPST
+)
+
+func observeAtxValidation(step string, start time.Time) {
+	atxValidationDuration.With("step", step).Observe(time.Since(start).Seconds())
+}
+
+// The reasons atxs fail syntactic validation.
+const (
+	failedSignature  = "signature"
+	failedCoinbase   = "coinbase"
+	failedPrevAtx    = "prev_atx"
+	failedCommitment = "commitment"
+	failedPosAtx     = "pos_atx"
+	failedTicks      = "ticks"
+	failedActiveSet  = "active_set"
+	failedNipst      = "nipst"
+	failedSpaceUnits = "space_units"
+	failedOther      = "other"
+)
+
+// atxValidationError is an error of atx syntactic validation with the reason it failed.
+type atxValidationError struct {
+	reason string
+	err    error
+}
+
+func (e *atxValidationError) Error() string {
+	return e.err.Error()
+}
+
+func invalidAtx(reason string, err error) error {
+	return &atxValidationError{reason: reason, err: err}
+}
+
+// atxValidationFailure returns the reason of an error of atx syntactic validation.
+func atxValidationFailure(err error) string {
+	if e, ok := err.(*atxValidationError); ok {
+		return e.reason
+	}
+	return failedOther
+}
+
+// ActiveSetDivergence is the number of atxs of an epoch whose declared active set size was checked, and of the ones
+// whose declared size differed from the active set size the node computed.
+type ActiveSetDivergence struct {
+	Checked    uint64
+	Mismatched uint64
+}
+
+// activeSetDivergence counts the active set checks of atxs by publication epoch. Many mismatches in an epoch mean
+// that nodes disagree on the views or active sets, rather than a few invalid atxs.
+type activeSetDivergence struct {
+	mu     sync.Mutex
+	epochs map[types.EpochID]*ActiveSetDivergence
+}
+
+func newActiveSetDivergence() *activeSetDivergence {
+	return &activeSetDivergence{epochs: make(map[types.EpochID]*ActiveSetDivergence)}
+}
+
+func (d *activeSetDivergence) record(epoch types.EpochID, declared, computed uint32) {
+	label := strconv.FormatUint(uint64(epoch), 10)
+	activeSetChecks.With("epoch", label).Add(1)
+	if declared != computed {
+		activeSetMismatches.With("epoch", label).Add(1)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	counts, found := d.epochs[epoch]
+	if !found {
+		counts = &ActiveSetDivergence{}
+		d.epochs[epoch] = counts
+	}
+	counts.Checked++
+	if declared != computed {
+		counts.Mismatched++
+	}
+}
+
+// ActiveSetDivergence returns the number of atxs published in epoch whose declared active set size the node checked
+// since it started, and how many of them differed from the computed size.
+func (db *DB) ActiveSetDivergence(epoch types.EpochID) ActiveSetDivergence {
+	db.divergence.mu.Lock()
+	defer db.divergence.mu.Unlock()
+	if counts, found := db.divergence.epochs[epoch]; found {
+		return *counts
+	}
+	return ActiveSetDivergence{}
+}
@@ -0,0 +1,95 @@
+package activation
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
+)
+
+// NextAtx is the atx the builder would publish next, as built by a dry run.
+type NextAtx struct {
+	Atx           *types.ActivationTx
+	ChallengeHash types.Hash32
+	Size          int // the size of the published atx, if the NIPST proof is the size of the PoST commitment
+	TargetEpoch   types.EpochID
+	Deadline      time.Time // the NIPST must be ready and the atx published before the publication epoch ends
+	InFlight      bool      // the atx is built on the challenge in flight, and not on a new one
+}
+
+// NextAtx builds the atx that the builder would publish next, without waiting for its NIPST, signing or publishing it,
+// so that operators can check that their smeshing setup builds a valid atx before the publication deadline. The atx
+// is built on the challenge in flight, or on the latest atxs in the database if there's none, and its active set is
+// counted from the current view: it may still change until publication. Its NIPST holds the PoST commitment in place
+// of the proof that's generated when the PoET proof arrives.
+func (b *Builder) NextAtx() (*NextAtx, error) {
+	if atomic.LoadInt32(&b.initStatus) != InitDone {
+		return nil, errors.New("PoST is not initialized")
+	}
+	challenge, err := b.readChallenge()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nipst challenge: %v", err)
+	}
+	inFlight := challenge != nil && challenge.PubLayerID.GetEpoch(b.layersPerEpoch)+1 >= b.currentEpoch()
+	if !inFlight {
+		if challenge, err = b.newNipstChallenge(); err != nil {
+			return nil, err
+		}
+	}
+	hash, err := challenge.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("getting challenge hash failed: %v", err)
+	}
+
+	pubEpoch := challenge.PubLayerID.GetEpoch(b.layersPerEpoch)
+	viewLayer := pubEpoch.FirstLayer(b.layersPerEpoch)
+	var view []types.BlockID
+	if viewLayer > 0 && !b.upgrades.Active(upgrade.FirstSeenActiveSet, pubEpoch) {
+		if view, err = b.mesh.GetOrphanBlocksBefore(viewLayer); err != nil {
+			return nil, fmt.Errorf("failed to get current view for layer %v: %v", viewLayer, err)
+		}
+	}
+	var activeSetSize uint32
+	if pubEpoch > 0 {
+		ctx, cancel := util.StopContext(b.stop)
+		activeSetSize, err = b.db.CalcActiveSet(ctx, view, pubEpoch)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate activeset: %v", err)
+		}
+	}
+	if activeSetSize == 0 && !(pubEpoch + 1).IsGenesis() {
+		return nil, fmt.Errorf("empty active set size found! epochId: %v, len(view): %d", pubEpoch, len(view))
+	}
+
+	var commitment *types.PostProof
+	if challenge.PrevATXID == *types.EmptyATXID {
+		commitment = b.commitment
+	}
+	cfg := b.postProver.Cfg()
+	nipst := &types.NIPST{Space: cfg.SpacePerUnit, NipstChallenge: hash, PostProof: b.commitment}
+	atx := types.NewActivationTx(*challenge, b.CoinbaseAccount(), activeSetSize, view, nipst, commitment)
+	atx.SpaceUnits = spaceUnits(nipst.Space, cfg.SpacePerUnit)
+	atx.CalcAndSetID()
+
+	// the size with a signature, the atx itself isn't signed
+	signed := *atx
+	signed.Sig = make([]byte, ed25519.SignatureSize)
+	buf, err := types.InterfaceToBytes(&signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize ATX: %v", err)
+	}
+	return &NextAtx{
+		Atx:           atx,
+		ChallengeHash: *hash,
+		Size:          len(buf),
+		TargetEpoch:   atx.TargetEpoch(b.layersPerEpoch),
+		Deadline:      b.layerClock.LayerToTime((pubEpoch + 1).FirstLayer(b.layersPerEpoch)),
+		InFlight:      inFlight,
+	}, nil
+}
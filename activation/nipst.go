@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/post/config"
 	"github.com/spacemeshos/post/shared"
@@ -54,6 +55,29 @@ type PoetProvingServiceClient interface {
 
 	// PoetServiceID returns the public key of the PoET proving service.
 	PoetServiceID() ([]byte, error)
+
+	// RoundTimes returns the times of the proving service's open round.
+	RoundTimes() (*PoetRoundTimes, error)
+}
+
+// PoetRoundTimes are the times of a PoET proving service's open round, as reported by the service. Services that
+// don't report them leave them zero.
+type PoetRoundTimes struct {
+	OpenRoundID string
+
+	// OpenRoundEnd is when the open round stops accepting challenges and starts executing.
+	OpenRoundEnd time.Time
+
+	// ExecutionDuration is how long a round executes before its proof is published.
+	ExecutionDuration time.Duration
+}
+
+// ProofTime returns when the proof of the open round is expected to be published, or the zero time if it's unknown.
+func (t *PoetRoundTimes) ProofTime() time.Time {
+	if t.OpenRoundEnd.IsZero() || t.ExecutionDuration == 0 {
+		return time.Time{}
+	}
+	return t.OpenRoundEnd.Add(t.ExecutionDuration)
 }
 
 type builderState struct {
@@ -64,6 +88,14 @@ type builderState struct {
 	// PoetRound is the round of the PoET proving service in which the PoET challenge was included in.
 	PoetRound *types.PoetRound
 
+	// SubmittingRound is the id of the open round of the PoET proving service, persisted before the challenge is
+	// submitted to it, so that a builder that stops before the service responds knows which round may include it.
+	SubmittingRound string
+
+	// RoundUnconfirmed is set when PoetRound was resumed from SubmittingRound rather than reported by the service, so
+	// the challenge may not be a member of it.
+	RoundUnconfirmed bool
+
 	// PoetServiceID returns the public key of the PoET proving service.
 	PoetServiceID []byte
 
@@ -107,6 +139,9 @@ func (nb *NIPSTBuilder) persist() {
 	}
 }
 
+// the default margin kept before a PoET round closes and before the ATX publication deadline
+const defaultPoetRoundMargin = time.Minute
+
 // NIPSTBuilder holds the required state and dependencies to create Non-Interactive Proofs of Space-Time (NIPST).
 type NIPSTBuilder struct {
 	minerID    []byte
@@ -117,6 +152,8 @@ type NIPSTBuilder struct {
 	state      *builderState
 	store      bytesStore
 	log        log.Log
+	poetMargin time.Duration
+	proofTime  time.Time // when the proof of the round the challenge was submitted to is expected, if known
 }
 
 type poetDbAPI interface {
@@ -143,13 +180,21 @@ func NewNIPSTBuilder(
 		state:      &builderState{Nipst: &types.NIPST{}},
 		store:      store,
 		log:        log,
+		poetMargin: defaultPoetRoundMargin,
 	}
 }
 
-// BuildNIPST uses the given challenge to build a NIPST. "atxExpired" and "stop" are channels for early termination of
-// the building process. The process can take considerable time, because it includes waiting for the poet service to
-// publish a proof - a process that takes about an epoch.
-func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, atxExpired, stop chan struct{}) (*types.NIPST, error) {
+// SetPoetRoundMargin sets the margin kept before a PoET round closes when submitting a challenge to it, and before
+// the ATX publication deadline when waiting for the PoET proof.
+func (nb *NIPSTBuilder) SetPoetRoundMargin(margin time.Duration) {
+	nb.poetMargin = margin
+}
+
+// BuildNIPST uses the given challenge to build a NIPST. "deadline" is the time the ATX must be published by, the zero
+// time if unknown, and is only used to warn when the NIPST risks missing it. "atxExpired" and "stop" are channels for
+// early termination of the building process. The process can take considerable time, because it includes waiting for
+// the poet service to publish a proof - a process that takes about an epoch.
+func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, deadline time.Time, atxExpired, stop chan struct{}) (*types.NIPST, error) {
 	nb.load(*challenge)
 
 	if initialized, _, err := nb.postProver.IsInitialized(); !initialized || err != nil {
@@ -162,6 +207,9 @@ func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, atxExpired, stop cha
 	nipst.Space = cfg.SpacePerUnit
 
 	// Phase 0: Submit challenge to PoET service.
+	if nb.state.PoetRound == nil && nb.state.SubmittingRound != "" {
+		nb.resumeSubmission()
+	}
 	if nb.state.PoetRound == nil {
 		poetServiceID, err := nb.poetProver.PoetServiceID()
 		if err != nil {
@@ -169,8 +217,16 @@ func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, atxExpired, stop cha
 		}
 		nb.state.PoetServiceID = poetServiceID
 
+		roundID, err := nb.awaitSubmissionWindow(deadline, atxExpired, stop)
+		if err != nil {
+			return nil, err
+		}
+
 		poetChallenge := challenge
 		nb.state.Challenge = *challenge
+		nipst.NipstChallenge = poetChallenge
+		nb.state.SubmittingRound = roundID
+		nb.persist()
 
 		nb.log.Debug("submitting challenge to PoET proving service (PoET id: %x, challenge: %x)",
 			nb.state.PoetServiceID, poetChallenge)
@@ -183,7 +239,6 @@ func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, atxExpired, stop cha
 		nb.log.Info("challenge submitted to PoET proving service (PoET id: %x, round id: %v, challenge: %x)",
 			nb.state.PoetServiceID, round.ID, poetChallenge)
 
-		nipst.NipstChallenge = poetChallenge
 		nb.state.PoetRound = round
 		nb.persist()
 	}
@@ -191,13 +246,22 @@ func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, atxExpired, stop cha
 	// Phase 1: receive proofs from PoET service
 	if nb.state.PoetProofRef == nil {
 		var poetProofRef []byte
-		select {
-		case poetProofRef = <-nb.poetDB.SubscribeToProofRef(nb.state.PoetServiceID, nb.state.PoetRound.ID):
-		case <-atxExpired:
-			nb.poetDB.UnsubscribeFromProofRef(nb.state.PoetServiceID, nb.state.PoetRound.ID)
-			return nil, fmt.Errorf("atx expired while waiting for poet proof, target epoch ended")
-		case <-stop:
-			return nil, &StopRequestedError{}
+		proofs := nb.poetDB.SubscribeToProofRef(nb.state.PoetServiceID, nb.state.PoetRound.ID)
+		alarm := nb.proofAlarm(deadline)
+	wait:
+		for {
+			select {
+			case poetProofRef = <-proofs:
+				break wait
+			case <-alarm:
+				alarm = nil
+				nb.warnDeadline("proof", nb.state.PoetRound.ID, nb.proofTime, deadline)
+			case <-atxExpired:
+				nb.poetDB.UnsubscribeFromProofRef(nb.state.PoetServiceID, nb.state.PoetRound.ID)
+				return nil, fmt.Errorf("atx expired while waiting for poet proof, target epoch ended")
+			case <-stop:
+				return nil, &StopRequestedError{}
+			}
 		}
 
 		membership, err := nb.poetDB.GetMembershipMap(poetProofRef)
@@ -206,8 +270,14 @@ func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, atxExpired, stop cha
 			return nil, fmt.Errorf("failed to fetch membership for PoET proof") // inconsistent state
 		}
 		if !membership[*nipst.NipstChallenge] {
+			poetID, roundID := nb.state.PoetServiceID, nb.state.PoetRound.ID
+			if nb.state.RoundUnconfirmed {
+				// the challenge didn't reach the round it was being submitted to, submit it to another round
+				nb.state = &builderState{Challenge: *challenge, Nipst: &types.NIPST{}}
+				nb.persist()
+			}
 			return nil, fmt.Errorf("not a member of this round (poetId: %x, roundId: %s, challenge: %x, num of members: %d)",
-				nb.state.PoetServiceID, nb.state.PoetRound.ID, *nipst.NipstChallenge, len(membership)) // TODO(noamnelke): handle this case!
+				poetID, roundID, *nipst.NipstChallenge, len(membership)) // TODO(noamnelke): handle this case!
 		}
 		nb.state.PoetProofRef = poetProofRef
 		nb.persist()
@@ -236,10 +306,93 @@ func (nb *NIPSTBuilder) BuildNIPST(challenge *types.Hash32, atxExpired, stop cha
 	nb.state = &builderState{
 		Nipst: &types.NIPST{},
 	}
+	nb.proofTime = time.Time{}
 	nb.persist()
 	return nipst, nil
 }
 
+// resumeSubmission resumes the submission of a challenge to a PoET round that was interrupted before the service
+// responded, e.g. by a restart. While the round is open the challenge is submitted again, since it may not have been
+// received. Once the round closed, the builder waits for the round's proof, and submits the challenge to another round
+// if it isn't a member.
+func (nb *NIPSTBuilder) resumeSubmission() {
+	times, err := nb.poetProver.RoundTimes()
+	if err == nil && times.OpenRoundID == nb.state.SubmittingRound {
+		nb.log.With().Info("resubmitting challenge to the PoET round it was being submitted to",
+			log.String("round_id", nb.state.SubmittingRound))
+		return
+	}
+	nb.log.With().Info("resuming challenge submitted to a closed PoET round",
+		log.String("round_id", nb.state.SubmittingRound))
+	nb.state.PoetRound = &types.PoetRound{ID: nb.state.SubmittingRound}
+	nb.state.RoundUnconfirmed = true
+	nb.persist()
+}
+
+// awaitSubmissionWindow waits for the next round of the PoET service when its open round closes within the margin,
+// so that the challenge isn't submitted to a round that may close before it's included. It warns when the proof of the
+// round the challenge will be submitted to is expected too close to the deadline. It returns the id of the round the
+// challenge will be submitted to, or an empty id if the service doesn't report it.
+func (nb *NIPSTBuilder) awaitSubmissionWindow(deadline time.Time, atxExpired, stop chan struct{}) (string, error) {
+	times, err := nb.poetProver.RoundTimes()
+	if err != nil {
+		nb.log.With().Warning("cannot get PoET round times, submitting without scheduling", log.Err(err))
+		return "", nil
+	}
+	if !times.OpenRoundEnd.IsZero() && time.Until(times.OpenRoundEnd) < nb.poetMargin {
+		nb.log.With().Info("PoET round closes within the submission margin, waiting for the next round",
+			log.String("round_id", times.OpenRoundID), log.String("round_end", times.OpenRoundEnd.String()))
+		select {
+		case <-time.After(time.Until(times.OpenRoundEnd)):
+		case <-atxExpired:
+			return "", fmt.Errorf("atx expired while waiting for the next poet round, target epoch ended")
+		case <-stop:
+			return "", &StopRequestedError{}
+		}
+		if times, err = nb.poetProver.RoundTimes(); err != nil {
+			nb.log.With().Warning("cannot get PoET round times, submitting without scheduling", log.Err(err))
+			return "", nil
+		}
+	}
+	nb.proofTime = times.ProofTime()
+	if !nb.proofTime.IsZero() && !deadline.IsZero() && nb.proofTime.Add(nb.poetMargin).After(deadline) {
+		nb.warnDeadline("submission", times.OpenRoundID, nb.proofTime, deadline)
+	}
+	return times.OpenRoundID, nil
+}
+
+// proofAlarm returns a channel that fires when the PoET proof is late: a margin after the time it's expected at, or a
+// margin before the deadline if that's earlier. It never fires when neither time is known.
+func (nb *NIPSTBuilder) proofAlarm(deadline time.Time) <-chan time.Time {
+	var alarm time.Time
+	if !nb.proofTime.IsZero() {
+		alarm = nb.proofTime.Add(nb.poetMargin)
+	}
+	if !deadline.IsZero() && (alarm.IsZero() || deadline.Add(-nb.poetMargin).Before(alarm)) {
+		alarm = deadline.Add(-nb.poetMargin)
+	}
+	if alarm.IsZero() {
+		return nil
+	}
+	return time.After(time.Until(alarm))
+}
+
+func (nb *NIPSTBuilder) warnDeadline(stage, roundID string, expected, deadline time.Time) {
+	nb.log.With().Warning("PoET proof risks missing the ATX publication deadline",
+		log.String("stage", stage),
+		log.String("round_id", roundID),
+		log.String("expected", expected.String()),
+		log.String("deadline", deadline.String()))
+	event := events.PoetDeadline{Stage: stage, RoundID: roundID}
+	if !expected.IsZero() {
+		event.Expected = expected.Unix()
+	}
+	if !deadline.IsZero() {
+		event.Deadline = deadline.Unix()
+	}
+	events.Publish(event)
+}
+
 // NewNIPSTWithChallenge is a convenience method FOR TESTS ONLY. TODO: move this out of production code.
 func NewNIPSTWithChallenge(challenge *types.Hash32, poetRef []byte) *types.NIPST {
 	return &types.NIPST{
@@ -1,6 +1,7 @@
 package activation
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/database"
@@ -10,6 +11,7 @@ import (
 	"github.com/spacemeshos/post/shared"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 var minerID = []byte("id")
@@ -74,7 +76,10 @@ func (p *postProverClientMock) Cfg() *config.Config {
 }
 
 type poetProvingServiceClientMock struct {
-	called int
+	called      int
+	times       PoetRoundTimes
+	timesCalled int
+	submitErr   error
 }
 
 // A compile time check to ensure that poetProvingServiceClientMock fully implements PoetProvingServiceClient.
@@ -82,7 +87,10 @@ var _ PoetProvingServiceClient = (*poetProvingServiceClientMock)(nil)
 
 func (p *poetProvingServiceClientMock) Submit(challenge types.Hash32) (*types.PoetRound, error) {
 	p.called++
-	return &types.PoetRound{}, nil
+	if p.submitErr != nil {
+		return nil, p.submitErr
+	}
+	return &types.PoetRound{ID: p.times.OpenRoundID}, nil
 }
 
 func (p *poetProvingServiceClientMock) PoetServiceID() ([]byte, error) {
@@ -90,6 +98,12 @@ func (p *poetProvingServiceClientMock) PoetServiceID() ([]byte, error) {
 	return []byte{}, nil
 }
 
+func (p *poetProvingServiceClientMock) RoundTimes() (*PoetRoundTimes, error) {
+	p.timesCalled++
+	times := p.times
+	return &times, nil
+}
+
 type poetDbMock struct {
 	errOn        bool
 	unsubscribed bool
@@ -117,6 +131,10 @@ func (p *poetDbMock) GetMembershipMap(poetRoot []byte) (map[types.Hash32]bool, e
 	return map[types.Hash32]bool{hash: true, hash2: true}, nil
 }
 
+func (*poetDbMock) GetLeafCount(proofRef []byte) (uint64, error) {
+	return 1, nil
+}
+
 func TestNIPSTBuilderWithMocks(t *testing.T) {
 	assert := require.New(t)
 
@@ -128,9 +146,120 @@ func TestNIPSTBuilderWithMocks(t *testing.T) {
 	nb := NewNIPSTBuilder(minerID, postProver, poetProver,
 		poetDb, database.NewMemDatabase(), log.NewDefault(string(minerID)))
 	hash := types.BytesToHash([]byte("anton"))
-	npst, err := nb.BuildNIPST(&hash, nil, nil)
+	npst, err := nb.BuildNIPST(&hash, time.Time{}, nil, nil)
+	assert.NoError(err)
+	assert.NotNil(npst)
+}
+
+func TestNIPSTBuilder_PoetRoundSchedule(t *testing.T) {
+	assert := require.New(t)
+
+	// the open round closes within the margin, so the builder waits for the next round before submitting
+	roundEnd := time.Now().Add(100 * time.Millisecond)
+	poetProver := &poetProvingServiceClientMock{times: PoetRoundTimes{OpenRoundID: "1", OpenRoundEnd: roundEnd}}
+	nb := NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver,
+		&poetDbMock{}, database.NewMemDatabase(), log.NewDefault(string(minerID)))
+	nb.SetPoetRoundMargin(time.Second)
+	hash := types.BytesToHash([]byte("anton"))
+	npst, err := nb.BuildNIPST(&hash, time.Time{}, nil, nil)
+	assert.NoError(err)
+	assert.NotNil(npst)
+	assert.False(time.Now().Before(roundEnd))
+	assert.Equal(2, poetProver.timesCalled)
+
+	// a stop request is handled while waiting for the next round
+	poetProver = &poetProvingServiceClientMock{times: PoetRoundTimes{OpenRoundID: "1", OpenRoundEnd: time.Now().Add(time.Hour)}}
+	nb = NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver,
+		&poetDbMock{}, database.NewMemDatabase(), log.NewDefault(string(minerID)))
+	nb.SetPoetRoundMargin(2 * time.Hour)
+	_, err = nb.BuildNIPST(&hash, time.Time{}, nil, closedChan)
+	assert.IsType(&StopRequestedError{}, err)
+	assert.Equal(1, poetProver.called) // the challenge wasn't submitted
+}
+
+func TestNIPSTBuilder_ResumeSubmission(t *testing.T) {
+	assert := require.New(t)
+	db := database.NewMemDatabase()
+	hash := types.BytesToHash([]byte("anton"))
+
+	// the round the challenge is being submitted to is persisted before submitting
+	poetProver := &poetProvingServiceClientMock{times: PoetRoundTimes{OpenRoundID: "1"}, submitErr: errors.New("stopped")}
+	nb := NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver, &poetDbMock{}, db, log.NewDefault(string(minerID)))
+	_, err := nb.BuildNIPST(&hash, time.Time{}, nil, nil)
+	assert.Error(err)
+	nb = NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver, &poetDbMock{}, db, log.NewDefault(string(minerID)))
+	nb.load(hash)
+	assert.Equal("1", nb.state.SubmittingRound)
+	assert.Nil(nb.state.PoetRound)
+
+	// while the round is open, the challenge is submitted again
+	poetProver = &poetProvingServiceClientMock{times: PoetRoundTimes{OpenRoundID: "1"}}
+	nb = NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver, &poetDbMock{}, db, log.NewDefault(string(minerID)))
+	npst, err := nb.BuildNIPST(&hash, time.Time{}, nil, nil)
+	assert.NoError(err)
+	assert.NotNil(npst)
+	assert.Equal(2, poetProver.called) // the service id and the submission
+
+	// once the round closed, the builder waits for its proof without submitting again
+	nb = NewNIPSTBuilder(minerID, &postProverClientMock{}, &poetProvingServiceClientMock{submitErr: errors.New("stopped")},
+		&poetDbMock{}, db, log.NewDefault(string(minerID)))
+	nb.state = &builderState{Challenge: hash, Nipst: &types.NIPST{NipstChallenge: &hash}, SubmittingRound: "1"}
+	nb.persist()
+	poetProver = &poetProvingServiceClientMock{times: PoetRoundTimes{OpenRoundID: "2"}}
+	nb = NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver, &poetDbMock{}, db, log.NewDefault(string(minerID)))
+	npst, err = nb.BuildNIPST(&hash, time.Time{}, nil, nil)
+	assert.NoError(err)
+	assert.NotNil(npst)
+	assert.Equal(&hash, npst.NipstChallenge)
+	assert.Equal(0, poetProver.called)
+
+	// and submits it to another round if it isn't a member of the closed round
+	nb.state = &builderState{Challenge: hash, Nipst: &types.NIPST{NipstChallenge: &hash}, SubmittingRound: "1"}
+	nb.persist()
+	nb = NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver, &poetDbMock{errOn: true}, db, log.NewDefault(string(minerID)))
+	_, err = nb.BuildNIPST(&hash, time.Time{}, nil, nil)
+	assert.Error(err)
+	assert.Equal(0, poetProver.called)
+	nb = NewNIPSTBuilder(minerID, &postProverClientMock{}, poetProver, &poetDbMock{}, db, log.NewDefault(string(minerID)))
+	npst, err = nb.BuildNIPST(&hash, time.Time{}, nil, nil)
 	assert.NoError(err)
 	assert.NotNil(npst)
+	assert.Equal(2, poetProver.called)
+}
+
+func TestNIPSTBuilder_ProofAlarm(t *testing.T) {
+	assert := require.New(t)
+
+	nb := NewNIPSTBuilder(minerID, &postProverClientMock{}, &poetProvingServiceClientMock{},
+		&poetDbMock{}, database.NewMemDatabase(), log.NewDefault(string(minerID)))
+	nb.SetPoetRoundMargin(time.Second)
+
+	// neither the proof time nor the deadline are known
+	assert.Nil(nb.proofAlarm(time.Time{}))
+
+	// the alarm fires a margin before the deadline, when that's before the proof is expected
+	nb.proofTime = time.Now().Add(time.Hour)
+	select {
+	case <-nb.proofAlarm(time.Now().Add(time.Second + 10*time.Millisecond)):
+	case <-time.After(time.Second):
+		assert.Fail("alarm didn't fire before the deadline")
+	}
+
+	// or a margin after the proof is expected
+	nb.proofTime = time.Now().Add(-time.Second)
+	select {
+	case <-nb.proofAlarm(time.Time{}):
+	case <-time.After(time.Second):
+		assert.Fail("alarm didn't fire after the proof was expected")
+	}
+}
+
+func TestPoetRoundTimes_ProofTime(t *testing.T) {
+	assert := require.New(t)
+	end := time.Now()
+	assert.True((&PoetRoundTimes{OpenRoundEnd: end}).ProofTime().IsZero())
+	assert.True((&PoetRoundTimes{ExecutionDuration: time.Minute}).ProofTime().IsZero())
+	assert.Equal(end.Add(time.Minute), (&PoetRoundTimes{OpenRoundEnd: end, ExecutionDuration: time.Minute}).ProofTime())
 }
 
 func TestInitializePost(t *testing.T) {
@@ -157,7 +286,7 @@ func TestInitializePost(t *testing.T) {
 	}()
 
 	hash := types.BytesToHash([]byte("anton"))
-	npst, err := nb.BuildNIPST(&hash, nil, nil)
+	npst, err := nb.BuildNIPST(&hash, time.Time{}, nil, nil)
 	assert.NoError(err)
 	assert.NotNil(npst)
 }
@@ -203,7 +332,7 @@ func buildNIPST(r *require.Assertions, postCfg config.Config, nipstChallenge typ
 	nb := NewNIPSTBuilder(minerID, postProver, poetProver,
 		poetDb, database.NewMemDatabase(), log.NewDefault(string(minerID)))
 
-	npst, err := nb.BuildNIPST(&nipstChallenge, nil, nil)
+	npst, err := nb.BuildNIPST(&nipstChallenge, time.Time{}, nil, nil)
 	r.NoError(err)
 	return npst
 }
@@ -233,7 +362,7 @@ func TestNewNIPSTBuilderNotInitialized(t *testing.T) {
 	nb := NewNIPSTBuilder(minerIDNotInitialized, postProver, poetProver,
 		poetDb, database.NewMemDatabase(), log.NewDefault(string(minerID)))
 
-	npst, err := nb.BuildNIPST(&nipstChallenge, nil, nil)
+	npst, err := nb.BuildNIPST(&nipstChallenge, time.Time{}, nil, nil)
 	r.EqualError(err, "PoST not initialized")
 	r.Nil(npst)
 
@@ -245,7 +374,7 @@ func TestNewNIPSTBuilderNotInitialized(t *testing.T) {
 	r.NoError(err)
 	r.NotNil(commitment)
 
-	npst, err = nb.BuildNIPST(&nipstChallenge, nil, nil)
+	npst, err = nb.BuildNIPST(&nipstChallenge, time.Time{}, nil, nil)
 	r.NoError(err)
 	r.NotNil(npst)
 
@@ -264,7 +393,7 @@ func TestNIPSTBuilder_BuildNIPST(t *testing.T) {
 	nb := NewNIPSTBuilder(minerID, postProver, poetProver,
 		poetDb, database.NewMemDatabase(), log.NewDefault(string(minerID)))
 	hash := types.BytesToHash([]byte("anton"))
-	npst, err := nb.BuildNIPST(&hash, nil, nil)
+	npst, err := nb.BuildNIPST(&hash, time.Time{}, nil, nil)
 	assert.NoError(err)
 	assert.NotNil(npst)
 	db := database.NewMemDatabase()
@@ -273,13 +402,13 @@ func TestNIPSTBuilder_BuildNIPST(t *testing.T) {
 	//fail after getting proof ref
 	nb = NewNIPSTBuilder(minerID, postProver, poetProver, poetDb, db, log.NewDefault(string(minerID)))
 	poetDb.errOn = true
-	npst, err = nb.BuildNIPST(&hash, nil, nil)
+	npst, err = nb.BuildNIPST(&hash, time.Time{}, nil, nil)
 	assert.Nil(npst)
 	assert.Error(err)
 
 	//check that proof ref is not called again
 	nb = NewNIPSTBuilder(minerID, postProver, poetProver, poetDb, db, log.NewDefault(string(minerID)))
-	npst, err = nb.BuildNIPST(&hash, nil, nil)
+	npst, err = nb.BuildNIPST(&hash, time.Time{}, nil, nil)
 	assert.Equal(4, poetProver.called)
 	assert.Nil(npst)
 	assert.Error(err)
@@ -289,7 +418,7 @@ func TestNIPSTBuilder_BuildNIPST(t *testing.T) {
 	poetDb.errOn = false
 	postProver.setError = true
 	//check that proof ref is not called again
-	npst, err = nb.BuildNIPST(&hash, nil, nil)
+	npst, err = nb.BuildNIPST(&hash, time.Time{}, nil, nil)
 	assert.Equal(4, poetProver.called)
 	assert.Nil(npst)
 	assert.Error(err)
@@ -299,7 +428,7 @@ func TestNIPSTBuilder_BuildNIPST(t *testing.T) {
 	poetDb.errOn = false
 	postProver.setError = false
 	//check that proof ref is not called again
-	npst, err = nb.BuildNIPST(&hash, nil, nil)
+	npst, err = nb.BuildNIPST(&hash, time.Time{}, nil, nil)
 	assert.Equal(4, poetProver.called)
 	assert.NotNil(npst)
 	assert.NoError(err)
@@ -307,7 +436,7 @@ func TestNIPSTBuilder_BuildNIPST(t *testing.T) {
 	assert.Equal(3, postProver.called)
 	//test state not loading if other challenge provided
 	hash2 := types.BytesToHash([]byte("anton1"))
-	npst, err = nb.BuildNIPST(&hash2, nil, nil)
+	npst, err = nb.BuildNIPST(&hash2, time.Time{}, nil, nil)
 	assert.Equal(6, poetProver.called)
 	assert.Equal(4, postProver.called)
 
@@ -350,8 +479,8 @@ func TestValidator_Validate(t *testing.T) {
 	r.EqualError(err, "NIPST challenge is not equal to expected challenge")
 }
 
-func validateNIPST(npst *types.NIPST, postCfg config.Config, nipstChallenge types.Hash32, poetDb poetDbAPI, minerID []byte) error {
-	v := &Validator{&postCfg, poetDb}
+func validateNIPST(npst *types.NIPST, postCfg config.Config, nipstChallenge types.Hash32, poetDb poetValidatorDbAPI, minerID []byte) error {
+	v := NewValidator(&postCfg, poetDb, 1)
 	return v.Validate(*signing.NewPublicKey(minerID), npst, nipstChallenge)
 }
 
@@ -367,7 +496,7 @@ func TestNIPSTBuilder_TimeoutUnsubscribe(t *testing.T) {
 		poetDb, database.NewMemDatabase(), log.NewDefault(string(minerID)))
 	hash := types.BytesToHash([]byte("anton"))
 	poetDb.unsubscribed = false
-	npst, err := nb.BuildNIPST(&hash, closedChan, nil) // closedChan will timeout immediately
+	npst, err := nb.BuildNIPST(&hash, time.Time{}, closedChan, nil) // closedChan will timeout immediately
 	r.EqualError(err, "atx expired while waiting for poet proof, target epoch ended")
 	r.Nil(npst)
 	r.True(poetDb.unsubscribed)
@@ -384,7 +513,7 @@ func TestNIPSTBuilder_Close(t *testing.T) {
 	nb := NewNIPSTBuilder(minerID, postProver, poetProver,
 		poetDb, database.NewMemDatabase(), log.NewDefault(string(minerID)))
 	hash := types.BytesToHash([]byte("anton"))
-	npst, err := nb.BuildNIPST(&hash, nil, closedChan) // closedChan will timeout immediately
+	npst, err := nb.BuildNIPST(&hash, time.Time{}, nil, closedChan) // closedChan will timeout immediately
 	r.IsType(&StopRequestedError{}, err)
 	r.Nil(npst)
 }
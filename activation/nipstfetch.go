@@ -0,0 +1,165 @@
+package activation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/config"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/p2p/server"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+)
+
+const nipstProtocol = "/atxnipst/1.0/"
+
+const nipstMsg server.MessageType = 1
+
+// maxNipstFetchPeers is the number of peers a NIPST is requested from, after the peer that sent the atx header.
+const maxNipstFetchPeers = 3
+
+var errNipstNotFound = errors.New("NIPST not found")
+
+// NipstSource returns the NIPSTs of the atxs a node has, to serve them to peers that received the atxs without their
+// NIPST.
+type NipstSource interface {
+	GetNipst(id types.ATXID) (*types.NIPST, error)
+}
+
+// NipstSources is a NipstSource that returns the NIPST from the first source that has it.
+type NipstSources []NipstSource
+
+// GetNipst returns the NIPST of the atx with id from the first source that has it.
+func (s NipstSources) GetNipst(id types.ATXID) (*types.NIPST, error) {
+	for _, source := range s {
+		if nipst, err := source.GetNipst(id); err == nil && nipst != nil {
+			return nipst, nil
+		}
+	}
+	return nil, errNipstNotFound
+}
+
+// GetNipst returns the NIPST of the stored atx with id.
+func (db *DB) GetNipst(id types.ATXID) (*types.NIPST, error) {
+	atx, err := db.GetFullAtx(id)
+	if err != nil {
+		return nil, err
+	}
+	if atx.Nipst == nil {
+		return nil, errNipstNotFound
+	}
+	return atx.Nipst, nil
+}
+
+// NipstFetcher serves the NIPSTs of the atxs the node has to peers, and fetches the NIPSTs of atxs that were gossiped
+// without them from peers.
+type NipstFetcher struct {
+	log.Log
+	*server.MessageServer
+	peers   *p2ppeers.Peers
+	timeout time.Duration
+}
+
+// NewNipstFetcher returns a NipstFetcher that serves the NIPSTs that source returns to the peers of srv, and gives up
+// on a request after timeout.
+func NewNipstFetcher(srv service.Service, source NipstSource, timeout time.Duration, logger log.Log) *NipstFetcher {
+	f := &NipstFetcher{
+		Log:           logger,
+		MessageServer: server.NewMsgServer(srv.(server.Service), nipstProtocol, timeout, make(chan service.DirectMessage, config.Values.BufferSize), logger),
+		peers:         p2ppeers.NewPeers(srv, logger.WithName("peers")),
+		timeout:       timeout,
+	}
+	f.RegisterBytesMsgHandler(nipstMsg, newNipstRequestHandler(source, logger))
+	return f
+}
+
+// Close stops serving NIPSTs.
+func (f *NipstFetcher) Close() {
+	f.MessageServer.Close()
+	f.peers.Close()
+}
+
+func newNipstRequestHandler(source NipstSource, logger log.Log) func(msg []byte) []byte {
+	return func(msg []byte) []byte {
+		var id types.ATXID
+		if err := types.BytesToInterface(msg, &id); err != nil {
+			logger.Error("could not unmarshal NIPST request: %v", err)
+			return nil
+		}
+		// a missing NIPST is answered with an empty response, so that the requester asks another peer
+		nipst, err := source.GetNipst(id)
+		if err != nil {
+			logger.With().Debug("NIPST not found", log.AtxID(id.ShortString()))
+			return nil
+		}
+		bts, err := types.InterfaceToBytes(nipst)
+		if err != nil {
+			logger.Error("could not marshal NIPST response: %v", err)
+			return nil
+		}
+		return bts
+	}
+}
+
+// Fetch fetches the NIPST of the atx with id whose hash is nipstHash from sender, the peer that sent the atx header,
+// or from other peers if sender doesn't serve it. It returns an error if no peer served a NIPST with the hash before
+// ctx is done.
+func (f *NipstFetcher) Fetch(ctx context.Context, sender p2pcrypto.PublicKey, id types.ATXID, nipstHash types.Hash32) (*types.NIPST, error) {
+	peers := []p2pcrypto.PublicKey{sender}
+	for _, peer := range f.peers.GetPeers() {
+		if len(peers) > maxNipstFetchPeers {
+			break
+		}
+		if peer.String() != sender.String() {
+			peers = append(peers, peer)
+		}
+	}
+	for _, peer := range peers {
+		nipst, err := f.request(ctx, peer, id)
+		if err == nil {
+			var hash types.Hash32
+			if hash, err = nipst.Hash(); err == nil && hash != nipstHash {
+				err = fmt.Errorf("NIPST hash %v doesn't match the atx's %v", hash.ShortString(), nipstHash.ShortString())
+			}
+		}
+		if err == nil {
+			return nipst, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		f.With().Debug("peer did not serve NIPST", log.AtxID(id.ShortString()), log.String("peer", peer.String()),
+			log.Err(err))
+	}
+	return nil, fmt.Errorf("no peer served the NIPST of atx %v", id.ShortString())
+}
+
+func (f *NipstFetcher) request(ctx context.Context, peer p2pcrypto.PublicKey, id types.ATXID) (*types.NIPST, error) {
+	payload, err := types.InterfaceToBytes(id)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []byte, 1)
+	if err := f.SendRequest(nipstMsg, payload, peer, func(msg []byte) { ch <- msg }); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-ch:
+		if len(msg) == 0 {
+			return nil, errNipstNotFound
+		}
+		var nipst types.NIPST
+		if err := types.BytesToInterface(msg, &nipst); err != nil {
+			return nil, fmt.Errorf("could not unmarshal NIPST: %v", err)
+		}
+		return &nipst, nil
+	case <-time.After(f.timeout):
+		return nil, errors.New("request timed out")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
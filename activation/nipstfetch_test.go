@@ -0,0 +1,50 @@
+package activation
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+type nipstMap map[types.ATXID]*types.NIPST
+
+func (m nipstMap) GetNipst(id types.ATXID) (*types.NIPST, error) {
+	if nipst, ok := m[id]; ok {
+		return nipst, nil
+	}
+	return nil, errNipstNotFound
+}
+
+func TestNipstRequestHandler(t *testing.T) {
+	r := require.New(t)
+	id1, id2 := types.ATXID(types.CalcHash32([]byte("1"))), types.ATXID(types.CalcHash32([]byte("2")))
+	nipst1 := NewNIPSTWithChallenge(&types.Hash32{1}, []byte{0xba, 0xbe})
+	nipst2 := NewNIPSTWithChallenge(&types.Hash32{2}, []byte{0xca, 0xfe})
+	handler := newNipstRequestHandler(NipstSources{nipstMap{id1: nipst1}, nipstMap{id1: nipst2, id2: nipst2}},
+		log.NewDefault(t.Name()))
+
+	req, err := types.InterfaceToBytes(id1)
+	r.NoError(err)
+	var got types.NIPST
+	r.NoError(types.BytesToInterface(handler(req), &got))
+	want, err := nipst1.Hash()
+	r.NoError(err)
+	hash, err := got.Hash()
+	r.NoError(err)
+	r.Equal(want, hash)
+
+	req, err = types.InterfaceToBytes(id2)
+	r.NoError(err)
+	r.NoError(types.BytesToInterface(handler(req), &got))
+	want, err = nipst2.Hash()
+	r.NoError(err)
+	hash, err = got.Hash()
+	r.NoError(err)
+	r.Equal(want, hash)
+
+	req, err = types.InterfaceToBytes(types.ATXID(types.CalcHash32([]byte("3"))))
+	r.NoError(err)
+	r.Empty(handler(req))
+}
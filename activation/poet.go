@@ -98,6 +98,27 @@ func (c *HTTPPoetClient) PoetServiceID() ([]byte, error) {
 	return resBody.ServicePubKey, nil
 }
 
+// RoundTimes returns the times of the PoET service's open round.
+func (c *HTTPPoetClient) RoundTimes() (*PoetRoundTimes, error) {
+	resBody := &GetInfoResponse{}
+	if err := c.req("GET", "/info", nil, resBody); err != nil {
+		return nil, err
+	}
+
+	times := &PoetRoundTimes{OpenRoundID: resBody.OpenRoundID}
+	if resBody.OpenRoundEnd != 0 {
+		times.OpenRoundEnd = time.Unix(resBody.OpenRoundEnd, 0)
+	}
+	if resBody.ExecutionDuration != "" {
+		d, err := time.ParseDuration(resBody.ExecutionDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid round execution duration %q: %v", resBody.ExecutionDuration, err)
+		}
+		times.ExecutionDuration = d
+	}
+	return times, nil
+}
+
 func (c *HTTPPoetClient) req(method string, endURL string, reqBody interface{}, resBody interface{}) error {
 	jsonReqBody, err := json.Marshal(reqBody)
 	if err != nil {
@@ -159,4 +180,6 @@ type GetInfoResponse struct {
 	OpenRoundID        string
 	ExecutingRoundsIDs []string
 	ServicePubKey      []byte
+	OpenRoundEnd       int64  // unix time the open round closes, 0 if not reported
+	ExecutionDuration  string // e.g. "10m", empty if not reported
 }
@@ -9,11 +9,10 @@ import (
 	"github.com/spacemeshos/poet/hash"
 	"github.com/spacemeshos/poet/shared"
 	"github.com/spacemeshos/poet/verifier"
-	"github.com/spacemeshos/sha256-simd"
 	"sync"
 )
 
-type poetProofKey [sha256.Size]byte
+type poetProofKey [types.Hash32Length]byte
 
 // PoetDb is a database for PoET proofs.
 type PoetDb struct {
@@ -150,6 +149,23 @@ func (db *PoetDb) GetProofMessage(proofRef []byte) ([]byte, error) {
 
 // GetMembershipMap returns the map of memberships in the requested PoET proof.
 func (db *PoetDb) GetMembershipMap(proofRef []byte) (map[types.Hash32]bool, error) {
+	proofMessage, err := db.getProof(proofRef)
+	if err != nil {
+		return nil, err
+	}
+	return membershipSliceToMap(proofMessage.Members), nil
+}
+
+// GetLeafCount returns the number of leaves in the requested PoET proof, i.e. the duration that the proof attests to.
+func (db *PoetDb) GetLeafCount(proofRef []byte) (uint64, error) {
+	proofMessage, err := db.getProof(proofRef)
+	if err != nil {
+		return 0, err
+	}
+	return proofMessage.LeafCount, nil
+}
+
+func (db *PoetDb) getProof(proofRef []byte) (*types.PoetProofMessage, error) {
 	proofMessageBytes, err := db.GetProofMessage(proofRef)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch poet proof for ref %x: %v", proofRef[:3], err)
@@ -158,12 +174,11 @@ func (db *PoetDb) GetMembershipMap(proofRef []byte) (map[types.Hash32]bool, erro
 	if err := types.BytesToInterface(proofMessageBytes, &proofMessage); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal poet proof for ref %x: %v", proofRef[:5], err)
 	}
-	return membershipSliceToMap(proofMessage.Members), nil
+	return &proofMessage, nil
 }
 
 func makeKey(poetID []byte, roundID string) poetProofKey {
-	sum := sha256.Sum256(append(poetID[:], []byte(roundID)...))
-	return sum
+	return poetProofKey(types.CalcHash32(append(poetID[:], []byte(roundID)...)))
 }
 
 func membershipSliceToMap(membership [][]byte) map[types.Hash32]bool {
@@ -0,0 +1,75 @@
+package activation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// posAtxHistorySize is the number of latest changes of the top atx kept in the positioning atx history.
+const posAtxHistorySize = 100
+
+// PosAtxChange is a change of the top atx, the atx the node positions its next atx on.
+type PosAtxChange struct {
+	AtxID   types.ATXID
+	LayerID types.LayerID
+	// Time is when the node selected the atx, in unix nanoseconds.
+	Time int64
+}
+
+// recordPosAtxChange writes the selection of top as the top atx to w, appending it to the positioning atx history and
+// dropping the oldest change if the history is full. Changes are persisted, so the history spans restarts.
+// This function is not thread safe and needs to be called under a global lock.
+func (db *DB) recordPosAtxChange(w atxWriter, top atxIDAndLayer) error {
+	seq := uint64(0)
+	it := db.atxs.Find([]byte(posAtxHistoryPrefix))
+	if it.Last() {
+		seq = binary.BigEndian.Uint64(it.Key()[len(posAtxHistoryPrefix):]) + 1
+	}
+	it.Release()
+
+	change := PosAtxChange{AtxID: top.AtxID, LayerID: top.LayerID, Time: time.Now().UnixNano()}
+	changeBytes, err := types.InterfaceToBytes(&change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal positioning atx change: %v", err)
+	}
+	if err := w.Put(getPosAtxHistoryKey(seq), changeBytes); err != nil {
+		return fmt.Errorf("failed to store positioning atx change: %v", err)
+	}
+	if seq >= posAtxHistorySize {
+		if err := w.Delete(getPosAtxHistoryKey(seq - posAtxHistorySize)); err != nil {
+			return fmt.Errorf("failed to drop positioning atx change: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetPosAtx returns the id and layer of the atx the node currently considers the best positioning atx.
+func (db *DB) GetPosAtx() (types.ATXID, types.LayerID, error) {
+	db.RLock()
+	defer db.RUnlock()
+	top, err := db.getTopAtx()
+	if err != nil {
+		return *types.EmptyATXID, 0, err
+	}
+	return top.AtxID, top.LayerID, nil
+}
+
+// PosAtxHistory returns the latest changes of the positioning atx, oldest first.
+func (db *DB) PosAtxHistory() ([]PosAtxChange, error) {
+	db.RLock()
+	defer db.RUnlock()
+	it := db.atxs.Find([]byte(posAtxHistoryPrefix))
+	defer it.Release()
+	var history []PosAtxChange
+	for it.Next() {
+		var change PosAtxChange
+		if err := types.BytesToInterface(it.Value(), &change); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal positioning atx change: %v", err)
+		}
+		history = append(history, change)
+	}
+	return history, nil
+}
@@ -0,0 +1,52 @@
+package activation
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_PosAtxHistory(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+
+	_, _, err := atxdb.GetPosAtx()
+	r.Equal(database.ErrNotFound, err)
+	history, err := atxdb.PosAtxHistory()
+	r.NoError(err)
+	r.Empty(history)
+
+	first, err := createAndStoreAtx(atxdb, 10)
+	r.NoError(err)
+	_, err = createAndStoreAtx(atxdb, 5) // lower than the top atx, not selected
+	r.NoError(err)
+	second, err := createAndStoreAtx(atxdb, 20)
+	r.NoError(err)
+
+	id, layer, err := atxdb.GetPosAtx()
+	r.NoError(err)
+	r.Equal(second.ID(), id)
+	r.Equal(types.LayerID(20), layer)
+
+	history, err = atxdb.PosAtxHistory()
+	r.NoError(err)
+	r.Len(history, 2)
+	r.Equal(first.ID(), history[0].AtxID)
+	r.Equal(types.LayerID(10), history[0].LayerID)
+	r.Equal(second.ID(), history[1].AtxID)
+	r.True(history[0].Time <= history[1].Time)
+
+	// only the latest changes are kept
+	var last *types.ActivationTx
+	for i := 0; i < posAtxHistorySize; i++ {
+		last, err = createAndStoreAtx(atxdb, types.LayerID(100+i))
+		r.NoError(err)
+	}
+	history, err = atxdb.PosAtxHistory()
+	r.NoError(err)
+	r.Len(history, posAtxHistorySize)
+	r.Equal(types.LayerID(100), history[0].LayerID)
+	r.Equal(last.ID(), history[posAtxHistorySize-1].AtxID)
+}
@@ -0,0 +1,134 @@
+package activation
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+const (
+	// HighestPosAtxPolicy positions atxs on the atx with the highest layer the node has seen.
+	HighestPosAtxPolicy = "highest"
+	// RandomTopPosAtxPolicy positions atxs on a random atx among the ones with the highest layers.
+	RandomTopPosAtxPolicy = "random-top"
+	// OwnPrevPosAtxPolicy positions atxs on the node's previous atx when it's as recent as the highest atx.
+	OwnPrevPosAtxPolicy = "own-prev"
+)
+
+// DefaultPosAtxTopN is the default number of highest atxs that RandomTopPosAtxPolicy picks from.
+const DefaultPosAtxTopN = 10
+
+// PositioningAtxProvider selects the atx that the node positions its next atx on.
+type PositioningAtxProvider interface {
+	PositioningAtx(nodeID types.NodeID) (types.ATXID, error)
+}
+
+// posAtxDB is the part of the atx database that positioning atx providers read.
+type posAtxDB interface {
+	GetPosAtxID() (types.ATXID, error)
+	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
+	GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error)
+}
+
+// NewPositioningAtxProvider returns the provider of policy, one of HighestPosAtxPolicy, RandomTopPosAtxPolicy and
+// OwnPrevPosAtxPolicy. topN is the number of atxs that RandomTopPosAtxPolicy picks from.
+func NewPositioningAtxProvider(policy string, topN int, db *DB) (PositioningAtxProvider, error) {
+	switch policy {
+	case HighestPosAtxPolicy:
+		return &highestPosAtx{db: db}, nil
+	case RandomTopPosAtxPolicy:
+		if topN < 1 {
+			return nil, fmt.Errorf("positioning atx policy %q needs at least one atx to pick from, got %v", policy, topN)
+		}
+		return &randomTopPosAtx{db: db, epochAtxs: db.forEachEpochAtx, layersPerEpoch: db.LayersPerEpoch, n: topN, pick: rand.Intn}, nil
+	case OwnPrevPosAtxPolicy:
+		return &ownPrevPosAtx{db: db, layersPerEpoch: db.LayersPerEpoch}, nil
+	default:
+		return nil, fmt.Errorf("unknown positioning atx policy %q", policy)
+	}
+}
+
+// highestPosAtx selects the atx with the highest layer, see DB.GetPosAtxID.
+type highestPosAtx struct {
+	db posAtxDB
+}
+
+func (p *highestPosAtx) PositioningAtx(types.NodeID) (types.ATXID, error) {
+	return p.db.GetPosAtxID()
+}
+
+// randomTopPosAtx selects a random atx among the n atxs with the highest layers that target the same epoch as the
+// highest atx. Miners that don't all build on the same atx are less likely to lose their atxs together when it's
+// orphaned.
+type randomTopPosAtx struct {
+	db             posAtxDB
+	epochAtxs      func(epoch types.EpochID, f func(nodeKey string, id types.ATXID) bool)
+	layersPerEpoch uint16
+	n              int
+	pick           func(n int) int
+}
+
+func (p *randomTopPosAtx) PositioningAtx(types.NodeID) (types.ATXID, error) {
+	topID, err := p.db.GetPosAtxID()
+	if err != nil {
+		return *types.EmptyATXID, err
+	}
+	top, err := p.db.GetAtxHeader(topID)
+	if err != nil {
+		return *types.EmptyATXID, fmt.Errorf("failed to get header of atx %v: %v", topID.ShortString(), err)
+	}
+
+	var candidates []*types.ActivationTxHeader
+	p.epochAtxs(top.TargetEpoch(p.layersPerEpoch), func(_ string, id types.ATXID) bool {
+		if atx, err := p.db.GetAtxHeader(id); err == nil {
+			candidates = append(candidates, atx)
+		}
+		return true
+	})
+	if len(candidates) == 0 {
+		return topID, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].PubLayerID != candidates[j].PubLayerID {
+			return candidates[i].PubLayerID > candidates[j].PubLayerID
+		}
+		return bytes.Compare(candidates[i].ID().Bytes(), candidates[j].ID().Bytes()) < 0
+	})
+	if len(candidates) > p.n {
+		candidates = candidates[:p.n]
+	}
+	return candidates[p.pick(len(candidates))].ID(), nil
+}
+
+// ownPrevPosAtx selects the node's previous atx when it was published in the same epoch as the highest atx, so that
+// the node's atxs don't depend on atxs of others that may be orphaned. Otherwise it selects the highest atx.
+type ownPrevPosAtx struct {
+	db             posAtxDB
+	layersPerEpoch uint16
+}
+
+func (p *ownPrevPosAtx) PositioningAtx(nodeID types.NodeID) (types.ATXID, error) {
+	topID, err := p.db.GetPosAtxID()
+	if err != nil {
+		return *types.EmptyATXID, err
+	}
+	prevID, err := p.db.GetNodeLastAtxID(nodeID)
+	if err != nil {
+		return topID, nil
+	}
+	top, err := p.db.GetAtxHeader(topID)
+	if err != nil {
+		return *types.EmptyATXID, fmt.Errorf("failed to get header of atx %v: %v", topID.ShortString(), err)
+	}
+	prev, err := p.db.GetAtxHeader(prevID)
+	if err != nil {
+		return *types.EmptyATXID, fmt.Errorf("failed to get header of atx %v: %v", prevID.ShortString(), err)
+	}
+	if prev.PubLayerID.GetEpoch(p.layersPerEpoch) < top.PubLayerID.GetEpoch(p.layersPerEpoch) {
+		return topID, nil
+	}
+	return prevID, nil
+}
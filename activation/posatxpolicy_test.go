@@ -0,0 +1,75 @@
+package activation
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPositioningAtxProvider(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+
+	for _, policy := range []string{HighestPosAtxPolicy, RandomTopPosAtxPolicy, OwnPrevPosAtxPolicy} {
+		_, err := NewPositioningAtxProvider(policy, DefaultPosAtxTopN, atxdb)
+		r.NoError(err)
+	}
+	_, err := NewPositioningAtxProvider(RandomTopPosAtxPolicy, 0, atxdb)
+	r.Error(err)
+	_, err = NewPositioningAtxProvider("lowest", DefaultPosAtxTopN, atxdb)
+	r.Error(err)
+}
+
+func TestPositioningAtxProviders(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	nodeID := types.NodeID{Key: uuid.New().String(), VRFPublicKey: []byte("vrf")}
+
+	highest, err := NewPositioningAtxProvider(HighestPosAtxPolicy, DefaultPosAtxTopN, atxdb)
+	r.NoError(err)
+	_, err = highest.PositioningAtx(nodeID)
+	r.Error(err)
+
+	var atxs []*types.ActivationTx
+	for _, layer := range []types.LayerID{10, 40, 30, 20} {
+		atx, err := createAndStoreAtx(atxdb, layer)
+		r.NoError(err)
+		atxs = append(atxs, atx)
+	}
+	id, err := highest.PositioningAtx(nodeID)
+	r.NoError(err)
+	r.Equal(atxs[1].ID(), id)
+
+	// the random pick is among the 2 highest atxs
+	var picked []int
+	randomTop := &randomTopPosAtx{db: atxdb, epochAtxs: atxdb.forEachEpochAtx, layersPerEpoch: atxdb.LayersPerEpoch, n: 2,
+		pick: func(n int) int {
+			picked = append(picked, n)
+			return n - 1
+		}}
+	id, err = randomTop.PositioningAtx(nodeID)
+	r.NoError(err)
+	r.Equal([]int{2}, picked)
+	r.Equal(atxs[2].ID(), id)
+
+	// the node's previous atx is preferred over a higher atx of the same epoch
+	ownPrev, err := NewPositioningAtxProvider(OwnPrevPosAtxPolicy, DefaultPosAtxTopN, atxdb)
+	r.NoError(err)
+	id, err = ownPrev.PositioningAtx(nodeID)
+	r.NoError(err)
+	r.Equal(atxs[1].ID(), id)
+	prev := newActivationTx(nodeID, 0, *types.EmptyATXID, 15, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	r.NoError(atxdb.StoreAtx(prev.TargetEpoch(layersPerEpochBig), prev))
+	id, err = ownPrev.PositioningAtx(nodeID)
+	r.NoError(err)
+	r.Equal(prev.ID(), id)
+
+	// but not over an atx of a later epoch
+	later, err := createAndStoreAtx(atxdb, layersPerEpochBig+10)
+	r.NoError(err)
+	id, err = ownPrev.PositioningAtx(nodeID)
+	r.NoError(err)
+	r.Equal(later.ID(), id)
+}
@@ -1,6 +1,8 @@
 package activation
 
 import (
+	"bytes"
+	"crypto/rand"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/signing"
@@ -97,6 +99,47 @@ func (c *PostClient) Execute(challenge []byte) (*types.PostProof, error) {
 	return (*types.PostProof)(proof), err
 }
 
+// VerifyData re-reads the initialized data and checks its integrity: the Merkle root of all labels must match
+// commitmentRoot, when given, and the labels proven for rounds random challenges must be valid for the client's id. It
+// returns the Merkle root of the data. A corrupt or unreadable file is detected before it fails a proof of an epoch.
+func (c *PostClient) VerifyData(commitmentRoot []byte, rounds int) ([]byte, error) {
+	initialized, _, err := c.IsInitialized()
+	if err != nil {
+		return nil, err
+	}
+	if !initialized {
+		return nil, fmt.Errorf("post not initialized, cannot verify its data")
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	key := signing.NewPublicKey(c.minerID)
+	commitment, err := c.prover.GenerateProof(shared.ZeroChallenge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %v", err)
+	}
+	root := commitment.MerkleRoot
+	if commitmentRoot != nil && !bytes.Equal(root, commitmentRoot) {
+		return root, fmt.Errorf("data merkle root %x does not match the commitment merkle root %x", root, commitmentRoot)
+	}
+	for i := 0; i < rounds; i++ {
+		challenge := make([]byte, 32)
+		if _, err := rand.Read(challenge); err != nil {
+			return root, err
+		}
+		proof, err := c.prover.GenerateProof(challenge)
+		if err != nil {
+			return root, fmt.Errorf("failed to read data: %v", err)
+		}
+		err = verifyPost(*key, (*types.PostProof)(proof), c.cfg.SpacePerUnit, c.cfg.NumProvenLabels, c.cfg.Difficulty)
+		if err != nil {
+			return root, fmt.Errorf("invalid labels for challenge %x: %v", challenge, err)
+		}
+	}
+	return root, nil
+}
+
 // Reset removes the initialization phase files.
 func (c *PostClient) Reset() error {
 	ok, _, err := c.IsInitialized()
@@ -4,7 +4,11 @@ import (
 	"crypto/rand"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/post/shared"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -41,3 +45,48 @@ func TestPostClient(t *testing.T) {
 	err = verifyPost(*keyID, proof, postCfg.SpacePerUnit, postCfg.NumProvenLabels, postCfg.Difficulty)
 	assert.NoError(err)
 }
+
+func TestPostClient_VerifyData(t *testing.T) {
+	assert := require.New(t)
+
+	id := make([]byte, 32)
+	_, err := rand.Read(id)
+	assert.NoError(err)
+
+	c, err := NewPostClient(&postCfg, id)
+	assert.NoError(err)
+
+	_, err = c.VerifyData(nil, 1)
+	assert.Error(err)
+
+	commitment, err := c.Initialize()
+	assert.NoError(err)
+	defer func() {
+		assert.NoError(c.Reset())
+	}()
+
+	root, err := c.VerifyData(commitment.MerkleRoot, 3)
+	assert.NoError(err)
+	assert.Equal(commitment.MerkleRoot, root)
+
+	_, err = c.VerifyData([]byte("another root"), 1)
+	assert.Error(err)
+
+	// zero the data file, so that every label is invalid
+	files, err := filepath.Glob(filepath.Join(shared.GetInitDir(postCfg.DataDir, id), "*"))
+	assert.NoError(err)
+	var data os.FileInfo
+	var dataFile string
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil && !info.IsDir() && (data == nil || info.Size() > data.Size()) {
+			data, dataFile = info, f
+		}
+	}
+	assert.NotNil(data)
+	assert.NoError(ioutil.WriteFile(dataFile, make([]byte, data.Size()), data.Mode()))
+
+	_, err = c.VerifyData(nil, 3)
+	assert.Error(err)
+	_, err = c.VerifyData(commitment.MerkleRoot, 0)
+	assert.Error(err)
+}
@@ -0,0 +1,72 @@
+package activation
+
+import (
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// Prewarm loads the atx headers targeting epoch, and the identities that published them, into their caches. These are
+// the active identities of the epoch. At most limit atxs are loaded, it returns how many were.
+func (db *DB) Prewarm(epoch types.EpochID, limit int) int {
+	loaded := 0
+	if limit <= 0 {
+		return loaded
+	}
+	db.forEachEpochAtx(epoch, func(nodeKey string, id types.ATXID) bool {
+		if _, err := db.GetAtxHeader(id); err != nil {
+			db.log.With().Warning("failed to prewarm atx", log.AtxID(id.ShortString()), log.Err(err))
+			return true
+		}
+		if _, err := db.idStore.GetIdentity(nodeKey); err != nil {
+			db.log.With().Warning("failed to prewarm identity", log.String("node_id", nodeKey), log.Err(err))
+		}
+		loaded++
+		return loaded < limit
+	})
+	return loaded
+}
+
+// Prewarmer prewarms the atx and identity caches with the active identities of each epoch when it starts, so that the
+// first hare rounds and block validations of the epoch don't all miss the caches and stampede the database.
+type Prewarmer struct {
+	db     *DB
+	layers chan types.LayerID
+	limit  int
+	log    log.Log
+	exit   chan struct{}
+}
+
+// NewPrewarmer returns a Prewarmer that prewarms at most limit atxs when layers ticks the first layer of an epoch.
+func NewPrewarmer(db *DB, layers chan types.LayerID, limit int, logger log.Log) *Prewarmer {
+	return &Prewarmer{db: db, layers: layers, limit: limit, log: logger, exit: make(chan struct{})}
+}
+
+// Start starts prewarming the caches at the start of each epoch.
+func (p *Prewarmer) Start() {
+	go p.loop()
+}
+
+// Close stops the Prewarmer.
+func (p *Prewarmer) Close() {
+	close(p.exit)
+}
+
+func (p *Prewarmer) loop() {
+	for {
+		select {
+		case <-p.exit:
+			return
+		case layer := <-p.layers:
+			epoch := layer.GetEpoch(p.db.LayersPerEpoch)
+			if layer != epoch.FirstLayer(p.db.LayersPerEpoch) {
+				continue
+			}
+			start := time.Now()
+			loaded := p.db.Prewarm(epoch, p.limit)
+			p.log.With().Info("prewarmed caches for the new epoch", epoch,
+				log.Int("atxs", loaded), log.String("duration", time.Since(start).String()))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package activation
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Prewarm(t *testing.T) {
+	r := require.New(t)
+	atxdb, _, _ := getAtxDb(t.Name())
+	coinbase := types.HexToAddress("aaaa")
+
+	var active []*types.ActivationTx
+	for i := 0; i < 3; i++ {
+		id := types.NodeID{Key: uuid.New().String()}
+		r.NoError(atxdb.idStore.StoreNodeIdentity(id))
+		atx := newActivationTx(id, 0, *types.EmptyATXID, 1, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+		r.NoError(atxdb.StoreAtx(1, atx))
+		active = append(active, atx)
+	}
+	other := newActivationTx(types.NodeID{Key: uuid.New().String()}, 0, *types.EmptyATXID, 1001, 0, *types.EmptyATXID, coinbase, 3, []types.BlockID{}, &types.NIPST{})
+	r.NoError(atxdb.StoreAtx(2, other))
+
+	atxdb.SetHeaderCacheSize(DefaultAtxCacheSize)
+	r.Equal(2, atxdb.Prewarm(1, 2))
+	r.Equal(3, atxdb.Prewarm(1, 10))
+	for _, atx := range active {
+		_, ok := atxdb.atxHeaderCache.Get(atx.ID())
+		r.True(ok)
+	}
+	_, ok := atxdb.atxHeaderCache.Get(other.ID())
+	r.False(ok)
+}
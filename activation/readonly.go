@@ -0,0 +1,52 @@
+package activation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+var (
+	errReadOnly = errors.New("the atx database is read-only")
+	errNoMesh   = errors.New("the atx database was opened without the mesh")
+)
+
+// OpenActivationDbReadOnly opens the atx database of a node for inspection by external tools, such as explorers and
+// debugging CLIs. path is the directory that holds the node's "atx" and "ids" stores, which is its data directory
+// unless the stores were moved with --store-dirs.
+//
+// The stores are opened read-only, so the node's state can't be corrupted: storing, processing and validating atxs
+// fails. Atxs aren't validated and active sets aren't calculated, so no validator or mesh is needed, and atx headers
+// aren't cached. A store can't be opened while a running node has it open, so to inspect the atxs of a running node,
+// open a backup taken with the Backup RPC. The database must be closed with Close.
+func OpenActivationDbReadOnly(path string, layersPerEpoch uint16, logger log.Log) (*DB, error) {
+	atxs, err := database.NewLDBDatabaseReadOnly(filepath.Join(path, "atx"), logger)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open atx store: %v", err)
+	}
+	ids, err := database.NewLDBDatabaseReadOnly(filepath.Join(path, "ids"), logger)
+	if err != nil {
+		atxs.Close()
+		return nil, fmt.Errorf("cannot open identity store: %v", err)
+	}
+	db := NewDB(atxs, NewIdentityStore(ids), nil, layersPerEpoch, nil, logger)
+	db.readOnly = true
+	db.stores = []*database.LDBDatabase{atxs, ids}
+	if err := db.MigrateKeys(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the stores opened by OpenActivationDbReadOnly. The stores of a database created by NewDB are owned by
+// the caller and aren't closed.
+func (db *DB) Close() {
+	for _, store := range db.stores {
+		store.Close()
+	}
+	db.stores = nil
+}
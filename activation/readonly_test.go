@@ -0,0 +1,69 @@
+package activation
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenActivationDbReadOnly(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+	lg := log.NewDefault(t.Name())
+
+	atxStore, err := database.NewLDBDatabase(filepath.Join(dir, "atx"), 0, 0, lg)
+	r.NoError(err)
+	idStore, err := database.NewLDBDatabase(filepath.Join(dir, "ids"), 0, 0, lg)
+	r.NoError(err)
+	atxdb := NewDB(atxStore, NewIdentityStore(idStore), mesh.NewMemMeshDB(lg), layersPerEpoch, &ValidatorMock{}, lg)
+	r.NoError(atxdb.MigrateKeys())
+	atx, err := createAndStoreAtx(atxdb, 10)
+	r.NoError(err)
+	r.NoError(atxdb.StoreNodeIdentity(atx.NodeID))
+
+	// a store can't be opened while the node has it open
+	_, err = OpenActivationDbReadOnly(dir, layersPerEpoch, lg)
+	r.Error(err)
+	atxStore.Close()
+	idStore.Close()
+
+	ro, err := OpenActivationDbReadOnly(dir, layersPerEpoch, lg)
+	r.NoError(err)
+	defer ro.Close()
+	got, err := ro.GetFullAtx(atx.ID())
+	r.NoError(err)
+	r.Equal(atx.ID(), got.ID())
+	id, err := ro.GetNodeLastAtxID(atx.NodeID)
+	r.NoError(err)
+	r.Equal(atx.ID(), id)
+	_, err = ro.GetIdentity(atx.NodeID.Key)
+	r.NoError(err)
+
+	other, err := createAndStoreAtx(ro, 20)
+	r.Equal(errReadOnly, err)
+	r.Nil(other)
+	r.Equal(errReadOnly, ro.ProcessAtx(atx))
+	r.Equal(errReadOnly, ro.SyntacticallyValidateAtx(context.Background(), atx))
+	_, err = ro.CalcActiveSetFromFirstSeen(1)
+	r.Equal(errNoMesh, err)
+}
+
+func TestOpenActivationDbReadOnly_Missing(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = OpenActivationDbReadOnly(dir, layersPerEpoch, log.NewDefault(t.Name()))
+	require.Error(t, err)
+	_, err = os.Stat(filepath.Join(dir, "atx"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -9,17 +9,28 @@ import (
 	"github.com/spacemeshos/post/config"
 )
 
+type poetValidatorDbAPI interface {
+	GetMembershipMap(proofRef []byte) (map[types.Hash32]bool, error)
+	GetLeafCount(proofRef []byte) (uint64, error)
+}
+
 // Validator contains the dependencies required to validate NIPSTs
 type Validator struct {
-	postCfg *config.Config
-	poetDb  poetDbAPI
+	postCfg  *config.Config
+	poetDb   poetValidatorDbAPI
+	tickSize uint64
 }
 
-// NewValidator returns a new NIPST validator
-func NewValidator(postCfg *config.Config, poetDb poetDbAPI) *Validator {
+// NewValidator returns a new NIPST validator. tickSize is the number of PoET leaves that make up a single tick, the
+// unit in which ATXs declare the duration they prove.
+func NewValidator(postCfg *config.Config, poetDb poetValidatorDbAPI, tickSize uint64) *Validator {
+	if tickSize == 0 {
+		tickSize = 1
+	}
 	return &Validator{
-		postCfg: postCfg,
-		poetDb:  poetDb,
+		postCfg:  postCfg,
+		poetDb:   poetDb,
+		tickSize: tickSize,
 	}
 }
 
@@ -45,6 +56,30 @@ func (v *Validator) Validate(minerID signing.PublicKey, nipst *types.NIPST, expe
 	return nil
 }
 
+// NumOfTicks returns the number of ticks proven by the PoET proof referenced by the NIPST.
+func (v *Validator) NumOfTicks(nipst *types.NIPST) (uint64, error) {
+	if nipst == nil || nipst.PostProof == nil {
+		return 0, errors.New("NIPST does not reference a PoET proof")
+	}
+	leafCount, err := v.poetDb.GetLeafCount(nipst.PostProof.Challenge)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get PoET proof duration: %v", err)
+	}
+	return leafCount / v.tickSize, nil
+}
+
+// NumOfSpaceUnits returns the number of whole space units committed by the PoST included in the NIPST.
+func (v *Validator) NumOfSpaceUnits(nipst *types.NIPST) uint32 {
+	return spaceUnits(nipst.Space, v.postCfg.SpacePerUnit)
+}
+
+func spaceUnits(space, spacePerUnit uint64) uint32 {
+	if spacePerUnit == 0 {
+		return 0
+	}
+	return uint32(space / spacePerUnit)
+}
+
 // VerifyPost validates a Proof of Space-Time (PoST). It returns nil if validation passed or an error indicating why
 // validation failed.
 func (v *Validator) VerifyPost(minerID signing.PublicKey, proof *types.PostProof, space uint64) error {
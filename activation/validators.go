@@ -0,0 +1,59 @@
+package activation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// validatorVersion is a NIPST validator of a single PoST parameter version, with the first epoch it validates ATXs of.
+type validatorVersion struct {
+	version   uint32
+	epoch     types.EpochID
+	validator nipstValidator
+}
+
+// ValidatorRegistry holds the NIPST validators of all PoST parameter versions of the network. Each version validates
+// the ATXs that target its epoch and later epochs, until the epoch of the next version, so that a change of the PoST
+// parameters rolls out at an epoch boundary: ATXs published before it are still validated with the parameters they
+// were built with.
+//
+// The version of an ATX's format isn't encoded in the ATX, it's determined by the ATX's target epoch.
+type ValidatorRegistry struct {
+	mu       sync.RWMutex
+	versions []validatorVersion // sorted by version, and so by epoch
+}
+
+// NewValidatorRegistry returns a registry whose version 0 is genesis, which validates ATXs from the genesis epoch on.
+func NewValidatorRegistry(genesis nipstValidator) *ValidatorRegistry {
+	return &ValidatorRegistry{versions: []validatorVersion{{validator: genesis}}}
+}
+
+// Register adds a validator of the given version, which validates ATXs that target epoch or later epochs. Versions
+// must be registered in order, each with a higher version and a later epoch than the latest one registered.
+func (r *ValidatorRegistry) Register(version uint32, epoch types.EpochID, validator nipstValidator) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latest := r.versions[len(r.versions)-1]
+	if version <= latest.version {
+		return fmt.Errorf("validator version %v is not higher than the latest version %v", version, latest.version)
+	}
+	if epoch <= latest.epoch {
+		return fmt.Errorf("validator version %v activates at epoch %v, not after version %v at epoch %v",
+			version, epoch, latest.version, latest.epoch)
+	}
+	r.versions = append(r.versions, validatorVersion{version: version, epoch: epoch, validator: validator})
+	return nil
+}
+
+// ForEpoch returns the version and the validator of ATXs that target the given epoch.
+func (r *ValidatorRegistry) ForEpoch(epoch types.EpochID) (uint32, nipstValidator) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	// the first version that activates after epoch, the one before it is the version of epoch
+	i := sort.Search(len(r.versions), func(i int) bool { return r.versions[i].epoch > epoch })
+	v := r.versions[i-1]
+	return v.version, v.validator
+}
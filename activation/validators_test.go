@@ -0,0 +1,34 @@
+package activation
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorRegistry(t *testing.T) {
+	r := require.New(t)
+	genesis, v1, v2 := &ValidatorMock{}, &countingValidator{}, &spaceUnitsValidator{}
+	registry := NewValidatorRegistry(genesis)
+
+	version, validator := registry.ForEpoch(10)
+	r.Equal(uint32(0), version)
+	r.Same(genesis, validator)
+
+	r.NoError(registry.Register(1, 5, v1))
+	r.NoError(registry.Register(3, 8, v2))
+	r.EqualError(registry.Register(3, 9, v2), "validator version 3 is not higher than the latest version 3")
+	r.EqualError(registry.Register(4, 8, v2), "validator version 4 activates at epoch 8, not after version 3 at epoch 8")
+
+	for epoch, expected := range map[uint64]struct {
+		version   uint32
+		validator nipstValidator
+	}{
+		0: {0, genesis}, 4: {0, genesis}, 5: {1, v1}, 7: {1, v1}, 8: {3, v2}, 100: {3, v2},
+	} {
+		version, validator := registry.ForEpoch(types.EpochID(epoch))
+		r.Equal(expected.version, version, epoch)
+		r.Same(expected.validator, validator, epoch)
+	}
+}
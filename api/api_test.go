@@ -5,16 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/backup"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	config2 "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/layercache"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/miner"
+	"github.com/spacemeshos/go-spacemesh/p2p"
+	"github.com/spacemeshos/go-spacemesh/p2p/gossip"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/timesync"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"math/big"
@@ -36,8 +45,9 @@ import (
 // Better a small code duplication than a small dependency
 
 type NodeAPIMock struct {
-	balances map[types.Address]*big.Int
-	nonces   map[types.Address]uint64
+	balances      map[types.Address]*big.Int
+	nonces        map[types.Address]uint64
+	layerBalances map[types.LayerID]map[types.Address]uint64
 }
 
 type NetworkMock struct {
@@ -57,10 +67,25 @@ func (s *NetworkMock) Broadcast(_ string, payload []byte) error {
 	return nil
 }
 
+func (s *NetworkMock) GossipReport() gossip.Report {
+	return gossip.Report{Peers: 5, Protocols: []gossip.ProtocolStats{
+		{Protocol: "newBlock", New: 10, Duplicate: 30, Missed: 10, Latency: make([]uint64, len(gossip.LatencyBuckets)+1), FirstDeliverers: 3},
+	}}
+}
+
+func (s *NetworkMock) PeerStats() []p2p.PeerProtocolStats {
+	return []p2p.PeerProtocolStats{
+		{Peer: "peer1", Protocol: "/sync/1.0/", Sent: 4, Received: 4, BytesSent: 100, BytesReceived: 4000, Responses: 4,
+			ResponseTime: 200 * time.Millisecond},
+		{Peer: "peer1", Protocol: "/p2p/1.0/gossip", Received: 3, BytesReceived: 300, Invalid: 1},
+	}
+}
+
 func NewNodeAPIMock() NodeAPIMock {
 	return NodeAPIMock{
-		balances: make(map[types.Address]*big.Int),
-		nonces:   make(map[types.Address]uint64),
+		balances:      make(map[types.Address]*big.Int),
+		nonces:        make(map[types.Address]uint64),
+		layerBalances: make(map[types.LayerID]map[types.Address]uint64),
 	}
 }
 
@@ -77,11 +102,48 @@ func (n NodeAPIMock) Exist(address types.Address) bool {
 	return ok
 }
 
+func (n NodeAPIMock) GetLayerBalance(layer types.LayerID, address types.Address) (uint64, error) {
+	balances, ok := n.layerBalances[layer]
+	if !ok {
+		return 0, fmt.Errorf("no state for layer %v", layer)
+	}
+	return balances[address], nil
+}
+
 type TxAPIMock struct {
 	mockOrigin   types.Address
 	returnTx     map[types.TransactionID]*types.Transaction
 	layerApplied map[types.TransactionID]*types.LayerID
 	err          error
+	refusedReorg *types.LayerID
+	tombstones   map[types.BlockID]*mesh.Tombstone
+}
+
+func (t *TxAPIMock) GetTombstone(id types.BlockID) (*mesh.Tombstone, error) {
+	if ts, ok := t.tombstones[id]; ok {
+		return ts, nil
+	}
+	return nil, database.ErrNotFound
+}
+
+func (t *TxAPIMock) FinalizedLayer() types.LayerID {
+	return ValidatedLayerID - 4
+}
+
+func (t *TxAPIMock) RefusedReorg() (types.LayerID, bool) {
+	if t.refusedReorg == nil {
+		return 0, false
+	}
+	return *t.refusedReorg, true
+}
+
+func (t *TxAPIMock) ApproveReorg() (types.LayerID, error) {
+	if t.refusedReorg == nil {
+		return 0, errors.New("no reorganization was refused")
+	}
+	lyr := *t.refusedReorg
+	t.refusedReorg = nil
+	return lyr, nil
 }
 
 func (t *TxAPIMock) GetStateRoot() types.Hash32 {
@@ -102,6 +164,10 @@ func (t *TxAPIMock) LatestLayerInState() types.LayerID {
 	return ValidatedLayerID
 }
 
+func (t *TxAPIMock) VerifiedLayer() types.LayerID {
+	return ValidatedLayerID - 2
+}
+
 func (t *TxAPIMock) GetLayerApplied(txID types.TransactionID) *types.LayerID {
 	return t.layerApplied[txID]
 }
@@ -166,7 +232,13 @@ func (*MiningAPIMock) StartPost(types.Address, string, uint64) error {
 	return nil
 }
 
-func (*MiningAPIMock) SetCoinbaseAccount(types.Address) {}
+func (*MiningAPIMock) SetCoinbaseAccount(types.Address) error { return nil }
+
+func (*MiningAPIMock) NextAtx() (*activation.NextAtx, error) {
+	challenge := types.NIPSTChallenge{NodeID: types.NodeID{Key: "aaaa"}, Sequence: 3, PubLayerID: 25}
+	atx := types.NewActivationTx(challenge, types.HexToAddress("0x1234"), 10, []types.BlockID{{1}, {2}}, &types.NIPST{}, nil)
+	return &activation.NextAtx{Atx: atx, Size: 512, TargetEpoch: 3, Deadline: time.Unix(1000, 0)}, nil
+}
 
 type OracleMock struct{}
 
@@ -186,6 +258,97 @@ func (t GenesisTimeMock) GetGenesisTime() time.Time {
 	return t.t
 }
 
+func (t GenesisTimeMock) LayerToTime(id types.LayerID) time.Time {
+	return timesync.NewLayerConv(layerDuration*time.Second, t.t).LayerToTime(id)
+}
+
+func (t GenesisTimeMock) TimeToLayer(tm time.Time) types.LayerID {
+	return timesync.NewLayerConv(layerDuration*time.Second, t.t).TimeToLayer(tm)
+}
+
+type LayerResultsMock struct {
+	tx *types.Transaction
+}
+
+func (m LayerResultsMock) Get(layer types.LayerID) (*layercache.LayerResults, error) {
+	if layer != TxReturnLayer {
+		return nil, layercache.ErrNotCached
+	}
+	return &layercache.LayerResults{
+		Layer:     layer,
+		StateRoot: types.Hash32{1},
+		Txs:       []layercache.TxResult{{Tx: m.tx, Applied: true}},
+		Rewards:   []layercache.Reward{{Coinbase: types.BytesToAddress([]byte{2}), TotalReward: 60, LayerRewardEstimate: 50}},
+		Accounts:  []layercache.AccountDiff{{Address: m.tx.Recipient, Balance: 10}},
+	}, nil
+}
+
+type PosAtxMock struct{}
+
+var posAtxID = types.ATXID(types.CalcHash32([]byte("posatx")))
+
+func (PosAtxMock) GetPosAtx() (types.ATXID, types.LayerID, error) {
+	return posAtxID, 20, nil
+}
+
+func (PosAtxMock) PosAtxHistory() ([]activation.PosAtxChange, error) {
+	return []activation.PosAtxChange{
+		{AtxID: types.ATXID(types.CalcHash32([]byte("old"))), LayerID: 10, Time: 1},
+		{AtxID: posAtxID, LayerID: 20, Time: 2},
+	}, nil
+}
+
+type NodeAtxsMock struct{}
+
+var nodeAtxIDs = []types.ATXID{types.ATXID(types.CalcHash32([]byte("atx1"))), types.ATXID(types.CalcHash32([]byte("atx2")))}
+
+func (NodeAtxsMock) GetNodeAtxIDs(nodeID types.NodeID) ([]types.ATXID, error) {
+	if nodeID.Key != "miner" {
+		return nil, fmt.Errorf("atx for node %v does not exist", nodeID.ShortString())
+	}
+	return nodeAtxIDs, nil
+}
+
+// EpochAtxIDs pages through nodeAtxIDs, which all target epoch 3
+func (NodeAtxsMock) EpochAtxIDs(epoch types.EpochID, after *types.ATXID, limit int) ([]types.ATXID, error) {
+	if epoch != 3 {
+		return nil, nil
+	}
+	ids := nodeAtxIDs
+	if after != nil {
+		for i, id := range ids {
+			if id == *after {
+				ids = ids[i+1:]
+				break
+			}
+		}
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// SubscribeAtx streams the ids of nodeAtxIDs
+func (NodeAtxsMock) SubscribeAtx() (<-chan types.ATXID, func()) {
+	ch := make(chan types.ATXID, len(nodeAtxIDs))
+	for _, id := range nodeAtxIDs {
+		ch <- id
+	}
+	return ch, func() {}
+}
+
+type BeaconMock struct{}
+
+var epochBeacon = []byte{1, 2, 3, 4}
+
+func (BeaconMock) GetBeacon(epoch types.EpochID) ([]byte, error) {
+	if epoch != 3 {
+		return nil, fmt.Errorf("no beacon for epoch %v", epoch)
+	}
+	return epochBeacon, nil
+}
+
 type PostMock struct {
 }
 
@@ -207,6 +370,7 @@ var (
 	oracle      = OracleMock{}
 	genTime     = GenesisTimeMock{time.Unix(genTimeUnix, 0)}
 	txMempool   = miner.NewTxMemPool()
+	layerTx, _  = mesh.NewSignedTx(1, [20]byte{1}, 10, 1, 1, signing.NewEdSigner())
 	txAPI       = &TxAPIMock{
 		returnTx:     make(map[types.TransactionID]*types.Transaction),
 		layerApplied: make(map[types.TransactionID]*types.LayerID),
@@ -218,7 +382,7 @@ func TestServersConfig(t *testing.T) {
 	port2, err := node.GetUnboundedPort()
 	require.NoError(t, err, "Should be able to establish a connection on a port")
 
-	grpcService := NewGrpcService(port1, &networkMock, ap, txAPI, nil, &mining, &oracle, nil, PostMock{}, 0, nil, nil, nil)
+	grpcService := NewGrpcService(port1, &networkMock, ap, txAPI, nil, &mining, &oracle, nil, PostMock{}, 0, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	require.Equal(t, grpcService.Port, uint(port1), "Expected same port")
 
 	jsonService := NewJSONHTTPServer(port2, port1)
@@ -252,6 +416,413 @@ func TestGrpcApi(t *testing.T) {
 	shutDown()
 }
 
+func TestGrpcApi_PeerEvents(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.PeerEvents(ctx, &empty.Empty{})
+	r.NoError(err)
+
+	// the server subscribes asynchronously, so publish until the event is streamed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			events.Publish(events.Peer{ID: "peer", Type: events.PeerConnected, Reason: events.ReasonInbound})
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	ev, err := stream.Recv()
+	r.NoError(err)
+	r.Equal("peer", ev.Peer)
+	r.Equal(events.PeerConnected, ev.Type)
+	r.Equal(events.ReasonInbound, ev.Reason)
+}
+
+func TestGrpcApi_TransactionEvents(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	tracked, other := types.BytesToAddress([]byte{1}), types.BytesToAddress([]byte{2})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.TransactionEvents(ctx, &pb.TxFilter{Accounts: []*pb.AccountId{{Address: tracked.Bech32(types.DefaultAddressHRP)}}})
+	r.NoError(err)
+
+	// the server subscribes asynchronously, so publish until an event is streamed. Only the transaction received by the
+	// tracked account passes the filter
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			events.Publish(events.TxReceipt{ID: types.TransactionID{1}.String(), Origin: other.String(),
+				Destination: other.String(), Amount: 10, Layer: 3, Valid: true})
+			events.Publish(events.TxReceipt{ID: types.TransactionID{2}.String(), Origin: other.String(),
+				Destination: tracked.String(), Amount: 20, Layer: 3, Valid: false})
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	tx, err := stream.Recv()
+	r.NoError(err)
+	r.Equal(types.TransactionID{2}.Bytes(), tx.TxId.Id)
+	r.Equal(tracked.Bech32(types.DefaultAddressHRP), tx.Receiver.Address)
+	r.Equal(uint64(20), tx.Amount)
+	r.Equal(uint64(3), tx.LayerId)
+	r.Equal(pb.TxStatus_REJECTED, tx.Status)
+}
+
+func TestGrpcApi_Mempool(t *testing.T) {
+	r := require.New(t)
+	pool := miner.NewTxMemPool()
+	signer1, signer2 := signing.NewEdSigner(), signing.NewEdSigner()
+	var txs []*types.Transaction
+	for _, signer := range []*signing.EdSigner{signer1, signer2} {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, err := mesh.NewSignedTx(nonce, types.Address{1}, 10, 1, 1, signer)
+			r.NoError(err)
+			pool.Put(tx.ID(), tx)
+			txs = append(txs, tx)
+		}
+	}
+	nodeConfig := config2.DefaultConfig()
+	s := SpacemeshGrpcService{TxMempool: pool, Config: &nodeConfig, admin: true}
+
+	// the admin api is disabled by default
+	_, err := s.GetMempool(context.Background(), &pb.MempoolRequest{})
+	r.Equal(errAdminAPIDisabled, err)
+	_, err = s.EvictMempoolTxs(context.Background(), &pb.EvictRequest{TxIds: []*pb.TransactionId{{Id: txs[0].ID().Bytes()}}})
+	r.Equal(errAdminAPIDisabled, err)
+	nodeConfig.API.AdminAPI = true
+
+	stats, err := s.GetMempoolStats(context.Background(), &empty.Empty{})
+	r.NoError(err)
+	r.Equal(&pb.MempoolStats{Txs: 4, Accounts: 2, Amount: 40, Fees: 4}, stats)
+
+	// the second page spans both origins
+	sorted := pool.Txs()
+	page, err := s.GetMempool(context.Background(), &pb.MempoolRequest{Offset: 1, Limit: 2})
+	r.NoError(err)
+	r.Equal(uint64(4), page.Total)
+	r.Len(page.Accounts, 2)
+	r.Equal(sorted[1].Origin().Bech32(types.DefaultAddressHRP), page.Accounts[0].Account.Address)
+	r.Len(page.Accounts[0].Txs, 1)
+	r.Equal(sorted[1].ID().Bytes(), page.Accounts[0].Txs[0].TxId.Id)
+	r.Equal(pb.TxStatus_PENDING, page.Accounts[0].Txs[0].Status)
+	r.Equal(sorted[2].Origin().Bech32(types.DefaultAddressHRP), page.Accounts[1].Account.Address)
+	r.Len(page.Accounts[1].Txs, 1)
+	page, err = s.GetMempool(context.Background(), &pb.MempoolRequest{Offset: 4})
+	r.NoError(err)
+	r.Empty(page.Accounts)
+
+	origin2 := types.BytesToAddress(signer2.PublicKey().Bytes())
+	evicted, err := s.EvictMempoolTxs(context.Background(), &pb.EvictRequest{
+		TxIds:   []*pb.TransactionId{{Id: txs[0].ID().Bytes()}, {Id: txs[0].ID().Bytes()}},
+		Account: &pb.AccountId{Address: origin2.Bech32(types.DefaultAddressHRP)},
+	})
+	r.NoError(err)
+	r.Len(evicted.TxIds, 3)
+	r.Equal([]*types.Transaction{txs[1]}, pool.Txs())
+
+	_, err = s.SetMinGasPrice(context.Background(), &pb.MinGasPrice{Price: 2})
+	r.NoError(err)
+	r.Equal(uint64(2), pool.MinGasPrice())
+	nodeConfig.API.AdminAPI = false
+	_, err = s.SetMinGasPrice(context.Background(), &pb.MinGasPrice{Price: 3})
+	r.Equal(errAdminAPIDisabled, err)
+	r.Equal(uint64(2), pool.MinGasPrice())
+}
+
+// poolTxAPI validates the nonces of transactions against the projection of the mempool.
+type poolTxAPI struct {
+	*TxAPIMock
+	pool *miner.TxMempool
+}
+
+func (t poolTxAPI) ValidateNonceAndBalance(tx *types.Transaction) error {
+	if nonce, _ := t.pool.GetProjection(tx.Origin(), 0, 100); tx.AccountNonce != nonce {
+		return fmt.Errorf("incorrect account nonce! Expected: %d, Actual: %d", nonce, tx.AccountNonce)
+	}
+	return nil
+}
+
+func TestSpacemeshGrpcService_SubmitTransactions(t *testing.T) {
+	r := require.New(t)
+	pool := miner.NewTxMemPool()
+	s := SpacemeshGrpcService{Network: &NetworkMock{}, Tx: poolTxAPI{&TxAPIMock{}, pool}, TxMempool: pool}
+	signer := signing.NewEdSigner()
+	var batch pb.SignedTransactions
+	var txs []*types.Transaction
+	for _, nonce := range []uint64{0, 1, 3, 2} {
+		tx, err := mesh.NewSignedTx(nonce, types.Address{1}, 10, 1, 1, signer)
+		r.NoError(err)
+		b, err := types.InterfaceToBytes(tx)
+		r.NoError(err)
+		batch.Txs = append(batch.Txs, &pb.SignedTransaction{Tx: b})
+		txs = append(txs, tx)
+	}
+	batch.Txs = append(batch.Txs, &pb.SignedTransaction{Tx: []byte{1, 2, 3}})
+
+	res, err := s.SubmitTransactions(context.Background(), &batch)
+	r.NoError(err)
+	r.Equal(uint64(3), res.Accepted)
+	r.Len(res.Results, 5)
+	for i, tx := range txs {
+		r.Equal(util.Bytes2Hex(tx.ID().Bytes()), res.Results[i].Id)
+	}
+	r.Empty(res.Results[0].Error)
+	r.Empty(res.Results[1].Error)
+	r.NotEmpty(res.Results[2].Error) // the nonce that follows isn't in the pool yet
+	r.Empty(res.Results[3].Error)
+	r.Empty(res.Results[4].Id)
+	r.NotEmpty(res.Results[4].Error)
+	r.Equal([]*types.Transaction{txs[0], txs[1], txs[3]}, pool.Txs())
+
+	_, err = s.SubmitTransactions(context.Background(), &pb.SignedTransactions{Txs: make([]*pb.SignedTransaction, maxTxBatch+1)})
+	r.Error(err)
+}
+
+func TestSpacemeshGrpcService_ApproveReorg(t *testing.T) {
+	r := require.New(t)
+	refused := types.LayerID(ValidatedLayerID - 5)
+	tx := &TxAPIMock{refusedReorg: &refused}
+	nodeConfig := config2.DefaultConfig()
+	s := SpacemeshGrpcService{Tx: tx, Config: &nodeConfig, admin: true}
+
+	_, err := s.ApproveReorg(context.Background(), &empty.Empty{})
+	r.Equal(errAdminAPIDisabled, err)
+	r.NotNil(tx.refusedReorg)
+
+	nodeConfig.API.AdminAPI = true
+	lyr, err := s.ApproveReorg(context.Background(), &empty.Empty{})
+	r.NoError(err)
+	r.Equal(refused.Uint64(), lyr.Layer)
+	_, ok := tx.RefusedReorg()
+	r.False(ok)
+	_, err = s.ApproveReorg(context.Background(), &empty.Empty{})
+	r.Error(err)
+}
+
+func TestGrpcApi_GetGossipReport(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	report, err := c.GetGossipReport(context.Background(), &empty.Empty{})
+	r.NoError(err)
+	r.Equal(uint64(5), report.Peers)
+	r.Len(report.Protocols, 1)
+	st := report.Protocols[0]
+	r.Equal("newBlock", st.Protocol)
+	r.Equal(3.0, st.DuplicateRatio)
+	r.Equal(0.5, st.MissedRate)
+	r.Len(st.LatencyBuckets, len(gossip.LatencyBuckets))
+	r.Len(st.Latency, len(gossip.LatencyBuckets)+1)
+}
+
+func TestSpacemeshGrpcService_GetPeerStats(t *testing.T) {
+	r := require.New(t)
+	nodeConfig := config2.DefaultConfig()
+	s := SpacemeshGrpcService{Network: &NetworkMock{}, Config: &nodeConfig, admin: true}
+
+	_, err := s.GetPeerStats(context.Background(), &empty.Empty{})
+	r.Equal(errAdminAPIDisabled, err)
+
+	nodeConfig.API.AdminAPI = true
+	res, err := s.GetPeerStats(context.Background(), &empty.Empty{})
+	r.NoError(err)
+	r.Len(res.Stats, 2)
+	r.Equal("/sync/1.0/", res.Stats[0].Protocol)
+	r.Equal(uint64(4000), res.Stats[0].BytesReceived)
+	r.Equal(uint64(50), res.Stats[0].AvgResponseTime)
+	r.Equal(uint64(1), res.Stats[1].Invalid)
+	r.Equal(uint64(0), res.Stats[1].AvgResponseTime)
+}
+
+func TestGrpcApi_GetLayerResults(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	res, err := c.GetLayerResults(context.Background(), &pb.LayerNum{Layer: TxReturnLayer})
+	r.NoError(err)
+	r.Equal(uint64(TxReturnLayer), res.Layer)
+	r.Equal(types.Hash32{1}.String(), res.StateRoot)
+	r.Len(res.Txs, 1)
+	r.Equal(layerTx.ID().Bytes(), res.Txs[0].TxId.Id)
+	r.Equal(pb.TxStatus_CONFIRMED, res.Txs[0].Status)
+	r.Len(res.Rewards, 1)
+	r.Equal(uint64(60), res.Rewards[0].TotalReward)
+	r.Len(res.Accounts, 1)
+	r.Equal(uint64(10), res.Accounts[0].Balance)
+
+	_, err = c.GetLayerResults(context.Background(), &pb.LayerNum{Layer: TxReturnLayer + 1})
+	r.Error(err)
+}
+
+func TestGrpcApi_GetPosAtx(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	res, err := c.GetPosAtx(context.Background(), &empty.Empty{})
+	r.NoError(err)
+	r.Equal(posAtxID.Hash32().String(), res.AtxId)
+	r.Equal(uint64(20), res.Layer)
+	r.Len(res.History, 2)
+	r.Equal(uint64(10), res.History[0].Layer)
+	r.Equal(res.AtxId, res.History[1].AtxId)
+	r.Equal(int64(2), res.History[1].Time)
+}
+
+func TestGrpcApi_GetNodeAtxIds(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	res, err := c.GetNodeAtxIds(context.Background(), &pb.NodeId{Id: "miner"})
+	r.NoError(err)
+	r.Equal([]string{nodeAtxIDs[0].Hash32().String(), nodeAtxIDs[1].Hash32().String()}, res.Ids)
+
+	_, err = c.GetNodeAtxIds(context.Background(), &pb.NodeId{Id: "unknown"})
+	r.Error(err)
+	_, err = c.GetNodeAtxIds(context.Background(), &pb.NodeId{})
+	r.Error(err)
+}
+
+func TestGrpcApi_GetEpochAtxIds(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	res, err := c.GetEpochAtxIds(context.Background(), &pb.EpochAtxsRequest{Epoch: 3})
+	r.NoError(err)
+	r.Equal([]string{nodeAtxIDs[0].Hash32().String(), nodeAtxIDs[1].Hash32().String()}, res.Ids)
+
+	res, err = c.GetEpochAtxIds(context.Background(), &pb.EpochAtxsRequest{Epoch: 3, Limit: 1})
+	r.NoError(err)
+	r.Equal([]string{nodeAtxIDs[0].Hash32().String()}, res.Ids)
+	res, err = c.GetEpochAtxIds(context.Background(), &pb.EpochAtxsRequest{Epoch: 3, After: res.Ids[0], Limit: 1})
+	r.NoError(err)
+	r.Equal([]string{nodeAtxIDs[1].Hash32().String()}, res.Ids)
+	res, err = c.GetEpochAtxIds(context.Background(), &pb.EpochAtxsRequest{Epoch: 3, After: res.Ids[0], Limit: 1})
+	r.NoError(err)
+	r.Empty(res.Ids)
+
+	res, err = c.GetEpochAtxIds(context.Background(), &pb.EpochAtxsRequest{Epoch: 4})
+	r.NoError(err)
+	r.Empty(res.Ids)
+
+	_, err = c.GetEpochAtxIds(context.Background(), &pb.EpochAtxsRequest{Epoch: 3, After: "0x1234"})
+	r.Error(err)
+}
+
+func TestGrpcApi_AtxEvents(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.AtxEvents(ctx, &empty.Empty{})
+	r.NoError(err)
+	for _, id := range nodeAtxIDs {
+		ev, err := stream.Recv()
+		r.NoError(err)
+		r.Equal(id.Hash32().String(), ev.Id)
+	}
+}
+
+func TestGrpcApi_GetEpochBeacon(t *testing.T) {
+	r := require.New(t)
+	shutDown := launchServer(t)
+	defer shutDown()
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	c := pb.NewSpacemeshServiceClient(conn)
+
+	res, err := c.GetEpochBeacon(context.Background(), &pb.EpochNum{Epoch: 3})
+	r.NoError(err)
+	r.Equal(uint64(3), res.Epoch)
+	r.Equal(util.Bytes2Hex(epochBeacon), res.Beacon)
+
+	_, err = c.GetEpochBeacon(context.Background(), &pb.EpochNum{Epoch: 4})
+	r.Error(err)
+}
+
 func TestJsonApi(t *testing.T) {
 	shutDown := launchServer(t)
 
@@ -292,7 +863,7 @@ func TestJsonWalletApi(t *testing.T) {
 	ap.balances[addr] = big.NewInt(100)
 
 	// generate request payload (api input params)
-	payload := marshalProto(t, &pb.AccountId{Address: util.Bytes2Hex(addr.Bytes())})
+	payload := marshalProto(t, &pb.AccountId{Address: addr.Bech32(types.DefaultAddressHRP)})
 
 	respBody, respStatus := callEndpoint(t, "v1/nonce", payload)
 	r.Equal(http.StatusOK, respStatus)
@@ -302,6 +873,16 @@ func TestJsonWalletApi(t *testing.T) {
 	r.Equal(http.StatusOK, respStatus)
 	assertSimpleMessage(t, respBody, "100")
 
+	// the verified balance is read from the state of the verified layer
+	ap.layerBalances[ValidatedLayerID-2] = map[types.Address]uint64{addr: 70}
+	respBody, respStatus = callEndpoint(t, "v1/accountbalances", payload)
+	r.Equal(http.StatusOK, respStatus)
+	var balances pb.AccountBalances
+	r.NoError(jsonpb.UnmarshalString(respBody, &balances))
+	r.Equal("100", balances.Optimistic)
+	r.Equal("70", balances.Verified)
+	r.Equal(uint64(ValidatedLayerID-2), balances.VerifiedLayer)
+
 	// Test submit transaction
 	submitTx(t, genTx(t))
 
@@ -314,7 +895,7 @@ func TestJsonWalletApi(t *testing.T) {
 	r.Equal("{\"error\":\""+msg+"\",\"message\":\""+msg+"\",\"code\":2}", respBody)
 
 	// test start mining
-	initPostRequest := pb.InitPost{Coinbase: "0x1234", LogicalDrive: "/tmp/aaa", CommitmentSize: 2048}
+	initPostRequest := pb.InitPost{Coinbase: types.HexToAddress("0x1234").Bech32(types.DefaultAddressHRP), LogicalDrive: "/tmp/aaa", CommitmentSize: 2048}
 	respBody, respStatus = callEndpoint(t, "v1/startmining", marshalProto(t, &initPostRequest))
 	r.Equal(http.StatusOK, respStatus)
 	assertSimpleMessage(t, respBody, "ok")
@@ -328,7 +909,7 @@ func TestJsonWalletApi(t *testing.T) {
 
 	r.Equal(int32(miningStatus), stats.Status)
 	r.Equal("/tmp", stats.DataDir)
-	r.Equal("123456", stats.Coinbase)
+	r.Equal(types.HexToAddress("123456").Bech32(types.DefaultAddressHRP), stats.Coinbase)
 	r.Equal(uint64(remainingBytes), stats.RemainingBytes)
 
 	// test get node status
@@ -345,14 +926,46 @@ func TestJsonWalletApi(t *testing.T) {
 	r.Equal(uint64(10), nodeStatus.SyncedLayer)
 	r.Equal(uint64(1), nodeStatus.CurrentLayer)
 	r.Equal(uint64(8), nodeStatus.VerifiedLayer)
+	r.Zero(nodeStatus.MinGasPrice)
 
 	// test get genesisTime
 	respBody, respStatus = callEndpoint(t, "v1/genesis", "")
 	r.Equal(http.StatusOK, respStatus)
 	assertSimpleMessage(t, respBody, genTime.t.Format(time.RFC3339))
 
+	// test conversions between layers, epochs and time (3 layers per epoch, layer 1 starts at genesis)
+	var layerTime pb.LayerTime
+	respBody, respStatus = callEndpoint(t, "v1/layertime", marshalProto(t, &pb.LayerNum{Layer: 4}))
+	r.Equal(http.StatusOK, respStatus)
+	r.NoError(jsonpb.UnmarshalString(respBody, &layerTime))
+	r.Equal(uint64(4), layerTime.Layer)
+	r.Equal(uint64(1), layerTime.Epoch)
+	r.Equal(int64(genTimeUnix+3*layerDuration), layerTime.Start)
+	r.Equal(int64(genTimeUnix+4*layerDuration), layerTime.End)
+
+	layerTime = pb.LayerTime{}
+	respBody, respStatus = callEndpoint(t, "v1/layerattime", marshalProto(t, &pb.UnixTime{Value: genTimeUnix + 35}))
+	r.Equal(http.StatusOK, respStatus)
+	r.NoError(jsonpb.UnmarshalString(respBody, &layerTime))
+	r.Equal(uint64(4), layerTime.Layer)
+
+	layerTime = pb.LayerTime{}
+	respBody, respStatus = callEndpoint(t, "v1/layerattime", marshalProto(t, &pb.UnixTime{Value: genTimeUnix - 5}))
+	r.Equal(http.StatusOK, respStatus)
+	r.NoError(jsonpb.UnmarshalString(respBody, &layerTime))
+	r.Zero(layerTime.Layer)
+	r.Equal(int64(genTimeUnix), layerTime.End)
+
+	var epochTime pb.EpochTime
+	respBody, respStatus = callEndpoint(t, "v1/epochtime", marshalProto(t, &pb.EpochNum{Epoch: 2}))
+	r.Equal(http.StatusOK, respStatus)
+	r.NoError(jsonpb.UnmarshalString(respBody, &epochTime))
+	r.Equal(uint64(6), epochTime.FirstLayer)
+	r.Equal(int64(genTimeUnix+5*layerDuration), epochTime.Start)
+	r.Equal(int64(genTimeUnix+8*layerDuration), epochTime.End)
+
 	// test get rewards per account
-	payload = marshalProto(t, &pb.AccountId{Address: util.Bytes2Hex(addr.Bytes())})
+	payload = marshalProto(t, &pb.AccountId{Address: addr.Bech32(types.DefaultAddressHRP)})
 	respBody, respStatus = callEndpoint(t, "v1/accountrewards", payload)
 	r.Equal(http.StatusOK, respStatus)
 
@@ -383,7 +996,7 @@ func TestJsonWalletApi(t *testing.T) {
 	txAPI.returnTx[meshTxOut.ID()] = meshTxOut
 
 	// test with start layer that gets the mesh txs
-	payload = marshalProto(t, &pb.GetTxsSinceLayer{Account: &pb.AccountId{Address: util.Bytes2Hex(addr.Bytes())}, StartLayer: TxReturnLayer})
+	payload = marshalProto(t, &pb.GetTxsSinceLayer{Account: &pb.AccountId{Address: addr.Bech32(types.DefaultAddressHRP)}, StartLayer: TxReturnLayer})
 	respBody, respStatus = callEndpoint(t, "v1/accounttxs", payload)
 	r.Equal(http.StatusOK, respStatus)
 
@@ -398,7 +1011,7 @@ func TestJsonWalletApi(t *testing.T) {
 	}, accounts.Txs)
 
 	// test with start layer that doesn't get the mesh txs (mempool txs return anyway)
-	payload = marshalProto(t, &pb.GetTxsSinceLayer{Account: &pb.AccountId{Address: util.Bytes2Hex(addr.Bytes())}, StartLayer: TxReturnLayer + 1})
+	payload = marshalProto(t, &pb.GetTxsSinceLayer{Account: &pb.AccountId{Address: addr.Bech32(types.DefaultAddressHRP)}, StartLayer: TxReturnLayer + 1})
 	respBody, respStatus = callEndpoint(t, "v1/accounttxs", payload)
 	r.Equal(http.StatusOK, respStatus)
 
@@ -410,7 +1023,7 @@ func TestJsonWalletApi(t *testing.T) {
 	}, accounts.Txs)
 
 	// test get txs per account with wrong layer error
-	payload = marshalProto(t, &pb.GetTxsSinceLayer{Account: &pb.AccountId{Address: util.Bytes2Hex(addr.Bytes())}, StartLayer: 11})
+	payload = marshalProto(t, &pb.GetTxsSinceLayer{Account: &pb.AccountId{Address: addr.Bech32(types.DefaultAddressHRP)}, StartLayer: 11})
 	respBody, respStatus = callEndpoint(t, "v1/accounttxs", payload)
 	r.Equal(http.StatusInternalServerError, respStatus)
 	const ErrInvalidStartLayer = "{\"error\":\"invalid start layer\",\"message\":\"invalid start layer\",\"code\":2}"
@@ -465,7 +1078,7 @@ func TestSpacemeshGrpcService_GetTransaction(t *testing.T) {
 	respTx3 := getTx(t, tx3)
 
 	assertTx(t, respTx1, tx1, "PENDING", 0, 0)
-	assertTx(t, respTx2, tx2, "CONFIRMED", 1, genTimeUnix+layerDuration*2)
+	assertTx(t, respTx2, tx2, "CONFIRMED", 1, genTimeUnix+layerDuration)
 	assertTx(t, respTx3, tx3, "REJECTED", 0, 0)
 
 	shutDown()
@@ -487,8 +1100,8 @@ func assertTx(t *testing.T, respTx pb.Transaction, tx *types.Transaction, status
 	r.Equal(tx.ID().Bytes(), respTx.TxId.Id)
 	r.Equal(tx.Fee, respTx.Fee)
 	r.Equal(tx.Amount, respTx.Amount)
-	r.Equal(util.Bytes2Hex(tx.Recipient.Bytes()), respTx.Receiver.Address)
-	r.Equal(util.Bytes2Hex(tx.Origin().Bytes()), respTx.Sender.Address)
+	r.Equal(tx.Recipient.Bech32(types.DefaultAddressHRP), respTx.Receiver.Address)
+	r.Equal(tx.Origin().Bech32(types.DefaultAddressHRP), respTx.Sender.Address)
 	r.Equal(layerID, respTx.LayerId)
 	r.Equal(status, respTx.Status.String())
 	r.Equal(timestamp, respTx.Timestamp)
@@ -525,7 +1138,7 @@ func (SyncerMock) IsSynced() bool { return false }
 func launchServer(t *testing.T) func() {
 	networkMock.broadcasted = []byte{0x00}
 	defaultConfig := config2.DefaultConfig()
-	grpcService := NewGrpcService(cfg.GrpcServerPort, &networkMock, ap, txAPI, txMempool, &mining, &oracle, &genTime, PostMock{}, layerDuration, &SyncerMock{}, &defaultConfig, nil)
+	grpcService := NewGrpcService(cfg.GrpcServerPort, &networkMock, ap, txAPI, txMempool, &mining, &oracle, &genTime, PostMock{}, layerDuration, &SyncerMock{}, &defaultConfig, nil, nil, LayerResultsMock{layerTx}, PosAtxMock{}, NodeAtxsMock{}, BeaconMock{}, nil, nil)
 	jsonService := NewJSONHTTPServer(cfg.JSONServerPort, cfg.GrpcServerPort)
 	// start gRPC and json server
 	grpcService.StartService()
@@ -567,7 +1180,7 @@ func TestJsonWalletApi_Errors(t *testing.T) {
 
 	// generate request payload (api input params)
 	addrBytes := []byte{0x02} // address that does not exist
-	payload := marshalProto(t, &pb.AccountId{Address: util.Bytes2Hex(addrBytes)})
+	payload := marshalProto(t, &pb.AccountId{Address: types.BytesToAddress(addrBytes).Bech32(types.DefaultAddressHRP)})
 	const expectedResponse = "{\"error\":\"account does not exist\",\"message\":\"account does not exist\",\"code\":2}"
 
 	respBody, respStatus := callEndpoint(t, "v1/nonce", payload)
@@ -578,6 +1191,14 @@ func TestJsonWalletApi_Errors(t *testing.T) {
 	require.Equal(t, http.StatusInternalServerError, respStatus) // TODO: Should we change it to err 400 somehow?
 	require.Equal(t, expectedResponse, respBody)
 
+	// only bech32 addresses are accepted
+	hexPayload := marshalProto(t, &pb.AccountId{Address: util.Bytes2Hex(addrBytes)})
+	for _, endpoint := range []string{"v1/nonce", "v1/balance", "v1/accountrewards", "v1/setawardsaddr"} {
+		respBody, respStatus = callEndpoint(t, endpoint, hexPayload)
+		require.Equal(t, http.StatusInternalServerError, respStatus, endpoint)
+		require.Contains(t, respBody, "invalid bech32 address", endpoint)
+	}
+
 	// stop the services
 	shutDown()
 }
@@ -662,3 +1283,154 @@ func TestApproveAPIGossipMessages(t *testing.T) {
 	require.Equal(t, res.Protocol(), apiGossipProtocol)
 	cancel()
 }
+
+func TestSpacemeshGrpcService_GetNextAtx(t *testing.T) {
+	r := require.New(t)
+	nodeConfig := config2.DefaultConfig()
+	s := SpacemeshGrpcService{Mining: &MiningAPIMock{}, Config: &nodeConfig, admin: true}
+	_, err := s.GetNextAtx(context.Background(), &empty.Empty{})
+	r.Equal(errAdminAPIDisabled, err)
+
+	nodeConfig.API.AdminAPI = true
+	res, err := s.GetNextAtx(context.Background(), &empty.Empty{})
+	r.NoError(err)
+	r.Equal(uint64(3), res.Sequence)
+	r.Equal(uint64(25), res.PubLayer)
+	r.Equal(uint64(3), res.TargetEpoch)
+	r.Equal(uint32(10), res.ActiveSetSize)
+	r.Equal(uint32(2), res.ViewSize)
+	r.Equal(types.HexToAddress("0x1234").Bech32(types.DefaultAddressHRP), res.Coinbase)
+	r.Equal(uint64(512), res.Size)
+	r.Equal(int64(1000), res.Deadline)
+	r.False(res.InFlight)
+}
+
+type BlockProducerMock struct {
+	layers []types.LayerID
+}
+
+func (m *BlockProducerMock) DryRun(layer types.LayerID) *miner.BlockDryRun {
+	m.layers = append(m.layers, layer)
+	if layer == 7 {
+		return &miner.BlockDryRun{Layer: layer, Synced: true, Reason: "the node is not eligible for blocks in the layer"}
+	}
+	b := &types.MiniBlock{
+		BlockHeader: types.BlockHeader{LayerIndex: layer, BlockVotes: []types.BlockID{{1}, {2}}},
+		TxIDs:       []types.TransactionID{{3}},
+		ATXIDs:      []types.ATXID{{4}},
+	}
+	return &miner.BlockDryRun{Layer: layer, Synced: true, Eligibility: 2, Block: b, BlockID: types.BlockID{5},
+		ViewEdges: []types.BlockID{{6}}, Size: 300}
+}
+
+func TestSpacemeshGrpcService_GetBlockDryRun(t *testing.T) {
+	r := require.New(t)
+	blocks := &BlockProducerMock{}
+	nodeConfig := config2.DefaultConfig()
+	s := SpacemeshGrpcService{Blocks: blocks, GenTime: GenesisTimeMock{}, Config: &nodeConfig, admin: true}
+	_, err := s.GetBlockDryRun(context.Background(), &pb.LayerNum{})
+	r.Equal(errAdminAPIDisabled, err)
+
+	nodeConfig.API.AdminAPI = true
+	res, err := s.GetBlockDryRun(context.Background(), &pb.LayerNum{})
+	r.NoError(err)
+	r.Equal(uint64(1), res.Layer) // the current layer
+	r.Empty(res.Reason)
+	r.Equal(uint32(2), res.Eligibility)
+	id := types.BlockID{5}
+	r.Equal(id[:], res.BlockId)
+	r.Len(res.TxIds, 1)
+	r.Len(res.AtxIds, 1)
+	r.Len(res.Votes, 2)
+	r.Equal(uint32(1), res.ViewSize)
+	r.Equal(uint64(300), res.Size)
+
+	res, err = s.GetBlockDryRun(context.Background(), &pb.LayerNum{Layer: 7})
+	r.NoError(err)
+	r.Equal("the node is not eligible for blocks in the layer", res.Reason)
+	r.Empty(res.BlockId)
+	r.Equal([]types.LayerID{1, 7}, blocks.layers)
+}
+
+func TestSpacemeshGrpcService_GetBlockTombstone(t *testing.T) {
+	r := require.New(t)
+	invalid, unknown := types.BlockID{1, 2, 3}, types.BlockID{4}
+	tx := &TxAPIMock{tombstones: map[types.BlockID]*mesh.Tombstone{
+		invalid: {Block: invalid, Layer: 7, Reason: mesh.InvalidContents, Detail: "block atxs don't match the referenced ones"},
+	}}
+	s := SpacemeshGrpcService{Tx: tx}
+
+	res, err := s.GetBlockTombstone(context.Background(), &pb.BlockId{Id: invalid[:]})
+	r.NoError(err)
+	r.Equal(invalid[:], res.Id)
+	r.Equal(uint64(7), res.Layer)
+	r.Equal(uint32(mesh.InvalidContents), res.ReasonCode)
+	r.Equal("invalid contents", res.Reason)
+	r.Equal("block atxs don't match the referenced ones", res.Detail)
+
+	_, err = s.GetBlockTombstone(context.Background(), &pb.BlockId{Id: unknown[:]})
+	r.Error(err)
+	_, err = s.GetBlockTombstone(context.Background(), &pb.BlockId{Id: []byte{1, 2}})
+	r.Error(err)
+}
+
+type backupMock struct {
+	dirs []string
+}
+
+func (b *backupMock) Backup(dir string) (*backup.Manifest, error) {
+	b.dirs = append(b.dirs, dir)
+	return &backup.Manifest{Layer: 7}, nil
+}
+
+func TestBackup_AdminAPI(t *testing.T) {
+	r := require.New(t)
+	nodeConfig := config2.DefaultConfig()
+	backups := &backupMock{}
+	s := SpacemeshGrpcService{Backups: backups, Config: &nodeConfig, admin: true}
+
+	// backups write to the node's disk, they're only taken through the admin api
+	_, err := s.Backup(context.Background(), &pb.SimpleMessage{Value: "backup"})
+	r.Equal(errAdminAPIDisabled, err)
+	r.Empty(backups.dirs)
+
+	nodeConfig.API.AdminAPI = true
+	res, err := s.Backup(context.Background(), &pb.SimpleMessage{Value: "backup"})
+	r.NoError(err)
+	r.Equal([]string{"backup"}, backups.dirs)
+	r.Contains(res.Value, `"layer":7`)
+}
+
+func TestGrpcApi_AdminListener(t *testing.T) {
+	r := require.New(t)
+	nodeConfig := config2.DefaultConfig()
+	nodeConfig.API.AdminAPI = true
+	backups := &backupMock{}
+	s := NewGrpcService(cfg.GrpcServerPort, &networkMock, nil, nil, nil, nil, nil, nil, nil, 0, nil, &nodeConfig, nil, backups, nil, nil, nil, nil, nil, nil)
+	s.StartService()
+	defer func() {
+		r.NoError(s.Close())
+	}()
+	time.Sleep(time.Second) // wait for the servers to be ready
+
+	conn, err := grpc.Dial("localhost:"+strconv.Itoa(cfg.GrpcServerPort), grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(conn.Close())
+	}()
+	adminConn, err := grpc.Dial(nodeConfig.API.AdminListener, grpc.WithInsecure())
+	r.NoError(err)
+	defer func() {
+		r.NoError(adminConn.Close())
+	}()
+
+	// the admin api isn't served with the other endpoints
+	_, err = pb.NewSpacemeshServiceClient(conn).Backup(context.Background(), &pb.SimpleMessage{Value: "backup"})
+	r.Error(err)
+	r.Contains(err.Error(), errNotAdminListener.Error())
+	r.Empty(backups.dirs)
+
+	_, err = pb.NewSpacemeshServiceClient(adminConn).Backup(context.Background(), &pb.SimpleMessage{Value: "backup"})
+	r.NoError(err)
+	r.Equal([]string{"backup"}, backups.dirs)
+}
@@ -6,6 +6,10 @@ const (
 	defaultGRPCServerPort  = 9091
 	defaultStartJSONServer = false
 	defaultJSONServerPort  = 9090
+	defaultAdminAPI        = false
+	defaultAdminListener   = "localhost:9093"
+
+	defaultLayerResultsCache = 50
 )
 
 // Config defines the api config params
@@ -14,6 +18,13 @@ type Config struct {
 	GrpcServerPort  int  `mapstructure:"grpc-port"`
 	StartJSONServer bool `mapstructure:"json-server"`
 	JSONServerPort  int  `mapstructure:"json-port"`
+	// AdminAPI enables the api endpoints that change the state of the node, such as mempool eviction
+	AdminAPI bool `mapstructure:"admin-api"`
+	// AdminListener is the address the admin api is served on, apart from the other endpoints. It's bound to localhost
+	// by default, so that only local operators can reach it
+	AdminListener string `mapstructure:"admin-listener"`
+	// LayerResultsCache is the number of latest layers whose execution results are kept in memory for the api
+	LayerResultsCache int `mapstructure:"layer-results-cache"`
 }
 
 func init() {
@@ -27,5 +38,9 @@ func DefaultConfig() Config {
 		GrpcServerPort:  defaultGRPCServerPort,
 		StartJSONServer: defaultStartJSONServer,
 		JSONServerPort:  defaultJSONServerPort,
+		AdminAPI:        defaultAdminAPI,
+		AdminListener:   defaultAdminListener,
+
+		LayerResultsCache: defaultLayerResultsCache,
 	}
 }
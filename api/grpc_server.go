@@ -3,9 +3,12 @@ package api
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/empty"
@@ -19,8 +22,11 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/miner"
+	"github.com/spacemeshos/go-spacemesh/p2p/gossip"
 	"github.com/spacemeshos/go-spacemesh/p2p/peers"
 )
 
@@ -32,6 +38,7 @@ type PeerCounter interface {
 // SpacemeshGrpcService is a grpc server providing the Spacemesh api
 type SpacemeshGrpcService struct {
 	Server        *grpc.Server
+	AdminServer   *grpc.Server // serves the admin api on its own listener
 	Port          uint
 	StateAPI      StateAPI         // State DB
 	Network       NetworkAPI       // P2P Swarm
@@ -46,6 +53,15 @@ type SpacemeshGrpcService struct {
 	Syncer        Syncer
 	Config        *config.Config
 	Logging       LoggingAPI
+	Backups       BackupAPI
+	LayerResults  LayerResultsAPI
+	PosAtxs       PosAtxAPI
+	NodeAtxs      NodeAtxsAPI
+	Beacons       BeaconAPI
+	Versions      VersionAPI
+	Blocks        BlockProducerAPI
+
+	admin bool // the service is served by the admin server, the only one that serves the admin api
 }
 
 var _ pb.SpacemeshServiceServer = (*SpacemeshGrpcService)(nil)
@@ -88,18 +104,14 @@ func (s SpacemeshGrpcService) GetTransaction(ctx context.Context, txID *pb.Trans
 	var layerID, timestamp uint64
 	if layerApplied != nil {
 		layerID = uint64(*layerApplied)
-		timestamp = uint64(s.GenTime.GetGenesisTime().Add(s.LayerDuration * time.Duration(layerID+1)).Unix())
+		timestamp = uint64(s.GenTime.LayerToTime(*layerApplied + 1).Unix())
 		// We use layerID + 1 so the timestamp is the end of the layer.
 	}
 
 	return &pb.Transaction{
-		TxId: txID,
-		Sender: &pb.AccountId{
-			Address: util.Bytes2Hex(tx.Origin().Bytes()),
-		},
-		Receiver: &pb.AccountId{
-			Address: util.Bytes2Hex(tx.Recipient.Bytes()),
-		},
+		TxId:      txID,
+		Sender:    s.accountID(tx.Origin()),
+		Receiver:  s.accountID(tx.Recipient),
 		Amount:    tx.Amount,
 		Fee:       tx.Fee,
 		Status:    status,
@@ -129,7 +141,10 @@ func (s SpacemeshGrpcService) getProjection(addr types.Address) (nonce, balance
 // transactions coming INTO the given account (from mempool or unapplied blocks) are NOT counted.
 func (s SpacemeshGrpcService) GetBalance(ctx context.Context, in *pb.AccountId) (*pb.SimpleMessage, error) {
 	log.Debug("GRPC GetBalance msg")
-	addr := types.HexToAddress(in.Address)
+	addr, err := s.parseAddress(in.Address)
+	if err != nil {
+		return nil, err
+	}
 	log.Debug("GRPC GetBalance for address %x (len %v)", addr, len(addr))
 	if s.StateAPI.Exist(addr) != true {
 		log.Error("GRPC GetBalance returned error msg: account does not exist, address %x", addr)
@@ -145,11 +160,40 @@ func (s SpacemeshGrpcService) GetBalance(ctx context.Context, in *pb.AccountId)
 	return msg, nil
 }
 
+// GetAccountBalances returns the account balance in the latest state, which includes layers applied optimistically
+// from hare results, and in the state of the latest layer verified by the tortoise. Unlike GetBalance, the balances
+// don't include unapplied transactions.
+func (s SpacemeshGrpcService) GetAccountBalances(ctx context.Context, in *pb.AccountId) (*pb.AccountBalances, error) {
+	log.Debug("GRPC GetAccountBalances msg")
+	addr, err := s.parseAddress(in.Address)
+	if err != nil {
+		return nil, err
+	}
+	if s.StateAPI.Exist(addr) != true {
+		log.Error("GRPC GetAccountBalances returned error msg: account does not exist, address %x", addr)
+		return nil, fmt.Errorf("account does not exist")
+	}
+
+	verifiedLayer := s.Tx.VerifiedLayer()
+	verified, err := s.StateAPI.GetLayerBalance(verifiedLayer, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AccountBalances{
+		Optimistic:    strconv.FormatUint(s.StateAPI.GetBalance(addr), 10),
+		Verified:      strconv.FormatUint(verified, 10),
+		VerifiedLayer: verifiedLayer.Uint64(),
+	}, nil
+}
+
 // GetNonce returns the current account nonce for the provided account ID. The nonce is based on the global state and
 // all known transactions in unapplied blocks and the mempool.
 func (s SpacemeshGrpcService) GetNonce(ctx context.Context, in *pb.AccountId) (*pb.SimpleMessage, error) {
 	log.Info("GRPC GetNonce msg")
-	addr := types.HexToAddress(in.Address)
+	addr, err := s.parseAddress(in.Address)
+	if err != nil {
+		return nil, err
+	}
 
 	if s.StateAPI.Exist(addr) != true {
 		log.Error("GRPC GetNonce got error msg: account does not exist, %v", addr)
@@ -189,6 +233,10 @@ func (s SpacemeshGrpcService) SubmitTransaction(ctx context.Context, in *pb.Sign
 		log.With().Error("tx failed nonce and balance check", log.Err(err))
 		return nil, err
 	}
+	if err := s.TxMempool.CheckGasPrice(tx); err != nil {
+		log.With().Error("tx failed gas price check", log.Err(err))
+		return nil, err
+	}
 	log.Info("GRPC SubmitTransaction BROADCAST tx. address %x (len %v), gas limit %v, fee %v id %v nonce %v",
 		tx.Recipient, len(tx.Recipient), tx.GasLimit, tx.Fee, tx.ID().ShortString(), tx.AccountNonce)
 	go s.Network.Broadcast(miner.IncomingTxProtocol, in.Tx)
@@ -196,6 +244,63 @@ func (s SpacemeshGrpcService) SubmitTransaction(ctx context.Context, in *pb.Sign
 	return &pb.TxConfirmation{Value: "ok", Id: hex.EncodeToString(tx.ID().Bytes())}, nil
 }
 
+// maxTxBatch is the maximal number of transactions submitted in a single SubmitTransactions call.
+const maxTxBatch = 1000
+
+// SubmitTransactions validates a batch of transactions in order, puts the valid ones in the mempool and transmits them
+// via gossip network. Each transaction is validated against the projected state that includes the batch's earlier
+// transactions, so a batch can hold consecutive nonces of an account. Invalid transactions don't fail the batch, the
+// result of each transaction tells whether it was accepted.
+func (s SpacemeshGrpcService) SubmitTransactions(ctx context.Context, in *pb.SignedTransactions) (*pb.TxResults, error) {
+	log.Info("GRPC SubmitTransactions msg")
+	if len(in.Txs) > maxTxBatch {
+		return nil, fmt.Errorf("batch of %d transactions exceeds the maximum of %d", len(in.Txs), maxTxBatch)
+	}
+	res := &pb.TxResults{Results: make([]*pb.TxResult, len(in.Txs))}
+	txs := make([]*types.Transaction, 0, len(in.Txs))
+	batchIdx := make([]int, 0, len(in.Txs)) // the index in the batch of each decoded transaction
+	for i, signed := range in.Txs {
+		res.Results[i] = &pb.TxResult{}
+		tx, err := types.BytesToTransaction(signed.Tx)
+		if err == nil {
+			err = tx.CalcAndSetOrigin()
+		}
+		if err != nil {
+			res.Results[i].Error = err.Error()
+			continue
+		}
+		res.Results[i].Id = hex.EncodeToString(tx.ID().Bytes())
+		txs = append(txs, tx)
+		batchIdx = append(batchIdx, i)
+	}
+	errs := s.TxMempool.PutBatch(txs, func(tx *types.Transaction) error {
+		if !s.Tx.AddressExists(tx.Origin()) {
+			return fmt.Errorf("transaction origin (%v) not found in global state", tx.Origin().Short())
+		}
+		return s.Tx.ValidateNonceAndBalance(tx)
+	})
+	accepted := make([][]byte, 0, len(txs))
+	for j, err := range errs {
+		i := batchIdx[j]
+		if err != nil {
+			res.Results[i].Error = err.Error()
+			continue
+		}
+		res.Accepted++
+		accepted = append(accepted, in.Txs[i].Tx)
+	}
+	go func() {
+		// in order, so that peers receive consecutive nonces of an account in order
+		for _, tx := range accepted {
+			if err := s.Network.Broadcast(miner.IncomingTxProtocol, tx); err != nil {
+				log.Error("failed to broadcast transaction: %v", err)
+			}
+		}
+	}()
+	log.Info("GRPC SubmitTransactions accepted %v of %v transactions", res.Accepted, len(in.Txs))
+	return res, nil
+}
+
 // P2P API
 
 // Broadcast broadcasts message to gossip network
@@ -226,6 +331,7 @@ func (s SpacemeshGrpcService) BroadcastPoet(ctx context.Context, in *pb.BinaryMe
 func (s SpacemeshGrpcService) Close() error {
 	log.Debug("Stopping grpc service...")
 	s.Server.Stop()
+	s.AdminServer.Stop()
 	log.Debug("grpc service stopped...")
 	return nil
 }
@@ -247,11 +353,16 @@ type TxAPI interface {
 	GetTransaction(id types.TransactionID) (*types.Transaction, error)
 	GetProjection(addr types.Address, prevNonce, prevBalance uint64) (nonce, balance uint64, err error)
 	LatestLayerInState() types.LayerID
+	VerifiedLayer() types.LayerID
 	GetStateRoot() types.Hash32
+	FinalizedLayer() types.LayerID
+	RefusedReorg() (types.LayerID, bool)
+	ApproveReorg() (types.LayerID, error)
+	GetTombstone(id types.BlockID) (*mesh.Tombstone, error)
 }
 
 // NewGrpcService create a new grpc service using config data.
-func NewGrpcService(port int, net NetworkAPI, state StateAPI, tx TxAPI, txMempool *miner.TxMempool, mining MiningAPI, oracle OracleAPI, genTime GenesisTimeAPI, post PostAPI, layerDurationSec int, syncer Syncer, cfg *config.Config, logging LoggingAPI) *SpacemeshGrpcService {
+func NewGrpcService(port int, net NetworkAPI, state StateAPI, tx TxAPI, txMempool *miner.TxMempool, mining MiningAPI, oracle OracleAPI, genTime GenesisTimeAPI, post PostAPI, layerDurationSec int, syncer Syncer, cfg *config.Config, logging LoggingAPI, backups BackupAPI, layerResults LayerResultsAPI, posAtxs PosAtxAPI, nodeAtxs NodeAtxsAPI, beacons BeaconAPI, versions VersionAPI, blocks BlockProducerAPI) *SpacemeshGrpcService {
 	options := []grpc.ServerOption{
 		// XXX: this is done to prevent routers from cleaning up our connections (e.g aws load balances..)
 		// TODO: these parameters work for now but we might need to revisit or add them as configuration
@@ -264,9 +375,9 @@ func NewGrpcService(port int, net NetworkAPI, state StateAPI, tx TxAPI, txMempoo
 			Timeout:               time.Minute * 3,
 		}),
 	}
-	server := grpc.NewServer(options...)
 	return &SpacemeshGrpcService{
-		Server:        server,
+		Server:        grpc.NewServer(options...),
+		AdminServer:   grpc.NewServer(options...),
 		Port:          uint(port),
 		StateAPI:      state,
 		Network:       net,
@@ -281,12 +392,41 @@ func NewGrpcService(port int, net NetworkAPI, state StateAPI, tx TxAPI, txMempoo
 		Syncer:        syncer,
 		Config:        cfg,
 		Logging:       logging,
+		Backups:       backups,
+		LayerResults:  layerResults,
+		PosAtxs:       posAtxs,
+		NodeAtxs:      nodeAtxs,
+		Beacons:       beacons,
+		Versions:      versions,
+		Blocks:        blocks,
+	}
+}
+
+// addressHRP returns the human readable part of the bech32 addresses of the node's network.
+func (s SpacemeshGrpcService) addressHRP() string {
+	if s.Config == nil {
+		return types.DefaultAddressHRP
 	}
+	return s.Config.AddressHRP
+}
+
+// parseAddress decodes an account address given to the API, only the bech32 addresses of the node's network are
+// accepted.
+func (s SpacemeshGrpcService) parseAddress(address string) (types.Address, error) {
+	return types.Bech32ToAddress(address, s.addressHRP())
 }
 
-// StartService starts the grpc service.
+// accountID returns the API account id of addr, which holds its bech32 form.
+func (s SpacemeshGrpcService) accountID(addr types.Address) *pb.AccountId {
+	return &pb.AccountId{Address: addr.Bech32(s.addressHRP())}
+}
+
+// StartService starts the grpc service, and the admin api on its own listener when it's enabled.
 func (s SpacemeshGrpcService) StartService() {
 	go s.startServiceInternal()
+	if s.Config != nil && s.Config.API.AdminAPI {
+		go s.startAdminService()
+	}
 }
 
 // This is a blocking method designed to be called using a go routine
@@ -313,13 +453,36 @@ func (s SpacemeshGrpcService) startServiceInternal() {
 
 }
 
+// startAdminService serves the admin api on the admin listener, it blocks until the admin server is stopped. The other
+// endpoints are served there too, so that operators need only one connection.
+func (s SpacemeshGrpcService) startAdminService() {
+	lis, err := net.Listen("tcp", s.Config.API.AdminListener)
+	if err != nil {
+		log.Error("failed to listen for the admin api: %v", err)
+		return
+	}
+
+	admin := s
+	admin.admin = true
+	pb.RegisterSpacemeshServiceServer(s.AdminServer, admin)
+
+	log.Info("grpc admin API listening on %v", s.Config.API.AdminListener)
+
+	if err := s.AdminServer.Serve(lis); err != nil {
+		log.Error("grpc admin api stopped serving: %v", err)
+	}
+}
+
 // StartMining start post init followed by publication of atxs and blocks
 func (s SpacemeshGrpcService) StartMining(ctx context.Context, message *pb.InitPost) (*pb.SimpleMessage, error) {
 	log.Info("GRPC StartMining msg")
-	addr, err := types.StringToAddress(message.Coinbase)
+	addr, err := s.parseAddress(message.Coinbase)
 	if err != nil {
 		return nil, err
 	}
+	if s.Mining == nil {
+		return nil, fmt.Errorf("mining is not run by this node")
+	}
 	err = s.Mining.StartPost(addr, message.LogicalDrive, message.CommitmentSize)
 	if err != nil {
 		return nil, err
@@ -330,8 +493,16 @@ func (s SpacemeshGrpcService) StartMining(ctx context.Context, message *pb.InitP
 // SetAwardsAddress sets the award address for which this miner will receive awards
 func (s SpacemeshGrpcService) SetAwardsAddress(ctx context.Context, id *pb.AccountId) (*pb.SimpleMessage, error) {
 	log.Info("GRPC SetAwardsAddress msg")
-	addr := types.HexToAddress(id.Address)
-	s.Mining.SetCoinbaseAccount(addr)
+	addr, err := s.parseAddress(id.Address)
+	if err != nil {
+		return nil, err
+	}
+	if s.Mining == nil {
+		return nil, fmt.Errorf("mining is not run by this node")
+	}
+	if err := s.Mining.SetCoinbaseAccount(addr); err != nil {
+		return nil, err
+	}
 	return &pb.SimpleMessage{Value: "ok"}, nil
 }
 
@@ -339,7 +510,14 @@ func (s SpacemeshGrpcService) SetAwardsAddress(ctx context.Context, id *pb.Accou
 func (s SpacemeshGrpcService) GetMiningStats(ctx context.Context, empty *empty.Empty) (*pb.MiningStats, error) {
 	//todo: we should review if this RPC is necessary
 	log.Info("GRPC GetInitProgress msg")
+	if s.Mining == nil {
+		return nil, fmt.Errorf("mining is not run by this node")
+	}
 	stat, remainingBytes, coinbase, dataDir := s.Mining.MiningStats()
+	coinbase, err := types.HexToBech32(coinbase, s.addressHRP())
+	if err != nil {
+		return nil, err
+	}
 	return &pb.MiningStats{
 		DataDir:        dataDir,
 		Status:         int32(stat),
@@ -351,20 +529,33 @@ func (s SpacemeshGrpcService) GetMiningStats(ctx context.Context, empty *empty.E
 // GetNodeStatus returns a status object providing information about the connected peers, sync status,
 // current and verified layer
 func (s SpacemeshGrpcService) GetNodeStatus(context.Context, *empty.Empty) (*pb.NodeStatus, error) {
-	return &pb.NodeStatus{
-		Peers:         s.PeerCounter.PeerCount(),
-		MinPeers:      uint64(s.Config.P2P.SwarmConfig.RandomConnections),
-		MaxPeers:      uint64(s.Config.P2P.MaxInboundPeers + s.Config.P2P.SwarmConfig.RandomConnections),
-		Synced:        s.Syncer.IsSynced(),
-		SyncedLayer:   s.Tx.LatestLayer().Uint64(),
-		CurrentLayer:  s.GenTime.GetCurrentLayer().Uint64(),
-		VerifiedLayer: s.Tx.LatestLayerInState().Uint64(),
-	}, nil
+	status := &pb.NodeStatus{
+		Peers:          s.PeerCounter.PeerCount(),
+		MinPeers:       uint64(s.Config.P2P.SwarmConfig.RandomConnections),
+		MaxPeers:       uint64(s.Config.P2P.MaxInboundPeers + s.Config.P2P.SwarmConfig.RandomConnections),
+		Synced:         s.Syncer != nil && s.Syncer.IsSynced(),
+		SyncedLayer:    s.Tx.LatestLayer().Uint64(),
+		CurrentLayer:   s.GenTime.GetCurrentLayer().Uint64(),
+		VerifiedLayer:  s.Tx.LatestLayerInState().Uint64(),
+		MinGasPrice:    s.TxMempool.MinGasPrice(),
+		FinalizedLayer: s.Tx.FinalizedLayer().Uint64(),
+	}
+	if lyr, ok := s.Tx.RefusedReorg(); ok {
+		status.RefusedReorgLayer = lyr.Uint64()
+	}
+	if s.Versions != nil {
+		st := s.Versions.Status()
+		status.Version, status.LatestVersion, status.MinVersion, status.Outdated = st.Version, st.Latest, st.MinVersion, st.Outdated
+	}
+	return status, nil
 }
 
 // GetUpcomingAwards returns the id of layers at which this miner will receive rewards
 func (s SpacemeshGrpcService) GetUpcomingAwards(ctx context.Context, empty *empty.Empty) (*pb.EligibleLayers, error) {
 	log.Info("GRPC GetUpcomingAwards msg")
+	if s.Oracle == nil {
+		return nil, fmt.Errorf("blocks are not produced by this node")
+	}
 	layers := s.Oracle.GetEligibleLayers()
 	ly := make([]uint64, 0, len(layers))
 	for _, l := range layers {
@@ -379,9 +570,52 @@ func (s SpacemeshGrpcService) GetGenesisTime(ctx context.Context, empty *empty.E
 	return &pb.SimpleMessage{Value: s.GenTime.GetGenesisTime().Format(time.RFC3339)}, nil
 }
 
+func (s SpacemeshGrpcService) layerTime(layer types.LayerID) *pb.LayerTime {
+	return &pb.LayerTime{
+		Layer: layer.Uint64(),
+		Epoch: uint64(layer.GetEpoch(uint16(s.Config.LayersPerEpoch))),
+		Start: s.GenTime.LayerToTime(layer).Unix(),
+		End:   s.GenTime.LayerToTime(layer + 1).Unix(),
+	}
+}
+
+// GetLayerTime returns the epoch of the given layer and the times at which it starts and ends
+func (s SpacemeshGrpcService) GetLayerTime(ctx context.Context, in *pb.LayerNum) (*pb.LayerTime, error) {
+	log.Debug("GRPC GetLayerTime msg")
+	return s.layerTime(types.LayerID(in.Layer)), nil
+}
+
+// GetLayerAtTime returns the layer that is current at the given unix time, along with its epoch and start and end
+// times. Times before genesis are in layer 0
+func (s SpacemeshGrpcService) GetLayerAtTime(ctx context.Context, in *pb.UnixTime) (*pb.LayerTime, error) {
+	log.Debug("GRPC GetLayerAtTime msg")
+	layer := s.GenTime.TimeToLayer(time.Unix(in.Value, 0))
+	if layer == 0 {
+		return &pb.LayerTime{End: s.GenTime.GetGenesisTime().Unix()}, nil
+	}
+	return s.layerTime(layer), nil
+}
+
+// GetEpochTime returns the first layer of the given epoch and the times at which the epoch starts and ends
+func (s SpacemeshGrpcService) GetEpochTime(ctx context.Context, in *pb.EpochNum) (*pb.EpochTime, error) {
+	log.Debug("GRPC GetEpochTime msg")
+	layersPerEpoch := uint16(s.Config.LayersPerEpoch)
+	epoch := types.EpochID(in.Epoch)
+	firstLayer := epoch.FirstLayer(layersPerEpoch)
+	return &pb.EpochTime{
+		Epoch:      in.Epoch,
+		FirstLayer: firstLayer.Uint64(),
+		Start:      s.GenTime.LayerToTime(firstLayer).Unix(),
+		End:        s.GenTime.LayerToTime((epoch + 1).FirstLayer(layersPerEpoch)).Unix(),
+	}, nil
+}
+
 // ResetPost removed post commitment for this miner
 func (s SpacemeshGrpcService) ResetPost(ctx context.Context, empty *empty.Empty) (*pb.SimpleMessage, error) {
 	log.Info("GRPC ResetPost msg")
+	if s.Mining == nil {
+		return nil, fmt.Errorf("mining is not run by this node")
+	}
 	stat, _, _, _ := s.Mining.MiningStats()
 	if stat == activation.InitInProgress {
 		return nil, fmt.Errorf("cannot reset, init in progress")
@@ -409,7 +643,10 @@ func (s SpacemeshGrpcService) GetAccountTxs(ctx context.Context, txsSinceLayer *
 
 	currentPBase := s.Tx.LatestLayerInState()
 
-	addr := types.HexToAddress(txsSinceLayer.Account.Address)
+	addr, err := s.parseAddress(txsSinceLayer.Account.Address)
+	if err != nil {
+		return nil, err
+	}
 	minLayer := types.LayerID(txsSinceLayer.StartLayer)
 	if minLayer > s.Tx.LatestLayer() {
 		return &pb.AccountTxs{}, fmt.Errorf("invalid start layer")
@@ -444,7 +681,10 @@ func (s SpacemeshGrpcService) getTxIdsFromMesh(minLayer types.LayerID, addr type
 // GetAccountRewards returns the rewards for the provided account
 func (s SpacemeshGrpcService) GetAccountRewards(ctx context.Context, account *pb.AccountId) (*pb.AccountRewards, error) {
 	log.Debug("GRPC GetAccountRewards msg")
-	acc := types.HexToAddress(account.Address)
+	acc, err := s.parseAddress(account.Address)
+	if err != nil {
+		return nil, err
+	}
 
 	rewards, err := s.Tx.GetRewards(acc)
 	if err != nil {
@@ -468,3 +708,533 @@ func (s SpacemeshGrpcService) GetStateRoot(ctx context.Context, empty *empty.Emp
 	log.Info("GRPC GetStateRoot msg")
 	return &pb.SimpleMessage{Value: s.Tx.GetStateRoot().String()}, nil
 }
+
+// Backup writes a consistent backup of the node's databases to the directory in msg, on the node's file system. It
+// returns the backup's manifest as JSON.
+func (s SpacemeshGrpcService) Backup(ctx context.Context, msg *pb.SimpleMessage) (*pb.SimpleMessage, error) {
+	log.Info("GRPC Backup msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	if s.Backups == nil {
+		return nil, fmt.Errorf("backups are not supported by this node")
+	}
+	manifest, err := s.Backups.Backup(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SimpleMessage{Value: string(bytes)}, nil
+}
+
+// GetGossipReport returns the gossip delivery statistics of each protocol and the number of peers.
+func (s SpacemeshGrpcService) GetGossipReport(ctx context.Context, empty *empty.Empty) (*pb.GossipReport, error) {
+	log.Info("GRPC GetGossipReport msg")
+	reporter, ok := s.Network.(GossipReporter)
+	if !ok {
+		return nil, fmt.Errorf("gossip statistics are not supported by this node")
+	}
+	report := reporter.GossipReport()
+	buckets := make([]uint64, 0, len(gossip.LatencyBuckets))
+	for _, b := range gossip.LatencyBuckets {
+		buckets = append(buckets, uint64(b/time.Millisecond))
+	}
+	res := &pb.GossipReport{Peers: uint64(report.Peers)}
+	for _, st := range report.Protocols {
+		res.Protocols = append(res.Protocols, &pb.GossipProtocolStats{
+			Protocol:          st.Protocol,
+			New:               st.New,
+			Duplicate:         st.Duplicate,
+			Invalid:           st.Invalid,
+			Missed:            st.Missed,
+			DuplicateRatio:    st.DuplicateRatio(),
+			MissedRate:        st.MissedRate(),
+			LatencyBuckets:    buckets,
+			Latency:           st.Latency,
+			FirstDeliverers:   uint64(st.FirstDeliverers),
+			TopDelivererShare: st.TopDelivererShare,
+		})
+	}
+	return res, nil
+}
+
+// GetPeerStats returns the statistics of the messages exchanged with each connected peer, by protocol. Admin api.
+func (s SpacemeshGrpcService) GetPeerStats(ctx context.Context, empty *empty.Empty) (*pb.PeerStats, error) {
+	log.Info("GRPC GetPeerStats msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	reporter, ok := s.Network.(PeerStatsReporter)
+	if !ok {
+		return nil, fmt.Errorf("peer statistics are not supported by this node")
+	}
+	res := &pb.PeerStats{}
+	for _, st := range reporter.PeerStats() {
+		res.Stats = append(res.Stats, &pb.PeerProtocolStats{
+			Peer:            st.Peer,
+			Protocol:        st.Protocol,
+			Sent:            st.Sent,
+			Received:        st.Received,
+			BytesSent:       st.BytesSent,
+			BytesReceived:   st.BytesReceived,
+			Invalid:         st.Invalid,
+			Responses:       st.Responses,
+			AvgResponseTime: uint64(st.AvgResponseTime() / time.Millisecond),
+		})
+	}
+	return res, nil
+}
+
+// GetLayerResults returns the execution results of one of the latest layers applied to state: its transactions with
+// their status, the rewards of the layer and the state of the accounts it changed.
+func (s SpacemeshGrpcService) GetLayerResults(ctx context.Context, in *pb.LayerNum) (*pb.LayerResults, error) {
+	log.Info("GRPC GetLayerResults msg")
+	if s.LayerResults == nil {
+		return nil, fmt.Errorf("layer results are not cached by this node")
+	}
+	results, err := s.LayerResults.Get(types.LayerID(in.Layer))
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.LayerResults{Layer: results.Layer.Uint64(), StateRoot: results.StateRoot.String()}
+	for _, r := range results.Txs {
+		status := pb.TxStatus_REJECTED
+		if r.Applied {
+			status = pb.TxStatus_CONFIRMED
+		}
+		res.Txs = append(res.Txs, &pb.Transaction{
+			TxId:     &pb.TransactionId{Id: r.Tx.ID().Bytes()},
+			Sender:   s.accountID(r.Tx.Origin()),
+			Receiver: s.accountID(r.Tx.Recipient),
+			Amount:   r.Tx.Amount,
+			Fee:      r.Tx.Fee,
+			Status:   status,
+			LayerId:  results.Layer.Uint64(),
+		})
+	}
+	for _, r := range results.Rewards {
+		res.Rewards = append(res.Rewards, &pb.LayerReward{
+			Coinbase:            s.accountID(r.Coinbase),
+			TotalReward:         r.TotalReward,
+			LayerRewardEstimate: r.LayerRewardEstimate,
+		})
+	}
+	for _, a := range results.Accounts {
+		res.Accounts = append(res.Accounts, &pb.AccountState{
+			Account: s.accountID(a.Address),
+			Balance: a.Balance,
+			Nonce:   a.Nonce,
+		})
+	}
+	return res, nil
+}
+
+// GetPosAtx returns the atx the node currently considers the best positioning atx, and the latest changes of its
+// selection. It's meant for diagnosing miners that can't find or choose stale positioning atxs.
+func (s SpacemeshGrpcService) GetPosAtx(ctx context.Context, empty *empty.Empty) (*pb.PosAtxInfo, error) {
+	log.Info("GRPC GetPosAtx msg")
+	if s.PosAtxs == nil {
+		return nil, fmt.Errorf("atxs are not processed by this node")
+	}
+	id, layer, err := s.PosAtxs.GetPosAtx()
+	if err != nil {
+		return nil, fmt.Errorf("no positioning atx: %v", err)
+	}
+	history, err := s.PosAtxs.PosAtxHistory()
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.PosAtxInfo{AtxId: id.Hash32().String(), Layer: layer.Uint64()}
+	for _, change := range history {
+		res.History = append(res.History, &pb.PosAtxChange{
+			AtxId: change.AtxID.Hash32().String(),
+			Layer: change.LayerID.Uint64(),
+			Time:  change.Time,
+		})
+	}
+	return res, nil
+}
+
+// GetNodeAtxIds returns the ids of all atxs the node received from a miner, ordered by their sequence number.
+func (s SpacemeshGrpcService) GetNodeAtxIds(ctx context.Context, in *pb.NodeId) (*pb.AtxIds, error) {
+	log.Info("GRPC GetNodeAtxIds msg")
+	if s.NodeAtxs == nil {
+		return nil, fmt.Errorf("atxs are not processed by this node")
+	}
+	if in.Id == "" {
+		return nil, fmt.Errorf("missing node id")
+	}
+	ids, err := s.NodeAtxs.GetNodeAtxIDs(types.NodeID{Key: in.Id})
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.AtxIds{}
+	for _, id := range ids {
+		res.Ids = append(res.Ids, id.Hash32().String())
+	}
+	return res, nil
+}
+
+const (
+	// defaultEpochAtxsLimit is the number of atx ids GetEpochAtxIds returns when the request doesn't limit them
+	defaultEpochAtxsLimit = 1000
+	// maxEpochAtxsLimit bounds the number of atx ids GetEpochAtxIds returns at once
+	maxEpochAtxsLimit = 10000
+)
+
+// GetEpochAtxIds returns a page of the ids of the atxs targeting an epoch, ordered by id. The next page starts after the
+// last id of the page, and a page shorter than the limit is the last one.
+func (s SpacemeshGrpcService) GetEpochAtxIds(ctx context.Context, in *pb.EpochAtxsRequest) (*pb.EpochAtxIds, error) {
+	log.Info("GRPC GetEpochAtxIds msg")
+	if s.NodeAtxs == nil {
+		return nil, fmt.Errorf("atxs are not processed by this node")
+	}
+	var after *types.ATXID
+	if in.After != "" {
+		bts, err := hex.DecodeString(strings.TrimPrefix(in.After, "0x"))
+		if err != nil || len(bts) != types.Hash32Length {
+			return nil, fmt.Errorf("invalid atx id %v", in.After)
+		}
+		id := types.ATXID(types.BytesToHash(bts))
+		after = &id
+	}
+	limit := in.Limit
+	if limit == 0 {
+		limit = defaultEpochAtxsLimit
+	} else if limit > maxEpochAtxsLimit {
+		limit = maxEpochAtxsLimit
+	}
+	ids, err := s.NodeAtxs.EpochAtxIDs(types.EpochID(in.Epoch), after, int(limit))
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.EpochAtxIds{}
+	for _, id := range ids {
+		res.Ids = append(res.Ids, id.Hash32().String())
+	}
+	return res, nil
+}
+
+// AtxEvents streams the ids of the atxs that the node stores until the client cancels. Events are dropped if the client
+// doesn't keep up.
+func (s SpacemeshGrpcService) AtxEvents(empty *empty.Empty, stream pb.SpacemeshService_AtxEventsServer) error {
+	log.Info("GRPC AtxEvents msg")
+	if s.NodeAtxs == nil {
+		return fmt.Errorf("atxs are not processed by this node")
+	}
+	atxs, unsubscribe := s.NodeAtxs.SubscribeAtx()
+	defer unsubscribe()
+	for {
+		select {
+		case id := <-atxs:
+			if err := stream.Send(&pb.AtxEvent{Id: id.Hash32().String()}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// GetEpochBeacon returns the tortoise beacon of an epoch, which the node calculated during the previous epoch.
+func (s SpacemeshGrpcService) GetEpochBeacon(ctx context.Context, in *pb.EpochNum) (*pb.EpochBeacon, error) {
+	log.Info("GRPC GetEpochBeacon msg")
+	if s.Beacons == nil {
+		return nil, fmt.Errorf("the tortoise beacon is not run by this node")
+	}
+	beacon, err := s.Beacons.GetBeacon(types.EpochID(in.Epoch))
+	if err != nil {
+		return nil, fmt.Errorf("no beacon for epoch %v: %v", in.Epoch, err)
+	}
+	return &pb.EpochBeacon{Epoch: in.Epoch, Beacon: util.Bytes2Hex(beacon)}, nil
+}
+
+// GetBlockTombstone returns the tombstone of a block that the node found invalid, with the reason it was found invalid.
+func (s SpacemeshGrpcService) GetBlockTombstone(ctx context.Context, in *pb.BlockId) (*pb.BlockTombstone, error) {
+	log.Info("GRPC GetBlockTombstone msg")
+	var id types.BlockID
+	if len(in.Id) != len(id) {
+		return nil, fmt.Errorf("invalid block id length %v, expected %v", len(in.Id), len(id))
+	}
+	copy(id[:], in.Id)
+	t, err := s.Tx.GetTombstone(id)
+	if err != nil {
+		return nil, fmt.Errorf("block %v was not found invalid: %v", id, err)
+	}
+	return &pb.BlockTombstone{Id: in.Id, Layer: t.Layer.Uint64(), ReasonCode: uint32(t.Reason), Reason: t.Reason.String(),
+		Detail: t.Detail}, nil
+}
+
+// GetNextAtx builds the atx the node would publish next, without signing or publishing it, so that operators can
+// check their smeshing setup before the publication deadline. Admin api.
+func (s SpacemeshGrpcService) GetNextAtx(ctx context.Context, empty *empty.Empty) (*pb.NextAtx, error) {
+	log.Info("GRPC GetNextAtx msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	if s.Mining == nil {
+		return nil, fmt.Errorf("mining is not run by this node")
+	}
+	next, err := s.Mining.NextAtx()
+	if err != nil {
+		return nil, fmt.Errorf("cannot build the next atx: %v", err)
+	}
+	atx := next.Atx
+	return &pb.NextAtx{
+		AtxId:         atx.ID().Hash32().String(),
+		Sequence:      atx.Sequence,
+		PrevAtxId:     atx.PrevATXID.Hash32().String(),
+		PosAtxId:      atx.PositioningATX.Hash32().String(),
+		PubLayer:      atx.PubLayerID.Uint64(),
+		TargetEpoch:   uint64(next.TargetEpoch),
+		ActiveSetSize: atx.ActiveSetSize,
+		ViewSize:      uint32(len(atx.View)),
+		SpaceUnits:    atx.SpaceUnits,
+		Coinbase:      atx.Coinbase.Bech32(s.addressHRP()),
+		Challenge:     next.ChallengeHash.String(),
+		Size:          uint64(next.Size),
+		Deadline:      next.Deadline.Unix(),
+		InFlight:      next.InFlight,
+	}, nil
+}
+
+// GetBlockDryRun runs block production for a layer, the current layer if it's 0, without signing or broadcasting the
+// block, and returns the would-be block or the reason the node wouldn't produce one. Admin api.
+func (s SpacemeshGrpcService) GetBlockDryRun(ctx context.Context, in *pb.LayerNum) (*pb.BlockDryRun, error) {
+	log.Info("GRPC GetBlockDryRun msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	if s.Blocks == nil {
+		return nil, fmt.Errorf("blocks are not produced by this node")
+	}
+	layer := types.LayerID(in.Layer)
+	if layer == 0 {
+		layer = s.GenTime.GetCurrentLayer()
+	}
+	run := s.Blocks.DryRun(layer)
+	res := &pb.BlockDryRun{
+		Layer:       run.Layer.Uint64(),
+		Synced:      run.Synced,
+		AtxId:       run.ATXID.Hash32().String(),
+		Eligibility: uint32(run.Eligibility),
+		Reason:      run.Reason,
+	}
+	if b := run.Block; b != nil {
+		res.BlockId = append([]byte{}, run.BlockID[:]...)
+		for _, id := range b.TxIDs {
+			res.TxIds = append(res.TxIds, id.Bytes())
+		}
+		for _, id := range b.ATXIDs {
+			res.AtxIds = append(res.AtxIds, id.Hash32().String())
+		}
+		for _, id := range b.BlockVotes {
+			res.Votes = append(res.Votes, append([]byte{}, id[:]...))
+		}
+		res.ViewSize = uint32(len(run.ViewEdges))
+		res.CompactViewLayers = uint32(len(b.CompactView))
+		res.Size = uint64(run.Size)
+	}
+	return res, nil
+}
+
+const defaultMempoolLimit = 100
+
+var (
+	errAdminAPIDisabled = errors.New("the admin api is disabled, enable it with --admin-api")
+	errNotAdminListener = errors.New("the admin api is only served on the admin listener, see --admin-listener")
+)
+
+func (s SpacemeshGrpcService) checkAdminAPI() error {
+	if !s.Config.API.AdminAPI {
+		return errAdminAPIDisabled
+	}
+	if !s.admin {
+		return errNotAdminListener
+	}
+	return nil
+}
+
+// GetMempool returns a page of the transactions in the mempool, grouped by origin. Admin api.
+func (s SpacemeshGrpcService) GetMempool(ctx context.Context, in *pb.MempoolRequest) (*pb.MempoolTxs, error) {
+	log.Info("GRPC GetMempool msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	txs := s.TxMempool.Txs()
+	res := &pb.MempoolTxs{Total: uint64(len(txs))}
+	limit := in.Limit
+	if limit == 0 {
+		limit = defaultMempoolLimit
+	}
+	if in.Offset >= uint64(len(txs)) {
+		return res, nil
+	}
+	txs = txs[in.Offset:]
+	if limit < uint64(len(txs)) {
+		txs = txs[:limit]
+	}
+	var group *pb.MempoolAccountTxs
+	for _, tx := range txs {
+		origin := s.accountID(tx.Origin())
+		if group == nil || group.Account.Address != origin.Address {
+			group = &pb.MempoolAccountTxs{Account: origin}
+			res.Accounts = append(res.Accounts, group)
+		}
+		group.Txs = append(group.Txs, &pb.Transaction{
+			TxId:     &pb.TransactionId{Id: tx.ID().Bytes()},
+			Sender:   origin,
+			Receiver: s.accountID(tx.Recipient),
+			Amount:   tx.Amount,
+			Fee:      tx.Fee,
+			Status:   pb.TxStatus_PENDING,
+		})
+	}
+	return res, nil
+}
+
+// GetMempoolStats returns a summary of the contents of the mempool. Admin api.
+func (s SpacemeshGrpcService) GetMempoolStats(ctx context.Context, empty *empty.Empty) (*pb.MempoolStats, error) {
+	log.Info("GRPC GetMempoolStats msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	stats := s.TxMempool.Stats()
+	return &pb.MempoolStats{
+		Txs:      uint64(stats.Txs),
+		Accounts: uint64(stats.Accounts),
+		Amount:   stats.Amount,
+		Fees:     stats.Fees,
+	}, nil
+}
+
+// SetMinGasPrice sets the minimum gas price of the transactions that the mempool accepts and that the node relays,
+// until the node restarts. Admin api.
+func (s SpacemeshGrpcService) SetMinGasPrice(ctx context.Context, in *pb.MinGasPrice) (*pb.SimpleMessage, error) {
+	log.Info("GRPC SetMinGasPrice msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	s.TxMempool.SetMinGasPrice(in.Price)
+	log.With().Info("set the minimum gas price", log.Uint64("min_gas_price", in.Price))
+	return &pb.SimpleMessage{Value: "ok"}, nil
+}
+
+// EvictMempoolTxs removes the given transactions and the transactions that the given account sent from the mempool,
+// and returns the IDs of the removed transactions. Admin api.
+func (s SpacemeshGrpcService) EvictMempoolTxs(ctx context.Context, in *pb.EvictRequest) (*pb.EvictedTxs, error) {
+	log.Info("GRPC EvictMempoolTxs msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	var evicted []types.TransactionID
+	for _, txID := range in.TxIds {
+		id := types.TransactionID{}
+		copy(id[:], txID.Id)
+		if s.TxMempool.Evict(id) {
+			evicted = append(evicted, id)
+		}
+	}
+	if in.Account != nil && in.Account.Address != "" {
+		addr, err := s.parseAddress(in.Account.Address)
+		if err != nil {
+			return nil, err
+		}
+		evicted = append(evicted, s.TxMempool.EvictAccount(addr)...)
+	}
+	res := &pb.EvictedTxs{}
+	for _, id := range evicted {
+		res.TxIds = append(res.TxIds, &pb.TransactionId{Id: id.Bytes()})
+	}
+	log.With().Info("evicted transactions from the mempool", log.Int("count", len(evicted)))
+	return res, nil
+}
+
+// ApproveReorg overrides finality: it rolls the state back to the earliest final layer whose reorganization was
+// refused and reapplies the layers as the tortoise verified them. It returns the layer the state was rolled back to.
+// Admin api.
+func (s SpacemeshGrpcService) ApproveReorg(ctx context.Context, empty *empty.Empty) (*pb.LayerNum, error) {
+	log.Info("GRPC ApproveReorg msg")
+	if err := s.checkAdminAPI(); err != nil {
+		return nil, err
+	}
+	lyr, err := s.Tx.ApproveReorg()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LayerNum{Layer: lyr.Uint64()}, nil
+}
+
+// TransactionEvents streams the transactions that the node processes as part of layers, CONFIRMED if they were applied
+// and REJECTED otherwise, until the client cancels. The applied transactions of layers that are rolled back are streamed
+// again as PENDING. When the filter lists accounts, only the transactions that they send or receive are streamed.
+// Events are dropped if the client doesn't keep up.
+func (s SpacemeshGrpcService) TransactionEvents(in *pb.TxFilter, stream pb.SpacemeshService_TransactionEventsServer) error {
+	log.Info("GRPC TransactionEvents msg")
+	accounts := make(map[types.Address]struct{}, len(in.Accounts))
+	for _, acc := range in.Accounts {
+		addr, err := s.parseAddress(acc.GetAddress())
+		if err != nil {
+			return err
+		}
+		accounts[addr] = struct{}{}
+	}
+	receipts, unsubscribe := events.SubscribeLocal(events.EventTxReceipt)
+	defer unsubscribe()
+	for {
+		select {
+		case ev := <-receipts:
+			receipt := ev.(events.TxReceipt)
+			origin, recipient := types.HexToAddress(receipt.Origin), types.HexToAddress(receipt.Destination)
+			if len(accounts) > 0 {
+				_, sent := accounts[origin]
+				_, received := accounts[recipient]
+				if !sent && !received {
+					continue
+				}
+			}
+			status := pb.TxStatus_REJECTED
+			if receipt.Reverted {
+				status = pb.TxStatus_PENDING
+			} else if receipt.Valid {
+				status = pb.TxStatus_CONFIRMED
+			}
+			tx := &pb.Transaction{
+				TxId:     &pb.TransactionId{Id: util.FromHex(receipt.ID)},
+				Sender:   s.accountID(origin),
+				Receiver: s.accountID(recipient),
+				Amount:   receipt.Amount,
+				Fee:      receipt.Fee,
+				Status:   status,
+				LayerId:  receipt.Layer,
+			}
+			if err := stream.Send(tx); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// PeerEvents streams the peer connected, disconnected and rejected events of the node until the client cancels.
+// Events are dropped if the client doesn't keep up.
+func (s SpacemeshGrpcService) PeerEvents(empty *empty.Empty, stream pb.SpacemeshService_PeerEventsServer) error {
+	log.Info("GRPC PeerEvents msg")
+	peerEvents, unsubscribe := events.SubscribeLocal(events.EventPeer)
+	defer unsubscribe()
+	for {
+		select {
+		case ev := <-peerEvents:
+			peer := ev.(events.Peer)
+			if err := stream.Send(&pb.PeerEvent{Peer: peer.ID, Type: peer.Type, Reason: peer.Reason}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package api
 
 import (
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/backup"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/layercache"
+	"github.com/spacemeshos/go-spacemesh/miner"
+	"github.com/spacemeshos/go-spacemesh/p2p"
+	"github.com/spacemeshos/go-spacemesh/p2p/gossip"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/updater"
 	"time"
 )
 
@@ -20,6 +27,8 @@ type StateAPI interface {
 	GetNonce(address types.Address) uint64
 
 	Exist(address types.Address) bool
+
+	GetLayerBalance(layer types.LayerID, address types.Address) (uint64, error)
 }
 
 // NetworkAPI is an API to nodes gossip network
@@ -31,9 +40,11 @@ type NetworkAPI interface {
 // MiningAPI is an API for controlling Post, setting coinbase account and getting mining stats
 type MiningAPI interface {
 	StartPost(address types.Address, datadir string, space uint64) error
-	SetCoinbaseAccount(rewardAddress types.Address)
+	SetCoinbaseAccount(rewardAddress types.Address) error
 	// MiningStats returns state of post init, coinbase reward account and data directory path for post commitment
 	MiningStats() (postStatus int, remainingBytes uint64, coinbaseAccount string, postDatadir string)
+	// NextAtx builds the atx the node would publish next, without signing or publishing it
+	NextAtx() (*activation.NextAtx, error)
 }
 
 // OracleAPI gets eligible layers from oracle
@@ -41,10 +52,12 @@ type OracleAPI interface {
 	GetEligibleLayers() []types.LayerID
 }
 
-// GenesisTimeAPI is an API to get genesis time and current layer of the system
+// GenesisTimeAPI is an API to get genesis time and current layer of the system, and to convert between layers and time
 type GenesisTimeAPI interface {
 	GetGenesisTime() time.Time
 	GetCurrentLayer() types.LayerID
+	LayerToTime(id types.LayerID) time.Time
+	TimeToLayer(t time.Time) types.LayerID
 }
 
 // LoggingAPI is an API to system loggers
@@ -52,6 +65,56 @@ type LoggingAPI interface {
 	SetLogLevel(loggerName, severity string) error
 }
 
+// BackupAPI is an API to back up the node's databases
+type BackupAPI interface {
+	// Backup writes a consistent copy of the node's databases to dir, which must be under the data directory and
+	// empty or not exist
+	Backup(dir string) (*backup.Manifest, error)
+}
+
+// GossipReporter is implemented by networks that keep gossip delivery statistics
+type GossipReporter interface {
+	GossipReport() gossip.Report
+}
+
+// PeerStatsReporter is implemented by networks that keep per peer statistics of the messages of each protocol
+type PeerStatsReporter interface {
+	PeerStats() []p2p.PeerProtocolStats
+}
+
+// BlockProducerAPI is an API to dry runs of the node's block production
+type BlockProducerAPI interface {
+	DryRun(layer types.LayerID) *miner.BlockDryRun
+}
+
+// VersionAPI is an API to the result of the latest check of the node's version against the network's
+type VersionAPI interface {
+	Status() updater.Status
+}
+
+// LayerResultsAPI is an API to the execution results of the latest layers applied to state
+type LayerResultsAPI interface {
+	Get(layer types.LayerID) (*layercache.LayerResults, error)
+}
+
+// PosAtxAPI is an API to the positioning atx selection of the node
+type PosAtxAPI interface {
+	GetPosAtx() (types.ATXID, types.LayerID, error)
+	PosAtxHistory() ([]activation.PosAtxChange, error)
+}
+
+// NodeAtxsAPI is an API to the atxs published by nodes
+type NodeAtxsAPI interface {
+	GetNodeAtxIDs(nodeID types.NodeID) ([]types.ATXID, error)
+	EpochAtxIDs(epoch types.EpochID, after *types.ATXID, limit int) ([]types.ATXID, error)
+	SubscribeAtx() (<-chan types.ATXID, func())
+}
+
+// BeaconAPI is an API to the tortoise beacons calculated by the node
+type BeaconAPI interface {
+	GetBeacon(epoch types.EpochID) ([]byte, error)
+}
+
 // PostAPI is an API for post init module
 type PostAPI interface {
 	Reset() error
@@ -0,0 +1,125 @@
+// Package backup describes backups of a node's databases and restores them. A backup holds a copy of every store of
+// the node, taken at a layer boundary, and a manifest that records the genesis the node was part of and the schema
+// version of every store, so that a backup is never restored into another network or an incompatible node version.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// FormatVersion is the version of the backup layout and manifest.
+const FormatVersion = 1
+
+// ManifestFile is the name of the manifest in the backup directory.
+const ManifestFile = "manifest.json"
+
+// Store is a database included in a backup.
+type Store struct {
+	// Path is the path of the store relative to the data directory, with forward slashes.
+	Path          string `json:"path"`
+	SchemaVersion uint32 `json:"schema_version"`
+}
+
+// Manifest describes a backup.
+type Manifest struct {
+	FormatVersion uint32        `json:"format_version"`
+	GenesisID     string        `json:"genesis_id"`
+	Layer         types.LayerID `json:"layer"` // the latest layer applied to state in the backup
+	NodeVersion   string        `json:"node_version"`
+	Created       time.Time     `json:"created"`
+	Stores        []Store       `json:"stores"`
+}
+
+// WriteManifest writes m to dir.
+func WriteManifest(dir string, m *Manifest) error {
+	bytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot serialize manifest: %v", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ManifestFile), bytes, 0600)
+}
+
+// ReadManifest reads the manifest of the backup in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read manifest: %v", err)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(bytes, m); err != nil {
+		return nil, fmt.Errorf("cannot parse manifest: %v", err)
+	}
+	return m, nil
+}
+
+// Verify returns an error unless the backup can be restored by a node of the given genesis, whose stores have the
+// given schema versions.
+func (m *Manifest) Verify(genesisID string, schemaVersions map[string]uint32) error {
+	if m.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported backup format version %d, expected %d", m.FormatVersion, FormatVersion)
+	}
+	if m.GenesisID != genesisID {
+		return fmt.Errorf("backup is of genesis %v, this node is configured for genesis %v", m.GenesisID, genesisID)
+	}
+	for _, store := range m.Stores {
+		version, ok := schemaVersions[store.Path]
+		if !ok {
+			return fmt.Errorf("backup contains unknown store %v", store.Path)
+		}
+		if store.SchemaVersion != version {
+			return fmt.Errorf("store %v has schema version %d, this node requires version %d", store.Path,
+				store.SchemaVersion, version)
+		}
+	}
+	return nil
+}
+
+// Restore copies the stores of the backup in dir into the directories returned by storeDir for their paths. None of
+// the stores may exist already.
+func Restore(dir string, storeDir func(path string) string, m *Manifest) error {
+	for _, store := range m.Stores {
+		dst := filepath.Join(storeDir(store.Path), filepath.FromSlash(store.Path))
+		if _, err := os.Stat(dst); err == nil {
+			return fmt.Errorf("store %v already exists", dst)
+		}
+	}
+	for _, store := range m.Stores {
+		src := filepath.Join(dir, filepath.FromSlash(store.Path))
+		dst := filepath.Join(storeDir(store.Path), filepath.FromSlash(store.Path))
+		if err := copyDir(src, dst); err != nil {
+			return fmt.Errorf("cannot restore store %v: %v", store.Path, err)
+		}
+	}
+	return nil
+}
+
+// copyDir copies the files of the directory src, which must not have subdirectories, to dst.
+func copyDir(src, dst string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			return fmt.Errorf("unexpected directory %v", filepath.Join(src, file.Name()))
+		}
+		bytes, err := ioutil.ReadFile(filepath.Join(src, file.Name()))
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dst, file.Name()), bytes, file.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
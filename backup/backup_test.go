@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testManifest() *Manifest {
+	return &Manifest{
+		FormatVersion: FormatVersion,
+		GenesisID:     "genesis",
+		Layer:         7,
+		NodeVersion:   "v0.1.0",
+		Created:       time.Now().UTC().Truncate(time.Second),
+		Stores:        []Store{{Path: "state", SchemaVersion: 1}, {Path: "mesh/blocks", SchemaVersion: 2}},
+	}
+}
+
+func TestManifest_WriteRead(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "backup")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	m := testManifest()
+	r.NoError(WriteManifest(dir, m))
+	read, err := ReadManifest(dir)
+	r.NoError(err)
+	r.Equal(m, read)
+
+	_, err = ReadManifest(filepath.Join(dir, "missing"))
+	r.Error(err)
+}
+
+func TestManifest_Verify(t *testing.T) {
+	r := require.New(t)
+	versions := map[string]uint32{"state": 1, "mesh/blocks": 2}
+	m := testManifest()
+	r.NoError(m.Verify("genesis", versions))
+	r.EqualError(m.Verify("other", versions), "backup is of genesis genesis, this node is configured for genesis other")
+	r.EqualError(m.Verify("genesis", map[string]uint32{"state": 1, "mesh/blocks": 3}),
+		"store mesh/blocks has schema version 2, this node requires version 3")
+	r.EqualError(m.Verify("genesis", map[string]uint32{"state": 1}), "backup contains unknown store mesh/blocks")
+
+	m.FormatVersion = FormatVersion + 1
+	r.EqualError(m.Verify("genesis", versions), "unsupported backup format version 2, expected 1")
+}
+
+func TestRestore(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "backup")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+	dataDir, err := ioutil.TempDir("", "data")
+	r.NoError(err)
+	defer os.RemoveAll(dataDir)
+
+	m := testManifest()
+	for _, store := range m.Stores {
+		path := filepath.Join(dir, filepath.FromSlash(store.Path))
+		r.NoError(os.MkdirAll(path, 0700))
+		r.NoError(ioutil.WriteFile(filepath.Join(path, "000001.log"), []byte(store.Path), 0600))
+	}
+
+	meshDir, err := ioutil.TempDir("", "mesh")
+	r.NoError(err)
+	defer os.RemoveAll(meshDir)
+	storeDir := func(path string) string {
+		if strings.HasPrefix(path, "mesh/") {
+			return meshDir
+		}
+		return dataDir
+	}
+
+	r.NoError(Restore(dir, storeDir, m))
+	bytes, err := ioutil.ReadFile(filepath.Join(meshDir, "mesh", "blocks", "000001.log"))
+	r.NoError(err)
+	r.Equal("mesh/blocks", string(bytes))
+	bytes, err = ioutil.ReadFile(filepath.Join(dataDir, "state", "000001.log"))
+	r.NoError(err)
+	r.Equal("state", string(bytes))
+
+	// never overwrite existing stores
+	r.Error(Restore(dir, storeDir, m))
+}
@@ -0,0 +1,292 @@
+// Package blockvalidation implements the syntactic validation rules of blocks. Blocks received from peers are accepted
+// to the mesh only after they pass all of the rules.
+package blockvalidation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
+)
+
+// DefaultMaxBlockSize is the default max size of a serialized block, in bytes.
+const DefaultMaxBlockSize = 1 << 20
+
+var (
+	// ErrTooLarge is returned when the serialized block is larger than the max block size.
+	ErrTooLarge = errors.New("block is too large")
+	// ErrTooManyTxs is returned when a block references more transactions than allowed.
+	ErrTooManyTxs = errors.New("too many txs in block")
+	// ErrTooManyAtxs is returned when a block references more ATXs than allowed.
+	ErrTooManyAtxs = errors.New("too many atxs in blocks")
+	// ErrDupTx is returned when a block references a transaction more than once.
+	ErrDupTx = errors.New("duplicate TransactionID in block")
+	// ErrDupAtx is returned when a block references an ATX more than once.
+	ErrDupAtx = errors.New("duplicate ATXID in block")
+	// ErrAtxEpoch is returned when the ATX of a block doesn't target the epoch of the block.
+	ErrAtxEpoch = errors.New("block ATX doesn't target the block epoch")
+	// ErrNotEligible is returned when the eligibility proof of a block isn't valid.
+	ErrNotEligible = errors.New("block is not eligible")
+	// ErrTxsMismatch is returned when the fetched transactions of a block don't match the ones it references.
+	ErrTxsMismatch = errors.New("block transactions don't match the referenced ones")
+	// ErrAtxsMismatch is returned when the fetched ATXs of a block don't match the ones it references.
+	ErrAtxsMismatch = errors.New("block atxs don't match the referenced ones")
+	// ErrGasLimit is returned when the transactions of a block exceed the block gas limit.
+	ErrGasLimit = errors.New("block transactions exceed the block gas limit")
+	// ErrViewUnavailable is returned when blocks in the view of a block can't be found or fetched.
+	ErrViewUnavailable = errors.New("blocks in view are unavailable")
+	// ErrCompactView is returned when the compact view of a block isn't allowed in its epoch or isn't canonical.
+	ErrCompactView = errors.New("invalid compact view")
+)
+
+// Config holds the limits that blocks must satisfy.
+type Config struct {
+	LayersPerEpoch uint16
+	MaxBlockSize   int    // in bytes of the serialized block, including the signature
+	MaxTxs         int    // max number of transactions a block references
+	MaxAtxs        int    // max number of ATXs a block references
+	BlockGasLimit  uint64 // max sum of the gas limits of a block's transactions, zero for no limit
+}
+
+type eligibilityValidator interface {
+	BlockSignedAndEligible(block *types.Block) (bool, error)
+}
+
+// store is where the validator looks up the ATXs and transactions that blocks reference, and the state roots that
+// blocks report, i.e. the mesh.
+type store interface {
+	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
+	GetTransactions(ids []types.TransactionID) ([]*types.Transaction, map[types.TransactionID]struct{})
+	VerifiedLayer() types.LayerID
+	LayerStateRoot(layer types.LayerID) (types.Hash32, error)
+}
+
+// Fetcher provides the data that a block references, fetching it from peers when it's missing locally.
+type Fetcher interface {
+	// FetchData returns the transactions and the ATXs that blk references.
+	FetchData(blk *types.Block) ([]*types.Transaction, []*types.ActivationTx, error)
+	// FetchView returns an error unless all the blocks in the view of blk exist or were fetched.
+	FetchView(blk *types.Block) error
+}
+
+// Validator validates blocks against the full rule set.
+type Validator struct {
+	conf        Config
+	eligibility eligibilityValidator
+	store       store
+	upgrades    *upgrade.Schedule
+	log         log.Log
+}
+
+// NewValidator returns a new Validator. Eligibility proofs are validated by eligibility, and referenced ATXs and
+// transactions are looked up in store.
+func NewValidator(conf Config, eligibility eligibilityValidator, store store, logger log.Log) *Validator {
+	return &Validator{conf: conf, eligibility: eligibility, store: store, log: logger}
+}
+
+// SetUpgrades sets the schedule of the protocol upgrades that block validation branches on. It must be called before
+// blocks are validated, without a schedule blocks are validated by the original protocol.
+func (v *Validator) SetUpgrades(upgrades *upgrade.Schedule) {
+	v.upgrades = upgrades
+}
+
+// Validate validates blk with all the rules. It returns the transactions and ATXs of the block, fetched by f.
+func (v *Validator) Validate(blk *types.Block, f Fetcher) ([]*types.Transaction, []*types.ActivationTx, error) {
+	if err := v.ValidateHeader(blk); err != nil {
+		return nil, nil, err
+	}
+	txs, atxs, err := f.FetchData(blk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("DataAvailabilty failed for block %v err: %v", blk.ID(), err)
+	}
+	if err := v.ValidateContents(blk, txs, atxs); err != nil {
+		return nil, nil, err
+	}
+	if err := f.FetchView(blk); err != nil {
+		return nil, nil, fmt.Errorf("%v: %v", ErrViewUnavailable, err)
+	}
+	return txs, atxs, nil
+}
+
+// ValidateHeader validates the rules that don't need the data a block references: the block size, the number of
+// transactions and ATXs it references, that it references them once, that its ATX targets the block's epoch, that its
+// compact view is allowed and canonical and that its miner is eligible. The signature is validated when the block is
+// initialized with TryInitialize. The state root that the block reports is compared to ours too, but a divergence
+// doesn't fail the validation (see CheckStateRoot).
+func (v *Validator) ValidateHeader(blk *types.Block) error {
+	if v.conf.MaxBlockSize > 0 {
+		if size := len(blk.Bytes()) + len(blk.Signature); size > v.conf.MaxBlockSize {
+			return fmt.Errorf("%v: %d bytes, max %d", ErrTooLarge, size, v.conf.MaxBlockSize)
+		}
+	}
+	if len(blk.TxIDs) > v.conf.MaxTxs {
+		v.log.Error("Too many txs in block expected<=%v actual=%v", v.conf.MaxTxs, len(blk.TxIDs))
+		return ErrTooManyTxs
+	}
+	if len(blk.ATXIDs) > v.conf.MaxAtxs {
+		v.log.Error("Too many atxs in block expected<=%v actual=%v", v.conf.MaxAtxs, len(blk.ATXIDs))
+		return ErrTooManyAtxs
+	}
+	if err := ValidateUniqueTxAtx(blk); err != nil {
+		return err
+	}
+	if err := v.validateAtxEpoch(blk); err != nil {
+		return err
+	}
+	if err := v.validateCompactView(blk); err != nil {
+		return err
+	}
+	if eligible, err := v.eligibility.BlockSignedAndEligible(blk); err != nil {
+		return fmt.Errorf("%v: %v", ErrNotEligible, err)
+	} else if !eligible {
+		return ErrNotEligible
+	}
+	v.CheckStateRoot(blk)
+	return nil
+}
+
+// CheckStateRoot compares the state root that blk reports to our state root at the end of the same layer, and
+// returns false if they differ. A divergence is logged and published as an event rather than failing the validation,
+// since either side may have the wrong state. Blocks of producers without a verified state, and blocks that report a
+// layer we haven't verified yet, are not compared.
+func (v *Validator) CheckStateRoot(blk *types.Block) bool {
+	if blk.StateRoot == (types.Hash32{}) || blk.StateLayer > v.store.VerifiedLayer() {
+		return true
+	}
+	root, err := v.store.LayerStateRoot(blk.StateLayer)
+	if err != nil {
+		// e.g. the state was imported at a later layer
+		return true
+	}
+	if root == blk.StateRoot {
+		return true
+	}
+	v.log.Event().Warning("state root divergence", blk.ID(), blk.MinerID(),
+		log.Uint64("state_layer", blk.StateLayer.Uint64()),
+		log.String("local_state_root", root.String()),
+		log.String("remote_state_root", blk.StateRoot.String()))
+	events.Publish(events.StateRootDivergence{
+		BlockID: blk.ID().String(),
+		Miner:   blk.MinerID().String(),
+		Layer:   blk.StateLayer.Uint64(),
+		Local:   root.String(),
+		Remote:  blk.StateRoot.String(),
+	})
+	return false
+}
+
+// ValidateUniqueTxAtx returns an error if b references a transaction or an ATX more than once.
+func ValidateUniqueTxAtx(b *types.Block) error {
+	mt := make(map[types.TransactionID]struct{}, len(b.TxIDs))
+	for _, tx := range b.TxIDs {
+		if _, exist := mt[tx]; exist {
+			return ErrDupTx
+		}
+		mt[tx] = struct{}{}
+	}
+
+	ma := make(map[types.ATXID]struct{}, len(b.ATXIDs))
+	for _, atx := range b.ATXIDs {
+		if _, exist := ma[atx]; exist {
+			return ErrDupAtx
+		}
+		ma[atx] = struct{}{}
+	}
+	return nil
+}
+
+// validateAtxEpoch checks that the ATX of blk was published in the epoch before the block's epoch. Blocks of epoch 0
+// and genesis blocks without an ATX are exempt, as in the eligibility validation.
+func (v *Validator) validateAtxEpoch(blk *types.Block) error {
+	epoch := blk.LayerIndex.GetEpoch(v.conf.LayersPerEpoch)
+	if epoch == 0 || blk.ATXID == *types.EmptyATXID {
+		return nil
+	}
+	atx, err := v.store.GetAtxHeader(blk.ATXID)
+	if err != nil {
+		return fmt.Errorf("cannot get block ATX %v: %v", blk.ATXID.ShortString(), err)
+	}
+	if target := atx.PubLayerID.GetEpoch(v.conf.LayersPerEpoch) + 1; target != epoch {
+		return fmt.Errorf("%v: ATX target epoch %v, block epoch %v", ErrAtxEpoch, target, epoch)
+	}
+	return nil
+}
+
+// validateCompactView validates that a block has a compact view only once the compact views upgrade is active in its
+// epoch, that it doesn't list view edges as well, and that the compact view is canonical.
+func (v *Validator) validateCompactView(blk *types.Block) error {
+	if len(blk.CompactView) == 0 {
+		return nil
+	}
+	if !v.upgrades.Active(upgrade.CompactViews, blk.LayerIndex.GetEpoch(v.conf.LayersPerEpoch)) {
+		return fmt.Errorf("%v: compact views aren't active in the block's epoch", ErrCompactView)
+	}
+	if len(blk.ViewEdges) > 0 {
+		return fmt.Errorf("%v: block has both view edges and a compact view", ErrCompactView)
+	}
+	if err := types.ValidateCompactView(blk.CompactView, blk.LayerIndex); err != nil {
+		return fmt.Errorf("%v: %v", ErrCompactView, err)
+	}
+	return nil
+}
+
+// ValidateContents validates the rules on the data a block references. txs and atxs are the transactions and ATXs of
+// the block that aren't in the mesh yet, as returned by Fetcher.FetchData: each of them must be referenced by the
+// block, whose signature covers the references, and all the transactions of the block must fit the block gas limit.
+func (v *Validator) ValidateContents(blk *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error {
+	txIDs := make(map[types.TransactionID]struct{}, len(blk.TxIDs))
+	for _, id := range blk.TxIDs {
+		txIDs[id] = struct{}{}
+	}
+	all := make([]*types.Transaction, 0, len(blk.TxIDs))
+	for _, tx := range txs {
+		if _, ok := txIDs[tx.ID()]; !ok {
+			return ErrTxsMismatch
+		}
+		delete(txIDs, tx.ID())
+		all = append(all, tx)
+	}
+	if len(txIDs) > 0 {
+		rest := make([]types.TransactionID, 0, len(txIDs))
+		for id := range txIDs {
+			rest = append(rest, id)
+		}
+		stored, missing := v.store.GetTransactions(rest)
+		if len(missing) > 0 {
+			return fmt.Errorf("%v: %d transactions are missing", ErrTxsMismatch, len(missing))
+		}
+		all = append(all, stored...)
+	}
+	if err := v.validateGas(all); err != nil {
+		return err
+	}
+
+	atxIDs := make(map[types.ATXID]struct{}, len(blk.ATXIDs))
+	for _, id := range blk.ATXIDs {
+		atxIDs[id] = struct{}{}
+	}
+	for _, atx := range atxs {
+		if _, ok := atxIDs[atx.ID()]; !ok {
+			return ErrAtxsMismatch
+		}
+		delete(atxIDs, atx.ID())
+	}
+	return nil
+}
+
+func (v *Validator) validateGas(txs []*types.Transaction) error {
+	if v.conf.BlockGasLimit == 0 {
+		return nil
+	}
+	var gas uint64
+	for _, tx := range txs {
+		if tx.GasLimit > v.conf.BlockGasLimit-gas {
+			return fmt.Errorf("%v: limit %d", ErrGasLimit, v.conf.BlockGasLimit)
+		}
+		gas += tx.GasLimit
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package blockvalidation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
+)
+
+const layersPerEpoch = 3
+
+var errFoo = errors.New("some err")
+
+type eligibilityMock struct {
+	eligible bool
+	err      error
+}
+
+func (m eligibilityMock) BlockSignedAndEligible(*types.Block) (bool, error) {
+	return m.eligible, m.err
+}
+
+type storeMock struct {
+	atxs     map[types.ATXID]*types.ActivationTxHeader
+	txs      map[types.TransactionID]*types.Transaction
+	verified types.LayerID
+	roots    map[types.LayerID]types.Hash32
+}
+
+func (m storeMock) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error) {
+	if atx, ok := m.atxs[id]; ok {
+		return atx, nil
+	}
+	return nil, errFoo
+}
+
+func (m storeMock) GetTransactions(ids []types.TransactionID) ([]*types.Transaction, map[types.TransactionID]struct{}) {
+	var txs []*types.Transaction
+	missing := make(map[types.TransactionID]struct{})
+	for _, id := range ids {
+		if tx, ok := m.txs[id]; ok {
+			txs = append(txs, tx)
+		} else {
+			missing[id] = struct{}{}
+		}
+	}
+	return txs, missing
+}
+
+func (m storeMock) VerifiedLayer() types.LayerID {
+	return m.verified
+}
+
+func (m storeMock) LayerStateRoot(layer types.LayerID) (types.Hash32, error) {
+	if root, ok := m.roots[layer]; ok {
+		return root, nil
+	}
+	return types.Hash32{}, errFoo
+}
+
+type fetcherMock struct {
+	txs     []*types.Transaction
+	atxs    []*types.ActivationTx
+	dataErr error
+	viewErr error
+}
+
+func (m fetcherMock) FetchData(*types.Block) ([]*types.Transaction, []*types.ActivationTx, error) {
+	return m.txs, m.atxs, m.dataErr
+}
+
+func (m fetcherMock) FetchView(*types.Block) error {
+	return m.viewErr
+}
+
+func testConfig() Config {
+	return Config{LayersPerEpoch: layersPerEpoch, MaxBlockSize: DefaultMaxBlockSize, MaxTxs: 3, MaxAtxs: 3}
+}
+
+func newTx(t *testing.T, nonce, gas uint64) *types.Transaction {
+	tx, err := mesh.NewSignedTx(nonce, types.HexToAddress("1"), 1, gas, 1, signing.NewEdSigner())
+	require.NoError(t, err)
+	return tx
+}
+
+func newBlock(layer types.LayerID, txs []*types.Transaction, atxs []types.ATXID) *types.Block {
+	blk := types.NewExistingBlock(layer, []byte("data"))
+	for _, tx := range txs {
+		blk.TxIDs = append(blk.TxIDs, tx.ID())
+	}
+	blk.ATXIDs = atxs
+	return blk
+}
+
+func TestValidateUniqueTxAtx(t *testing.T) {
+	r := require.New(t)
+	b := &types.Block{}
+	txid1, txid2, txid3 := types.TransactionID{1}, types.TransactionID{2}, types.TransactionID{3}
+	atx1, atx2, atx3 := types.ATXID{1}, types.ATXID{2}, types.ATXID{3}
+
+	// unique
+	b.TxIDs = []types.TransactionID{txid1, txid2, txid3}
+	b.ATXIDs = []types.ATXID{atx1, atx2, atx3}
+	r.Nil(ValidateUniqueTxAtx(b))
+
+	// dup txs
+	b.TxIDs = []types.TransactionID{txid1, txid2, txid1}
+	b.ATXIDs = []types.ATXID{atx1, atx2, atx3}
+	r.EqualError(ValidateUniqueTxAtx(b), ErrDupTx.Error())
+
+	// dup atxs
+	b.TxIDs = []types.TransactionID{txid1, txid2, txid3}
+	b.ATXIDs = []types.ATXID{atx1, atx2, atx1}
+	r.EqualError(ValidateUniqueTxAtx(b), ErrDupAtx.Error())
+}
+
+func TestValidator_ValidateHeader(t *testing.T) {
+	r := require.New(t)
+	store := storeMock{atxs: map[types.ATXID]*types.ActivationTxHeader{}}
+	v := NewValidator(testConfig(), eligibilityMock{eligible: true}, store, log.NewDefault(t.Name()))
+
+	blk := newBlock(1, []*types.Transaction{newTx(t, 1, 1)}, []types.ATXID{{1}})
+	r.NoError(v.ValidateHeader(blk))
+
+	// limits
+	blk.ATXIDs = []types.ATXID{{1}, {2}, {3}, {4}}
+	r.Equal(ErrTooManyAtxs, v.ValidateHeader(blk))
+	blk.ATXIDs = nil
+	blk.TxIDs = []types.TransactionID{{1}, {2}, {3}, {4}}
+	r.Equal(ErrTooManyTxs, v.ValidateHeader(blk))
+	blk.TxIDs = nil
+	blk.Data = make([]byte, DefaultMaxBlockSize)
+	r.Contains(v.ValidateHeader(blk).Error(), ErrTooLarge.Error())
+	blk.Data = nil
+
+	// the ATX must target the block's epoch
+	blk.LayerIndex = 2 * layersPerEpoch
+	blk.ATXID = types.ATXID{7}
+	r.Error(v.ValidateHeader(blk))
+	store.atxs[blk.ATXID] = &types.ActivationTxHeader{NIPSTChallenge: types.NIPSTChallenge{PubLayerID: 0}}
+	r.Contains(v.ValidateHeader(blk).Error(), ErrAtxEpoch.Error())
+	store.atxs[blk.ATXID].PubLayerID = layersPerEpoch
+	r.NoError(v.ValidateHeader(blk))
+
+	// eligibility
+	v.eligibility = eligibilityMock{eligible: false}
+	r.Equal(ErrNotEligible, v.ValidateHeader(blk))
+	v.eligibility = eligibilityMock{err: errFoo}
+	r.Contains(v.ValidateHeader(blk).Error(), ErrNotEligible.Error())
+}
+
+func TestValidator_ValidateCompactView(t *testing.T) {
+	r := require.New(t)
+	v := NewValidator(testConfig(), eligibilityMock{eligible: true}, storeMock{}, log.NewDefault(t.Name()))
+
+	blk := newBlock(2*layersPerEpoch, nil, nil)
+	blk.CompactView = []types.LayerView{{Layer: 1, Hash: types.Hash32{1}}}
+	r.Contains(v.ValidateHeader(blk).Error(), ErrCompactView.Error())
+
+	upgrades, err := upgrade.NewSchedule(map[string]int{string(upgrade.CompactViews): 2})
+	r.NoError(err)
+	v.SetUpgrades(upgrades)
+	r.NoError(v.ValidateHeader(blk))
+
+	blk.AddView(types.BlockID{1})
+	r.Contains(v.ValidateHeader(blk).Error(), ErrCompactView.Error())
+	blk.ViewEdges = nil
+	blk.CompactView = append(blk.CompactView, types.LayerView{Layer: 2 * layersPerEpoch, Hash: types.Hash32{1}})
+	r.Contains(v.ValidateHeader(blk).Error(), ErrCompactView.Error())
+
+	// the upgrade isn't active in the block's epoch
+	blk = newBlock(layersPerEpoch, nil, nil)
+	blk.CompactView = []types.LayerView{{Layer: 1, Hash: types.Hash32{1}}}
+	r.Contains(v.ValidateHeader(blk).Error(), ErrCompactView.Error())
+}
+
+func TestValidator_CheckStateRoot(t *testing.T) {
+	r := require.New(t)
+	store := storeMock{verified: 5, roots: map[types.LayerID]types.Hash32{4: {4}, 5: {5}}}
+	v := NewValidator(testConfig(), eligibilityMock{eligible: true}, store, log.NewDefault(t.Name()))
+	divergence, unsubscribe := events.SubscribeLocal(events.EventStateRootDivergence)
+	defer unsubscribe()
+
+	blk := newBlock(6, nil, nil)
+	// the producer has no verified state
+	r.True(v.CheckStateRoot(blk))
+
+	blk.StateLayer, blk.StateRoot = 4, types.Hash32{4}
+	r.True(v.CheckStateRoot(blk))
+	// we haven't verified the layer yet
+	blk.StateLayer, blk.StateRoot = 6, types.Hash32{1}
+	r.True(v.CheckStateRoot(blk))
+	// we have no state root for the layer
+	blk.StateLayer = 3
+	r.True(v.CheckStateRoot(blk))
+	r.Len(divergence, 0)
+
+	blk.StateLayer, blk.StateRoot = 5, types.Hash32{1}
+	r.False(v.CheckStateRoot(blk))
+	r.Equal(events.StateRootDivergence{
+		BlockID: blk.ID().String(),
+		Miner:   blk.MinerID().String(),
+		Layer:   5,
+		Local:   types.Hash32{5}.String(),
+		Remote:  types.Hash32{1}.String(),
+	}, <-divergence)
+
+	// a divergence doesn't fail the validation
+	r.NoError(v.ValidateHeader(blk))
+}
+
+func TestValidator_ValidateContents(t *testing.T) {
+	r := require.New(t)
+	stored, fetched := newTx(t, 1, 5), newTx(t, 2, 5)
+	store := storeMock{txs: map[types.TransactionID]*types.Transaction{stored.ID(): stored}}
+	conf := testConfig()
+	conf.BlockGasLimit = 10
+	v := NewValidator(conf, eligibilityMock{eligible: true}, store, log.NewDefault(t.Name()))
+
+	blk := newBlock(1, []*types.Transaction{stored, fetched}, nil)
+	r.NoError(v.ValidateContents(blk, []*types.Transaction{fetched}, nil))
+
+	// a transaction the block doesn't reference
+	r.Equal(ErrTxsMismatch, v.ValidateContents(blk, []*types.Transaction{fetched, newTx(t, 3, 0)}, nil))
+	// a referenced transaction that's neither fetched nor stored
+	r.Contains(v.ValidateContents(blk, nil, nil).Error(), ErrTxsMismatch.Error())
+
+	// the gas of stored and fetched transactions counts
+	v.conf.BlockGasLimit = 9
+	r.Contains(v.ValidateContents(blk, []*types.Transaction{fetched}, nil).Error(), ErrGasLimit.Error())
+
+	// an ATX the block doesn't reference
+	atx := &types.ActivationTx{InnerActivationTx: &types.InnerActivationTx{ActivationTxHeader: &types.ActivationTxHeader{}}}
+	atx.CalcAndSetID()
+	v.conf.BlockGasLimit = 0
+	r.Equal(ErrAtxsMismatch, v.ValidateContents(blk, []*types.Transaction{fetched}, []*types.ActivationTx{atx}))
+	blk.ATXIDs = []types.ATXID{atx.ID()}
+	r.NoError(v.ValidateContents(blk, []*types.Transaction{fetched}, []*types.ActivationTx{atx}))
+}
+
+func TestValidator_Validate(t *testing.T) {
+	r := require.New(t)
+	v := NewValidator(testConfig(), eligibilityMock{eligible: true}, storeMock{}, log.NewDefault(t.Name()))
+	tx := newTx(t, 1, 1)
+	blk := newBlock(1, []*types.Transaction{tx}, nil)
+
+	txs, atxs, err := v.Validate(blk, fetcherMock{txs: []*types.Transaction{tx}})
+	r.NoError(err)
+	r.Equal([]*types.Transaction{tx}, txs)
+	r.Empty(atxs)
+
+	_, _, err = v.Validate(blk, fetcherMock{dataErr: errFoo})
+	r.Error(err)
+
+	_, _, err = v.Validate(blk, fetcherMock{txs: []*types.Transaction{tx}, viewErr: errFoo})
+	r.Contains(err.Error(), ErrViewUnavailable.Error())
+}
@@ -0,0 +1,404 @@
+// Package certifier implements the certification of hare outputs: after the hare terminates for a layer, a committee
+// sampled by the hare oracle signs the output block set and gossips its signatures. Once enough signatures on the same
+// block set were collected, they make up a certificate that is gossiped once per layer and passed to the mesh, which
+// applies the layer's hare output to state only then. The tortoise still validates the layer independently, so a layer
+// that fails to be certified is applied to state once the tortoise verifies it.
+package certifier
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// Protocol is the name of the certify messages gossip protocol.
+const Protocol = "CertifierProtocol"
+
+// CertificateProtocol is the name of the certificates gossip protocol. Nodes relay only the first valid certificate of
+// a layer, so a layer's certificate is gossiped once even when several nodes collected enough signatures.
+const CertificateProtocol = "CertificateProtocol"
+
+// CertifyRound is the hare oracle round the certifying committee is sampled for. It's out of the range of the rounds
+// the hare uses, so that certifying doesn't reveal or depend on the hare committees.
+const CertifyRound = math.MaxInt32
+
+// the number of layers, before the latest certified or output layer, that signatures are collected for
+const layerBuffer = 20
+
+// Config is the configuration of the certifier.
+type Config struct {
+	CommitteeSize int  // the expected size of the certifying committee of a layer
+	Threshold     int  // the number of committee signatures required for a certificate
+	ListenOnly    bool // validate and count the certify messages of others, never sign one
+}
+
+type certificateHandler interface {
+	HandleCertificate(cert *types.Certificate) error
+}
+
+type rolacle interface {
+	Eligible(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (bool, error)
+	Proof(layer types.LayerID, round int32) ([]byte, error)
+}
+
+type signer interface {
+	Sign(m []byte) []byte
+	PublicKey() *signing.PublicKey
+}
+
+type identityProvider interface {
+	GetIdentity(edID string) (types.NodeID, error)
+}
+
+// the signatures collected for a layer, by the hash of the signed block set and the signer
+type layerSignatures map[types.Hash32]map[string]types.CertifySignature
+
+// Certifier certifies hare outputs. It gets the hare output of every layer alongside the mesh, signs it if this node is
+// in the layer's certifying committee, and passes the layer's certificate to the mesh.
+type Certifier struct {
+	log.Log
+	cfg            Config
+	net            service.Service
+	msh            certificateHandler
+	oracle         rolacle
+	signer         signer
+	ids            identityProvider
+	layersPerEpoch uint16
+	messages       chan service.GossipMessage
+	certificates   chan service.GossipMessage
+
+	mu         sync.Mutex
+	signatures map[types.LayerID]layerSignatures
+	assembled  map[types.LayerID]bool // layers this node broadcast a certificate of
+	certified  map[types.LayerID]bool // layers whose certificate was passed to the mesh
+	latest     types.LayerID
+
+	started bool
+	exit    chan struct{}
+}
+
+// NewCertifier returns a new Certifier.
+func NewCertifier(cfg Config, net service.Service, msh certificateHandler, oracle rolacle, signer signer, ids identityProvider,
+	layersPerEpoch uint16, logger log.Log) *Certifier {
+	return &Certifier{
+		Log:            logger,
+		cfg:            cfg,
+		net:            net,
+		msh:            msh,
+		oracle:         oracle,
+		signer:         signer,
+		ids:            ids,
+		layersPerEpoch: layersPerEpoch,
+		messages:       net.RegisterGossipProtocol(Protocol, priorityq.Mid),
+		certificates:   net.RegisterGossipProtocol(CertificateProtocol, priorityq.Mid),
+		signatures:     make(map[types.LayerID]layerSignatures),
+		assembled:      make(map[types.LayerID]bool),
+		certified:      make(map[types.LayerID]bool),
+		exit:           make(chan struct{}),
+	}
+}
+
+// Start starts listening to certify messages.
+func (c *Certifier) Start() {
+	if c.started {
+		return
+	}
+	go c.loop()
+	c.started = true
+}
+
+// Close stops listening to certify messages.
+func (c *Certifier) Close() {
+	close(c.exit)
+	c.started = false
+}
+
+func (c *Certifier) loop() {
+	for {
+		select {
+		case msg := <-c.messages:
+			if msg == nil {
+				c.Error("nil certify message received!")
+				continue
+			}
+			go c.handleGossipMessage(msg)
+		case msg := <-c.certificates:
+			if msg == nil {
+				c.Error("nil certificate received!")
+				continue
+			}
+			go c.handleGossipCertificate(msg)
+		case <-c.exit:
+			c.Info("listening stopped")
+			return
+		}
+	}
+}
+
+// HandleValidatedLayer gets the hare output of a layer, and signs and gossips it if this node is in the layer's
+// certifying committee.
+func (c *Certifier) HandleValidatedLayer(layer types.LayerID, blockIDs []types.BlockID) {
+	c.mu.Lock()
+	c.advance(layer)
+	c.mu.Unlock()
+
+	if c.cfg.ListenOnly {
+		return
+	}
+	proof, err := c.oracle.Proof(layer, CertifyRound)
+	if err != nil {
+		c.With().Error("could not get certify eligibility proof", layer, log.Err(err))
+		return
+	}
+	eligible, err := c.oracle.Eligible(layer, CertifyRound, c.cfg.CommitteeSize, c.identity(), proof)
+	if err != nil {
+		c.With().Error("could not check certify eligibility", layer, log.Err(err))
+		return
+	}
+	if !eligible {
+		c.With().Debug("not in the certifying committee", layer)
+		return
+	}
+
+	msg := &types.CertifyMessage{Layer: layer, BlockIDs: blockIDs, Proof: proof}
+	msg.Signature = c.signer.Sign(msg.Bytes())
+	bts, err := types.InterfaceToBytes(msg)
+	if err != nil {
+		c.With().Error("could not serialize certify message", layer, log.Err(err))
+		return
+	}
+	if err := c.net.Broadcast(Protocol, bts); err != nil {
+		c.With().Error("could not broadcast certify message", layer, log.Err(err))
+	}
+	// like other gossip messages, our own message is delivered back to us and counted once validated
+	c.With().Info("signed hare output", layer, log.Int("num_blocks", len(blockIDs)))
+}
+
+func (c *Certifier) identity() types.NodeID {
+	id, err := c.ids.GetIdentity(c.signer.PublicKey().String())
+	if err != nil {
+		// the node didn't publish an ATX yet, the oracle won't find it eligible
+		return types.NodeID{Key: c.signer.PublicKey().String()}
+	}
+	return id
+}
+
+func (c *Certifier) handleGossipMessage(gossipMessage service.GossipMessage) {
+	var msg types.CertifyMessage
+	if err := types.BytesToInterface(gossipMessage.Bytes(), &msg); err != nil {
+		c.Error("could not deserialize certify message: %v", err)
+		return
+	}
+	if c.isCertified(msg.Layer) {
+		return // the layer's certificate is gossiped instead of its signatures
+	}
+	if err := c.handleMessage(&msg); err != nil {
+		c.With().Warning("invalid certify message", msg.Layer, log.Err(err))
+		return
+	}
+	gossipMessage.ReportValidation(Protocol)
+}
+
+func (c *Certifier) handleGossipCertificate(gossipMessage service.GossipMessage) {
+	var cert types.Certificate
+	if err := types.BytesToInterface(gossipMessage.Bytes(), &cert); err != nil {
+		c.Error("could not deserialize certificate: %v", err)
+		return
+	}
+	if err := c.handleCertificate(&cert); err != nil {
+		if err != errCertified {
+			c.With().Warning("invalid certificate", cert.Layer, log.Err(err))
+		}
+		return
+	}
+	gossipMessage.ReportValidation(CertificateProtocol)
+}
+
+var (
+	errOldLayer  = errors.New("layer is too old")
+	errCertified = errors.New("layer is already certified")
+)
+
+func (c *Certifier) isCertified(layer types.LayerID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.certified[layer]
+}
+
+// validates cert and passes it to the mesh, unless its layer was already certified. Only the first certificate of a
+// layer is relayed.
+func (c *Certifier) handleCertificate(cert *types.Certificate) error {
+	c.mu.Lock()
+	old := cert.Layer+layerBuffer < c.latest
+	certified := c.certified[cert.Layer]
+	c.mu.Unlock()
+	if old {
+		return errOldLayer
+	}
+	if certified {
+		return errCertified
+	}
+
+	if err := c.ValidateCertificate(cert); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.certified[cert.Layer] {
+		c.mu.Unlock()
+		return errCertified
+	}
+	c.certified[cert.Layer] = true
+	delete(c.signatures, cert.Layer)
+	c.advance(cert.Layer)
+	c.mu.Unlock()
+
+	if err := c.msh.HandleCertificate(cert); err != nil {
+		c.With().Error("could not save certificate", cert.Layer, log.Err(err))
+	}
+	c.With().Info("layer certified", cert.Layer, log.Int("num_blocks", len(cert.BlockIDs)),
+		log.Int("num_signatures", len(cert.Signatures)))
+	return nil
+}
+
+// validates msg, and adds it to the signatures of its layer
+func (c *Certifier) handleMessage(msg *types.CertifyMessage) error {
+	c.mu.Lock()
+	old := msg.Layer+layerBuffer < c.latest
+	c.mu.Unlock()
+	if old {
+		return errOldLayer
+	}
+
+	pub, err := c.validate(msg.Layer, msg.BlockIDs, types.CertifySignature{Proof: msg.Proof, Signature: msg.Signature})
+	if err != nil {
+		return err
+	}
+	c.addSignature(msg.Layer, msg.BlockIDs, pub, types.CertifySignature{Proof: msg.Proof, Signature: msg.Signature})
+	return nil
+}
+
+// validate checks that sig is a valid signature of a certifying committee member of layer on blockIDs, and returns
+// the signer's public key.
+func (c *Certifier) validate(layer types.LayerID, blockIDs []types.BlockID, sig types.CertifySignature) (*signing.PublicKey, error) {
+	pubKey, err := ed25519.ExtractPublicKey(types.CertifiedBytes(layer, blockIDs, sig.Proof), sig.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("could not extract public key: %v", err)
+	}
+	pub := signing.NewPublicKey(pubKey)
+	if layer.GetEpoch(c.layersPerEpoch).IsGenesis() {
+		return pub, nil // like the hare, there are no identities to sample a committee from in genesis
+	}
+	id, err := c.ids.GetIdentity(pub.String())
+	if err != nil {
+		return nil, fmt.Errorf("unknown identity %v: %v", pub.ShortString(), err)
+	}
+	eligible, err := c.oracle.Eligible(layer, CertifyRound, c.cfg.CommitteeSize, id, sig.Proof)
+	if err != nil {
+		return nil, fmt.Errorf("could not check eligibility: %v", err)
+	}
+	if !eligible {
+		return nil, fmt.Errorf("identity %v is not in the certifying committee", pub.ShortString())
+	}
+	return pub, nil
+}
+
+func (c *Certifier) addSignature(layer types.LayerID, blockIDs []types.BlockID, pub *signing.PublicKey, sig types.CertifySignature) {
+	c.mu.Lock()
+	if c.certified[layer] || c.assembled[layer] {
+		c.mu.Unlock()
+		return
+	}
+	setHash := types.CalcBlocksHash32(blockIDs, nil)
+	sigs, ok := c.signatures[layer]
+	if !ok {
+		sigs = make(layerSignatures)
+		c.signatures[layer] = sigs
+	}
+	if sigs[setHash] == nil {
+		sigs[setHash] = make(map[string]types.CertifySignature)
+	}
+	sigs[setHash][pub.String()] = sig
+	if len(sigs[setHash]) < c.cfg.Threshold {
+		c.mu.Unlock()
+		return
+	}
+
+	cert := &types.Certificate{Layer: layer, BlockIDs: blockIDs, Signatures: sortedSignatures(sigs[setHash])}
+	c.assembled[layer] = true
+	delete(c.signatures, layer)
+	c.mu.Unlock()
+
+	bts, err := types.InterfaceToBytes(cert)
+	if err != nil {
+		c.With().Error("could not serialize certificate", layer, log.Err(err))
+		return
+	}
+	// our own certificate is delivered back to us, and handled like the certificates of others. It's relayed unless
+	// another certificate of the layer was received first
+	if err := c.net.Broadcast(CertificateProtocol, bts); err != nil {
+		c.With().Error("could not broadcast certificate", layer, log.Err(err))
+	}
+}
+
+func sortedSignatures(sigs map[string]types.CertifySignature) []types.CertifySignature {
+	keys := make([]string, 0, len(sigs))
+	for key := range sigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]types.CertifySignature, 0, len(sigs))
+	for _, key := range keys {
+		sorted = append(sorted, sigs[key])
+	}
+	return sorted
+}
+
+// advances the latest layer and evicts the signatures, assembled and certified flags of layers before the buffer. Must
+// be called with c.mu held.
+func (c *Certifier) advance(layer types.LayerID) {
+	if layer <= c.latest {
+		return
+	}
+	c.latest = layer
+	for lyr := range c.signatures {
+		if lyr+layerBuffer < layer {
+			delete(c.signatures, lyr)
+		}
+	}
+	for lyr := range c.assembled {
+		if lyr+layerBuffer < layer {
+			delete(c.assembled, lyr)
+		}
+	}
+	for lyr := range c.certified {
+		if lyr+layerBuffer < layer {
+			delete(c.certified, lyr)
+		}
+	}
+}
+
+// ValidateCertificate checks that cert has at least the threshold of signatures by distinct members of the layer's
+// certifying committee on its block set.
+func (c *Certifier) ValidateCertificate(cert *types.Certificate) error {
+	signers := make(map[string]struct{}, len(cert.Signatures))
+	for _, sig := range cert.Signatures {
+		pub, err := c.validate(cert.Layer, cert.BlockIDs, sig)
+		if err != nil {
+			return err
+		}
+		signers[pub.String()] = struct{}{}
+	}
+	if len(signers) < c.cfg.Threshold {
+		return fmt.Errorf("certificate has %v distinct signers, %v required", len(signers), c.cfg.Threshold)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package certifier
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+const layersPerEpoch = 10
+const layer = types.LayerID(3 * layersPerEpoch)
+
+type meshMock struct {
+	mu      sync.Mutex
+	certs   map[types.LayerID]*types.Certificate
+	handled map[types.LayerID]int
+}
+
+func newMeshMock() *meshMock {
+	return &meshMock{certs: make(map[types.LayerID]*types.Certificate), handled: make(map[types.LayerID]int)}
+}
+
+func (m *meshMock) HandleCertificate(cert *types.Certificate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.certs[cert.Layer] = cert
+	m.handled[cert.Layer]++
+	return nil
+}
+
+func (m *meshMock) certificate(layer types.LayerID) *types.Certificate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.certs[layer]
+}
+
+// an oracle that finds the identities in committee eligible
+type oracleMock struct {
+	committee map[string]bool
+}
+
+func (o *oracleMock) Eligible(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (bool, error) {
+	return round == CertifyRound && o.committee[id.Key], nil
+}
+
+func (o *oracleMock) Proof(layer types.LayerID, round int32) ([]byte, error) {
+	return []byte{1, 2, 3}, nil
+}
+
+type idsMock struct{}
+
+func (idsMock) GetIdentity(edID string) (types.NodeID, error) {
+	return types.NodeID{Key: edID}, nil
+}
+
+func createCertifiers(t *testing.T, n, committee int, cfg Config) ([]*Certifier, []*meshMock) {
+	sim := service.NewSimulator()
+	oracle := &oracleMock{committee: make(map[string]bool)}
+	certifiers := make([]*Certifier, 0, n)
+	meshes := make([]*meshMock, 0, n)
+	for i := 0; i < n; i++ {
+		sgn := signing.NewEdSigner()
+		if i < committee {
+			oracle.committee[sgn.PublicKey().String()] = true
+		}
+		msh := newMeshMock()
+		c := NewCertifier(cfg, sim.NewNode(), msh, oracle, sgn, idsMock{}, layersPerEpoch, log.NewDefault(t.Name()))
+		c.Start()
+		certifiers = append(certifiers, c)
+		meshes = append(meshes, msh)
+	}
+	return certifiers, meshes
+}
+
+func waitForCertificate(t *testing.T, msh *meshMock, layer types.LayerID) *types.Certificate {
+	timeout := time.After(5 * time.Second)
+	for {
+		if cert := msh.certificate(layer); cert != nil {
+			return cert
+		}
+		select {
+		case <-timeout:
+			require.Fail(t, "layer not certified")
+			return nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestCertifier_Certify(t *testing.T) {
+	r := require.New(t)
+	certifiers, meshes := createCertifiers(t, 4, 3, Config{CommitteeSize: 3, Threshold: 2})
+	for _, c := range certifiers {
+		defer c.Close()
+	}
+	blocks := []types.BlockID{types.NewExistingBlock(layer, []byte("data1")).ID(), types.NewExistingBlock(layer, []byte("data2")).ID()}
+
+	// one signature isn't enough for a certificate
+	certifiers[0].HandleValidatedLayer(layer, blocks)
+	time.Sleep(100 * time.Millisecond)
+	for _, msh := range meshes {
+		r.Nil(msh.certificate(layer))
+	}
+
+	certifiers[1].HandleValidatedLayer(layer, blocks)
+	for _, msh := range meshes {
+		cert := waitForCertificate(t, msh, layer)
+		r.Equal(blocks, cert.BlockIDs)
+		r.Len(cert.Signatures, 2)
+		r.NoError(certifiers[3].ValidateCertificate(cert))
+	}
+
+	// every node collected the signatures and broadcast a certificate, but only the first one is passed to the mesh,
+	// and the signatures of a certified layer aren't counted anymore
+	certifiers[2].HandleValidatedLayer(layer, blocks)
+	time.Sleep(100 * time.Millisecond)
+	for _, msh := range meshes {
+		msh.mu.Lock()
+		r.Equal(1, msh.handled[layer])
+		r.Len(msh.certs[layer].Signatures, 2)
+		msh.mu.Unlock()
+	}
+}
+
+func TestCertifier_HandleCertificate(t *testing.T) {
+	r := require.New(t)
+	certifiers, meshes := createCertifiers(t, 3, 2, Config{CommitteeSize: 2, Threshold: 2})
+	for _, c := range certifiers {
+		defer c.Close()
+	}
+	blocks := []types.BlockID{types.NewExistingBlock(layer, []byte("data1")).ID()}
+	proof := []byte{1, 2, 3}
+	cert := &types.Certificate{Layer: layer, BlockIDs: blocks}
+	for _, c := range certifiers[:2] {
+		msg := &types.CertifyMessage{Layer: layer, BlockIDs: blocks, Proof: proof}
+		cert.Signatures = append(cert.Signatures, types.CertifySignature{Proof: proof, Signature: c.signer.Sign(msg.Bytes())})
+	}
+
+	// a certificate without enough signatures isn't passed to the mesh
+	r.Error(certifiers[2].handleCertificate(&types.Certificate{Layer: layer, BlockIDs: blocks, Signatures: cert.Signatures[:1]}))
+	r.Nil(meshes[2].certificate(layer))
+
+	// only the first valid certificate of a layer is passed to the mesh and relayed
+	r.NoError(certifiers[2].handleCertificate(cert))
+	r.Equal(cert, meshes[2].certificate(layer))
+	r.Equal(errCertified, certifiers[2].handleCertificate(cert))
+	meshes[2].mu.Lock()
+	r.Equal(1, meshes[2].handled[layer])
+	meshes[2].mu.Unlock()
+}
+
+func TestCertifier_RejectsNonMembers(t *testing.T) {
+	r := require.New(t)
+	certifiers, meshes := createCertifiers(t, 3, 1, Config{CommitteeSize: 1, Threshold: 2})
+	for _, c := range certifiers {
+		defer c.Close()
+	}
+	blocks := []types.BlockID{types.NewExistingBlock(layer, []byte("data1")).ID()}
+
+	// a node outside the committee doesn't sign, and a forged signature of it is rejected
+	certifiers[1].HandleValidatedLayer(layer, blocks)
+	proof := []byte{1, 2, 3}
+	msg := &types.CertifyMessage{Layer: layer, BlockIDs: blocks, Proof: proof}
+	msg.Signature = certifiers[1].signer.Sign(msg.Bytes())
+	r.Error(certifiers[0].handleMessage(msg))
+
+	certifiers[0].HandleValidatedLayer(layer, blocks)
+	time.Sleep(100 * time.Millisecond)
+	for _, msh := range meshes {
+		r.Nil(msh.certificate(layer))
+	}
+
+	// a certificate needs the threshold of distinct signers
+	cert := &types.Certificate{Layer: layer, BlockIDs: blocks}
+	msg = &types.CertifyMessage{Layer: layer, BlockIDs: blocks, Proof: proof}
+	sig := types.CertifySignature{Proof: proof, Signature: certifiers[0].signer.Sign(msg.Bytes())}
+	cert.Signatures = []types.CertifySignature{sig, sig}
+	r.Error(certifiers[2].ValidateCertificate(cert))
+
+	// and signatures on the same block set
+	cert.Signatures = []types.CertifySignature{sig}
+	cert.BlockIDs = nil
+	r.Error(certifiers[2].ValidateCertificate(cert))
+}
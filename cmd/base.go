@@ -98,6 +98,8 @@ func parseConfig() (*bc.Config, error) {
 	}
 
 	conf := bc.DefaultConfig()
+	// environment variables override the config file
+	bc.LoadEnv(vip, os.LookupEnv)
 	// load config if it was loaded to our viper
 	err := vip.Unmarshal(&conf)
 	if err != nil {
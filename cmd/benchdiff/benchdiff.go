@@ -0,0 +1,214 @@
+// Command benchdiff compares two runs of go benchmarks and fails if a benchmark regressed by more than its threshold.
+//
+// Usage:
+//
+//	benchdiff [-threshold 0.2] [-thresholds thresholds.json] old.txt new.txt
+//
+// The inputs are the outputs of `go test -bench`, run with -benchmem to compare allocations too. When a benchmark ran
+// more than once (-count) the median of its runs is compared. Benchmarks that appear in only one of the inputs are
+// reported but never fail the comparison.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// results are the measurements of a single benchmark, one per run.
+type results struct {
+	nsPerOp     []float64
+	allocsPerOp []float64
+}
+
+// procsSuffix is the -GOMAXPROCS suffix go test appends to benchmark names.
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// parse reads the output of go test -bench and returns the results of every benchmark by name.
+func parse(r io.Reader) (map[string]*results, error) {
+	parsed := make(map[string]*results)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil { // not a result line, e.g. a log line of a benchmark
+			continue
+		}
+		name := procsSuffix.ReplaceAllString(fields[0], "")
+		res, ok := parsed[name]
+		if !ok {
+			res = &results{}
+			parsed[name] = res
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("benchmark %v: bad value %q: %v", name, fields[i], err)
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				res.nsPerOp = append(res.nsPerOp, value)
+			case "allocs/op":
+				res.allocsPerOp = append(res.allocsPerOp, value)
+			}
+		}
+	}
+	return parsed, scanner.Err()
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// delta returns the relative change from old to new, e.g. 0.1 for a 10% increase.
+func delta(old, new float64) float64 {
+	if old == 0 {
+		if new == 0 {
+			return 0
+		}
+		return 1
+	}
+	return (new - old) / old
+}
+
+// comparison is the comparison of a benchmark between the old and the new run.
+type comparison struct {
+	name                 string
+	oldNs, newNs         float64
+	oldAllocs, newAllocs float64
+	threshold            float64
+	missing              string // "old" or "new" if the benchmark is missing from that run
+}
+
+// regressed returns true if the time or the allocations per op increased by more than the threshold.
+func (c comparison) regressed() bool {
+	if c.missing != "" {
+		return false
+	}
+	return delta(c.oldNs, c.newNs) > c.threshold || delta(c.oldAllocs, c.newAllocs) > c.threshold
+}
+
+// compare compares every benchmark of the two runs, sorted by name. Benchmarks are compared against their threshold
+// in thresholds, or defaultThreshold if they have none.
+func compare(old, new map[string]*results, thresholds map[string]float64, defaultThreshold float64) []comparison {
+	names := make(map[string]struct{})
+	for name := range old {
+		names[name] = struct{}{}
+	}
+	for name := range new {
+		names[name] = struct{}{}
+	}
+
+	comparisons := make([]comparison, 0, len(names))
+	for name := range names {
+		c := comparison{name: name, threshold: defaultThreshold}
+		if t, ok := thresholds[name]; ok {
+			c.threshold = t
+		}
+		o, inOld := old[name]
+		n, inNew := new[name]
+		switch {
+		case !inOld:
+			c.missing = "old"
+		case !inNew:
+			c.missing = "new"
+		default:
+			c.oldNs, c.newNs = median(o.nsPerOp), median(n.nsPerOp)
+			c.oldAllocs, c.newAllocs = median(o.allocsPerOp), median(n.allocsPerOp)
+		}
+		comparisons = append(comparisons, c)
+	}
+	sort.Slice(comparisons, func(i, j int) bool { return comparisons[i].name < comparisons[j].name })
+	return comparisons
+}
+
+// report writes a table of the comparisons to w and returns the number of regressions.
+func report(w io.Writer, comparisons []comparison) int {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\told ns/op\tnew ns/op\tdelta\told allocs/op\tnew allocs/op\tdelta\tthreshold\t")
+	regressions := 0
+	for _, c := range comparisons {
+		if c.missing != "" {
+			fmt.Fprintf(tw, "%v\tmissing from %v run\t\t\t\t\t\t\t\n", c.name, c.missing)
+			continue
+		}
+		status := ""
+		if c.regressed() {
+			status = "REGRESSED"
+			regressions++
+		}
+		fmt.Fprintf(tw, "%v\t%.0f\t%.0f\t%+.1f%%\t%.0f\t%.0f\t%+.1f%%\t%.0f%%\t%v\n", c.name,
+			c.oldNs, c.newNs, 100*delta(c.oldNs, c.newNs),
+			c.oldAllocs, c.newAllocs, 100*delta(c.oldAllocs, c.newAllocs),
+			100*c.threshold, status)
+	}
+	tw.Flush()
+	return regressions
+}
+
+func parseFile(path string) (map[string]*results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+func main() {
+	threshold := flag.Float64("threshold", 0.2, "maximal relative increase of a benchmark's ns/op or allocs/op")
+	thresholdsPath := flag.String("thresholds", "", "json file of per-benchmark thresholds, overriding -threshold")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: benchdiff [flags] old.txt new.txt")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	thresholds := make(map[string]float64)
+	if *thresholdsPath != "" {
+		buf, err := ioutil.ReadFile(*thresholdsPath)
+		if err == nil {
+			err = json.Unmarshal(buf, &thresholds)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read thresholds: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	old, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot parse old results: %v\n", err)
+		os.Exit(2)
+	}
+	new, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot parse new results: %v\n", err)
+		os.Exit(2)
+	}
+
+	if regressions := report(os.Stdout, compare(old, new, thresholds, *threshold)); regressions > 0 {
+		fmt.Fprintf(os.Stderr, "%v benchmarks regressed\n", regressions)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const oldRun = `goos: linux
+goarch: amd64
+pkg: github.com/spacemeshos/go-spacemesh/signing
+BenchmarkVerify-8   	   20000	     60000 ns/op
+BenchmarkVerify-8   	   20000	     62000 ns/op
+BenchmarkVerify-8   	   20000	     90000 ns/op
+BenchmarkBlock_Serialize-8   	  100000	     10000 ns/op	    4096 B/op	      10 allocs/op
+BenchmarkRemoved-8   	  100000	     10000 ns/op
+PASS
+ok  	github.com/spacemeshos/go-spacemesh/signing	5.1s
+`
+
+const newRun = `BenchmarkVerify-4   	   20000	     61000 ns/op
+BenchmarkVerify-4   	   20000	     63000 ns/op
+BenchmarkBlock_Serialize-4   	  100000	     10100 ns/op	    4096 B/op	      20 allocs/op
+BenchmarkAdded-4   	  100000	     10000 ns/op
+BenchmarkLogging	some log line of the benchmark
+`
+
+func TestParse(t *testing.T) {
+	r := require.New(t)
+	res, err := parse(strings.NewReader(oldRun))
+	r.NoError(err)
+	r.Len(res, 3)
+	r.Equal([]float64{60000, 62000, 90000}, res["BenchmarkVerify"].nsPerOp)
+	r.Equal([]float64{10}, res["BenchmarkBlock_Serialize"].allocsPerOp)
+
+	_, err = parse(strings.NewReader("BenchmarkBad-8 10 fast ns/op\n"))
+	r.Error(err)
+}
+
+func TestCompare(t *testing.T) {
+	r := require.New(t)
+	old, err := parse(strings.NewReader(oldRun))
+	r.NoError(err)
+	new, err := parse(strings.NewReader(newRun))
+	r.NoError(err)
+
+	comparisons := compare(old, new, map[string]float64{"BenchmarkVerify": 0.05}, 0.2)
+	r.Len(comparisons, 4)
+	byName := make(map[string]comparison)
+	for _, c := range comparisons {
+		byName[c.name] = c
+	}
+
+	// medians: 62000 and 62000
+	verify := byName["BenchmarkVerify"]
+	r.Equal(62000.0, verify.oldNs)
+	r.Equal(62000.0, verify.newNs)
+	r.Equal(0.05, verify.threshold)
+	r.False(verify.regressed())
+
+	// time is within the threshold but allocations doubled
+	r.True(byName["BenchmarkBlock_Serialize"].regressed())
+
+	r.Equal("new", byName["BenchmarkRemoved"].missing)
+	r.Equal("old", byName["BenchmarkAdded"].missing)
+	r.False(byName["BenchmarkAdded"].regressed())
+
+	var out bytes.Buffer
+	r.Equal(1, report(&out, comparisons))
+	r.Contains(out.String(), "REGRESSED")
+}
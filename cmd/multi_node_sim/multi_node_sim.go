@@ -19,6 +19,7 @@ type Config struct {
 	BlocksPerLayer int
 	RunUntilLayer  uint32
 	DbLocation     string
+	DoubleSpend    bool
 }
 
 // AddCommands adds commands for multi node sim
@@ -31,6 +32,8 @@ func AddCommands(cmd *cobra.Command) {
 		10, "number of nodes")
 	cmd.PersistentFlags().Uint32VarP(&multiConfig.RunUntilLayer, "layer", "l",
 		10, "run until layer")
+	cmd.PersistentFlags().BoolVar(&multiConfig.DoubleSpend, "double-spend",
+		false, "flood the network with conflicting transactions and equivocating blocks")
 }
 
 // Cmd is node simulator cmd
@@ -38,6 +41,10 @@ var Cmd = &cobra.Command{
 	Use:   "run_sim",
 	Short: "start simulation",
 	Run: func(cmd *cobra.Command, args []string) {
+		if multiConfig.DoubleSpend {
+			node.StartDoubleSpendStress(multiConfig.NumberOfNodes, multiConfig.BlocksPerLayer, multiConfig.RunUntilLayer, multiConfig.DbLocation)
+			return
+		}
 		node.StartMultiNode(multiConfig.NumberOfNodes, multiConfig.BlocksPerLayer, multiConfig.RunUntilLayer, multiConfig.DbLocation)
 	},
 }
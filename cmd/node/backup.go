@@ -0,0 +1,289 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	apiCfg "github.com/spacemeshos/go-spacemesh/api/config"
+	"github.com/spacemeshos/go-spacemesh/api/pb"
+	"github.com/spacemeshos/go-spacemesh/backup"
+	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spf13/cobra"
+	"github.com/syndtr/goleveldb/leveldb"
+	"google.golang.org/grpc"
+)
+
+// storeSchemaVersions is the version of the data layout of every store, by path relative to its store directory. Bump
+// the version of a store whenever its layout changes, so that old backups are not restored into incompatible nodes.
+var storeSchemaVersions = map[string]uint32{
+	"state":             1,
+	"atx":               4,
+	"poet":              1,
+	"ids":               1,
+	"store":             1,
+	"malfeasance":       1,
+	"beacon":            1,
+	"appliedTxs":        1,
+	"replication":       1,
+	"hare":              1,
+	"mesh/blocks":       2,
+	"mesh/layers":       1,
+	"mesh/validity":     1,
+	"mesh/transactions": 1,
+	"mesh/general":      2,
+	"mesh/unappliedTxs": 1,
+}
+
+var backupDir string
+
+// BackupCmd asks a running node to back up its databases.
+var BackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "back up the databases of a running node",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := LoadConfigFromFile()
+		if err != nil {
+			log.With().Error("cannot load config", log.Err(err))
+			return
+		}
+		cmdp.EnsureCLIFlags(cmd.Root(), cfg)
+		// the node writes the backup under its data directory, relative paths are relative to it
+		dir, err := backupPath(cfg.DataDir(), backupDir)
+		if err != nil {
+			log.With().Error("backup failed", log.Err(err))
+			return
+		}
+		manifest, err := requestBackup(cfg.API.AdminListener, dir)
+		if err != nil {
+			log.With().Error("backup failed", log.Err(err))
+			return
+		}
+		log.With().Info("backup created", log.String("dir", dir), log.LayerID(manifest.Layer.Uint64()))
+	},
+}
+
+// RestoreCmd restores the databases of a stopped node from a backup.
+var RestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "restore the databases of a stopped node from a backup",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := LoadConfigFromFile()
+		if err != nil {
+			log.With().Error("cannot load config", log.Err(err))
+			return
+		}
+		cmdp.EnsureCLIFlags(cmd.Root(), cfg)
+		manifest, err := Restore(cfg, backupDir)
+		if err != nil {
+			log.With().Error("restore failed", log.Err(err))
+			return
+		}
+		log.With().Info("backup restored", log.String("data_dir", cfg.DataDir()), log.LayerID(manifest.Layer.Uint64()))
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{BackupCmd, RestoreCmd} {
+		cmd.Flags().StringVar(&backupDir, "backup-dir", "", "directory of the backup")
+		cmd.MarkFlagRequired("backup-dir")
+	}
+}
+
+// requestBackup asks the node to back up its databases through the admin api, which it serves on adminListener.
+func requestBackup(adminListener, dir string) (*backup.Manifest, error) {
+	conn, err := grpc.Dial(adminListener, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to node: %v", err)
+	}
+	defer conn.Close()
+	res, err := pb.NewSpacemeshServiceClient(conn).Backup(context.Background(), &pb.SimpleMessage{Value: dir})
+	if err != nil {
+		return nil, err
+	}
+	manifest := &backup.Manifest{}
+	if err := json.Unmarshal([]byte(res.Value), manifest); err != nil {
+		return nil, fmt.Errorf("cannot parse manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+// genesisID identifies the network a node belongs to, it's derived from the genesis time, the hash of the protocol
+// config and the genesis accounts.
+func genesisID(cfg *config.Config) (string, error) {
+	genesis := apiCfg.DefaultGenesisConfig()
+	if cfg.GenesisConfPath != "" {
+		var err error
+		if genesis, err = apiCfg.LoadGenesisConfig(cfg.GenesisConfPath); err != nil {
+			return "", fmt.Errorf("cannot load genesis config: %v", err)
+		}
+	}
+	ids := make([]string, 0, len(genesis.InitialAccounts))
+	for id := range genesis.InitialAccounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	hash := sha256.New()
+	protocolHash := cfg.Protocol().Hash()
+	fmt.Fprintf(hash, "%s/%x", cfg.GenesisTime, protocolHash.Bytes())
+	for _, id := range ids {
+		account := genesis.InitialAccounts[id]
+		fmt.Fprintf(hash, "/%s:%d:%d", id, account.Balance, account.Nonce)
+	}
+	return util.Bytes2Hex(hash.Sum(nil)), nil
+}
+
+// storeDir returns the directory that holds the store with the given name, the node's data directory unless the store
+// is mapped to another directory in the config.
+func (app *SpacemeshApp) storeDir(name string) string {
+	return app.Config.StoreDir(app.dbStorepath, name)
+}
+
+// storeName returns the name of the store at path, as it appears in storeSchemaVersions.
+func (app *SpacemeshApp) storeName(path string) (string, error) {
+	for name := range storeSchemaVersions {
+		if filepath.Join(app.storeDir(name), filepath.FromSlash(name)) == path {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("store %v has no schema version", path)
+}
+
+// newStore opens the store with the given name in its store directory. The store is closed when the node stops and
+// included in backups.
+func (app *SpacemeshApp) newStore(name string, logger log.Log) (*database.LDBDatabase, error) {
+	db, err := database.NewLDBDatabase(filepath.Join(app.storeDir(name), name), 0, 0, logger)
+	if err != nil {
+		return nil, err
+	}
+	app.closers = append(app.closers, db)
+	app.stores = append(app.stores, db)
+	return db, nil
+}
+
+// backupPath returns the absolute path of the backup directory dir, which must be under dataDir, so that the node
+// doesn't write files anywhere a client of the api asks it to. A relative dir is relative to dataDir.
+func backupPath(dataDir, dir string) (string, error) {
+	dataDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(dataDir, dir)
+	}
+	dir = filepath.Clean(dir)
+	rel, err := filepath.Rel(dataDir, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("backup directory %v is not under the data directory %v", dir, dataDir)
+	}
+	return dir, nil
+}
+
+// Backup writes a copy of all the node's stores to dir, taken while no layer is applied to state. The stores are
+// snapshotted together and copied afterwards, so the node only pauses applying layers for a moment. dir must be under
+// the node's data directory.
+func (app *SpacemeshApp) Backup(dir string) (*backup.Manifest, error) {
+	dir, err := backupPath(app.Config.DataDir(), dir)
+	if err != nil {
+		return nil, err
+	}
+	if files, err := ioutil.ReadDir(dir); err == nil && len(files) > 0 {
+		return nil, fmt.Errorf("backup directory %v is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	genesis, err := genesisID(app.Config)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &backup.Manifest{
+		FormatVersion: backup.FormatVersion,
+		GenesisID:     genesis,
+		NodeVersion:   cmdp.Version,
+		Created:       time.Now().UTC(),
+	}
+	for _, store := range app.stores {
+		path, err := app.storeName(store.Path())
+		if err != nil {
+			return nil, err
+		}
+		manifest.Stores = append(manifest.Stores, backup.Store{Path: path, SchemaVersion: storeSchemaVersions[path]})
+	}
+
+	snapshots := make([]*leveldb.Snapshot, 0, len(app.stores))
+	defer func() {
+		for _, snap := range snapshots {
+			snap.Release()
+		}
+	}()
+	err = app.mesh.WithStateLocked(func(layer types.LayerID) error {
+		manifest.Layer = layer
+		for _, store := range app.stores {
+			snap, err := store.Snapshot()
+			if err != nil {
+				return fmt.Errorf("cannot snapshot %v: %v", store.Path(), err)
+			}
+			snapshots = append(snapshots, snap)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	app.log.With().Info("backing up stores", log.String("dir", dir), log.LayerID(manifest.Layer.Uint64()))
+
+	for i, snap := range snapshots {
+		if err := database.WriteSnapshot(snap, filepath.Join(dir, filepath.FromSlash(manifest.Stores[i].Path))); err != nil {
+			return nil, err
+		}
+	}
+	if err := backup.WriteManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore restores the backup in dir into the data directory of a node with the given config. The node must be
+// stopped and must not have any of the stores in the backup. The store directories are locked meanwhile, so that a
+// running node doesn't open the stores while they're restored.
+func Restore(cfg *config.Config, dir string) (*backup.Manifest, error) {
+	app := NewSpacemeshApp()
+	app.Config = cfg
+	if err := app.lockStoreDirs(app.storeDirs()); err != nil {
+		return nil, err
+	}
+	defer app.unlockStoreDirs()
+
+	manifest, err := backup.ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := genesisID(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.Verify(genesis, storeSchemaVersions); err != nil {
+		return nil, err
+	}
+	storeDir := func(path string) string {
+		return cfg.StoreDir(cfg.DataDir(), path)
+	}
+	if err := backup.Restore(dir, storeDir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,83 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/spacemeshos/go-spacemesh/backup"
+	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/eligibility"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpacemeshApp_BackupRestore(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "backup")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := getTestDefaultConfig()
+	r.NotNil(cfg)
+	cfg.EligibilityOracle = config.PowEligibilityOracle
+	cfg.StoreDirs = map[string]string{"mesh": filepath.Join(dir, "mesh-disk")}
+	genesisTime := time.Now().Format(time.RFC3339)
+	app, err := InitSingleInstance(*cfg, 0, genesisTime, BLS381.DefaultSeed(), filepath.Join(dir, "node"),
+		eligibility.New(), mockPoetClient{}, NewManualClock(time.Now()), service.NewSimulator())
+	r.NoError(err)
+	r.NoError(app.stores[0].Put([]byte("key"), []byte("value")))
+	r.DirExists(filepath.Join(dir, "mesh-disk", fmt.Sprint(cfg.P2P.NetworkID), "mesh", "blocks"))
+
+	// backups are only written under the data directory
+	_, err = app.Backup(filepath.Join(dir, "backup"))
+	r.Error(err)
+	_, err = app.Backup(filepath.Join("..", "backup"))
+	r.Error(err)
+	backupDir := filepath.Join(app.Config.DataDir(), "backup")
+	manifest, err := app.Backup("backup")
+	r.NoError(err)
+	r.Len(manifest.Stores, len(storeSchemaVersions)-1) // replication is disabled
+	_, err = app.Backup(backupDir)
+	r.Error(err)
+	written, err := backup.ReadManifest(backupDir)
+	r.NoError(err)
+	r.Equal(manifest.GenesisID, written.GenesisID)
+	for _, closer := range app.closers {
+		closer.Close()
+	}
+	app.mesh.Close()
+
+	restoreCfg := *app.Config
+	restoreCfg.DataDirParent = filepath.Join(dir, "restored")
+	restoreCfg.StoreDirs = map[string]string{"state": filepath.Join(dir, "state-disk")}
+	// a backup isn't restored under a running node
+	running := NewSpacemeshApp()
+	running.Config = &restoreCfg
+	r.NoError(running.lockStoreDirs(running.storeDirs()))
+	_, err = Restore(&restoreCfg, backupDir)
+	r.Error(err)
+	running.unlockStoreDirs()
+	_, err = Restore(&restoreCfg, backupDir)
+	r.NoError(err)
+	r.DirExists(filepath.Join(restoreCfg.DataDir(), "mesh", "blocks"))
+	r.Equal("state", manifest.Stores[0].Path)
+	state, err := database.NewLDBDatabase(filepath.Join(dir, "state-disk", fmt.Sprint(restoreCfg.P2P.NetworkID), "state"), 0, 0, log.NewDefault("state"))
+	r.NoError(err)
+	defer state.Close()
+	value, err := state.Get([]byte("key"))
+	r.NoError(err)
+	r.Equal([]byte("value"), value)
+
+	// a backup can only be restored by nodes of the same network
+	restoreCfg.DataDirParent = filepath.Join(dir, "other")
+	restoreCfg.GenesisTime = time.Now().Add(time.Hour).Format(time.RFC3339)
+	_, err = Restore(&restoreCfg, backupDir)
+	r.Error(err)
+}
@@ -0,0 +1,175 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/spacemeshos/go-spacemesh/activation"
+	apiCfg "github.com/spacemeshos/go-spacemesh/api/config"
+	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/eligibility"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spf13/cobra"
+)
+
+// DevnetConfig is the configuration of a local devnet.
+type DevnetConfig struct {
+	Nodes         int
+	Accounts      int
+	Balance       uint64
+	LayerDuration time.Duration
+	DataDir       string
+}
+
+var devnetConfig = DevnetConfig{}
+
+// DevnetCmd starts a local chain for application developers: a number of in-process nodes connected by an in-memory
+// network, that use proof-of-work eligibility instead of PoET and PoST and advance layers on a fast manual clock.
+var DevnetCmd = &cobra.Command{
+	Use:   "devnet",
+	Short: "start a local development network",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := getTestDefaultConfig()
+		if cfg == nil {
+			return
+		}
+		cfg.PowDifficulty = 8
+		// the config flags are persistent flags of the root command
+		cmdp.EnsureCLIFlags(cmd.Root(), cfg)
+		if err := StartDevnet(cfg, devnetConfig); err != nil {
+			log.With().Error("devnet failed", log.Err(err))
+		}
+	},
+}
+
+func init() {
+	DevnetCmd.Flags().IntVarP(&devnetConfig.Nodes, "nodes", "n", 3, "number of in-process nodes")
+	DevnetCmd.Flags().IntVar(&devnetConfig.Accounts, "accounts", 10, "number of funded accounts created at genesis")
+	DevnetCmd.Flags().Uint64Var(&devnetConfig.Balance, "balance", uint64(math.Pow10(17)), "genesis balance of each funded account, in smidge")
+	DevnetCmd.Flags().DurationVar(&devnetConfig.LayerDuration, "layer-duration", time.Second, "time between layer ticks")
+	DevnetCmd.Flags().StringVar(&devnetConfig.DataDir, "dir", "", "directory to store the nodes' databases (defaults to a temporary directory)")
+}
+
+// mockPoetClient is a PoET client that never talks to a PoET server. Nodes in a devnet don't publish ATXs, so it only
+// satisfies the NIPST builder's dependency.
+type mockPoetClient struct{}
+
+func (mockPoetClient) Submit(types.Hash32) (*types.PoetRound, error) {
+	return &types.PoetRound{ID: "devnet"}, nil
+}
+
+func (mockPoetClient) PoetServiceID() ([]byte, error) {
+	return []byte("devnet"), nil
+}
+
+func (mockPoetClient) RoundTimes() (*activation.PoetRoundTimes, error) {
+	return &activation.PoetRoundTimes{OpenRoundID: "devnet"}, nil
+}
+
+// devnetGenesis creates numOfAccounts funded accounts and writes them as a genesis config to path. It returns the
+// signers of the accounts.
+func devnetGenesis(path string, numOfAccounts int, balance uint64) ([]*signing.EdSigner, error) {
+	accounts := make([]*signing.EdSigner, 0, numOfAccounts)
+	genesis := apiCfg.GenesisConfig{InitialAccounts: make(map[string]apiCfg.GenesisAccount, numOfAccounts)}
+	for i := 0; i < numOfAccounts; i++ {
+		sgn := signing.NewEdSigner()
+		accounts = append(accounts, sgn)
+		genesis.InitialAccounts["0x"+util.Bytes2Hex(sgn.PublicKey().Bytes())] = apiCfg.GenesisAccount{
+			Balance: new(big.Int).SetUint64(balance),
+		}
+	}
+	if err := apiCfg.SaveGenesisConfig(path, genesis); err != nil {
+		return nil, fmt.Errorf("failed to save genesis config: %v", err)
+	}
+	return accounts, nil
+}
+
+// StartDevnet runs a local devnet until the node's main context is canceled (e.g. on ctrl-c). Only the first node
+// serves the gRPC API.
+func StartDevnet(cfg *config.Config, devnet DevnetConfig) error {
+	if devnet.Nodes < 1 {
+		return fmt.Errorf("devnet requires at least one node, got %d", devnet.Nodes)
+	}
+
+	// exit gracefully - e.g. with app Cleanup on sig abort (ctrl-c)
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	go func() {
+		for range signalChan {
+			log.Info("Received an interrupt, stopping devnet...")
+			cmdp.Cancel()
+		}
+	}()
+
+	dir := devnet.DataDir
+	if dir == "" {
+		tmp, err := ioutil.TempDir("", "spacemesh-devnet")
+		if err != nil {
+			return fmt.Errorf("failed to create data dir: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+
+	cfg.EligibilityOracle = config.PowEligibilityOracle
+	cfg.HARE.SuperHare = true
+	cfg.LayerAvgSize = devnet.Nodes
+	cfg.GenesisConfPath = filepath.Join(dir, "genesis.json")
+	accounts, err := devnetGenesis(cfg.GenesisConfPath, devnet.Accounts, devnet.Balance)
+	if err != nil {
+		return err
+	}
+
+	net := service.NewSimulator()
+	genesisTime := time.Now()
+	clock := NewManualClock(genesisTime)
+	rolacle := eligibility.New()
+	rng := BLS381.DefaultSeed()
+
+	apps := make([]*SpacemeshApp, 0, devnet.Nodes)
+	for i := 0; i < devnet.Nodes; i++ {
+		storePath := filepath.Join(dir, strconv.Itoa(i))
+		app, err := InitSingleInstance(*cfg, i, genesisTime.Format(time.RFC3339), rng, storePath, rolacle, mockPoetClient{}, clock, net)
+		if err != nil {
+			return fmt.Errorf("failed to initialize node %d: %v", i, err)
+		}
+		apps = append(apps, app)
+	}
+	for _, app := range apps {
+		app.startServices()
+	}
+	defer GracefulShutdown(apps)
+	ActivateGrpcServer(apps[0])
+
+	log.Info("devnet started with %d nodes, gRPC API listening on port %d", devnet.Nodes, cfg.API.GrpcServerPort)
+	for _, sgn := range accounts {
+		log.Info("funded account %v private key: %v", types.PublicKeyToAddress(sgn.PublicKey().Bytes()),
+			"0x"+util.Bytes2Hex(sgn.ToBuffer()))
+	}
+
+	ticker := time.NewTicker(devnet.LayerDuration)
+	defer ticker.Stop()
+	clock.Tick()
+	for {
+		select {
+		case <-cmdp.Ctx.Done():
+			return nil
+		case <-ticker.C:
+			clock.Tick()
+			log.Info("devnet layer %v", clock.GetCurrentLayer())
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/filesystem"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p"
+	"github.com/spacemeshos/go-spacemesh/sync"
+)
+
+// diagnosticsDir is the directory under the data dir that diagnostic dumps are written to.
+const diagnosticsDir = "diagnostics"
+
+// diagnostics is a structured dump of the node's state, written when the node crashes or is asked to, so that reports
+// of stalls come with the context to act on.
+type diagnostics struct {
+	Time    time.Time
+	Reason  string
+	Version string
+	Layer   types.LayerID
+	P2P     *p2p.Snapshot        `json:",omitempty"` // nil if p2p didn't start
+	Repair  *sync.RepairProgress `json:",omitempty"` // nil if the syncer didn't start
+	Stacks  string               // the stacks of all goroutines
+}
+
+// writeDiagnostics writes a diagnostic dump to the diagnostics directory and returns its path.
+func (app *SpacemeshApp) writeDiagnostics(reason string) (string, error) {
+	d := diagnostics{Time: time.Now().UTC(), Reason: reason, Version: cmdp.Version}
+	if app.clock != nil {
+		d.Layer = app.clock.GetCurrentLayer()
+	}
+	if swarm, ok := app.P2P.(interface{ Snapshot() p2p.Snapshot }); ok {
+		snap := swarm.Snapshot()
+		d.P2P = &snap
+	}
+	if app.syncer != nil {
+		repair := app.syncer.RepairProgress()
+		d.Repair = &repair
+	}
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			d.Stacks = string(buf[:n])
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(app.Config.DataDir(), diagnosticsDir)
+	if err := filesystem.ExistOrCreate(dir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("dump-%v.json", d.Time.Format("20060102-150405.000")))
+	return path, ioutil.WriteFile(path, b, 0644)
+}
+
+// logDiagnostics writes a diagnostic dump and logs where it was written.
+func (app *SpacemeshApp) logDiagnostics(reason string) {
+	path, err := app.writeDiagnostics(reason)
+	if err != nil {
+		log.Error("failed to write diagnostic dump: %v", err)
+		return
+	}
+	log.With().Info("wrote diagnostic dump", log.String("path", path), log.String("reason", reason))
+}
+
+// dumpOnPanic writes a diagnostic dump if the calling goroutine panics, and then panics again. It must be deferred.
+func (app *SpacemeshApp) dumpOnPanic() {
+	if r := recover(); r != nil {
+		app.logDiagnostics(fmt.Sprintf("panic: %v", r))
+		panic(r)
+	}
+}
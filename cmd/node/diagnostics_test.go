@@ -0,0 +1,32 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpacemeshApp_writeDiagnostics(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	app := NewSpacemeshApp()
+	app.Config.DataDirParent = dir
+	path, err := app.writeDiagnostics("test")
+	r.NoError(err)
+	r.Equal(filepath.Join(app.Config.DataDir(), diagnosticsDir), filepath.Dir(path))
+
+	b, err := ioutil.ReadFile(path)
+	r.NoError(err)
+	var d diagnostics
+	r.NoError(json.Unmarshal(b, &d))
+	r.Equal("test", d.Reason)
+	r.Nil(d.P2P)
+	r.Contains(d.Stacks, "TestSpacemeshApp_writeDiagnostics")
+}
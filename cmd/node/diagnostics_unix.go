@@ -0,0 +1,26 @@
+// +build !windows
+
+package node
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// dumpOnSignal writes a diagnostic dump whenever the node receives SIGUSR1, until the node stops.
+func (app *SpacemeshApp) dumpOnSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-app.term:
+				return
+			case <-sig:
+				app.logDiagnostics("SIGUSR1")
+			}
+		}
+	}()
+}
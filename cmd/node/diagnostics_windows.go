@@ -0,0 +1,4 @@
+package node
+
+// dumpOnSignal does nothing, windows has no signal to ask for a diagnostic dump.
+func (app *SpacemeshApp) dumpOnSignal() {}
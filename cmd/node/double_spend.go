@@ -0,0 +1,162 @@
+package node
+
+import (
+	"fmt"
+	"time"
+
+	apiCfg "github.com/spacemeshos/go-spacemesh/api/config"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/miner"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// DoubleSpendGenerator generates a stress scenario in which the network is flooded with conflicting transactions
+// (several variants spending the same nonce of the same account) and with equivocating blocks (two different blocks
+// signed by the same miner for the same eligibility). It is used to validate the mempool acceptance rules, hare
+// robustness and tortoise convergence under adversarial load.
+type DoubleSpendGenerator struct {
+	signer     *signing.EdSigner
+	origin     types.Address
+	recipients []types.Address
+	nonces     int
+	amount     uint64
+	fee        uint64
+}
+
+// NewDoubleSpendGenerator creates a generator spending nonces [0, nonces) of the account controlled by signer. Each
+// nonce is spent in variants different transactions, each one sending amount to a different recipient.
+func NewDoubleSpendGenerator(signer *signing.EdSigner, nonces, variants int, amount uint64) *DoubleSpendGenerator {
+	recipients := make([]types.Address, 0, variants)
+	for i := 0; i < variants; i++ {
+		recipients = append(recipients, types.BytesToAddress([]byte{0xde, 0xad, byte(i + 1)}))
+	}
+	return &DoubleSpendGenerator{
+		signer:     signer,
+		origin:     types.PublicKeyToAddress(signer.PublicKey().Bytes()),
+		recipients: recipients,
+		nonces:     nonces,
+		amount:     amount,
+		fee:        1,
+	}
+}
+
+// NewDefaultDoubleSpendGenerator creates a generator spending from the second genesis test account.
+func NewDefaultDoubleSpendGenerator(nonces, variants int) (*DoubleSpendGenerator, error) {
+	sgn, err := signing.NewEdSignerFromBuffer(util.FromHex(apiCfg.Account2Private))
+	if err != nil {
+		return nil, fmt.Errorf("could not build ed signer: %v", err)
+	}
+	return NewDoubleSpendGenerator(sgn, nonces, variants, 10), nil
+}
+
+// ConflictingTxs returns all the generated transactions, grouped by nonce. All the transactions in a group spend the
+// same nonce and conflict with each other.
+func (g *DoubleSpendGenerator) ConflictingTxs() ([][]*types.Transaction, error) {
+	groups := make([][]*types.Transaction, 0, g.nonces)
+	for nonce := 0; nonce < g.nonces; nonce++ {
+		group := make([]*types.Transaction, 0, len(g.recipients))
+		for _, rec := range g.recipients {
+			tx, err := mesh.NewSignedTx(uint64(nonce), rec, g.amount, 1, g.fee, g.signer)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create signed tx: %v", err)
+			}
+			group = append(group, tx)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// FloodTxs broadcasts all the conflicting transactions. Each variant of a nonce is gossiped by a different node, so
+// that different nodes see the variants in different orders.
+func (g *DoubleSpendGenerator) FloodTxs(apps []*SpacemeshApp) error {
+	groups, err := g.ConflictingTxs()
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		for i, tx := range group {
+			bytes, err := types.InterfaceToBytes(tx)
+			if err != nil {
+				return fmt.Errorf("failed to serialize tx: %v", err)
+			}
+			app := apps[i%len(apps)]
+			if err := app.P2P.Broadcast(miner.IncomingTxProtocol, bytes); err != nil {
+				return fmt.Errorf("failed to broadcast tx %v: %v", tx.ID().ShortString(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// EquivocateBlock returns a block that conflicts with blk: it is signed by the same miner with the same eligibility
+// proof, but has a different content (no transactions and a different timestamp), and therefore a different ID.
+func EquivocateBlock(blk *types.Block, sgn *signing.EdSigner) (*types.Block, error) {
+	mb := blk.MiniBlock
+	mb.Timestamp = time.Now().UnixNano()
+	mb.TxIDs = nil
+	bytes, err := types.InterfaceToBytes(mb)
+	if err != nil {
+		return nil, err
+	}
+	eq := &types.Block{MiniBlock: mb, Signature: sgn.Sign(bytes)}
+	eq.Initialize()
+	return eq, nil
+}
+
+// FloodEquivocatingBlocks makes every node publish an equivocating version of each of the blocks it created in the
+// given layer.
+func (g *DoubleSpendGenerator) FloodEquivocatingBlocks(apps []*SpacemeshApp, layer types.LayerID) error {
+	for _, app := range apps {
+		if app.edSgn == nil {
+			continue
+		}
+		blocks, err := app.mesh.LayerBlocks(layer)
+		if err != nil {
+			return fmt.Errorf("failed to read layer %v: %v", layer, err)
+		}
+		for _, blk := range blocks {
+			if blk.MinerID().String() != app.edSgn.PublicKey().String() {
+				continue
+			}
+			eq, err := EquivocateBlock(blk, app.edSgn)
+			if err != nil {
+				return fmt.Errorf("failed to equivocate block %v: %v", blk.ID(), err)
+			}
+			bytes, err := types.InterfaceToBytes(eq)
+			if err != nil {
+				return fmt.Errorf("failed to serialize block: %v", err)
+			}
+			log.With().Info("publishing equivocating block", blk.ID(), log.String("equivocation", eq.ID().String()))
+			if err := app.P2P.Broadcast(config.NewBlockProtocol, bytes); err != nil {
+				return fmt.Errorf("failed to broadcast block %v: %v", eq.ID(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Converged returns nil if all nodes applied exactly one variant of every nonce and agree on the resulting state.
+func (g *DoubleSpendGenerator) Converged(apps []*SpacemeshApp) error {
+	root := apps[0].state.GetStateRoot()
+	for _, app := range apps {
+		if r := app.state.GetStateRoot(); r != root {
+			return fmt.Errorf("node %v state root %v differs from %v", app.nodeID.ShortString(), r.ShortString(), root.ShortString())
+		}
+		if nonce := app.state.GetNonce(g.origin); nonce != uint64(g.nonces) {
+			return fmt.Errorf("node %v origin nonce is %v, expected %v", app.nodeID.ShortString(), nonce, g.nonces)
+		}
+		received := uint64(0)
+		for _, rec := range g.recipients {
+			received += app.state.GetBalance(rec)
+		}
+		if expected := uint64(g.nonces) * g.amount; received != expected {
+			return fmt.Errorf("node %v recipients received %v, expected %v", app.nodeID.ShortString(), received, expected)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package node
+
+import (
+	"fmt"
+
+	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
+	"github.com/spacemeshos/go-spacemesh/log"
+	p2pnode "github.com/spacemeshos/go-spacemesh/p2p/node"
+	"github.com/spf13/cobra"
+)
+
+var forceIdentity bool
+
+// IdentityCmd groups the commands that replace the node's p2p identity. They run once, on a stopped node, and the node
+// keeps the identity they persist across restarts.
+var IdentityCmd = &cobra.Command{
+	Use:   "identity",
+	Short: "replace the node's p2p identity",
+}
+
+// NewIdentityCmd creates a new p2p identity for the node.
+var NewIdentityCmd = &cobra.Command{
+	Use:   "new",
+	Short: "create a new p2p identity, the current identity file is kept in a backup file",
+	Run: func(cmd *cobra.Command, args []string) {
+		runIdentityCmd(cmd, func(dataDir string) (p2pnode.LocalNode, error) {
+			return p2pnode.NewIdentity(dataDir, forceIdentity)
+		})
+	},
+}
+
+// ImportIdentityCmd imports the node's p2p identity from an identity file.
+var ImportIdentityCmd = &cobra.Command{
+	Use:   "import <identity-file>",
+	Short: "import the p2p identity from the given identity file, the current identity file is kept in a backup file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runIdentityCmd(cmd, func(dataDir string) (p2pnode.LocalNode, error) {
+			return p2pnode.ImportIdentity(dataDir, args[0], forceIdentity)
+		})
+	},
+}
+
+func init() {
+	IdentityCmd.AddCommand(NewIdentityCmd)
+	IdentityCmd.AddCommand(ImportIdentityCmd)
+	for _, cmd := range []*cobra.Command{NewIdentityCmd, ImportIdentityCmd} {
+		cmd.Flags().BoolVar(&forceIdentity, "force", false, "replace the node's existing identity")
+	}
+}
+
+func runIdentityCmd(cmd *cobra.Command, replace func(dataDir string) (p2pnode.LocalNode, error)) {
+	cfg, err := LoadConfigFromFile()
+	if err != nil {
+		log.With().Error("cannot load config", log.Err(err))
+		return
+	}
+	cmdp.EnsureCLIFlags(cmd.Root(), cfg)
+	app := NewSpacemeshApp()
+	app.Config = cfg
+	id, err := app.replaceIdentity(replace)
+	if err != nil {
+		log.With().Error("cannot replace p2p identity", log.Err(err))
+		return
+	}
+	log.With().Info("p2p identity saved", log.String("node_id", id.PublicKey().String()),
+		log.String("data_dir", cfg.DataDir()))
+}
+
+// replaceIdentity persists the identity that replace returns in the node's data directory. The data directory is
+// locked meanwhile, so that the identity of a running node isn't replaced under it.
+func (app *SpacemeshApp) replaceIdentity(replace func(dataDir string) (p2pnode.LocalNode, error)) (p2pnode.LocalNode, error) {
+	if err := app.lockStoreDirs([]string{app.Config.DataDir()}); err != nil {
+		return p2pnode.LocalNode{}, err
+	}
+	defer app.unlockStoreDirs()
+	id, err := replace(app.Config.DataDir())
+	if err == p2pnode.ErrIdentityExists {
+		return id, fmt.Errorf("%v in %v, use --force to replace it", err, p2pnode.IdentityFile(app.Config.DataDir()))
+	}
+	return id, err
+}
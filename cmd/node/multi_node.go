@@ -12,8 +12,11 @@ import (
 	"github.com/spacemeshos/go-spacemesh/eligibility"
 	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/miner"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/state"
 	"github.com/spacemeshos/go-spacemesh/timesync"
 	"strconv"
 	"sync"
@@ -157,7 +160,7 @@ func getTestDefaultConfig() *config.Config {
 func ActivateGrpcServer(smApp *SpacemeshApp) {
 	smApp.Config.API.StartGrpcServer = true
 	layerDuration := smApp.Config.LayerDurationSec
-	smApp.grpcAPIService = api.NewGrpcService(smApp.Config.API.GrpcServerPort, smApp.P2P, smApp.state, smApp.mesh, smApp.txPool, smApp.atxBuilder, smApp.oracle, smApp.clock, nil, layerDuration, nil, nil, nil)
+	smApp.grpcAPIService = api.NewGrpcService(smApp.Config.API.GrpcServerPort, smApp.P2P, smApp.state, smApp.mesh, smApp.txPool, smApp.atxBuilder, smApp.oracle, smApp.clock, nil, layerDuration, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	smApp.grpcAPIService.StartService()
 }
 
@@ -178,6 +181,41 @@ func GracefulShutdown(apps []*SpacemeshApp) {
 	log.Info("Graceful shutdown end")
 }
 
+// StartServices starts the services of an app that was initialized by InitSingleInstance.
+func (app *SpacemeshApp) StartServices() {
+	app.startServices()
+}
+
+// StopServices stops the services of an app that was started by StartServices.
+func (app *SpacemeshApp) StopServices() {
+	app.stopServices()
+}
+
+// NodeID returns the app's node identity.
+func (app *SpacemeshApp) NodeID() types.NodeID {
+	return app.nodeID
+}
+
+// Mesh returns the app's mesh.
+func (app *SpacemeshApp) Mesh() *mesh.Mesh {
+	return app.mesh
+}
+
+// State returns the app's transaction processor, which holds the global state.
+func (app *SpacemeshApp) State() *state.TransactionProcessor {
+	return app.state
+}
+
+// AtxDB returns the app's activation database.
+func (app *SpacemeshApp) AtxDB() *activation.DB {
+	return app.atxDb
+}
+
+// TxPool returns the app's transaction mempool.
+func (app *SpacemeshApp) TxPool() *miner.TxMempool {
+	return app.txPool
+}
+
 type network interface {
 	NewNode() *service.Node
 }
@@ -187,7 +225,7 @@ type network interface {
 
 // InitSingleInstance initializes a node instance with given
 // configuration and parameters, it does not stop the instance.
-func InitSingleInstance(cfg config.Config, i int, genesisTime string, rng *amcl.RAND, storePath string, rolacle *eligibility.FixedRolacle, poetClient *activation.HTTPPoetClient, clock TickProvider, net network) (*SpacemeshApp, error) {
+func InitSingleInstance(cfg config.Config, i int, genesisTime string, rng *amcl.RAND, storePath string, rolacle *eligibility.FixedRolacle, poetClient activation.PoetProvingServiceClient, clock TickProvider, net network) (*SpacemeshApp, error) {
 
 	smApp := NewSpacemeshApp()
 	smApp.Config = &cfg
@@ -203,15 +241,15 @@ func InitSingleInstance(cfg config.Config, i int, genesisTime string, rng *amcl.
 	swarm := net.NewNode()
 	dbStorepath := storePath
 
-	hareOracle := newLocalOracle(rolacle, 5, nodeID)
-	hareOracle.Register(true, pub.String())
+	rolacle.Register(true, pub.String())
 
 	postClient, err := activation.NewPostClient(&smApp.Config.POST, util.Hex2Bytes(nodeID.Key))
 	if err != nil {
 		return nil, err
 	}
 
-	err = smApp.initServices(nodeID, swarm, dbStorepath, edSgn, false, hareOracle, uint32(smApp.Config.LayerAvgSize), postClient, poetClient, vrfSigner, uint16(smApp.Config.LayersPerEpoch), clock)
+	smApp.edSgn = edSgn
+	err = smApp.initServices(nodeID, swarm, dbStorepath, edSgn, false, rolacle, uint32(smApp.Config.LayerAvgSize), postClient, poetClient, vrfSigner, uint16(smApp.Config.LayersPerEpoch), clock)
 	if err != nil {
 		return nil, err
 	}
@@ -222,6 +260,47 @@ func InitSingleInstance(cfg config.Config, i int, genesisTime string, rng *amcl.
 // StartMultiNode Starts the run of a number of nodes, running in process consensus between them.
 // this also runs a single transaction between the nodes.
 func StartMultiNode(numOfinstances, layerAvgSize int, runTillLayer uint32, dbPath string) {
+	startMultiNode(numOfinstances, layerAvgSize, runTillLayer, dbPath, nil)
+}
+
+// doubleSpendLayer is the layer in which the double spend stress scenario starts, it should be after genesis so that
+// nodes are synced and accept gossip.
+const doubleSpendLayer = 3
+
+// StartDoubleSpendStress runs a number of in process nodes like StartMultiNode while flooding the network with
+// conflicting same-nonce transactions and equivocating blocks. It panics if the nodes fail to converge to the same
+// state, applying exactly one variant of each conflicting transaction.
+func StartDoubleSpendStress(numOfinstances, layerAvgSize int, runTillLayer uint32, dbPath string) {
+	gen, err := NewDefaultDoubleSpendGenerator(10, 3)
+	if err != nil {
+		log.Panic("cannot create double spend generator: %v", err)
+	}
+	sc := &scenario{
+		onLayer: func(apps []*SpacemeshApp, layer types.LayerID) {
+			switch {
+			case layer == doubleSpendLayer:
+				if err := gen.FloodTxs(apps); err != nil {
+					log.Panic("failed flooding conflicting txs: %v", err)
+				}
+			case layer > doubleSpendLayer:
+				if err := gen.FloodEquivocatingBlocks(apps, layer-1); err != nil {
+					log.Panic("failed flooding equivocating blocks: %v", err)
+				}
+			}
+		},
+		check: gen.Converged,
+	}
+	startMultiNode(numOfinstances, layerAvgSize, runTillLayer, dbPath, sc)
+}
+
+// scenario hooks into a multi node run: onLayer is called after every layer tick and check is called once the run
+// reached its last layer.
+type scenario struct {
+	onLayer func(apps []*SpacemeshApp, layer types.LayerID)
+	check   func(apps []*SpacemeshApp) error
+}
+
+func startMultiNode(numOfinstances, layerAvgSize int, runTillLayer uint32, dbPath string, sc *scenario) {
 	cfg := getTestDefaultConfig()
 	cfg.LayerAvgSize = layerAvgSize
 	numOfInstances := numOfinstances
@@ -331,6 +410,9 @@ loop:
 
 			startLayer = time.Now()
 			clock.Tick()
+			if sc != nil && sc.onLayer != nil {
+				sc.onLayer(apps, clock.GetCurrentLayer())
+			}
 
 			if apps[0].mesh.LatestLayer() >= types.LayerID(runTillLayer) {
 				break loop
@@ -339,4 +421,10 @@ loop:
 		}
 	}
 	collect.Stop()
+	if sc != nil && sc.check != nil {
+		if err := sc.check(apps); err != nil {
+			log.Panic("scenario did not converge: %v", err)
+		}
+		log.Info("scenario converged")
+	}
 }
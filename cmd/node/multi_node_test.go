@@ -1,9 +1,86 @@
 package node
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	apiCfg "github.com/spacemeshos/go-spacemesh/api/config"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMultiNode(t *testing.T) {
 	StartMultiNode(5, 10, 10, "/tmp/data")
 }
+
+func TestDoubleSpendStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	StartDoubleSpendStress(5, 10, 10, "/tmp/double_spend")
+}
+
+func TestDoubleSpendGenerator_ConflictingTxs(t *testing.T) {
+	r := require.New(t)
+	gen, err := NewDefaultDoubleSpendGenerator(4, 3)
+	r.NoError(err)
+
+	groups, err := gen.ConflictingTxs()
+	r.NoError(err)
+	r.Len(groups, 4)
+	for nonce, group := range groups {
+		r.Len(group, 3)
+		recipients := make(map[types.Address]struct{})
+		for _, tx := range group {
+			r.Equal(uint64(nonce), tx.AccountNonce)
+			r.Equal(gen.origin, tx.Origin())
+			recipients[tx.Recipient] = struct{}{}
+		}
+		r.Len(recipients, 3)
+	}
+}
+
+func TestEquivocateBlock(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+
+	blk := types.NewExistingBlock(5, []byte("data"))
+	blk.TxIDs = []types.TransactionID{{1}, {2}}
+	bytes, err := types.InterfaceToBytes(blk.MiniBlock)
+	r.NoError(err)
+	blk.Signature = sgn.Sign(bytes)
+	blk.Initialize()
+
+	eq, err := EquivocateBlock(blk, sgn)
+	r.NoError(err)
+	r.NotEqual(blk.ID(), eq.ID())
+	r.Equal(blk.LayerIndex, eq.LayerIndex)
+	r.Equal(blk.EligibilityProof, eq.EligibilityProof)
+	r.Equal(blk.MinerID().String(), eq.MinerID().String())
+	r.Empty(eq.TxIDs)
+}
+
+func TestDevnetGenesis(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "devnet-genesis")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "genesis.json")
+	accounts, err := devnetGenesis(path, 3, 1000)
+	r.NoError(err)
+	r.Len(accounts, 3)
+
+	conf, err := apiCfg.LoadGenesisConfig(path)
+	r.NoError(err)
+	r.Len(conf.InitialAccounts, 3)
+	for _, sgn := range accounts {
+		acc, ok := conf.InitialAccounts["0x"+util.Bytes2Hex(sgn.PublicKey().Bytes())]
+		r.True(ok)
+		r.Equal(uint64(1000), acc.Balance.Uint64())
+	}
+}
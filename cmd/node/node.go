@@ -4,11 +4,13 @@ package node
 import "C"
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"github.com/spacemeshos/amcl"
 	"github.com/spacemeshos/amcl/BLS381"
 	"github.com/spacemeshos/go-spacemesh/activation"
 	apiCfg "github.com/spacemeshos/go-spacemesh/api/config"
+	"github.com/spacemeshos/go-spacemesh/certifier"
 	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
@@ -16,17 +18,24 @@ import (
 	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/hare"
 	"github.com/spacemeshos/go-spacemesh/hare/eligibility"
+	"github.com/spacemeshos/go-spacemesh/membudget"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/metrics"
 	"github.com/spacemeshos/go-spacemesh/miner"
 	"github.com/spacemeshos/go-spacemesh/oracle"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/pendingtxs"
+	"github.com/spacemeshos/go-spacemesh/replication"
 	"github.com/spacemeshos/go-spacemesh/signing"
 	"github.com/spacemeshos/go-spacemesh/state"
+	"github.com/spacemeshos/go-spacemesh/statesync"
 	"github.com/spacemeshos/go-spacemesh/sync"
+	"github.com/spacemeshos/go-spacemesh/tasks"
 	"github.com/spacemeshos/go-spacemesh/tortoise"
+	"github.com/spacemeshos/go-spacemesh/tortoisebeacon"
 	"github.com/spacemeshos/go-spacemesh/turbohare"
+	"github.com/spacemeshos/go-spacemesh/updater"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 	"github.com/spacemeshos/post/shared"
 	"go.uber.org/zap"
 	"io/ioutil"
@@ -41,8 +50,11 @@ import (
 	"github.com/spacemeshos/go-spacemesh/api"
 	cfg "github.com/spacemeshos/go-spacemesh/config"
 	"github.com/spacemeshos/go-spacemesh/filesystem"
+	"github.com/spacemeshos/go-spacemesh/layercache"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/malfeasance"
 	"github.com/spacemeshos/go-spacemesh/p2p"
+	p2pConf "github.com/spacemeshos/go-spacemesh/p2p/config"
 	"github.com/spacemeshos/go-spacemesh/timesync"
 	timeCfg "github.com/spacemeshos/go-spacemesh/timesync/config"
 	"github.com/spf13/cobra"
@@ -77,8 +89,15 @@ const (
 	BlockBuilderLogger   = "blockBuilder"
 	BlockListenerLogger  = "blockListener"
 	PoetListenerLogger   = "poetListener"
+	MalfeasanceLogger    = "malfeasance"
 	NipstBuilderLogger   = "nipstBuilder"
 	AtxBuilderLogger     = "atxBuilder"
+	ReplicationLogger    = "replication"
+	StateSyncLogger      = "stateSync"
+	NipstFetcherLogger   = "nipstFetcher"
+	CertifierLogger      = "certifier"
+	LayerCacheLogger     = "layerCache"
+	TortoiseBeaconLogger = "tortoiseBeacon"
 )
 
 // Cmd is the cobra wrapper for the node, that allows adding parameters to it
@@ -115,6 +134,12 @@ func init() {
 	// TODO add commands actually adds flags
 	cmdp.AddCommands(Cmd)
 	Cmd.AddCommand(VersionCmd)
+	Cmd.AddCommand(DevnetCmd)
+	Cmd.AddCommand(BackupCmd)
+	Cmd.AddCommand(RestoreCmd)
+	Cmd.AddCommand(PostCmd)
+	Cmd.AddCommand(IdentityCmd)
+	Cmd.AddCommand(TestVectorsCmd)
 }
 
 // Service is a general service interface that specifies the basic start/stop functionality
@@ -129,6 +154,17 @@ type HareService interface {
 	GetResult(id types.LayerID) ([]types.BlockID, error)
 }
 
+// blockEligibilityOracle provides this node's block eligibility, either from its ATXs or from proof of work
+type blockEligibilityOracle interface {
+	BlockEligible(layerID types.LayerID) (types.ATXID, []types.BlockEligibilityProof, error)
+	GetEligibleLayers() []types.LayerID
+}
+
+// blockEligibilityValidator validates the eligibility of blocks received from the network
+type blockEligibilityValidator interface {
+	BlockSignedAndEligible(block *types.Block) (bool, error)
+}
+
 // TickProvider is an interface to a glopbal system clock that releases ticks on each layer
 type TickProvider interface {
 	Subscribe() timesync.LayerTimer
@@ -153,17 +189,33 @@ type SpacemeshApp struct {
 	blockListener  *sync.BlockListener
 	state          *state.TransactionProcessor
 	blockProducer  *miner.BlockBuilder
-	oracle         *oracle.MinerBlockOracle
+	oracle         blockEligibilityOracle
 	txProcessor    *state.TransactionProcessor
 	mesh           *mesh.Mesh
 	clock          TickProvider
 	hare           HareService
 	atxBuilder     *activation.Builder
+	atxDb          *activation.DB
+	prewarmer      *activation.Prewarmer
+	tortoiseBeacon *tortoisebeacon.TortoiseBeacon
 	poetListener   *activation.PoetListener
+	malfeasance    *malfeasance.Handler
+	replicaLeader  *replication.Leader
+	replica        *replication.Follower
+	layerResults   *layercache.Cache
+	stateSync      *statesync.StateSync
+	nipstFetcher   *activation.NipstFetcher
+	certifier      *certifier.Certifier
+	updater        *updater.Updater
+	services       *serviceRegistry
 	edSgn          *signing.EdSigner
 	closers        []interface{ Close() }
+	dirLocks       []*filesystem.DirLock // locks of the store directories, held while the node runs
+	stores         []*database.LDBDatabase
+	dbStorepath    string
 	log            log.Log
 	txPool         *miner.TxMempool
+	budget         *membudget.Manager // sizes the caches by the memory budget
 	loggers        map[string]*zap.AtomicLevel
 	term           chan struct{} // this channel is closed when closing services, goroutines should wait on this channel in order to terminate
 }
@@ -181,6 +233,8 @@ func LoadConfigFromFile() (*cfg.Config, error) {
 	}
 
 	conf := cfg.DefaultConfig()
+	// environment variables override the config file
+	cfg.LoadEnv(vip, os.LookupEnv)
 	// load config if it was loaded to our viper
 	err := vip.Unmarshal(&conf)
 	if err != nil {
@@ -242,6 +296,9 @@ func (app *SpacemeshApp) Initialize(cmd *cobra.Command, args []string) (err erro
 
 	// ensure cli flags are higher priority than config file
 	cmdp.EnsureCLIFlags(cmd, app.Config)
+	if err := app.Config.Validate(); err != nil {
+		return err
+	}
 
 	// override default config in timesync since timesync is using TimeCongigValues
 	timeCfg.TimeConfigValues = app.Config.TIME
@@ -256,13 +313,7 @@ func (app *SpacemeshApp) Initialize(cmd *cobra.Command, args []string) (err erro
 
 	app.introduction()
 
-	drift, err := timesync.CheckSystemClockDrift()
-	if err != nil {
-		return err
-	}
-
-	log.Info("System clock synchronized with ntp. drift: %s", drift)
-	return nil
+	return app.preflight()
 }
 
 // setupLogging configured the app logging system.
@@ -292,6 +343,7 @@ func (app *SpacemeshApp) getAppInfo() string {
 func (app *SpacemeshApp) Cleanup(cmd *cobra.Command, args []string) (err error) {
 	log.Info("App Cleanup starting...")
 	app.stopServices()
+	app.unlockStoreDirs()
 	// add any other Cleanup tasks here....
 	log.Info("App Cleanup completed\n\n")
 
@@ -347,6 +399,14 @@ func (weakCoinStub) GetResult() bool {
 	return true
 }
 
+// cacheBudget returns the memory budget the caches are sized by, creating it on first use.
+func (app *SpacemeshApp) cacheBudget() *membudget.Manager {
+	if app.budget == nil {
+		app.budget = membudget.NewManager(app.Config.CacheMB, membudget.DefaultPolicy, log.AppLog.WithName("memBudget"))
+	}
+	return app.budget
+}
+
 func (app *SpacemeshApp) addLogger(name string, logger log.Log) log.Log {
 	log.Level()
 	lvl := zap.NewAtomicLevel()
@@ -397,10 +457,14 @@ func (app *SpacemeshApp) addLogger(name string, logger log.Log) log.Log {
 		err = lvl.UnmarshalText([]byte(app.Config.LOGGING.BlockListenerLoggerLevel))
 	case PoetListenerLogger:
 		err = lvl.UnmarshalText([]byte(app.Config.LOGGING.PoetListenerLoggerLevel))
+	case MalfeasanceLogger:
+		err = lvl.UnmarshalText([]byte(app.Config.LOGGING.MalfeasanceLoggerLevel))
 	case NipstBuilderLogger:
 		err = lvl.UnmarshalText([]byte(app.Config.LOGGING.NipstBuilderLoggerLevel))
 	case AtxBuilderLogger:
 		err = lvl.UnmarshalText([]byte(app.Config.LOGGING.AtxBuilderLoggerLevel))
+	case ReplicationLogger:
+		err = lvl.UnmarshalText([]byte(app.Config.LOGGING.ReplicationLoggerLevel))
 	default:
 		lvl.SetLevel(log.Level())
 	}
@@ -439,6 +503,7 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 	layersPerEpoch uint16, clock TickProvider) error {
 
 	app.nodeID = nodeID
+	app.dbStorepath = dbStorepath
 
 	name := nodeID.ShortString()
 
@@ -446,74 +511,148 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 
 	app.log = app.addLogger(AppLogger, lg)
 
+	services, err := newServiceRegistry(app.Config.Roles, app.log)
+	if err != nil {
+		return err
+	}
+	app.services = services
+
 	postClient.SetLogger(app.addLogger(PostLogger, lg))
 
-	db, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "state"), 0, 0, app.addLogger(StateDbLogger, lg))
+	db, err := app.newStore("state", app.addLogger(StateDbLogger, lg))
 	if err != nil {
 		return err
 	}
-	app.closers = append(app.closers, db)
 
 	coinToss := weakCoinStub{}
 
-	atxdbstore, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "atx"), 0, 0, app.addLogger(AtxDbStoreLogger, lg))
+	atxdbstore, err := app.newStore("atx", app.addLogger(AtxDbStoreLogger, lg))
+	if err != nil {
+		return err
+	}
+
+	poetDbStore, err := app.newStore("poet", app.addLogger(PoetDbStoreLogger, lg))
 	if err != nil {
 		return err
 	}
-	app.closers = append(app.closers, atxdbstore)
 
-	poetDbStore, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "poet"), 0, 0, app.addLogger(PoetDbStoreLogger, lg))
+	iddbstore, err := app.newStore("ids", app.addLogger(StateDbLogger, lg))
 	if err != nil {
 		return err
 	}
-	app.closers = append(app.closers, poetDbStore)
 
-	iddbstore, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "ids"), 0, 0, app.addLogger(StateDbLogger, lg))
+	store, err := app.newStore("store", app.addLogger(StoreLogger, lg))
 	if err != nil {
 		return err
 	}
-	app.closers = append(app.closers, iddbstore)
 
-	store, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "store"), 0, 0, app.addLogger(StoreLogger, lg))
+	malfeasanceDbStore, err := app.newStore("malfeasance", app.addLogger(MalfeasanceLogger, lg))
 	if err != nil {
 		return err
 	}
-	app.closers = append(app.closers, store)
 
 	idStore := activation.NewIdentityStore(iddbstore)
 	poetDb := activation.NewPoetDb(poetDbStore, app.addLogger(PoetDbLogger, lg))
-	validator := activation.NewValidator(&app.Config.POST, poetDb)
-	mdb, err := mesh.NewPersistentMeshDB(filepath.Join(dbStorepath, "mesh"), app.Config.BlockCacheSize, app.addLogger(MeshDBLogger, lg))
+	validator := activation.NewValidator(&app.Config.POST, poetDb, app.Config.TickSize)
+	budget := app.cacheBudget()
+	blockCacheSize := budget.Register(membudget.BlockCache, app.Config.BlockCacheSize)
+	mdb, err := mesh.NewPersistentMeshDB(filepath.Join(app.storeDir("mesh"), "mesh"), blockCacheSize, app.addLogger(MeshDBLogger, lg))
 	if err != nil {
 		return err
 	}
+	app.stores = append(app.stores, mdb.Stores()...)
 
 	app.txPool = miner.NewTxMemPool()
+	app.txPool.SetMinGasPrice(app.Config.MinGasPrice)
+	app.txPool.SetMaxTxs(budget.Register(membudget.Mempool, 0))
 	atxpool := miner.NewAtxMemPool()
 	meshAndPoolProjector := pendingtxs.NewMeshAndPoolProjector(mdb, app.txPool)
 
-	appliedTxs, err := database.NewLDBDatabase(filepath.Join(dbStorepath, "appliedTxs"), 0, 0, lg.WithName("appliedTxs"))
+	appliedTxs, err := app.newStore("appliedTxs", lg.WithName("appliedTxs"))
 	if err != nil {
 		return err
 	}
-	app.closers = append(app.closers, appliedTxs)
 	processor := state.NewTransactionProcessor(db, appliedTxs, meshAndPoolProjector, lg.WithName("state"))
 
 	atxdb := activation.NewDB(atxdbstore, idStore, mdb, layersPerEpoch, validator, app.addLogger(AtxDbLogger, lg))
-	beaconProvider := &oracle.EpochBeaconProvider{}
-	eValidator := oracle.NewBlockEligibilityValidator(layerSize, uint32(app.Config.GenesisActiveSet), layersPerEpoch, atxdb, beaconProvider, BLS381.Verify2, app.addLogger(BlkEligibilityLogger, lg))
+	atxCacheSize := budget.Register(membudget.AtxCache, activation.DefaultAtxCacheSize)
+	atxdb.SetHeaderCacheSize(atxCacheSize)
+	upgrades, err := upgrade.NewSchedule(app.Config.Upgrades)
+	if err != nil {
+		return fmt.Errorf("invalid upgrade schedule: %v", err)
+	}
+	atxdb.SetUpgrades(upgrades)
+	atxdb.SetActiveSetGracePeriod(uint16(app.Config.ActiveSetGraceLayers))
+	activation.SetActivesetCacheSize(budget.Register(membudget.ActivesetCache, activation.DefaultActivesetCacheSize))
+	beaconDbStore, err := app.newStore("beacon", app.addLogger(TortoiseBeaconLogger, lg))
+	if err != nil {
+		return err
+	}
+	// the beacon of an epoch is calculated in the layers at its start, before the epoch ends
+	if app.Config.BeaconProposalLayers < 1 || app.Config.BeaconVotingLayers < 1 ||
+		app.Config.BeaconProposalLayers+app.Config.BeaconVotingLayers >= app.Config.LayersPerEpoch {
+		return fmt.Errorf("the tortoise beacon proposal layers (%v) and voting layers (%v) must be positive and fit in an epoch (%v layers)",
+			app.Config.BeaconProposalLayers, app.Config.BeaconVotingLayers, app.Config.LayersPerEpoch)
+	}
+	beaconConf := tortoisebeacon.Config{
+		ProposalCount:  app.Config.BeaconProposals,
+		ProposalLayers: uint16(app.Config.BeaconProposalLayers),
+		VotingLayers:   uint16(app.Config.BeaconVotingLayers),
+	}
+	tortoiseBeacon := tortoisebeacon.NewTortoiseBeacon(beaconConf, swarm, atxdb, beaconDbStore, nodeID, vrfSigner, BLS381.Verify2, sgn, layersPerEpoch, clock.Subscribe(), app.addLogger(TortoiseBeaconLogger, lg))
+	beaconProvider := oracle.NewEpochBeaconProvider(tortoiseBeacon, upgrades, app.addLogger(BlockOracle, lg))
+	malfeasanceStore, err := malfeasance.NewStore(malfeasanceDbStore)
+	if err != nil {
+		return err
+	}
+	// block eligibility splits the blocks of an epoch between the active identities according to their committed space
+	eligibilityLayerSize := layerSize
+	if app.Config.TargetLayerSize > 0 {
+		eligibilityLayerSize = uint32(app.Config.TargetLayerSize)
+	}
+	eValidator := oracle.NewBlockEligibilityValidator(eligibilityLayerSize, uint32(app.Config.GenesisActiveSet), layersPerEpoch, atxdb, beaconProvider, BLS381.Verify2, app.addLogger(BlkEligibilityLogger, lg))
+	eValidator.SetMalfeasanceChecker(malfeasanceStore)
 
 	var msh *mesh.Mesh
 	var trtl tortoise.Tortoise
 	if mdb.PersistentData() {
 		trtl = tortoise.NewRecoveredTortoise(mdb, app.addLogger(TrtlLogger, lg))
-		msh = mesh.NewRecoveredMesh(mdb, atxdb, app.Config.REWARD, trtl, app.txPool, atxpool, processor, app.addLogger(MeshLogger, lg))
+		msh = mesh.NewRecoveredMesh(mdb, atxdb, app.Config.REWARD, trtl, app.txPool, atxpool, processor, upgrades, layersPerEpoch, app.addLogger(MeshLogger, lg))
 		go msh.CacheWarmUp(app.Config.LayerAvgSize)
 	} else {
 		trtl = tortoise.NewTortoise(int(layerSize), mdb, app.Config.Hdist, app.addLogger(TrtlLogger, lg))
 		msh = mesh.NewMesh(mdb, atxdb, app.Config.REWARD, trtl, app.txPool, atxpool, processor, app.addLogger(MeshLogger, lg))
+		msh.SetUpgrades(upgrades, layersPerEpoch)
 		app.setupGenesis(processor, msh)
 	}
+	msh.SetFinalityDepth(uint32(app.Config.FinalityDepth))
+	if err := atxdb.MigrateKeys(); err != nil {
+		return err
+	}
+	// atxs and blocks whose processing was interrupted by a crash are processed again before any new ones are received
+	if err := atxdb.ReplayIntents(); err != nil {
+		return err
+	}
+	if err := msh.ReplayIntents(); err != nil {
+		return err
+	}
+
+	if app.Config.ReplicationListen != "" && services.Enabled(cfg.SyncRole) {
+		replicationDb, err := app.newStore("replication", lg.WithName("replicationDb"))
+		if err != nil {
+			return err
+		}
+		app.replicaLeader = replication.NewLeader(app.Config.ReplicationListen, replicationDb, msh, processor, app.addLogger(ReplicationLogger, lg))
+		msh.AddStateObserver(app.replicaLeader)
+	}
+	if app.Config.API.LayerResultsCache > 0 && app.Config.ReplicationLeader == "" && services.Enabled(cfg.APIRole) {
+		// read replicas don't apply layers themselves, so they have no results to cache
+		app.layerResults = layercache.New(app.Config.API.LayerResultsCache, msh, processor, app.addLogger(LayerCacheLogger, lg))
+		msh.AddStateObserver(app.layerResults)
+	}
+	if app.Config.ReplicationLeader != "" {
+		app.replica = replication.NewFollower(app.Config.ReplicationLeader, msh, atxdb, processor, layersPerEpoch, app.addLogger(ReplicationLogger, lg))
+	}
 
 	syncConf := sync.Configuration{Concurrency: 4,
 		LayerSize:       int(layerSize),
@@ -521,8 +660,10 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 		RequestTimeout:  time.Duration(app.Config.SyncRequestTimeout) * time.Millisecond,
 		SyncInterval:    time.Duration(app.Config.SyncInterval) * time.Second,
 		ValidationDelta: time.Duration(app.Config.SyncValidationDelta) * time.Second,
+		RepairInterval:  time.Duration(app.Config.SyncRepairInterval) * time.Second,
 		Hdist:           app.Config.Hdist,
-		AtxsLimit:       app.Config.AtxsPerBlock}
+		AtxsLimit:       app.Config.AtxsPerBlock,
+		MaxResponseSize: app.Config.P2P.MsgSizeLimit}
 
 	if app.Config.AtxsPerBlock > miner.AtxsPerBlockLimit { // validate limit
 		app.log.Panic("Number of atxs per block required is bigger than the limit atxsPerBlock=%v limit=%v", app.Config.AtxsPerBlock, miner.AtxsPerBlockLimit)
@@ -534,49 +675,246 @@ func (app *SpacemeshApp) initServices(nodeID types.NodeID,
 			app.Config.HareEligibility.EpochOffset, app.Config.BaseConfig.LayersPerEpoch)
 	}
 
-	syncer := sync.NewSync(swarm, msh, app.txPool, atxpool, eValidator, poetDb, syncConf, clock, app.addLogger(SyncLogger, lg))
-	blockOracle := oracle.NewMinerBlockOracle(layerSize, uint32(app.Config.GenesisActiveSet), layersPerEpoch, atxdb, beaconProvider, vrfSigner, nodeID, syncer.ListenToGossip, app.addLogger(BlockOracle, lg))
-
-	// TODO: we should probably decouple the apptest and the node (and duplicate as necessary) (#1926)
-	var hOracle hare.Rolacle
-	if isFixedOracle { // fixed rolacle, take the provided rolacle
-		hOracle = rolacle
-	} else { // regular oracle, build and use it
-		beacon := eligibility.NewBeacon(mdb, app.Config.HareEligibility.ConfidenceParam, app.addLogger(HareBeaconLogger, lg))
-		hOracle = eligibility.New(beacon, atxdb.CalcActiveSetSize, BLS381.Verify2, vrfSigner, uint16(app.Config.LayersPerEpoch), app.Config.GenesisActiveSet, mdb, app.Config.HareEligibility, app.addLogger(HareOracleLogger, lg))
+	// the pow oracle replaces all ATX based eligibility, so that local dev networks can run without PoET and PoST
+	var powOracle *oracle.PowOracle
+	var blockValidator blockEligibilityValidator = eValidator
+	if app.Config.EligibilityOracle == cfg.PowEligibilityOracle {
+		app.log.Warning("using proof-of-work eligibility, this network is not sybil resistant")
+		powOracle = oracle.NewPowOracle(uint8(app.Config.PowDifficulty), layersPerEpoch, nodeID, app.addLogger(BlockOracle, lg))
+		blockValidator = powOracle
+	} else if app.Config.EligibilityOracle != cfg.VRFEligibilityOracle {
+		return fmt.Errorf("unknown eligibility oracle %q", app.Config.EligibilityOracle)
+	}
+
+	// the subsystems of a role are only constructed if the node runs the role. The roles a role depends on are always
+	// run with it, e.g. the syncer exists whenever the hare or the block producer do
+	if services.Enabled(cfg.P2PRole) {
+		app.stateSync = statesync.NewStateSync(swarm, processor.TrieDB(), db, time.Duration(app.Config.SyncRequestTimeout)*time.Millisecond, app.addLogger(StateSyncLogger, lg))
+		app.poetListener = activation.NewPoetListener(swarm, poetDb, app.addLogger(PoetListenerLogger, lg))
+		// the hare publishes proofs of the equivocations it detects
+		app.malfeasance = malfeasance.NewHandler(swarm, malfeasance.NewVerifier(layersPerEpoch), malfeasanceStore, app.addLogger(MalfeasanceLogger, lg))
+		if peers, ok := swarm.(updater.Peers); ok && app.Config.UpdateCheckInterval > 0 {
+			feedKey, err := hex.DecodeString(app.Config.ReleaseFeedKey)
+			if err != nil {
+				return fmt.Errorf("invalid release feed key: %v", err)
+			}
+			updaterConf := updater.Config{Interval: time.Duration(app.Config.UpdateCheckInterval) * time.Second,
+				FeedURL: app.Config.ReleaseFeedURL, FeedKey: feedKey}
+			app.updater, err = updater.New(updaterConf, p2pConf.ClientVersion, peers, lg.WithName("updater"))
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	ha := app.HareFactory(mdb, swarm, sgn, nodeID, syncer, msh, hOracle, idStore, clock, lg)
+	if services.Enabled(cfg.SyncRole) {
+		app.syncer = sync.NewSync(swarm, msh, app.txPool, atxpool, blockValidator, poetDb, syncConf, clock, app.addLogger(SyncLogger, lg))
+		app.syncer.SetUpgrades(upgrades)
+		atxdb.SetAtxFetcher(app.syncer)
+		app.blockListener = sync.NewBlockListener(swarm, app.syncer, 4, app.addLogger(BlockListenerLogger, lg))
+	}
+
+	if services.Enabled(cfg.ConsensusRole) {
+		// TODO: we should probably decouple the apptest and the node (and duplicate as necessary) (#1926)
+		var hOracle hare.Rolacle
+		if isFixedOracle { // fixed rolacle, take the provided rolacle
+			hOracle = rolacle
+		} else if powOracle != nil {
+			hOracle = powOracle
+		} else { // regular oracle, build and use it
+			beacon := eligibility.NewBeacon(mdb, app.Config.HareEligibility.ConfidenceParam, app.addLogger(HareBeaconLogger, lg))
+			eOracle := eligibility.New(beacon, atxdb.CalcActiveSetSize, BLS381.Verify2, vrfSigner, uint16(app.Config.LayersPerEpoch), app.Config.GenesisActiveSet, mdb, app.Config.HareEligibility, app.addLogger(HareOracleLogger, lg))
+			eOracle.SetMalfeasanceChecker(malfeasanceStore)
+			eOracle.SetAtxProvider(atxdb)
+			if app.Config.HARE.ListenOnly {
+				eOracle.SetListenOnly()
+			}
+			hOracle = eOracle
+		}
+
+		if app.Config.CertifyCommitteeSize > 0 {
+			threshold := app.Config.CertifyThreshold
+			if threshold == 0 {
+				threshold = app.Config.CertifyCommitteeSize/2 + 1
+			}
+			certifierConf := certifier.Config{CommitteeSize: app.Config.CertifyCommitteeSize, Threshold: threshold,
+				ListenOnly: app.Config.HARE.ListenOnly}
+			app.certifier = certifier.NewCertifier(certifierConf, swarm, msh, hOracle, sgn, idStore, layersPerEpoch, app.addLogger(CertifierLogger, lg))
+			msh.RequireCertificates()
+		}
+
+		hareDb, err := app.newStore("hare", app.addLogger(HareLogger, lg))
+		if err != nil {
+			return err
+		}
+		app.hare = app.HareFactory(mdb, hareDb, swarm, sgn, nodeID, app.syncer, msh, hOracle, idStore, clock, lg)
+		app.prewarmer = activation.NewPrewarmer(atxdb, clock.Subscribe(), atxCacheSize, app.addLogger(AtxDbLogger, lg))
+	}
 
-	stateAndMeshProjector := pendingtxs.NewStateAndMeshProjector(processor, msh)
-	blockProducer := miner.NewBlockBuilder(nodeID, sgn, swarm, clock.Subscribe(), app.Config.Hdist, app.txPool, atxpool, coinToss, msh, ha, blockOracle, processor, atxdb, syncer, app.Config.AtxsPerBlock, layersPerEpoch, stateAndMeshProjector, app.addLogger(BlockBuilderLogger, lg))
-	blockListener := sync.NewBlockListener(swarm, syncer, 4, app.addLogger(BlockListenerLogger, lg))
+	if services.Enabled(cfg.MiningRole) {
+		if powOracle != nil {
+			app.oracle = powOracle
+		} else {
+			app.oracle = oracle.NewMinerBlockOracle(eligibilityLayerSize, uint32(app.Config.GenesisActiveSet), layersPerEpoch, atxdb, beaconProvider, vrfSigner, nodeID, app.syncer.ListenToGossip, app.addLogger(BlockOracle, lg))
+		}
 
-	msh.SetBlockBuilder(blockProducer)
+		stateAndMeshProjector := pendingtxs.NewStateAndMeshProjector(processor, msh)
+		blockProducer := miner.NewBlockBuilder(nodeID, sgn, swarm, clock.Subscribe(), app.Config.Hdist, app.txPool, atxpool, coinToss, msh, app.hare, app.oracle, processor, atxdb, app.syncer, app.Config.AtxsPerBlock, layersPerEpoch, stateAndMeshProjector, app.addLogger(BlockBuilderLogger, lg))
+		blockProducer.SetUpgrades(upgrades)
+		msh.SetBlockBuilder(blockProducer)
 
-	poetListener := activation.NewPoetListener(swarm, poetDb, app.addLogger(PoetListenerLogger, lg))
+		nipstBuilder := activation.NewNIPSTBuilder(util.Hex2Bytes(nodeID.Key), postClient, poetClient, poetDb, store, app.addLogger(NipstBuilderLogger, lg))
+		nipstBuilder.SetPoetRoundMargin(time.Duration(app.Config.PoetRoundMarginSec) * time.Second)
 
-	nipstBuilder := activation.NewNIPSTBuilder(util.Hex2Bytes(nodeID.Key), postClient, poetClient, poetDb, store, app.addLogger(NipstBuilderLogger, lg))
+		coinBase, err := types.ParseAddress(app.Config.CoinbaseAccount, app.Config.AddressHRP)
+		if err != nil && app.Config.StartMining {
+			app.log.Panic("invalid Coinbase account %v", err)
+		}
 
-	coinBase := types.HexToAddress(app.Config.CoinbaseAccount)
+		if coinBase.Big().Uint64() == 0 && app.Config.StartMining {
+			app.log.Panic("invalid Coinbase account")
+		}
+		atxBuilder := activation.NewBuilder(nodeID, coinBase, sgn, atxdb, swarm, msh, layersPerEpoch, nipstBuilder, postClient, clock, app.syncer, store, app.addLogger("atxBuilder", lg))
+		atxBuilder.SetUpgrades(upgrades)
+		posAtxs, err := activation.NewPositioningAtxProvider(app.Config.PosAtxPolicy, app.Config.PosAtxTopN, atxdb)
+		if err != nil {
+			return err
+		}
+		atxBuilder.SetPositioningAtxProvider(posAtxs)
+		app.blockProducer = blockProducer
+		app.atxBuilder = atxBuilder
+	}
 
-	if coinBase.Big().Uint64() == 0 && app.Config.StartMining {
-		app.log.Panic("invalid Coinbase account")
+	if services.Enabled(cfg.P2PRole) {
+		// atxs gossiped without their NIPST are served from the node's own atx, the atx pool and the database
+		var nipstSources activation.NipstSources
+		if app.atxBuilder != nil {
+			nipstSources = append(nipstSources, app.atxBuilder, app.blockProducer)
+		}
+		nipstSources = append(nipstSources, atxdb)
+		app.nipstFetcher = activation.NewNipstFetcher(swarm, nipstSources, time.Duration(app.Config.SyncRequestTimeout)*time.Millisecond, app.addLogger(NipstFetcherLogger, lg))
+		if app.blockProducer != nil {
+			app.blockProducer.SetNipstFetcher(app.atxBuilder, app.nipstFetcher)
+		}
 	}
-	atxBuilder := activation.NewBuilder(nodeID, coinBase, sgn, atxdb, swarm, msh, layersPerEpoch, nipstBuilder, postClient, clock, syncer, store, app.addLogger("atxBuilder", lg))
 
-	app.blockProducer = blockProducer
-	app.blockListener = blockListener
 	app.mesh = msh
-	app.syncer = syncer
 	app.clock = clock
 	app.state = processor
-	app.hare = ha
 	app.P2P = swarm
-	app.poetListener = poetListener
-	app.atxBuilder = atxBuilder
-	app.oracle = blockOracle
+	app.atxDb = atxdb
+	app.tortoiseBeacon = tortoiseBeacon
 	app.txProcessor = processor
+	return app.registerServices()
+}
+
+// registerServices registers the subsystems that were constructed for the node's roles, so that they're started and
+// stopped by role.
+func (app *SpacemeshApp) registerServices() error {
+	services := app.services
+	if services.Enabled(cfg.P2PRole) {
+		services.Register(cfg.P2PRole, "state sync server", startFunc(func() {}), app.stateSync.Close)
+		services.Register(cfg.P2PRole, "NIPST server", startFunc(func() {}), app.nipstFetcher.Close)
+	}
+	if services.Enabled(cfg.SyncRole) {
+		if app.Config.StateSyncRoot != "" {
+			// must start before the syncer, so that no layer is applied to state before the checkpoint state is imported
+			services.Register(cfg.SyncRole, "state sync", app.startStateSync, nil)
+		}
+		services.Register(cfg.SyncRole, "block listener", startFunc(app.blockListener.Start), app.blockListener.Close)
+		services.Register(cfg.SyncRole, "syncer", startFunc(app.syncer.Start), nil) // the block listener closes the syncer
+	}
+	if services.Enabled(cfg.ConsensusRole) {
+		services.Register(cfg.ConsensusRole, "hare", app.hare.Start, app.hare.Close)
+		services.Register(cfg.ConsensusRole, "cache prewarmer", startFunc(app.prewarmer.Start), app.prewarmer.Close)
+		if app.Config.EligibilityOracle == cfg.VRFEligibilityOracle {
+			services.Register(cfg.ConsensusRole, "tortoise beacon", startFunc(app.tortoiseBeacon.Start), app.tortoiseBeacon.Close)
+		}
+		if app.certifier != nil {
+			services.Register(cfg.ConsensusRole, "certifier", startFunc(app.certifier.Start), app.certifier.Close)
+		}
+	}
+	if app.updater != nil {
+		services.Register(cfg.P2PRole, "updater", startFunc(app.updater.Start), app.updater.Close)
+	}
+	if services.Enabled(cfg.MiningRole) {
+		services.Register(cfg.MiningRole, "block producer", app.blockProducer.Start, func() {
+			if err := app.blockProducer.Close(); err != nil {
+				app.log.Error("cannot stop block producer %v", err)
+			}
+		})
+	}
+	if services.Enabled(cfg.P2PRole) {
+		services.Register(cfg.P2PRole, "PoET listener", startFunc(app.poetListener.Start), app.poetListener.Close)
+		services.Register(cfg.P2PRole, "malfeasance handler", startFunc(app.malfeasance.Start), app.malfeasance.Close)
+	}
+	if app.replicaLeader != nil {
+		services.Register(cfg.SyncRole, "replication leader", app.replicaLeader.Start, app.replicaLeader.Close)
+	}
+	if app.replica != nil {
+		// a read replica's mesh and state are written by the leader only
+		if services.Enabled(cfg.SyncRole) {
+			return fmt.Errorf("a read replica can't run role %q", cfg.SyncRole)
+		}
+		services.Register(cfg.APIRole, "replication follower", startFunc(app.replica.Start), app.replica.Close)
+	}
+	if !services.Enabled(cfg.MiningRole) {
+		return nil
+	}
+	if app.Config.EligibilityOracle == cfg.PowEligibilityOracle {
+		// eligibility does not depend on ATXs, there is no need to initialize PoST or publish ATXs
+		app.log.Info("Proof-of-work eligibility, not starting the ATX builder")
+	} else {
+		services.Register(cfg.MiningRole, "atx builder", app.startAtxBuilder, app.atxBuilder.Stop)
+	}
+	return nil
+}
+
+// startStateSync fetches the state of the configured checkpoint layer from peers and imports it. The mesh doesn't apply
+// layers to state until the state was imported, and skips the layers up to the checkpoint afterwards.
+func (app *SpacemeshApp) startStateSync() error {
+	root := types.HexToHash32(app.Config.StateSyncRoot)
+	if root == (types.Hash32{}) {
+		return fmt.Errorf("invalid state sync root %q", app.Config.StateSyncRoot)
+	}
+	layer := types.LayerID(app.Config.StateSyncLayer)
+	if app.mesh.LatestLayerInState() >= layer {
+		app.log.With().Info("state is past the state sync checkpoint, not fetching state", log.LayerID(uint64(layer)))
+		return nil
+	}
+	locked := make(chan struct{})
+	go func() {
+		err := app.mesh.WithStateLocked(func(types.LayerID) error {
+			close(locked)
+			if err := app.stateSync.Fetch(root); err != nil {
+				return err
+			}
+			if err := app.txProcessor.ImportState(layer, root); err != nil {
+				return err
+			}
+			app.mesh.SetLatestLayerInState(layer)
+			return nil
+		})
+		if err != nil {
+			app.log.Error("state sync failed: %v", err)
+		}
+	}()
+	<-locked
+	return nil
+}
+
+func (app *SpacemeshApp) startAtxBuilder() error {
+	if app.Config.StartMining {
+		// the configured account, or the one last set through the api
+		coinBase := app.atxBuilder.CoinbaseAccount()
+		err := app.atxBuilder.StartPost(coinBase, app.Config.POST.DataDir, app.Config.POST.SpacePerUnit)
+		if err != nil {
+			return fmt.Errorf("error initializing post: %v", err)
+		}
+	} else {
+		log.Info("Manual post init")
+	}
+	app.atxBuilder.Start()
 	return nil
 }
 
@@ -591,18 +929,27 @@ func (app *SpacemeshApp) checkTimeDrifts() {
 			return
 
 		case <-checkTimeSync.C:
-			_, err := timesync.CheckSystemClockDrift()
+			drift, err := timesync.CheckSystemClockDrift()
 			if err != nil {
 				app.log.Error("System time couldn't synchronize %s", err)
 				cmdp.Cancel()
 				return
 			}
+			// layer and round boundaries are derived from the clock, so alert when it diverges from the network's
+			if peers, ok := app.P2P.(interface{ PeerTimeDrift() (time.Duration, int) }); ok {
+				peerDrift, samples := peers.PeerTimeDrift()
+				if err := timesync.CheckPeerDrift(peerDrift, samples, drift); err != nil {
+					app.log.With().Error("clock diverges from the network's time", log.Err(err),
+						log.String("ntp_drift", drift.String()), log.String("peer_drift", peerDrift.String()),
+						log.Int("peers", samples))
+				}
+			}
 		}
 	}
 }
 
 // HareFactory returns a hare consensus algorithm according to the parameters is app.Config.Hare.SuperHare
-func (app *SpacemeshApp) HareFactory(mdb *mesh.DB, swarm service.Service, sgn hare.Signer, nodeID types.NodeID, syncer *sync.Syncer, msh *mesh.Mesh, hOracle hare.Rolacle, idStore *activation.IdentityStore, clock TickProvider, lg log.Log) HareService {
+func (app *SpacemeshApp) HareFactory(mdb *mesh.DB, hareDb database.Database, swarm service.Service, sgn hare.Signer, nodeID types.NodeID, syncer *sync.Syncer, msh *mesh.Mesh, hOracle hare.Rolacle, idStore *activation.IdentityStore, clock TickProvider, lg log.Log) HareService {
 	if app.Config.HARE.SuperHare {
 		return turbohare.New(msh)
 	}
@@ -624,35 +971,56 @@ func (app *SpacemeshApp) HareFactory(mdb *mesh.DB, swarm service.Service, sgn ha
 
 		return true
 	}
-	ha := hare.New(app.Config.HARE, swarm, sgn, nodeID, validationFunc, syncer.IsSynced, msh, hOracle, uint16(app.Config.LayersPerEpoch), idStore, hOracle, clock.Subscribe(), app.addLogger(HareLogger, lg))
+	// with certification, hare outputs are passed to the certifier alongside the mesh, which applies them to state once
+	// they're certified
+	var output interface {
+		LayerBlockIds(layerID types.LayerID) ([]types.BlockID, error)
+		HandleValidatedLayer(validatedLayer types.LayerID, layer []types.BlockID)
+	} = msh
+	if app.certifier != nil {
+		output = certifiedHareOutput{Mesh: msh, certifier: app.certifier}
+	}
+	ha := hare.New(app.Config.HARE, swarm, sgn, nodeID, validationFunc, syncer.IsSynced, output, hOracle, uint16(app.Config.LayersPerEpoch), idStore, hOracle, clock.Subscribe(), app.addLogger(HareLogger, lg))
+	ha.SetMessageStore(hareDb)
+	ha.SetBlockProvider(mdb)
+	if app.malfeasance != nil {
+		ha.SetEquivocationHandler(app.publishEquivocation)
+	}
 	return ha
 }
 
-func (app *SpacemeshApp) startServices() {
-	app.blockListener.Start()
-	app.syncer.Start()
-	err := app.hare.Start()
-	if err != nil {
-		log.Panic("cannot start hare")
-	}
-	err = app.blockProducer.Start()
+// publishEquivocation publishes a malfeasance proof that convicts the identity that signed the conflicting hare
+// messages first and second.
+func (app *SpacemeshApp) publishEquivocation(first, second *hare.Message) {
+	proof, err := malfeasance.NewProof(malfeasance.HareEquivocation, first, second)
 	if err != nil {
-		log.Panic("cannot start block producer")
+		app.log.With().Error("cannot build hare equivocation proof", log.Err(err))
+		return
 	}
+	// the proof is broadcast without blocking the consensus process that detected the equivocation
+	go func() {
+		if err := app.malfeasance.Publish(proof); err != nil {
+			app.log.With().Error("cannot publish hare equivocation proof", log.Err(err))
+		}
+	}()
+}
 
-	app.poetListener.Start()
+// certifiedHareOutput passes the hare output of a layer to the certifier, which signs it, and to the mesh, which applies
+// it to state once the layer is certified.
+type certifiedHareOutput struct {
+	*mesh.Mesh
+	certifier *certifier.Certifier
+}
 
-	if app.Config.StartMining {
-		coinBase := types.HexToAddress(app.Config.CoinbaseAccount)
-		err := app.atxBuilder.StartPost(coinBase, app.Config.POST.DataDir, app.Config.POST.SpacePerUnit)
-		if err != nil {
-			log.Error("Error initializing post, err: %v", err)
-			log.Panic("Error initializing post")
-		}
-	} else {
-		log.Info("Manual post init")
+func (o certifiedHareOutput) HandleValidatedLayer(layer types.LayerID, blockIDs []types.BlockID) {
+	o.certifier.HandleValidatedLayer(layer, blockIDs)
+	o.Mesh.HandleValidatedLayer(layer, blockIDs)
+}
+
+func (app *SpacemeshApp) startServices() {
+	if err := app.services.Start(); err != nil {
+		log.Panic("%v", err)
 	}
-	app.atxBuilder.Start()
 	app.clock.StartNotifying()
 	go app.checkTimeDrifts()
 }
@@ -672,36 +1040,13 @@ func (app *SpacemeshApp) stopServices() {
 		app.grpcAPIService.Close()
 	}
 
-	if app.blockProducer != nil {
-		app.log.Info("%v closing block producer", app.nodeID.Key)
-		if err := app.blockProducer.Close(); err != nil {
-			log.Error("cannot stop block producer %v", err)
-		}
-	}
-
 	if app.clock != nil {
 		app.log.Info("%v closing clock", app.nodeID.Key)
 		app.clock.Close()
 	}
 
-	if app.poetListener != nil {
-		app.log.Info("closing PoET listener")
-		app.poetListener.Close()
-	}
-
-	if app.atxBuilder != nil {
-		app.log.Info("closing atx builder")
-		app.atxBuilder.Stop()
-	}
-
-	if app.blockListener != nil {
-		app.log.Info("%v closing blockListener", app.nodeID.Key)
-		app.blockListener.Close()
-	}
-
-	if app.hare != nil {
-		app.log.Info("%v closing Hare", app.nodeID.Key)
-		app.hare.Close()
+	if app.services != nil {
+		app.services.Stop()
 	}
 
 	if app.P2P != nil {
@@ -786,6 +1131,7 @@ func (app *SpacemeshApp) getIdentityFile() (string, error) {
 
 // Start starts the Spacemesh node and initializes all relevant services according to command line arguments provided.
 func (app *SpacemeshApp) Start(cmd *cobra.Command, args []string) {
+	defer app.dumpOnPanic()
 	log.With().Info("Starting Spacemesh", log.String("data-dir", app.Config.DataDir()), log.String("post-dir", app.Config.POST.DataDir))
 
 	err := filesystem.ExistOrCreate(app.Config.DataDir())
@@ -823,6 +1169,8 @@ func (app *SpacemeshApp) Start(cmd *cobra.Command, args []string) {
 
 	if app.Config.PprofHTTPServer {
 		log.Info("Starting pprof server")
+		// the tasks the node runs by layer and epoch, next to the pprof goroutine dump
+		http.Handle("/debug/tasks", tasks.Handler(uint16(app.Config.LayersPerEpoch)))
 		srv := &http.Server{Addr: ":6060"}
 		defer srv.Shutdown(context.TODO())
 		go func() {
@@ -868,6 +1216,13 @@ func (app *SpacemeshApp) Start(cmd *cobra.Command, args []string) {
 	clock := timesync.NewClock(timesync.RealClock{}, ld, gTime, log.NewDefault("clock"))
 
 	log.Info("Initializing P2P services")
+	// peers with another protocol config are rejected in the p2p handshake
+	app.Config.P2P.ProtocolHash = app.Config.Protocol().Hash()
+	log.With().Info("protocol config", log.String("protocol_hash", app.Config.P2P.ProtocolHash.ShortString()))
+	for _, u := range upgrade.List(app.Config.Upgrades) {
+		log.With().Info("protocol upgrade scheduled", log.String("upgrade", string(u.Name)), log.EpochID(uint64(u.Epoch)))
+	}
+	app.Config.P2P.SwarmConfig.DedupCacheSize = app.cacheBudget().Register(membudget.GossipDedup, app.Config.P2P.SwarmConfig.DedupCacheSize)
 	swarm, err := p2p.New(cmdp.Ctx, app.Config.P2P, app.addLogger(P2PLogger, lg), dbStorepath)
 	if err != nil {
 		log.Panic("Error starting p2p services. err: %v", err)
@@ -888,24 +1243,58 @@ func (app *SpacemeshApp) Start(cmd *cobra.Command, args []string) {
 	}
 
 	app.startServices()
+	app.dumpOnSignal()
 	// P2P must start last to not block when sending messages to protocols
-	err = app.P2P.Start()
-	if err != nil {
-		log.Panic("Error starting p2p services: %v", err)
+	if app.services.Enabled(cfg.P2PRole) {
+		err = app.P2P.Start()
+		if err != nil {
+			log.Panic("Error starting p2p services: %v", err)
+		}
 	}
 
 	/* Expose API */
 
 	// start api servers
-	if apiConf.StartGrpcServer || apiConf.StartJSONServer {
+	if !app.services.Enabled(cfg.APIRole) {
+		log.Info("api role disabled, not starting api servers")
+	} else if apiConf.StartGrpcServer || apiConf.StartJSONServer {
 		// start grpc if specified or if json rpc specified
 		layerDuration := app.Config.LayerDurationSec
+		var layerResults api.LayerResultsAPI
+		if app.layerResults != nil {
+			layerResults = app.layerResults
+		}
+		var posAtxs api.PosAtxAPI
+		var nodeAtxs api.NodeAtxsAPI
+		if app.atxDb != nil {
+			posAtxs = app.atxDb
+			nodeAtxs = app.atxDb
+		}
+		var beacons api.BeaconAPI
+		if app.tortoiseBeacon != nil {
+			beacons = app.tortoiseBeacon
+		}
+		var versions api.VersionAPI
+		if app.updater != nil {
+			versions = app.updater
+		}
+		var blocks api.BlockProducerAPI
+		var mining api.MiningAPI
+		if app.blockProducer != nil {
+			blocks = app.blockProducer
+			mining = app.atxBuilder
+		}
+		var syncer api.Syncer
+		if app.syncer != nil {
+			syncer = app.syncer
+		}
 		app.grpcAPIService = api.NewGrpcService(apiConf.GrpcServerPort, app.P2P, app.state, app.mesh, app.txPool,
-			app.atxBuilder, app.oracle, app.clock, postClient, layerDuration, app.syncer, app.Config, app)
+			mining, app.oracle, app.clock, postClient, layerDuration, syncer, app.Config, app, app, layerResults,
+			posAtxs, nodeAtxs, beacons, versions, blocks)
 		app.grpcAPIService.StartService()
 	}
 
-	if apiConf.StartJSONServer {
+	if app.services.Enabled(cfg.APIRole) && apiConf.StartJSONServer {
 		app.jsonAPIService = api.NewJSONHTTPServer(apiConf.JSONServerPort, apiConf.GrpcServerPort)
 		app.jsonAPIService.StartService()
 	}
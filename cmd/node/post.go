@@ -0,0 +1,76 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	cmdp "github.com/spacemeshos/go-spacemesh/cmd"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyRounds      int
+	commitmentRootHex string
+)
+
+// PostCmd groups the commands that operate on the node's PoST data.
+var PostCmd = &cobra.Command{
+	Use:   "post",
+	Short: "manage the node's PoST data",
+}
+
+// VerifyDataCmd checks the integrity of the node's initialized PoST data.
+var VerifyDataCmd = &cobra.Command{
+	Use:   "verify-data",
+	Short: "re-read the PoST data and verify random labels against the commitment",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := LoadConfigFromFile()
+		if err != nil {
+			log.With().Error("cannot load config", log.Err(err))
+			return
+		}
+		cmdp.EnsureCLIFlags(cmd.Root(), cfg)
+		app := NewSpacemeshApp()
+		app.Config = cfg
+		root, err := app.verifyPostData(util.FromHex(commitmentRootHex), verifyRounds)
+		if err != nil {
+			log.With().Error("PoST data verification failed", log.String("post-dir", cfg.POST.DataDir), log.Err(err))
+			return
+		}
+		log.With().Info("PoST data verified", log.String("post-dir", cfg.POST.DataDir),
+			log.String("merkle_root", util.Bytes2Hex(root)), log.Int("rounds", verifyRounds))
+	},
+}
+
+func init() {
+	PostCmd.AddCommand(VerifyDataCmd)
+	VerifyDataCmd.Flags().IntVar(&verifyRounds, "rounds", 3, "number of random challenges to verify the labels of")
+	VerifyDataCmd.Flags().StringVar(&commitmentRootHex, "commitment", "",
+		"hex merkle root of the commitment the data must match, e.g. from the node's published ATX")
+}
+
+// verifyPostData verifies the PoST data of the node's identity, without creating an identity if there is none. The
+// data directory is locked meanwhile, so that the data isn't verified while a running node initializes it.
+func (app *SpacemeshApp) verifyPostData(commitmentRoot []byte, rounds int) ([]byte, error) {
+	if err := app.lockStoreDirs([]string{app.Config.DataDir()}); err != nil {
+		return nil, err
+	}
+	defer app.unlockStoreDirs()
+	if _, err := app.getIdentityFile(); err != nil {
+		return nil, fmt.Errorf("no identity in PoST data dir: %v", err)
+	}
+	edSgn, err := app.LoadOrCreateEdSigner()
+	if err != nil {
+		return nil, err
+	}
+	postClient, err := activation.NewPostClient(&app.Config.POST, edSgn.PublicKey().Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create post client: %v", err)
+	}
+	if len(commitmentRoot) == 0 {
+		commitmentRoot = nil
+	}
+	return postClient.VerifyData(commitmentRoot, rounds)
+}
@@ -0,0 +1,196 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	cfg "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/filesystem"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/timesync"
+)
+
+// errUnsupported is returned by the preflight checks that the platform can't run, they're skipped.
+var errUnsupported = errors.New("not supported on this platform")
+
+// preflight checks that the node can run before any service is started, so that a misconfigured node fails fast
+// with an error that tells the operator what to fix instead of failing later in some subsystem.
+func (app *SpacemeshApp) preflight() error {
+	dirs := app.storeDirs()
+	if err := app.lockStoreDirs(dirs); err != nil {
+		return err
+	}
+	if err := checkDiskSpace(dirs, uint64(app.Config.DiskGrowthMB)); err != nil {
+		return err
+	}
+	if err := checkStoresUnlocked(dirs); err != nil {
+		return err
+	}
+	if err := checkPorts(app.listenAddresses()); err != nil {
+		return err
+	}
+	drift, err := timesync.CheckSystemClockDrift()
+	if err != nil {
+		return fmt.Errorf("clock check failed, make sure the system clock is synchronized with ntp: %v", err)
+	}
+	log.Info("System clock synchronized with ntp. drift: %s", drift)
+	return nil
+}
+
+// storeDirs returns the distinct directories the node keeps its stores in.
+func (app *SpacemeshApp) storeDirs() []string {
+	dataDir := app.Config.DataDir()
+	seen := map[string]bool{dataDir: true}
+	dirs := []string{dataDir}
+	for name := range app.Config.StoreDirs {
+		if dir := app.Config.StoreDir(dataDir, name); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// lockStoreDirs locks dirs for the node, so that another node can't corrupt the stores by using them at the same
+// time. The locks are released by unlockStoreDirs.
+func (app *SpacemeshApp) lockStoreDirs(dirs []string) error {
+	for _, dir := range dirs {
+		lock, err := filesystem.LockDir(dir)
+		if _, ok := err.(filesystem.ErrDirLocked); ok {
+			return fmt.Errorf("%v: another node is running with this data folder, stop it "+
+				"or run this one with another --data-folder", err)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot lock %v: %v", dir, err)
+		}
+		app.dirLocks = append(app.dirLocks, lock)
+	}
+	return nil
+}
+
+func (app *SpacemeshApp) unlockStoreDirs() {
+	for _, lock := range app.dirLocks {
+		if err := lock.Unlock(); err != nil {
+			log.Warning("failed to release data folder lock: %v", err)
+		}
+	}
+	app.dirLocks = nil
+}
+
+// listenAddresses returns the addresses the node will listen on, by the flag that configures them.
+func (app *SpacemeshApp) listenAddresses() map[string]string {
+	addrs := make(map[string]string)
+	port := func(p int) string { return ":" + strconv.Itoa(p) }
+	if app.Config.P2P.TCPPort != 0 {
+		addrs["tcp-port"] = port(app.Config.P2P.TCPPort)
+	}
+	if hasRole(app.Config.Roles, cfg.APIRole) {
+		if app.Config.API.StartGrpcServer || app.Config.API.StartJSONServer {
+			addrs["grpc-port"] = port(app.Config.API.GrpcServerPort)
+			if app.Config.API.AdminAPI {
+				addrs["admin-listener"] = app.Config.API.AdminListener
+			}
+		}
+		if app.Config.API.StartJSONServer {
+			addrs["json-port"] = port(app.Config.API.JSONServerPort)
+		}
+	}
+	if app.Config.CollectMetrics {
+		addrs["metrics-port"] = port(app.Config.MetricsPort)
+	}
+	if app.Config.ReplicationListen != "" {
+		addrs["replication-listen"] = app.Config.ReplicationListen
+	}
+	return addrs
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDiskSpace returns an error if any of dirs has less than growthMB of free disk space.
+func checkDiskSpace(dirs []string, growthMB uint64) error {
+	if growthMB == 0 {
+		return nil
+	}
+	for _, dir := range dirs {
+		free, err := freeDiskSpace(dir)
+		if err == errUnsupported {
+			log.Warning("skipping disk space check of %v: %v", dir, err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot check the free disk space of %v: %v", dir, err)
+		}
+		if free/(1<<20) < growthMB {
+			return fmt.Errorf("only %d MB of disk space are free in %v, the node's stores are expected to grow by %d MB: "+
+				"free up disk space, move stores with store-dirs or lower --disk-growth-mb", free/(1<<20), dir, growthMB)
+		}
+	}
+	return nil
+}
+
+// checkStoresUnlocked returns an error if one of the stores in dirs is held open by another process, i.e. another
+// node runs with the same data folder. The stores of a database are found by their LOCK files.
+func checkStoresUnlocked(dirs []string) error {
+	for _, dir := range dirs {
+		for _, pattern := range []string{"*/LOCK", "*/*/LOCK"} {
+			locks, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return err
+			}
+			for _, lock := range locks {
+				held, err := fileLocked(lock)
+				if err == errUnsupported {
+					log.Warning("skipping store lock check: %v", err)
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("cannot check store lock %v: %v", lock, err)
+				}
+				if held {
+					return fmt.Errorf("the store in %v is used by another process: stop the other node "+
+						"or run this one with another --data-folder", filepath.Dir(lock))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkPorts returns an error if one of addrs, by the flag that configures it, can't be bound. tcp and udp are
+// checked, since the p2p port serves both.
+func checkPorts(addrs map[string]string) error {
+	flags := make([]string, 0, len(addrs))
+	for flag := range addrs {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		addr := addrs[flag]
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("cannot listen on %v set by --%v, is another process using it? %v", addr, flag, err)
+		}
+		l.Close()
+		if flag != "tcp-port" {
+			continue
+		}
+		c, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return fmt.Errorf("cannot listen on udp %v set by --%v, is another process using it? %v", addr, flag, err)
+		}
+		c.Close()
+	}
+	return nil
+}
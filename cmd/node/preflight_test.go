@@ -0,0 +1,66 @@
+package node
+
+import (
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	r.NoError(checkDiskSpace([]string{dir}, 0))
+	r.NoError(checkDiskSpace([]string{dir}, 1))
+	r.Error(checkDiskSpace([]string{dir}, math.MaxUint64>>20))
+}
+
+func TestCheckStoresUnlocked(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	db, err := database.NewLDBDatabase(filepath.Join(dir, "mesh", "blocks"), 0, 0, log.NewDefault(t.Name()))
+	r.NoError(err)
+	r.Error(checkStoresUnlocked([]string{dir}))
+
+	db.Close()
+	r.NoError(checkStoresUnlocked([]string{dir}))
+}
+
+func TestCheckPorts(t *testing.T) {
+	r := require.New(t)
+	l, err := net.Listen("tcp", ":0")
+	r.NoError(err)
+	addr := l.Addr().String()
+
+	r.Error(checkPorts(map[string]string{"grpc-port": addr}))
+	l.Close()
+	r.NoError(checkPorts(map[string]string{"grpc-port": addr}))
+}
+
+func TestSpacemeshApp_LockStoreDirs(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	app := NewSpacemeshApp()
+	r.NoError(app.lockStoreDirs([]string{dir}))
+	other := NewSpacemeshApp()
+	r.Error(other.lockStoreDirs([]string{dir}))
+
+	app.unlockStoreDirs()
+	r.NoError(other.lockStoreDirs([]string{dir}))
+	other.unlockStoreDirs()
+}
@@ -0,0 +1,34 @@
+// +build !windows
+
+package node
+
+import (
+	"os"
+	"syscall"
+)
+
+// freeDiskSpace returns the number of bytes available to the node on the file system of dir.
+func freeDiskSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+// fileLocked returns true if another open file holds an exclusive flock on file, like leveldb does on its LOCK file.
+func fileLocked(file string) (bool, error) {
+	f, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,9 @@
+package node
+
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errUnsupported
+}
+
+func fileLocked(file string) (bool, error) {
+	return false, errUnsupported
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"fmt"
+
+	cfg "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// roleDependencies lists, for every role, the roles it can't run without.
+var roleDependencies = map[string][]string{
+	cfg.P2PRole:       {},
+	cfg.SyncRole:      {cfg.P2PRole},
+	cfg.ConsensusRole: {cfg.P2PRole, cfg.SyncRole},
+	cfg.MiningRole:    {cfg.P2PRole, cfg.SyncRole, cfg.ConsensusRole},
+	cfg.APIRole:       {}, // read replicas serve the API with data replicated from a leader
+}
+
+type roleService struct {
+	role  string
+	name  string
+	start func() error
+	stop  func()
+}
+
+// serviceRegistry starts and stops the node's subsystems according to the roles enabled for the node, so that a
+// deployment can e.g. run API-only replicas that sync the mesh without taking part in consensus or mining. Services
+// are started in the order they were registered and stopped in reverse order.
+type serviceRegistry struct {
+	roles    map[string]bool
+	services []roleService
+	started  []roleService
+	log      log.Log
+}
+
+// newServiceRegistry returns a registry for the given roles, or an error if a role is unknown or one of its
+// dependencies is not enabled.
+func newServiceRegistry(roles []string, logger log.Log) (*serviceRegistry, error) {
+	enabled := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		if _, ok := roleDependencies[role]; !ok {
+			return nil, fmt.Errorf("unknown role %q, known roles are %v", role, cfg.AllRoles)
+		}
+		enabled[role] = true
+	}
+	for role := range enabled {
+		for _, dep := range roleDependencies[role] {
+			if !enabled[dep] {
+				return nil, fmt.Errorf("role %q requires role %q", role, dep)
+			}
+		}
+	}
+	return &serviceRegistry{roles: enabled, log: logger}, nil
+}
+
+// Enabled returns true if the node runs the given role.
+func (r *serviceRegistry) Enabled(role string) bool {
+	return r.roles[role]
+}
+
+// startFunc adapts a start method that can't fail to Register.
+func startFunc(start func()) func() error {
+	return func() error {
+		start()
+		return nil
+	}
+}
+
+// Register adds a service of the given role. stop may be nil.
+func (r *serviceRegistry) Register(role, name string, start func() error, stop func()) {
+	r.services = append(r.services, roleService{role: role, name: name, start: start, stop: stop})
+}
+
+// Start starts the services of all enabled roles.
+func (r *serviceRegistry) Start() error {
+	for _, s := range r.services {
+		if !r.roles[s.role] {
+			r.log.With().Info("role disabled, not starting service", log.String("role", s.role), log.String("service", s.name))
+			continue
+		}
+		if err := s.start(); err != nil {
+			return fmt.Errorf("cannot start %v: %v", s.name, err)
+		}
+		r.started = append(r.started, s)
+	}
+	return nil
+}
+
+// Stop stops the services that were started, in reverse order.
+func (r *serviceRegistry) Stop() {
+	for i := len(r.started) - 1; i >= 0; i-- {
+		s := r.started[i]
+		if s.stop == nil {
+			continue
+		}
+		r.log.Info("closing %v", s.name)
+		s.stop()
+	}
+	r.started = nil
+}
@@ -0,0 +1,51 @@
+package node
+
+import (
+	"errors"
+	"testing"
+
+	cfg "github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServiceRegistry(t *testing.T) {
+	r := require.New(t)
+	lg := log.NewDefault("roles")
+
+	_, err := newServiceRegistry(cfg.AllRoles, lg)
+	r.NoError(err)
+	_, err = newServiceRegistry([]string{cfg.P2PRole, cfg.SyncRole, cfg.APIRole}, lg)
+	r.NoError(err)
+
+	_, err = newServiceRegistry([]string{cfg.P2PRole, "gpu"}, lg)
+	r.Error(err)
+	_, err = newServiceRegistry([]string{cfg.P2PRole, cfg.SyncRole, cfg.MiningRole}, lg)
+	r.EqualError(err, `role "mining" requires role "consensus"`)
+	_, err = newServiceRegistry([]string{cfg.APIRole}, lg)
+	r.NoError(err)
+}
+
+func TestServiceRegistry_StartStop(t *testing.T) {
+	r := require.New(t)
+	services, err := newServiceRegistry([]string{cfg.P2PRole, cfg.SyncRole, cfg.APIRole}, log.NewDefault("roles"))
+	r.NoError(err)
+	r.True(services.Enabled(cfg.SyncRole))
+	r.False(services.Enabled(cfg.MiningRole))
+
+	var events []string
+	register := func(role, name string) {
+		services.Register(role, name, startFunc(func() { events = append(events, "start "+name) }),
+			func() { events = append(events, "stop "+name) })
+	}
+	register(cfg.SyncRole, "a")
+	register(cfg.MiningRole, "b")
+	register(cfg.P2PRole, "c")
+
+	r.NoError(services.Start())
+	services.Stop()
+	r.Equal([]string{"start a", "start c", "stop c", "stop a"}, events)
+
+	services.Register(cfg.P2PRole, "d", func() error { return errors.New("boom") }, nil)
+	r.EqualError(services.Start(), "cannot start d: boom")
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/testvectors"
+	"github.com/spf13/cobra"
+)
+
+var testVectorsOut string
+
+// TestVectorsCmd writes the test vectors of the protocol's encodings, for external client implementations.
+var TestVectorsCmd = &cobra.Command{
+	Use:   "test-vectors",
+	Short: "write the canonical encodings of the protocol's messages as JSON test vectors",
+	Run: func(cmd *cobra.Command, args []string) {
+		vectors, err := testvectors.Generate()
+		if err != nil {
+			log.With().Error("cannot generate test vectors", log.Err(err))
+			return
+		}
+		data, err := json.MarshalIndent(vectors, "", "  ")
+		if err != nil {
+			log.With().Error("cannot encode test vectors", log.Err(err))
+			return
+		}
+		data = append(data, '\n')
+		if testVectorsOut == "" {
+			_, _ = os.Stdout.Write(data)
+			return
+		}
+		if err := ioutil.WriteFile(testVectorsOut, data, 0644); err != nil {
+			log.With().Error("cannot write test vectors", log.String("file", testVectorsOut), log.Err(err))
+			return
+		}
+		log.With().Info("test vectors written", log.String("file", testVectorsOut), log.Int("version", testvectors.Version))
+	},
+}
+
+func init() {
+	TestVectorsCmd.Flags().StringVar(&testVectorsOut, "out", "", "file to write the test vectors to (defaults to stdout)")
+}
@@ -20,6 +20,8 @@ func AddCommands(cmd *cobra.Command) {
 		"config", "c", config.BaseConfig.ConfigFile, "Set Load configuration from file")
 	cmd.PersistentFlags().StringVarP(&config.BaseConfig.DataDirParent, "data-folder", "d",
 		config.BaseConfig.DataDirParent, "Specify data directory for spacemesh")
+	cmd.PersistentFlags().IntVar(&config.DiskGrowthMB, "disk-growth-mb",
+		config.DiskGrowthMB, "free disk space in MB the node's stores are expected to grow into, the node won't start with less (0 disables the check)")
 	cmd.PersistentFlags().BoolVar(&config.TestMode, "test-mode",
 		config.TestMode, "Initialize testing features")
 	cmd.PersistentFlags().BoolVar(&config.CollectMetrics, "metrics",
@@ -32,12 +34,46 @@ func AddCommands(cmd *cobra.Command) {
 		config.OracleServerWorldID, "The worldid to use with the oracle server (temporary) ")
 	cmd.PersistentFlags().StringVar(&config.PoETServer, "poet-server",
 		config.OracleServer, "The poet server url. (temporary) ")
+	cmd.PersistentFlags().IntVar(&config.PoetRoundMarginSec, "poet-round-margin-sec",
+		config.PoetRoundMarginSec, "Margin in seconds to keep before a PoET round closes and before the ATX publication deadline")
+	cmd.PersistentFlags().Uint64Var(&config.TickSize, "tick-size",
+		config.TickSize, "number of PoET leaves in a single tick")
+	cmd.PersistentFlags().StringVar(&config.PosAtxPolicy, "pos-atx-policy",
+		config.PosAtxPolicy, "selection of the ATX that new ATXs are positioned on: highest, random-top or own-prev")
+	cmd.PersistentFlags().IntVar(&config.PosAtxTopN, "pos-atx-top-n",
+		config.PosAtxTopN, "number of highest ATXs that the random-top positioning ATX policy picks from")
 	cmd.PersistentFlags().StringVar(&config.GenesisTime, "genesis-time",
 		config.GenesisTime, "Time of the genesis layer in 2019-13-02T17:02:00+00:00 format")
 	cmd.PersistentFlags().IntVar(&config.LayerDurationSec, "layer-duration-sec",
 		config.LayerDurationSec, "Duration between layers in seconds")
 	cmd.PersistentFlags().IntVar(&config.LayerAvgSize, "layer-average-size",
 		config.LayerAvgSize, "Layer Avg size")
+	cmd.PersistentFlags().IntVar(&config.TargetLayerSize, "target-layer-size",
+		config.TargetLayerSize, "expected number of blocks per layer that block eligibility is derived from (defaults to layer-average-size)")
+	cmd.PersistentFlags().StringVar(&config.EligibilityOracle, "eligibility-oracle",
+		config.EligibilityOracle, "eligibility oracle for blocks and hare: vrf, or pow for local dev networks without PoET and PoST")
+	cmd.PersistentFlags().IntVar(&config.PowDifficulty, "pow-difficulty",
+		config.PowDifficulty, "leading zero bits required by the pow eligibility oracle")
+	cmd.PersistentFlags().StringSliceVar(&config.Roles, "roles",
+		config.Roles, "comma separated subsystems to run: p2p, sync, consensus, mining and api (e.g. p2p,sync,api for an API-only node)")
+	cmd.PersistentFlags().StringVar(&config.ReplicationListen, "replication-listen",
+		config.ReplicationListen, "address to stream applied layers to read replicas on (trusted networks only)")
+	cmd.PersistentFlags().StringVar(&config.ReplicationLeader, "replication-leader",
+		config.ReplicationLeader, "address of a node started with --replication-listen, runs this node as an API-only read replica")
+	cmd.PersistentFlags().StringVar(&config.StateSyncRoot, "state-sync-root",
+		config.StateSyncRoot, "hex state root of a trusted checkpoint, fetched from peers so that layers up to the checkpoint aren't applied")
+	cmd.PersistentFlags().IntVar(&config.StateSyncLayer, "state-sync-layer",
+		config.StateSyncLayer, "the layer that --state-sync-root is the state of")
+	cmd.PersistentFlags().IntVar(&config.CertifyCommitteeSize, "certify-committee-size",
+		config.CertifyCommitteeSize, "expected size of the committee that certifies hare outputs before they are applied to state (0 disables certification)")
+	cmd.PersistentFlags().IntVar(&config.CertifyThreshold, "certify-threshold",
+		config.CertifyThreshold, "number of committee signatures required to certify a hare output (0 for a majority of the committee)")
+	cmd.PersistentFlags().IntVar(&config.UpdateCheckInterval, "update-check-interval",
+		config.UpdateCheckInterval, "seconds between checks of the node's version against the versions of peers and the release feed (0 disables them)")
+	cmd.PersistentFlags().StringVar(&config.ReleaseFeedURL, "release-feed-url",
+		config.ReleaseFeedURL, "url of a signed release feed announcing the latest and the minimum recommended version")
+	cmd.PersistentFlags().StringVar(&config.ReleaseFeedKey, "release-feed-key",
+		config.ReleaseFeedKey, "hex ed25519 public key that signs the release feed")
 	cmd.PersistentFlags().IntVar(&config.Hdist, "hdist",
 		config.Hdist, "hdist")
 	cmd.PersistentFlags().BoolVar(&config.StartMining, "start-mining",
@@ -53,12 +89,24 @@ func AddCommands(cmd *cobra.Command) {
 		config.GenesisConfPath, "add genesis configuration")
 	cmd.PersistentFlags().StringVar(&config.CoinbaseAccount, "coinbase",
 		config.CoinbaseAccount, "coinbase account to accumulate rewards")
+	cmd.PersistentFlags().StringVar(&config.AddressHRP, "address-hrp",
+		config.AddressHRP, "human readable part of bech32 addresses on this network")
 
 	cmd.PersistentFlags().IntVar(&config.GenesisActiveSet, "genesis-active-size",
 		config.GenesisActiveSet, "The active set size for the genesis flow")
+	cmd.PersistentFlags().IntVar(&config.ActiveSetGraceLayers, "active-set-grace-layers",
+		config.ActiveSetGraceLayers, "number of layers at the start of an epoch whose blocks still count towards its active set after the first-seen-active-set upgrade")
+	cmd.PersistentFlags().IntVar(&config.BeaconProposals, "beacon-proposals",
+		config.BeaconProposals, "expected number of tortoise beacon proposals in an epoch")
+	cmd.PersistentFlags().IntVar(&config.BeaconProposalLayers, "beacon-proposal-layers",
+		config.BeaconProposalLayers, "number of layers at the start of an epoch that tortoise beacon proposals are accepted in")
+	cmd.PersistentFlags().IntVar(&config.BeaconVotingLayers, "beacon-voting-layers",
+		config.BeaconVotingLayers, "number of layers after the proposal phase that tortoise beacon votes are accepted in")
 
 	cmd.PersistentFlags().IntVar(&config.BlockCacheSize, "block-cache-size",
 		config.BlockCacheSize, "size in layers of meshdb block cache")
+	cmd.PersistentFlags().IntVar(&config.CacheMB, "cache-mb",
+		config.CacheMB, "memory budget in MB divided between the node's caches, overrides their own sizes (0 keeps them)")
 
 	cmd.PersistentFlags().StringVar(&config.PublishEventsURL, "events-url",
 		config.PublishEventsURL, "publish events on this url, if no url specified event will no be published")
@@ -66,9 +114,17 @@ func AddCommands(cmd *cobra.Command) {
 	cmd.PersistentFlags().IntVar(&config.SyncRequestTimeout, "sync-request-timeout",
 		2000, "the timeout in ms for direct requests in the sync")
 
+	cmd.PersistentFlags().IntVar(&config.SyncRepairInterval, "sync-repair-interval",
+		config.SyncRepairInterval, "interval in seconds of the repair that fetches blocks missing from stored views (0 disables it)")
+	cmd.PersistentFlags().IntVar(&config.FinalityDepth, "finality-depth",
+		config.FinalityDepth, "number of layers after which applied layers are final, the state isn't rolled back to them without an admin's approval (0 disables finality)")
+
 	cmd.PersistentFlags().IntVar(&config.AtxsPerBlock, "atxs-per-block",
 		100, "the number of atxs to select per block on block creation")
 
+	cmd.PersistentFlags().Uint64Var(&config.MinGasPrice, "min-gas-price",
+		config.MinGasPrice, "the minimum fee per unit of gas limit of transactions accepted to the mempool and relayed")
+
 	/** ======================== P2P Flags ========================== **/
 
 	cmd.PersistentFlags().IntVar(&config.P2P.TCPPort, "tcp-port",
@@ -111,6 +167,18 @@ func AddCommands(cmd *cobra.Command) {
 		config.TIME.MaxAllowedDrift, "When to close the app until user resolves time sync problems")
 	cmd.PersistentFlags().StringVar(&config.P2P.SwarmConfig.PeersFile, "peers-file",
 		config.P2P.SwarmConfig.PeersFile, "addrbook peers file. located under data-dir/<publickey>/<peer-file> not loaded or saved if empty string is given.")
+	cmd.PersistentFlags().StringSliceVar(&config.P2P.SwarmConfig.LazyPushProtocols, "lazy-push-protocols",
+		config.P2P.SwarmConfig.LazyPushProtocols, "Gossip protocols whose messages are announced to peers and pulled on demand instead of flooded")
+	cmd.PersistentFlags().DurationVar(&config.P2P.SwarmConfig.DedupPersistWindow, "dedup-persist-window",
+		config.P2P.SwarmConfig.DedupPersistWindow, "How long seen gossip messages are remembered across restarts, 0 disables it")
+	cmd.PersistentFlags().StringVar(&config.P2P.SwarmConfig.DirectoryURL, "directory-url",
+		config.P2P.SwarmConfig.DirectoryURL, "Base url of a peer directory to bootstrap from, disabled if empty")
+	cmd.PersistentFlags().BoolVar(&config.P2P.SwarmConfig.DirectoryRegister, "directory-register",
+		config.P2P.SwarmConfig.DirectoryRegister, "Periodically register the node's signed record with the peer directory")
+	cmd.PersistentFlags().DurationVar(&config.P2P.SwarmConfig.DirectoryInterval, "directory-interval",
+		config.P2P.SwarmConfig.DirectoryInterval, "Interval of registrations with the peer directory")
+	cmd.PersistentFlags().StringSliceVar(&config.P2P.SwarmConfig.DirectoryCapabilities, "directory-capabilities",
+		config.P2P.SwarmConfig.DirectoryCapabilities, "Capabilities the node advertises in the peer directory, e.g. bootnode")
 	cmd.PersistentFlags().IntVar(&config.TIME.NtpQueries, "ntp-queries",
 		config.TIME.NtpQueries, "Number of ntp queries to do")
 	cmd.PersistentFlags().DurationVar(&config.TIME.DefaultTimeoutLatency, "default-timeout-latency",
@@ -136,6 +204,12 @@ func AddCommands(cmd *cobra.Command) {
 	// GrpcServerPortFlag determines the grpc server local listening port
 	cmd.PersistentFlags().IntVar(&config.API.GrpcServerPort, "grpc-port",
 		config.API.GrpcServerPort, "GRPC api server port")
+	cmd.PersistentFlags().BoolVar(&config.API.AdminAPI, "admin-api",
+		config.API.AdminAPI, "Enable the admin api endpoints, e.g. mempool inspection and eviction")
+	cmd.PersistentFlags().StringVar(&config.API.AdminListener, "admin-listener",
+		config.API.AdminListener, "Address the admin api is served on with grpc, apart from the other endpoints")
+	cmd.PersistentFlags().IntVar(&config.API.LayerResultsCache, "layer-results-cache",
+		config.API.LayerResultsCache, "Number of latest layers whose execution results are cached for the api, 0 to disable")
 
 	/**======================== Hare Flags ========================== **/
 
@@ -156,6 +230,10 @@ func AddCommands(cmd *cobra.Command) {
 		config.HARE.LimitIterations, "The limit of the number of iteration per consensus process")
 	cmd.PersistentFlags().IntVar(&config.HARE.LimitConcurrent, "hare-limit-concurrent",
 		config.HARE.LimitConcurrent, "The number of consensus processes running concurrently")
+	cmd.PersistentFlags().BoolVar(&config.HARE.SingleBlock, "hare-single-block",
+		config.HARE.SingleBlock, "Agree on a single leader block per layer instead of a set of blocks")
+	cmd.PersistentFlags().BoolVar(&config.HARE.ListenOnly, "hare-listen-only",
+		config.HARE.ListenOnly, "Follow and validate hare consensus without ever signing or sending hare messages")
 
 	/**======================== Hare Eligibility Oracle Flags ========================== **/
 
@@ -163,6 +241,8 @@ func AddCommands(cmd *cobra.Command) {
 		config.HareEligibility.ConfidenceParam, "The relative layer (with respect to the current layer) we are confident to have consensus about")
 	cmd.PersistentFlags().IntVar(&config.HareEligibility.EpochOffset, "eligibility-epoch-offset",
 		config.HareEligibility.EpochOffset, "The constant layer (within an epoch) for which we traverse its view for the purpose of counting consensus active set")
+	cmd.PersistentFlags().IntVar(&config.HareEligibility.CacheSize, "eligibility-cache-size",
+		config.HareEligibility.CacheSize, "The max number of hare eligibility results cached by identity, layer and round (0 disables the cache)")
 
 	/**======================== PoST Flags ========================== **/
 
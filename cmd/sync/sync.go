@@ -96,6 +96,7 @@ func (app *syncApp) start(cmd *cobra.Command, args []string) {
 
 	lg.Info("local db path: ", path)
 
+	app.Config.P2P.ProtocolHash = app.Config.Protocol().Hash()
 	swarm, err := p2p.New(cmdp.Ctx, app.Config.P2P, lg.WithName("p2p"), app.Config.DataDir())
 
 	if err != nil {
@@ -7,7 +7,6 @@ import (
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/poet/shared"
 	"github.com/spacemeshos/post/proving"
-	"github.com/spacemeshos/sha256-simd"
 	"strings"
 )
 
@@ -55,12 +54,22 @@ func (t ATXID) Field() log.Field { return t.Hash32().Field("atx_id") }
 var EmptyATXID = &ATXID{}
 
 // ActivationTxHeader is the header of an activation transaction. It includes all fields from the NIPSTChallenge, as
-// well as the coinbase address and active set size.
+// well as the coinbase address, active set size and the number of space units committed by the miner.
 type ActivationTxHeader struct {
 	NIPSTChallenge
 	id            *ATXID // non-exported cache of the ATXID
 	Coinbase      Address
 	ActiveSetSize uint32
+	SpaceUnits    uint32 // the number of space units committed by the NIPST, which determines the miner's weight
+}
+
+// EffectiveSpaceUnits returns the number of space units the ATX is weighted by. Every ATX counts for at least one space
+// unit.
+func (atxh *ActivationTxHeader) EffectiveSpaceUnits() uint32 {
+	if atxh.SpaceUnits == 0 {
+		return 1
+	}
+	return atxh.SpaceUnits
 }
 
 // ShortString returns the first 5 characters of the ID, for logging purposes.
@@ -174,6 +183,32 @@ func (atx *ActivationTx) InnerBytes() ([]byte, error) {
 	return InterfaceToBytes(atx.InnerActivationTx)
 }
 
+// atxHeaderDomain prefixes the bytes that header-first atxs sign. Serialized inner atxs start with the presence marker
+// of their header, so the signed bytes of the two kinds of atxs can't be equal.
+const atxHeaderDomain = "spacemesh/atx-header/1"
+
+// HeaderBytes returns the bytes that an atx gossiped without its NIPST signs: the inner atx without the NIPST,
+// followed by nipstHash, the hash of the NIPST, so that the signature commits to the NIPST that's fetched later.
+func (atx *ActivationTx) HeaderBytes(nipstHash Hash32) ([]byte, error) {
+	inner := *atx.InnerActivationTx
+	inner.Nipst = nil
+	bts, err := InterfaceToBytes(&inner)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(atxHeaderDomain)+len(bts)+Hash32Length)
+	buf = append(buf, atxHeaderDomain...)
+	buf = append(buf, bts...)
+	return append(buf, nipstHash[:]...), nil
+}
+
+// AtxHeader is an atx gossiped without its NIPST, with the hash of the NIPST that the atx's signature covers. Nodes
+// that need the NIPST fetch it from the peer that sent the header and check it against NipstHash.
+type AtxHeader struct {
+	Atx       *ActivationTx // its Nipst is nil
+	NipstHash Hash32
+}
+
 // Fields returns an array of LoggableFields for logging
 func (atx *ActivationTx) Fields(layersPerEpoch uint16, size int) []log.LoggableField {
 	commitmentStr := ""
@@ -195,6 +230,7 @@ func (atx *ActivationTx) Fields(layersPerEpoch uint16, size int) []log.LoggableF
 		atx.PubLayerID,
 		atx.PubLayerID.GetEpoch(layersPerEpoch),
 		log.Uint32("active_set", atx.ActiveSetSize),
+		log.Uint32("space_units", atx.SpaceUnits),
 		log.Int("viewlen", len(atx.View)),
 		log.Uint64("sequence_number", atx.Sequence),
 		log.String("NIPSTChallenge", challenge),
@@ -244,7 +280,7 @@ type PoetProofMessage struct {
 	Signature     []byte
 }
 
-// Ref returns the reference to the PoET proof message. It's the sha256 sum of the entire proof message.
+// Ref returns the reference to the PoET proof message. It's the protocol hash sum of the entire proof message.
 func (proofMessage PoetProofMessage) Ref() ([]byte, error) {
 	poetProofBytes, err := InterfaceToBytes(&proofMessage.PoetProof)
 	if err != nil {
@@ -252,7 +288,7 @@ func (proofMessage PoetProofMessage) Ref() ([]byte, error) {
 			proofMessage.PoetServiceID, proofMessage.RoundID, err)
 	}
 
-	ref := sha256.Sum256(poetProofBytes)
+	ref := CalcHash32(poetProofBytes)
 	return ref[:], nil
 }
 
@@ -280,6 +316,15 @@ type NIPST struct {
 	PostProof *PostProof
 }
 
+// Hash returns the hash of the serialized NIPST, that atxs gossiped without their NIPST sign.
+func (nipst *NIPST) Hash() (Hash32, error) {
+	bts, err := InterfaceToBytes(nipst)
+	if err != nil {
+		return Hash32{}, err
+	}
+	return CalcHash32(bts), nil
+}
+
 // PostProof is an alias to the PoST proof.
 type PostProof proving.Proof
 
@@ -3,14 +3,19 @@ package types
 import (
 	"encoding/hex"
 	"fmt"
+	"github.com/btcsuite/btcutil/bech32"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/crypto/sha3"
 	"math/big"
+	"strings"
 )
 
 const (
 	// AddressLength is the expected length of the address
 	AddressLength = 20
+
+	// DefaultAddressHRP is the human readable part of bech32 addresses used when no network specific one is configured
+	DefaultAddressHRP = "sm"
 )
 
 // Address represents the 20 byte address of an spacemesh account.
@@ -51,6 +56,61 @@ func StringToAddress(s string) (Address, error) {
 	return BytesToAddress(bt), nil
 }
 
+// PublicKeyToAddress deterministically derives the address of the account controlled by the given public key.
+// The address is the last AddressLength bytes of the key.
+func PublicKeyToAddress(pub []byte) Address {
+	return BytesToAddress(pub)
+}
+
+// Bech32ToAddress decodes a bech32 encoded address. An error is returned if the checksum is invalid, the payload is not
+// AddressLength bytes long or the human readable part isn't hrp, the one of the node's network.
+func Bech32ToAddress(s, hrp string) (Address, error) {
+	got, data, err := bech32.Decode(s)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid bech32 address: %v", err)
+	}
+	if got != hrp {
+		return Address{}, fmt.Errorf("address belongs to network %q, expected %q", got, hrp)
+	}
+	bt, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid bech32 address payload: %v", err)
+	}
+	if len(bt) != AddressLength {
+		return Address{}, fmt.Errorf("invalid address length %d, expected %d", len(bt), AddressLength)
+	}
+	return BytesToAddress(bt), nil
+}
+
+// HexToBech32 converts an address given in its raw hex form to its bech32 form with the human readable part hrp.
+func HexToBech32(s, hrp string) (string, error) {
+	addr, err := StringToAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return addr.Bech32(hrp), nil
+}
+
+// ParseAddress parses an address given either in its bech32 form or in its legacy raw hex form. Bech32 addresses must
+// carry the human readable part hrp.
+func ParseAddress(s, hrp string) (Address, error) {
+	if isBech32(s) {
+		return Bech32ToAddress(s, hrp)
+	}
+	return StringToAddress(s)
+}
+
+// isBech32 reports whether s has the shape of a bech32 string ("<hrp>1<data>") rather than of a hex string.
+func isBech32(s string) bool {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return false
+	}
+	isHex := strings.IndexFunc(s, func(r rune) bool {
+		return !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F')
+	}) == -1
+	return !isHex && strings.LastIndexByte(s, '1') > 0
+}
+
 // Bytes gets the string representation of the underlying address.
 func (a Address) Bytes() []byte { return a[:] }
 
@@ -82,6 +142,19 @@ func (a Address) Hex() string {
 	return "0x" + string(result)
 }
 
+// Bech32 returns the checksummed bech32 representation of the address with the human readable part hrp.
+func (a Address) Bech32(hrp string) string {
+	data, err := bech32.ConvertBits(a[:], 8, 5, true)
+	if err != nil {
+		panic("failed to convert address bits: " + err.Error())
+	}
+	s, err := bech32.Encode(hrp, data)
+	if err != nil {
+		panic("failed to encode address: " + err.Error())
+	}
+	return s
+}
+
 // String implements fmt.Stringer.
 func (a Address) String() string {
 	return a.Hex()
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestAddress_Bech32RoundTrip(t *testing.T) {
+	addr := BytesToAddress([]byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x02, 0x03})
+	enc := addr.Bech32(DefaultAddressHRP)
+	assert.True(t, strings.HasPrefix(enc, DefaultAddressHRP+"1"))
+
+	dec, err := Bech32ToAddress(enc, DefaultAddressHRP)
+	require.NoError(t, err)
+	assert.Equal(t, addr, dec)
+
+	parsed, err := ParseAddress(enc, DefaultAddressHRP)
+	require.NoError(t, err)
+	assert.Equal(t, addr, parsed)
+}
+
+func TestAddress_Bech32Checksum(t *testing.T) {
+	enc := BytesToAddress([]byte{1, 2, 3, 4}).Bech32(DefaultAddressHRP)
+
+	// flip the last character, which is part of the checksum
+	last := enc[len(enc)-1]
+	replacement := byte('q')
+	if last == replacement {
+		replacement = 'p'
+	}
+	typo := enc[:len(enc)-1] + string(replacement)
+
+	_, err := Bech32ToAddress(typo, DefaultAddressHRP)
+	assert.Error(t, err)
+	_, err = ParseAddress(typo, DefaultAddressHRP)
+	assert.Error(t, err)
+}
+
+func TestAddress_Bech32WrongNetwork(t *testing.T) {
+	addr := BytesToAddress([]byte{1, 2, 3, 4})
+
+	enc := addr.Bech32("smtest")
+
+	_, err := Bech32ToAddress(enc, DefaultAddressHRP)
+	assert.Error(t, err)
+	_, err = ParseAddress(enc, DefaultAddressHRP)
+	assert.Error(t, err)
+}
+
+func TestParseAddress_Hex(t *testing.T) {
+	addr := BytesToAddress([]byte{0x11, 0x22, 0x33})
+
+	parsed, err := ParseAddress(addr.Hex(), DefaultAddressHRP)
+	require.NoError(t, err)
+	assert.Equal(t, addr, parsed)
+
+	parsed, err = ParseAddress("112233", DefaultAddressHRP)
+	require.NoError(t, err)
+	assert.Equal(t, addr, parsed)
+
+	_, err = ParseAddress("0x11223g", DefaultAddressHRP)
+	assert.Error(t, err)
+}
+
+func TestHexToBech32(t *testing.T) {
+	addr := BytesToAddress([]byte{0x11, 0x22, 0x33})
+
+	enc, err := HexToBech32(addr.Hex(), DefaultAddressHRP)
+	require.NoError(t, err)
+	assert.Equal(t, addr.Bech32(DefaultAddressHRP), enc)
+
+	_, err = HexToBech32("zz", DefaultAddressHRP)
+	assert.Error(t, err)
+}
+
+func TestPublicKeyToAddress(t *testing.T) {
+	pub := make([]byte, 32)
+	for i := range pub {
+		pub[i] = byte(i)
+	}
+	addr := PublicKeyToAddress(pub)
+	assert.Equal(t, pub[32-AddressLength:], addr.Bytes())
+	assert.Equal(t, addr, PublicKeyToAddress(pub))
+}
@@ -3,6 +3,7 @@ package types
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/spacemeshos/ed25519"
 	"github.com/spacemeshos/go-spacemesh/common/util"
@@ -55,6 +56,12 @@ func (l LayerID) Uint64() uint64 {
 // Field returns a log field. Implements the LoggableField interface.
 func (l LayerID) Field() log.Field { return log.Uint64("layer_id", uint64(l)) }
 
+// LayerContext returns a copy of ctx that carries the layer and epoch of l as log fields, for the processing of layer l.
+// Loggers derived with log.Log.WithContext log them with every message.
+func LayerContext(ctx context.Context, l LayerID, layersPerEpoch uint16) context.Context {
+	return log.ContextWithFields(ctx, l, l.GetEpoch(layersPerEpoch))
+}
+
 // NodeID contains a miner's two public keys.
 type NodeID struct {
 	// Key is the miner's Edwards public key
@@ -106,6 +113,13 @@ type BlockHeader struct {
 	Timestamp        int64
 	BlockVotes       []BlockID
 	ViewEdges        []BlockID
+	// StateLayer is the latest layer whose state the producer verified, and StateRoot the state root at its end. Nodes
+	// compare them to their own state to detect divergence early.
+	StateLayer LayerID
+	StateRoot  Hash32
+	// CompactView is the compact encoding of the view, used instead of ViewEdges once the compact views upgrade is
+	// active, see View.
+	CompactView []LayerView
 }
 
 // Layer returns the block's LayerID.
@@ -139,9 +153,24 @@ type Block struct {
 	// keep id and minerID private to prevent them from being serialized
 	id        BlockID            // ⚠️ keep private
 	minerID   *signing.PublicKey // ⚠️ keep private
+	view      []BlockID          // ⚠️ keep private, the expanded CompactView
 	Signature []byte
 }
 
+// View returns the blocks in the block's view: ViewEdges, or the expanded CompactView if the view is compact. The
+// compact view of a block received from a peer is expanded by the syncer, and View returns nil until it is.
+func (b *Block) View() []BlockID {
+	if len(b.CompactView) == 0 {
+		return b.ViewEdges
+	}
+	return b.view
+}
+
+// SetView sets the expansion of the block's CompactView, see ExpandView.
+func (b *Block) SetView(view []BlockID) {
+	b.view = view
+}
+
 // Bytes returns the serialization of the MiniBlock.
 func (b *Block) Bytes() []byte {
 	blkBytes, err := InterfaceToBytes(b.MiniBlock)
@@ -157,7 +186,8 @@ func (b *Block) Fields() []log.LoggableField {
 		b.ID(),
 		b.LayerIndex,
 		b.MinerID(),
-		log.Int("view_edges", len(b.ViewEdges)),
+		log.Int("view_edges", len(b.View())),
+		log.Bool("compact_view", len(b.CompactView) > 0),
 		log.Int("vote_count", len(b.BlockVotes)),
 		log.Uint32("eligibility_counter", b.EligibilityProof.J),
 		log.Int("tx_count", len(b.TxIDs)),
@@ -174,17 +204,26 @@ func (b *Block) ID() BlockID {
 // Initialize calculates and sets the block's cached ID and MinerID. This should be called once all the other fields of
 // the block are set.
 func (b *Block) Initialize() {
+	if err := b.TryInitialize(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryInitialize is like Initialize, but returns an error if the block's signature is malformed instead of panicking.
+// Blocks received from peers should be initialized with TryInitialize.
+func (b *Block) TryInitialize() error {
 	blockBytes, err := InterfaceToBytes(b.MiniBlock)
 	if err != nil {
-		panic("failed to marshal block: " + err.Error())
+		return fmt.Errorf("failed to marshal block: %v", err)
 	}
-	b.id = BlockID(CalcHash32(blockBytes).ToHash20())
 
 	pubkey, err := ed25519.ExtractPublicKey(blockBytes, b.Signature)
 	if err != nil {
-		panic("failed to extract public key: " + err.Error())
+		return fmt.Errorf("failed to extract public key: %v", err)
 	}
+	b.id = BlockID(CalcHash32(blockBytes).ToHash20())
 	b.minerID = signing.NewPublicKey(pubkey)
+	return nil
 }
 
 // Hash32 returns a Hash32 whose first 20 bytes are the bytes of this BlockID, it is right-padded with zeros.
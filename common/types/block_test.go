@@ -36,3 +36,54 @@ func TestFields(t *testing.T) {
 	b.ATXIDs = []ATXID{atx1, atx2, atx3}
 	log.With().Info("got new block", b.Fields()...)
 }
+
+func TestBlock_TryInitialize(t *testing.T) {
+	b := NewExistingBlock(1, []byte("data"))
+	minerID := b.MinerID()
+	if err := b.TryInitialize(); err != nil || !b.MinerID().Equals(minerID) {
+		t.Fatalf("failed to initialize a signed block: %v", err)
+	}
+
+	b.Signature = b.Signature[:10]
+	if err := b.TryInitialize(); err == nil {
+		t.Fatal("initialized a block with a malformed signature")
+	}
+}
+
+func benchmarkBlock() *Block {
+	b := NewExistingBlock(1, []byte("data"))
+	for i := 0; i < 100; i++ {
+		b.TxIDs = append(b.TxIDs, TransactionID(genByte32()))
+		b.ATXIDs = append(b.ATXIDs, ATXID(genByte32()))
+		vote, edge := genByte32(), genByte32()
+		b.BlockVotes = append(b.BlockVotes, BlockID(BytesToHash(vote[:]).ToHash20()))
+		b.ViewEdges = append(b.ViewEdges, BlockID(BytesToHash(edge[:]).ToHash20()))
+	}
+	return b
+}
+
+func BenchmarkBlock_Serialize(b *testing.B) {
+	blk := benchmarkBlock()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := InterfaceToBytes(blk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBlock_Deserialize(b *testing.B) {
+	buf, err := InterfaceToBytes(benchmarkBlock())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var blk Block
+		if err := BytesToInterface(buf, &blk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package types
+
+// CertifyMessage is the signature of a certifying committee member on the hare output of a layer.
+type CertifyMessage struct {
+	Layer     LayerID
+	BlockIDs  []BlockID
+	Proof     []byte // the member's eligibility proof for the certify round
+	Signature []byte
+}
+
+// CertifiedBytes returns the bytes signed by a certifying committee member: the layer, the hash of the block set and
+// the eligibility proof.
+func CertifiedBytes(layer LayerID, blockIDs []BlockID, proof []byte) []byte {
+	setHash := CalcBlocksHash32(blockIDs, nil)
+	bts := append(layer.Bytes(), setHash.Bytes()...)
+	return append(bts, proof...)
+}
+
+// Bytes returns the bytes signed by the message's signature.
+func (m *CertifyMessage) Bytes() []byte {
+	return CertifiedBytes(m.Layer, m.BlockIDs, m.Proof)
+}
+
+// CertifySignature is a committee member's eligibility proof and signature in a Certificate.
+type CertifySignature struct {
+	Proof     []byte
+	Signature []byte
+}
+
+// Certificate proves that a sampled committee signed the hare output of a layer. All signatures are over the same
+// layer and block set, so the block set is only included once.
+type Certificate struct {
+	Layer      LayerID
+	BlockIDs   []BlockID
+	Signatures []CertifySignature
+}
@@ -0,0 +1,131 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The key types below are the ids of atxs, blocks, transactions, layers and nodes as they appear in database keys.
+// Each is a distinct struct, so one kind of id can't be converted to, or passed as, another, and the only way to get
+// one is through its constructor, which rejects ids that would make ambiguous keys. Key builders take these types
+// instead of raw bytes or strings.
+
+// ErrEmptyKey is returned when building a database key from a zero id.
+var ErrEmptyKey = errors.New("database key of an empty id")
+
+// NodeKeySeparator separates a node key from the rest of the database keys it's part of.
+const NodeKeySeparator = "_"
+
+// nodeKeyEscape escapes the node keys' separators and escape characters, so that a node key never contains
+// NodeKeySeparator. Node keys are hex encoded public keys, which have neither and are kept as they are.
+const nodeKeyEscape = "%"
+
+var (
+	nodeKeyEscaper   = strings.NewReplacer(nodeKeyEscape, nodeKeyEscape+"25", NodeKeySeparator, nodeKeyEscape+"5f")
+	nodeKeyUnescaper = strings.NewReplacer(nodeKeyEscape+"25", nodeKeyEscape, nodeKeyEscape+"5f", NodeKeySeparator)
+)
+
+// AtxKey is an atx id in a database key.
+type AtxKey struct {
+	id ATXID
+}
+
+// NewAtxKey returns the key of the atx with id, or ErrEmptyKey if id is the empty atx id.
+func NewAtxKey(id ATXID) (AtxKey, error) {
+	if id == *EmptyATXID {
+		return AtxKey{}, ErrEmptyKey
+	}
+	return AtxKey{id: id}, nil
+}
+
+// Bytes returns the bytes of the atx id.
+func (k AtxKey) Bytes() []byte {
+	return k.id.Bytes()
+}
+
+// BlockKey is a block id in a database key.
+type BlockKey struct {
+	id BlockID
+}
+
+// NewBlockKey returns the key of the block with id, or ErrEmptyKey if id is the zero block id.
+func NewBlockKey(id BlockID) (BlockKey, error) {
+	if id == (BlockID{}) {
+		return BlockKey{}, ErrEmptyKey
+	}
+	return BlockKey{id: id}, nil
+}
+
+// Bytes returns the bytes of the block id.
+func (k BlockKey) Bytes() []byte {
+	return k.id.Bytes()
+}
+
+// TxKey is a transaction id in a database key.
+type TxKey struct {
+	id TransactionID
+}
+
+// NewTxKey returns the key of the transaction with id, or ErrEmptyKey if id is the zero transaction id.
+func NewTxKey(id TransactionID) (TxKey, error) {
+	if id == EmptyTransactionID {
+		return TxKey{}, ErrEmptyKey
+	}
+	return TxKey{id: id}, nil
+}
+
+// Bytes returns the bytes of the transaction id.
+func (k TxKey) Bytes() []byte {
+	return k.id.Bytes()
+}
+
+// LayerKey is a layer id in a database key. Every layer id, genesis included, is a valid key.
+type LayerKey struct {
+	id LayerID
+}
+
+// NewLayerKey returns the key of layer.
+func NewLayerKey(layer LayerID) LayerKey {
+	return LayerKey{id: layer}
+}
+
+// Bytes returns the bytes of the layer id.
+func (k LayerKey) Bytes() []byte {
+	return k.id.Bytes()
+}
+
+// NodeKey is a node's key in a database key.
+type NodeKey struct {
+	key string
+}
+
+// NewNodeKey returns the key of the node with id. It returns an error if the node's key is empty. The key is escaped,
+// so that it doesn't contain NodeKeySeparator, which would make the keys of one node a prefix of another's.
+func NewNodeKey(id NodeID) (NodeKey, error) {
+	if id.Key == "" {
+		return NodeKey{}, ErrEmptyKey
+	}
+	return NodeKey{key: nodeKeyEscaper.Replace(id.Key)}, nil
+}
+
+// ParseNodeKey returns the node key s, as returned by String and read from a database key.
+func ParseNodeKey(s string) (NodeKey, error) {
+	if s == "" {
+		return NodeKey{}, ErrEmptyKey
+	}
+	if strings.Contains(s, NodeKeySeparator) {
+		return NodeKey{}, fmt.Errorf("node key %q contains the key separator %q", s, NodeKeySeparator)
+	}
+	return NodeKey{key: s}, nil
+}
+
+// String returns the node's key as it appears in database keys.
+func (k NodeKey) String() string {
+	return k.key
+}
+
+// Key returns the node's key, the Key of its NodeID.
+func (k NodeKey) Key() string {
+	return nodeKeyUnescaper.Replace(k.key)
+}
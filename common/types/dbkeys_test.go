@@ -0,0 +1,53 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeys(t *testing.T) {
+	r := require.New(t)
+
+	_, err := NewAtxKey(*EmptyATXID)
+	r.Equal(ErrEmptyKey, err)
+	atx, err := NewAtxKey(ATXID(HexToHash32("01")))
+	r.NoError(err)
+	r.Equal(ATXID(HexToHash32("01")).Bytes(), atx.Bytes())
+
+	_, err = NewBlockKey(BlockID{})
+	r.Equal(ErrEmptyKey, err)
+	block, err := NewBlockKey(BlockID{1})
+	r.NoError(err)
+	r.Equal(BlockID{1}.Bytes(), block.Bytes())
+
+	_, err = NewTxKey(EmptyTransactionID)
+	r.Equal(ErrEmptyKey, err)
+	tx, err := NewTxKey(TransactionID{1})
+	r.NoError(err)
+	r.Equal(TransactionID{1}.Bytes(), tx.Bytes())
+
+	r.Equal(LayerID(5).Bytes(), NewLayerKey(5).Bytes())
+
+	_, err = NewNodeKey(NodeID{})
+	r.Equal(ErrEmptyKey, err)
+	node, err := NewNodeKey(NodeID{Key: "ab"})
+	r.NoError(err)
+	r.Equal("ab", node.String())
+	r.Equal("ab", node.Key())
+
+	// keys with separators or escape characters are escaped, and parsed back to the node's key
+	for _, key := range []string{"a" + NodeKeySeparator + "b", "a%5fb", "%", NodeKeySeparator + "%25"} {
+		node, err := NewNodeKey(NodeID{Key: key})
+		r.NoError(err)
+		r.NotContains(node.String(), NodeKeySeparator)
+		r.Equal(key, node.Key())
+		parsed, err := ParseNodeKey(node.String())
+		r.NoError(err)
+		r.Equal(node, parsed)
+	}
+	_, err = ParseNodeKey("a" + NodeKeySeparator + "b")
+	r.Error(err)
+	_, err = ParseNodeKey("")
+	r.Equal(ErrEmptyKey, err)
+}
@@ -32,6 +32,13 @@ func (a *DoubleCache) GetOrInsert(key Hash12) bool {
 	return false
 }
 
+// Get returns whether or not the value is in the cache, without adding it.
+func (a *DoubleCache) Get(key Hash12) bool {
+	a.cacheMutex.RLock()
+	defer a.cacheMutex.RUnlock()
+	return a.get(key)
+}
+
 func (a *DoubleCache) get(key Hash12) bool {
 	_, ok := a.cacheA[key]
 	if ok {
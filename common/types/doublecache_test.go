@@ -17,6 +17,8 @@ func Test_doubleCache(t *testing.T) {
 	}
 
 	require.Len(t, c.cacheA, int(size))
+	require.True(t, c.Get(CalcMessageHash12([]byte("LOL0"), "prot")))
+	require.False(t, c.Get(CalcMessageHash12([]byte(fmt.Sprintf("LOL%v", size+1)), "prot")))
 
 	c.GetOrInsert(CalcMessageHash12([]byte(fmt.Sprintf("LOL%v", size+1)), "prot"))
 	require.Len(t, c.cacheA, int(size))
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"fmt"
+	"hash"
+
+	"github.com/spacemeshos/sha256-simd"
+)
+
+// HashVersion identifies the hash function of a protocol version.
+type HashVersion uint8
+
+const (
+	// HashSHA256 is SHA-256, the hash of object ids, NIPST challenges and the mesh since genesis.
+	HashSHA256 HashVersion = 1
+)
+
+// ProtocolHashVersion is the version of the hash that object ids, NIPST challenges and the other protocol hashes are
+// calculated with. Changing it changes the ids of every object, so it's changed with a protocol upgrade that all nodes
+// apply at the same layer.
+const ProtocolHashVersion = HashSHA256
+
+// Hasher is a hash function that the protocol can use. Its sums are Hash32Length bytes long.
+type Hasher interface {
+	Version() HashVersion
+	// New returns a hash.Hash to write a pre-image to in parts.
+	New() hash.Hash
+	// Sum returns the sum of data.
+	Sum(data []byte) Hash32
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Version() HashVersion { return HashSHA256 }
+
+func (sha256Hasher) New() hash.Hash { return sha256.New() }
+
+func (sha256Hasher) Sum(data []byte) Hash32 { return sha256.Sum256(data) }
+
+// hashers are the hash functions by version, they're only registered in init functions and read afterwards.
+var hashers = map[HashVersion]Hasher{HashSHA256: sha256Hasher{}}
+
+// protocolHasher is the hash of ProtocolHashVersion, that CalcHash32 and the other Calc functions use.
+var protocolHasher = mustGetHasher(ProtocolHashVersion)
+
+// RegisterHasher registers h as the hash function of its version, so that a protocol upgrade can switch to it. It must
+// be called from an init function, and panics if the version is already registered or h's sums have another length.
+func RegisterHasher(h Hasher) {
+	if _, ok := hashers[h.Version()]; ok {
+		panic(fmt.Sprintf("hash version %v is already registered", h.Version()))
+	}
+	if size := h.New().Size(); size != Hash32Length {
+		panic(fmt.Sprintf("hash version %v has %v byte sums, expected %v", h.Version(), size, Hash32Length))
+	}
+	hashers[h.Version()] = h
+}
+
+// GetHasher returns the hash function of version v.
+func GetHasher(v HashVersion) (Hasher, error) {
+	h, ok := hashers[v]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash version %v", v)
+	}
+	return h, nil
+}
+
+func mustGetHasher(v HashVersion) Hasher {
+	h, err := GetHasher(v)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// ProtocolHasher returns the hash function of ProtocolHashVersion.
+func ProtocolHasher() Hasher {
+	return protocolHasher
+}
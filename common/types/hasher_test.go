@@ -0,0 +1,42 @@
+package types
+
+import (
+	"crypto/sha256"
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type shortHasher struct{}
+
+func (shortHasher) Version() HashVersion { return 200 }
+
+func (shortHasher) New() hash.Hash { return sha256.New224() }
+
+func (shortHasher) Sum(data []byte) (h Hash32) {
+	sum := sha256.Sum224(data)
+	copy(h[:], sum[:])
+	return
+}
+
+func TestProtocolHasher(t *testing.T) {
+	r := require.New(t)
+	data := []byte("spacemesh")
+	r.Equal(HashSHA256, ProtocolHasher().Version())
+	r.Equal(Hash32(sha256.Sum256(data)), CalcHash32(data))
+
+	h := ProtocolHasher().New()
+	h.Write(data)
+	r.Equal(CalcHash32(data).Bytes(), h.Sum(nil))
+}
+
+func TestRegisterHasher(t *testing.T) {
+	r := require.New(t)
+	_, err := GetHasher(200)
+	r.EqualError(err, "unknown hash version 200")
+	r.Panics(func() { RegisterHasher(sha256Hasher{}) })
+	r.Panics(func() { RegisterHasher(shortHasher{}) })
+	_, err = GetHasher(200)
+	r.Error(err)
+}
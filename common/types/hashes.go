@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/log"
-	"github.com/spacemeshos/sha256-simd"
 	"math/big"
 	"math/rand"
 	"reflect"
@@ -17,13 +16,13 @@ const (
 	hash12Length = 12
 )
 
-// Hash12 represents the first 12 bytes of sha256, mostly used for internal caches
+// Hash12 represents the first 12 bytes of the protocol hash, mostly used for internal caches
 type Hash12 [hash12Length]byte
 
-// Hash32 represents the 32-byte sha256 hash of arbitrary data.
+// Hash32 represents the 32-byte protocol hash of arbitrary data.
 type Hash32 [Hash32Length]byte
 
-// Hash20 represents the 20-byte sha256 hash of arbitrary data.
+// Hash20 represents the 20-byte prefix of the protocol hash of arbitrary data.
 type Hash20 [hash20Length]byte
 
 // Field returns a log field. Implements the LoggableField interface.
@@ -90,21 +89,21 @@ func (h Hash20) ToHash32() (h32 Hash32) {
 // Field returns a log field. Implements the LoggableField interface.
 func (h Hash20) Field(name string) log.Field { return log.String(name, util.Bytes2Hex(h[:])) }
 
-// CalcHash12 returns the 12-byte prefix of the sha256 sum of the given byte slice.
+// CalcHash12 returns the 12-byte prefix of the protocol hash sum of the given byte slice.
 func CalcHash12(data []byte) (h Hash12) {
-	h32 := sha256.Sum256(data)
+	h32 := protocolHasher.Sum(data)
 	copy(h[:], h32[:])
 	return
 }
 
-// CalcBlocksHash12 returns the 12-byte sha256 sum of the block IDs, sorted in lexicographic order.
+// CalcBlocksHash12 returns the 12-byte protocol hash sum of the block IDs, sorted in lexicographic order.
 func CalcBlocksHash12(view []BlockID) (h Hash12) {
 	h32 := CalcBlocksHash32(view, nil)
 	copy(h[:], h32[:])
 	return
 }
 
-// CalcBlocksHash32 returns the 32-byte sha256 sum of the block IDs, sorted in lexicographic order. The pre-image is
+// CalcBlocksHash32 returns the 32-byte protocol hash sum of the block IDs, sorted in lexicographic order. The pre-image is
 // prefixed with additionalBytes.
 func CalcBlocksHash32(view []BlockID, additionalBytes []byte) Hash32 {
 	sortedView := make([]BlockID, len(view))
@@ -113,10 +112,10 @@ func CalcBlocksHash32(view []BlockID, additionalBytes []byte) Hash32 {
 	return CalcBlockHash32Presorted(sortedView, additionalBytes)
 }
 
-// CalcBlockHash32Presorted returns the 32-byte sha256 sum of the block IDs, in the order given. The pre-image is
-// prefixed with additionalBytes.
+// CalcBlockHash32Presorted returns the 32-byte protocol hash sum of the block IDs, in the order given. The pre-image
+// is prefixed with additionalBytes.
 func CalcBlockHash32Presorted(sortedView []BlockID, additionalBytes []byte) Hash32 {
-	hash := sha256.New()
+	hash := protocolHasher.New()
 	hash.Write(additionalBytes)
 	for _, id := range sortedView {
 		hash.Write(id.Bytes()) // this never returns an error: https://golang.org/pkg/hash/#Hash
@@ -126,19 +125,19 @@ func CalcBlockHash32Presorted(sortedView []BlockID, additionalBytes []byte) Hash
 	return res
 }
 
-// CalcMessageHash12 returns the 12-byte sha256 sum of the given msg suffixed with protocol.
+// CalcMessageHash12 returns the 12-byte protocol hash sum of the given msg suffixed with protocol.
 func CalcMessageHash12(msg []byte, protocol string) Hash12 {
 	return CalcHash12(append(msg, protocol...))
 }
 
 var hashT = reflect.TypeOf(Hash32{})
 
-// CalcHash32 returns the 32-byte sha256 sum of the given data.
+// CalcHash32 returns the 32-byte protocol hash sum of the given data.
 func CalcHash32(data []byte) Hash32 {
-	return sha256.Sum256(data)
+	return protocolHasher.Sum(data)
 }
 
-// CalcATXHash32 returns the 32-byte sha256 sum of serialization of the given ATX.
+// CalcATXHash32 returns the 32-byte protocol hash sum of serialization of the given ATX.
 func CalcATXHash32(atx *ActivationTx) Hash32 {
 	bytes, err := InterfaceToBytes(&atx.ActivationTxHeader)
 	if err != nil {
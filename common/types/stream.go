@@ -0,0 +1,61 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	xdr "github.com/nullstyle/go-xdr/xdr3"
+)
+
+// ErrSizeLimit is returned by ArrayWriter.Append when the serialized array would grow past its size limit.
+var ErrSizeLimit = errors.New("serialized size limit reached")
+
+// ArrayWriter serializes an array one element at a time, in the format of InterfaceToBytes. Large objects can be
+// loaded, appended and released one by one, instead of being held in memory together with their serialization.
+type ArrayWriter struct {
+	buf   bytes.Buffer
+	n     uint32
+	limit int
+}
+
+// NewArrayWriter returns an ArrayWriter that serializes at most limit bytes, or any number of bytes if limit is 0.
+func NewArrayWriter(limit int) *ArrayWriter {
+	w := &ArrayWriter{limit: limit}
+	w.buf.Write(make([]byte, 4)) // element count, set by Bytes
+	return w
+}
+
+// Append serializes i as the next element of the array. If the array would exceed the size limit, i isn't appended
+// and ErrSizeLimit is returned.
+// ⚠️ Pass the element by reference
+func (w *ArrayWriter) Append(i interface{}) error {
+	size := w.buf.Len()
+	if _, err := xdr.Marshal(&w.buf, i); err != nil {
+		w.buf.Truncate(size)
+		return err
+	}
+	if w.limit > 0 && w.buf.Len() > w.limit {
+		w.buf.Truncate(size)
+		return ErrSizeLimit
+	}
+	w.n++
+	return nil
+}
+
+// Count returns the number of elements appended.
+func (w *ArrayWriter) Count() int {
+	return int(w.n)
+}
+
+// Len returns the size of the serialized array in bytes.
+func (w *ArrayWriter) Len() int {
+	return w.buf.Len()
+}
+
+// Bytes returns the serialized array, it can be deserialized by BytesToInterface into a slice of the elements' type.
+func (w *ArrayWriter) Bytes() []byte {
+	b := w.buf.Bytes()
+	binary.BigEndian.PutUint32(b, w.n)
+	return b
+}
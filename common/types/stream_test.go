@@ -0,0 +1,40 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrayWriter(t *testing.T) {
+	r := require.New(t)
+	blocks := []Block{*NewExistingBlock(1, []byte("a")), *NewExistingBlock(2, []byte("b"))}
+
+	w := NewArrayWriter(0)
+	for i := range blocks {
+		r.NoError(w.Append(&blocks[i]))
+	}
+	expected, err := InterfaceToBytes(blocks)
+	r.NoError(err)
+	r.Equal(expected, w.Bytes())
+	r.Equal(len(expected), w.Len())
+
+	var decoded []Block
+	r.NoError(BytesToInterface(w.Bytes(), &decoded))
+	r.Len(decoded, 2)
+	decoded[1].Initialize()
+	r.Equal(blocks[1].ID(), decoded[1].ID())
+}
+
+func TestArrayWriter_Limit(t *testing.T) {
+	r := require.New(t)
+	first := NewExistingBlock(1, []byte("a"))
+	single, err := InterfaceToBytes([]Block{*first})
+	r.NoError(err)
+
+	w := NewArrayWriter(len(single))
+	r.NoError(w.Append(first))
+	r.Equal(ErrSizeLimit, w.Append(NewExistingBlock(2, []byte("b"))))
+	r.Equal(1, w.Count())
+	r.Equal(single, w.Bytes())
+}
@@ -79,8 +79,8 @@ func (t *Transaction) CalcAndSetOrigin() error {
 		return fmt.Errorf("failed to extract transaction pubkey: %v", err)
 	}
 
-	t.origin = &Address{}
-	t.origin.SetBytes(pubKey)
+	origin := PublicKeyToAddress(pubKey)
+	t.origin = &origin
 	return nil
 }
 
@@ -116,6 +116,19 @@ func (t *Transaction) String() string {
 		t.ID().ShortString(), t.Origin().Short(), t.Recipient.Short(), t.Amount, t.AccountNonce, t.GasLimit, t.Fee)
 }
 
+// Fields returns an array of LoggableFields for logging. The origin must be set.
+func (t *Transaction) Fields() []log.LoggableField {
+	return []log.LoggableField{
+		t.ID(),
+		log.String("origin", t.Origin().Short()),
+		log.String("recipient", t.Recipient.Short()),
+		log.Uint64("amount", t.Amount),
+		log.Uint64("nonce", t.AccountNonce),
+		log.Uint64("gas_limit", t.GasLimit),
+		log.Uint64("fee", t.Fee),
+	}
+}
+
 // InnerTransaction includes all of a transaction's fields, except the signature (origin and id aren't stored).
 type InnerTransaction struct {
 	AccountNonce uint64
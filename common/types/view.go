@@ -0,0 +1,163 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrViewHashMismatch is returned when expanding a compact view with blocks of a layer that don't hash to the layer
+// hash it references.
+var ErrViewHashMismatch = errors.New("layer blocks don't match the hash in the compact view")
+
+// LayerView is the part of a compact view in one layer: the blocks of Layer whose ids hash to Hash, as calculated by
+// CalcBlocksHash32, except Excluded, plus Included. A zero Hash references no blocks, so the layer's part of the view
+// is Included alone. Views usually hold all the blocks of the layers they reference, so a layer hash replaces a list
+// of ids that grows with the size of the network.
+type LayerView struct {
+	Layer    LayerID
+	Hash     Hash32
+	Excluded []BlockID // sorted
+	Included []BlockID // sorted
+}
+
+// hashEncodingSize is the size of the layer hash, in block ids, for deciding between the encodings of a layer's view.
+const hashEncodingSize = 2
+
+// EncodeView returns the compact encoding of view, with the layer of every block looked up by layerOf and the blocks
+// of every layer by layerBlocks. The part of the view in each layer is encoded as the layer's hash with the blocks of
+// the layer that aren't in the view excluded, unless listing the view's blocks of that layer is shorter.
+func EncodeView(view []BlockID, layerOf func(BlockID) (LayerID, error), layerBlocks func(LayerID) ([]BlockID, error)) ([]LayerView, error) {
+	byLayer := make(map[LayerID]map[BlockID]struct{})
+	for _, id := range view {
+		layer, err := layerOf(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get layer of block %v: %v", id, err)
+		}
+		if byLayer[layer] == nil {
+			byLayer[layer] = make(map[BlockID]struct{})
+		}
+		byLayer[layer][id] = struct{}{}
+	}
+
+	cv := make([]LayerView, 0, len(byLayer))
+	for layer, inView := range byLayer {
+		blocks, err := layerBlocks(layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get blocks of layer %v: %v", layer, err)
+		}
+		lv := LayerView{Layer: layer}
+		inLayer := make(map[BlockID]struct{}, len(blocks))
+		for _, id := range blocks {
+			inLayer[id] = struct{}{}
+			if _, ok := inView[id]; !ok {
+				lv.Excluded = append(lv.Excluded, id)
+			}
+		}
+		for id := range inView {
+			if _, ok := inLayer[id]; !ok {
+				lv.Included = append(lv.Included, id)
+			}
+		}
+		if hashEncodingSize+len(lv.Excluded) < len(inView) {
+			lv.Hash = CalcBlocksHash32(blocks, nil)
+		} else {
+			lv.Excluded, lv.Included = nil, lv.Included[:0]
+			for id := range inView {
+				lv.Included = append(lv.Included, id)
+			}
+		}
+		SortBlockIDs(lv.Excluded)
+		SortBlockIDs(lv.Included)
+		cv = append(cv, lv)
+	}
+	sort.Slice(cv, func(i, j int) bool { return cv[i].Layer < cv[j].Layer })
+	return cv, nil
+}
+
+// ExpandView returns the blocks of the compact view cv, in the order of its layers. baseSet returns the blocks that
+// the hash of the i-th layer view lv references, and they are checked against the hash.
+func ExpandView(cv []LayerView, baseSet func(i int, lv LayerView) ([]BlockID, error)) ([]BlockID, error) {
+	var view []BlockID
+	for i, lv := range cv {
+		var blocks []BlockID
+		if lv.Hash != (Hash32{}) {
+			base, err := baseSet(i, lv)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get blocks of layer %v: %v", lv.Layer, err)
+			}
+			if CalcBlocksHash32(base, nil) != lv.Hash {
+				return nil, fmt.Errorf("%v: layer %v", ErrViewHashMismatch, lv.Layer)
+			}
+			excluded := make(map[BlockID]struct{}, len(lv.Excluded))
+			for _, id := range lv.Excluded {
+				excluded[id] = struct{}{}
+			}
+			for _, id := range SortBlockIDs(append([]BlockID(nil), base...)) {
+				if _, ok := excluded[id]; !ok {
+					blocks = append(blocks, id)
+				}
+			}
+		}
+		view = append(view, blocks...)
+		view = append(view, lv.Included...)
+	}
+	return view, nil
+}
+
+// ViewBaseSets returns the blocks that the hash of each layer view of cv references, given the expansion of cv, view,
+// and the layers of its blocks. Peers that can't expand a compact view, because their blocks of a layer don't match
+// its hash, expand it with the base sets of a peer that did.
+func ViewBaseSets(cv []LayerView, view []BlockID, layerOf func(BlockID) (LayerID, error)) ([][]BlockID, error) {
+	byLayer := make(map[LayerID][]BlockID)
+	for _, id := range view {
+		layer, err := layerOf(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get layer of block %v: %v", id, err)
+		}
+		byLayer[layer] = append(byLayer[layer], id)
+	}
+	bases := make([][]BlockID, len(cv))
+	for i, lv := range cv {
+		if lv.Hash == (Hash32{}) {
+			continue
+		}
+		included := make(map[BlockID]struct{}, len(lv.Included))
+		for _, id := range lv.Included {
+			included[id] = struct{}{}
+		}
+		base := append([]BlockID(nil), lv.Excluded...)
+		for _, id := range byLayer[lv.Layer] {
+			if _, ok := included[id]; !ok {
+				base = append(base, id)
+			}
+		}
+		bases[i] = SortBlockIDs(base)
+	}
+	return bases, nil
+}
+
+// ValidateCompactView returns an error unless cv is a canonical compact view of a block of layer blockLayer: its layer
+// views are of distinct layers before blockLayer in ascending order, their exception lists are sorted without
+// duplicates, and layer views without a hash exclude no blocks and include some.
+func ValidateCompactView(cv []LayerView, blockLayer LayerID) error {
+	for i, lv := range cv {
+		if lv.Layer >= blockLayer {
+			return fmt.Errorf("view of layer %v isn't before the block's layer %v", lv.Layer, blockLayer)
+		}
+		if i > 0 && lv.Layer <= cv[i-1].Layer {
+			return fmt.Errorf("view of layer %v isn't after the view of layer %v", lv.Layer, cv[i-1].Layer)
+		}
+		if lv.Hash == (Hash32{}) && (len(lv.Excluded) > 0 || len(lv.Included) == 0) {
+			return fmt.Errorf("view of layer %v without a hash must only include blocks", lv.Layer)
+		}
+		for _, ids := range [][]BlockID{lv.Excluded, lv.Included} {
+			for j := 1; j < len(ids); j++ {
+				if !ids[j-1].Compare(ids[j]) {
+					return fmt.Errorf("block ids of the view of layer %v aren't sorted or unique", lv.Layer)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func viewTestBlocks(n int) []BlockID {
+	ids := make([]BlockID, n)
+	for i := range ids {
+		ids[i] = BlockID(CalcHash32([]byte{byte(i)}).ToHash20())
+	}
+	return ids
+}
+
+func TestCompactView(t *testing.T) {
+	r := require.New(t)
+	ids := viewTestBlocks(12)
+	layers := map[LayerID][]BlockID{1: ids[:10], 2: ids[10:]}
+	layerOf := func(id BlockID) (LayerID, error) {
+		for layer, blocks := range layers {
+			for _, b := range blocks {
+				if b == id {
+					return layer, nil
+				}
+			}
+		}
+		return 0, errors.New("unknown block")
+	}
+	layerBlocks := func(layer LayerID) ([]BlockID, error) { return layers[layer], nil }
+
+	// all of layer 1 but one block, and one block of layer 2
+	view := append(append([]BlockID(nil), ids[1:10]...), ids[11])
+	cv, err := EncodeView(view, layerOf, layerBlocks)
+	r.NoError(err)
+	r.Len(cv, 2)
+	r.Equal(LayerView{Layer: 1, Hash: CalcBlocksHash32(ids[:10], nil), Excluded: []BlockID{ids[0]}}, cv[0])
+	r.Equal(LayerView{Layer: 2, Included: []BlockID{ids[11]}}, cv[1])
+	r.NoError(ValidateCompactView(cv, 3))
+
+	local := func(_ int, lv LayerView) ([]BlockID, error) { return layerBlocks(lv.Layer) }
+	expanded, err := ExpandView(cv, local)
+	r.NoError(err)
+	r.ElementsMatch(view, expanded)
+
+	// a peer that got a late block of layer 1 can't expand the view with its own blocks
+	late := BlockID(CalcHash32([]byte("late")).ToHash20())
+	layers[1] = append(layers[1][:10:10], late)
+	_, err = ExpandView(cv, local)
+	r.Error(err)
+	// but it can with the base sets of a peer that expanded it
+	bases, err := ViewBaseSets(cv, expanded, layerOf)
+	r.NoError(err)
+	r.Nil(bases[1])
+	expanded, err = ExpandView(cv, func(i int, _ LayerView) ([]BlockID, error) { return bases[i], nil })
+	r.NoError(err)
+	r.ElementsMatch(view, expanded)
+}
+
+func TestValidateCompactView(t *testing.T) {
+	ids := SortBlockIDs(viewTestBlocks(2))
+	hash := CalcBlocksHash32(ids, nil)
+	for _, tc := range []struct {
+		name string
+		cv   []LayerView
+	}{
+		{"layer of block", []LayerView{{Layer: 3, Hash: hash}}},
+		{"unordered layers", []LayerView{{Layer: 2, Hash: hash}, {Layer: 1, Hash: hash}}},
+		{"duplicate layers", []LayerView{{Layer: 1, Hash: hash}, {Layer: 1, Hash: hash}}},
+		{"no hash with exclusions", []LayerView{{Layer: 1, Excluded: ids[:1], Included: ids[1:]}}},
+		{"no hash nor blocks", []LayerView{{Layer: 1}}},
+		{"unsorted", []LayerView{{Layer: 1, Hash: hash, Excluded: []BlockID{ids[1], ids[0]}}}},
+		{"duplicate blocks", []LayerView{{Layer: 1, Included: []BlockID{ids[0], ids[0]}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Error(t, ValidateCompactView(tc.cv, 3))
+		})
+	}
+	require.NoError(t, ValidateCompactView([]LayerView{{Layer: 1, Hash: hash, Excluded: ids}, {Layer: 2, Included: ids}}, 3))
+}
@@ -0,0 +1,17 @@
+package util
+
+import "context"
+
+// StopContext returns a context that's canceled when stop is closed, or when the returned cancel function is called.
+// It lets components that stop by closing a channel cancel the context-aware calls they make.
+func StopContext(stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
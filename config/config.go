@@ -3,11 +3,14 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spacemeshos/go-spacemesh/activation"
 	apiConfig "github.com/spacemeshos/go-spacemesh/api/config"
+	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/filesystem"
 	hareConfig "github.com/spacemeshos/go-spacemesh/hare/config"
 	eligConfig "github.com/spacemeshos/go-spacemesh/hare/eligibility/config"
@@ -26,8 +29,26 @@ const (
 	Genesis = mesh.Genesis
 	// NewBlockProtocol indicates the protocol name for new blocks arriving.
 	NewBlockProtocol = "newBlock"
+	// VRFEligibilityOracle selects the VRF based eligibility oracles, which derive eligibility from ATXs.
+	VRFEligibilityOracle = "vrf"
+	// PowEligibilityOracle selects the proof-of-work eligibility oracle, for local dev networks without PoET and PoST.
+	PowEligibilityOracle = "pow"
+
+	// P2PRole runs the gossip listeners that every node needs to take part in the network.
+	P2PRole = "p2p"
+	// SyncRole keeps the node's mesh in sync with the network.
+	SyncRole = "sync"
+	// ConsensusRole runs the hare protocol.
+	ConsensusRole = "consensus"
+	// MiningRole builds blocks and ATXs.
+	MiningRole = "mining"
+	// APIRole serves the gRPC and JSON APIs.
+	APIRole = "api"
 )
 
+// AllRoles are the roles a node can run, a full node runs all of them.
+var AllRoles = []string{P2PRole, SyncRole, ConsensusRole, MiningRole, APIRole}
+
 var (
 	defaultHomeDir  = filesystem.GetUserHomeDirectory()
 	defaultDataDir  = filepath.Join(defaultHomeDir, defaultDataDirName, "/")
@@ -53,10 +74,33 @@ func (cfg *Config) DataDir() string {
 	return filepath.Join(filesystem.GetCanonicalPath(cfg.DataDirParent), fmt.Sprint(cfg.P2P.NetworkID))
 }
 
+// StoreDir returns the absolute path of the directory that holds the store with the given name, e.g. "state" or
+// "mesh/blocks". Stores are kept in dataDir unless the first element of their name is mapped to another directory in
+// StoreDirs, in which case they are kept in a subfolder of that directory named after the network ID.
+func (cfg *Config) StoreDir(dataDir, name string) string {
+	dir, ok := cfg.StoreDirs[strings.SplitN(name, "/", 2)[0]]
+	if !ok {
+		return dataDir
+	}
+	return filepath.Join(filesystem.GetCanonicalPath(dir), fmt.Sprint(cfg.P2P.NetworkID))
+}
+
+// Validate checks the values that can't be represented by the types the node uses them as.
+func (cfg *Config) Validate() error {
+	if cfg.PowDifficulty < 0 || cfg.PowDifficulty > math.MaxUint8 {
+		return fmt.Errorf("pow difficulty %d is out of range, it must be between 0 and %d", cfg.PowDifficulty, math.MaxUint8)
+	}
+	return nil
+}
+
 // BaseConfig defines the default configuration options for spacemesh app
 type BaseConfig struct {
 	DataDirParent string `mapstructure:"data-folder"`
 
+	StoreDirs map[string]string `mapstructure:"store-dirs"` // directories to keep stores in instead of the data folder, by store name
+
+	DiskGrowthMB int `mapstructure:"disk-growth-mb"` // free disk space the stores are expected to grow into, checked on startup
+
 	ConfigFile string `mapstructure:"config"`
 
 	TestMode bool `mapstructure:"test-mode"`
@@ -70,11 +114,19 @@ type BaseConfig struct {
 	GenesisTime      string `mapstructure:"genesis-time"`
 	LayerDurationSec int    `mapstructure:"layer-duration-sec"`
 	LayerAvgSize     int    `mapstructure:"layer-average-size"`
+	TargetLayerSize  int    `mapstructure:"target-layer-size"` // blocks per layer that eligibility targets, 0 means LayerAvgSize
 	LayersPerEpoch   int    `mapstructure:"layers-per-epoch"`
 	Hdist            int    `mapstructure:"hdist"`
 
 	PoETServer string `mapstructure:"poet-server"`
 
+	PoetRoundMarginSec int `mapstructure:"poet-round-margin-sec"` // margin kept before PoET round and ATX deadlines
+
+	TickSize uint64 `mapstructure:"tick-size"` // number of PoET leaves in a single tick, the ATX weight unit
+
+	PosAtxPolicy string `mapstructure:"pos-atx-policy"` // selection of the ATX that new ATXs are positioned on
+	PosAtxTopN   int    `mapstructure:"pos-atx-top-n"`  // number of highest ATXs that the random-top policy picks from
+
 	MemProfile string `mapstructure:"mem-profile"`
 
 	CPUProfile string `mapstructure:"cpu-profile"`
@@ -85,21 +137,57 @@ type BaseConfig struct {
 
 	CoinbaseAccount string `mapstructure:"coinbase"`
 
+	AddressHRP string `mapstructure:"address-hrp"` // the human readable part of this network's bech32 addresses
+
 	GenesisActiveSet int `mapstructure:"genesis-active-size"` // the active set size for genesis
 
+	ActiveSetGraceLayers int `mapstructure:"active-set-grace-layers"` // layers of an epoch whose blocks count towards its active set after the first-seen-active-set upgrade
+
+	BeaconProposals      int `mapstructure:"beacon-proposals"`       // expected number of tortoise beacon proposals in an epoch
+	BeaconProposalLayers int `mapstructure:"beacon-proposal-layers"` // layers at the start of an epoch that tortoise beacon proposals are accepted in
+	BeaconVotingLayers   int `mapstructure:"beacon-voting-layers"`   // layers after the proposal phase that tortoise beacon votes are accepted in
+
+	Upgrades map[string]int `mapstructure:"upgrades"` // the epochs protocol upgrades activate at, by upgrade name
+
 	SyncRequestTimeout int `mapstructure:"sync-request-timeout"` // ms the timeout for direct request in the sync
 
 	SyncInterval int `mapstructure:"sync-interval"` // sync interval in seconds
 
 	SyncValidationDelta int `mapstructure:"sync-validation-delta"` // sync interval in seconds
 
+	SyncRepairInterval int `mapstructure:"sync-repair-interval"` // data availability repair interval in seconds, 0 disables it
+
+	FinalityDepth int `mapstructure:"finality-depth"` // layers after which applied layers are final and aren't rolled back, 0 disables finality
+
 	PublishEventsURL string `mapstructure:"events-url"`
 
 	StartMining bool `mapstructure:"start-mining"`
 
 	AtxsPerBlock int `mapstructure:"atxs-per-block"`
 
+	MinGasPrice uint64 `mapstructure:"min-gas-price"` // the minimum fee per unit of gas limit of transactions accepted to the mempool and relayed
+
 	BlockCacheSize int `mapstructure:"block-cache-size"`
+
+	CacheMB int `mapstructure:"cache-mb"` // memory budget of the caches divided between them, 0 keeps each cache's own size
+
+	EligibilityOracle string `mapstructure:"eligibility-oracle"` // "vrf" for PoST based eligibility, "pow" for local dev networks
+	PowDifficulty     int    `mapstructure:"pow-difficulty"`     // leading zero bits required by the pow eligibility oracle
+
+	Roles []string `mapstructure:"roles"` // the subsystems this node runs, see AllRoles
+
+	ReplicationListen string `mapstructure:"replication-listen"` // address to stream applied layers to read replicas on
+	ReplicationLeader string `mapstructure:"replication-leader"` // address of the node a read replica replicates layers from
+
+	StateSyncRoot  string `mapstructure:"state-sync-root"`  // hex state root of a checkpoint layer to fetch from peers instead of applying all layers
+	StateSyncLayer int    `mapstructure:"state-sync-layer"` // the checkpoint layer that state-sync-root is the state of
+
+	CertifyCommitteeSize int `mapstructure:"certify-committee-size"` // expected size of the committee certifying hare outputs, 0 disables certification
+	CertifyThreshold     int `mapstructure:"certify-threshold"`      // committee signatures required to certify a hare output, 0 for a majority of the committee
+
+	UpdateCheckInterval int    `mapstructure:"update-check-interval"` // seconds between checks of the node's version against the network's, 0 disables them
+	ReleaseFeedURL      string `mapstructure:"release-feed-url"`      // url of a signed release feed the node's version is also checked against
+	ReleaseFeedKey      string `mapstructure:"release-feed-key"`      // hex ed25519 public key that signs the release feed
 }
 
 // LoggerConfig holds the logging level for each module.
@@ -125,9 +213,11 @@ type LoggerConfig struct {
 	BlockBuilderLoggerLevel   string `mapstructure:"block-builder"`
 	BlockListenerLoggerLevel  string `mapstructure:"block-listener"`
 	PoetListenerLoggerLevel   string `mapstructure:"poet"`
+	MalfeasanceLoggerLevel    string `mapstructure:"malfeasance"`
 	NipstBuilderLoggerLevel   string `mapstructure:"nipst"`
 	AtxBuilderLoggerLevel     string `mapstructure:"atx-builder"`
 	HareBeaconLoggerLevel     string `mapstructure:"hare-beacon"`
+	ReplicationLoggerLevel    string `mapstructure:"replication"`
 }
 
 // DefaultConfig returns the default configuration for a spacemesh node
@@ -147,24 +237,38 @@ func DefaultConfig() Config {
 // DefaultBaseConfig returns a default configuration for spacemesh
 func defaultBaseConfig() BaseConfig {
 	return BaseConfig{
-		DataDirParent:       defaultDataDir,
-		ConfigFile:          defaultConfigFileName,
-		TestMode:            defaultTestMode,
-		CollectMetrics:      false,
-		MetricsPort:         1010,
-		OracleServer:        "http://localhost:3030",
-		OracleServerWorldID: 0,
-		GenesisTime:         time.Now().Format(time.RFC3339),
-		LayerDurationSec:    30,
-		LayersPerEpoch:      3,
-		PoETServer:          "127.0.0.1",
-		Hdist:               5,
-		GenesisActiveSet:    5,
-		BlockCacheSize:      20,
-		SyncRequestTimeout:  2000,
-		SyncInterval:        10,
-		SyncValidationDelta: 30,
-		AtxsPerBlock:        100,
+		DataDirParent:        defaultDataDir,
+		ConfigFile:           defaultConfigFileName,
+		DiskGrowthMB:         1024,
+		TestMode:             defaultTestMode,
+		CollectMetrics:       false,
+		MetricsPort:          1010,
+		OracleServer:         "http://localhost:3030",
+		OracleServerWorldID:  0,
+		GenesisTime:          time.Now().Format(time.RFC3339),
+		LayerDurationSec:     30,
+		LayersPerEpoch:       3,
+		PoETServer:           "127.0.0.1",
+		PoetRoundMarginSec:   60,
+		TickSize:             1,
+		PosAtxPolicy:         activation.HighestPosAtxPolicy,
+		PosAtxTopN:           activation.DefaultPosAtxTopN,
+		Hdist:                5,
+		GenesisActiveSet:     5,
+		ActiveSetGraceLayers: 1,
+		BeaconProposals:      10,
+		BeaconProposalLayers: 1,
+		BeaconVotingLayers:   1,
+		BlockCacheSize:       20,
+		SyncRequestTimeout:   2000,
+		SyncInterval:         10,
+		SyncValidationDelta:  30,
+		SyncRepairInterval:   1800,
+		AtxsPerBlock:         100,
+		AddressHRP:           types.DefaultAddressHRP,
+		EligibilityOracle:    VRFEligibilityOracle,
+		PowDifficulty:        16,
+		Roles:                append([]string(nil), AllRoles...),
 	}
 }
 
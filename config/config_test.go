@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -28,3 +29,81 @@ func TestConfig_DataDir(t *testing.T) {
 	config.DataDirParent = "~" + sep + "space-a-mesh" + sep // trailing slash should be ignored
 	assert.Equal(t, expectedDataDir, config.DataDir())
 }
+
+func TestConfig_StoreDir(t *testing.T) {
+	config := DefaultConfig()
+	config.P2P.NetworkID = 88
+	config.StoreDirs = map[string]string{"mesh": filepath.Join("disk2", "spacemesh")}
+	expectedMeshDir := filepath.Join(filesystem.GetCanonicalPath(filepath.Join("disk2", "spacemesh")), "88")
+	assert.Equal(t, expectedMeshDir, config.StoreDir("data", "mesh"))
+	assert.Equal(t, expectedMeshDir, config.StoreDir("data", "mesh/blocks"))
+	assert.Equal(t, "data", config.StoreDir("data", "state"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	assert.NoError(t, config.Validate())
+	for _, difficulty := range []int{0, 255} {
+		config.PowDifficulty = difficulty
+		assert.NoError(t, config.Validate())
+	}
+	for _, difficulty := range []int{-1, 256} {
+		config.PowDifficulty = difficulty
+		assert.Error(t, config.Validate())
+	}
+}
+
+func TestProtocolConfig_Hash(t *testing.T) {
+	config := DefaultConfig()
+	other := DefaultConfig()
+	assert.Equal(t, config.Protocol().Hash(), other.Protocol().Hash())
+
+	other.DataDirParent = "other" // not a protocol param
+	assert.Equal(t, config.Protocol().Hash(), other.Protocol().Hash())
+
+	other.HARE.N++
+	assert.NotEqual(t, config.Protocol().Hash(), other.Protocol().Hash())
+
+	upgraded := DefaultConfig()
+	upgraded.Upgrades = map[string]int{"atx-coinbase-required": 10}
+	assert.NotEqual(t, config.Protocol().Hash(), upgraded.Protocol().Hash())
+	rescheduled := DefaultConfig()
+	rescheduled.Upgrades = map[string]int{"atx-coinbase-required": 11}
+	assert.NotEqual(t, upgraded.Protocol().Hash(), rescheduled.Protocol().Hash())
+}
+
+func TestEnvVarName(t *testing.T) {
+	assert.Equal(t, "SPACEMESH_MAIN_LAYERS_PER_EPOCH", EnvVarName("main.layers-per-epoch"))
+	assert.Equal(t, "SPACEMESH_P2P_SWARM_BOOTNODES", EnvVarName("p2p.swarm.bootnodes"))
+}
+
+func TestKeys(t *testing.T) {
+	keys := Keys()
+	assert.Contains(t, keys, "main.layers-per-epoch")
+	assert.Contains(t, keys, "p2p.swarm.bootnodes")
+	assert.Contains(t, keys, "p2p.dial-timeout")
+	assert.Contains(t, keys, "reward.base-reward")
+	assert.NotContains(t, keys, "p2p.swarm")
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"SPACEMESH_MAIN_LAYERS_PER_EPOCH": "7",
+		"SPACEMESH_P2P_DIAL_TIMEOUT":      "3s",
+		"SPACEMESH_P2P_SWARM_BOOTNODES":   "a,b",
+	}
+	vip := viper.New()
+	vip.Set("main.layers-per-epoch", 5) // read from the config file
+	vip.Set("main.hdist", 9)
+	LoadEnv(vip, func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	})
+
+	conf := DefaultConfig()
+	assert.NoError(t, vip.Unmarshal(&conf))
+	assert.Equal(t, 7, conf.LayersPerEpoch)
+	assert.Equal(t, 9, conf.Hdist)
+	assert.Equal(t, 3*time.Second, conf.P2P.DialTimeout)
+	assert.Equal(t, []string{"a", "b"}, conf.P2P.SwarmConfig.BootstrapNodes)
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is the prefix of the environment variables that set config fields.
+const EnvPrefix = "SPACEMESH"
+
+// EnvVarName returns the name of the environment variable that sets the config field with the given key. The key is
+// the field's path in the config file, e.g. main.layers-per-epoch is set by SPACEMESH_MAIN_LAYERS_PER_EPOCH.
+func EnvVarName(key string) string {
+	name := strings.NewReplacer(".", "_", "-", "_").Replace(key)
+	return EnvPrefix + "_" + strings.ToUpper(name)
+}
+
+// Keys returns the keys of all the config fields, as they appear in the config file.
+func Keys() []string {
+	return structKeys(reflect.TypeOf(Config{}), "")
+}
+
+func structKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		key := prefix + name
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() != "time" {
+			keys = append(keys, structKeys(field.Type, key+".")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// LoadEnv sets the config fields that have an environment variable in vip, overriding the values read from the config
+// file. Command line flags are applied after the config is unmarshalled, so the precedence is: flags, environment
+// variables, config file and defaults. lookup is usually os.LookupEnv.
+func LoadEnv(vip *viper.Viper, lookup func(string) (string, bool)) {
+	for _, key := range Keys() {
+		if val, ok := lookup(EnvVarName(key)); ok {
+			vip.Set(key, val)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
+)
+
+// ProtocolConfig holds the consensus constants that all the nodes of a network must agree on. Nodes with different
+// protocol configs would disagree on the validity of blocks, ATXs and hare messages, so the hash of the protocol config
+// is part of the genesis ID and of the p2p handshake, and nodes don't connect to peers with another protocol config.
+type ProtocolConfig struct {
+	LayersPerEpoch   uint32
+	LayerDurationSec uint32
+	Hdist            uint32
+	TickSize         uint64
+	AtxsPerBlock     uint32 // the maximum number of ATXs in a block, which bounds the view of a block
+
+	ActiveSetGraceLayers uint32 // layers of an epoch whose blocks count towards its active set after the first-seen-active-set upgrade
+
+	BeaconProposals      uint32
+	BeaconProposalLayers uint32
+	BeaconVotingLayers   uint32
+
+	HareCommitteeSize   uint32
+	HareMaxAdversaries  uint32
+	HareRoundDuration   uint32
+	HareExpectedLeaders uint32
+	HareLimitIterations uint32
+	HareSingleBlock     bool
+
+	PostSpacePerUnit    uint64
+	PostNumFiles        uint32
+	PostDifficulty      uint32
+	PostNumProvenLabels uint32
+
+	Upgrades []upgrade.Upgrade // the scheduled protocol upgrades, ordered by activation epoch
+}
+
+// Protocol returns the protocol config of cfg.
+func (cfg *Config) Protocol() ProtocolConfig {
+	return ProtocolConfig{
+		LayersPerEpoch:   uint32(cfg.LayersPerEpoch),
+		LayerDurationSec: uint32(cfg.LayerDurationSec),
+		Hdist:            uint32(cfg.Hdist),
+		TickSize:         cfg.TickSize,
+		AtxsPerBlock:     uint32(cfg.AtxsPerBlock),
+
+		ActiveSetGraceLayers: uint32(cfg.ActiveSetGraceLayers),
+
+		BeaconProposals:      uint32(cfg.BeaconProposals),
+		BeaconProposalLayers: uint32(cfg.BeaconProposalLayers),
+		BeaconVotingLayers:   uint32(cfg.BeaconVotingLayers),
+
+		HareCommitteeSize:   uint32(cfg.HARE.N),
+		HareMaxAdversaries:  uint32(cfg.HARE.F),
+		HareRoundDuration:   uint32(cfg.HARE.RoundDuration),
+		HareExpectedLeaders: uint32(cfg.HARE.ExpectedLeaders),
+		HareLimitIterations: uint32(cfg.HARE.LimitIterations),
+		HareSingleBlock:     cfg.HARE.SingleBlock,
+
+		PostSpacePerUnit:    cfg.POST.SpacePerUnit,
+		PostNumFiles:        uint32(cfg.POST.NumFiles),
+		PostDifficulty:      uint32(cfg.POST.Difficulty),
+		PostNumProvenLabels: uint32(cfg.POST.NumProvenLabels),
+
+		Upgrades: upgrade.List(cfg.Upgrades),
+	}
+}
+
+// Hash returns the hash of the protocol config. Nodes with the same hash agree on all the consensus constants.
+func (p ProtocolConfig) Hash() types.Hash32 {
+	bytes, err := types.InterfaceToBytes(&p)
+	if err != nil {
+		panic("failed to marshal protocol config: " + err.Error())
+	}
+	return types.CalcHash32(bytes)
+}
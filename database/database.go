@@ -19,6 +19,7 @@ package database
 
 import (
 	"fmt"
+	"github.com/go-kit/kit/metrics"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
@@ -26,6 +27,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/util"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -35,6 +37,7 @@ import (
 
 const (
 	writePauseWarningThrottler = 1 * time.Minute
+	snapshotBatchSize          = 1000
 )
 
 // ErrNotFound is special type error for not found in DB
@@ -48,6 +51,8 @@ type LDBDatabase struct {
 	quitLock sync.Mutex      // Mutex protecting the quit channel access
 	quitChan chan chan error // Quit channel to stop the metrics collection before closing the database
 
+	metrics *storeMetrics // Operation counters, latencies and size of the store
+
 	log log.Log // Contextual logger tracking the database path
 }
 
@@ -78,10 +83,33 @@ func NewLDBDatabase(file string, cache int, handles int, logger log.Log) (*LDBDa
 	if err != nil {
 		return nil, err
 	}
+	ldb := &LDBDatabase{
+		fn:      file,
+		db:      db,
+		metrics: newStoreMetrics(file),
+		log:     logger,
+	}
+	ldb.Meter(filepath.Base(file))
+	return ldb, nil
+}
+
+// NewLDBDatabaseReadOnly opens an existing LevelDB database read-only, for inspection by external tools. Writes fail
+// and a corrupted database isn't recovered. The database can't be opened while another process has it open for
+// writing.
+func NewLDBDatabaseReadOnly(file string, logger log.Log) (*LDBDatabase, error) {
+	db, err := leveldb.OpenFile(file, &opt.Options{
+		ReadOnly:       true,
+		ErrorIfMissing: true,
+		Filter:         filter.NewBloomFilter(10),
+	})
+	if err != nil {
+		return nil, err
+	}
 	return &LDBDatabase{
-		fn:  file,
-		db:  db,
-		log: logger,
+		fn:      file,
+		db:      db,
+		metrics: newStoreMetrics(file),
+		log:     logger,
 	}, nil
 }
 
@@ -92,16 +120,19 @@ func (db *LDBDatabase) Path() string {
 
 // Put puts the given key / value to the queue
 func (db *LDBDatabase) Put(key []byte, value []byte) error {
+	defer db.metrics.write.done(time.Now())
 	return db.db.Put(key, value, nil)
 }
 
 // Has returns whether the db contains the key
 func (db *LDBDatabase) Has(key []byte) (bool, error) {
+	defer db.metrics.read.done(time.Now())
 	return db.db.Has(key, nil)
 }
 
 // Get returns the given key if it's present.
 func (db *LDBDatabase) Get(key []byte) ([]byte, error) {
+	defer db.metrics.read.done(time.Now())
 	dat, err := db.db.Get(key, nil)
 	if err != nil {
 		return nil, err
@@ -111,6 +142,7 @@ func (db *LDBDatabase) Get(key []byte) ([]byte, error) {
 
 // Delete deletes the key from the queue and database
 func (db *LDBDatabase) Delete(key []byte) error {
+	defer db.metrics.delete.done(time.Now())
 	return db.db.Delete(key, nil)
 }
 
@@ -163,7 +195,43 @@ func (db *LDBDatabase) LDB() *leveldb.DB {
 	return db.db
 }
 
-// Meter configures the database metrics collectors and
+// Snapshot returns a consistent, read-only view of the database at the time of the call. The snapshot must be
+// released after use.
+func (db *LDBDatabase) Snapshot() (*leveldb.Snapshot, error) {
+	return db.db.GetSnapshot()
+}
+
+// WriteSnapshot copies all the entries of snap into a new database at path. It fails if a database exists at path.
+func WriteSnapshot(snap *leveldb.Snapshot, path string) error {
+	out, err := leveldb.OpenFile(path, &opt.Options{ErrorIfExist: true})
+	if err != nil {
+		return fmt.Errorf("cannot create database at %v: %v", path, err)
+	}
+	defer out.Close()
+
+	it := snap.NewIterator(nil, nil)
+	defer it.Release()
+	batch := new(leveldb.Batch)
+	for it.Next() {
+		batch.Put(it.Key(), it.Value())
+		if batch.Len() >= snapshotBatchSize {
+			if err := out.Write(batch, nil); err != nil {
+				return fmt.Errorf("cannot write to %v: %v", path, err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("cannot read snapshot: %v", err)
+	}
+	if err := out.Write(batch, nil); err != nil {
+		return fmt.Errorf("cannot write to %v: %v", path, err)
+	}
+	return out.Close()
+}
+
+// Meter configures the database metrics collectors and starts reporting the internal leveldb counters of the store.
+// It's called when the database is opened.
 func (db *LDBDatabase) Meter(prefix string) {
 	// Initialize all the metrics collector at the requested prefix
 	// Create a quit channel for the periodic collector and run it
@@ -171,7 +239,7 @@ func (db *LDBDatabase) Meter(prefix string) {
 	db.quitChan = make(chan chan error)
 	db.quitLock.Unlock()
 
-	go db.meter(3 * time.Second)
+	go db.meter(3*time.Second, db.metrics.size)
 }
 
 // meter periodically retrieves internal leveldb counters and reports them to
@@ -191,7 +259,7 @@ func (db *LDBDatabase) Meter(prefix string) {
 //
 // This is how the iostats look like (currently):
 // Read(MB):3895.04860 Write(MB):3654.64712
-func (db *LDBDatabase) meter(refresh time.Duration) {
+func (db *LDBDatabase) meter(refresh time.Duration, size metrics.Gauge) {
 	// Create the counters to store current and previous compaction values
 	compactions := make([][]float64, 2)
 	for i := 0; i < 2; i++ {
@@ -236,11 +304,15 @@ func (db *LDBDatabase) meter(refresh time.Duration) {
 		for j := 0; j < len(compactions[i%2]); j++ {
 			compactions[i%2][j] = 0
 		}
+		var sizeMB float64
 		for _, line := range lines {
 			parts := strings.Split(line, "|")
 			if len(parts) != 6 {
 				break
 			}
+			if value, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64); err == nil {
+				sizeMB += value
+			}
 			for idx, counter := range parts[3:] {
 				value, err := strconv.ParseFloat(strings.TrimSpace(counter), 64)
 				if err != nil {
@@ -251,6 +323,7 @@ func (db *LDBDatabase) meter(refresh time.Duration) {
 				compactions[i%2][idx] += value
 			}
 		}
+		size.Set(sizeMB * 1024 * 1024)
 		// Update all the requested meters
 		/*if db.compTimeMeter != nil {
 			db.compTimeMeter.Mark(int64((compactions[i%2][0] - compactions[(i-1)%2][0]) * 1000 * 1000 * 1000))
@@ -344,13 +417,14 @@ func (db *LDBDatabase) meter(refresh time.Duration) {
 
 //NewBatch creates a new batch write struct, able to add multiple values in a single operation
 func (db *LDBDatabase) NewBatch() Batch {
-	return &ldbBatch{db: db.db, b: new(leveldb.Batch)}
+	return &ldbBatch{db: db.db, b: new(leveldb.Batch), metrics: db.metrics}
 }
 
 type ldbBatch struct {
-	db   *leveldb.DB
-	b    *leveldb.Batch
-	size int
+	db      *leveldb.DB
+	b       *leveldb.Batch
+	size    int
+	metrics *storeMetrics
 }
 
 func (b *ldbBatch) Put(key, value []byte) error {
@@ -366,6 +440,7 @@ func (b *ldbBatch) Delete(key []byte) error {
 }
 
 func (b *ldbBatch) Write() error {
+	defer b.metrics.batch.done(time.Now())
 	return b.db.Write(b.b, nil)
 }
 
@@ -210,3 +210,50 @@ func testParallelPutGet(db database.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_WriteSnapshot(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	for i := 0; i < 2500; i++ {
+		if err := db.Put([]byte(strconv.Itoa(i)), []byte("v"+strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+	// writes after the snapshot was taken are not copied
+	if err := db.Put([]byte("late"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "ethdb_snapshot_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/copy"
+	if err := database.WriteSnapshot(snap, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.WriteSnapshot(snap, path); err == nil {
+		t.Fatal("expected an error when the database exists")
+	}
+
+	copied, err := database.NewLDBDatabase(path, 0, 0, log.NewDefault("db.copy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	for i := 0; i < 2500; i++ {
+		val, err := copied.Get([]byte(strconv.Itoa(i)))
+		if err != nil || string(val) != "v"+strconv.Itoa(i) {
+			t.Fatalf("got %q, %v for key %d", val, err, i)
+		}
+	}
+	if _, err := copied.Get([]byte("late")); err != database.ErrNotFound {
+		t.Fatalf("expected ErrNotFound for a write after the snapshot, got %v", err)
+	}
+}
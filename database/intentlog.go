@@ -0,0 +1,51 @@
+package database
+
+import "fmt"
+
+// IntentLog records the objects that are being processed, so that objects whose processing was interrupted between
+// their writes, e.g. by a crash, can be processed again when the node starts. An intent is recorded before the first
+// write of an object and cleared once all of its writes are done. Intents are kept in db, under prefix.
+type IntentLog struct {
+	db     Database
+	prefix []byte
+}
+
+// NewIntentLog returns an IntentLog that keeps its intents in db, with keys starting with prefix. The prefix must not
+// be the prefix of any other key in db.
+func NewIntentLog(db Database, prefix string) *IntentLog {
+	return &IntentLog{db: db, prefix: []byte(prefix)}
+}
+
+func (l *IntentLog) key(id []byte) []byte {
+	return append(append([]byte{}, l.prefix...), id...)
+}
+
+// Begin records the intent to process the object with the given id. data must hold everything needed to process the
+// object again.
+func (l *IntentLog) Begin(id, data []byte) error {
+	return l.db.Put(l.key(id), data)
+}
+
+// Done clears the intent to process the object with the given id.
+func (l *IntentLog) Done(id []byte) error {
+	return l.db.Delete(l.key(id))
+}
+
+// Replay calls process with the id and data of every intent that wasn't cleared, in order of their ids, and clears
+// them. It's up to process to handle the objects that cannot be processed again.
+func (l *IntentLog) Replay(process func(id, data []byte)) error {
+	var ids, data [][]byte
+	it := l.db.Find(l.prefix)
+	for it.Next() {
+		ids = append(ids, append([]byte{}, it.Key()[len(l.prefix):]...))
+		data = append(data, append([]byte{}, it.Value()...))
+	}
+
+	for i, id := range ids {
+		process(id, data[i])
+		if err := l.Done(id); err != nil {
+			return fmt.Errorf("cannot clear intent: %v", err)
+		}
+	}
+	return nil
+}
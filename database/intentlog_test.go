@@ -0,0 +1,35 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntentLog_Replay(t *testing.T) {
+	r := require.New(t)
+	db := NewMemDatabase()
+	r.NoError(db.Put([]byte("other"), []byte("value")))
+	l := NewIntentLog(db, "intent_")
+
+	r.NoError(l.Begin([]byte("b"), []byte("data b")))
+	r.NoError(l.Begin([]byte("a"), []byte("data a")))
+	r.NoError(l.Begin([]byte("c"), []byte("data c")))
+	r.NoError(l.Done([]byte("c")))
+
+	var replayed []string
+	r.NoError(l.Replay(func(id, data []byte) {
+		replayed = append(replayed, string(id)+":"+string(data))
+	}))
+	r.Equal([]string{"a:data a", "b:data b"}, replayed)
+
+	// replayed intents are cleared, other keys are kept
+	replayed = nil
+	r.NoError(l.Replay(func(id, data []byte) {
+		replayed = append(replayed, string(id))
+	}))
+	r.Empty(replayed)
+	value, err := db.Get([]byte("other"))
+	r.NoError(err)
+	r.Equal([]byte("value"), value)
+}
@@ -60,4 +60,8 @@ type Iterator interface {
 	iterator.IteratorSeeker
 	Key() []byte
 	Value() []byte
+	// Release releases the iterator's resources, it must be called once the iterator isn't used anymore
+	Release()
+	// Error returns the error that stopped the iteration, if any
+	Error() error
 }
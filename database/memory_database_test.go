@@ -27,3 +27,22 @@ func TestMemoryDB_Iterator(t *testing.T) {
 	iter.Next()
 	checkRow(secondKey, secondValue, iter, t)
 }
+
+func TestMemoryDB_IteratorSeek(t *testing.T) {
+	db := NewMemDatabase()
+	for _, k := range []string{"a1", "a3", "a5", "b1"} {
+		db.Put([]byte(k), []byte("v"+k))
+	}
+
+	iter := db.Find([]byte("a")).(*MemDatabaseIterator)
+	assert.True(t, iter.Seek([]byte("a3")))
+	checkRow([]byte("a3"), []byte("va3"), iter, t)
+	assert.True(t, iter.Seek([]byte("a4")))
+	checkRow([]byte("a5"), []byte("va5"), iter, t)
+	assert.False(t, iter.Next())
+	assert.False(t, iter.Seek([]byte("a6")))
+
+	assert.True(t, iter.First())
+	checkRow([]byte("a1"), []byte("va1"), iter, t)
+	assert.False(t, db.Find([]byte("c")).First())
+}
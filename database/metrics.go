@@ -0,0 +1,82 @@
+package database
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	mt "github.com/spacemeshos/go-spacemesh/metrics"
+)
+
+const (
+	// MetricsSubsystem is the subsystem of the database metrics.
+	MetricsSubsystem = "database"
+
+	storeLabel = "store"
+	opLabel    = "op"
+)
+
+var (
+	operations = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: mt.Namespace,
+		Subsystem: MetricsSubsystem,
+		Name:      "operations_total",
+		Help:      "Number of database operations by store and operation.",
+	}, []string{storeLabel, opLabel})
+
+	operationDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: mt.Namespace,
+		Subsystem: MetricsSubsystem,
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of database operations by store and operation, in seconds.",
+		Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	}, []string{storeLabel, opLabel})
+
+	storeSize = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: mt.Namespace,
+		Subsystem: MetricsSubsystem,
+		Name:      "size_bytes",
+		Help:      "Estimated size of the tables of a store on disk.",
+	}, []string{storeLabel})
+)
+
+// opMetrics counts an operation and observes its duration.
+type opMetrics struct {
+	count    metrics.Counter
+	duration metrics.Histogram
+}
+
+func newOpMetrics(store, op string) opMetrics {
+	return opMetrics{
+		count:    operations.With(storeLabel, store, opLabel, op),
+		duration: operationDuration.With(storeLabel, store, opLabel, op),
+	}
+}
+
+// done records an operation that started at start.
+func (m opMetrics) done(start time.Time) {
+	m.count.Add(1)
+	m.duration.Observe(time.Since(start).Seconds())
+}
+
+// storeMetrics are the metrics of a single store, labeled by the store's name.
+type storeMetrics struct {
+	read   opMetrics
+	write  opMetrics
+	delete opMetrics
+	batch  opMetrics
+	size   metrics.Gauge
+}
+
+func newStoreMetrics(file string) *storeMetrics {
+	store := filepath.Base(file)
+	return &storeMetrics{
+		read:   newOpMetrics(store, "read"),
+		write:  newOpMetrics(store, "write"),
+		delete: newOpMetrics(store, "delete"),
+		batch:  newOpMetrics(store, "batch"),
+		size:   storeSize.With(storeLabel, store),
+	}
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+func testOpMetrics() (opMetrics, *generic.Counter) {
+	count := generic.NewCounter("count")
+	return opMetrics{count: count, duration: generic.NewHistogram("duration", 10)}, count
+}
+
+func TestLDBDatabase_Metrics(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	db, err := NewLDBDatabase(dir, 0, 0, log.NewDefault(t.Name()))
+	r.NoError(err)
+	defer db.Close()
+
+	var reads, writes, deletes, batches *generic.Counter
+	m := &storeMetrics{size: generic.NewGauge("size")}
+	m.read, reads = testOpMetrics()
+	m.write, writes = testOpMetrics()
+	m.delete, deletes = testOpMetrics()
+	m.batch, batches = testOpMetrics()
+	db.metrics = m
+
+	r.NoError(db.Put([]byte("k"), []byte("v")))
+	_, err = db.Get([]byte("k"))
+	r.NoError(err)
+	_, err = db.Has([]byte("k"))
+	r.NoError(err)
+	r.NoError(db.Delete([]byte("k")))
+	b := db.NewBatch()
+	r.NoError(b.Put([]byte("k"), []byte("v")))
+	r.NoError(b.Write())
+
+	r.Equal(1.0, writes.Value())
+	r.Equal(2.0, reads.Value())
+	r.Equal(1.0, deletes.Value())
+	r.Equal(1.0, batches.Value())
+}
@@ -45,3 +45,23 @@ func TestNewBlockEvent(t *testing.T) {
 	}
 
 }
+
+func TestSubscribeLocal(t *testing.T) {
+	peers, unsubscribe := SubscribeLocal(EventPeer)
+	blocks, unsubscribeBlocks := SubscribeLocal(EventNewBlock)
+	defer unsubscribeBlocks()
+
+	orig := Peer{ID: "peer", Type: PeerDisconnected, Reason: ReasonConnClosed}
+	Publish(orig)
+	select {
+	case ev := <-peers:
+		assert.Equal(t, orig, ev)
+	default:
+		assert.Fail(t, "didn't receive peer event")
+	}
+	assert.Len(t, blocks, 0)
+
+	unsubscribe()
+	Publish(orig)
+	assert.Len(t, peers, 0)
+}
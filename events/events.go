@@ -4,6 +4,7 @@ package events
 import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"sync"
 )
 
 // These consts are used as prefixes for different messages in pubsub
@@ -17,12 +18,22 @@ const (
 	EventRewardReceived
 	EventCreatedBlock
 	EventCreatedAtx
+	EventPoetDeadline
+	EventPeer
+	EventStateRootDivergence
+	EventTxReceipt
 )
 
 // publisher is the event publisher singleton.
 var publisher *EventPublisher
 
-// Publish publishes an event on the pubsub singleton.
+// localSubs are the in-process subscribers to events, by channel.
+var (
+	localSubs   = make(map[ChannelID]map[chan Event]struct{})
+	localSubsMu sync.RWMutex
+)
+
+// Publish publishes an event on the pubsub singleton and sends it to the in-process subscribers of its channel.
 func Publish(event Event) {
 	if publisher != nil {
 		err := publisher.PublishEvent(event)
@@ -31,6 +42,32 @@ func Publish(event Event) {
 		}
 
 	}
+
+	localSubsMu.RLock()
+	for ch := range localSubs[event.GetChannel()] {
+		select {
+		case ch <- event:
+		default: // the subscriber is too slow, drop the event rather than block the publisher
+		}
+	}
+	localSubsMu.RUnlock()
+}
+
+// SubscribeLocal returns a channel on which the events published on channel are received in-process, e.g. to stream
+// them over the API. Events are dropped if the subscriber doesn't keep up. Unsubscribe by calling the returned func.
+func SubscribeLocal(channel ChannelID) (<-chan Event, func()) {
+	ch := make(chan Event, 100)
+	localSubsMu.Lock()
+	if localSubs[channel] == nil {
+		localSubs[channel] = make(map[chan Event]struct{})
+	}
+	localSubs[channel][ch] = struct{}{}
+	localSubsMu.Unlock()
+	return ch, func() {
+		localSubsMu.Lock()
+		delete(localSubs[channel], ch)
+		localSubsMu.Unlock()
+	}
 }
 
 // InitializeEventPubsub initializes the global pubsub broadcaster server
@@ -162,6 +199,23 @@ func (ValidTx) GetChannel() ChannelID {
 	return EventTxValid
 }
 
+// TxReceipt signals that a transaction was processed as part of a layer, whether it was applied or not
+type TxReceipt struct {
+	ID          string
+	Origin      string
+	Destination string
+	Amount      uint64
+	Fee         uint64
+	Layer       uint64
+	Valid       bool
+	Reverted    bool // the transaction was applied in Layer, which was rolled back
+}
+
+// GetChannel gets the message type which means on which this message should be sent
+func (TxReceipt) GetChannel() ChannelID {
+	return EventTxReceipt
+}
+
 // RewardReceived signals reward has been received
 type RewardReceived struct {
 	Coinbase string
@@ -184,3 +238,63 @@ type AtxCreated struct {
 func (AtxCreated) GetChannel() ChannelID {
 	return EventCreatedAtx
 }
+
+// PoetDeadline signals that submitting a challenge to the PoET service, or receiving its proof, risks missing the
+// deadline for publishing the miner's ATX
+type PoetDeadline struct {
+	Stage    string // "submission" or "proof"
+	RoundID  string
+	Expected int64 // unix time the proof is expected at
+	Deadline int64 // unix time the ATX must be published by
+}
+
+// GetChannel gets the message type which means on which this message should be sent
+func (PoetDeadline) GetChannel() ChannelID {
+	return EventPoetDeadline
+}
+
+// These are the types of peer events
+const (
+	PeerConnected    = "connected"
+	PeerDisconnected = "disconnected"
+	PeerRejected     = "rejected" // the peer failed the handshake or was turned away, it never became a neighbor
+)
+
+// These are the reasons of peer events
+const (
+	ReasonOutbound       = "outbound"        // connected to a peer we dialed
+	ReasonInbound        = "inbound"         // connected to a peer that dialed us
+	ReasonConnClosed     = "conn_closed"     // the connection was closed, by either side
+	ReasonBadMessage     = "bad_message"     // the peer sent a message that couldn't be read
+	ReasonMaxInbound     = "max_inbound"     // we have the maximum number of inbound peers
+	ReasonBadHandshake   = "bad_handshake"   // the handshake couldn't be read
+	ReasonClientVersion  = "client_version"  // the peer has another network ID or an unsupported client version
+	ReasonProtocolConfig = "protocol_config" // the peer has another protocol config
+)
+
+// Peer signals that a peer connected, disconnected or was rejected
+type Peer struct {
+	ID     string
+	Type   string // one of PeerConnected, PeerDisconnected and PeerRejected
+	Reason string // one of the Reason constants
+}
+
+// GetChannel gets the message type which means on which this message should be sent
+func (Peer) GetChannel() ChannelID {
+	return EventPeer
+}
+
+// StateRootDivergence signals that a block reports a state root for a layer that differs from the state root of the
+// node at the end of that layer
+type StateRootDivergence struct {
+	BlockID string
+	Miner   string
+	Layer   uint64 // the state layer reported by the block
+	Local   string // the state root of the node
+	Remote  string // the state root reported by the block
+}
+
+// GetChannel gets the message type which means on which this message should be sent
+func (StateRootDivergence) GetChannel() ChannelID {
+	return EventStateRootDivergence
+}
@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockFileName is the name of the file LockDir locks in a directory. It holds the pid of the process holding the lock.
+const LockFileName = "node.lock"
+
+// ErrDirLocked is returned by LockDir when the directory is locked by another process.
+type ErrDirLocked struct {
+	Dir string
+	PID int // the pid of the process holding the lock, 0 if it's unknown
+}
+
+func (e ErrDirLocked) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("%v is locked by another process", e.Dir)
+	}
+	return fmt.Sprintf("%v is locked by another process (pid %d)", e.Dir, e.PID)
+}
+
+// DirLock is an exclusive lock of a directory held by this process, see LockDir.
+type DirLock struct {
+	file *os.File
+}
+
+// LockDir creates dir if it doesn't exist and locks it exclusively, so that two processes can't use it at the same
+// time. The lock is released by Unlock, or by the os when the process exits, so a lock is never left stale by a
+// crash. If another process holds the lock an ErrDirLocked is returned.
+func LockDir(dir string) (*DirLock, error) {
+	if err := ExistOrCreate(dir); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, LockFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, OwnerReadWrite)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := tryLock(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !locked {
+		pid, _ := readPID(f)
+		f.Close()
+		return nil, ErrDirLocked{Dir: dir, PID: pid}
+	}
+
+	if err := writePID(f); err != nil {
+		unlock(f)
+		f.Close()
+		return nil, err
+	}
+	return &DirLock{file: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *DirLock) Unlock() error {
+	// the pid is cleared so that it isn't reported for a lock no one holds.
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := unlock(l.file); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+func readPID(f *os.File) (int, error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,34 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockDir(t *testing.T) {
+	r := require.New(t)
+	parent, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(parent)
+	dir := filepath.Join(parent, "data")
+
+	lock, err := LockDir(dir)
+	r.NoError(err)
+	data, err := ioutil.ReadFile(filepath.Join(dir, LockFileName))
+	r.NoError(err)
+	r.Equal(strconv.Itoa(os.Getpid()), strings.TrimSpace(string(data)))
+
+	_, err = LockDir(dir)
+	r.Equal(ErrDirLocked{Dir: dir, PID: os.Getpid()}, err)
+
+	r.NoError(lock.Unlock())
+	lock, err = LockDir(dir)
+	r.NoError(err)
+	r.NoError(lock.Unlock())
+}
@@ -0,0 +1,14 @@
+package filesystem
+
+import (
+	"os"
+)
+
+// tryLock doesn't lock on windows, where files can't be flocked, the pid file is still written.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
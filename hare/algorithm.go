@@ -76,6 +76,8 @@ func (proc *consensusProcess) report(completed bool) {
 
 var _ TerminationOutput = (*procReport)(nil)
 
+var errListenOnly = errors.New("hare is listen-only, not building messages")
+
 // State holds the current state of the consensus process (aka the participant).
 type State struct {
 	k           int32        // the round counter (k%4 is the round number)
@@ -170,6 +172,9 @@ type consensusProcess struct {
 	notifySent        bool            // flag to set in case a notification had already been sent by this instance
 	mTracker          *msgsTracker    // tracks valid messages
 	terminating       bool
+	sent              *sentMessages // persists the messages this instance signs, may be nil
+	abstain           bool          // set if messages were signed for this layer by a previous instance
+	onEquivocation    equivocationHandler
 }
 
 // newConsensusProcess creates a new consensus process instance.
@@ -223,6 +228,19 @@ func (proc *consensusProcess) Start() error {
 		return startInstanceError(errors.New("instance started with nil inbox"))
 	}
 
+	if proc.sent != nil {
+		round, participated, err := proc.sent.Participated(proc.instanceID)
+		if err != nil {
+			proc.With().Error("could not read sent messages, abstaining", log.LayerID(uint64(proc.instanceID)), log.Err(err))
+			proc.abstain = true
+		} else if participated {
+			// we don't know the state of the previous instance, signing messages for this layer may equivocate
+			proc.With().Warning("restarted consensus process of a layer that messages were already sent in, abstaining",
+				log.LayerID(uint64(proc.instanceID)), log.Int32("last_round", round))
+			proc.abstain = true
+		}
+	}
+
 	proc.isStarted = true
 
 	go proc.eventLoop()
@@ -436,7 +454,21 @@ func (proc *consensusProcess) sendMessage(msg *Msg) bool {
 		return false
 	}
 
-	if err := proc.network.Broadcast(protoName, msg.Bytes()); err != nil {
+	if proc.cfg.ListenOnly {
+		proc.Error("sendMessage was called in listen-only mode, not sending")
+		return false
+	}
+
+	bts := msg.Bytes()
+	if proc.sent != nil {
+		// persist before sending, so that a restarted instance never signs a second message for this layer
+		if err := proc.sent.Record(proc.instanceID, proc.k, bts); err != nil {
+			proc.With().Error("could not persist round message, not sending it", log.Err(err))
+			return false
+		}
+	}
+
+	if err := proc.network.Broadcast(protoName, bts); err != nil {
 		proc.Error("Could not broadcast round message ", err.Error())
 		return false
 	}
@@ -499,7 +531,9 @@ func (proc *consensusProcess) beginStatusRound() {
 }
 
 func (proc *consensusProcess) beginProposalRound() {
-	proc.proposalTracker = newProposalTracker(proc.Log)
+	pt := newProposalTracker(proc.Log)
+	pt.onEquivocation = proc.onEquivocation
+	proc.proposalTracker = pt
 
 	// done with building proposal, reset statuses tracking
 	defer func() { proc.statusesTracker = nil }()
@@ -636,7 +670,11 @@ func (proc *consensusProcess) onRoundBegin() {
 }
 
 // init a new message builder with the current state (s, k, ki) for this instance
+// returns errListenOnly in listen-only mode, without asking the oracle for a role proof
 func (proc *consensusProcess) initDefaultBuilder(s *Set) (*messageBuilder, error) {
+	if proc.cfg.ListenOnly {
+		return nil, errListenOnly
+	}
 	builder := newMessageBuilder().SetInstanceID(proc.instanceID)
 	builder = builder.SetRoundCounter(proc.k).SetKi(proc.ki).SetValues(s)
 	proof, err := proc.oracle.Proof(types.LayerID(proc.instanceID), proc.k)
@@ -752,6 +790,18 @@ func (proc *consensusProcess) endOfStatusRound() {
 // checks if we should participate in the current round
 // returns true if we should participate, false otherwise
 func (proc *consensusProcess) shouldParticipate() bool {
+	if proc.cfg.ListenOnly {
+		proc.With().Debug("should not participate: listen-only",
+			log.Uint64("layer_id", uint64(proc.instanceID)))
+		return false
+	}
+
+	if proc.abstain {
+		proc.With().Info("should not participate: abstaining after restart",
+			log.Uint64("layer_id", uint64(proc.instanceID)))
+		return false
+	}
+
 	// query if identity is active
 	res, err := proc.oracle.IsIdentityActiveOnConsensusView(proc.signing.PublicKey().String(), types.LayerID(proc.instanceID))
 	if err != nil {
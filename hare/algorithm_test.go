@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/spacemeshos/amcl/BLS381"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/eligibility"
 	"github.com/spacemeshos/go-spacemesh/hare/config"
 	"github.com/spacemeshos/go-spacemesh/log"
@@ -347,6 +348,47 @@ func TestConsensusProcess_sendMessage(t *testing.T) {
 	r.True(b)
 }
 
+func TestConsensusProcess_RestartAbstains(t *testing.T) {
+	r := require.New(t)
+	net := &mockP2p{}
+	sent := newSentMessages(database.NewMemDatabase())
+
+	oracle := &mockRolacle{MockStateQuerier: MockStateQuerier{true, nil}, isEligible: true}
+
+	proc := generateConsensusProcess(t)
+	proc.network = net
+	proc.oracle = oracle
+	proc.sent = sent
+	proc.k = 2
+	r.True(proc.sendMessage(buildStatusMsg(generateSigning(t), proc.s, 0)))
+
+	round, participated, err := sent.Participated(proc.instanceID)
+	r.NoError(err)
+	r.True(participated)
+	r.Equal(int32(2), round)
+
+	// a process started again for the same layer doesn't sign messages
+	restarted := generateConsensusProcess(t)
+	restarted.network = net
+	restarted.oracle = oracle
+	restarted.sent = sent
+	restarted.SetInbox(make(chan *Msg))
+	r.NoError(restarted.Start())
+	defer restarted.Close()
+	r.False(restarted.shouldParticipate())
+
+	// but does for other layers
+	other := generateConsensusProcess(t)
+	other.instanceID = instanceID2
+	other.network = net
+	other.oracle = oracle
+	other.sent = sent
+	other.SetInbox(make(chan *Msg))
+	r.NoError(other.Start())
+	defer other.Close()
+	r.True(other.shouldParticipate())
+}
+
 func TestConsensusProcess_procPre(t *testing.T) {
 	proc := generateConsensusProcess(t)
 	s := NewDefaultEmptySet()
@@ -652,3 +694,30 @@ func TestConsensusProcess_beginRound4(t *testing.T) {
 	r.Equal(1, net.callBroadcast)
 	r.True(proc.notifySent)
 }
+
+func TestConsensusProcess_ListenOnly(t *testing.T) {
+	r := require.New(t)
+
+	proc := generateConsensusProcess(t)
+	proc.cfg.ListenOnly = true
+	net := &mockNet{}
+	proc.network = net
+	oracle := &mockRolacle{MockStateQuerier: MockStateQuerier{true, nil}, isEligible: true}
+	proc.oracle = oracle
+
+	r.False(proc.shouldParticipate())
+	_, err := proc.initDefaultBuilder(proc.s)
+	r.Equal(errListenOnly, err)
+	r.False(proc.sendMessage(buildStatusMsg(generateSigning(t), proc.s, 0)))
+
+	// the certificate of the notify round is recorded, but no notify message is sent
+	mpt := &mockProposalTracker{proposedSet: NewSetFromValues(value1)}
+	mct := &mockCommitTracker{hasEnoughCommits: true, certificate: &certificate{}}
+	proc.proposalTracker = mpt
+	proc.commitTracker = mct
+	proc.beginNotifyRound()
+	r.True(proc.s.Equals(mpt.proposedSet))
+	r.Equal(mct.certificate, proc.certificate)
+	r.False(proc.notifySent)
+	r.Equal(0, net.callBroadcast)
+}
@@ -8,11 +8,13 @@ type Config struct {
 	WakeupDelta     int `mapstructure:"hare-wakeup-delta"`       // the wakeup delta after tick
 	ExpectedLeaders int `mapstructure:"hare-exp-leaders"`        // the expected number of leaders
 	SuperHare       bool
-	LimitIterations int `mapstructure:"hare-limit-iterations"` // limit on number of iterations
-	LimitConcurrent int `mapstructure:"hare-limit-concurrent"` // limit number of concurrent CPs
+	LimitIterations int  `mapstructure:"hare-limit-iterations"` // limit on number of iterations
+	LimitConcurrent int  `mapstructure:"hare-limit-concurrent"` // limit number of concurrent CPs
+	SingleBlock     bool `mapstructure:"hare-single-block"`     // agree on a single leader block per layer
+	ListenOnly      bool `mapstructure:"hare-listen-only"`      // follow and validate consensus without ever sending messages
 }
 
 // DefaultConfig returns the default configuration for the hare.
 func DefaultConfig() Config {
-	return Config{10, 5, 2, 10, 5, false, 1000, 5}
+	return Config{10, 5, 2, 10, 5, false, 1000, 5, false, false}
 }
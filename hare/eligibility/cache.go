@@ -0,0 +1,110 @@
+package eligibility
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare/metrics"
+)
+
+type eligibilityKey struct {
+	id    string
+	layer types.LayerID
+	round int32
+}
+
+// the result is only reused for the same proof and committee size, so that a cached result of a valid proof is not
+// returned for an invalid one
+type eligibilityEntry struct {
+	proof         [sha256.Size]byte
+	committeeSize int
+	eligible      bool
+}
+
+// eligibilityCache caches the results of eligibility checks by identity, layer and round, since the same check is
+// repeated for every copy of a hare message received from peers. It holds at most size results. Results are grouped
+// by epoch: when an epoch starts, the results of epochs before the previous one are evicted, and when the cache is
+// full the oldest epoch is evicted to make room.
+type eligibilityCache struct {
+	mu             sync.Mutex
+	size           int
+	count          int
+	layersPerEpoch uint16
+	epochs         map[types.EpochID]map[eligibilityKey]eligibilityEntry
+	latest         types.EpochID
+}
+
+func newEligibilityCache(size int, layersPerEpoch uint16) *eligibilityCache {
+	return &eligibilityCache{
+		size:           size,
+		layersPerEpoch: layersPerEpoch,
+		epochs:         make(map[types.EpochID]map[eligibilityKey]eligibilityEntry),
+	}
+}
+
+// Get returns the cached result of the eligibility check, and false if there is none.
+func (c *eligibilityCache) Get(id types.NodeID, layer types.LayerID, round int32, committeeSize int, proof []byte) (eligible bool, exist bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.epochs[layer.GetEpoch(c.layersPerEpoch)][eligibilityKey{id.Key, layer, round}]
+	if !ok || entry.committeeSize != committeeSize || entry.proof != sha256.Sum256(proof) {
+		metrics.EligibilityCacheMisses.Add(1)
+		return false, false
+	}
+	metrics.EligibilityCacheHits.Add(1)
+	return entry.eligible, true
+}
+
+// Add caches the result of an eligibility check.
+func (c *eligibilityCache) Add(id types.NodeID, layer types.LayerID, round int32, committeeSize int, proof []byte, eligible bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	epoch := layer.GetEpoch(c.layersPerEpoch)
+	if epoch > c.latest {
+		c.latest = epoch
+		for ep := range c.epochs {
+			if ep+1 < epoch {
+				c.evict(ep)
+			}
+		}
+	}
+	if epoch+1 < c.latest {
+		return // would be evicted right away
+	}
+	for c.count >= c.size && len(c.epochs) > 0 {
+		c.evict(c.oldest())
+	}
+	if c.count >= c.size {
+		return // the cache is disabled
+	}
+	results, ok := c.epochs[epoch]
+	if !ok {
+		results = make(map[eligibilityKey]eligibilityEntry)
+		c.epochs[epoch] = results
+	}
+	key := eligibilityKey{id.Key, layer, round}
+	if _, exist := results[key]; !exist {
+		c.count++
+	}
+	results[key] = eligibilityEntry{proof: sha256.Sum256(proof), committeeSize: committeeSize, eligible: eligible}
+	metrics.EligibilityCacheSize.Set(float64(c.count))
+}
+
+func (c *eligibilityCache) oldest() types.EpochID {
+	oldest := c.latest
+	for ep := range c.epochs {
+		if ep < oldest {
+			oldest = ep
+		}
+	}
+	return oldest
+}
+
+func (c *eligibilityCache) evict(epoch types.EpochID) {
+	evicted := len(c.epochs[epoch])
+	delete(c.epochs, epoch)
+	c.count -= evicted
+	metrics.EligibilityCacheEvictions.Add(float64(evicted))
+	metrics.EligibilityCacheSize.Set(float64(c.count))
+}
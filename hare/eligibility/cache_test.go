@@ -0,0 +1,105 @@
+package eligibility
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	eCfg "github.com/spacemeshos/go-spacemesh/hare/eligibility/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEligibilityCache_GetAdd(t *testing.T) {
+	r := require.New(t)
+	c := newEligibilityCache(10, defLayersPerEpoch)
+	id := types.NodeID{Key: "abc"}
+
+	_, exist := c.Get(id, 1, 2, 10, []byte{1})
+	r.False(exist)
+	c.Add(id, 1, 2, 10, []byte{1}, true)
+	eligible, exist := c.Get(id, 1, 2, 10, []byte{1})
+	r.True(exist)
+	r.True(eligible)
+
+	// the result is not reused for another proof, committee size, round or identity
+	_, exist = c.Get(id, 1, 2, 10, []byte{2})
+	r.False(exist)
+	_, exist = c.Get(id, 1, 2, 11, []byte{1})
+	r.False(exist)
+	_, exist = c.Get(id, 1, 3, 10, []byte{1})
+	r.False(exist)
+	_, exist = c.Get(types.NodeID{Key: "def"}, 1, 2, 10, []byte{1})
+	r.False(exist)
+}
+
+func TestEligibilityCache_EpochEviction(t *testing.T) {
+	r := require.New(t)
+	c := newEligibilityCache(100, defLayersPerEpoch)
+	id := types.NodeID{Key: "abc"}
+
+	c.Add(id, 1, 0, 10, nil, true)                   // epoch 0
+	c.Add(id, defLayersPerEpoch+1, 0, 10, nil, true) // epoch 1
+	_, exist := c.Get(id, 1, 0, 10, nil)
+	r.True(exist)
+
+	// epoch 2 evicts epoch 0 but keeps the previous epoch
+	c.Add(id, 2*defLayersPerEpoch+1, 0, 10, nil, true)
+	_, exist = c.Get(id, 1, 0, 10, nil)
+	r.False(exist)
+	_, exist = c.Get(id, defLayersPerEpoch+1, 0, 10, nil)
+	r.True(exist)
+	r.Equal(2, c.count)
+
+	// results of evicted epochs are not added
+	c.Add(id, 2, 0, 10, nil, true)
+	_, exist = c.Get(id, 2, 0, 10, nil)
+	r.False(exist)
+}
+
+func TestEligibilityCache_Bounded(t *testing.T) {
+	r := require.New(t)
+	c := newEligibilityCache(3, defLayersPerEpoch)
+	id := types.NodeID{Key: "abc"}
+
+	c.Add(id, 1, 0, 10, nil, true)
+	c.Add(id, 1, 1, 10, nil, true)
+	c.Add(id, defLayersPerEpoch+1, 0, 10, nil, true)
+	r.Equal(3, c.count)
+
+	// full, the oldest epoch is evicted
+	c.Add(id, defLayersPerEpoch+1, 1, 10, nil, true)
+	r.Equal(2, c.count)
+	_, exist := c.Get(id, 1, 0, 10, nil)
+	r.False(exist)
+	_, exist = c.Get(id, defLayersPerEpoch+1, 1, 10, nil)
+	r.True(exist)
+
+	// a zero size disables the cache
+	c = newEligibilityCache(0, defLayersPerEpoch)
+	c.Add(id, 1, 0, 10, nil, true)
+	_, exist = c.Get(id, 1, 0, 10, nil)
+	r.False(exist)
+}
+
+func TestOracle_EligibleCached(t *testing.T) {
+	r := require.New(t)
+	verifications := 0
+	verifier := func(msg, sig []byte, pub []byte) (bool, error) {
+		verifications++
+		return true, nil
+	}
+	o := New(&mockValueProvider{1, nil}, (&mockActiveSetProvider{10}).ActiveSet, verifier, &mockSigner{}, defLayersPerEpoch,
+		genActive, mockBlocksProvider{}, eCfg.Config{ConfidenceParam: 25, EpochOffset: 30, CacheSize: 10}, log.NewDefault(t.Name()))
+	id := types.NodeID{Key: "abc"}
+
+	for i := 0; i < 3; i++ {
+		res, err := o.Eligible(1, 1, 10, id, []byte{1})
+		r.NoError(err)
+		r.True(res)
+	}
+	r.Equal(1, verifications)
+
+	_, err := o.Eligible(1, 1, 10, id, []byte{2})
+	r.NoError(err)
+	r.Equal(2, verifications)
+}
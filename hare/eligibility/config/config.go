@@ -4,9 +4,10 @@ package config
 type Config struct {
 	ConfidenceParam uint64 `mapstructure:"eligibility-confidence-param"` // the confidence interval
 	EpochOffset     int    `mapstructure:"eligibility-epoch-offset"`     // the offset from the beginning of the epoch
+	CacheSize       int    `mapstructure:"eligibility-cache-size"`       // max number of cached eligibility results, 0 disables the cache
 }
 
 // DefaultConfig returns the default configuration for the oracle package.
 func DefaultConfig() Config {
-	return Config{25, 0}
+	return Config{25, 0, 100000}
 }
@@ -21,6 +21,7 @@ const activesCacheSize = 5 // we don't expect to handle more than two layers con
 var (
 	errGenesis            = errors.New("no data about active nodes for genesis")
 	errNoContextualBlocks = errors.New("no contextually valid blocks")
+	errListenOnly         = errors.New("oracle is listen-only, not signing role proofs")
 )
 
 type valueProvider interface {
@@ -39,6 +40,11 @@ type goodBlocksProvider interface {
 	ContextuallyValidBlock(layer types.LayerID) (map[types.BlockID]struct{}, error)
 }
 
+// reports whether an identity was convicted of malfeasance
+type malfeasanceChecker interface {
+	IsMalicious(nodeID string) bool
+}
+
 // a function to verify the message with the signature and its public key.
 type verifierFunc = func(msg, sig, pub []byte) (bool, error)
 
@@ -54,7 +60,11 @@ type Oracle struct {
 	activesCache         addGet
 	genesisActiveSetSize int
 	blocksProvider       goodBlocksProvider
+	malfeasance          malfeasanceChecker
+	atxs                 atxProvider
+	cache                *eligibilityCache
 	cfg                  eCfg.Config
+	listenOnly           bool
 	log.Log
 }
 
@@ -95,6 +105,10 @@ func roundedSafeLayer(layer types.LayerID, safetyParam types.LayerID,
 func New(beacon valueProvider, activeSetFunc activeSetFunc, vrfVerifier verifierFunc, vrfSigner signer,
 	layersPerEpoch uint16, genesisActiveSet int, goodBlocksProvider goodBlocksProvider,
 	cfg eCfg.Config, log log.Log) *Oracle {
+	if layersPerEpoch == 0 {
+		log.Panic("layers per epoch must be positive")
+	}
+
 	vmc, e := lru.New(vrfMsgCacheSize)
 	if e != nil {
 		log.Panic("Could not create lru cache err=%v", e)
@@ -115,6 +129,7 @@ func New(beacon valueProvider, activeSetFunc activeSetFunc, vrfVerifier verifier
 		activesCache:         ac,
 		genesisActiveSetSize: genesisActiveSet,
 		blocksProvider:       goodBlocksProvider,
+		cache:                newEligibilityCache(cfg.CacheSize, layersPerEpoch),
 		cfg:                  cfg,
 		Log:                  log,
 	}
@@ -178,11 +193,40 @@ func (o *Oracle) activeSetSize(layer types.LayerID) (uint32, error) {
 		return 0, err
 	}
 
-	return uint32(len(actives)), nil
+	return uint32(actives.Len()), nil
+}
+
+// SetMalfeasanceChecker makes the oracle consider identities convicted of malfeasance as not eligible.
+func (o *Oracle) SetMalfeasanceChecker(checker malfeasanceChecker) {
+	o.malfeasance = checker
 }
 
 // Eligible checks if ID is eligible on the given Layer where msg is the VRF message, sig is the role proof and assuming commSize as the expected committee size
 func (o *Oracle) Eligible(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (bool, error) {
+	if o.malfeasance != nil && o.malfeasance.IsMalicious(id.Key) {
+		o.With().Info("eligibility: identity was convicted of malfeasance", id, layer)
+		return false, nil
+	}
+
+	// an identity is in the active set of the safe epoch only if it published an atx targeting it
+	sl := roundedSafeLayer(layer, types.LayerID(o.cfg.ConfidenceParam), o.layersPerEpoch, types.LayerID(o.cfg.EpochOffset))
+	if safeEp := sl.GetEpoch(o.layersPerEpoch); o.atxs != nil && !safeEp.IsGenesis() && !o.atxs.MayHaveAtxForEpoch(id, safeEp) {
+		o.With().Info("eligibility: identity has no atx targeting the safe epoch", id, layer)
+		return false, nil
+	}
+
+	if eligible, exist := o.cache.Get(id, layer, round, committeeSize, sig); exist {
+		return eligible, nil
+	}
+	eligible, err := o.eligible(layer, round, committeeSize, id, sig)
+	if err != nil {
+		return false, err
+	}
+	o.cache.Add(id, layer, round, committeeSize, sig, eligible)
+	return eligible, nil
+}
+
+func (o *Oracle) eligible(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (bool, error) {
 	msg, err := o.buildVRFMessage(layer, round)
 	if err != nil {
 		o.Error("eligibility: could not build VRF message")
@@ -232,8 +276,17 @@ func (o *Oracle) Eligible(layer types.LayerID, round int32, committeeSize int, i
 	return true, nil
 }
 
+// SetListenOnly makes the oracle refuse to sign role proofs, so that the node's identity is never eligible to send
+// messages. Eligibility of other identities is validated as usual. It must be called before the oracle is used.
+func (o *Oracle) SetListenOnly() {
+	o.listenOnly = true
+}
+
 // Proof returns the role proof for the current Layer & Round
 func (o *Oracle) Proof(layer types.LayerID, round int32) ([]byte, error) {
+	if o.listenOnly {
+		return nil, errListenOnly
+	}
 	msg, err := o.buildVRFMessage(layer, round)
 	if err != nil {
 		o.Error("Proof: could not build VRF message err=%v", err)
@@ -249,8 +302,8 @@ func (o *Oracle) Proof(layer types.LayerID, round int32) ([]byte, error) {
 	return sig, nil
 }
 
-// Returns a map of all active nodes in the specified layer id
-func (o *Oracle) actives(layer types.LayerID) (map[string]struct{}, error) {
+// Returns the set of all active nodes in the specified layer id
+func (o *Oracle) actives(layer types.LayerID) (*activeSet, error) {
 	sl := roundedSafeLayer(layer, types.LayerID(o.cfg.ConfidenceParam), o.layersPerEpoch, types.LayerID(o.cfg.EpochOffset))
 	safeEp := sl.GetEpoch(o.layersPerEpoch)
 
@@ -266,7 +319,7 @@ func (o *Oracle) actives(layer types.LayerID) (map[string]struct{}, error) {
 	// check cache
 	if val, exist := o.activesCache.Get(safeEp); exist {
 		o.lock.Unlock()
-		return val.(map[string]struct{}), nil
+		return val.(*activeSet), nil
 	}
 
 	// build a map of all blocks on the current layer
@@ -297,26 +350,20 @@ func (o *Oracle) actives(layer types.LayerID) (map[string]struct{}, error) {
 	}
 
 	// update
-	o.activesCache.Add(safeEp, activeMap)
+	actives := newActiveSet(activeMap)
+	o.activesCache.Add(safeEp, actives)
 
 	o.lock.Unlock()
-	return activeMap, nil
+	return actives, nil
 }
 
 // IsIdentityActiveOnConsensusView returns true if the provided identity is active on the consensus view derived
-// from the specified layer, false otherwise.
+// from the specified layer, false otherwise. The error is set iff the activeness could not be checked, see
+// IdentityStatus for why an identity is inactive.
 func (o *Oracle) IsIdentityActiveOnConsensusView(edID string, layer types.LayerID) (bool, error) {
-	actives, err := o.actives(layer)
-	if err != nil {
-		if err == errGenesis { // we are in genesis
-			return true, nil // all ids are active in genesis
-		}
-
-		o.With().Error("IsIdentityActiveOnConsensusView erred while calling actives func",
-			log.LayerID(uint64(layer)), log.Err(err))
+	status, err := o.IdentityStatus(edID, layer)
+	if status == DataError {
 		return false, err
 	}
-	_, exist := actives[edID]
-
-	return exist, nil
+	return status == ActiveWithAtx, nil
 }
@@ -152,8 +152,7 @@ func TestOracle_buildVRFMessageConcurrency(t *testing.T) {
 }
 
 func TestOracle_IsEligible(t *testing.T) {
-	o := New(&mockValueProvider{1, nil}, nil, nil, nil, 0, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
-	o.layersPerEpoch = 10
+	o := New(&mockValueProvider{1, nil}, nil, nil, nil, 10, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
 	o.vrfVerifier = buildVerifier(false, errFoo)
 	res, err := o.Eligible(types.LayerID(1), 0, 1, types.NodeID{}, []byte{})
 	assert.NotNil(t, err)
@@ -175,6 +174,18 @@ func TestOracle_IsEligible(t *testing.T) {
 	res, err = o.Eligible(types.LayerID(50), 1, 10, types.NodeID{}, []byte{})
 	assert.Nil(t, err)
 	assert.True(t, res)
+
+	o.SetMalfeasanceChecker(mockMalfeasance{"": {}})
+	res, err = o.Eligible(types.LayerID(50), 1, 10, types.NodeID{}, []byte{})
+	assert.Nil(t, err)
+	assert.False(t, res)
+}
+
+type mockMalfeasance map[string]struct{}
+
+func (m mockMalfeasance) IsMalicious(nodeID string) bool {
+	_, ok := m[nodeID]
+	return ok
 }
 
 func Test_safeLayer(t *testing.T) {
@@ -304,6 +315,11 @@ func TestOracle_Proof(t *testing.T) {
 	sig, err = o.Proof(2, 3)
 	assert.Nil(t, err)
 	assert.Equal(t, mySig, sig)
+
+	o.SetListenOnly()
+	sig, err = o.Proof(2, 3)
+	assert.Nil(t, sig)
+	assert.Equal(t, errListenOnly, err)
 }
 
 func TestOracle_Eligible(t *testing.T) {
@@ -382,8 +398,7 @@ func TestOracle_actives(t *testing.T) {
 	r.NoError(err)
 	r.Equal(v, v2)
 	for k := range mp {
-		_, exist := v[k]
-		r.True(exist)
+		r.True(v.Has(k))
 	}
 
 	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
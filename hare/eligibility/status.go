@@ -0,0 +1,145 @@
+package eligibility
+
+import (
+	"hash/fnv"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// IdentityStatus is the activeness of an identity on the consensus view of a layer.
+type IdentityStatus int
+
+const (
+	// ActiveWithAtx is the status of an identity with an atx in the active set of the layer's safe epoch. All
+	// identities are active in genesis.
+	ActiveWithAtx IdentityStatus = iota
+	// InactiveNoAtx is the status of a known identity that has no atx in the active set of the layer's safe epoch.
+	InactiveNoAtx
+	// InactiveOldAtx is the status of an identity whose latest atx targets an epoch before the layer's safe epoch.
+	InactiveOldAtx
+	// UnknownIdentity is the status of an identity this node has never seen an atx of.
+	UnknownIdentity
+	// DataError is the status of an identity whose activeness could not be checked, it comes with the error.
+	DataError
+)
+
+func (s IdentityStatus) String() string {
+	switch s {
+	case ActiveWithAtx:
+		return "active"
+	case InactiveNoAtx:
+		return "inactive (no atx)"
+	case InactiveOldAtx:
+		return "inactive (old atx)"
+	case UnknownIdentity:
+		return "unknown identity"
+	case DataError:
+		return "data error"
+	default:
+		return "invalid status"
+	}
+}
+
+// reports the atxs of an identity, used to tell why an identity is inactive and to skip eligibility checks of
+// identities without an atx in the active set
+type atxProvider interface {
+	GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error)
+	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
+	MayHaveAtxForEpoch(nodeID types.NodeID, targetEpoch types.EpochID) bool
+	ActiveIdentities(epoch types.EpochID) map[string]types.ATXID
+}
+
+// activeSet is the set of active identities of an epoch. A bloom filter in front of the set answers most queries of
+// inactive identities without hashing the identity into the set's map.
+type activeSet struct {
+	ids   map[string]struct{}
+	bloom []uint64
+	mask  uint32
+}
+
+// bloomBitsPerID keeps the filter's false positive rate around 5% with two hash functions
+const bloomBitsPerID = 8
+
+func newActiveSet(ids map[string]struct{}) *activeSet {
+	bits := uint32(64)
+	for bits < uint32(len(ids))*bloomBitsPerID {
+		bits <<= 1
+	}
+	s := &activeSet{ids: ids, bloom: make([]uint64, bits/64), mask: bits - 1}
+	for id := range ids {
+		h1, h2 := s.hash(id)
+		s.bloom[h1/64] |= 1 << (h1 % 64)
+		s.bloom[h2/64] |= 1 << (h2 % 64)
+	}
+	return s
+}
+
+func (s *activeSet) hash(id string) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	sum := h.Sum64()
+	return uint32(sum) & s.mask, uint32(sum>>32) & s.mask
+}
+
+// Has returns true if id is in the set.
+func (s *activeSet) Has(id string) bool {
+	h1, h2 := s.hash(id)
+	if s.bloom[h1/64]&(1<<(h1%64)) == 0 || s.bloom[h2/64]&(1<<(h2%64)) == 0 {
+		return false
+	}
+	_, exist := s.ids[id]
+	return exist
+}
+
+// Len returns the number of identities in the set.
+func (s *activeSet) Len() int {
+	return len(s.ids)
+}
+
+// SetAtxProvider makes the oracle tell inactive identities apart by their latest atx. Without it, every identity that
+// isn't active is reported as InactiveNoAtx.
+func (o *Oracle) SetAtxProvider(atxs atxProvider) {
+	o.atxs = atxs
+}
+
+// IdentityStatus returns the activeness of the identity with the provided Ed public key on the consensus view derived
+// from the specified layer. The error is set iff the status is DataError.
+func (o *Oracle) IdentityStatus(edID string, layer types.LayerID) (IdentityStatus, error) {
+	actives, err := o.actives(layer)
+	if err != nil {
+		if err == errGenesis { // we are in genesis
+			return ActiveWithAtx, nil // all ids are active in genesis
+		}
+
+		o.With().Error("IdentityStatus erred while calling actives func", log.LayerID(uint64(layer)), log.Err(err))
+		return DataError, err
+	}
+	if actives.Has(edID) {
+		return ActiveWithAtx, nil
+	}
+	if o.atxs == nil {
+		return InactiveNoAtx, nil
+	}
+
+	// identities with an atx targeting the safe epoch are told apart from the cached identities of the epoch, only the
+	// others are looked up in the database
+	sl := roundedSafeLayer(layer, types.LayerID(o.cfg.ConfidenceParam), o.layersPerEpoch, types.LayerID(o.cfg.EpochOffset))
+	if _, exist := o.atxs.ActiveIdentities(sl.GetEpoch(o.layersPerEpoch))[edID]; exist {
+		return InactiveNoAtx, nil
+	}
+	id, err := o.atxs.GetNodeLastAtxID(types.NodeID{Key: edID})
+	if err != nil {
+		return UnknownIdentity, nil
+	}
+	atx, err := o.atxs.GetAtxHeader(id)
+	if err != nil {
+		o.With().Error("IdentityStatus could not read the identity's latest atx", log.String("node_id", edID),
+			log.AtxID(id.ShortString()), log.Err(err))
+		return DataError, err
+	}
+	if atx.TargetEpoch(o.layersPerEpoch) < sl.GetEpoch(o.layersPerEpoch) {
+		return InactiveOldAtx, nil
+	}
+	return InactiveNoAtx, nil
+}
@@ -0,0 +1,140 @@
+package eligibility
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAtxProvider struct {
+	last    map[string]types.ATXID
+	headers map[types.ATXID]*types.ActivationTxHeader
+	epochs  map[string]types.EpochID
+}
+
+func (m *mockAtxProvider) GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error) {
+	if id, exist := m.last[nodeID.Key]; exist {
+		return id, nil
+	}
+	return *types.EmptyATXID, errors.New("does not exist")
+}
+
+func (m *mockAtxProvider) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error) {
+	if h, exist := m.headers[id]; exist {
+		return h, nil
+	}
+	return nil, errFoo
+}
+
+func (m *mockAtxProvider) MayHaveAtxForEpoch(nodeID types.NodeID, targetEpoch types.EpochID) bool {
+	epoch, exist := m.epochs[nodeID.Key]
+	return exist && epoch == targetEpoch
+}
+
+func (m *mockAtxProvider) ActiveIdentities(targetEpoch types.EpochID) map[string]types.ATXID {
+	ids := make(map[string]types.ATXID)
+	for node, epoch := range m.epochs {
+		if epoch == targetEpoch {
+			ids[node] = types.ATXID{}
+		}
+	}
+	return ids
+}
+
+func TestActiveSet_Has(t *testing.T) {
+	r := require.New(t)
+	ids := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		ids[strconv.Itoa(i)] = struct{}{}
+	}
+	s := newActiveSet(ids)
+	r.Equal(1000, s.Len())
+	for i := 0; i < 1000; i++ {
+		r.True(s.Has(strconv.Itoa(i)))
+	}
+	for i := 1000; i < 2000; i++ {
+		r.False(s.Has(strconv.Itoa(i)))
+	}
+
+	r.False(newActiveSet(map[string]struct{}{}).Has("1"))
+}
+
+func TestOracle_IdentityStatus(t *testing.T) {
+	r := require.New(t)
+	o := New(&mockValueProvider{1, nil}, nil, nil, nil, 5, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+		return map[string]struct{}{"active": {}}, nil
+	}
+
+	status, err := o.IdentityStatus("unknown", 1)
+	r.NoError(err)
+	r.Equal(ActiveWithAtx, status) // genesis
+
+	status, err = o.IdentityStatus("active", 100)
+	r.NoError(err)
+	r.Equal(ActiveWithAtx, status)
+	status, err = o.IdentityStatus("unknown", 100)
+	r.NoError(err)
+	r.Equal(InactiveNoAtx, status)
+
+	safeEpoch := roundedSafeLayer(100, types.LayerID(cfg.ConfidenceParam), 5, types.LayerID(cfg.EpochOffset)).GetEpoch(5)
+	oldAtx, newAtx, missingAtx := types.ATXID{1}, types.ATXID{2}, types.ATXID{3}
+	o.SetAtxProvider(&mockAtxProvider{
+		epochs: map[string]types.EpochID{"counted": safeEpoch},
+		last:   map[string]types.ATXID{"old": oldAtx, "new": newAtx, "broken": missingAtx},
+		headers: map[types.ATXID]*types.ActivationTxHeader{
+			oldAtx: {NIPSTChallenge: types.NIPSTChallenge{PubLayerID: (safeEpoch - 2).FirstLayer(5)}},
+			newAtx: {NIPSTChallenge: types.NIPSTChallenge{PubLayerID: safeEpoch.FirstLayer(5)}},
+		},
+	})
+	status, err = o.IdentityStatus("unknown", 100)
+	r.NoError(err)
+	r.Equal(UnknownIdentity, status)
+	status, err = o.IdentityStatus("old", 100)
+	r.NoError(err)
+	r.Equal(InactiveOldAtx, status)
+	status, err = o.IdentityStatus("new", 100)
+	r.NoError(err)
+	r.Equal(InactiveNoAtx, status)
+	// an identity with an atx targeting the safe epoch isn't looked up in the database
+	status, err = o.IdentityStatus("counted", 100)
+	r.NoError(err)
+	r.Equal(InactiveNoAtx, status)
+	status, err = o.IdentityStatus("broken", 100)
+	r.Equal(errFoo, err)
+	r.Equal(DataError, status)
+	active, err := o.IsIdentityActiveOnConsensusView("broken", 100)
+	r.Equal(errFoo, err)
+	r.False(active)
+
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+		return nil, errFoo
+	}
+	status, err = o.IdentityStatus("active", 200)
+	r.Equal(errFoo, err)
+	r.Equal(DataError, status)
+}
+
+func TestOracle_EligibleWithoutAtx(t *testing.T) {
+	r := require.New(t)
+	o := New(&mockValueProvider{1, nil}, nil, nil, nil, 5, genActive, mockBlocksProvider{}, cfg, log.NewDefault(t.Name()))
+	o.getActiveSet = func(epoch types.EpochID, blocks map[types.BlockID]struct{}) (map[string]struct{}, error) {
+		return createMapWithSize(9), nil
+	}
+	o.vrfVerifier = func(msg, sig, pub []byte) (bool, error) {
+		return true, nil
+	}
+	safeEpoch := roundedSafeLayer(100, types.LayerID(cfg.ConfidenceParam), 5, types.LayerID(cfg.EpochOffset)).GetEpoch(5)
+	o.SetAtxProvider(&mockAtxProvider{epochs: map[string]types.EpochID{"active": safeEpoch, "old": safeEpoch - 1}})
+
+	eligible, err := o.Eligible(100, 1, 10, types.NodeID{Key: "active"}, []byte{1})
+	r.NoError(err)
+	r.True(eligible)
+	eligible, err = o.Eligible(100, 1, 10, types.NodeID{Key: "old"}, []byte{1})
+	r.NoError(err)
+	r.False(eligible)
+}
@@ -0,0 +1,52 @@
+package hare
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// VerifyEquivocation checks that the two provided serialized hare messages prove that their sender equivocated, i.e.
+// that the same identity signed two different messages of the same type, for the same instance and round.
+// It returns the public key of the equivocating identity and the layer of the instance.
+func VerifyEquivocation(first, second []byte) (*signing.PublicKey, types.LayerID, error) {
+	m1, err := MessageFromBuffer(first)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse first message: %v", err)
+	}
+	m2, err := MessageFromBuffer(second)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse second message: %v", err)
+	}
+	if m1.InnerMsg == nil || m2.InnerMsg == nil {
+		return nil, 0, errors.New("missing inner message")
+	}
+
+	inner1, inner2 := m1.InnerMsg.Bytes(), m2.InnerMsg.Bytes()
+	if bytes.Equal(inner1, inner2) {
+		return nil, 0, errors.New("messages are identical")
+	}
+	if m1.InnerMsg.InstanceID != m2.InnerMsg.InstanceID || m1.InnerMsg.K != m2.InnerMsg.K ||
+		m1.InnerMsg.Type != m2.InnerMsg.Type {
+		return nil, 0, fmt.Errorf("messages are not for the same instance, round and type (%v) (%v)",
+			m1.InnerMsg, m2.InnerMsg)
+	}
+
+	pub1, err := ed25519.ExtractPublicKey(inner1, m1.Sig)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not extract public key of first message: %v", err)
+	}
+	pub2, err := ed25519.ExtractPublicKey(inner2, m2.Sig)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not extract public key of second message: %v", err)
+	}
+	if !bytes.Equal(pub1, pub2) {
+		return nil, 0, errors.New("messages were signed by different identities")
+	}
+
+	return signing.NewPublicKey(pub1), types.LayerID(m1.InnerMsg.InstanceID), nil
+}
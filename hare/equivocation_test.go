@@ -0,0 +1,43 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestVerifyEquivocation(t *testing.T) {
+	sgn := signing.NewEdSigner()
+	m1 := BuildStatusMsg(sgn, NewSetFromValues(value1))
+	m2 := BuildStatusMsg(sgn, NewSetFromValues(value2))
+
+	pub, layer, err := VerifyEquivocation(m1.Bytes(), m2.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, sgn.PublicKey().String(), pub.String())
+	assert.Equal(t, types.LayerID(instanceID1), layer)
+}
+
+func TestVerifyEquivocation_NotEquivocation(t *testing.T) {
+	sgn := signing.NewEdSigner()
+	m1 := BuildStatusMsg(sgn, NewSetFromValues(value1))
+
+	// same message twice
+	_, _, err := VerifyEquivocation(m1.Bytes(), m1.Bytes())
+	assert.Error(t, err)
+
+	// different rounds
+	m2 := BuildPreRoundMsg(sgn, NewSetFromValues(value2))
+	_, _, err = VerifyEquivocation(m1.Bytes(), m2.Bytes())
+	assert.Error(t, err)
+
+	// different signers
+	m3 := BuildStatusMsg(signing.NewEdSigner(), NewSetFromValues(value2))
+	_, _, err = VerifyEquivocation(m1.Bytes(), m3.Bytes())
+	assert.Error(t, err)
+
+	// garbage
+	_, _, err = VerifyEquivocation(m1.Bytes(), []byte{1, 2, 3})
+	assert.Error(t, err)
+}
@@ -3,8 +3,10 @@ package hare
 import (
 	"errors"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/hare/config"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/tasks"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -66,6 +68,7 @@ type Hare struct {
 	outputChan chan TerminationOutput
 	mu         sync.RWMutex
 	outputs    map[types.LayerID][]types.BlockID
+	running    map[instanceID]*tasks.Task // the consensus processes that haven't output yet
 
 	factory consensusFactory
 
@@ -73,6 +76,12 @@ type Hare struct {
 
 	nid types.NodeID
 
+	sent *sentMessages
+
+	onEquivocation equivocationHandler
+
+	leaders *leaderSelector
+
 	totalCPs int32
 }
 
@@ -88,6 +97,9 @@ func New(conf config.Config, p2p NetworkService, sign Signer, nid types.NodeID,
 	h.Log = logger
 
 	h.config = conf
+	if conf.ListenOnly {
+		logger.Info("hare is listen-only, it validates and records consensus results but never sends messages")
+	}
 
 	h.network = p2p
 	h.beginLayer = beginLayer
@@ -108,9 +120,13 @@ func New(conf config.Config, p2p NetworkService, sign Signer, nid types.NodeID,
 
 	h.outputChan = make(chan TerminationOutput, h.bufferSize)
 	h.outputs = make(map[types.LayerID][]types.BlockID, h.bufferSize) //  we keep results about LayerBuffer past layers
+	h.running = make(map[instanceID]*tasks.Task)
 
 	h.factory = func(conf config.Config, instanceId instanceID, s *Set, oracle Rolacle, signing Signer, p2p NetworkService, terminationReport chan TerminationOutput) Consensus {
-		return newConsensusProcess(conf, instanceId, s, oracle, stateQ, layersPerEpoch, signing, nid, p2p, terminationReport, ev, logger)
+		proc := newConsensusProcess(conf, instanceId, s, oracle, stateQ, layersPerEpoch, signing, nid, p2p, terminationReport, ev, logger)
+		proc.sent = h.sent
+		proc.onEquivocation = h.onEquivocation
+		return proc
 	}
 
 	h.validate = validate
@@ -120,6 +136,26 @@ func New(conf config.Config, p2p NetworkService, sign Signer, nid types.NodeID,
 	return h
 }
 
+// SetMessageStore persists the messages signed by the node's consensus processes to db. A consensus process that is
+// started again for a layer it already signed messages in, e.g. after the node restarted mid-layer, abstains from the
+// layer instead of signing messages that may conflict with the ones it sent before. Must be called before Start.
+func (h *Hare) SetMessageStore(db database.Database) {
+	h.sent = newSentMessages(db)
+}
+
+// SetBlockProvider sets the source of the blocks the leader of a layer is selected from in single block mode, where
+// every node starts the consensus process with its leader candidate and the output is reduced to the leader of the
+// agreed set. Must be called before Start when the hare is configured to single block mode.
+func (h *Hare) SetBlockProvider(blocks blockProvider) {
+	h.leaders = &leaderSelector{blocks: blocks}
+}
+
+// SetEquivocationHandler makes the consensus processes report the equivocations they detect to f, with the two
+// conflicting messages signed by the equivocating identity. Must be called before Start.
+func (h *Hare) SetEquivocationHandler(f func(first, second *Message)) {
+	h.onEquivocation = f
+}
+
 func (h *Hare) getLastLayer() types.LayerID {
 	h.layerLock.RLock()
 	lyr := h.lastLayer
@@ -173,6 +209,12 @@ func (h *Hare) collectOutput(output TerminationOutput) error {
 
 	id := output.ID()
 
+	if h.config.SingleBlock {
+		// nodes may start with different leader candidates, so the agreed set is reduced to a single leader again
+		blocks = h.leaders.Select(blocks)
+		h.With().Info("selected leader block of hare output", log.LayerID(uint64(id)), log.Int("num_blocks", len(blocks)))
+	}
+
 	h.msh.HandleValidatedLayer(types.LayerID(id), blocks)
 
 	if h.outOfBufferRange(id) {
@@ -227,6 +269,9 @@ func (h *Hare) onTick(id types.LayerID) {
 	}
 
 	h.Debug("received %v new blocks ", len(blocks))
+	if h.config.SingleBlock {
+		blocks = h.leaders.Select(blocks)
+	}
 	set := NewEmptySet(len(blocks))
 	for _, b := range blocks {
 		set.Add(b)
@@ -240,10 +285,13 @@ func (h *Hare) onTick(id types.LayerID) {
 	}
 	cp := h.factory(h.config, instID, set, h.rolacle, h.sign, h.network, h.outputChan)
 	cp.SetInbox(c)
+	// counted before the process starts, as it may output before Start returns
+	h.trackInstance(instID, tasks.Start(tasks.HareInstance, id))
 	e := cp.Start()
 	if e != nil {
 		h.Error("Could not start consensus process %v", e.Error())
 		h.broker.Unregister(cp.ID())
+		h.untrackInstance(instID)
 		return
 	}
 	h.With().Info("number of consensus processes", log.Int32("count", atomic.AddInt32(&h.totalCPs, 1)))
@@ -289,6 +337,13 @@ func (h *Hare) outputCollectionLoop() {
 
 			// anyway, unregister from broker
 			h.broker.Unregister(out.ID()) // unregister from broker after termination
+			h.untrackInstance(out.ID())
+			if h.sent != nil && out.ID() >= instanceID(h.bufferSize) {
+				// the layer of the oldest buffered result can't start a consensus process anymore
+				if err := h.sent.Prune(out.ID() - instanceID(h.bufferSize)); err != nil {
+					h.With().Warning("could not prune sent hare messages", log.Err(err))
+				}
+			}
 			h.With().Info("number of consensus processes", log.Int32("count", atomic.AddInt32(&h.totalCPs, -1)))
 			// TODO: fix metrics
 			//metrics.TotalConsensusProcesses.With("layer", strconv.FormatUint(uint64(out.ID()), 10)).Add(-1)
@@ -298,6 +353,21 @@ func (h *Hare) outputCollectionLoop() {
 	}
 }
 
+func (h *Hare) trackInstance(id instanceID, task *tasks.Task) {
+	h.mu.Lock()
+	h.running[id] = task
+	h.mu.Unlock()
+}
+
+// untrackInstance ends the task of the consensus process of id.
+func (h *Hare) untrackInstance(id instanceID) {
+	h.mu.Lock()
+	task := h.running[id]
+	delete(h.running, id)
+	h.mu.Unlock()
+	task.Done()
+}
+
 // listens to new layers.
 func (h *Hare) tickLoop() {
 	for {
@@ -313,6 +383,9 @@ func (h *Hare) tickLoop() {
 // Start starts listening for layers and outputs.
 func (h *Hare) Start() error {
 	h.Log.Info("Starting %v", protoName)
+	if h.config.SingleBlock && h.leaders == nil {
+		return errors.New("single block mode requires a block provider")
+	}
 	err := h.broker.Start()
 	if err != nil {
 		return err
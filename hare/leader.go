@@ -0,0 +1,47 @@
+package hare
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+type blockProvider interface {
+	GetBlock(id types.BlockID) (*types.Block, error)
+}
+
+// leaderSelector selects the leader proposal of a layer in single block mode: the block with the lowest hash of its
+// eligibility VRF signature. Ties are broken by the block ID. Since the VRF signature can't be ground by the miner, the
+// leader is a random block of the layer, and all nodes that know the same blocks select the same leader.
+type leaderSelector struct {
+	blocks blockProvider
+}
+
+// Select returns the leader among ids, as a set of at most one block. Blocks that are not found are skipped.
+func (s *leaderSelector) Select(ids []types.BlockID) []types.BlockID {
+	var leader types.BlockID
+	var leaderHash [sha256.Size]byte
+	found := false
+	for _, id := range ids {
+		block, err := s.blocks.GetBlock(id)
+		if err != nil || block == nil {
+			continue
+		}
+		hash := sha256.Sum256(block.EligibilityProof.Sig)
+		if !found || lessLeader(hash, id, leaderHash, leader) {
+			leader, leaderHash, found = id, hash, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return []types.BlockID{leader}
+}
+
+func lessLeader(hash [sha256.Size]byte, id types.BlockID, otherHash [sha256.Size]byte, other types.BlockID) bool {
+	if c := bytes.Compare(hash[:], otherHash[:]); c != 0 {
+		return c < 0
+	}
+	return id.Compare(other)
+}
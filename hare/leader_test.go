@@ -0,0 +1,83 @@
+package hare
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/stretchr/testify/require"
+)
+
+type blockProviderMock map[types.BlockID]*types.Block
+
+func (m blockProviderMock) GetBlock(id types.BlockID) (*types.Block, error) {
+	if block, ok := m[id]; ok {
+		return block, nil
+	}
+	return nil, errors.New("block not found")
+}
+
+func newBlockWithVRF(layer types.LayerID, data string, vrfSig []byte) *types.Block {
+	block := types.NewExistingBlock(layer, []byte(data))
+	block.EligibilityProof.Sig = vrfSig
+	block.Initialize()
+	return block
+}
+
+// returns whether the hash of sig is lower than the hash of other
+func lowerVRF(sig, other []byte) bool {
+	hash, otherHash := sha256.Sum256(sig), sha256.Sum256(other)
+	return string(hash[:]) < string(otherHash[:])
+}
+
+func TestLeaderSelector_Select(t *testing.T) {
+	r := require.New(t)
+	b1 := newBlockWithVRF(1, "data1", []byte("vrf1"))
+	b2 := newBlockWithVRF(1, "data2", []byte("vrf2"))
+	leader, other := b1, b2
+	if !lowerVRF(b1.EligibilityProof.Sig, b2.EligibilityProof.Sig) {
+		leader, other = b2, b1
+	}
+	selector := &leaderSelector{blocks: blockProviderMock{b1.ID(): b1, b2.ID(): b2}}
+
+	r.Equal([]types.BlockID{leader.ID()}, selector.Select([]types.BlockID{b1.ID(), b2.ID()}))
+	r.Equal([]types.BlockID{leader.ID()}, selector.Select([]types.BlockID{b2.ID(), b1.ID()}))
+	r.Equal([]types.BlockID{other.ID()}, selector.Select([]types.BlockID{other.ID()}))
+
+	// unknown blocks are skipped
+	unknown := newBlockWithVRF(1, "data3", []byte("vrf3"))
+	r.Equal([]types.BlockID{other.ID()}, selector.Select([]types.BlockID{unknown.ID(), other.ID()}))
+	r.Nil(selector.Select([]types.BlockID{unknown.ID()}))
+	r.Nil(selector.Select(nil))
+
+	// equal VRF signatures are broken by the block ID
+	b3 := newBlockWithVRF(1, "data4", []byte("vrf1"))
+	selector = &leaderSelector{blocks: blockProviderMock{b1.ID(): b1, b3.ID(): b3}}
+	expected := b1.ID()
+	if b3.ID().Compare(b1.ID()) {
+		expected = b3.ID()
+	}
+	r.Equal([]types.BlockID{expected}, selector.Select([]types.BlockID{b1.ID(), b3.ID()}))
+}
+
+func TestHare_collectOutputSingleBlock(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	h := createHare(sim.NewNode(), log.NewDefault(t.Name()))
+	h.config.SingleBlock = true
+	r.Error(h.Start())
+
+	b1 := newBlockWithVRF(1, "data1", []byte("vrf1"))
+	b2 := newBlockWithVRF(1, "data2", []byte("vrf2"))
+	h.SetBlockProvider(blockProviderMock{b1.ID(): b1, b2.ID(): b2})
+	expected := h.leaders.Select([]types.BlockID{b1.ID(), b2.ID()})
+	r.Len(expected, 1)
+
+	r.NoError(h.collectOutput(mockReport{instanceID1, NewSetFromValues(b1.ID(), b2.ID()), true}))
+	output, err := h.GetResult(types.LayerID(instanceID1))
+	r.NoError(err)
+	r.Equal(expected, output)
+}
@@ -28,4 +28,36 @@ var (
 		Name:      "total_consensus_processes",
 		Help:      "The total number of current consensus processes running",
 	}, []string{"layer"})
+
+	// EligibilityCacheHits is the number of eligibility checks answered from the cache.
+	EligibilityCacheHits = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "eligibility_cache_hits",
+		Help:      "Number of eligibility checks answered from the cache",
+	}, []string{})
+
+	// EligibilityCacheMisses is the number of eligibility checks that were not cached.
+	EligibilityCacheMisses = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "eligibility_cache_misses",
+		Help:      "Number of eligibility checks that were not cached",
+	}, []string{})
+
+	// EligibilityCacheEvictions is the number of eligibility results evicted from the cache.
+	EligibilityCacheEvictions = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "eligibility_cache_evictions",
+		Help:      "Number of eligibility results evicted from the cache",
+	}, []string{})
+
+	// EligibilityCacheSize is the number of eligibility results in the cache.
+	EligibilityCacheSize = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: Subsystem,
+		Name:      "eligibility_cache_size",
+		Help:      "Number of eligibility results in the cache",
+	}, []string{})
 )
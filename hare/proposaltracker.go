@@ -12,11 +12,15 @@ type proposalTrackerProvider interface {
 	ProposedSet() *Set
 }
 
+// equivocationHandler is notified of two conflicting messages signed by the same identity, it may be nil.
+type equivocationHandler func(first, second *Message)
+
 // proposalTracker tracks proposal messages
 type proposalTracker struct {
 	log.Log
-	proposal      *Msg // maps PubKey->Proposal
-	isConflicting bool // maps PubKey->ConflictStatus
+	proposal       *Msg // maps PubKey->Proposal
+	isConflicting  bool // maps PubKey->ConflictStatus
+	onEquivocation equivocationHandler
 }
 
 func newProposalTracker(log log.Log) *proposalTracker {
@@ -44,6 +48,7 @@ func (pt *proposalTracker) OnProposal(msg *Msg) {
 			pt.With().Info("Equivocation detected on proposal round", log.String("id_malicious", msg.PubKey.String()),
 				log.String("current_set", g.String()), log.String("conflicting_set", s.String()))
 			pt.isConflicting = true
+			pt.reportEquivocation(msg)
 		}
 
 		return // process done
@@ -73,6 +78,7 @@ func (pt *proposalTracker) OnLateProposal(msg *Msg) {
 			pt.With().Info("Equivocation detected for late round", log.String("id_malicious", msg.PubKey.String()),
 				log.String("current_set", g.String()), log.String("conflicting_set", s.String()))
 			pt.isConflicting = true
+			pt.reportEquivocation(msg)
 		}
 	}
 
@@ -84,6 +90,13 @@ func (pt *proposalTracker) OnLateProposal(msg *Msg) {
 	}
 }
 
+// reportEquivocation reports msg and the tracked proposal, which were signed by the same identity.
+func (pt *proposalTracker) reportEquivocation(msg *Msg) {
+	if pt.onEquivocation != nil {
+		pt.onEquivocation(pt.proposal.Message, msg.Message)
+	}
+}
+
 // IsConflicting returns true if there was a conflict, false otherwise.
 func (pt *proposalTracker) IsConflicting() bool {
 	return pt.isConflicting
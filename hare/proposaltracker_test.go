@@ -1,6 +1,7 @@
 package hare
 
 import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -32,6 +33,29 @@ func TestProposalTracker_OnProposalConflict(t *testing.T) {
 	assert.True(t, tracker.IsConflicting())
 }
 
+func TestProposalTracker_ReportsEquivocation(t *testing.T) {
+	verifier := generateSigning(t)
+	m1 := BuildProposalMsg(verifier, NewSetFromValues(value1, value2))
+	m2 := BuildProposalMsg(verifier, NewSetFromValues(value3))
+	tracker := newProposalTracker(log.NewDefault(verifier.PublicKey().String()))
+	var reported []*Message
+	tracker.onEquivocation = func(first, second *Message) {
+		reported = append(reported, first, second)
+	}
+
+	tracker.OnProposal(m1)
+	assert.Empty(t, reported)
+	tracker.OnLateProposal(m2)
+	assert.Len(t, reported, 2)
+	first, err := types.InterfaceToBytes(reported[0])
+	assert.NoError(t, err)
+	second, err := types.InterfaceToBytes(reported[1])
+	assert.NoError(t, err)
+	pub, _, err := VerifyEquivocation(first, second)
+	assert.NoError(t, err)
+	assert.Equal(t, verifier.PublicKey().String(), pub.String())
+}
+
 func TestProposalTracker_IsConflicting(t *testing.T) {
 	s := NewEmptySet(lowDefaultSize)
 	s.Add(value1)
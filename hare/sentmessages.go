@@ -0,0 +1,87 @@
+package hare
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+)
+
+const sentMessagesPrefix = "sent"
+
+// sentLayer is the persisted record of the messages this node signed in the consensus process of a layer.
+type sentLayer struct {
+	Round int32    // the round counter of the last message signed
+	Msgs  [][]byte // the signed messages, in the order they were sent
+}
+
+// sentMessages persists the messages signed by this node before they are broadcast, so that after a restart in the
+// middle of a layer the node knows it already took part in the layer's consensus process and doesn't sign messages
+// that conflict with the ones it sent before the restart.
+type sentMessages struct {
+	mu sync.Mutex
+	db database.Database
+}
+
+func newSentMessages(db database.Database) *sentMessages {
+	return &sentMessages{db: db}
+}
+
+func sentLayerKey(id instanceID) []byte {
+	return append([]byte(sentMessagesPrefix), id.Bytes()...)
+}
+
+func (s *sentMessages) get(id instanceID) (*sentLayer, error) {
+	bts, err := s.db.Get(sentLayerKey(id))
+	if err == database.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec sentLayer
+	if err := types.BytesToInterface(bts, &rec); err != nil {
+		return nil, fmt.Errorf("could not unmarshal sent messages of layer %v: %v", id, err)
+	}
+	return &rec, nil
+}
+
+// Record persists msg as signed by this node in round k of the consensus process of layer id.
+func (s *sentMessages) Record(id instanceID, k int32, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		rec = &sentLayer{}
+	}
+	rec.Round = k
+	rec.Msgs = append(rec.Msgs, msg)
+	bts, err := types.InterfaceToBytes(rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(sentLayerKey(id), bts)
+}
+
+// Participated returns the last round this node signed a message in for layer id, and false if it didn't sign any
+// message for the layer.
+func (s *sentMessages) Participated(id instanceID) (int32, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.get(id)
+	if err != nil || rec == nil {
+		return 0, false, err
+	}
+	return rec.Round, true, nil
+}
+
+// Prune deletes the record of layer id.
+func (s *sentMessages) Prune(id instanceID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Delete(sentLayerKey(id))
+}
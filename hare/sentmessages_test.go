@@ -0,0 +1,40 @@
+package hare
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentMessages(t *testing.T) {
+	r := require.New(t)
+	db := database.NewMemDatabase()
+	sent := newSentMessages(db)
+
+	_, participated, err := sent.Participated(instanceID1)
+	r.NoError(err)
+	r.False(participated)
+
+	r.NoError(sent.Record(instanceID1, -1, []byte{1}))
+	r.NoError(sent.Record(instanceID1, 3, []byte{2}))
+	r.NoError(sent.Record(instanceID2, 0, []byte{3}))
+
+	// survives a restart
+	sent = newSentMessages(db)
+	round, participated, err := sent.Participated(instanceID1)
+	r.NoError(err)
+	r.True(participated)
+	r.Equal(int32(3), round)
+	rec, err := sent.get(instanceID1)
+	r.NoError(err)
+	r.Equal([][]byte{{1}, {2}}, rec.Msgs)
+
+	r.NoError(sent.Prune(instanceID1))
+	_, participated, err = sent.Participated(instanceID1)
+	r.NoError(err)
+	r.False(participated)
+	_, participated, err = sent.Participated(instanceID2)
+	r.NoError(err)
+	r.True(participated)
+}
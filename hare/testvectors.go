@@ -0,0 +1,32 @@
+package hare
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// TestVectorMessages returns a message of each type, signed by signer and encoded as it's broadcast, keyed by the name
+// of the message type. It's used to generate the test vectors of external client implementations, so the fields are
+// fixed and the role proofs aren't valid VRF signatures.
+func TestVectorMessages(signer Signer) map[string][]byte {
+	values := NewSetFromValues(types.BlockID{1}, types.BlockID{2}, types.BlockID{3})
+	build := func(mType messageType, k, ki int32) *messageBuilder {
+		return newMessageBuilder().SetType(mType).SetInstanceID(instanceID(10)).SetRoundCounter(k).SetKi(ki).
+			SetValues(values).SetRoleProof([]byte{byte(mType), 1, 2, 3})
+	}
+	statusMsg := build(status, statusRound, preRound).Sign(signer).Build()
+	commitMsg := build(commit, commitRound, statusRound).Sign(signer).Build()
+	svp := &aggregatedMessages{Messages: []*Message{statusMsg.Message}}
+	cert := &certificate{Values: values.ToSlice(), AggMsgs: &aggregatedMessages{Messages: []*Message{commitMsg.Message}}}
+	msgs := map[string]*Msg{
+		pre.String():      build(pre, preRound, preRound).Sign(signer).Build(),
+		status.String():   statusMsg,
+		proposal.String(): build(proposal, proposalRound, statusRound).SetSVP(svp).Sign(signer).Build(),
+		commit.String():   commitMsg,
+		notify.String():   build(notify, notifyRound, statusRound).SetCertificate(cert).Sign(signer).Build(),
+	}
+	res := make(map[string][]byte, len(msgs))
+	for name, msg := range msgs {
+		res[name] = msg.Bytes()
+	}
+	return res
+}
@@ -0,0 +1,188 @@
+// Package layercache keeps the execution results of the latest layers applied to state in memory, so that the API can
+// serve recent activity without reading the state and the mesh databases.
+package layercache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// DefaultSize is the default number of layers whose results are cached.
+const DefaultSize = 50
+
+// ErrNotCached is returned when the results of a layer aren't in the cache.
+var ErrNotCached = errors.New("layer results are not cached")
+
+type meshSource interface {
+	LayerTransactions(layer *types.Layer) []*types.Transaction
+	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
+	GetLayerReward(l types.LayerID, account types.Address) (types.Reward, error)
+}
+
+type stateSource interface {
+	GetBalance(addr types.Address) uint64
+	GetNonce(addr types.Address) uint64
+	GetLayerApplied(txID types.TransactionID) *types.LayerID
+	GetStateRoot() types.Hash32
+}
+
+// TxResult is the result of a transaction of a layer.
+type TxResult struct {
+	Tx      *types.Transaction
+	Applied bool // false if the transaction failed to apply, e.g. on its nonce or its balance
+}
+
+// Reward is the reward of a coinbase account in a layer.
+type Reward struct {
+	Coinbase            types.Address
+	TotalReward         uint64
+	LayerRewardEstimate uint64
+}
+
+// AccountDiff is the state of an account that a layer changed, at the end of the layer.
+type AccountDiff struct {
+	Address types.Address
+	Balance uint64
+	Nonce   uint64
+}
+
+// LayerResults are the execution results of a layer.
+type LayerResults struct {
+	Layer     types.LayerID
+	StateRoot types.Hash32
+	Txs       []TxResult // in the order they were applied
+	Rewards   []Reward
+	Accounts  []AccountDiff // the accounts of transaction origins and recipients and of rewarded coinbases
+}
+
+// Cache keeps the results of the latest layers applied to state. It must be added as a state observer of the mesh (see
+// mesh.Mesh.AddStateObserver).
+type Cache struct {
+	size   int
+	mesh   meshSource
+	state  stateSource
+	mu     sync.RWMutex
+	layers map[types.LayerID]*LayerResults
+	log    log.Log
+}
+
+// New returns a cache of the results of the latest size layers.
+func New(size int, msh meshSource, st stateSource, logger log.Log) *Cache {
+	return &Cache{
+		size:   size,
+		mesh:   msh,
+		state:  st,
+		layers: make(map[types.LayerID]*LayerResults),
+		log:    logger,
+	}
+}
+
+// LayerApplied caches the results of a layer that was applied to state. The state must not change before it returns.
+// When a layer is applied again after a rollback, the results of the layers after it are dropped.
+func (c *Cache) LayerApplied(layer *types.Layer) {
+	results, err := c.build(layer)
+	if err != nil {
+		c.log.With().Error("failed to build layer results", log.LayerID(layer.Index().Uint64()), log.Err(err))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for lyr := range c.layers {
+		if lyr >= layer.Index() || lyr+types.LayerID(c.size) <= layer.Index() {
+			delete(c.layers, lyr)
+		}
+	}
+	if results != nil {
+		c.layers[layer.Index()] = results
+	}
+}
+
+// LayersReverted drops the results of the layers from layer from on, which were rolled back.
+func (c *Cache) LayersReverted(from types.LayerID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for lyr := range c.layers {
+		if lyr >= from {
+			delete(c.layers, lyr)
+		}
+	}
+}
+
+func (c *Cache) build(layer *types.Layer) (*LayerResults, error) {
+	results := &LayerResults{Layer: layer.Index(), StateRoot: c.state.GetStateRoot()}
+	touched := make(map[types.Address]struct{})
+
+	for _, tx := range c.mesh.LayerTransactions(layer) {
+		applied := c.state.GetLayerApplied(tx.ID())
+		results.Txs = append(results.Txs, TxResult{Tx: tx, Applied: applied != nil && *applied == layer.Index()})
+		touched[tx.Origin()] = struct{}{}
+		touched[tx.Recipient] = struct{}{}
+	}
+
+	rewarded := make(map[types.Address]struct{})
+	for _, blk := range layer.Blocks() {
+		if blk.ATXID == *types.EmptyATXID {
+			continue
+		}
+		atx, err := c.mesh.GetAtxHeader(blk.ATXID)
+		if err != nil {
+			// the mesh doesn't reward blocks whose ATX it can't find
+			continue
+		}
+		if _, ok := rewarded[atx.Coinbase]; ok {
+			continue
+		}
+		rewarded[atx.Coinbase] = struct{}{}
+		reward, err := c.mesh.GetLayerReward(layer.Index(), atx.Coinbase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reward of %v: %v", atx.Coinbase.Short(), err)
+		}
+		results.Rewards = append(results.Rewards, Reward{
+			Coinbase:            atx.Coinbase,
+			TotalReward:         reward.TotalReward,
+			LayerRewardEstimate: reward.LayerRewardEstimate,
+		})
+		touched[atx.Coinbase] = struct{}{}
+	}
+
+	for addr := range touched {
+		results.Accounts = append(results.Accounts, AccountDiff{
+			Address: addr,
+			Balance: c.state.GetBalance(addr),
+			Nonce:   c.state.GetNonce(addr),
+		})
+	}
+	sort.Slice(results.Accounts, func(i, j int) bool {
+		return bytes.Compare(results.Accounts[i].Address.Bytes(), results.Accounts[j].Address.Bytes()) < 0
+	})
+	return results, nil
+}
+
+// Get returns the results of layer, or ErrNotCached if they aren't in the cache.
+func (c *Cache) Get(layer types.LayerID) (*LayerResults, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	results, ok := c.layers[layer]
+	if !ok {
+		return nil, ErrNotCached
+	}
+	return results, nil
+}
+
+// Layers returns the layers whose results are cached, in ascending order.
+func (c *Cache) Layers() []types.LayerID {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	layers := make([]types.LayerID, 0, len(c.layers))
+	for lyr := range c.layers {
+		layers = append(layers, lyr)
+	}
+	sort.Slice(layers, func(i, j int) bool { return layers[i] < layers[j] })
+	return layers
+}
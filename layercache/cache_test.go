@@ -0,0 +1,141 @@
+package layercache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+var errNotFound = errors.New("not found")
+
+type meshMock struct {
+	txs     map[types.LayerID][]*types.Transaction
+	atxs    map[types.ATXID]*types.ActivationTxHeader
+	rewards map[types.Address]types.Reward
+}
+
+func (m *meshMock) LayerTransactions(layer *types.Layer) []*types.Transaction {
+	return m.txs[layer.Index()]
+}
+
+func (m *meshMock) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error) {
+	if atx, ok := m.atxs[id]; ok {
+		return atx, nil
+	}
+	return nil, errNotFound
+}
+
+func (m *meshMock) GetLayerReward(l types.LayerID, account types.Address) (types.Reward, error) {
+	if reward, ok := m.rewards[account]; ok {
+		reward.Layer = l
+		return reward, nil
+	}
+	return types.Reward{}, errNotFound
+}
+
+type stateMock struct {
+	balances map[types.Address]uint64
+	nonces   map[types.Address]uint64
+	applied  map[types.TransactionID]types.LayerID
+}
+
+func (s *stateMock) GetBalance(addr types.Address) uint64 { return s.balances[addr] }
+func (s *stateMock) GetNonce(addr types.Address) uint64   { return s.nonces[addr] }
+func (s *stateMock) GetStateRoot() types.Hash32           { return types.Hash32{1} }
+
+func (s *stateMock) GetLayerApplied(txID types.TransactionID) *types.LayerID {
+	if layer, ok := s.applied[txID]; ok {
+		return &layer
+	}
+	return nil
+}
+
+func newTx(t *testing.T, signer *signing.EdSigner, nonce uint64, recipient types.Address) *types.Transaction {
+	tx, err := mesh.NewSignedTx(nonce, recipient, 10, 1, 1, signer)
+	require.NoError(t, err)
+	return tx
+}
+
+func newLayer(layer types.LayerID, atxs ...types.ATXID) *types.Layer {
+	var blocks []*types.Block
+	for _, atx := range atxs {
+		blk := types.NewExistingBlock(layer, []byte("data"))
+		blk.ATXID = atx
+		blocks = append(blocks, blk)
+	}
+	return types.NewExistingLayer(layer, blocks)
+}
+
+func TestCache_LayerApplied(t *testing.T) {
+	r := require.New(t)
+	signer := signing.NewEdSigner()
+	origin := types.BytesToAddress(signer.PublicKey().Bytes())
+	recipient, coinbase := types.BytesToAddress([]byte{1}), types.BytesToAddress([]byte{2})
+	applied, failed := newTx(t, signer, 0, recipient), newTx(t, signer, 5, recipient)
+
+	msh := &meshMock{
+		txs:     map[types.LayerID][]*types.Transaction{3: {applied, failed}},
+		atxs:    map[types.ATXID]*types.ActivationTxHeader{{1}: {Coinbase: coinbase}},
+		rewards: map[types.Address]types.Reward{coinbase: {TotalReward: 60, LayerRewardEstimate: 50}},
+	}
+	st := &stateMock{
+		balances: map[types.Address]uint64{origin: 89, recipient: 10, coinbase: 60},
+		nonces:   map[types.Address]uint64{origin: 1},
+		applied:  map[types.TransactionID]types.LayerID{applied.ID(): 3, failed.ID(): 1},
+	}
+	c := New(DefaultSize, msh, st, log.NewDefault(t.Name()))
+
+	_, err := c.Get(3)
+	r.Equal(ErrNotCached, err)
+
+	// two blocks of the same coinbase and a block whose ATX is unknown
+	c.LayerApplied(newLayer(3, types.ATXID{1}, types.ATXID{1}, types.ATXID{2}))
+	results, err := c.Get(3)
+	r.NoError(err)
+	r.Equal(types.LayerID(3), results.Layer)
+	r.Equal(types.Hash32{1}, results.StateRoot)
+	r.Equal([]TxResult{{Tx: applied, Applied: true}, {Tx: failed, Applied: false}}, results.Txs)
+	r.Equal([]Reward{{Coinbase: coinbase, TotalReward: 60, LayerRewardEstimate: 50}}, results.Rewards)
+	r.ElementsMatch([]AccountDiff{
+		{Address: origin, Balance: 89, Nonce: 1},
+		{Address: recipient, Balance: 10},
+		{Address: coinbase, Balance: 60},
+	}, results.Accounts)
+
+	// a missing reward fails the layer, which isn't cached
+	delete(msh.rewards, coinbase)
+	c.LayerApplied(newLayer(4, types.ATXID{1}))
+	_, err = c.Get(4)
+	r.Equal(ErrNotCached, err)
+}
+
+func TestCache_Eviction(t *testing.T) {
+	r := require.New(t)
+	c := New(3, &meshMock{}, &stateMock{}, log.NewDefault(t.Name()))
+	for i := types.LayerID(1); i <= 5; i++ {
+		c.LayerApplied(newLayer(i))
+	}
+	r.Equal([]types.LayerID{3, 4, 5}, c.Layers())
+
+	// the layers after a layer that is applied again were rolled back
+	c.LayerApplied(newLayer(4))
+	r.Equal([]types.LayerID{3, 4}, c.Layers())
+}
+
+func TestCache_LayersReverted(t *testing.T) {
+	r := require.New(t)
+	c := New(10, &meshMock{}, &stateMock{}, log.NewDefault(t.Name()))
+	for i := types.LayerID(1); i <= 5; i++ {
+		c.LayerApplied(newLayer(i))
+	}
+	c.LayersReverted(3)
+	r.Equal([]types.LayerID{1, 2}, c.Layers())
+	_, err := c.Get(3)
+	r.Equal(ErrNotCached, err)
+}
@@ -0,0 +1,25 @@
+package log
+
+import "context"
+
+type fieldsKey struct{}
+
+// ContextWithFields returns a copy of ctx that carries fields, in addition to the fields ctx already carries. It's
+// meant to be created where the processing of an object starts, e.g. with the layer and epoch being processed, and
+// passed along to the modules taking part in it.
+func ContextWithFields(ctx context.Context, fields ...LoggableField) context.Context {
+	carried, _ := ctx.Value(fieldsKey{}).([]LoggableField)
+	all := make([]LoggableField, 0, len(carried)+len(fields))
+	all = append(append(all, carried...), fields...)
+	return context.WithValue(ctx, fieldsKey{}, all)
+}
+
+// WithContext returns a logger with the fields carried by ctx appended to it, so that all the logs of a processing
+// scope can be correlated across modules.
+func (l Log) WithContext(ctx context.Context) Log {
+	fields, _ := ctx.Value(fieldsKey{}).([]LoggableField)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.WithFields(fields...)
+}
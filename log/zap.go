@@ -115,7 +115,7 @@ func AtxID(val string) Field {
 	return String("atx_id", val)
 }
 
-// BlockID returns a Uint64 field (key - "block_id")
+// BlockID returns a String field (key - "block_id")
 func BlockID(val string) Field {
 	return String("block_id", val)
 }
@@ -130,6 +130,11 @@ func NodeID(val string) Field {
 	return String("node_id", val)
 }
 
+// PeerID returns a String field (key - "peer_id")
+func PeerID(val string) Field {
+	return String("peer_id", val)
+}
+
 // Err returns an error field
 func Err(v error) Field {
 	return Field(zap.NamedError("errmsg", v))
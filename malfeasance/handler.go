@@ -0,0 +1,123 @@
+package malfeasance
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/priorityq"
+)
+
+// Protocol is the name of the malfeasance proof gossip protocol.
+const Protocol = "MalfeasanceProof"
+
+type proofVerifier interface {
+	Verify(proof *Proof) (string, error)
+}
+
+type proofStore interface {
+	StoreProof(nodeID string, proof *Proof) (bool, error)
+}
+
+// Handler handles malfeasance proof gossip messages. Valid proofs are persisted and propagated, and the convicted
+// identity is reported to the store so that the oracles can exclude it.
+type Handler struct {
+	Log      log.Log
+	net      service.Service
+	verifier proofVerifier
+	store    proofStore
+	proofs   chan service.GossipMessage
+	started  bool
+	exit     chan struct{}
+}
+
+// NewHandler returns a new Handler.
+func NewHandler(net service.Service, verifier proofVerifier, store proofStore, logger log.Log) *Handler {
+	return &Handler{
+		Log:      logger,
+		net:      net,
+		verifier: verifier,
+		store:    store,
+		proofs:   net.RegisterGossipProtocol(Protocol, priorityq.Low),
+		exit:     make(chan struct{}),
+	}
+}
+
+// Start starts listening to malfeasance proof gossip messages.
+func (h *Handler) Start() {
+	if h.started {
+		return
+	}
+	go h.loop()
+	h.started = true
+}
+
+// Close performs graceful shutdown of the malfeasance handler.
+func (h *Handler) Close() {
+	close(h.exit)
+	h.started = false
+}
+
+func (h *Handler) loop() {
+	for {
+		select {
+		case msg := <-h.proofs:
+			if msg == nil {
+				h.Log.Error("nil malfeasance message received!")
+				continue
+			}
+			go h.handleProofMessage(msg)
+		case <-h.exit:
+			h.Log.Info("listening stopped")
+			return
+		}
+	}
+}
+
+func (h *Handler) handleProofMessage(gossipMessage service.GossipMessage) {
+	// like PoET proofs, malfeasance proofs must be propagated regardless of whether the node is synced
+	var proof Proof
+	if err := types.BytesToInterface(gossipMessage.Bytes(), &proof); err != nil {
+		h.Log.Error("failed to unmarshal malfeasance proof: %v", err)
+		return
+	}
+	nodeID, err := h.verifier.Verify(&proof)
+	if err != nil {
+		h.Log.With().Warning("malfeasance proof not valid", log.String("proof_type", proof.Type.String()), log.Err(err))
+		return
+	}
+	isNew, err := h.store.StoreProof(nodeID, &proof)
+	if err != nil {
+		h.Log.Error("failed to store malfeasance proof: %v", err)
+		return
+	}
+	if !isNew {
+		// the identity was already convicted, there is no need to propagate another proof
+		return
+	}
+	h.Log.With().Warning("identity convicted of malfeasance", log.String("id_malicious", nodeID),
+		log.String("proof_type", proof.Type.String()))
+	gossipMessage.ReportValidation(Protocol)
+}
+
+// Publish verifies, stores and broadcasts a proof built by this node, e.g. after it detected two conflicting messages
+// signed by the same identity.
+func (h *Handler) Publish(proof *Proof) error {
+	nodeID, err := h.verifier.Verify(proof)
+	if err != nil {
+		return fmt.Errorf("invalid malfeasance proof: %v", err)
+	}
+	isNew, err := h.store.StoreProof(nodeID, proof)
+	if err != nil {
+		return err
+	}
+	if !isNew {
+		return nil
+	}
+	bytes, err := types.InterfaceToBytes(proof)
+	if err != nil {
+		return fmt.Errorf("failed to serialize malfeasance proof: %v", err)
+	}
+	return h.net.Broadcast(Protocol, bytes)
+}
@@ -0,0 +1,162 @@
+package malfeasance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const layersPerEpoch = 3
+
+func signedBlock(sgn *signing.EdSigner, layer types.LayerID, j uint32, data []byte) *types.Block {
+	blk := types.NewExistingBlock(layer, data)
+	blk.EligibilityProof.J = j
+	blk.Signature = sgn.Sign(blk.Bytes())
+	blk.Initialize()
+	return blk
+}
+
+func signedAtx(t *testing.T, sgn *signing.EdSigner, pubLayer types.LayerID, sequence uint64) *types.ActivationTx {
+	challenge := types.NIPSTChallenge{
+		NodeID:     types.NodeID{Key: sgn.PublicKey().String(), VRFPublicKey: []byte("vrf")},
+		Sequence:   sequence,
+		PubLayerID: pubLayer,
+	}
+	nipst := activation.NewNIPSTWithChallenge(&types.Hash32{}, []byte("poet"))
+	atx := types.NewActivationTx(challenge, types.Address{}, 0, nil, nipst, nil)
+	require.NoError(t, activation.SignAtx(sgn, atx))
+	atx.CalcAndSetID()
+	return atx
+}
+
+func TestVerifier_MultipleBlocks(t *testing.T) {
+	sgn := signing.NewEdSigner()
+	v := NewVerifier(layersPerEpoch)
+
+	proof, err := NewProof(MultipleBlocks, signedBlock(sgn, 5, 1, []byte("a")), signedBlock(sgn, 5, 1, []byte("b")))
+	require.NoError(t, err)
+	id, err := v.Verify(proof)
+	require.NoError(t, err)
+	assert.Equal(t, sgn.PublicKey().String(), id)
+
+	// different eligibility
+	proof, err = NewProof(MultipleBlocks, signedBlock(sgn, 5, 1, []byte("a")), signedBlock(sgn, 5, 2, []byte("b")))
+	require.NoError(t, err)
+	_, err = v.Verify(proof)
+	assert.Error(t, err)
+
+	// different miners
+	proof, err = NewProof(MultipleBlocks, signedBlock(sgn, 5, 1, []byte("a")),
+		signedBlock(signing.NewEdSigner(), 5, 1, []byte("b")))
+	require.NoError(t, err)
+	_, err = v.Verify(proof)
+	assert.Error(t, err)
+
+	// same block
+	blk := signedBlock(sgn, 5, 1, []byte("a"))
+	proof, err = NewProof(MultipleBlocks, blk, blk)
+	require.NoError(t, err)
+	_, err = v.Verify(proof)
+	assert.Error(t, err)
+}
+
+func TestVerifier_MultipleATXs(t *testing.T) {
+	sgn := signing.NewEdSigner()
+	v := NewVerifier(layersPerEpoch)
+
+	proof, err := NewProof(MultipleATXs, signedAtx(t, sgn, 3, 1), signedAtx(t, sgn, 4, 2))
+	require.NoError(t, err)
+	id, err := v.Verify(proof)
+	require.NoError(t, err)
+	assert.Equal(t, sgn.PublicKey().String(), id)
+
+	// different epochs
+	proof, err = NewProof(MultipleATXs, signedAtx(t, sgn, 3, 1), signedAtx(t, sgn, 6, 2))
+	require.NoError(t, err)
+	_, err = v.Verify(proof)
+	assert.Error(t, err)
+
+	// not signed by the publisher
+	forged := signedAtx(t, sgn, 4, 2)
+	require.NoError(t, activation.SignAtx(signing.NewEdSigner(), forged))
+	proof, err = NewProof(MultipleATXs, signedAtx(t, sgn, 3, 1), forged)
+	require.NoError(t, err)
+	_, err = v.Verify(proof)
+	assert.Error(t, err)
+
+	proof.Type = ProofType(42)
+	_, err = v.Verify(proof)
+	assert.Error(t, err)
+}
+
+func TestStore(t *testing.T) {
+	db := database.NewMemDatabase()
+	s, err := NewStore(db)
+	require.NoError(t, err)
+	proof := &Proof{Type: MultipleBlocks, First: []byte{1}, Second: []byte{2}}
+
+	assert.False(t, s.IsMalicious("aaaa"))
+	_, err = s.GetProof("aaaa")
+	assert.Equal(t, database.ErrNotFound, err)
+
+	isNew, err := s.StoreProof("aaaa", proof)
+	require.NoError(t, err)
+	assert.True(t, isNew)
+	assert.True(t, s.IsMalicious("aaaa"))
+	assert.False(t, s.IsMalicious("bbbb"))
+
+	isNew, err = s.StoreProof("aaaa", &Proof{Type: HareEquivocation})
+	require.NoError(t, err)
+	assert.False(t, isNew)
+
+	// a new store on the same db recovers the convicted identities
+	s, err = NewStore(db)
+	require.NoError(t, err)
+	assert.True(t, s.IsMalicious("aaaa"))
+	got, err := s.GetProof("aaaa")
+	require.NoError(t, err)
+	assert.Equal(t, proof, got)
+}
+
+func TestHandler_PropagatesValidProofs(t *testing.T) {
+	sim := service.NewSimulator()
+	n1, n2 := sim.NewNode(), sim.NewNode()
+
+	s1, err := NewStore(database.NewMemDatabase())
+	require.NoError(t, err)
+	h1 := NewHandler(n1, NewVerifier(layersPerEpoch), s1, log.NewDefault("malfeasance1"))
+	h1.Start()
+	defer h1.Close()
+	s2, err := NewStore(database.NewMemDatabase())
+	require.NoError(t, err)
+	h2 := NewHandler(n2, NewVerifier(layersPerEpoch), s2, log.NewDefault("malfeasance2"))
+	h2.Start()
+	defer h2.Close()
+
+	sgn := signing.NewEdSigner()
+	bad, err := NewProof(MultipleBlocks, signedBlock(sgn, 5, 1, []byte("a")), signedBlock(sgn, 5, 2, []byte("b")))
+	require.NoError(t, err)
+	assert.Error(t, h1.Publish(bad))
+
+	proof, err := NewProof(MultipleBlocks, signedBlock(sgn, 5, 1, []byte("a")), signedBlock(sgn, 5, 1, []byte("b")))
+	require.NoError(t, err)
+	require.NoError(t, h1.Publish(proof))
+	assert.True(t, s1.IsMalicious(sgn.PublicKey().String()))
+
+	timeout := time.After(time.Second)
+	for !s2.IsMalicious(sgn.PublicKey().String()) {
+		select {
+		case <-timeout:
+			t.Fatal("proof was not received by the other node")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
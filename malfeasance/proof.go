@@ -0,0 +1,170 @@
+// Package malfeasance defines proofs that an identity misbehaved, the gossip protocol used to propagate them and the
+// store that keeps track of convicted identities.
+package malfeasance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hare"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// ProofType identifies the kind of misbehavior that a Proof convicts an identity of.
+type ProofType uint8
+
+const (
+	// MultipleATXs proves that an identity published two different ATXs in the same epoch.
+	MultipleATXs ProofType = iota + 1
+	// MultipleBlocks proves that an identity produced two different blocks using the same eligibility.
+	MultipleBlocks
+	// HareEquivocation proves that an identity sent two different hare messages for the same instance and round.
+	HareEquivocation
+)
+
+func (t ProofType) String() string {
+	switch t {
+	case MultipleATXs:
+		return "multiple ATXs"
+	case MultipleBlocks:
+		return "multiple blocks"
+	case HareEquivocation:
+		return "hare equivocation"
+	default:
+		return fmt.Sprintf("unknown (%d)", uint8(t))
+	}
+}
+
+// Proof is a proof of malfeasance. It consists of two conflicting messages signed by the same identity. The
+// serialization of the messages depends on the proof type: ATXs, blocks or hare messages.
+type Proof struct {
+	Type   ProofType
+	First  []byte
+	Second []byte
+}
+
+// NewProof serializes the two conflicting objects into a new Proof of the given type.
+func NewProof(proofType ProofType, first, second interface{}) (*Proof, error) {
+	b1, err := types.InterfaceToBytes(first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize first message: %v", err)
+	}
+	b2, err := types.InterfaceToBytes(second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize second message: %v", err)
+	}
+	return &Proof{Type: proofType, First: b1, Second: b2}, nil
+}
+
+// Verifier validates malfeasance proofs.
+type Verifier struct {
+	layersPerEpoch uint16
+}
+
+// NewVerifier returns a new Verifier.
+func NewVerifier(layersPerEpoch uint16) *Verifier {
+	return &Verifier{layersPerEpoch: layersPerEpoch}
+}
+
+// Verify checks that the proof is valid. It returns the ed25519 public key (as a string) of the convicted identity
+// or an error that explains why the proof is invalid.
+func (v *Verifier) Verify(proof *Proof) (string, error) {
+	switch proof.Type {
+	case MultipleATXs:
+		return v.verifyAtxs(proof.First, proof.Second)
+	case MultipleBlocks:
+		return v.verifyBlocks(proof.First, proof.Second)
+	case HareEquivocation:
+		pub, _, err := hare.VerifyEquivocation(proof.First, proof.Second)
+		if err != nil {
+			return "", err
+		}
+		return pub.String(), nil
+	default:
+		return "", fmt.Errorf("unknown proof type %v", proof.Type)
+	}
+}
+
+func (v *Verifier) verifyAtxs(first, second []byte) (string, error) {
+	atx1, err := types.BytesToAtx(first)
+	if err != nil {
+		return "", fmt.Errorf("could not parse first ATX: %v", err)
+	}
+	atx2, err := types.BytesToAtx(second)
+	if err != nil {
+		return "", fmt.Errorf("could not parse second ATX: %v", err)
+	}
+	atx1.CalcAndSetID()
+	atx2.CalcAndSetID()
+	if atx1.ID() == atx2.ID() {
+		return "", errors.New("ATXs are identical")
+	}
+	if atx1.NodeID.Key != atx2.NodeID.Key {
+		return "", fmt.Errorf("ATXs were published by different identities (%v) (%v)",
+			atx1.NodeID.ShortString(), atx2.NodeID.ShortString())
+	}
+	if e1, e2 := atx1.PubLayerID.GetEpoch(v.layersPerEpoch), atx2.PubLayerID.GetEpoch(v.layersPerEpoch); e1 != e2 {
+		return "", fmt.Errorf("ATXs were published in different epochs (%v) (%v)", e1, e2)
+	}
+	for _, atx := range []*types.ActivationTx{atx1, atx2} {
+		pub, err := extractAtxPublicKey(atx)
+		if err != nil {
+			return "", fmt.Errorf("could not extract public key of ATX %v: %v", atx.ShortString(), err)
+		}
+		if pub.String() != atx.NodeID.Key {
+			return "", fmt.Errorf("ATX %v is not signed by its publisher", atx.ShortString())
+		}
+	}
+	return atx1.NodeID.Key, nil
+}
+
+// extractAtxPublicKey extracts the key that signed atx. ATXs published after the header-first-atx-gossip upgrade sign
+// the hash of their NIPST instead of the NIPST, the key of that scheme is returned if the other one isn't the
+// publisher's.
+func extractAtxPublicKey(atx *types.ActivationTx) (*signing.PublicKey, error) {
+	pub, err := activation.ExtractPublicKey(atx)
+	if (err == nil && pub.String() == atx.NodeID.Key) || atx.Nipst == nil {
+		return pub, err
+	}
+	nipstHash, err := atx.Nipst.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return activation.ExtractHeaderPublicKey(atx, nipstHash)
+}
+
+func (v *Verifier) verifyBlocks(first, second []byte) (string, error) {
+	var blk1, blk2 types.Block
+	if err := types.BytesToInterface(first, &blk1); err != nil {
+		return "", fmt.Errorf("could not parse first block: %v", err)
+	}
+	if err := types.BytesToInterface(second, &blk2); err != nil {
+		return "", fmt.Errorf("could not parse second block: %v", err)
+	}
+	if blk1.LayerIndex != blk2.LayerIndex || blk1.EligibilityProof.J != blk2.EligibilityProof.J {
+		return "", fmt.Errorf("blocks do not share the same layer and eligibility (%v, %v) (%v, %v)",
+			blk1.LayerIndex, blk1.EligibilityProof.J, blk2.LayerIndex, blk2.EligibilityProof.J)
+	}
+
+	bytes1, bytes2 := blk1.Bytes(), blk2.Bytes()
+	if bytes.Equal(bytes1, bytes2) {
+		return "", errors.New("blocks are identical")
+	}
+	pub1, err := ed25519.ExtractPublicKey(bytes1, blk1.Signature)
+	if err != nil {
+		return "", fmt.Errorf("could not extract public key of first block: %v", err)
+	}
+	pub2, err := ed25519.ExtractPublicKey(bytes2, blk2.Signature)
+	if err != nil {
+		return "", fmt.Errorf("could not extract public key of second block: %v", err)
+	}
+	if !bytes.Equal(pub1, pub2) {
+		return "", errors.New("blocks were produced by different identities")
+	}
+	blk1.Initialize()
+	return blk1.MinerID().String(), nil
+}
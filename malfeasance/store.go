@@ -0,0 +1,87 @@
+package malfeasance
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+)
+
+const proofPrefix = "m_"
+
+func getProofKey(nodeID string) []byte {
+	return []byte(fmt.Sprintf("%v%v", proofPrefix, nodeID))
+}
+
+// Store persists malfeasance proofs, keyed by the convicted identity. Only the first proof received for an identity
+// is kept, since a single proof is enough to convict it. The convicted identities are also kept in memory, so that
+// checking an identity doesn't read the db.
+type Store struct {
+	db        database.Database
+	lock      sync.Mutex
+	malicious map[string]struct{}
+}
+
+// NewStore returns a new Store, with the identities convicted by the proofs in db.
+func NewStore(db database.Database) (*Store, error) {
+	s := &Store{
+		db:        db,
+		malicious: make(map[string]struct{}),
+	}
+	it := db.Find([]byte(proofPrefix))
+	defer it.Release()
+	for it.Next() {
+		s.malicious[string(it.Key()[len(proofPrefix):])] = struct{}{}
+	}
+	if err := it.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read convicted identities: %v", err)
+	}
+	return s, nil
+}
+
+// StoreProof stores the proof that convicted nodeID. It returns false if the identity was already convicted, in which
+// case the proof is not stored.
+func (s *Store) StoreProof(nodeID string, proof *Proof) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.isMalicious(nodeID) {
+		return false, nil
+	}
+	bytes, err := types.InterfaceToBytes(proof)
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize proof: %v", err)
+	}
+	if err := s.db.Put(getProofKey(nodeID), bytes); err != nil {
+		return false, fmt.Errorf("failed to store proof: %v", err)
+	}
+	s.malicious[nodeID] = struct{}{}
+	return true, nil
+}
+
+// GetProof returns the proof that convicted nodeID, or database.ErrNotFound if the identity was not convicted.
+func (s *Store) GetProof(nodeID string) (*Proof, error) {
+	bytes, err := s.db.Get(getProofKey(nodeID))
+	if err != nil {
+		return nil, err
+	}
+	var proof Proof
+	if err := types.BytesToInterface(bytes, &proof); err != nil {
+		return nil, fmt.Errorf("failed to deserialize proof: %v", err)
+	}
+	return &proof, nil
+}
+
+// IsMalicious returns true if a valid malfeasance proof was received for nodeID.
+func (s *Store) IsMalicious(nodeID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.isMalicious(nodeID)
+}
+
+func (s *Store) isMalicious(nodeID string) bool {
+	_, ok := s.malicious[nodeID]
+	return ok
+}
@@ -0,0 +1,107 @@
+// Package membudget divides a global memory budget between the node's caches, so that their sizes are set by a
+// single limit instead of each cache's own hard-coded size.
+package membudget
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// The caches that register with the budget.
+const (
+	AtxCache       = "atx-cache"       // activation transaction headers, in headers
+	BlockCache     = "block-cache"     // mesh blocks, in layers of blocks
+	ActivesetCache = "activeset-cache" // active set sizes, in views
+	Mempool        = "mempool"         // pending transactions, in transactions
+	GossipDedup    = "gossip-dedup"    // hashes of seen gossip messages, in messages
+)
+
+// Quota is the part of the budget a cache gets.
+type Quota struct {
+	Share     int // percent of the budget
+	EntrySize int // estimated size of an entry in bytes, including the cache's overhead
+}
+
+// Policy maps caches to their quotas. The shares of a policy add up to at most 100.
+type Policy map[string]Quota
+
+// DefaultPolicy gives most of the budget to the caches that hold large objects and are hit on every layer.
+var DefaultPolicy = Policy{
+	BlockCache:     {Share: 40, EntrySize: 200 * 2048}, // a layer of 200 blocks of ~2KB
+	AtxCache:       {Share: 20, EntrySize: 512},
+	Mempool:        {Share: 25, EntrySize: 1024}, // the transaction and its indexes by account
+	GossipDedup:    {Share: 10, EntrySize: 128},  // the dedup cache keeps up to twice its size
+	ActivesetCache: {Share: 5, EntrySize: 96},
+}
+
+// Manager hands out the sizes of the caches under the budget.
+type Manager struct {
+	total  int // bytes, 0 for unlimited
+	policy Policy
+	log    log.Log
+
+	mu      sync.Mutex
+	entries map[string]int // entries granted by cache
+}
+
+// NewManager returns a Manager that divides cacheMB megabytes by policy. If cacheMB is 0 the budget is unlimited and
+// caches keep their own sizes.
+func NewManager(cacheMB int, policy Policy, logger log.Log) *Manager {
+	return &Manager{
+		total:   cacheMB << 20,
+		policy:  policy,
+		log:     logger,
+		entries: make(map[string]int),
+	}
+}
+
+// Register registers the cache name and returns the number of entries it may hold. def is the cache's own size, it's
+// returned when the budget is unlimited or the policy has no quota for the cache. A cache gets at least one entry.
+func (m *Manager) Register(name string, def int) int {
+	size := def
+	if q, ok := m.policy[name]; ok && m.total > 0 && q.EntrySize > 0 {
+		size = m.total * q.Share / 100 / q.EntrySize
+		if size < 1 {
+			size = 1
+		}
+	}
+
+	m.mu.Lock()
+	m.entries[name] = size
+	m.mu.Unlock()
+
+	m.log.With().Info("cache size set by memory budget",
+		log.String("cache", name),
+		log.Int("entries", size),
+		log.Int("estimated_mb", m.estimate(name, size)>>20))
+	return size
+}
+
+// Estimate returns the estimated memory in bytes of the registered caches.
+func (m *Manager) Estimate() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := 0
+	for name, size := range m.entries {
+		total += m.estimate(name, size)
+	}
+	return total
+}
+
+// Caches returns the names of the registered caches, sorted.
+func (m *Manager) Caches() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.entries))
+	for name := range m.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Manager) estimate(name string, size int) int {
+	return size * m.policy[name].EntrySize
+}
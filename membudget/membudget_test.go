@@ -0,0 +1,38 @@
+package membudget
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Register(t *testing.T) {
+	r := require.New(t)
+	policy := Policy{
+		AtxCache: {Share: 50, EntrySize: 1024},
+		Mempool:  {Share: 25, EntrySize: 1 << 30},
+	}
+	m := NewManager(10, policy, log.NewDefault(t.Name()))
+
+	r.Equal(5*1024, m.Register(AtxCache, 600))
+	r.Equal(1, m.Register(Mempool, 100))  // an entry larger than the quota still gets one
+	r.Equal(7, m.Register(BlockCache, 7)) // no quota
+	r.Equal([]string{AtxCache, BlockCache, Mempool}, m.Caches())
+	r.Equal(5<<20+1<<30, m.Estimate())
+}
+
+func TestManager_Unlimited(t *testing.T) {
+	r := require.New(t)
+	m := NewManager(0, DefaultPolicy, log.NewDefault(t.Name()))
+	r.Equal(600, m.Register(AtxCache, 600))
+	r.Equal(20, m.Register(BlockCache, 20))
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	total := 0
+	for _, q := range DefaultPolicy {
+		total += q.Share
+	}
+	require.True(t, total <= 100)
+}
@@ -1,6 +1,7 @@
 package mesh
 
 import (
+	"context"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 )
@@ -37,6 +38,20 @@ func (t *AtxDbMock) GetFullAtx(id types.ATXID) (*types.ActivationTx, error) {
 	return t.db[id], nil
 }
 
+// GetNodeLastAtxID returns the ATX of the node with the highest sequence number
+func (t *AtxDbMock) GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error) {
+	var last *types.ActivationTx
+	for _, atx := range t.db {
+		if atx.NodeID.Key == nodeID.Key && (last == nil || atx.Sequence > last.Sequence) {
+			last = atx
+		}
+	}
+	if last == nil {
+		return *types.EmptyATXID, fmt.Errorf("cannot find atx")
+	}
+	return last.ID(), nil
+}
+
 // AddAtx stores an ATX for later retrieval
 func (t *AtxDbMock) AddAtx(id types.ATXID, atx *types.ActivationTx) {
 	t.db[id] = atx
@@ -50,6 +65,6 @@ func (t *AtxDbMock) ProcessAtxs(atxs []*types.ActivationTx) error {
 }
 
 // SyntacticallyValidateAtx always returns no error
-func (AtxDbMock) SyntacticallyValidateAtx(*types.ActivationTx) error {
+func (AtxDbMock) SyntacticallyValidateAtx(context.Context, *types.ActivationTx) error {
 	return nil
 }
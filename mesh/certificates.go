@@ -0,0 +1,76 @@
+package mesh
+
+import (
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// the number of layers, before the latest hare output, whose hare outputs wait for their certificate
+const uncertifiedBuffer = 20
+
+// hareCertificates holds the hare outputs that wait for their layer's certificate before they're applied to state.
+type hareCertificates struct {
+	sync.Mutex
+	required    bool
+	uncertified map[types.LayerID][]types.BlockID
+}
+
+// RequireCertificates makes the hare output of a layer wait for the layer's certificate before it's applied to state.
+// It's applied once HandleCertificate gets a certificate on the same block set. A layer that isn't certified is applied
+// once the tortoise verifies it. It must be called before the hare starts.
+func (msh *Mesh) RequireCertificates() {
+	msh.certs.Lock()
+	defer msh.certs.Unlock()
+	msh.certs.required = true
+	msh.certs.uncertified = make(map[types.LayerID][]types.BlockID)
+}
+
+// HandleCertificate saves cert, and applies the hare output of its layer to state if it's the block set that cert
+// certifies.
+func (msh *Mesh) HandleCertificate(cert *types.Certificate) error {
+	msh.certs.Lock()
+	if err := msh.SaveCertificate(cert); err != nil {
+		msh.certs.Unlock()
+		return err
+	}
+	output, ok := msh.certs.uncertified[cert.Layer]
+	delete(msh.certs.uncertified, cert.Layer)
+	msh.certs.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if !certifies(cert, output) {
+		msh.Warning("certificate of layer %v is on a different block set than the hare output, the layer is applied "+
+			"once the tortoise verifies it", cert.Layer)
+		return nil
+	}
+	msh.applyHareOutput(cert.Layer, output)
+	return nil
+}
+
+// awaitCertificate reports whether the hare output of layer waits for the layer's certificate, which it does when
+// certificates are required and the layer doesn't have a certificate on output yet.
+func (msh *Mesh) awaitCertificate(layer types.LayerID, output []types.BlockID) bool {
+	msh.certs.Lock()
+	defer msh.certs.Unlock()
+	if !msh.certs.required {
+		return false
+	}
+	if cert, err := msh.GetCertificate(layer); err == nil && certifies(cert, output) {
+		return false
+	}
+	for lyr := range msh.certs.uncertified {
+		if lyr+uncertifiedBuffer < layer {
+			delete(msh.certs.uncertified, lyr)
+		}
+	}
+	msh.certs.uncertified[layer] = output
+	return true
+}
+
+// certifies reports whether cert certifies the block set blockIDs, in any order.
+func certifies(cert *types.Certificate, blockIDs []types.BlockID) bool {
+	return types.CalcBlocksHash32(cert.BlockIDs, nil) == types.CalcBlocksHash32(blockIDs, nil)
+}
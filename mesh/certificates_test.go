@@ -0,0 +1,47 @@
+package mesh
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMesh_RequireCertificates(t *testing.T) {
+	r := require.New(t)
+	s := &layeredMockState{MockMapState{Rewards: make(map[types.Address]*big.Int)}, make(map[types.LayerID][]*types.Transaction)}
+	lg := log.New(t.Name(), "", "")
+	atxDB := NewAtxDbMock()
+	mesh := NewMesh(NewMemMeshDB(lg), atxDB, ConfigTst(), &MeshValidatorMock{}, &MockTxMemPool{}, &MockAtxMemPool{}, s, lg)
+	mesh.SetBlockBuilder(&MockBlockBuilder{})
+	mesh.RequireCertificates()
+	defer mesh.Close()
+
+	var blocks [][]*types.Block
+	for i := 1; i <= 3; i++ {
+		_, lyrBlocks := createLayer(t, mesh, types.LayerID(i), 5, 20, atxDB)
+		blocks = append(blocks, lyrBlocks)
+	}
+
+	// the hare output isn't applied before the layer is certified
+	mesh.HandleValidatedLayer(1, types.BlockIDs(blocks[0]))
+	r.Equal(types.LayerID(0), mesh.LatestLayerInState())
+	r.NoError(mesh.HandleCertificate(&types.Certificate{Layer: 1, BlockIDs: types.BlockIDs(blocks[0])}))
+	r.Equal(types.LayerID(1), mesh.LatestLayerInState())
+
+	// a certificate that's received before the hare output lets it be applied once it's output
+	r.NoError(mesh.HandleCertificate(&types.Certificate{Layer: 2, BlockIDs: types.BlockIDs(blocks[1])}))
+	r.Equal(types.LayerID(1), mesh.LatestLayerInState())
+	mesh.HandleValidatedLayer(2, types.BlockIDs(blocks[1]))
+	r.Equal(types.LayerID(2), mesh.LatestLayerInState())
+
+	// a hare output that isn't the certified block set isn't applied
+	mesh.HandleValidatedLayer(3, types.BlockIDs(blocks[2][1:]))
+	r.NoError(mesh.HandleCertificate(&types.Certificate{Layer: 3, BlockIDs: types.BlockIDs(blocks[2])}))
+	r.Equal(types.LayerID(2), mesh.LatestLayerInState())
+	cert, err := mesh.GetCertificate(3)
+	r.NoError(err)
+	r.Equal(types.BlockIDs(blocks[2]), cert.BlockIDs)
+}
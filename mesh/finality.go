@@ -0,0 +1,98 @@
+package mesh
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+var errNoRefusedReorg = errors.New("no reorganization of a final layer was refused")
+
+// SetFinalityDepth sets the number of layers after which applied layers are final. When the tortoise verifies a final
+// layer with other blocks than the ones applied to state, the state isn't rolled back until ApproveReorg is called.
+// 0 disables finality. It must be called before layers are applied.
+func (msh *Mesh) SetFinalityDepth(depth uint32) {
+	msh.finalityDepth = depth
+}
+
+// FinalizedLayer returns the finality checkpoint, the latest final layer. It's persisted, and never moves back.
+func (msh *Mesh) FinalizedLayer() types.LayerID {
+	msh.pMutex.RLock()
+	defer msh.pMutex.RUnlock()
+	return msh.finalizedLayer
+}
+
+func (msh *Mesh) isFinal(lyr types.LayerID) bool {
+	return msh.finalityDepth > 0 && lyr <= msh.FinalizedLayer()
+}
+
+// advanceFinality moves the finality checkpoint to finalityDepth layers before the applied layer. Must be called with
+// txMutex held.
+func (msh *Mesh) advanceFinality(applied types.LayerID) {
+	depth := types.LayerID(msh.finalityDepth)
+	if depth == 0 || applied < depth {
+		return
+	}
+	final := applied - depth
+	msh.pMutex.Lock()
+	defer msh.pMutex.Unlock()
+	if final <= msh.finalizedLayer {
+		return
+	}
+	if err := msh.general.Put(constFINALIZED, final.Bytes()); err != nil {
+		msh.With().Error("could not persist finalized layer", log.LayerID(final.Uint64()), log.Err(err))
+		return
+	}
+	msh.finalizedLayer = final
+}
+
+// refuseReorg records that the tortoise verified the final layer with other blocks than the ones applied to state.
+// Must be called with txMutex held.
+func (msh *Mesh) refuseReorg(verified *types.Layer, latest types.LayerID) {
+	lyr := verified.Index()
+	msh.With().Error("tortoise disagrees with a final layer, refusing to roll back state",
+		log.LayerID(lyr.Uint64()), log.Uint64("finalized_layer", msh.FinalizedLayer().Uint64()),
+		log.Uint64("latest_layer_in_state", latest.Uint64()))
+	if msh.refusedReorg == nil || lyr < *msh.refusedReorg {
+		msh.refusedReorg = &lyr
+	}
+}
+
+// RefusedReorg returns the earliest final layer whose reorganization was refused since the node started, if any.
+func (msh *Mesh) RefusedReorg() (types.LayerID, bool) {
+	msh.txMutex.Lock()
+	defer msh.txMutex.Unlock()
+	if msh.refusedReorg == nil {
+		return 0, false
+	}
+	return *msh.refusedReorg, true
+}
+
+// ApproveReorg overrides finality: it rolls the state back to the earliest final layer whose reorganization was
+// refused, and applies the verified layers from it as the tortoise verified them. It returns the layer the state was
+// rolled back to.
+func (msh *Mesh) ApproveReorg() (types.LayerID, error) {
+	msh.txMutex.Lock()
+	defer msh.txMutex.Unlock()
+	if msh.refusedReorg == nil {
+		return 0, errNoRefusedReorg
+	}
+	from := *msh.refusedReorg
+	msh.refusedReorg = nil
+	latest := msh.LatestLayerInState()
+	for lyr := from; lyr <= msh.VerifiedLayer(); lyr++ {
+		l, err := msh.GetLayer(lyr)
+		if err != nil {
+			return from, fmt.Errorf("could not get layer %v: %v", lyr, err)
+		}
+		validBlocks, _ := msh.BlocksByValidity(l.Blocks())
+		verified := types.NewExistingLayer(lyr, validBlocks)
+		if !msh.appliedBlocksMatch(verified) {
+			msh.rollback(verified, latest)
+		}
+	}
+	msh.With().Warning("approved reorganization of final layers", log.LayerID(from.Uint64()))
+	return from, nil
+}
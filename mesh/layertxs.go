@@ -0,0 +1,143 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"sort"
+
+	"github.com/seehuhn/mt19937"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+)
+
+// The transactions of a layer are applied to the state once each, in an order that every node derives from the set of
+// the layer's valid blocks alone. Before the canonical-layer-tx-order upgrade, the blocks are sorted by ID and shuffled
+// with a Mersenne Twister seeded with the hash of the sorted IDs, and the transactions are taken as in rule 2 below.
+// From the upgrade, the order is canonical:
+//
+//  1. the seed of the layer is the sha256 sum of the IDs of the blocks, sorted in lexicographic order
+//     (types.CalcBlocksHash32). Each block's key is the sha256 sum of the seed followed by the block ID, and the blocks
+//     are sorted by key,
+//  2. the transactions are taken in the order of the blocks and, within a block, in the order of the block. A
+//     transaction that several blocks reference takes the position of its first occurrence,
+//  3. the transactions of each origin are sorted by nonce, keeping the positions that the origin's transactions took.
+//
+// Block IDs are 32 bytes long in the pre-images, right-padded with zeros (types.BlockID.Bytes). Blocks can reference
+// the same transactions in conflicting orders, e.g. when their miners saw them arrive in different orders, but the rules
+// above settle on a single order without looking at the order of the blocks in the layer. Any change to them changes
+// the state of the layers and must be coordinated as a protocol upgrade.
+
+// layerTxIDs returns the IDs of the transactions that blocks reference, each once, in the canonical block order if
+// canonical is true, and in the order of the shuffled blocks otherwise. blocks isn't modified.
+func layerTxIDs(blocks []*types.Block, canonical bool) []types.TransactionID {
+	if !canonical {
+		return uniqueTxIds(shuffledBlocks(blocks), make(map[types.TransactionID]struct{}))
+	}
+	byID := make(map[types.BlockID]*types.Block, len(blocks))
+	for _, b := range blocks {
+		byID[b.ID()] = b
+	}
+	ordered := make([]*types.Block, 0, len(blocks))
+	for _, id := range canonicalBlockOrder(types.BlockIDs(blocks)) {
+		ordered = append(ordered, byID[id])
+	}
+	return uniqueTxIds(ordered, make(map[types.TransactionID]struct{}))
+}
+
+// canonicalBlockOrder returns a copy of ids, without repetitions, sorted by their keys.
+func canonicalBlockOrder(ids []types.BlockID) []types.BlockID {
+	ordered := make([]types.BlockID, 0, len(ids))
+	seen := make(map[types.BlockID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ordered = append(ordered, id)
+		}
+	}
+	seed := types.CalcBlocksHash32(ordered, nil)
+	keys := make(map[types.BlockID]types.Hash32, len(ordered))
+	for _, id := range ordered {
+		keys[id] = types.CalcHash32(append(seed.Bytes(), id.Bytes()...))
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		ki, kj := keys[ordered[i]], keys[ordered[j]]
+		return bytes.Compare(ki[:], kj[:]) < 0
+	})
+	return ordered
+}
+
+// shuffledBlocks returns a copy of blocks sorted by ID and shuffled with a Mersenne Twister seeded with the hash of the
+// sorted IDs, the block order before the canonical-layer-tx-order upgrade. Its result depends on the algorithm of
+// rand.Shuffle, which isn't guaranteed across Go versions.
+func shuffledBlocks(blocks []*types.Block) []*types.Block {
+	shuffled := make([]*types.Block, len(blocks))
+	copy(shuffled, blocks)
+	types.SortBlocks(shuffled)
+
+	// Initialize a Mersenne Twister seeded with the hash of the sorted blocks
+	blockHash := types.CalcBlockHash32Presorted(types.BlockIDs(shuffled), nil)
+	mt := mt19937.New()
+	mt.SeedFromSlice(toUint64Slice(blockHash.Bytes()))
+	rng := rand.New(mt)
+
+	// Perform a Fisher-Yates shuffle on the blocks
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+func toUint64Slice(b []byte) []uint64 {
+	l := len(b)
+	var s []uint64
+	for i := 0; i < l; i += 8 {
+		s = append(s, binary.LittleEndian.Uint64(b[i:util.Min(l, i+8)]))
+	}
+	return s
+}
+
+// uniqueTxIds returns the IDs of the transactions that blocks reference and that aren't in seenTxIds, each once, in the
+// order of their first occurrence. The returned IDs are added to seenTxIds.
+func uniqueTxIds(blocks []*types.Block, seenTxIds map[types.TransactionID]struct{}) []types.TransactionID {
+	var txIds []types.TransactionID
+	for _, b := range blocks {
+		for _, id := range b.TxIDs {
+			if _, found := seenTxIds[id]; found {
+				continue
+			}
+			txIds = append(txIds, id)
+			seenTxIds[id] = struct{}{}
+		}
+	}
+	return txIds
+}
+
+// orderByNonce sorts the transactions of each origin by nonce in place, keeping the positions that the origin's
+// transactions take in txs, so that an origin's transactions don't fail on a wrong nonce because of the block order.
+// Transactions of an origin with the same nonce keep their relative order. txs is returned.
+func orderByNonce(txs []*types.Transaction) []*types.Transaction {
+	positions := make(map[types.Address][]int)
+	var origins []types.Address
+	for i, tx := range txs {
+		if _, ok := positions[tx.Origin()]; !ok {
+			origins = append(origins, tx.Origin())
+		}
+		positions[tx.Origin()] = append(positions[tx.Origin()], i)
+	}
+	for _, origin := range origins {
+		pos := positions[origin]
+		if len(pos) < 2 {
+			continue
+		}
+		byNonce := make([]*types.Transaction, len(pos))
+		for i, p := range pos {
+			byNonce[i] = txs[p]
+		}
+		sort.SliceStable(byNonce, func(i, j int) bool { return byNonce[i].AccountNonce < byNonce[j].AccountNonce })
+		for i, p := range pos {
+			txs[p] = byNonce[i]
+		}
+	}
+	return txs
+}
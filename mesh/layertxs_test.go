@@ -0,0 +1,116 @@
+package mesh
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
+	"github.com/stretchr/testify/require"
+)
+
+func newBlockWithTxIDs(ids ...types.TransactionID) *types.Block {
+	blk := types.NewExistingBlock(1, []byte("data"))
+	blk.TxIDs = ids
+	blk.Initialize()
+	return blk
+}
+
+func TestLayerTxIDs_ConflictingOrders(t *testing.T) {
+	r := require.New(t)
+	tx1, tx2, tx3, tx4 := types.TransactionID{1}, types.TransactionID{2}, types.TransactionID{3}, types.TransactionID{4}
+	b1 := newBlockWithTxIDs(tx1, tx2, tx3)
+	b2 := newBlockWithTxIDs(tx3, tx2, tx1)
+	b3 := newBlockWithTxIDs(tx2, tx4)
+
+	for _, canonical := range []bool{false, true} {
+		blocks := []*types.Block{b1, b2, b3}
+		ids := layerTxIDs(blocks, canonical)
+		r.ElementsMatch([]types.TransactionID{tx1, tx2, tx3, tx4}, ids)
+		r.Equal([]*types.Block{b1, b2, b3}, blocks)
+
+		// the order only depends on the set of blocks
+		for _, perm := range [][]*types.Block{{b1, b3, b2}, {b2, b1, b3}, {b2, b3, b1}, {b3, b1, b2}, {b3, b2, b1}} {
+			r.Equal(ids, layerTxIDs(perm, canonical))
+		}
+		r.Empty(layerTxIDs(nil, canonical))
+	}
+}
+
+func TestOrderByNonce(t *testing.T) {
+	r := require.New(t)
+	signer1, _ := newSignerAndAddress(r, "origin1")
+	signer2, _ := newSignerAndAddress(r, "origin2")
+	a2, a0, b1, a1, b0 := newTx(r, signer1, 2, 1), newTx(r, signer1, 0, 1), newTx(r, signer2, 1, 1),
+		newTx(r, signer1, 1, 1), newTx(r, signer2, 0, 1)
+
+	// each origin keeps its positions
+	r.Equal([]*types.Transaction{a0, a1, b0, a2, b1}, orderByNonce([]*types.Transaction{a2, a0, b1, a1, b0}))
+	r.Equal([]*types.Transaction{b0}, orderByNonce([]*types.Transaction{b0}))
+	r.Empty(orderByNonce(nil))
+}
+
+func TestMesh_ExtractUniqueOrderedTransactions_ConflictingOrders(t *testing.T) {
+	r := require.New(t)
+
+	msh := getMesh(t.Name())
+	defer msh.Close()
+	layerID := types.LayerID(1)
+	signer, _ := newSignerAndAddress(r, "origin")
+	tx1 := addTxToMesh(r, msh, signer, 1)
+	tx2 := addTxToMesh(r, msh, signer, 2)
+	tx3 := addTxToMesh(r, msh, signer, 3)
+	addBlockWithTxs(r, msh, layerID, true, tx3, tx2, tx1)
+	addBlockWithTxs(r, msh, layerID, true, tx2, tx1)
+	addBlockWithTxs(r, msh, layerID, true, tx1, tx3)
+	l, err := msh.GetLayer(layerID)
+	r.NoError(err)
+
+	// before the upgrade every transaction appears once, in the order of the shuffled blocks
+	upgrades, err := upgrade.NewSchedule(map[string]int{string(upgrade.CanonicalLayerTxOrder): 2})
+	r.NoError(err)
+	msh.SetUpgrades(upgrades, 1)
+	r.Equal(layerTxIDs(l.Blocks(), false), GetTransactionIds(msh.extractUniqueOrderedTransactions(l)...))
+
+	// after it every transaction appears once, in nonce order whatever the order of the blocks
+	upgrades, err = upgrade.NewSchedule(map[string]int{string(upgrade.CanonicalLayerTxOrder): 1})
+	r.NoError(err)
+	msh.SetUpgrades(upgrades, 1)
+	r.Equal(GetTransactionIds(tx1, tx2, tx3), GetTransactionIds(msh.extractUniqueOrderedTransactions(l)...))
+}
+
+// The golden tests pin the canonical order. They must only be updated together with a protocol upgrade, since nodes
+// that order the transactions of a layer differently diverge in state.
+
+func goldenBlock(data []byte, ids ...types.TransactionID) *types.Block {
+	blk := &types.Block{MiniBlock: types.MiniBlock{BlockHeader: types.BlockHeader{LayerIndex: 1, Data: data}, TxIDs: ids}}
+	blk.Signature = signing.NewEdSigner().Sign(blk.Bytes())
+	blk.Initialize()
+	return blk
+}
+
+func TestCanonicalBlockOrder_Golden(t *testing.T) {
+	r := require.New(t)
+	ids := []types.BlockID{{1}, {2}, {3}, {4}, {5}}
+	expected := []types.BlockID{{1}, {2}, {3}, {5}, {4}}
+	r.Equal(expected, canonicalBlockOrder(ids))
+	r.Equal(expected, canonicalBlockOrder([]types.BlockID{{5}, {4}, {3}, {2}, {1}, {3}}))
+	r.Equal([]types.BlockID{{1}, {2}, {3}, {4}, {5}}, ids)
+	r.Empty(canonicalBlockOrder(nil))
+}
+
+func TestLayerTxIDs_Golden(t *testing.T) {
+	r := require.New(t)
+	tx1, tx2, tx3, tx4, tx5 := types.TransactionID{1}, types.TransactionID{2}, types.TransactionID{3},
+		types.TransactionID{4}, types.TransactionID{5}
+	b1 := goldenBlock([]byte("b1"), tx1, tx2, tx3)
+	b2 := goldenBlock([]byte("b2"), tx3, tx2, tx1)
+	b3 := goldenBlock([]byte("b3"), tx4, tx2, tx5)
+	// the IDs only depend on the contents of the blocks, not on their signatures
+	r.Equal("0x40f22de226cbe04305fb556a6b05dd94b9825f91", types.Hash20(b1.ID()).Hex())
+	r.Equal("0x6f212cb8b3ef89e9f5a3e2c48a38c3c14889e8f2", types.Hash20(b2.ID()).Hex())
+	r.Equal("0x699709fd86ef64c5167b18016dd8aa9d77c8328f", types.Hash20(b3.ID()).Hex())
+
+	// b2 comes first, so tx3 precedes tx1
+	r.Equal([]types.TransactionID{tx3, tx2, tx1, tx4, tx5}, layerTxIDs([]*types.Block{b1, b2, b3}, true))
+}
@@ -3,18 +3,16 @@
 package mesh
 
 import (
-	"encoding/binary"
+	"context"
 	"errors"
 	"fmt"
-	"github.com/seehuhn/mt19937"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/signing"
-	"math/rand"
-
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 	"math/big"
 
 	"sync"
@@ -32,6 +30,11 @@ var constFalse = []byte{0}
 var constLATEST = []byte("latest")
 var constLAYERHASH = []byte("layer hash")
 var constPROCESSED = []byte("processed")
+var constCERTIFICATE = []byte("certificate")
+var constAPPLIED = []byte("applied")
+var constVERIFIEDSTATE = []byte("verified state")
+var constVIEW = []byte("view")
+var constFINALIZED = []byte("finalized")
 
 // TORTOISE key for tortoise persistence in database
 var TORTOISE = []byte("tortoise")
@@ -61,7 +64,9 @@ type txProcessor interface {
 	ValidateNonceAndBalance(transaction *types.Transaction) error
 	GetLayerApplied(txID types.TransactionID) *types.LayerID
 	GetStateRoot() types.Hash32
+	GetLayerStateRoot(layer types.LayerID) (types.Hash32, error)
 	LoadState(layer types.LayerID) error
+	RevertTransactions(layer types.LayerID, txs []*types.Transaction) error
 }
 
 type txMemPoolInValidator interface {
@@ -77,13 +82,23 @@ type AtxDB interface {
 	ProcessAtxs(atxs []*types.ActivationTx) error
 	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
 	GetFullAtx(id types.ATXID) (*types.ActivationTx, error)
-	SyntacticallyValidateAtx(atx *types.ActivationTx) error
+	GetNodeLastAtxID(nodeID types.NodeID) (types.ATXID, error)
+	SyntacticallyValidateAtx(ctx context.Context, atx *types.ActivationTx) error
 }
 
 type blockBuilder interface {
 	ValidateAndAddTxToPool(tx *types.Transaction) error
 }
 
+type stateObserver interface {
+	LayerApplied(layer *types.Layer)
+}
+
+// stateReverter is a stateObserver that keeps the results of applied layers, and drops them when they're rolled back.
+type stateReverter interface {
+	LayersReverted(from types.LayerID)
+}
+
 // Mesh is the logic layer above our mesh.DB database
 type Mesh struct {
 	log.Log
@@ -93,11 +108,13 @@ type Mesh struct {
 	Validator
 	trtl               tortoise
 	blockBuilder       blockBuilder
+	stateObservers     []stateObserver
 	txInvalidator      txMemPoolInValidator
 	atxInvalidator     atxMemPoolInValidator
 	config             Config
 	latestLayer        types.LayerID
 	latestLayerInState types.LayerID
+	verifiedLayer      types.LayerID
 	layerHash          []byte
 	lMutex             sync.RWMutex
 	lkMutex            sync.RWMutex
@@ -109,6 +126,12 @@ type Mesh struct {
 	nextValidLayers    map[types.LayerID]*types.Layer
 	maxValidatedLayer  types.LayerID
 	txMutex            sync.Mutex
+	finalityDepth      uint32
+	finalizedLayer     types.LayerID
+	refusedReorg       *types.LayerID
+	upgrades           *upgrade.Schedule
+	layersPerEpoch     uint16
+	certs              hareCertificates
 }
 
 // NewMesh creates a new instant of a mesh
@@ -131,9 +154,11 @@ func NewMesh(db *DB, atxDb AtxDB, rewardConfig Config, mesh tortoise, txInvalida
 	return ll
 }
 
-// NewRecoveredMesh creates new instance of mesh with recovered mesh data fom database
-func NewRecoveredMesh(db *DB, atxDb AtxDB, rewardConfig Config, mesh tortoise, txInvalidator txMemPoolInValidator, atxInvalidator atxMemPoolInValidator, pr txProcessor, logger log.Log) *Mesh {
+// NewRecoveredMesh creates new instance of mesh with recovered mesh data fom database. The layers that weren't applied
+// to the state before the node stopped are applied by the rules of upgrades.
+func NewRecoveredMesh(db *DB, atxDb AtxDB, rewardConfig Config, mesh tortoise, txInvalidator txMemPoolInValidator, atxInvalidator atxMemPoolInValidator, pr txProcessor, upgrades *upgrade.Schedule, layersPerEpoch uint16, logger log.Log) *Mesh {
 	msh := NewMesh(db, atxDb, rewardConfig, mesh, txInvalidator, atxInvalidator, pr, logger)
+	msh.SetUpgrades(upgrades, layersPerEpoch)
 
 	latest, err := db.general.Get(constLATEST)
 	if err != nil {
@@ -158,8 +183,15 @@ func NewRecoveredMesh(db *DB, atxDb AtxDB, rewardConfig Config, mesh tortoise, t
 	}
 	msh.latestLayerInState = types.LayerID(util.BytesToUint64(verified))
 
-	err = pr.LoadState(msh.LatestLayerInState())
-	if err != nil {
+	if verifiedState, err := db.general.Get(constVERIFIEDSTATE); err == nil {
+		msh.verifiedLayer = types.LayerID(util.BytesToUint64(verifiedState))
+	}
+
+	if finalized, err := db.general.Get(constFINALIZED); err == nil {
+		msh.finalizedLayer = types.LayerID(util.BytesToUint64(finalized))
+	}
+
+	if err := msh.recoverState(); err != nil {
 		logger.Panic("cannot load state for layer %v, message: %v", msh.LatestLayerInState(), err)
 	}
 	// in case we load a state that was not fully played
@@ -192,11 +224,25 @@ func (msh *Mesh) CacheWarmUp(layerSize int) {
 	msh.Info("cache warm up done")
 }
 
+// SetUpgrades sets the schedule of the protocol upgrades that applying layers to the state branches on, and the number
+// of layers per epoch to find the epoch of a layer. It must be called before layers are applied, without a schedule
+// layers are applied by the original protocol.
+func (msh *Mesh) SetUpgrades(upgrades *upgrade.Schedule, layersPerEpoch uint16) {
+	msh.upgrades = upgrades
+	msh.layersPerEpoch = layersPerEpoch
+}
+
 // SetBlockBuilder sets the block builder in use by mesh
 func (msh *Mesh) SetBlockBuilder(blockBuilder blockBuilder) {
 	msh.blockBuilder = blockBuilder
 }
 
+// AddStateObserver adds an observer that is notified with the valid blocks of every layer after it is applied to state.
+// Observers are notified in the order they were added. It must be called before layers are applied.
+func (msh *Mesh) AddStateObserver(observer stateObserver) {
+	msh.stateObservers = append(msh.stateObservers, observer)
+}
+
 // LatestLayerInState returns the latest layer we applied to state
 func (msh *Mesh) LatestLayerInState() types.LayerID {
 	defer msh.pMutex.RUnlock()
@@ -294,7 +340,7 @@ func (msh *Mesh) pushLayersToState(oldPbase types.LayerID, newPbase types.LayerI
 			return
 		}
 		validBlocks, invalidBlocks := msh.BlocksByValidity(l.Blocks())
-		msh.updateStateWithLayer(layerID, types.NewExistingLayer(layerID, validBlocks))
+		msh.updateStateWithLayer(layerID, types.NewExistingLayer(layerID, validBlocks), true)
 		msh.logStateRoot(l.Index())
 		msh.setLayerHash(l)
 		msh.reInsertTxsToPool(validBlocks, invalidBlocks, l.Index())
@@ -310,6 +356,10 @@ func (msh *Mesh) reInsertTxsToPool(validBlocks, invalidBlocks []*types.Block, l
 	for account := range accounts {
 		msh.removeRejectedFromAccountTxs(account, grouped, l)
 	}
+	if msh.blockBuilder == nil {
+		// nodes that don't mine have no block builder to validate the returned txs with
+		return
+	}
 	for _, tx := range returnedTxs {
 		err := msh.blockBuilder.ValidateAndAddTxToPool(tx)
 		// We ignore errors here, since they mean that the tx is no longer valid and we shouldn't re-add it
@@ -321,13 +371,41 @@ func (msh *Mesh) reInsertTxsToPool(validBlocks, invalidBlocks []*types.Block, l
 }
 
 func (msh *Mesh) applyState(l *types.Layer) {
+	if err := msh.persistAppliedBlocks(l); err != nil {
+		msh.With().Error("could not persist applied blocks", log.LayerID(l.Index().Uint64()), log.Err(err))
+	}
 	msh.accumulateRewards(l, msh.config)
 	msh.pushTransactions(l)
+	if err := msh.persistStateChecksum(l); err != nil {
+		msh.With().Error("could not persist state checksum", log.LayerID(l.Index().Uint64()), log.Err(err))
+	}
 	msh.setLatestLayerInState(l.Index())
+	msh.advanceFinality(l.Index())
+	for _, observer := range msh.stateObservers {
+		observer.LayerApplied(l)
+	}
 }
 
-// HandleValidatedLayer handles layer valid blocks as decided by hare
+// WithStateLocked calls fn with the latest layer in state while no layer is applied to state, so that fn can observe
+// the databases at a layer boundary. Writes that don't change the state, like adding blocks, are not blocked.
+func (msh *Mesh) WithStateLocked(fn func(layer types.LayerID) error) error {
+	msh.txMutex.Lock()
+	defer msh.txMutex.Unlock()
+	return fn(msh.LatestLayerInState())
+}
+
+// HandleValidatedLayer handles layer valid blocks as decided by hare. When certificates are required, they're applied
+// to state once the layer is certified, see RequireCertificates.
 func (msh *Mesh) HandleValidatedLayer(validatedLayer types.LayerID, layer []types.BlockID) {
+	if msh.awaitCertificate(validatedLayer, layer) {
+		msh.Info("hare output of layer %v waits for its certificate", validatedLayer)
+		return
+	}
+	msh.applyHareOutput(validatedLayer, layer)
+}
+
+// applyHareOutput applies the hare output of a layer to state, before the tortoise verifies the layer.
+func (msh *Mesh) applyHareOutput(validatedLayer types.LayerID, layer []types.BlockID) {
 	var blocks []*types.Block
 
 	for _, blockID := range layer {
@@ -341,7 +419,7 @@ func (msh *Mesh) HandleValidatedLayer(validatedLayer types.LayerID, layer []type
 	}
 	lyr := types.NewExistingLayer(validatedLayer, blocks)
 	invalidBlocks := msh.getInvalidBlocksByHare(lyr)
-	msh.updateStateWithLayer(validatedLayer, lyr)
+	msh.updateStateWithLayer(validatedLayer, lyr, false)
 	msh.reInsertTxsToPool(blocks, invalidBlocks, lyr.Index())
 }
 
@@ -364,11 +442,29 @@ func (msh *Mesh) getInvalidBlocksByHare(hareLayer *types.Layer) (invalid []*type
 	return
 }
 
-func (msh *Mesh) updateStateWithLayer(validatedLayer types.LayerID, layer *types.Layer) {
+// updateStateWithLayer applies layer to state. Hare results are applied optimistically, before the tortoise verifies
+// the layer. When the tortoise verifies a layer that was already applied with different valid blocks, the state is
+// rolled back to the previous layer and the applied layers are replayed on top of the verified one, unless the layer is
+// final (see SetFinalityDepth).
+func (msh *Mesh) updateStateWithLayer(validatedLayer types.LayerID, layer *types.Layer, verified bool) {
 	msh.txMutex.Lock()
 	defer msh.txMutex.Unlock()
+	if verified {
+		msh.setVerifiedLayer(validatedLayer)
+	} else if msh.isVerified(validatedLayer) {
+		log.Info("hare result received after tortoise verified layer %v", validatedLayer)
+		return
+	}
 	latest := msh.LatestLayerInState()
 	if validatedLayer <= latest {
+		if verified && !msh.appliedBlocksMatch(layer) {
+			if msh.isFinal(validatedLayer) {
+				msh.refuseReorg(layer, latest)
+				return
+			}
+			msh.rollback(layer, latest)
+			return
+		}
 		log.Info("result received after state has been advanced for layer %v, latest: %v", validatedLayer, latest)
 		return
 	}
@@ -391,6 +487,166 @@ func (msh *Mesh) updateStateWithLayer(validatedLayer types.LayerID, layer *types
 	}
 }
 
+// rollback reverts the state to the layer before the verified layer, applies the verified layer and replays the
+// layers applied after it, up to latest. The rewards, applied transactions and cached results of the reverted layers
+// are removed before the layers are applied again. Must be called with txMutex held.
+func (msh *Mesh) rollback(verified *types.Layer, latest types.LayerID) {
+	lyr := verified.Index()
+	msh.With().Warning("tortoise disagrees with applied layer, rolling back state",
+		log.LayerID(lyr.Uint64()), log.Uint64("latest_layer_in_state", latest.Uint64()))
+	if lyr == Genesis {
+		msh.With().Error("cannot roll back genesis layer")
+		return
+	}
+	if err := msh.LoadState(lyr - 1); err != nil {
+		msh.With().Error("could not roll back state", log.LayerID(lyr.Uint64()), log.Err(err))
+		return
+	}
+	for i := lyr; i <= latest; i++ {
+		msh.revertLayer(i)
+	}
+	for _, observer := range msh.stateObservers {
+		if reverter, ok := observer.(stateReverter); ok {
+			reverter.LayersReverted(lyr)
+		}
+	}
+	msh.setLatestLayerInState(lyr - 1)
+	msh.applyState(verified)
+	for i := lyr + 1; i <= latest; i++ {
+		l, err := msh.getAppliedLayer(i)
+		if err != nil {
+			msh.With().Error("could not replay layer after rollback", log.LayerID(i.Uint64()), log.Err(err))
+			return
+		}
+		msh.applyState(l)
+	}
+	msh.With().Info("rolled back state", log.LayerID(lyr.Uint64()),
+		log.String("state_root", util.Bytes2Hex(msh.txProcessor.GetStateRoot().Bytes())))
+}
+
+// revertLayer removes the results of applying layer, which is rolled back: the rewards of the coinbases of its blocks
+// and the applied markers of its transactions. The layers that are applied again record their results anew.
+func (msh *Mesh) revertLayer(layer types.LayerID) {
+	l, err := msh.getAppliedLayer(layer)
+	if err != nil {
+		msh.With().Warning("could not revert results of layer", log.LayerID(layer.Uint64()), log.Err(err))
+		return
+	}
+	var coinbases []types.Address
+	for _, bl := range l.Blocks() {
+		if atx, err := msh.AtxDB.GetAtxHeader(bl.ATXID); err == nil {
+			coinbases = append(coinbases, atx.Coinbase)
+		}
+	}
+	if err := msh.deleteTransactionRewards(layer, coinbases); err != nil {
+		msh.With().Error("could not revert rewards of layer", log.LayerID(layer.Uint64()), log.Err(err))
+	}
+	if err := msh.RevertTransactions(layer, msh.extractUniqueOrderedTransactions(l)); err != nil {
+		msh.With().Error("could not revert transactions of layer", log.LayerID(layer.Uint64()), log.Err(err))
+	}
+}
+
+func appliedKey(layer types.LayerKey) []byte {
+	return append(append([]byte{}, constAPPLIED...), layer.Bytes()...)
+}
+
+func (msh *Mesh) persistAppliedBlocks(l *types.Layer) error {
+	bts, err := types.InterfaceToBytes(types.BlockIDs(l.Blocks()))
+	if err != nil {
+		return err
+	}
+	return msh.general.Put(appliedKey(types.NewLayerKey(l.Index())), bts)
+}
+
+func (msh *Mesh) getAppliedBlocks(layer types.LayerID) ([]types.BlockID, error) {
+	bts, err := msh.general.Get(appliedKey(types.NewLayerKey(layer)))
+	if err != nil {
+		return nil, err
+	}
+	var ids []types.BlockID
+	if err := types.BytesToInterface(bts, &ids); err != nil {
+		return nil, fmt.Errorf("could not deserialize applied blocks: %v", err)
+	}
+	return ids, nil
+}
+
+func (msh *Mesh) getAppliedLayer(layer types.LayerID) (*types.Layer, error) {
+	ids, err := msh.getAppliedBlocks(layer)
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]*types.Block, 0, len(ids))
+	for _, id := range ids {
+		block, err := msh.GetBlock(id)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return types.NewExistingLayer(layer, blocks), nil
+}
+
+// appliedBlocksMatch returns whether the blocks applied to state for the layer are the blocks of l.
+func (msh *Mesh) appliedBlocksMatch(l *types.Layer) bool {
+	ids, err := msh.getAppliedBlocks(l.Index())
+	if err != nil {
+		// applied before the blocks were recorded, or imported with the state
+		return true
+	}
+	return types.CalcBlocksHash32(ids, nil) == types.CalcBlocksHash32(types.BlockIDs(l.Blocks()), nil)
+}
+
+// VerifiedLayer returns the latest layer applied to state that was verified by the tortoise. Layers after it were
+// applied optimistically from hare results, and may be rolled back.
+func (msh *Mesh) VerifiedLayer() types.LayerID {
+	msh.pMutex.RLock()
+	defer msh.pMutex.RUnlock()
+	if msh.verifiedLayer > msh.latestLayerInState {
+		return msh.latestLayerInState
+	}
+	return msh.verifiedLayer
+}
+
+// LayerStateRoot returns the state root at the end of layer, which must have been applied to state.
+func (msh *Mesh) LayerStateRoot(layer types.LayerID) (types.Hash32, error) {
+	return msh.txProcessor.GetLayerStateRoot(layer)
+}
+
+// VerifiedStateRoot returns the latest verified layer and the state root at its end.
+func (msh *Mesh) VerifiedStateRoot() (types.LayerID, types.Hash32, error) {
+	layer := msh.VerifiedLayer()
+	root, err := msh.LayerStateRoot(layer)
+	if err != nil {
+		return 0, types.Hash32{}, fmt.Errorf("no state root for verified layer %v: %v", layer, err)
+	}
+	return layer, root, nil
+}
+
+func (msh *Mesh) isVerified(lyr types.LayerID) bool {
+	msh.pMutex.RLock()
+	defer msh.pMutex.RUnlock()
+	return lyr <= msh.verifiedLayer
+}
+
+func (msh *Mesh) setVerifiedLayer(lyr types.LayerID) {
+	msh.pMutex.Lock()
+	defer msh.pMutex.Unlock()
+	if lyr <= msh.verifiedLayer {
+		return
+	}
+	if err := msh.general.Put(constVERIFIEDSTATE, lyr.Bytes()); err != nil {
+		msh.With().Error("could not persist verified layer", log.LayerID(lyr.Uint64()), log.Err(err))
+	}
+	msh.verifiedLayer = lyr
+}
+
+// SetLatestLayerInState marks lyr as the latest layer applied to state without applying it, after the state of lyr
+// was imported (see state.TransactionProcessor.ImportState). Layers up to lyr are not applied to state afterwards.
+// It must be called from WithStateLocked.
+func (msh *Mesh) SetLatestLayerInState(lyr types.LayerID) {
+	msh.setLatestLayerInState(lyr)
+}
+
 func (msh *Mesh) setLatestLayerInState(lyr types.LayerID) {
 	// update validated layer only after applying transactions since loading of state depends on processedLayer param.
 	msh.pMutex.Lock()
@@ -424,49 +680,24 @@ func (msh *Mesh) persistLayerHash() {
 	}
 }
 
-func (msh *Mesh) extractUniqueOrderedTransactions(l *types.Layer) (validBlockTxs []*types.Transaction) {
-	validBlocks := l.Blocks()
-
-	// Deterministically sort valid blocks
-	types.SortBlocks(validBlocks)
-
-	// Initialize a Mersenne Twister seeded with layerHash
-	blockHash := types.CalcBlockHash32Presorted(types.BlockIDs(validBlocks), nil)
-	mt := mt19937.New()
-	mt.SeedFromSlice(toUint64Slice(blockHash.Bytes()))
-	rng := rand.New(mt)
-
-	// Perform a Fisher-Yates shuffle on the blocks
-	rng.Shuffle(len(validBlocks), func(i, j int) {
-		validBlocks[i], validBlocks[j] = validBlocks[j], validBlocks[i]
-	})
-
-	// Get and return unique transactions
-	seenTxIds := make(map[types.TransactionID]struct{})
-	return msh.getTxs(uniqueTxIds(validBlocks, seenTxIds), l.Index())
+// LayerTransactions returns the transactions of the valid blocks of layer, each once, in the order in which they're
+// applied to state. The transactions must be in the mesh.
+func (msh *Mesh) LayerTransactions(layer *types.Layer) []*types.Transaction {
+	return msh.extractUniqueOrderedTransactions(layer)
 }
 
-func toUint64Slice(b []byte) []uint64 {
-	l := len(b)
-	var s []uint64
-	for i := 0; i < l; i += 8 {
-		s = append(s, binary.LittleEndian.Uint64(b[i:util.Min(l, i+8)]))
+func (msh *Mesh) extractUniqueOrderedTransactions(l *types.Layer) (validBlockTxs []*types.Transaction) {
+	canonical := msh.canonicalTxOrder(l.Index())
+	txs := msh.getTxs(layerTxIDs(l.Blocks(), canonical), l.Index())
+	if canonical {
+		return orderByNonce(txs)
 	}
-	return s
+	return txs
 }
 
-func uniqueTxIds(blocks []*types.Block, seenTxIds map[types.TransactionID]struct{}) []types.TransactionID {
-	var txIds []types.TransactionID
-	for _, b := range blocks {
-		for _, id := range b.TxIDs {
-			if _, found := seenTxIds[id]; found {
-				continue
-			}
-			txIds = append(txIds, id)
-			seenTxIds[id] = struct{}{}
-		}
-	}
-	return txIds
+// canonicalTxOrder returns true if the transactions of layer are ordered by the canonical-layer-tx-order upgrade.
+func (msh *Mesh) canonicalTxOrder(layer types.LayerID) bool {
+	return msh.layersPerEpoch > 0 && msh.upgrades.Active(upgrade.CanonicalLayerTxOrder, layer.GetEpoch(msh.layersPerEpoch))
 }
 
 func (msh *Mesh) getTxs(txIds []types.TransactionID, l types.LayerID) []*types.Transaction {
@@ -551,16 +782,83 @@ func (msh *Mesh) SetZeroBlockLayer(lyr types.LayerID) error {
 	if err != nil {
 		return errors.New("could not encode layer blk ids")
 	}
-	err = msh.layers.Put(lyr.Bytes(), w)
+	err = msh.layers.Put(types.NewLayerKey(lyr).Bytes(), w)
 	return err
 }
 
+// blockIntent is what's needed to add a block again if adding it was interrupted
+type blockIntent struct {
+	Block *types.Block
+	Txs   []*dbTransaction
+	Atxs  []*types.ActivationTx
+}
+
 // AddBlockWithTxs adds a block to the database
 // blk - the block to add
 // txs - block txs that we dont have in our tx database yet
 // atxs - block atxs that we dont have in our atx database yet
+// An intent to add the block is recorded while it's added, so that the block is added again on startup if the node
+// crashes between the writes (see ReplayIntents).
 func (msh *Mesh) AddBlockWithTxs(blk *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error {
+	intent := &blockIntent{Block: blk, Txs: make([]*dbTransaction, 0, len(txs)), Atxs: atxs}
+	for _, tx := range txs {
+		intent.Txs = append(intent.Txs, newDbTransaction(tx))
+	}
+	intentBytes, err := types.InterfaceToBytes(intent)
+	if err != nil {
+		return fmt.Errorf("could not encode block %v: %v", blk.ID(), err)
+	}
+	if err := msh.blockIntents.Begin(blk.ID().Bytes(), intentBytes); err != nil {
+		return fmt.Errorf("could not record intent to add block %v: %v", blk.ID(), err)
+	}
+	if err := msh.addBlockWithTxs(blk, txs, atxs); err != nil {
+		return err
+	}
+	return msh.blockIntents.Done(blk.ID().Bytes())
+}
+
+// ReplayIntents adds again the blocks whose adding was interrupted, e.g. by a crash between the writes of
+// AddBlockWithTxs. It should be called on startup, after the ATX database replayed its own intents and before blocks
+// are received.
+func (msh *Mesh) ReplayIntents() error {
+	return msh.blockIntents.Replay(func(id, data []byte) {
+		var intent blockIntent
+		if err := types.BytesToInterface(data, &intent); err != nil {
+			msh.With().Error("could not decode block of interrupted intent", log.String("block_id", util.Bytes2Hex(id)), log.Err(err))
+			return
+		}
+		blk := intent.Block
+		blk.Initialize()
+		txs := make([]*types.Transaction, 0, len(intent.Txs))
+		for _, tx := range intent.Txs {
+			txs = append(txs, tx.getTransaction())
+		}
+		for _, atx := range intent.Atxs {
+			atx.CalcAndSetID()
+		}
+		msh.With().Info("adding interrupted block again", log.BlockID(blk.ID().String()))
+		key, err := types.NewBlockKey(blk.ID())
+		if err != nil {
+			msh.With().Error("could not add interrupted block", log.BlockID(blk.ID().String()), log.Err(err))
+			return
+		}
+		// the block may have been stored already, delete it so the rest of the block is stored too
+		if err := msh.blocks.Delete(key.Bytes()); err != nil {
+			msh.With().Error("could not delete interrupted block", log.BlockID(blk.ID().String()), log.Err(err))
+			return
+		}
+		if err := msh.addBlockWithTxs(blk, txs, intent.Atxs); err != nil {
+			msh.With().Error("could not add interrupted block", log.BlockID(blk.ID().String()), log.Err(err))
+		}
+	})
+}
+
+func (msh *Mesh) addBlockWithTxs(blk *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error {
 	msh.With().Debug("adding block", blk.Fields()...)
+	key, err := types.NewBlockKey(blk.ID())
+	if err != nil {
+		return err
+	}
 
 	// Store transactions (doesn't have to be rolled back if other writes fail)
 	if len(txs) > 0 {
@@ -582,7 +880,7 @@ func (msh *Mesh) AddBlockWithTxs(blk *types.Block, txs []*types.Transaction, atx
 	// Store ATXs (atomically, delete the block on failure)
 	if err := msh.AtxDB.ProcessAtxs(atxs); err != nil {
 		// Roll back adding the block (delete it)
-		if err := msh.blocks.Delete(blk.ID().Bytes()); err != nil {
+		if err := msh.blocks.Delete(key.Bytes()); err != nil {
 			msh.With().Warning("failed to roll back adding a block", log.Err(err), log.BlockID(blk.ID().String()))
 		}
 		return fmt.Errorf("failed to process ATXs: %v", err)
@@ -600,6 +898,30 @@ func (msh *Mesh) AddBlockWithTxs(blk *types.Block, txs []*types.Transaction, atx
 	return nil
 }
 
+// AddReplicatedLayer stores the valid blocks of a layer and their transactions as received from a trusted leader node
+// and marks the layer as applied to state. It's used by read replicas, which don't validate the mesh and apply the
+// state of the layer themselves (see state.TransactionProcessor.ApplyAccountStates). ATXs must be stored beforehand.
+func (msh *Mesh) AddReplicatedLayer(layer types.LayerID, blocks []*types.Block, txs []*types.Transaction) error {
+	if len(txs) > 0 {
+		if err := msh.writeTransactions(layer, txs); err != nil {
+			return fmt.Errorf("could not write transactions of layer %v: %v", layer, err)
+		}
+	}
+	for _, blk := range blocks {
+		if err := msh.DB.AddBlock(blk); err != nil && err != ErrAlreadyExist {
+			return fmt.Errorf("could not add block %v: %v", blk.ID(), err)
+		}
+		if err := msh.SaveContextualValidity(blk.ID(), true); err != nil {
+			return fmt.Errorf("could not save validity of block %v: %v", blk.ID(), err)
+		}
+	}
+	msh.SetLatestLayer(layer)
+	msh.setLatestLayerInState(layer)
+	msh.With().Info("added replicated layer", log.LayerID(uint64(layer)), log.Int("num_blocks", len(blocks)),
+		log.Int("num_txs", len(txs)))
+	return nil
+}
+
 func (msh *Mesh) invalidateFromPools(blk *types.MiniBlock) {
 	for _, id := range blk.TxIDs {
 		msh.txInvalidator.Invalidate(id)
@@ -619,7 +941,7 @@ func (msh *Mesh) handleOrphanBlocks(blk *types.Block) {
 	}
 	msh.orphanBlocks[blk.Layer()][blk.ID()] = struct{}{}
 	msh.Debug("Added block %s to orphans", blk.ID())
-	for _, b := range blk.ViewEdges {
+	for _, b := range blk.View() {
 		for layerID, layermap := range msh.orphanBlocks {
 			if _, has := layermap[b]; has {
 				msh.Log.Debug("delete block ", b, "from orphans")
@@ -771,9 +1093,7 @@ func NewSignedTx(nonce uint64, rec types.Address, amount, gas, fee uint64, signe
 	}
 
 	copy(sst.Signature[:], signer.Sign(buf))
-	addr := types.Address{}
-	addr.SetBytes(signer.PublicKey().Bytes())
-	sst.SetOrigin(addr)
+	sst.SetOrigin(types.PublicKeyToAddress(signer.PublicKey().Bytes()))
 
 	return sst, nil
 }
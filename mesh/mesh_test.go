@@ -2,6 +2,7 @@ package mesh
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/database"
@@ -71,10 +72,18 @@ func (MockState) LoadState(types.LayerID) error {
 	panic("implement me")
 }
 
+func (MockState) RevertTransactions(types.LayerID, []*types.Transaction) error {
+	panic("implement me")
+}
+
 func (MockState) GetStateRoot() types.Hash32 {
 	return [32]byte{}
 }
 
+func (MockState) GetLayerStateRoot(types.LayerID) (types.Hash32, error) {
+	return [32]byte{}, nil
+}
+
 func (MockState) ValidateNonceAndBalance(*types.Transaction) error {
 	panic("implement me")
 }
@@ -473,7 +482,11 @@ func (FailingAtxDbMock) GetAtxHeader(types.ATXID) (*types.ActivationTxHeader, er
 
 func (FailingAtxDbMock) GetFullAtx(types.ATXID) (*types.ActivationTx, error) { panic("implement me") }
 
-func (FailingAtxDbMock) SyntacticallyValidateAtx(*types.ActivationTx) error { panic("implement me") }
+func (FailingAtxDbMock) GetNodeLastAtxID(types.NodeID) (types.ATXID, error) { panic("implement me") }
+
+func (FailingAtxDbMock) SyntacticallyValidateAtx(context.Context, *types.ActivationTx) error {
+	panic("implement me")
+}
 
 func TestMesh_AddBlockWithTxs(t *testing.T) {
 	r := require.New(t)
@@ -488,3 +501,140 @@ func TestMesh_AddBlockWithTxs(t *testing.T) {
 	_, err = meshDB.blocks.Get(blk.ID().Bytes())
 	r.EqualError(err, "leveldb: not found")
 }
+
+func TestMesh_ReplayIntents(t *testing.T) {
+	r := require.New(t)
+	msh := getMesh("t1")
+	defer msh.Close()
+
+	signer, _ := newSignerAndAddress(r, "origin")
+	blk := addBlockWithTxs(r, msh, 1, true, newTx(r, signer, 0, 111))
+	_, err := msh.general.Get(append([]byte(blockIntentPrefix), blk.ID().Bytes()...))
+	r.Equal(database.ErrNotFound, err)
+
+	// the node crashed after storing a block, before storing its transactions
+	tx := newTx(r, signer, 1, 111)
+	blk = types.NewExistingBlock(1, []byte("data"))
+	blk.TxIDs = []types.TransactionID{tx.ID()}
+	blk.Initialize()
+	intentBytes, err := types.InterfaceToBytes(&blockIntent{Block: blk, Txs: []*dbTransaction{newDbTransaction(tx)}})
+	r.NoError(err)
+	r.NoError(msh.blockIntents.Begin(blk.ID().Bytes(), intentBytes))
+	r.NoError(msh.DB.AddBlock(blk))
+	_, err = msh.GetTransaction(tx.ID())
+	r.Error(err)
+
+	r.NoError(msh.ReplayIntents())
+	_, err = msh.GetTransaction(tx.ID())
+	r.NoError(err)
+	ids, err := msh.LayerBlockIds(1)
+	r.NoError(err)
+	r.Len(ids, 2)
+	r.Contains(ids, blk.ID())
+	_, err = msh.general.Get(append([]byte(blockIntentPrefix), blk.ID().Bytes()...))
+	r.Equal(database.ErrNotFound, err)
+}
+
+type stateObserverMock struct {
+	layers []types.LayerID
+}
+
+func (m *stateObserverMock) LayerApplied(layer *types.Layer) {
+	m.layers = append(m.layers, layer.Index())
+}
+
+func TestMesh_AddStateObserver(t *testing.T) {
+	r := require.New(t)
+	msh := getMesh("observer")
+	defer msh.Close()
+	msh.txProcessor = &MockMapState{}
+	msh.SetBlockBuilder(&MockBlockBuilder{})
+	observer, other := &stateObserverMock{}, &stateObserverMock{}
+	msh.AddStateObserver(observer)
+	msh.AddStateObserver(other)
+
+	signer, _ := newSignerAndAddress(r, "origin")
+	addBlockWithTxs(r, msh, 1, true, addTxToMesh(r, msh, signer, 1))
+	addBlockWithTxs(r, msh, 2, true, addTxToMesh(r, msh, signer, 2))
+	msh.pushLayersToState(1, 3)
+	r.Equal([]types.LayerID{1, 2}, observer.layers)
+	r.Equal([]types.LayerID{1, 2}, other.layers)
+}
+
+func TestMesh_AddReplicatedLayer(t *testing.T) {
+	r := require.New(t)
+	msh := getMesh("replica")
+	defer msh.Close()
+
+	signer, origin := newSignerAndAddress(r, "origin")
+	tx := newTx(r, signer, 1, 111)
+	blk := types.NewExistingBlock(3, []byte("data"))
+	blk.TxIDs = append(blk.TxIDs, tx.ID())
+	blk.Initialize()
+
+	r.NoError(msh.AddReplicatedLayer(3, []*types.Block{blk}, []*types.Transaction{tx}))
+	r.NoError(msh.AddReplicatedLayer(3, []*types.Block{blk}, []*types.Transaction{tx}))
+	r.Equal(types.LayerID(3), msh.LatestLayer())
+	r.Equal(types.LayerID(3), msh.LatestLayerInState())
+
+	valid, err := msh.ContextualValidity(blk.ID())
+	r.NoError(err)
+	r.True(valid)
+	r.Equal([]types.TransactionID{tx.ID()}, msh.GetTransactionsByOrigin(3, origin))
+}
+
+// a layeredMockState with a state root for every layer
+type rootedMockState struct {
+	layeredMockState
+	roots map[types.LayerID]types.Hash32
+}
+
+func (s *rootedMockState) ApplyTransactions(layer types.LayerID, txs []*types.Transaction) (int, error) {
+	s.roots[layer] = types.CalcHash32(append(layer.Bytes(), byte(len(txs))))
+	return s.layeredMockState.ApplyTransactions(layer, txs)
+}
+
+func (s *rootedMockState) GetLayerStateRoot(layer types.LayerID) (types.Hash32, error) {
+	root, ok := s.roots[layer]
+	if !ok {
+		return types.Hash32{}, fmt.Errorf("no state root for layer %v", layer)
+	}
+	return root, nil
+}
+
+func TestMesh_recoverState(t *testing.T) {
+	r := require.New(t)
+	s := &rootedMockState{
+		layeredMockState{MockMapState{Rewards: make(map[types.Address]*big.Int)}, make(map[types.LayerID][]*types.Transaction)},
+		make(map[types.LayerID]types.Hash32),
+	}
+	lg := log.New(t.Name(), "", "")
+	atxDB := NewAtxDbMock()
+	mesh := NewMesh(NewMemMeshDB(lg), atxDB, ConfigTst(), &MeshValidatorMock{}, &MockTxMemPool{}, &MockAtxMemPool{}, s, lg)
+	mesh.SetBlockBuilder(&MockBlockBuilder{})
+	defer mesh.Close()
+
+	for i := 1; i <= 3; i++ {
+		_, blocks := createLayer(t, mesh, types.LayerID(i), 5, 20, atxDB)
+		mesh.HandleValidatedLayer(types.LayerID(i), types.BlockIDs(blocks))
+	}
+	r.Equal(types.LayerID(3), mesh.LatestLayerInState())
+	applied := append([]*types.Transaction{}, s.Txs...)
+
+	// the state is consistent
+	r.NoError(mesh.recoverState())
+	r.Equal(types.LayerID(3), mesh.LatestLayerInState())
+	r.Equal(applied, s.Txs)
+
+	// the state root of the last layer doesn't match its checksum, the state is rolled back to the layer before it
+	s.roots[3] = types.CalcHash32([]byte("corrupted"))
+	r.NoError(mesh.recoverState())
+	r.Equal(types.LayerID(2), mesh.LatestLayerInState())
+	r.Equal(s.layers[2], s.Txs)
+
+	// the blocks applied in layer 2 are missing
+	r.NoError(mesh.general.Delete(appliedKey(types.NewLayerKey(2))))
+	r.NoError(mesh.recoverState())
+	r.Equal(types.LayerID(1), mesh.LatestLayerInState())
+	r.Equal(s.layers[1], s.Txs)
+}
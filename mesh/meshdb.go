@@ -32,6 +32,7 @@ type DB struct {
 	general            database.Database
 	unappliedTxs       database.Database
 	unappliedTxsMutex  sync.Mutex
+	blockIntents       *database.IntentLog
 	orphanBlocks       map[types.LayerID]map[types.BlockID]struct{}
 	layerMutex         map[types.LayerID]*layerMutex
 	lhMutex            sync.Mutex
@@ -74,6 +75,7 @@ func NewPersistentMeshDB(path string, blockCacheSize int, log log.Log) (*DB, err
 		general:            gdb,
 		contextualValidity: vdb,
 		unappliedTxs:       utx,
+		blockIntents:       database.NewIntentLog(gdb, blockIntentPrefix),
 		orphanBlocks:       make(map[types.LayerID]map[types.BlockID]struct{}),
 		layerMutex:         make(map[types.LayerID]*layerMutex),
 		exit:               make(chan struct{}),
@@ -81,6 +83,17 @@ func NewPersistentMeshDB(path string, blockCacheSize int, log log.Log) (*DB, err
 	return ll, nil
 }
 
+// Stores returns the persistent databases backing the mesh, it returns nil for an in memory mesh.
+func (m *DB) Stores() []*database.LDBDatabase {
+	var stores []*database.LDBDatabase
+	for _, db := range []database.Database{m.blocks, m.layers, m.contextualValidity, m.transactions, m.general, m.unappliedTxs} {
+		if ldb, ok := db.(*database.LDBDatabase); ok {
+			stores = append(stores, ldb)
+		}
+	}
+	return stores
+}
+
 // PersistentData checks to see if db is empty
 func (m *DB) PersistentData() bool {
 	if _, err := m.general.Get(constLATEST); err == nil {
@@ -93,15 +106,17 @@ func (m *DB) PersistentData() bool {
 
 // NewMemMeshDB is a mock used for testing
 func NewMemMeshDB(log log.Log) *DB {
+	general := database.NewMemDatabase()
 	ll := &DB{
 		Log:                log,
 		blockCache:         newBlockCache(100 * layerSize),
 		blocks:             database.NewMemDatabase(),
 		layers:             database.NewMemDatabase(),
-		general:            database.NewMemDatabase(),
+		general:            general,
 		contextualValidity: database.NewMemDatabase(),
 		transactions:       database.NewMemDatabase(),
 		unappliedTxs:       database.NewMemDatabase(),
+		blockIntents:       database.NewIntentLog(general, blockIntentPrefix),
 		orphanBlocks:       make(map[types.LayerID]map[types.BlockID]struct{}),
 		layerMutex:         make(map[types.LayerID]*layerMutex),
 		exit:               make(chan struct{}),
@@ -120,6 +135,9 @@ func (m *DB) Close() {
 	m.contextualValidity.Close()
 }
 
+// blockIntentPrefix is the prefix of the keys of the intents to add blocks, see Mesh.ReplayIntents
+const blockIntentPrefix = "block intent "
+
 // ErrAlreadyExist error returned when adding an existing value to the database
 var ErrAlreadyExist = errors.New("block already exist in database")
 
@@ -153,9 +171,52 @@ func (m *DB) GetBlock(id types.BlockID) (*types.Block, error) {
 	mbk := &types.Block{}
 	err = types.BytesToInterface(b, mbk)
 	mbk.Initialize()
+	if err == nil && len(mbk.CompactView) > 0 {
+		var view []types.BlockID
+		if view, err = m.getView(id); err != nil {
+			return nil, fmt.Errorf("could not get view of block %v: %v", id, err)
+		}
+		mbk.SetView(view)
+	}
 	return mbk, err
 }
 
+// viewKey is the key of the expanded compact view of a block, see types.Block.View.
+func viewKey(id types.BlockKey) []byte {
+	return append(append([]byte{}, constVIEW...), id.Bytes()...)
+}
+
+func (m *DB) getView(id types.BlockID) ([]types.BlockID, error) {
+	key, err := types.NewBlockKey(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := m.general.Get(viewKey(key))
+	if err != nil {
+		return nil, err
+	}
+	var view []types.BlockID
+	err = types.BytesToInterface(b, &view)
+	return view, err
+}
+
+// writeView stores the expanded compact view of bl, which can't be derived from the block once the layers it
+// references change. A block added again after an interruption has no expanded view, and the one stored before is
+// kept.
+func (m *DB) writeView(key types.BlockKey, bl *types.Block) error {
+	if bl.View() == nil {
+		if _, err := m.general.Get(viewKey(key)); err != nil {
+			return fmt.Errorf("compact view of block %v isn't expanded", bl.ID())
+		}
+		return nil
+	}
+	b, err := types.InterfaceToBytes(bl.View())
+	if err != nil {
+		return fmt.Errorf("could not encode view of block %v: %v", bl.ID(), err)
+	}
+	return m.general.Put(viewKey(key), b)
+}
+
 // LayerBlocks retrieves all blocks from a layer by layer index
 func (m *DB) LayerBlocks(index types.LayerID) ([]*types.Block, error) {
 	ids, err := m.LayerBlockIds(index)
@@ -213,7 +274,7 @@ func (m *DB) ForBlockInView(view map[types.BlockID]struct{}, layer types.LayerID
 		}
 
 		// push children to bfs queue
-		for _, id := range block.ViewEdges {
+		for _, id := range block.View() {
 			if _, found := seenBlocks[id]; !found {
 				seenBlocks[id] = struct{}{}
 				blocksToVisit.PushBack(id)
@@ -225,7 +286,7 @@ func (m *DB) ForBlockInView(view map[types.BlockID]struct{}, layer types.LayerID
 
 // LayerBlockIds retrieves all block ids from a layer by layer index
 func (m *DB) LayerBlockIds(index types.LayerID) ([]types.BlockID, error) {
-	idsBytes, err := m.layers.Get(index.Bytes())
+	idsBytes, err := m.layers.Get(types.NewLayerKey(index).Bytes())
 	if err != nil {
 		return nil, err
 	}
@@ -243,12 +304,20 @@ func (m *DB) LayerBlockIds(index types.LayerID) ([]types.BlockID, error) {
 }
 
 func (m *DB) getBlockBytes(id types.BlockID) ([]byte, error) {
-	return m.blocks.Get(id.Bytes())
+	key, err := types.NewBlockKey(id)
+	if err != nil {
+		return nil, err
+	}
+	return m.blocks.Get(key.Bytes())
 }
 
 // ContextualValidity retrieves opinion on block from the database
 func (m *DB) ContextualValidity(id types.BlockID) (bool, error) {
-	b, err := m.contextualValidity.Get(id.Bytes())
+	key, err := types.NewBlockKey(id)
+	if err != nil {
+		return false, err
+	}
+	b, err := m.contextualValidity.Get(key.Bytes())
 	if err != nil {
 		return false, err
 	}
@@ -257,6 +326,10 @@ func (m *DB) ContextualValidity(id types.BlockID) (bool, error) {
 
 // SaveContextualValidity persists opinion on block to the database
 func (m *DB) SaveContextualValidity(id types.BlockID, valid bool) error {
+	key, err := types.NewBlockKey(id)
+	if err != nil {
+		return err
+	}
 	var v []byte
 	if valid {
 		v = constTrue
@@ -264,16 +337,44 @@ func (m *DB) SaveContextualValidity(id types.BlockID, valid bool) error {
 		v = constFalse
 	}
 	m.Debug("save contextual validity %v %v", id, valid)
-	return m.contextualValidity.Put(id.Bytes(), v)
+	if err := m.saveContextualTombstone(id, valid); err != nil {
+		return err
+	}
+	return m.contextualValidity.Put(key.Bytes(), v)
+}
+
+// saveContextualTombstone records the tombstone of a block that is found contextually invalid, and removes it once the
+// block is found valid again. The tombstone has the layer of the block if the block is stored.
+func (m *DB) saveContextualTombstone(id types.BlockID, valid bool) error {
+	if valid {
+		if wasValid, err := m.ContextualValidity(id); err == nil && !wasValid {
+			return m.deleteTombstone(id)
+		}
+		return nil
+	}
+	t := &Tombstone{Block: id, Reason: ContextuallyInvalid}
+	if blk, err := m.GetBlock(id); err == nil {
+		t.Layer = blk.Layer()
+	}
+	return m.SaveTombstone(t)
 }
 
 func (m *DB) writeBlock(bl *types.Block) error {
+	key, err := types.NewBlockKey(bl.ID())
+	if err != nil {
+		return err
+	}
 	bytes, err := types.InterfaceToBytes(bl)
 	if err != nil {
 		return fmt.Errorf("could not encode bl")
 	}
+	if len(bl.CompactView) > 0 {
+		if err := m.writeView(key, bl); err != nil {
+			return err
+		}
+	}
 
-	if err := m.blocks.Put(bl.ID().Bytes(), bytes); err != nil {
+	if err := m.blocks.Put(key.Bytes(), bytes); err != nil {
 		return fmt.Errorf("could not add bl %v to database %v", bl.ID(), err)
 	}
 
@@ -289,7 +390,7 @@ func (m *DB) updateLayerWithBlock(blk *types.Block) error {
 	defer m.endLayerWorker(blk.LayerIndex)
 	lm.m.Lock()
 	defer lm.m.Unlock()
-	ids, err := m.layers.Get(blk.LayerIndex.Bytes())
+	ids, err := m.layers.Get(types.NewLayerKey(blk.LayerIndex).Bytes())
 	var blockIds []types.BlockID
 	if err != nil {
 		// layer doesnt exist, need to insert new layer
@@ -300,13 +401,18 @@ func (m *DB) updateLayerWithBlock(blk *types.Block) error {
 			return errors.New("could not get all blocks from database ")
 		}
 	}
+	for _, id := range blockIds {
+		if id == blk.ID() { // the block is added again after its adding was interrupted
+			return nil
+		}
+	}
 	m.Debug("added block %v to layer %v", blk.ID(), blk.LayerIndex)
 	blockIds = append(blockIds, blk.ID())
 	w, err := types.BlockIdsToBytes(blockIds)
 	if err != nil {
 		return errors.New("could not encode layer blk ids")
 	}
-	m.layers.Put(blk.LayerIndex.Bytes(), w)
+	m.layers.Put(types.NewLayerKey(blk.LayerIndex).Bytes(), w)
 	return nil
 }
 
@@ -386,11 +492,15 @@ func (t dbTransaction) getTransaction() *types.Transaction {
 func (m *DB) writeTransactions(l types.LayerID, txs []*types.Transaction) error {
 	batch := m.transactions.NewBatch()
 	for _, t := range txs {
+		key, err := types.NewTxKey(t.ID())
+		if err != nil {
+			return fmt.Errorf("could not write tx %v to database: %v", t.ID().ShortString(), err)
+		}
 		bytes, err := types.InterfaceToBytes(newDbTransaction(t))
 		if err != nil {
 			return fmt.Errorf("could not marshall tx %v to bytes: %v", t.ID().ShortString(), err)
 		}
-		if err := batch.Put(t.ID().Bytes(), bytes); err != nil {
+		if err := batch.Put(key.Bytes(), bytes); err != nil {
 			return fmt.Errorf("could not write tx %v to database: %v", t.ID().ShortString(), err)
 		}
 		// write extra index for querying txs by account
@@ -433,6 +543,30 @@ func (m *DB) writeTransactionRewards(l types.LayerID, accounts []types.Address,
 	return batch.Write()
 }
 
+// deleteTransactionRewards deletes the rewards of accounts in layer l
+func (m *DB) deleteTransactionRewards(l types.LayerID, accounts []types.Address) error {
+	batch := m.transactions.NewBatch()
+	for _, account := range accounts {
+		if err := batch.Delete(getRewardKey(l, account)); err != nil {
+			return fmt.Errorf("could not delete reward of %v from database: %v", account.Short(), err)
+		}
+	}
+	return batch.Write()
+}
+
+// GetLayerReward retrieves the reward of account in layer
+func (m *DB) GetLayerReward(l types.LayerID, account types.Address) (types.Reward, error) {
+	b, err := m.transactions.Get(getRewardKey(l, account))
+	if err != nil {
+		return types.Reward{}, err
+	}
+	var reward dbReward
+	if err := types.BytesToInterface(b, &reward); err != nil {
+		return types.Reward{}, fmt.Errorf("failed to unmarshal reward: %v", err)
+	}
+	return types.Reward{Layer: l, TotalReward: reward.TotalReward, LayerRewardEstimate: reward.LayerRewardEstimate}, nil
+}
+
 // GetRewards retrieves account's rewards by address
 func (m *DB) GetRewards(account types.Address) (rewards []types.Reward, err error) {
 	it := m.transactions.Find(getRewardKeyPrefix(account))
@@ -614,7 +748,11 @@ func (m *DB) GetTransactions(transactions []types.TransactionID) ([]*types.Trans
 
 // GetTransaction retrieves a tx by its id
 func (m *DB) GetTransaction(id types.TransactionID) (*types.Transaction, error) {
-	tBytes, err := m.transactions.Get(id[:])
+	key, err := types.NewTxKey(id)
+	if err != nil {
+		return nil, err
+	}
+	tBytes, err := m.transactions.Get(key.Bytes())
 	if err != nil {
 		return nil, fmt.Errorf("could not find transaction in database %v err=%v", hex.EncodeToString(id[:]), err)
 	}
@@ -720,6 +858,33 @@ func (m *DB) ContextuallyValidBlock(layer types.LayerID) (map[types.BlockID]stru
 	return validBlks, nil
 }
 
+func certificateKey(layer types.LayerKey) []byte {
+	return append(append([]byte{}, constCERTIFICATE...), layer.Bytes()...)
+}
+
+// SaveCertificate persists the certificate of the hare output of a layer.
+func (m *DB) SaveCertificate(cert *types.Certificate) error {
+	bts, err := types.InterfaceToBytes(cert)
+	if err != nil {
+		return fmt.Errorf("could not serialize certificate: %v", err)
+	}
+	return m.general.Put(certificateKey(types.NewLayerKey(cert.Layer)), bts)
+}
+
+// GetCertificate returns the certificate of the hare output of layer, or database.ErrNotFound if the layer is not
+// certified.
+func (m *DB) GetCertificate(layer types.LayerID) (*types.Certificate, error) {
+	bts, err := m.general.Get(certificateKey(types.NewLayerKey(layer)))
+	if err != nil {
+		return nil, err
+	}
+	var cert types.Certificate
+	if err := types.BytesToInterface(bts, &cert); err != nil {
+		return nil, fmt.Errorf("could not deserialize certificate: %v", err)
+	}
+	return &cert, nil
+}
+
 // Persist persists an item v into the database using key as its id
 func (m *DB) Persist(key []byte, v interface{}) error {
 	buf, err := types.InterfaceToBytes(v)
@@ -77,6 +77,31 @@ func TestMeshDB_AddBlock(t *testing.T) {
 	// assert.True(t, bytes.Compare(rBlock2.Data, []byte("data2")) == 0, "block content was wrong")
 }
 
+func TestMeshDB_AddBlockCompactView(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.NewDefault(t.Name()))
+	defer mdb.Close()
+
+	b1 := types.NewExistingBlock(1, []byte("data1"))
+	b2 := types.NewExistingBlock(1, []byte("data2"))
+	r.NoError(mdb.AddBlock(b1))
+	r.NoError(mdb.AddBlock(b2))
+	view := []types.BlockID{b1.ID(), b2.ID()}
+
+	blk := types.NewExistingBlock(2, []byte("data3"))
+	blk.CompactView = []types.LayerView{{Layer: 1, Hash: types.CalcBlocksHash32(view, nil)}}
+	blk.Initialize()
+	// the compact view must be expanded before the block is stored
+	r.Error(mdb.AddBlock(blk))
+
+	blk.SetView(view)
+	r.NoError(mdb.AddBlock(blk))
+	mdb.blockCache = newBlockCache(10)
+	stored, err := mdb.GetBlock(blk.ID())
+	r.NoError(err)
+	r.Equal(view, stored.View())
+}
+
 func chooseRandomPattern(blocksInLayer int, patternSize int) []int {
 	rand.Seed(time.Now().UnixNano())
 	p := rand.Perm(blocksInLayer)
@@ -518,4 +543,57 @@ func TestMeshDB_testGetRewards(t *testing.T) {
 	rewards, err = mdb.GetRewards(addr4)
 	r.NoError(err)
 	r.Nil(rewards)
+
+	reward, err := mdb.GetLayerReward(3, addr2)
+	r.NoError(err)
+	r.Equal(types.Reward{Layer: 3, TotalReward: 30000, LayerRewardEstimate: 29000}, reward)
+	_, err = mdb.GetLayerReward(3, addr1)
+	r.Equal(database.ErrNotFound, err)
+}
+
+func TestMeshDB_Certificate(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+	defer mdb.Close()
+
+	_, err := mdb.GetCertificate(1)
+	r.Equal(database.ErrNotFound, err)
+
+	cert := &types.Certificate{
+		Layer:      1,
+		BlockIDs:   []types.BlockID{types.NewExistingBlock(1, []byte("data1")).ID()},
+		Signatures: []types.CertifySignature{{Proof: []byte{1}, Signature: []byte{2}}},
+	}
+	r.NoError(mdb.SaveCertificate(cert))
+	got, err := mdb.GetCertificate(1)
+	r.NoError(err)
+	r.Equal(cert, got)
+}
+
+func TestMeshDB_Tombstone(t *testing.T) {
+	r := require.New(t)
+	mdb := NewMemMeshDB(log.New(t.Name(), "", ""))
+	defer mdb.Close()
+
+	invalid := types.NewExistingBlock(3, []byte("invalid"))
+	_, err := mdb.GetTombstone(invalid.ID())
+	r.Equal(database.ErrNotFound, err)
+
+	ts := &Tombstone{Block: invalid.ID(), Layer: 3, Reason: InvalidContents, Detail: "block atxs don't match the referenced ones"}
+	r.NoError(mdb.SaveTombstone(ts))
+	got, err := mdb.GetTombstone(invalid.ID())
+	r.NoError(err)
+	r.Equal(ts, got)
+	r.Equal("invalid contents", got.Reason.String())
+
+	// contextually invalid blocks are tombstoned until they're found valid
+	blk := types.NewExistingBlock(4, []byte("data"))
+	r.NoError(mdb.AddBlock(blk))
+	r.NoError(mdb.SaveContextualValidity(blk.ID(), false))
+	got, err = mdb.GetTombstone(blk.ID())
+	r.NoError(err)
+	r.Equal(&Tombstone{Block: blk.ID(), Layer: 4, Reason: ContextuallyInvalid}, got)
+	r.NoError(mdb.SaveContextualValidity(blk.ID(), true))
+	_, err = mdb.GetTombstone(blk.ID())
+	r.Equal(database.ErrNotFound, err)
 }
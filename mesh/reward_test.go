@@ -6,6 +6,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/rand"
 	"github.com/spacemeshos/go-spacemesh/signing"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"math/big"
 	"strconv"
 	"testing"
@@ -21,6 +22,11 @@ func (s MockMapState) LoadState(types.LayerID) error                    { panic(
 func (MockMapState) GetStateRoot() types.Hash32                         { return [32]byte{} }
 func (MockMapState) ValidateNonceAndBalance(*types.Transaction) error   { panic("implement me") }
 func (MockMapState) GetLayerApplied(types.TransactionID) *types.LayerID { panic("implement me") }
+func (MockMapState) RevertTransactions(types.LayerID, []*types.Transaction) error {
+	return nil
+}
+
+func (MockMapState) GetLayerStateRoot(types.LayerID) (types.Hash32, error) { return [32]byte{}, nil }
 
 func (s *MockMapState) ApplyTransactions(_ types.LayerID, txs []*types.Transaction) (int, error) {
 	s.Txs = append(s.Txs, txs...)
@@ -243,6 +249,244 @@ func TestMesh_updateStateWithLayer(t *testing.T) {
 	assert.Equal(t, s.Txs, s3.Txs)
 }
 
+// a MockMapState that keeps the transactions applied up to every layer, so that it can be reverted
+type layeredMockState struct {
+	MockMapState
+	layers map[types.LayerID][]*types.Transaction
+}
+
+func (s *layeredMockState) ApplyTransactions(layer types.LayerID, txs []*types.Transaction) (int, error) {
+	s.Txs = append(s.Txs, txs...)
+	s.layers[layer] = append([]*types.Transaction{}, s.Txs...)
+	return 0, nil
+}
+
+func (s *layeredMockState) LoadState(layer types.LayerID) error {
+	s.Txs = append([]*types.Transaction{}, s.layers[layer]...)
+	return nil
+}
+
+func TestMesh_updateStateWithLayer_Rollback(t *testing.T) {
+	r := require.New(t)
+	s := &layeredMockState{MockMapState{Rewards: make(map[types.Address]*big.Int)}, make(map[types.LayerID][]*types.Transaction)}
+	lg := log.New(t.Name(), "", "")
+	atxDB := NewAtxDbMock()
+	mesh := NewMesh(NewMemMeshDB(lg), atxDB, ConfigTst(), &MeshValidatorMock{}, &MockTxMemPool{}, &MockAtxMemPool{}, s, lg)
+	mesh.SetBlockBuilder(&MockBlockBuilder{})
+	defer mesh.Close()
+
+	var blocks [][]*types.Block
+	for i := 1; i <= 3; i++ {
+		_, lyrBlocks := createLayer(t, mesh, types.LayerID(i), 5, 20, atxDB)
+		blocks = append(blocks, lyrBlocks)
+		for _, b := range lyrBlocks {
+			r.NoError(mesh.SaveContextualValidity(b.ID(), true))
+		}
+		// the hare outputs all blocks, and they're applied optimistically
+		mesh.HandleValidatedLayer(types.LayerID(i), types.BlockIDs(lyrBlocks))
+	}
+	r.Equal(types.LayerID(3), mesh.LatestLayerInState())
+	r.Equal(types.LayerID(0), mesh.VerifiedLayer())
+
+	// the tortoise agrees on layer 1
+	optimistic := append([]*types.Transaction{}, s.Txs...)
+	l, err := mesh.GetLayer(2)
+	r.NoError(err)
+	mesh.ValidateLayer(l)
+	r.Equal(types.LayerID(1), mesh.VerifiedLayer())
+	r.Equal(optimistic, s.Txs)
+
+	// but finds a block of layer 2 invalid
+	var invalid *types.Block
+	for _, b := range blocks[1] {
+		if len(b.TxIDs) > 0 {
+			invalid = b
+			break
+		}
+	}
+	r.NotNil(invalid)
+	r.NoError(mesh.SaveContextualValidity(invalid.ID(), false))
+	l, err = mesh.GetLayer(3)
+	r.NoError(err)
+	mesh.ValidateLayer(l)
+
+	r.Equal(types.LayerID(2), mesh.VerifiedLayer())
+	r.Equal(types.LayerID(3), mesh.LatestLayerInState())
+	var expected []*types.Transaction
+	for i, lyrBlocks := range blocks {
+		var valid []*types.Block
+		for _, b := range lyrBlocks {
+			if b.ID() != invalid.ID() {
+				valid = append(valid, b)
+			}
+		}
+		expected = append(expected, mesh.extractUniqueOrderedTransactions(types.NewExistingLayer(types.LayerID(i+1), valid))...)
+	}
+	r.Equal(expected, s.Txs)
+	r.NotEqual(optimistic, s.Txs)
+
+	// a late hare result for a verified layer is ignored
+	mesh.HandleValidatedLayer(2, types.BlockIDs(blocks[1]))
+	r.Equal(expected, s.Txs)
+}
+
+// a layeredMockState that records the layers in which transactions were applied
+type appliedMockState struct {
+	layeredMockState
+	applied map[types.TransactionID]types.LayerID
+}
+
+func (s *appliedMockState) ApplyTransactions(layer types.LayerID, txs []*types.Transaction) (int, error) {
+	for _, tx := range txs {
+		s.applied[tx.ID()] = layer
+	}
+	return s.layeredMockState.ApplyTransactions(layer, txs)
+}
+
+func (s *appliedMockState) GetLayerApplied(id types.TransactionID) *types.LayerID {
+	if layer, ok := s.applied[id]; ok {
+		return &layer
+	}
+	return nil
+}
+
+func (s *appliedMockState) RevertTransactions(layer types.LayerID, txs []*types.Transaction) error {
+	for _, tx := range txs {
+		if s.applied[tx.ID()] == layer {
+			delete(s.applied, tx.ID())
+		}
+	}
+	return nil
+}
+
+func TestMesh_updateStateWithLayer_RollbackResults(t *testing.T) {
+	r := require.New(t)
+	s := &appliedMockState{
+		layeredMockState{MockMapState{Rewards: make(map[types.Address]*big.Int)}, make(map[types.LayerID][]*types.Transaction)},
+		make(map[types.TransactionID]types.LayerID),
+	}
+	lg := log.New(t.Name(), "", "")
+	atxDB := NewAtxDbMock()
+	mesh := NewMesh(NewMemMeshDB(lg), atxDB, ConfigTst(), &MeshValidatorMock{}, &MockTxMemPool{}, &MockAtxMemPool{}, s, lg)
+	mesh.SetBlockBuilder(&MockBlockBuilder{})
+	defer mesh.Close()
+
+	var blocks [][]*types.Block
+	for i := 1; i <= 3; i++ {
+		_, lyrBlocks := createLayer(t, mesh, types.LayerID(i), 5, 20, atxDB)
+		blocks = append(blocks, lyrBlocks)
+		for _, b := range lyrBlocks {
+			r.NoError(mesh.SaveContextualValidity(b.ID(), true))
+		}
+		mesh.HandleValidatedLayer(types.LayerID(i), types.BlockIDs(lyrBlocks))
+	}
+	l, err := mesh.GetLayer(2)
+	r.NoError(err)
+	mesh.ValidateLayer(l)
+
+	// the tortoise finds a block of layer 2 invalid, whose transactions aren't in the other blocks
+	var invalid *types.Block
+	for _, b := range blocks[1] {
+		if len(b.TxIDs) > 0 {
+			invalid = b
+			break
+		}
+	}
+	r.NotNil(invalid)
+	atx, err := atxDB.GetAtxHeader(invalid.ATXID)
+	r.NoError(err)
+	_, err = mesh.GetLayerReward(2, atx.Coinbase)
+	r.NoError(err)
+	for _, id := range invalid.TxIDs {
+		r.Equal(types.LayerID(2), *s.GetLayerApplied(id))
+	}
+	r.NoError(mesh.SaveContextualValidity(invalid.ID(), false))
+	l, err = mesh.GetLayer(3)
+	r.NoError(err)
+	mesh.ValidateLayer(l)
+	r.Equal(types.LayerID(2), mesh.VerifiedLayer())
+
+	// the rewards and the transactions of the invalid block aren't reported anymore
+	_, err = mesh.GetLayerReward(2, atx.Coinbase)
+	r.Error(err)
+	for _, id := range invalid.TxIDs {
+		r.Nil(s.GetLayerApplied(id))
+	}
+	// the rest of the layers were applied again
+	for i, lyrBlocks := range blocks {
+		for _, b := range lyrBlocks {
+			if b.ID() == invalid.ID() {
+				continue
+			}
+			atx, err := atxDB.GetAtxHeader(b.ATXID)
+			r.NoError(err)
+			_, err = mesh.GetLayerReward(types.LayerID(i+1), atx.Coinbase)
+			r.NoError(err)
+			for _, id := range b.TxIDs {
+				r.Equal(types.LayerID(i+1), *s.GetLayerApplied(id))
+			}
+		}
+	}
+}
+
+func TestMesh_updateStateWithLayer_FinalLayer(t *testing.T) {
+	r := require.New(t)
+	s := &layeredMockState{MockMapState{Rewards: make(map[types.Address]*big.Int)}, make(map[types.LayerID][]*types.Transaction)}
+	lg := log.New(t.Name(), "", "")
+	atxDB := NewAtxDbMock()
+	mesh := NewMesh(NewMemMeshDB(lg), atxDB, ConfigTst(), &MeshValidatorMock{}, &MockTxMemPool{}, &MockAtxMemPool{}, s, lg)
+	mesh.SetBlockBuilder(&MockBlockBuilder{})
+	mesh.SetFinalityDepth(1)
+	defer mesh.Close()
+
+	var blocks [][]*types.Block
+	for i := 1; i <= 3; i++ {
+		_, lyrBlocks := createLayer(t, mesh, types.LayerID(i), 5, 20, atxDB)
+		blocks = append(blocks, lyrBlocks)
+		for _, b := range lyrBlocks {
+			r.NoError(mesh.SaveContextualValidity(b.ID(), true))
+		}
+		mesh.HandleValidatedLayer(types.LayerID(i), types.BlockIDs(lyrBlocks))
+	}
+	r.Equal(types.LayerID(2), mesh.FinalizedLayer())
+	_, err := mesh.ApproveReorg()
+	r.Equal(errNoRefusedReorg, err)
+
+	// the tortoise finds a block of the final layer 2 invalid, the state isn't rolled back
+	optimistic := append([]*types.Transaction{}, s.Txs...)
+	var invalid *types.Block
+	for _, b := range blocks[1] {
+		if len(b.TxIDs) > 0 {
+			invalid = b
+			break
+		}
+	}
+	r.NotNil(invalid)
+	r.NoError(mesh.SaveContextualValidity(invalid.ID(), false))
+	for i := 2; i <= 3; i++ {
+		l, err := mesh.GetLayer(types.LayerID(i))
+		r.NoError(err)
+		mesh.ValidateLayer(l)
+	}
+	r.Equal(types.LayerID(2), mesh.VerifiedLayer())
+	r.Equal(optimistic, s.Txs)
+	refused, ok := mesh.RefusedReorg()
+	r.True(ok)
+	r.Equal(types.LayerID(2), refused)
+
+	// until it's approved
+	from, err := mesh.ApproveReorg()
+	r.NoError(err)
+	r.Equal(types.LayerID(2), from)
+	_, ok = mesh.RefusedReorg()
+	r.False(ok)
+	r.NotEqual(optimistic, s.Txs)
+	l, err := mesh.getAppliedLayer(2)
+	r.NoError(err)
+	r.Len(l.Blocks(), len(blocks[1])-1)
+	r.Equal(types.LayerID(3), mesh.LatestLayerInState())
+}
+
 func copyLayer(t *testing.T, srcMesh, dstMesh *Mesh, dstAtxDb *AtxDbMock, id types.LayerID) []types.BlockID {
 	l, err := srcMesh.GetLayer(id)
 	assert.NoError(t, err)
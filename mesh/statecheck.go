@@ -0,0 +1,110 @@
+package mesh
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+var constSTATECHECKSUM = []byte("state checksum")
+
+func stateChecksumKey(layer types.LayerKey) []byte {
+	return append(append([]byte{}, constSTATECHECKSUM...), layer.Bytes()...)
+}
+
+// stateChecksum chains the state root of a layer and the blocks applied to state in it to the checksum of the layer
+// before it, so that the checksum of a layer covers the state of all the layers before it.
+func stateChecksum(prev types.Hash32, layer types.LayerID, root types.Hash32, blocks []types.BlockID) types.Hash32 {
+	applied := types.CalcBlocksHash32(blocks, nil)
+	buf := make([]byte, 0, 3*types.Hash32Length+8)
+	buf = append(buf, prev.Bytes()...)
+	buf = append(buf, layer.Bytes()...)
+	buf = append(buf, root.Bytes()...)
+	buf = append(buf, applied.Bytes()...)
+	return types.CalcHash32(buf)
+}
+
+// getStateChecksum returns the checksum of layer, or the zero hash if it has none: it's the genesis layer, or it was
+// applied by a version that didn't write checksums.
+func (msh *Mesh) getStateChecksum(layer types.LayerID) (types.Hash32, bool, error) {
+	b, err := msh.general.Get(stateChecksumKey(types.NewLayerKey(layer)))
+	if err == database.ErrNotFound {
+		return types.Hash32{}, false, nil
+	}
+	if err != nil {
+		return types.Hash32{}, false, err
+	}
+	return types.BytesToHash(b), true, nil
+}
+
+// persistStateChecksum stores the checksum of a layer that was applied to state. Must be called with txMutex held.
+func (msh *Mesh) persistStateChecksum(l *types.Layer) error {
+	root, err := msh.txProcessor.GetLayerStateRoot(l.Index())
+	if err != nil {
+		return fmt.Errorf("no state root: %v", err)
+	}
+	var prev types.Hash32
+	if l.Index() > 0 {
+		if prev, _, err = msh.getStateChecksum(l.Index() - 1); err != nil {
+			return err
+		}
+	}
+	checksum := stateChecksum(prev, l.Index(), root, types.BlockIDs(l.Blocks()))
+	return msh.general.Put(stateChecksumKey(types.NewLayerKey(l.Index())), checksum.Bytes())
+}
+
+// checkStateChecksum checks that the stored state root and applied blocks of layer match its stored checksum.
+func (msh *Mesh) checkStateChecksum(layer types.LayerID) error {
+	stored, found, err := msh.getStateChecksum(layer)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	root, err := msh.txProcessor.GetLayerStateRoot(layer)
+	if err != nil {
+		return fmt.Errorf("no state root: %v", err)
+	}
+	blocks, err := msh.getAppliedBlocks(layer)
+	if err != nil {
+		return fmt.Errorf("no applied blocks: %v", err)
+	}
+	var prev types.Hash32
+	if layer > 0 {
+		if prev, _, err = msh.getStateChecksum(layer - 1); err != nil {
+			return err
+		}
+	}
+	if checksum := stateChecksum(prev, layer, root, blocks); checksum != stored {
+		return fmt.Errorf("checksum %v doesn't match the stored checksum %v", checksum.ShortString(), stored.ShortString())
+	}
+	return nil
+}
+
+// recoverState loads the state of the latest layer applied to state whose state root and applied blocks match their
+// checksum chain, and whose state can be loaded. If that's an earlier layer, the layers after it are rolled back, and
+// applied again from the mesh before the node joins gossip.
+func (msh *Mesh) recoverState() error {
+	latest := msh.LatestLayerInState()
+	for layer := latest; ; layer-- {
+		err := msh.checkStateChecksum(layer)
+		if err == nil {
+			err = msh.txProcessor.LoadState(layer)
+		}
+		if err == nil {
+			if layer < latest {
+				msh.With().Warning("rolled back inconsistent state to the latest consistent layer",
+					log.LayerID(layer.Uint64()), log.Uint64("latest_layer_in_state", latest.Uint64()))
+				msh.setLatestLayerInState(layer)
+			}
+			return nil
+		}
+		msh.With().Error("state of layer is inconsistent", log.LayerID(layer.Uint64()), log.Err(err))
+		if layer == 0 {
+			return fmt.Errorf("no consistent state found up to layer %v", latest)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mesh
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// TombstoneReason is the reason a block was found invalid.
+type TombstoneReason uint8
+
+// The reasons a block is found invalid. The values are persisted, new reasons are added at the end.
+const (
+	// InvalidHeader blocks have an invalid header: signature, eligibility proof or atx.
+	InvalidHeader TombstoneReason = iota + 1
+	// InvalidView blocks vote for or see blocks that are invalid or can't be found.
+	InvalidView
+	// InvalidContents blocks carry invalid transactions or atxs.
+	InvalidContents
+	// InvalidVotes blocks vote for blocks that are too far back or not in their view.
+	InvalidVotes
+	// ContextuallyInvalid blocks are syntactically valid, but the tortoise voted against them.
+	ContextuallyInvalid
+)
+
+var tombstoneReasons = map[TombstoneReason]string{
+	InvalidHeader:       "invalid header",
+	InvalidView:         "invalid view",
+	InvalidContents:     "invalid contents",
+	InvalidVotes:        "invalid votes",
+	ContextuallyInvalid: "contextually invalid",
+}
+
+func (r TombstoneReason) String() string {
+	if s, ok := tombstoneReasons[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown reason %d", r)
+}
+
+// Tombstone records that a block was found invalid. Syntactically invalid blocks aren't stored, their tombstone is
+// what the node keeps of them, so that it doesn't fetch them again whenever another block or a peer refers to them.
+type Tombstone struct {
+	Block  types.BlockID
+	Layer  types.LayerID
+	Reason TombstoneReason
+	Detail string // the validation error
+}
+
+var constTOMBSTONE = []byte("tombstone")
+
+func tombstoneKey(id types.BlockKey) []byte {
+	return append(append([]byte{}, constTOMBSTONE...), id.Bytes()...)
+}
+
+// SaveTombstone persists the tombstone of an invalid block.
+func (m *DB) SaveTombstone(t *Tombstone) error {
+	key, err := types.NewBlockKey(t.Block)
+	if err != nil {
+		return err
+	}
+	b, err := types.InterfaceToBytes(t)
+	if err != nil {
+		return fmt.Errorf("could not serialize tombstone: %v", err)
+	}
+	return m.general.Put(tombstoneKey(key), b)
+}
+
+// GetTombstone returns the tombstone of a block, or database.ErrNotFound if the block wasn't found invalid.
+func (m *DB) GetTombstone(id types.BlockID) (*Tombstone, error) {
+	key, err := types.NewBlockKey(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := m.general.Get(tombstoneKey(key))
+	if err != nil {
+		return nil, err
+	}
+	var t Tombstone
+	if err := types.BytesToInterface(b, &t); err != nil {
+		return nil, fmt.Errorf("could not deserialize tombstone: %v", err)
+	}
+	return &t, nil
+}
+
+func (m *DB) deleteTombstone(id types.BlockID) error {
+	key, err := types.NewBlockKey(id)
+	if err != nil {
+		return err
+	}
+	return m.general.Delete(tombstoneKey(key))
+}
@@ -0,0 +1,198 @@
+package miner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+)
+
+// nipstFetchTimeout bounds the fetch of the NIPSTs of pending atxs before a block is built, atxs whose NIPST isn't
+// fetched in time are included in a later block
+const nipstFetchTimeout = 5 * time.Second
+
+// nipstFetchWorkers is the number of NIPSTs fetched at once
+const nipstFetchWorkers = 8
+
+var errNipstNotInPool = errors.New("NIPST not in pool")
+
+type nipstFetcher interface {
+	Fetch(ctx context.Context, sender p2pcrypto.PublicKey, id types.ATXID, nipstHash types.Hash32) (*types.NIPST, error)
+}
+
+// pendingAtx is an atx whose header was validated, that's included in blocks once its NIPST is fetched and validated.
+type pendingAtx struct {
+	atx       *types.ActivationTx
+	nipstHash types.Hash32
+	sender    p2pcrypto.PublicKey
+}
+
+// pendingAtxs are the atxs gossiped without their NIPST, by id.
+type pendingAtxs struct {
+	sync.Mutex
+	atxs map[types.ATXID]*pendingAtx
+}
+
+// SetNipstFetcher sets where the NIPSTs of atxs gossiped without them are fetched from: local, the node's own atxs,
+// is tried before fetcher, which requests them from peers. It must be called before the builder starts.
+func (t *BlockBuilder) SetNipstFetcher(local activation.NipstSource, fetcher nipstFetcher) {
+	t.localNipsts = local
+	t.nipstFetcher = fetcher
+}
+
+// GetNipst returns the NIPST of the atx with id if it's in the atx pool, so that peers that got the atx without its
+// NIPST fetch it from the node.
+func (t *BlockBuilder) GetNipst(id types.ATXID) (*types.NIPST, error) {
+	atx, err := t.AtxPool.Get(id)
+	if err != nil || atx.Nipst == nil {
+		return nil, errNipstNotInPool
+	}
+	return atx.Nipst, nil
+}
+
+func (t *BlockBuilder) handleGossipAtxHeader(data service.GossipMessage) {
+	if data == nil {
+		return
+	}
+	var header types.AtxHeader
+	if err := types.BytesToInterface(data.Bytes(), &header); err != nil || header.Atx == nil ||
+		header.Atx.InnerActivationTx == nil || header.Atx.ActivationTxHeader == nil {
+		t.Error("cannot parse incoming ATX header")
+		return
+	}
+	atx := header.Atx
+	if atx.Nipst != nil {
+		t.Warning("received ATX header (%v) with a NIPST", atx.ShortString())
+		return
+	}
+	atx.CalcAndSetID()
+
+	// headers seen recently were already handled, whether they were valid or not, see handleGossipAtx
+	if t.atxReplays.Replayed(types.CalcHash32(data.Bytes()).Bytes(), data.Sender()) {
+		return
+	}
+
+	t.With().Info("got new ATX header", atx.Fields(t.layersPerEpoch, len(data.Bytes()))...)
+
+	ctx, cancel := util.StopContext(t.stopChan)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, atxValidationTimeout)
+	defer cancelTimeout()
+	if err := t.atxValidator.SyntacticallyValidateAtxHeader(ctx, atx, header.NipstHash); err != nil {
+		t.Warning("received syntactically invalid ATX header %v: %v", atx.ShortString(), err)
+		// TODO: blacklist peer
+		return
+	}
+
+	t.pending.Lock()
+	t.pending.atxs[atx.ID()] = &pendingAtx{atx: atx, nipstHash: header.NipstHash, sender: data.Sender()}
+	t.pending.Unlock()
+	data.ReportValidation(activation.AtxHeaderProtocol)
+	t.With().Info("propagated new ATX header, its NIPST is fetched before it's included in a block",
+		log.AtxID(atx.ShortString()))
+}
+
+// resolvePendingAtxs fetches and validates the NIPSTs of the pending atxs, the atxs whose NIPST is valid are added to
+// the atx pool. It's called when the node is eligible to build a block in layer, which is when it needs the atxs. Atxs
+// that target an epoch before the layer's are dropped, they can't be included in blocks anymore.
+func (t *BlockBuilder) resolvePendingAtxs(layer types.LayerID) {
+	epoch := layer.GetEpoch(t.layersPerEpoch)
+	t.pending.Lock()
+	var pending []*pendingAtx
+	for id, p := range t.pending.atxs {
+		if p.atx.TargetEpoch(t.layersPerEpoch) < epoch {
+			delete(t.pending.atxs, id)
+			continue
+		}
+		pending = append(pending, p)
+	}
+	t.pending.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := util.StopContext(t.stopChan)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, nipstFetchTimeout)
+	defer cancelTimeout()
+	queue := make(chan *pendingAtx, len(pending))
+	for _, p := range pending {
+		queue <- p
+	}
+	close(queue)
+	var wg sync.WaitGroup
+	for i := 0; i < nipstFetchWorkers && i < len(pending); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				if ctx.Err() != nil {
+					return
+				}
+				t.resolvePendingAtx(ctx, p)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// resolvePendingAtx fetches the NIPST of p and validates the atx with it. The atx stays pending if its NIPST can't be
+// fetched, and is dropped once it's validated.
+func (t *BlockBuilder) resolvePendingAtx(ctx context.Context, p *pendingAtx) {
+	id := p.atx.ID()
+	nipst, err := t.fetchNipst(ctx, p)
+	if err != nil {
+		t.With().Warning("could not fetch NIPST of atx", log.AtxID(id.ShortString()), log.Err(err))
+		return
+	}
+
+	t.pending.Lock()
+	delete(t.pending.atxs, id)
+	t.pending.Unlock()
+
+	inner := *p.atx.InnerActivationTx
+	inner.Nipst = nipst
+	atx := &types.ActivationTx{InnerActivationTx: &inner, Sig: p.atx.Sig}
+	if err := t.syncer.FetchPoetProof(atx.GetPoetProofRef()); err != nil {
+		t.Warning("received ATX (%v) with syntactically invalid or missing PoET proof (%x): %v",
+			atx.ShortString(), atx.GetShortPoetProofRef(), err)
+		return
+	}
+	// the fetch timeout doesn't bound the validation, which may traverse the atx's view
+	vctx, cancel := util.StopContext(t.stopChan)
+	defer cancel()
+	vctx, cancelTimeout := context.WithTimeout(vctx, atxValidationTimeout)
+	defer cancelTimeout()
+	err = t.atxValidator.SyntacticallyValidateAtx(vctx, atx)
+	events.Publish(events.ValidAtx{ID: atx.ShortString(), Valid: err == nil})
+	if err != nil {
+		t.Warning("received syntactically invalid ATX %v: %v", atx.ShortString(), err)
+		return
+	}
+	t.AtxPool.Put(atx)
+	t.With().Info("stored new syntactically valid ATX with its fetched NIPST", log.AtxID(atx.ShortString()))
+}
+
+// fetchNipst returns the NIPST of p from the node's own atxs, or from peers.
+func (t *BlockBuilder) fetchNipst(ctx context.Context, p *pendingAtx) (*types.NIPST, error) {
+	if t.localNipsts != nil {
+		if nipst, err := t.localNipsts.GetNipst(p.atx.ID()); err == nil {
+			if hash, err := nipst.Hash(); err == nil && hash == p.nipstHash {
+				return nipst, nil
+			}
+		}
+	}
+	if t.nipstFetcher == nil {
+		return nil, fmt.Errorf("no NIPST fetcher")
+	}
+	return t.nipstFetcher.Fetch(ctx, p.sender, p.atx.ID(), p.nipstHash)
+}
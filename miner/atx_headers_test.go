@@ -0,0 +1,115 @@
+package miner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+type mockNipstFetcher struct {
+	nipsts  map[types.ATXID]*types.NIPST
+	fetched int
+}
+
+func (m *mockNipstFetcher) GetNipst(id types.ATXID) (*types.NIPST, error) {
+	if nipst, ok := m.nipsts[id]; ok {
+		return nipst, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (m *mockNipstFetcher) Fetch(_ context.Context, _ p2pcrypto.PublicKey, id types.ATXID, _ types.Hash32) (*types.NIPST, error) {
+	m.fetched++
+	return m.GetNipst(id)
+}
+
+func TestBlockBuilder_AtxHeaderGossip(t *testing.T) {
+	r := require.New(t)
+	net := service.NewSimulator()
+	n1 := net.NewNode()
+	builder := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, make(chan types.LayerID), 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{}, MockHare{}, &mockBlockOracle{}, mockTxProcessor{}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.New(n1.String(), "", ""))
+	local := &mockNipstFetcher{nipsts: make(map[types.ATXID]*types.NIPST)}
+	peers := &mockNipstFetcher{nipsts: make(map[types.ATXID]*types.NIPST)}
+	builder.SetNipstFetcher(local, peers)
+	r.NoError(builder.Start())
+	defer builder.Close()
+
+	nipst := activation.NewNIPSTWithChallenge(&types.Hash32{}, []byte{0xba, 0x38})
+	nipstHash, err := nipst.Hash()
+	r.NoError(err)
+	// published in epoch 0, targets epoch 1
+	atx := newActivationTx(types.NodeID{Key: "aaaa", VRFPublicKey: []byte("bbb")}, 1, types.ATXID(types.Hash32{1}), 5, 1, types.ATXID{}, types.HexToAddress("aaaa"), 5, nil, nil)
+	bts, err := types.InterfaceToBytes(&types.AtxHeader{Atx: atx, NipstHash: nipstHash})
+	r.NoError(err)
+	r.NoError(n1.Broadcast(activation.AtxHeaderProtocol, bts))
+	time.Sleep(300 * time.Millisecond)
+
+	// the header is validated, but the atx isn't included in blocks before its NIPST is fetched
+	builder.pending.Lock()
+	r.Len(builder.pending.atxs, 1)
+	builder.pending.Unlock()
+	r.Empty(builder.AtxPool.GetAllItems())
+	_, err = builder.GetNipst(atx.ID())
+	r.Error(err)
+
+	// the NIPST isn't fetched yet, the atx stays pending
+	builder.resolvePendingAtxs(5)
+	r.Equal(1, peers.fetched)
+	r.Empty(builder.AtxPool.GetAllItems())
+
+	peers.nipsts[atx.ID()] = nipst
+	builder.resolvePendingAtxs(5)
+	r.Equal(2, peers.fetched)
+	r.Len(builder.AtxPool.GetAllItems(), 1)
+	got, err := builder.GetNipst(atx.ID())
+	r.NoError(err)
+	r.Equal(nipst, got)
+	builder.pending.Lock()
+	r.Empty(builder.pending.atxs)
+	builder.pending.Unlock()
+}
+
+func TestBlockBuilder_ResolvePendingAtxs(t *testing.T) {
+	r := require.New(t)
+	net := service.NewSimulator()
+	n1 := net.NewNode()
+	builder := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, make(chan types.LayerID), 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{}, MockHare{}, &mockBlockOracle{}, mockTxProcessor{}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.New(n1.String(), "", ""))
+	local := &mockNipstFetcher{nipsts: make(map[types.ATXID]*types.NIPST)}
+	peers := &mockNipstFetcher{nipsts: make(map[types.ATXID]*types.NIPST)}
+	builder.SetNipstFetcher(local, peers)
+
+	nipst := activation.NewNIPSTWithChallenge(&types.Hash32{}, []byte{0xba, 0x38})
+	nipstHash, err := nipst.Hash()
+	r.NoError(err)
+	own := newActivationTx(types.NodeID{Key: "aaaa", VRFPublicKey: []byte("bbb")}, 1, types.ATXID(types.Hash32{1}), 5, 1, types.ATXID{}, types.HexToAddress("aaaa"), 5, nil, nil)
+	expired := newActivationTx(types.NodeID{Key: "bbbb", VRFPublicKey: []byte("bbb")}, 1, types.ATXID(types.Hash32{1}), 5, 1, types.ATXID{}, types.HexToAddress("bbbb"), 5, nil, nil)
+	later := newActivationTx(types.NodeID{Key: "cccc", VRFPublicKey: []byte("bbb")}, 1, types.ATXID(types.Hash32{1}), 15, 1, types.ATXID{}, types.HexToAddress("cccc"), 5, nil, nil)
+	for _, atx := range []*types.ActivationTx{own, expired, later} {
+		builder.pending.atxs[atx.ID()] = &pendingAtx{atx: atx, nipstHash: nipstHash}
+	}
+	local.nipsts[own.ID()] = nipst
+	// a NIPST that doesn't match the hash the atx signs isn't used
+	local.nipsts[later.ID()] = activation.NewNIPSTWithChallenge(&types.Hash32{1}, []byte{0xba, 0x38})
+
+	// in epoch 2 atxs published in epoch 0 can't be included in blocks anymore
+	builder.resolvePendingAtxs(25)
+	r.Equal(1, peers.fetched)
+	r.Empty(builder.AtxPool.GetAllItems())
+	r.Len(builder.pending.atxs, 1)
+	r.Contains(builder.pending.atxs, later.ID())
+
+	builder.pending.atxs[own.ID()] = &pendingAtx{atx: own, nipstHash: nipstHash}
+	builder.resolvePendingAtxs(15)
+	r.Equal(2, peers.fetched)
+	r.Len(builder.AtxPool.GetAllItems(), 1)
+	r.Contains(builder.pending.atxs, later.ID())
+}
@@ -2,19 +2,24 @@
 package miner
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/activation"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/config"
 	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/p2p"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/p2p/replay"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 	"math/rand"
 	"sync"
 	"time"
@@ -32,6 +37,9 @@ const IncomingTxProtocol = "TxGossip"
 // AtxsPerBlockLimit indicates the maximum number of atxs a block can reference
 const AtxsPerBlockLimit = 100
 
+// atxValidationTimeout bounds the validation of a gossiped atx, atxs whose view takes longer to traverse are dropped
+const atxValidationTimeout = 2 * time.Minute
+
 type signer interface {
 	Sign(m []byte) []byte
 }
@@ -42,7 +50,8 @@ type txValidator interface {
 }
 
 type atxValidator interface {
-	SyntacticallyValidateAtx(atx *types.ActivationTx) error
+	SyntacticallyValidateAtx(ctx context.Context, atx *types.ActivationTx) error
+	SyntacticallyValidateAtxHeader(ctx context.Context, atx *types.ActivationTx, nipstHash types.Hash32) error
 }
 
 type syncer interface {
@@ -53,8 +62,10 @@ type syncer interface {
 
 type txPool interface {
 	GetTxsForBlock(numOfTxs int, getState func(addr types.Address) (nonce, balance uint64, err error)) ([]types.TransactionID, error)
+	Get(id types.TransactionID) (*types.Transaction, error)
 	Put(id types.TransactionID, item *types.Transaction)
 	Invalidate(id types.TransactionID)
+	CheckGasPrice(tx *types.Transaction) error
 }
 
 type projector interface {
@@ -78,10 +89,16 @@ type BlockBuilder struct {
 	stopChan         chan struct{}
 	txGossipChannel  chan service.GossipMessage
 	atxGossipChannel chan service.GossipMessage
+	atxHeaderChannel chan service.GossipMessage
+	atxReplays       *replay.Window
+	pending          pendingAtxs
+	localNipsts      activation.NipstSource
+	nipstFetcher     nipstFetcher
 	hareResult       hareResultProvider
 	AtxPool          *AtxMemPool
 	TransactionPool  txPool
 	mu               sync.Mutex
+	eligibilityMu    sync.Mutex // serializes the block oracle's eligibility checks, see blockEligible
 	network          p2p.Service
 	weakCoinToss     weakCoinProvider
 	meshProvider     meshProvider
@@ -93,6 +110,7 @@ type BlockBuilder struct {
 	atxsPerBlock     int // number of atxs to select per block
 	layersPerEpoch   uint16
 	projector        projector
+	upgrades         *upgrade.Schedule
 }
 
 // NewBlockBuilder creates a struct of block builder type.
@@ -115,6 +133,9 @@ func NewBlockBuilder(minerID types.NodeID, sgn signer, net p2p.Service, beginRou
 		TransactionPool:  txPool,
 		txGossipChannel:  net.RegisterGossipProtocol(IncomingTxProtocol, priorityq.Low),
 		atxGossipChannel: net.RegisterGossipProtocol(activation.AtxProtocol, priorityq.Low),
+		atxHeaderChannel: net.RegisterGossipProtocol(activation.AtxHeaderProtocol, priorityq.Low),
+		atxReplays:       replay.NewWindow(activation.AtxProtocol, replay.DefaultWindow),
+		pending:          pendingAtxs{atxs: make(map[types.ATXID]*pendingAtx)},
 		hareResult:       hare,
 		mu:               sync.Mutex{},
 		network:          net,
@@ -132,6 +153,12 @@ func NewBlockBuilder(minerID types.NodeID, sgn signer, net p2p.Service, beginRou
 
 }
 
+// SetUpgrades sets the schedule of the protocol upgrades that block creation branches on. It must be called before the
+// builder starts.
+func (t *BlockBuilder) SetUpgrades(upgrades *upgrade.Schedule) {
+	t.upgrades = upgrades
+}
+
 // Start starts the process of creating a block, it listens for txs and atxs received by gossip, and starts querying
 // block oracle when it should create a block. This function returns an error if Start was already called once
 func (t *BlockBuilder) Start() error {
@@ -174,6 +201,11 @@ type meshProvider interface {
 	GetBlock(id types.BlockID) (*types.Block, error)
 }
 
+// stateRootProvider is implemented by meshes that keep the state, so that blocks report the producer's state root.
+type stateRootProvider interface {
+	VerifiedStateRoot() (types.LayerID, types.Hash32, error)
+}
+
 //used from external API call to dd transaction
 func (t *BlockBuilder) addTransaction(tx *types.Transaction) error {
 	if !t.started {
@@ -257,16 +289,55 @@ func (t *BlockBuilder) getVotes(id types.LayerID) ([]types.BlockID, error) {
 func (t *BlockBuilder) createBlock(id types.LayerID, atxID types.ATXID, eligibilityProof types.BlockEligibilityProof,
 	txids []types.TransactionID, atxids []types.ATXID) (*types.Block, error) {
 
-	votes, err := t.getVotes(id)
+	b, viewEdges, err := t.buildBlock(id, atxID, eligibilityProof, txids, atxids)
 	if err != nil {
 		return nil, err
 	}
 
-	viewEdges, err := t.meshProvider.GetOrphanBlocksBefore(id)
+	blockBytes, err := types.InterfaceToBytes(b)
 	if err != nil {
 		return nil, err
 	}
 
+	bl := &types.Block{MiniBlock: *b, Signature: t.signer.Sign(blockBytes)}
+	if len(b.CompactView) > 0 {
+		bl.SetView(viewEdges)
+	}
+
+	bl.Initialize()
+
+	t.Log.Event().Info("block created",
+		bl.ID(),
+		bl.LayerIndex,
+		bl.LayerIndex.GetEpoch(t.layersPerEpoch),
+		bl.MinerID(),
+		log.Int("tx_count", len(bl.TxIDs)),
+		log.Int("atx_count", len(bl.ATXIDs)),
+		log.Int("view_edges", len(bl.View())),
+		log.Int("compact_view_layers", len(bl.CompactView)),
+		log.Int("vote_count", len(bl.BlockVotes)),
+		bl.ATXID,
+		log.Uint32("eligibility_counter", bl.EligibilityProof.J),
+		log.Uint64("state_layer", bl.StateLayer.Uint64()),
+		log.String("state_root", bl.StateRoot.String()),
+	)
+	return bl, nil
+}
+
+// buildBlock builds the unsigned block of an eligibility proof, and returns it with its view edges.
+func (t *BlockBuilder) buildBlock(id types.LayerID, atxID types.ATXID, eligibilityProof types.BlockEligibilityProof,
+	txids []types.TransactionID, atxids []types.ATXID) (*types.MiniBlock, []types.BlockID, error) {
+
+	votes, err := t.getVotes(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	viewEdges, err := t.meshProvider.GetOrphanBlocksBefore(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	b := types.MiniBlock{
 		BlockHeader: types.BlockHeader{
 			LayerIndex:       id,
@@ -282,28 +353,38 @@ func (t *BlockBuilder) createBlock(id types.LayerID, atxID types.ATXID, eligibil
 		TxIDs:  txids,
 	}
 
-	blockBytes, err := types.InterfaceToBytes(b)
-	if err != nil {
-		return nil, err
+	if t.upgrades.Active(upgrade.CompactViews, id.GetEpoch(t.layersPerEpoch)) && len(viewEdges) > 0 {
+		cv, err := types.EncodeView(viewEdges, t.blockLayer, t.meshProvider.LayerBlockIds)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode compact view: %v", err)
+		}
+		b.ViewEdges, b.CompactView = nil, cv
 	}
 
-	bl := &types.Block{MiniBlock: b, Signature: t.signer.Sign(blockBytes)}
+	if sr, ok := t.meshProvider.(stateRootProvider); ok {
+		if layer, root, err := sr.VerifiedStateRoot(); err != nil {
+			t.With().Warning("block will not report a state root", log.Err(err))
+		} else {
+			b.StateLayer, b.StateRoot = layer, root
+		}
+	}
+	return &b, viewEdges, nil
+}
 
-	bl.Initialize()
+// blockEligible checks the eligibility for blocks in layer. The block oracle caches the eligibility of an epoch, the
+// checks of block production and of dry runs are serialized so that they don't refresh the cache concurrently.
+func (t *BlockBuilder) blockEligible(layer types.LayerID) (types.ATXID, []types.BlockEligibilityProof, error) {
+	t.eligibilityMu.Lock()
+	defer t.eligibilityMu.Unlock()
+	return t.blockOracle.BlockEligible(layer)
+}
 
-	t.Log.Event().Info("block created",
-		bl.ID(),
-		bl.LayerIndex,
-		bl.LayerIndex.GetEpoch(t.layersPerEpoch),
-		bl.MinerID(),
-		log.Int("tx_count", len(bl.TxIDs)),
-		log.Int("atx_count", len(bl.ATXIDs)),
-		log.Int("view_edges", len(bl.ViewEdges)),
-		log.Int("vote_count", len(bl.BlockVotes)),
-		bl.ATXID,
-		log.Uint32("eligibility_counter", bl.EligibilityProof.J),
-	)
-	return bl, nil
+func (t *BlockBuilder) blockLayer(id types.BlockID) (types.LayerID, error) {
+	blk, err := t.meshProvider.GetBlock(id)
+	if err != nil {
+		return 0, err
+	}
+	return blk.Layer(), nil
 }
 
 func selectAtxs(atxs []types.ATXID, atxsPerBlock int) []types.ATXID {
@@ -350,6 +431,12 @@ func (t *BlockBuilder) listenForTx() {
 				t.With().Error("failed to calc transaction origin", log.TxID(tx.ID().ShortString()), log.Err(err))
 				continue
 			}
+			if _, err := t.TransactionPool.Get(tx.ID()); err == nil {
+				// validated when it was put in the pool, e.g. by a batch submitted to the api, its nonce is already
+				// projected so it's relayed without validating it again
+				data.ReportValidation(IncomingTxProtocol)
+				continue
+			}
 			if !t.txValidator.AddressExists(tx.Origin()) {
 				t.With().Error("transaction origin does not exist", log.String("transaction", tx.String()),
 					log.TxID(tx.ID().ShortString()), log.String("origin", tx.Origin().Short()), log.Err(err))
@@ -359,6 +446,11 @@ func (t *BlockBuilder) listenForTx() {
 				t.With().Error("nonce and balance validation failed", log.TxID(tx.ID().ShortString()), log.Err(err))
 				continue
 			}
+			if err := t.TransactionPool.CheckGasPrice(tx); err != nil {
+				// not relayed either, since the message isn't reported as valid
+				t.With().Info("dropping transaction below the minimum gas price", log.TxID(tx.ID().ShortString()), log.Err(err))
+				continue
+			}
 			t.Log.With().Info("got new tx",
 				log.TxID(tx.ID().ShortString()),
 				log.Uint64("nonce", tx.AccountNonce),
@@ -380,6 +472,9 @@ func (t *BlockBuilder) ValidateAndAddTxToPool(tx *types.Transaction) error {
 	if err != nil {
 		return err
 	}
+	if err := t.TransactionPool.CheckGasPrice(tx); err != nil {
+		return err
+	}
 	t.TransactionPool.Put(tx.ID(), tx)
 	return nil
 }
@@ -396,6 +491,11 @@ func (t *BlockBuilder) listenForAtx() {
 				continue
 			}
 			t.handleGossipAtx(data)
+		case data := <-t.atxHeaderChannel:
+			if !t.syncer.ListenToGossip() {
+				continue
+			}
+			t.handleGossipAtxHeader(data)
 		}
 	}
 }
@@ -411,11 +511,18 @@ func (t *BlockBuilder) handleGossipAtx(data service.GossipMessage) {
 	}
 	atx.CalcAndSetID()
 
+	// atxs seen recently were already handled, whether they were valid or not. They're keyed by the hash of the whole
+	// message, since the id doesn't cover the NIPST and the signature, and an invalid atx with the id of a valid one
+	// would hide it
+	if t.atxReplays.Replayed(types.CalcHash32(data.Bytes()).Bytes(), data.Sender()) {
+		return
+	}
+
 	t.With().Info("got new ATX", atx.Fields(t.layersPerEpoch, len(data.Bytes()))...)
 
-	//todo fetch from neighbour (#1925)
+	// atxs without their NIPST are gossiped as headers on AtxHeaderProtocol
 	if atx.Nipst == nil {
-		t.Panic("nil nipst in gossip")
+		t.Warning("received ATX (%v) without NIPST", atx.ShortString())
 		return
 	}
 
@@ -425,7 +532,11 @@ func (t *BlockBuilder) handleGossipAtx(data service.GossipMessage) {
 		return
 	}
 
-	err = t.atxValidator.SyntacticallyValidateAtx(atx)
+	ctx, cancel := util.StopContext(t.stopChan)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, atxValidationTimeout)
+	defer cancelTimeout()
+	err = t.atxValidator.SyntacticallyValidateAtx(ctx, atx)
 	events.Publish(events.ValidAtx{ID: atx.ShortString(), Valid: err == nil})
 	if err != nil {
 		t.Warning("received syntactically invalid ATX %v: %v", atx.ShortString(), err)
@@ -438,6 +549,11 @@ func (t *BlockBuilder) handleGossipAtx(data service.GossipMessage) {
 	t.With().Info("stored and propagated new syntactically valid ATX", log.AtxID(atx.ShortString()))
 }
 
+// PeerAtxReplays returns the number of atxs peer gossiped again shortly after they were seen.
+func (t *BlockBuilder) PeerAtxReplays(peer p2pcrypto.PublicKey) uint64 {
+	return t.atxReplays.PeerReplays(peer)
+}
+
 func (t *BlockBuilder) acceptBlockData() {
 	for {
 		select {
@@ -452,7 +568,7 @@ func (t *BlockBuilder) acceptBlockData() {
 			}
 
 			t.Debug("builder got layer %v", layerID)
-			atxID, proofs, err := t.blockOracle.BlockEligible(layerID)
+			atxID, proofs, err := t.blockEligible(layerID)
 			if err != nil {
 				events.Publish(events.DoneCreatingBlock{Eligible: true, Layer: uint64(layerID), Error: "failed to check for block eligibility"})
 				t.With().Error("failed to check for block eligibility", log.LayerID(uint64(layerID)), log.Err(err))
@@ -465,6 +581,8 @@ func (t *BlockBuilder) acceptBlockData() {
 			}
 			// TODO: include multiple proofs in each block and weigh blocks where applicable
 
+			t.resolvePendingAtxs(layerID)
+
 			var atxList []types.ATXID
 			for _, atx := range t.AtxPool.GetAllItems() {
 				atxList = append(atxList, atx.ID())
@@ -2,6 +2,7 @@ package miner
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -58,7 +59,13 @@ func (mockAtxValidator) GetIdentity(string) (types.NodeID, error) {
 	return types.NodeID{}, nil
 }
 
-func (mockAtxValidator) SyntacticallyValidateAtx(*types.ActivationTx) error { return nil }
+func (mockAtxValidator) SyntacticallyValidateAtx(context.Context, *types.ActivationTx) error {
+	return nil
+}
+
+func (mockAtxValidator) SyntacticallyValidateAtxHeader(context.Context, *types.ActivationTx, types.Hash32) error {
+	return nil
+}
 
 type mockTxProcessor struct {
 	notValid bool
@@ -365,6 +372,30 @@ func TestBlockBuilder_Gossip_NotSynced(t *testing.T) {
 	assert.Empty(t, ids)
 }
 
+func TestBlockBuilder_Gossip_MinGasPrice(t *testing.T) {
+	net := service.NewSimulator()
+	n1 := net.NewNode()
+	pool := NewTxMemPool()
+	pool.SetMinGasPrice(defaultFee/defaultGasLimit + 1)
+	builder1 := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, make(chan types.LayerID), 5, pool, NewAtxMemPool(), MockCoin{}, &mockMesh{}, MockHare{}, &mockBlockOracle{}, mockTxProcessor{false}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.New(n1.Info.ID.String(), "", ""))
+	assert.NoError(t, builder1.Start())
+	tx := NewTx(t, 5, types.HexToAddress("0xFF"), signing.NewEdSigner())
+	b, err := types.InterfaceToBytes(tx)
+	assert.NoError(t, err)
+	assert.NoError(t, n1.Broadcast(IncomingTxProtocol, b))
+	time.Sleep(300 * time.Millisecond)
+	ids, err := builder1.TransactionPool.GetTxsForBlock(10, getState)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+	assert.Error(t, builder1.ValidateAndAddTxToPool(tx))
+
+	pool.SetMinGasPrice(defaultFee / defaultGasLimit)
+	assert.NoError(t, builder1.ValidateAndAddTxToPool(tx))
+	ids, err = builder1.TransactionPool.GetTxsForBlock(10, getState)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TransactionID{tx.ID()}, ids)
+}
+
 func Test_calcHdistRange(t *testing.T) {
 	r := require.New(t)
 
@@ -629,6 +660,36 @@ func TestBlockBuilder_createBlock(t *testing.T) {
 	r.Equal([]types.BlockID(nil), b.BlockVotes)
 	emptyID := types.BlockID{}
 	r.NotEqual(b.ID(), emptyID)
+	r.Equal(types.Hash32{}, b.StateRoot)
+}
+
+type mockStateMesh struct {
+	mockMesh
+	layer types.LayerID
+	root  types.Hash32
+	err   error
+}
+
+func (m *mockStateMesh) VerifiedStateRoot() (types.LayerID, types.Hash32, error) {
+	return m.layer, m.root, m.err
+}
+
+func TestBlockBuilder_createBlock_StateRoot(t *testing.T) {
+	r := require.New(t)
+	n1 := service.NewSimulator().NewNode()
+	msh := &mockStateMesh{layer: 3, root: types.Hash32{1, 2, 3}}
+	builder := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, make(chan types.LayerID), 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, msh, &mockResult{}, &mockBlockOracle{}, mockTxProcessor{true}, &mockAtxValidator{}, &mockSyncer{}, selectCount, layersPerEpoch, mockProjector, log.NewDefault(t.Name()))
+
+	b, err := builder.createBlock(5, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
+	r.NoError(err)
+	r.Equal(types.LayerID(3), b.StateLayer)
+	r.Equal(types.Hash32{1, 2, 3}, b.StateRoot)
+
+	// the block is still created without a state root
+	msh.err = errExample
+	b, err = builder.createBlock(5, types.ATXID{}, types.BlockEligibilityProof{}, nil, nil)
+	r.NoError(err)
+	r.Equal(types.Hash32{}, b.StateRoot)
 }
 
 func TestBlockBuilder_notSynced(t *testing.T) {
@@ -648,6 +709,40 @@ func TestBlockBuilder_notSynced(t *testing.T) {
 	r.Equal(0, mbo.calls)
 }
 
+func TestBlockBuilder_DryRun(t *testing.T) {
+	r := require.New(t)
+	n1 := service.NewSimulator().NewNode()
+	block1 := types.NewExistingBlock(0, []byte(rand.String(8)))
+	block2 := types.NewExistingBlock(0, []byte(rand.String(8)))
+	bs := []*types.Block{block1, block2}
+	ms := &mockSyncer{}
+	mbo := &mockBlockOracle{}
+	builder := NewBlockBuilder(types.NodeID{Key: "a"}, signing.NewEdSigner(), n1, make(chan types.LayerID), 5, NewTxMemPool(), NewAtxMemPool(), MockCoin{}, &mockMesh{b: bs}, &mockResult{err: errExample}, mbo, mockTxProcessor{true}, &mockAtxValidator{}, ms, selectCount, layersPerEpoch, mockProjector, log.NewDefault(t.Name()))
+
+	res := builder.DryRun(5)
+	r.Empty(res.Reason)
+	r.True(res.Synced)
+	r.Equal(1, res.Eligibility)
+	r.NotNil(res.Block)
+	r.Equal(types.LayerID(5), res.Block.LayerIndex)
+	r.Equal([]types.BlockID{block1.ID(), block2.ID()}, res.Block.BlockVotes)
+	r.NotEqual(types.BlockID{}, res.BlockID)
+	r.NotZero(res.Size)
+	r.Equal(1, mbo.calls)
+
+	// the block is still built when the node isn't synced, with the reason it wouldn't be produced
+	ms.notSynced = true
+	res = builder.DryRun(5)
+	r.Equal("the node is not synced", res.Reason)
+	r.NotNil(res.Block)
+
+	ms.notSynced = false
+	mbo.err = errExample
+	res = builder.DryRun(5)
+	r.Equal(fmt.Sprintf("failed to check for block eligibility: %v", errExample), res.Reason)
+	r.Nil(res.Block)
+}
+
 var (
 	block1 = types.NewExistingBlock(1, []byte{1}).ID()
 	block2 = types.NewExistingBlock(1, []byte{2}).ID()
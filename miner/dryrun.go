@@ -0,0 +1,73 @@
+package miner
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// BlockDryRun is the result of running block production for a layer without publishing the block.
+type BlockDryRun struct {
+	Layer       types.LayerID
+	Synced      bool        // blocks are only produced when the node is synced
+	ATXID       types.ATXID // the atx the node's eligibility is derived from
+	Eligibility int         // the number of blocks the node is eligible for in the layer
+	// Block is the block of the first eligibility proof, unsigned. It's nil if the node can't produce a block.
+	Block     *types.MiniBlock
+	BlockID   types.BlockID // the id the block would have, blocks of the layer have another timestamp and so another id
+	ViewEdges []types.BlockID
+	Size      int    // the size of the block once signed
+	Reason    string // why the node wouldn't produce a block in the layer, empty if it would
+}
+
+// DryRun runs block production for layer, from the eligibility check to the selection of the block's txs, atxs, votes
+// and view, without signing or broadcasting the block, to diagnose why the node does or doesn't produce blocks. Every
+// step runs even if the node wouldn't produce a block because it isn't synced, the reason is reported with the block.
+func (t *BlockBuilder) DryRun(layer types.LayerID) *BlockDryRun {
+	res := &BlockDryRun{Layer: layer, Synced: t.syncer.IsSynced()}
+	if !res.Synced {
+		res.Reason = "the node is not synced"
+	}
+	fail := func(reason string) *BlockDryRun {
+		if res.Reason == "" {
+			res.Reason = reason
+		}
+		return res
+	}
+
+	atxID, proofs, err := t.blockEligible(layer)
+	if err != nil {
+		return fail(fmt.Sprintf("failed to check for block eligibility: %v", err))
+	}
+	res.ATXID, res.Eligibility = atxID, len(proofs)
+	if len(proofs) == 0 {
+		return fail("the node is not eligible for blocks in the layer")
+	}
+
+	var atxList []types.ATXID
+	for _, atx := range t.AtxPool.GetAllItems() {
+		atxList = append(atxList, atx.ID())
+	}
+	txList, err := t.TransactionPool.GetTxsForBlock(MaxTransactionsPerBlock, t.projector.GetProjection)
+	if err != nil {
+		return fail(fmt.Sprintf("failed to get txs for block: %v", err))
+	}
+	b, viewEdges, err := t.buildBlock(layer, atxID, proofs[0], txList, atxList)
+	if err != nil {
+		return fail(fmt.Sprintf("cannot create new block: %v", err))
+	}
+	res.Block, res.ViewEdges = b, viewEdges
+
+	bytes, err := types.InterfaceToBytes(b)
+	if err != nil {
+		return fail(fmt.Sprintf("cannot serialize block: %v", err))
+	}
+	res.BlockID = types.BlockID(types.CalcHash32(bytes).ToHash20())
+	signed, err := types.InterfaceToBytes(&types.Block{MiniBlock: *b, Signature: make([]byte, ed25519.SignatureSize)})
+	if err != nil {
+		return fail(fmt.Sprintf("cannot serialize block: %v", err))
+	}
+	res.Size = len(signed)
+	return res
+}
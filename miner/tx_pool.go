@@ -1,20 +1,27 @@
 package miner
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/pendingtxs"
 	"github.com/spacemeshos/go-spacemesh/rand"
+	"sort"
 	"sync"
 )
 
+var errPoolFull = errors.New("the mempool is full of transactions with a higher gas price")
+
 // TxMempool is a struct that holds txs received via gossip network
 type TxMempool struct {
 	txs      map[types.TransactionID]*types.Transaction
 	accounts map[types.Address]*pendingtxs.AccountPendingTxs
 	txByAddr map[types.Address]map[types.TransactionID]struct{}
 	mu       sync.RWMutex
+
+	minGasPrice uint64 // the minimum fee per unit of gas limit of transactions that the pool accepts
+	maxTxs      int    // the maximum number of transactions in the pool, 0 for unlimited
 }
 
 // NewTxMemPool returns a new TxMempool struct
@@ -26,6 +33,46 @@ func NewTxMemPool() *TxMempool {
 	}
 }
 
+// SetMinGasPrice sets the minimum gas price, the fee per unit of gas limit, of the transactions that the pool accepts.
+// Transactions that are already in the pool stay.
+func (t *TxMempool) SetMinGasPrice(price uint64) {
+	t.mu.Lock()
+	t.minGasPrice = price
+	t.mu.Unlock()
+}
+
+// SetMaxTxs sets the maximum number of transactions in the pool, 0 for unlimited. Once the pool is full, a new
+// transaction evicts the transaction with the lowest gas price, unless its own gas price isn't higher.
+func (t *TxMempool) SetMaxTxs(max int) {
+	t.mu.Lock()
+	t.maxTxs = max
+	t.mu.Unlock()
+}
+
+// MinGasPrice returns the minimum gas price of the transactions that the pool accepts.
+func (t *TxMempool) MinGasPrice() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.minGasPrice
+}
+
+// CheckGasPrice returns an error if the gas price of tx is below the minimum gas price. A gas limit of 0 counts as 1,
+// so that a zero fee never passes a minimum above 0.
+func (t *TxMempool) CheckGasPrice(tx *types.Transaction) error {
+	if minPrice := t.MinGasPrice(); gasPrice(tx) < minPrice {
+		return fmt.Errorf("gas price %v is below the minimum of %v", gasPrice(tx), minPrice)
+	}
+	return nil
+}
+
+func gasPrice(tx *types.Transaction) uint64 {
+	gas := tx.GasLimit
+	if gas == 0 {
+		gas = 1
+	}
+	return tx.Fee / gas
+}
+
 // Get returns transaction by provided id, it returns an error if transaction is not found
 func (t *TxMempool) Get(id types.TransactionID) (*types.Transaction, error) {
 	t.mu.RLock()
@@ -87,11 +134,44 @@ func getRandIdxs(numOfTxs, spaceSize int) map[uint64]struct{} {
 // Put inserts a transaction into the mem pool. It indexes it by source and dest addresses as well
 func (t *TxMempool) Put(id types.TransactionID, tx *types.Transaction) {
 	t.mu.Lock()
+	t.put(id, tx)
+	t.mu.Unlock()
+}
+
+// PutBatch puts txs in the pool in order, each after it passes validate and the gas price check. Each transaction is
+// validated after the batch's earlier transactions were put, so a batch can hold consecutive nonces of an account. It
+// returns the error of each transaction that wasn't put, and nil for the transactions that were.
+func (t *TxMempool) PutBatch(txs []*types.Transaction, validate func(tx *types.Transaction) error) []error {
+	errs := make([]error, len(txs))
+	for i, tx := range txs {
+		if err := validate(tx); err != nil {
+			errs[i] = err
+			continue
+		}
+		if err := t.CheckGasPrice(tx); err != nil {
+			errs[i] = err
+			continue
+		}
+		t.mu.Lock()
+		if !t.put(tx.ID(), tx) {
+			errs[i] = errPoolFull
+		}
+		t.mu.Unlock()
+	}
+	return errs
+}
+
+// put puts tx in the pool and returns true, or returns false if the pool is full and tx isn't pricier than the
+// cheapest transaction. ⚠️ must be called under write-lock
+func (t *TxMempool) put(id types.TransactionID, tx *types.Transaction) bool {
+	if _, found := t.txs[id]; !found && t.maxTxs > 0 && len(t.txs) >= t.maxTxs && !t.evictCheapest(gasPrice(tx)) {
+		return false
+	}
 	t.txs[id] = tx
 	t.getOrCreate(tx.Origin()).Add(0, tx)
 	t.addToAddr(tx.Origin(), id)
 	t.addToAddr(tx.Recipient, id)
-	t.mu.Unlock()
+	return true
 }
 
 // Invalidate removes transaction from pool
@@ -114,6 +194,108 @@ func (t *TxMempool) Invalidate(id types.TransactionID) {
 	t.mu.Unlock()
 }
 
+// MempoolStats summarizes the contents of the mempool.
+type MempoolStats struct {
+	Txs      int    // number of transactions
+	Accounts int    // number of origin accounts
+	Amount   uint64 // sum of the amounts of the transactions
+	Fees     uint64 // sum of the fees of the transactions
+}
+
+// Txs returns the transactions in the pool, sorted by origin, then by nonce and then by ID.
+func (t *TxMempool) Txs() []*types.Transaction {
+	t.mu.RLock()
+	txs := make([]*types.Transaction, 0, len(t.txs))
+	for _, tx := range t.txs {
+		txs = append(txs, tx)
+	}
+	t.mu.RUnlock()
+	sort.Slice(txs, func(i, j int) bool {
+		if c := bytes.Compare(txs[i].Origin().Bytes(), txs[j].Origin().Bytes()); c != 0 {
+			return c < 0
+		}
+		if txs[i].AccountNonce != txs[j].AccountNonce {
+			return txs[i].AccountNonce < txs[j].AccountNonce
+		}
+		idI, idJ := txs[i].ID(), txs[j].ID()
+		return bytes.Compare(idI[:], idJ[:]) < 0
+	})
+	return txs
+}
+
+// Stats returns a summary of the contents of the pool.
+func (t *TxMempool) Stats() MempoolStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	stats := MempoolStats{Txs: len(t.txs), Accounts: len(t.accounts)}
+	for _, tx := range t.txs {
+		stats.Amount += tx.Amount
+		stats.Fees += tx.Fee
+	}
+	return stats
+}
+
+// Evict removes the transaction with the given id from the pool. Unlike Invalidate, other transactions with the same
+// nonce stay in the pool. It returns false if the transaction isn't in the pool.
+func (t *TxMempool) Evict(id types.TransactionID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tx, found := t.txs[id]
+	if !found {
+		return false
+	}
+	t.evict(id, tx)
+	return true
+}
+
+// EvictAccount removes the transactions that addr sent from the pool and returns their IDs. Transactions that addr
+// receives stay in the pool.
+func (t *TxMempool) EvictAccount(addr types.Address) []types.TransactionID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var evicted []types.TransactionID
+	for id := range t.txByAddr[addr] {
+		if tx := t.txs[id]; tx.Origin() == addr {
+			evicted = append(evicted, id)
+		}
+	}
+	for _, id := range evicted {
+		t.evict(id, t.txs[id])
+	}
+	return evicted
+}
+
+// evictCheapest evicts the transaction with the lowest gas price if it's lower than price. It returns false if no
+// transaction was evicted.
+// ⚠️ must be called under write-lock
+func (t *TxMempool) evictCheapest(price uint64) bool {
+	var cheapest *types.Transaction
+	for _, tx := range t.txs {
+		if cheapest == nil || gasPrice(tx) < gasPrice(cheapest) {
+			cheapest = tx
+		}
+	}
+	if cheapest == nil || gasPrice(cheapest) >= price {
+		return false
+	}
+	t.evict(cheapest.ID(), cheapest)
+	return true
+}
+
+// ⚠️ must be called under write-lock
+func (t *TxMempool) evict(id types.TransactionID, tx *types.Transaction) {
+	if pendingTxs, found := t.accounts[tx.Origin()]; found {
+		// transactions in the pool aren't included in any layer, so RemoveRejected removes them whatever the layer
+		pendingTxs.RemoveRejected([]*types.Transaction{tx}, 0)
+		if pendingTxs.IsEmpty() {
+			delete(t.accounts, tx.Origin())
+		}
+	}
+	delete(t.txs, id)
+	t.removeFromAddr(tx.Origin(), id)
+	t.removeFromAddr(tx.Recipient, id)
+}
+
 // GetProjection returns the estimated nonce and balance for the provided address addr and previous nonce and balance
 // projecting state is done by applying transactions from the pool
 func (t *TxMempool) GetProjection(addr types.Address, prevNonce, prevBalance uint64) (nonce, balance uint64) {
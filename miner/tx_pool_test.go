@@ -103,6 +103,131 @@ func TestTxPoolWithAccounts_GetRandomTxs(t *testing.T) {
 	*/
 }
 
+func TestTxPool_Evict(t *testing.T) {
+	r := require.New(t)
+	pool := NewTxMemPool()
+	signer1, signer2 := signing.NewEdSigner(), signing.NewEdSigner()
+	origin1 := types.BytesToAddress(signer1.PublicKey().Bytes())
+
+	// two versions of the same nonce, and a transaction to origin1
+	id1, tx1 := newTx(t, 4, 50, signer1)
+	id2, tx2 := newTx(t, 4, 60, signer1)
+	incoming, err := mesh.NewSignedTx(0, origin1, 10, 3, 1, signer2)
+	r.NoError(err)
+	for _, tx := range []*types.Transaction{tx1, tx2, incoming} {
+		pool.Put(tx.ID(), tx)
+	}
+	r.Equal(MempoolStats{Txs: 3, Accounts: 2, Amount: 49 + 59 + 10, Fees: 3}, pool.Stats())
+	r.Len(pool.Txs(), 3)
+
+	// unlike Invalidate, evicting one version keeps the other
+	r.True(pool.Evict(id2))
+	r.False(pool.Evict(id2))
+	_, err = pool.Get(id1)
+	r.NoError(err)
+	r.Empty(pool.GetTxIdsByAddress(tx2.Recipient))
+	nonce, balance := pool.GetProjection(origin1, 4, 1000)
+	r.Equal(uint64(5), nonce)
+	r.Equal(uint64(950), balance)
+
+	// evicting origin1 keeps the transaction it receives
+	r.Equal([]types.TransactionID{id1}, pool.EvictAccount(origin1))
+	r.Equal([]*types.Transaction{incoming}, pool.Txs())
+	r.Equal([]types.TransactionID{incoming.ID()}, pool.GetTxIdsByAddress(origin1))
+	nonce, balance = pool.GetProjection(origin1, 4, 1000)
+	r.Equal(uint64(4), nonce)
+	r.Equal(uint64(1000), balance)
+	r.Equal(MempoolStats{Txs: 1, Accounts: 1, Amount: 10, Fees: 1}, pool.Stats())
+}
+
+func TestTxPool_Txs(t *testing.T) {
+	r := require.New(t)
+	pool := NewTxMemPool()
+	signer := signing.NewEdSigner()
+	_, tx5 := newTx(t, 5, 50, signer)
+	_, tx3 := newTx(t, 3, 50, signer)
+	_, tx4 := newTx(t, 4, 50, signer)
+	for _, tx := range []*types.Transaction{tx5, tx3, tx4} {
+		pool.Put(tx.ID(), tx)
+	}
+	r.Equal([]*types.Transaction{tx3, tx4, tx5}, pool.Txs())
+}
+
+func TestTxPool_CheckGasPrice(t *testing.T) {
+	r := require.New(t)
+	pool := NewTxMemPool()
+	signer := signing.NewEdSigner()
+	zeroFee, err := mesh.NewSignedTx(0, types.Address{1}, 10, 0, 0, signer)
+	r.NoError(err)
+	priced, err := mesh.NewSignedTx(0, types.Address{1}, 10, 5, 15, signer) // gas price 3
+	r.NoError(err)
+	noLimit, err := mesh.NewSignedTx(0, types.Address{1}, 10, 0, 2, signer) // a gas limit of 0 counts as 1
+	r.NoError(err)
+
+	// no floor by default
+	r.NoError(pool.CheckGasPrice(zeroFee))
+
+	pool.SetMinGasPrice(3)
+	r.Equal(uint64(3), pool.MinGasPrice())
+	r.Error(pool.CheckGasPrice(zeroFee))
+	r.NoError(pool.CheckGasPrice(priced))
+	r.Error(pool.CheckGasPrice(noLimit))
+
+	pool.SetMinGasPrice(4)
+	r.Error(pool.CheckGasPrice(priced))
+}
+
+func TestTxPool_MaxTxs(t *testing.T) {
+	r := require.New(t)
+	pool := NewTxMemPool()
+	pool.SetMaxTxs(2)
+	signer := signing.NewEdSigner()
+	price := func(nonce, price uint64) *types.Transaction {
+		tx, err := mesh.NewSignedTx(nonce, types.Address{1}, 10, 1, price, signer)
+		r.NoError(err)
+		return tx
+	}
+	cheap, mid, high, low := price(0, 1), price(1, 2), price(2, 3), price(3, 1)
+
+	pool.Put(cheap.ID(), cheap)
+	pool.Put(mid.ID(), mid)
+	pool.Put(high.ID(), high) // evicts the cheapest
+	r.Equal([]*types.Transaction{mid, high}, pool.Txs())
+
+	pool.Put(low.ID(), low) // dropped, it's not pricier than the cheapest
+	r.Equal([]*types.Transaction{mid, high}, pool.Txs())
+}
+
+func TestTxPool_PutBatch(t *testing.T) {
+	r := require.New(t)
+	pool := NewTxMemPool()
+	pool.SetMaxTxs(3)
+	pool.SetMinGasPrice(2)
+	signer := signing.NewEdSigner()
+	newTx := func(nonce, price uint64) *types.Transaction {
+		tx, err := mesh.NewSignedTx(nonce, types.Address{1}, 10, 1, price, signer)
+		r.NoError(err)
+		return tx
+	}
+	// the transactions are validated against the nonces projected by the batch's earlier transactions
+	validate := func(tx *types.Transaction) error {
+		if nonce, _ := pool.GetProjection(tx.Origin(), 0, 100); tx.AccountNonce != nonce {
+			return fmt.Errorf("incorrect nonce %v", tx.AccountNonce)
+		}
+		return nil
+	}
+	txs := []*types.Transaction{newTx(0, 2), newTx(1, 1), newTx(1, 2), newTx(3, 2), newTx(2, 3), newTx(3, 2)}
+	errs := pool.PutBatch(txs, validate)
+	r.Len(errs, len(txs))
+	r.NoError(errs[0])
+	r.Error(errs[1]) // below the minimum gas price
+	r.NoError(errs[2])
+	r.Error(errs[3]) // skips a nonce
+	r.NoError(errs[4])
+	r.Equal(errPoolFull, errs[5])
+	r.Equal([]*types.Transaction{txs[0], txs[2], txs[4]}, pool.Txs())
+}
+
 func TestGetRandIdxs(t *testing.T) {
 	seed := []byte("seedseed")
 	rand.Seed(int64(binary.LittleEndian.Uint64(seed)))
@@ -10,6 +10,11 @@ import (
 // VRFValidationFunction is the VRF validation function.
 type VRFValidationFunction func(message, signature, publicKey []byte) (bool, error)
 
+// malfeasanceChecker reports whether an identity was convicted of malfeasance.
+type malfeasanceChecker interface {
+	IsMalicious(nodeID string) bool
+}
+
 // BlockEligibilityValidator holds all the dependencies for validating block eligibility.
 type BlockEligibilityValidator struct {
 	committeeSize        uint32
@@ -18,6 +23,7 @@ type BlockEligibilityValidator struct {
 	activationDb         activationDB
 	beaconProvider       *EpochBeaconProvider
 	validateVRF          VRFValidationFunction
+	malfeasance          malfeasanceChecker
 	log                  log.Log
 }
 
@@ -36,12 +42,23 @@ func NewBlockEligibilityValidator(committeeSize, genesisActiveSetSize uint32, la
 	}
 }
 
+// SetMalfeasanceChecker makes the validator reject blocks produced by identities convicted of malfeasance. Since
+// blocks are validated before they are added to the mesh, this also keeps such blocks out of the tortoise.
+func (v *BlockEligibilityValidator) SetMalfeasanceChecker(checker malfeasanceChecker) {
+	v.malfeasance = checker
+}
+
 // BlockSignedAndEligible checks that a given block is signed and eligible. It returns true with no error or false and
 // an error that explains why validation failed.
 func (v BlockEligibilityValidator) BlockSignedAndEligible(block *types.Block) (bool, error) {
-	var activeSetSize uint32
+	var activeSetSpaceUnits uint64
 	var vrfPubkey []byte
 	var genesisNoAtx bool
+	spaceUnits := uint32(1)
+
+	if v.malfeasance != nil && v.malfeasance.IsMalicious(block.MinerID().String()) {
+		return false, fmt.Errorf("block miner (%s) was convicted of malfeasance", block.MinerID().ShortString())
+	}
 
 	epochNumber := block.LayerIndex.GetEpoch(v.layersPerEpoch)
 	if epochNumber == 0 {
@@ -59,15 +76,21 @@ func (v BlockEligibilityValidator) BlockSignedAndEligible(block *types.Block) (b
 		if err != nil {
 			return false, err
 		}
-		activeSetSize, vrfPubkey = atx.ActiveSetSize, atx.NodeID.VRFPublicKey
+		spaceUnits, vrfPubkey = atx.EffectiveSpaceUnits(), atx.NodeID.VRFPublicKey
 	}
 	if epochNumber.IsGenesis() {
 		v.log.With().Info("using genesisActiveSetSize",
 			log.BlockID(block.ShortString()), log.Uint32("genesisActiveSetSize", v.genesisActiveSetSize))
-		activeSetSize = v.genesisActiveSetSize
+		activeSetSpaceUnits = uint64(v.genesisActiveSetSize)
+	} else {
+		units, err := v.activationDb.GetActiveSetSpaceUnits(block.ATXID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get active set space units: %v", err)
+		}
+		activeSetSpaceUnits = units
 	}
 
-	numberOfEligibleBlocks, err := getNumberOfEligibleBlocks(activeSetSize, v.committeeSize, v.layersPerEpoch)
+	numberOfEligibleBlocks, err := getNumberOfEligibleBlocks(spaceUnits, activeSetSpaceUnits, v.committeeSize, v.layersPerEpoch)
 	if err != nil {
 		return false, fmt.Errorf("failed to get number of eligible blocks: %v", err)
 	}
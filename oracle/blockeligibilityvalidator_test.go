@@ -27,6 +27,13 @@ func (m mockAtxDB) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, erro
 	return m.atxH, m.err
 }
 
+func (m mockAtxDB) GetActiveSetSpaceUnits(types.ATXID) (uint64, error) {
+	if m.atxH == nil {
+		return 0, m.err
+	}
+	return uint64(m.atxH.ActiveSetSize), m.err
+}
+
 func TestBlockEligibilityValidator_getValidAtx(t *testing.T) {
 	r := require.New(t)
 	atxdb := &mockAtxDB{err: errFoo}
@@ -53,3 +60,28 @@ func TestBlockEligibilityValidator_getValidAtx(t *testing.T) {
 	r.NoError(err)
 	r.Equal(atxHeader, atx)
 }
+
+type mockMalfeasance map[string]struct{}
+
+func (m mockMalfeasance) IsMalicious(nodeID string) bool {
+	_, ok := m[nodeID]
+	return ok
+}
+
+func TestBlockEligibilityValidator_ConvictedMiner(t *testing.T) {
+	r := require.New(t)
+	v := NewBlockEligibilityValidator(10, 5, 5, &mockAtxDB{}, &EpochBeaconProvider{},
+		validateVRF, log.NewDefault(t.Name()))
+
+	block := &types.Block{MiniBlock: types.MiniBlock{BlockHeader: types.BlockHeader{LayerIndex: 1}}} // epoch 0
+	block.Signature = edSigner.Sign(block.Bytes())
+	block.Initialize()
+	eligible, err := v.BlockSignedAndEligible(block)
+	r.NoError(err)
+	r.True(eligible)
+
+	v.SetMalfeasanceChecker(mockMalfeasance{edSigner.PublicKey().String(): {}})
+	eligible, err = v.BlockSignedAndEligible(block)
+	r.Error(err)
+	r.False(eligible)
+}
@@ -16,6 +16,7 @@ type activationDB interface {
 	GetNodeAtxIDForEpoch(nodeID types.NodeID, targetEpoch types.EpochID) (types.ATXID, error)
 	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
 	GetIdentity(edID string) (types.NodeID, error)
+	GetActiveSetSpaceUnits(id types.ATXID) (uint64, error)
 }
 
 type signer interface {
@@ -87,23 +88,29 @@ func (bo *MinerBlockOracle) calcEligibilityProofs(epochNumber types.EpochID) err
 	bo.log.Info("calculating eligibility")
 	epochBeacon := bo.beaconProvider.GetBeacon(epochNumber)
 
-	var activeSetSize uint32
+	var activeSetSpaceUnits uint64
+	spaceUnits := uint32(1)
 	atx, err := bo.getValidAtxForEpoch(epochNumber)
 	if err != nil {
 		if !epochNumber.IsGenesis() {
 			return fmt.Errorf("failed to get latest ATX: %v", err)
 		}
 	} else {
-		activeSetSize = atx.ActiveSetSize
+		spaceUnits = atx.EffectiveSpaceUnits()
 		bo.atxID = atx.ID()
 	}
 
 	if epochNumber.IsGenesis() {
-		activeSetSize = bo.genesisActiveSetSize
-		bo.log.Info("genesis epoch detected, using GenesisActiveSetSize (%v)", activeSetSize)
+		activeSetSpaceUnits = uint64(bo.genesisActiveSetSize)
+		bo.log.Info("genesis epoch detected, using GenesisActiveSetSize (%v)", bo.genesisActiveSetSize)
+	} else {
+		activeSetSpaceUnits, err = bo.atxDB.GetActiveSetSpaceUnits(atx.ID())
+		if err != nil {
+			return fmt.Errorf("failed to get active set space units: %v", err)
+		}
 	}
 
-	numberOfEligibleBlocks, err := getNumberOfEligibleBlocks(activeSetSize, bo.committeeSize, bo.layersPerEpoch)
+	numberOfEligibleBlocks, err := getNumberOfEligibleBlocks(spaceUnits, activeSetSpaceUnits, bo.committeeSize, bo.layersPerEpoch)
 	if err != nil {
 		bo.log.Error("failed to get number of eligible blocks: %v", err)
 		return err
@@ -152,6 +159,8 @@ func (bo *MinerBlockOracle) calcEligibilityProofs(epochNumber types.EpochID) err
 		bo.nodeID,
 		epochNumber,
 		log.Uint32("total_num_blocks", numberOfEligibleBlocks),
+		log.Uint32("space_units", spaceUnits),
+		log.Uint64("active_set_space_units", activeSetSpaceUnits),
 		log.Int("num_layers_eligible", len(bo.eligibilityProofs)),
 		log.String("layers_and_num_blocks", strings.Join(strs, ", ")))
 	bo.eligibilityMutex.RUnlock()
@@ -177,15 +186,18 @@ func calcEligibleLayer(epochNumber types.EpochID, layersPerEpoch uint16, vrfHash
 	return epochNumber.FirstLayer(layersPerEpoch).Add(uint16(eligibleLayerOffset))
 }
 
-func getNumberOfEligibleBlocks(activeSetSize, committeeSize uint32, layersPerEpoch uint16) (uint32, error) {
-	if activeSetSize == 0 {
+// getNumberOfEligibleBlocks returns the number of blocks an identity committing spaceUnits space units may produce in
+// an epoch. The committeeSize*layersPerEpoch blocks of the epoch are split between the identities of the active set
+// proportionally to the space they committed, but every identity is eligible for at least one block.
+func getNumberOfEligibleBlocks(spaceUnits uint32, activeSetSpaceUnits uint64, committeeSize uint32, layersPerEpoch uint16) (uint32, error) {
+	if activeSetSpaceUnits == 0 {
 		return 0, errors.New("empty active set not allowed")
 	}
-	numberOfEligibleBlocks := committeeSize * uint32(layersPerEpoch) / activeSetSize
+	numberOfEligibleBlocks := uint64(committeeSize) * uint64(layersPerEpoch) * uint64(spaceUnits) / activeSetSpaceUnits
 	if numberOfEligibleBlocks == 0 {
 		numberOfEligibleBlocks = 1
 	}
-	return numberOfEligibleBlocks, nil
+	return uint32(numberOfEligibleBlocks), nil
 }
 
 func (bo *MinerBlockOracle) getATXIDForEpoch(targetEpoch types.EpochID) (types.ATXID, error) {
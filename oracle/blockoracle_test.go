@@ -26,6 +26,8 @@ func generateNodeIDAndSigner() (types.NodeID, signer) {
 
 type mockActivationDB struct {
 	activeSetSize       uint32
+	spaceUnits          uint32
+	activeSetSpaceUnits uint64
 	atxPublicationLayer types.LayerID
 	atxs                map[string]map[types.LayerID]types.ATXID
 }
@@ -52,6 +54,7 @@ func (a mockActivationDB) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeade
 				PubLayerID: a.atxPublicationLayer,
 			},
 			ActiveSetSize: a.activeSetSize,
+			SpaceUnits:    a.spaceUnits,
 		}
 		atxHeader.SetID(&id)
 		return atxHeader, nil
@@ -59,6 +62,16 @@ func (a mockActivationDB) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeade
 	return nil, errors.New("wrong atx id")
 }
 
+func (a mockActivationDB) GetActiveSetSpaceUnits(id types.ATXID) (uint64, error) {
+	if id != atxID {
+		return 0, errors.New("wrong atx id")
+	}
+	if a.activeSetSpaceUnits == 0 {
+		return uint64(a.activeSetSize), nil
+	}
+	return a.activeSetSpaceUnits, nil
+}
+
 func TestBlockOracle(t *testing.T) {
 	r := require.New(t)
 
@@ -70,8 +83,42 @@ func TestBlockOracle(t *testing.T) {
 	testBlockOracleAndValidator(r, 5, 2, 2)
 }
 
+func TestBlockOracleProportionalToSpace(t *testing.T) {
+	r := require.New(t)
+
+	// 3 of the 30 space units of the active set: a tenth of the 10*20 blocks of the epoch
+	testWeightedBlockOracleAndValidator(r, 3, 30, 5, 10, 20)
+	// 6 of the 30 space units of the active set: a fifth of the 10*20 blocks of the epoch
+	testWeightedBlockOracleAndValidator(r, 6, 30, 5, 10, 20)
+	// a tiny fraction of the space still gets a single block
+	testWeightedBlockOracleAndValidator(r, 1, 1000, 5, 10, 20)
+}
+
+func TestGetNumberOfEligibleBlocks(t *testing.T) {
+	r := require.New(t)
+
+	n, err := getNumberOfEligibleBlocks(1, 5, 10, 20)
+	r.NoError(err)
+	r.Equal(uint32(40), n)
+
+	n, err = getNumberOfEligibleBlocks(4, 20, 10, 20)
+	r.NoError(err)
+	r.Equal(uint32(40), n)
+
+	n, err = getNumberOfEligibleBlocks(1, 1000, 10, 20)
+	r.NoError(err)
+	r.Equal(uint32(1), n)
+
+	_, err = getNumberOfEligibleBlocks(1, 0, 10, 20)
+	r.Error(err)
+}
+
 func testBlockOracleAndValidator(r *require.Assertions, activeSetSize uint32, committeeSize uint32, layersPerEpoch uint16) {
-	activationDB := &mockActivationDB{activeSetSize: activeSetSize, atxPublicationLayer: types.LayerID(0), atxs: map[string]map[types.LayerID]types.ATXID{}}
+	testWeightedBlockOracleAndValidator(r, 1, uint64(activeSetSize), activeSetSize, committeeSize, layersPerEpoch)
+}
+
+func testWeightedBlockOracleAndValidator(r *require.Assertions, spaceUnits uint32, activeSetSpaceUnits uint64, activeSetSize uint32, committeeSize uint32, layersPerEpoch uint16) {
+	activationDB := &mockActivationDB{activeSetSize: activeSetSize, spaceUnits: spaceUnits, activeSetSpaceUnits: activeSetSpaceUnits, atxPublicationLayer: types.LayerID(0), atxs: map[string]map[types.LayerID]types.ATXID{}}
 	beaconProvider := &EpochBeaconProvider{}
 	lg := log.NewDefault(nodeID.Key[:5])
 	blockOracle := NewMinerBlockOracle(committeeSize, activeSetSize, layersPerEpoch, activationDB, beaconProvider, vrfSigner, nodeID, func() bool { return true }, lg.WithName("blockOracle"))
@@ -94,7 +141,7 @@ func testBlockOracleAndValidator(r *require.Assertions, activeSetSize uint32, co
 		}
 	}
 
-	numberOfEligibleBlocks := committeeSize * uint32(layersPerEpoch) / activeSetSize
+	numberOfEligibleBlocks := uint32(uint64(committeeSize) * uint64(layersPerEpoch) * uint64(spaceUnits) / activeSetSpaceUnits)
 	if numberOfEligibleBlocks == 0 {
 		numberOfEligibleBlocks = 1
 	}
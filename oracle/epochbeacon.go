@@ -2,14 +2,41 @@ package oracle
 
 import (
 	"encoding/binary"
+
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 )
 
-// EpochBeaconProvider holds all the dependencies for generating an epoch beacon. There are currently none.
-type EpochBeaconProvider struct{}
+type beaconSource interface {
+	GetBeacon(epoch types.EpochID) ([]byte, error)
+}
+
+// EpochBeaconProvider provides the beacons that seed block eligibility. Before the tortoise-beacon upgrade, and in a
+// zero EpochBeaconProvider, the beacon of an epoch is its ID.
+type EpochBeaconProvider struct {
+	beacons  beaconSource
+	upgrades *upgrade.Schedule
+	log      log.Log
+}
+
+// NewEpochBeaconProvider returns an EpochBeaconProvider that provides the beacons of beacons from the epoch the
+// tortoise-beacon upgrade activates at.
+func NewEpochBeaconProvider(beacons beaconSource, upgrades *upgrade.Schedule, logger log.Log) *EpochBeaconProvider {
+	return &EpochBeaconProvider{beacons: beacons, upgrades: upgrades, log: logger}
+}
 
-// GetBeacon returns a beacon given an epoch ID. The current implementation returns the epoch ID in byte format.
+// GetBeacon returns a beacon given an epoch ID. Before the tortoise-beacon upgrade, it returns the epoch ID in byte
+// format. After it, it returns the tortoise beacon of the epoch, or the epoch ID if the node didn't calculate the
+// beacon, e.g. when it wasn't running during the previous epoch.
 func (p *EpochBeaconProvider) GetBeacon(epochNumber types.EpochID) []byte {
+	if p.beacons != nil && p.upgrades.Active(upgrade.TortoiseBeacon, epochNumber) {
+		beacon, err := p.beacons.GetBeacon(epochNumber)
+		if err == nil {
+			return beacon
+		}
+		p.log.With().Warning("no tortoise beacon for epoch, using the epoch id", epochNumber, log.Err(err))
+	}
 	ret := make([]byte, 32)
 	binary.LittleEndian.PutUint64(ret, uint64(epochNumber))
 	return ret
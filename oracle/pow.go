@@ -0,0 +1,141 @@
+package oracle
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/sha256-simd"
+)
+
+const (
+	powBlockDomain byte = iota + 1
+	powHareDomain
+)
+
+// PowOracle is a proof-of-work based eligibility oracle for local dev networks. It lets a network run without PoET and
+// PoST infrastructure: an identity proves that it is eligible for a block or a hare round by finding a nonce for which
+// the hash of the nonce and the eligibility context (identity, layer and round) has difficulty leading zero bits.
+// Every identity that does the work is eligible for a single block in every layer and for every hare round, so the
+// oracle provides no sybil resistance and must never be used on a public network.
+type PowOracle struct {
+	difficulty     uint8
+	layersPerEpoch uint16
+	nodeID         types.NodeID
+
+	epoch      types.EpochID
+	epochMutex sync.RWMutex
+	log        log.Log
+}
+
+// NewPowOracle returns a new PowOracle for nodeID, requiring difficulty leading zero bits in proof hashes.
+func NewPowOracle(difficulty uint8, layersPerEpoch uint16, nodeID types.NodeID, log log.Log) *PowOracle {
+	return &PowOracle{
+		difficulty:     difficulty,
+		layersPerEpoch: layersPerEpoch,
+		nodeID:         nodeID,
+		log:            log,
+	}
+}
+
+func powHash(domain byte, id string, layer types.LayerID, round int32, nonce uint64) [32]byte {
+	msg := make([]byte, 1+len(id)+8+4+8)
+	msg[0] = domain
+	copy(msg[1:], id)
+	offset := 1 + len(id)
+	binary.LittleEndian.PutUint64(msg[offset:], uint64(layer))
+	binary.LittleEndian.PutUint32(msg[offset+8:], uint32(round))
+	binary.LittleEndian.PutUint64(msg[offset+12:], nonce)
+	return sha256.Sum256(msg)
+}
+
+func leadingZeros(hash [32]byte) int {
+	zeros := 0
+	for _, b := range hash {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
+
+func (o *PowOracle) solve(domain byte, layer types.LayerID, round int32) []byte {
+	nonce := uint64(0)
+	for leadingZeros(powHash(domain, o.nodeID.Key, layer, round, nonce)) < int(o.difficulty) {
+		nonce++
+	}
+	proof := make([]byte, 8)
+	binary.LittleEndian.PutUint64(proof, nonce)
+	return proof
+}
+
+func (o *PowOracle) verify(domain byte, id string, layer types.LayerID, round int32, proof []byte) error {
+	if len(proof) != 8 {
+		return fmt.Errorf("proof of work must be 8 bytes, got %d", len(proof))
+	}
+	nonce := binary.LittleEndian.Uint64(proof)
+	if leadingZeros(powHash(domain, id, layer, round, nonce)) < int(o.difficulty) {
+		return errors.New("proof of work does not meet the difficulty")
+	}
+	return nil
+}
+
+// BlockEligible returns a single block eligibility proof for the given layer. Since there are no ATXs in a PoW
+// network, the returned ATXID is always empty.
+func (o *PowOracle) BlockEligible(layerID types.LayerID) (types.ATXID, []types.BlockEligibilityProof, error) {
+	o.epochMutex.Lock()
+	o.epoch = layerID.GetEpoch(o.layersPerEpoch)
+	o.epochMutex.Unlock()
+
+	proof := types.BlockEligibilityProof{J: 0, Sig: o.solve(powBlockDomain, layerID, 0)}
+	return *types.EmptyATXID, []types.BlockEligibilityProof{proof}, nil
+}
+
+// GetEligibleLayers returns the layers of the last epoch eligibility was queried for, which are all of its layers.
+func (o *PowOracle) GetEligibleLayers() []types.LayerID {
+	o.epochMutex.RLock()
+	first := o.epoch.FirstLayer(o.layersPerEpoch)
+	o.epochMutex.RUnlock()
+
+	layers := make([]types.LayerID, 0, o.layersPerEpoch)
+	for i := uint16(0); i < o.layersPerEpoch; i++ {
+		layers = append(layers, first.Add(i))
+	}
+	return layers
+}
+
+// BlockSignedAndEligible checks that the block's eligibility proof is a valid proof of work by the block's miner.
+func (o *PowOracle) BlockSignedAndEligible(block *types.Block) (bool, error) {
+	if block.EligibilityProof.J != 0 {
+		return false, fmt.Errorf("proof counter (%d) must be 0", block.EligibilityProof.J)
+	}
+	err := o.verify(powBlockDomain, block.MinerID().String(), block.LayerIndex, 0, block.EligibilityProof.Sig)
+	if err != nil {
+		return false, fmt.Errorf("invalid block eligibility: %v", err)
+	}
+	return true, nil
+}
+
+// Eligible checks that sig is a valid proof of work by id for the given layer and hare round.
+func (o *PowOracle) Eligible(layer types.LayerID, round int32, committeeSize int, id types.NodeID, sig []byte) (bool, error) {
+	if err := o.verify(powHareDomain, id.Key, layer, round, sig); err != nil {
+		o.log.With().Info("eligibility: invalid proof of work", id, layer, log.Int32("round", round), log.Err(err))
+		return false, nil
+	}
+	return true, nil
+}
+
+// Proof returns a proof of work for the given layer and hare round.
+func (o *PowOracle) Proof(layer types.LayerID, round int32) ([]byte, error) {
+	return o.solve(powHareDomain, layer, round), nil
+}
+
+// IsIdentityActiveOnConsensusView always returns true, since every identity may participate in a PoW network.
+func (o *PowOracle) IsIdentityActiveOnConsensusView(edID string, layer types.LayerID) (bool, error) {
+	return true, nil
+}
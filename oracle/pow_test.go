@@ -0,0 +1,74 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowOracle_BlockEligibility(t *testing.T) {
+	r := require.New(t)
+	sgn := signing.NewEdSigner()
+	nodeID := types.NodeID{Key: sgn.PublicKey().String()}
+	o := NewPowOracle(16, 3, nodeID, log.NewDefault(nodeID.ShortString()))
+
+	atxID, proofs, err := o.BlockEligible(7)
+	r.NoError(err)
+	r.Equal(*types.EmptyATXID, atxID)
+	r.Len(proofs, 1)
+	r.Equal([]types.LayerID{6, 7, 8}, o.GetEligibleLayers())
+
+	block := types.NewExistingBlock(7, []byte("data"))
+	block.EligibilityProof = proofs[0]
+	block.Signature = sgn.Sign(block.Bytes())
+	block.Initialize()
+	eligible, err := o.BlockSignedAndEligible(block)
+	r.NoError(err)
+	r.True(eligible)
+
+	// the proof is bound to the layer
+	block = types.NewExistingBlock(8, []byte("data"))
+	block.EligibilityProof = proofs[0]
+	block.Signature = sgn.Sign(block.Bytes())
+	block.Initialize()
+	eligible, err = o.BlockSignedAndEligible(block)
+	r.Error(err)
+	r.False(eligible)
+
+	// the proof is bound to the miner
+	other := signing.NewEdSigner()
+	block = types.NewExistingBlock(7, []byte("data"))
+	block.EligibilityProof = proofs[0]
+	block.Signature = other.Sign(block.Bytes())
+	block.Initialize()
+	eligible, err = o.BlockSignedAndEligible(block)
+	r.Error(err)
+	r.False(eligible)
+}
+
+func TestPowOracle_HareEligibility(t *testing.T) {
+	r := require.New(t)
+	nodeID := types.NodeID{Key: "aaaa"}
+	o := NewPowOracle(16, 3, nodeID, log.NewDefault(nodeID.ShortString()))
+
+	proof, err := o.Proof(5, 2)
+	r.NoError(err)
+	eligible, err := o.Eligible(5, 2, 10, nodeID, proof)
+	r.NoError(err)
+	r.True(eligible)
+
+	eligible, err = o.Eligible(5, 3, 10, nodeID, proof)
+	r.NoError(err)
+	r.False(eligible)
+
+	eligible, err = o.Eligible(5, 2, 10, types.NodeID{Key: "bbbb"}, proof)
+	r.NoError(err)
+	r.False(eligible)
+
+	eligible, err = o.Eligible(5, 2, 10, nodeID, []byte{1})
+	r.NoError(err)
+	r.False(eligible)
+}
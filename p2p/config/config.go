@@ -4,6 +4,7 @@ package config
 import (
 	"time"
 
+	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/log"
 )
 
@@ -12,8 +13,10 @@ const (
 	P2PDirectoryPath = "p2p"
 	// NodeDataFileName is the name of the file we store the the p2p identity keys
 	NodeDataFileName = "id.json"
-	// NodesDirectoryName is the name of the directory we store nodes identities under
+	// NodesDirectoryName is the name of the directory older versions stored nodes identities under
 	NodesDirectoryName = "nodes"
+	// IdentityFileName is the name of the file we store the p2p identity keys in, under the p2p directory
+	IdentityFileName = "identity.json"
 	// UnlimitedMsgSize is a constant used to check whether message size is set to unlimited size
 	UnlimitedMsgSize = 0
 )
@@ -51,6 +54,7 @@ type Config struct {
 	SwarmConfig           SwarmConfig   `mapstructure:"swarm"`
 	BufferSize            int           `mapstructure:"buffer-size"`
 	MsgSizeLimit          int           `mapstructure:"msg-size-limit"` // in bytes
+	ProtocolHash          types.Hash32  `mapstructure:"-"`              // hash of the node's protocol config, peers must have the same hash
 }
 
 // SwarmConfig specifies swarm config params.
@@ -62,6 +66,20 @@ type SwarmConfig struct {
 	RandomConnections      int      `mapstructure:"randcon"`
 	BootstrapNodes         []string `mapstructure:"bootnodes"`
 	PeersFile              string   `mapstructure:"peers-file"`
+	LazyPushProtocols      []string `mapstructure:"lazy-push-protocols"`
+	DedupCacheSize         int      `mapstructure:"dedup-cache-size"` // number of seen gossip messages remembered to drop duplicates
+	// DedupPersistWindow is how long seen gossip messages are remembered across restarts, so that messages received
+	// before a restart are dropped as duplicates after it. Seen messages aren't persisted if it's 0.
+	DedupPersistWindow time.Duration `mapstructure:"dedup-persist-window"`
+
+	// DirectoryURL is the base url of a peer directory service, queried for peers when the address book is empty.
+	// Directory bootstrap is disabled if it's empty.
+	DirectoryURL string `mapstructure:"directory-url"`
+	// DirectoryRegister opts in to registering the node's signed record with the peer directory every
+	// DirectoryInterval, advertising DirectoryCapabilities.
+	DirectoryRegister     bool          `mapstructure:"directory-register"`
+	DirectoryInterval     time.Duration `mapstructure:"directory-interval"`
+	DirectoryCapabilities []string      `mapstructure:"directory-capabilities"`
 }
 
 // DefaultConfig defines the default p2p configuration
@@ -76,6 +94,15 @@ func DefaultConfig() Config {
 		RandomConnections:      5,
 		BootstrapNodes:         []string{},   // these should be the spacemesh foundation bootstrap nodes
 		PeersFile:              "peers.json", // located under data-dir/<publickey>/<peer-file> not loaded or save if empty string is given.
+		// blocks and atxs are large, so they're announced to peers which pull them on demand
+		LazyPushProtocols: []string{"newBlock", "AtxGossip"},
+		DedupCacheSize:    10000,
+		DirectoryURL:      "",
+		DirectoryRegister: false,
+		DirectoryInterval: duration("10m"),
+
+		// the messages of the last minutes, the time it takes to restart after an upgrade
+		DedupPersistWindow: duration("10m"),
 	}
 
 	return Config{
@@ -2,9 +2,11 @@ package config
 
 // params are non-configurable (hard-coded) consts. To create a configurable param use Config.
 // add all node params here (non-configurable consts) - ideally most node params should be configurable.
+// The min client version is the version of the last wire change, peers of older versions are rejected in the
+// handshake: 0.0.2 changed the bytes that gossip envelopes sign.
 const (
-	ClientVersion    = "go-spacemesh/0.0.1"
-	MinClientVersion = "0.0.1"
+	ClientVersion    = "go-spacemesh/0.0.2"
+	MinClientVersion = "0.0.2"
 )
 
 // NetworkID represents the network that the node lives in
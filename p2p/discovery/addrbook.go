@@ -3,9 +3,11 @@ package discovery
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
 	"math/rand"
 	"net"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -94,6 +96,8 @@ type addrBook struct {
 	// todo: use arrays instead of maps
 	addrNew   [newBucketCount]map[node.ID]*KnownAddress
 	addrTried [triedBucketCount]map[node.ID]*KnownAddress
+	// anchors are the addresses of the peers we're connected to, they're never evicted from the tried buckets.
+	anchors map[node.ID]struct{}
 
 	localAddrMtx   sync.RWMutex
 	localAddresses []*node.Info
@@ -140,8 +144,9 @@ func (a *addrBook) isLocalAddressUnlocked(addr *node.Info) bool {
 }
 
 // updateAddress is a helper function to either update an address already known
-// to the address manager, or to add the address if not already known.
-func (a *addrBook) updateAddress(netAddr, srcAddr *node.Info) {
+// to the address manager, or to add the address if not already known. rec is
+// the signed record of the address, or nil if it isn't known.
+func (a *addrBook) updateAddress(netAddr, srcAddr *node.Info, rec *node.Record) {
 
 	if a.IsLocalAddress(netAddr) {
 		a.logger.Debug("skipping adding a local address %v", netAddr.String())
@@ -157,6 +162,9 @@ func (a *addrBook) updateAddress(netAddr, srcAddr *node.Info) {
 
 	ka := a.lookup(netAddr.PublicKey())
 	if ka != nil {
+		if rec != nil && !a.updateRecord(ka, rec, srcAddr) {
+			return
+		}
 		// TODO: only update addresses periodically.
 		// Update the last seen time and services.
 		// note that to prevent causing excess garbage on getaddr
@@ -185,7 +193,7 @@ func (a *addrBook) updateAddress(netAddr, srcAddr *node.Info) {
 	// Make a copy of the net address to avoid races since it is
 	// updated elsewhere in the addrmanager code and would otherwise
 	// change the actual netaddress on the peer.
-	ka = &KnownAddress{na: netAddr, srcAddr: srcAddr, lastSeen: time.Now()}
+	ka = &KnownAddress{na: netAddr, srcAddr: srcAddr, rec: rec, lastSeen: time.Now()}
 	a.addrIndex[netAddr.ID] = ka
 	a.nNew++
 	// XXX time penalty?
@@ -198,7 +206,7 @@ func (a *addrBook) updateAddress(netAddr, srcAddr *node.Info) {
 	}
 
 	// Enforce max addresses.
-	if len(a.addrNew[bucket]) > newBucketSize {
+	if len(a.addrNew[bucket]) >= newBucketSize {
 		a.logger.Debug("new bucket is full, expiring old")
 		a.expireNew(bucket)
 	}
@@ -210,6 +218,28 @@ func (a *addrBook) updateAddress(netAddr, srcAddr *node.Info) {
 	a.logger.Debug("Added new address %s for a total of %d addresses", netAddr.String(), a.nTried+a.nNew)
 }
 
+// updateRecord replaces the record of ka with rec if rec supersedes it, and
+// returns false if rec is rejected. The signer of the first record of a node
+// is pinned, records signed by another key are rejected unless the node sent
+// the record itself, since p2p keys can't sign and anyone can sign a record
+// for any node.
+func (a *addrBook) updateRecord(ka *KnownAddress, rec *node.Record, srcAddr *node.Info) bool {
+	if ka.rec != nil {
+		if !bytes.Equal(ka.rec.Signer, rec.Signer) {
+			if srcAddr.ID != rec.ID {
+				a.logger.Debug("ignoring record of %v signed by another key", rec.String())
+				return false
+			}
+		} else if !rec.Supersedes(ka.rec) {
+			return false
+		}
+	}
+	// the address is replaced rather than updated, see updateAddress.
+	ka.rec = rec
+	ka.na = &rec.Info
+	return true
+}
+
 // GetAddress returns a single address that should be routable.  It picks a
 // random one from the possible addresses with preference given to ones that
 // have not been used recently and should not pick 'close' addresses
@@ -282,6 +312,77 @@ func (a *addrBook) GetAddress() *KnownAddress {
 	}
 }
 
+// AddressBias controls which addresses SelectAddresses returns.
+type AddressBias struct {
+	// SeenWithin, when not zero, limits the addresses to those seen within
+	// this duration.
+	SeenWithin time.Duration
+	// NeverTried limits the addresses to those we never attempted.
+	NeverTried bool
+	// Filter, when set, limits the addresses to those it returns true for,
+	// e.g. to skip the peers we're connected to. Addresses don't carry the
+	// services of the nodes, so selecting by services is done here too.
+	Filter func(info *node.Info) bool
+}
+
+func (b AddressBias) match(ka *KnownAddress, now time.Time) bool {
+	if b.SeenWithin != 0 && now.Sub(ka.lastSeen) > b.SeenWithin {
+		return false
+	}
+	if b.NeverTried && !ka.lastattempt.IsZero() {
+		return false
+	}
+	return b.Filter == nil || b.Filter(ka.na)
+}
+
+// SelectAddresses returns up to n distinct addresses that match bias. Like
+// GetAddress, half of the addresses are picked from the tried buckets and
+// half from the new ones when there are enough of both, and addresses are
+// picked randomly with preference given to their chance.
+func (a *addrBook) SelectAddresses(n int, bias AddressBias) []*KnownAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	now := time.Now()
+	var tried, fresh []*KnownAddress
+	for _, ka := range a.addrIndex {
+		if !bias.match(ka, now) {
+			continue
+		}
+		if ka.tried {
+			tried = append(tried, ka)
+		} else {
+			fresh = append(fresh, ka)
+		}
+	}
+
+	// an odd n gives the extra address to tried or new with a 50% chance.
+	nTried := (n + a.rand.Intn(2)) / 2
+	if n-nTried > len(fresh) {
+		nTried = n - len(fresh)
+	}
+	if nTried > len(tried) {
+		nTried = len(tried)
+	}
+	out := a.sample(tried, nTried)
+	return append(out, a.sample(fresh, n-nTried)...)
+}
+
+// sample picks n distinct addresses from kas randomly, weighted by their
+// chance. it uses the Efraimidis-Spirakis weighted sampling without
+// replacement. kas is reordered.
+func (a *addrBook) sample(kas []*KnownAddress, n int) []*KnownAddress {
+	if n > len(kas) {
+		n = len(kas)
+	}
+	keys := make(map[*KnownAddress]float64, len(kas))
+	for _, ka := range kas {
+		keys[ka] = math.Log(1-a.rand.Float64()) / ka.chance()
+	}
+	sort.Slice(kas, func(i, j int) bool { return keys[kas[i]] > keys[kas[j]] })
+	return kas[:n]
+}
+
 // Lookup searches for an address using a public key. returns *Info
 func (a *addrBook) Lookup(addr p2pcrypto.PublicKey) (*node.Info, error) {
 	a.mtx.Lock()
@@ -294,18 +395,41 @@ func (a *addrBook) Lookup(addr p2pcrypto.PublicKey) (*node.Info, error) {
 	return d.na, nil
 }
 
+// AddressStats returns the statistics of the address of the node with the given public key.
+func (a *addrBook) AddressStats(addr p2pcrypto.PublicKey) (AddressStats, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	ka := a.lookup(addr)
+	if ka == nil {
+		return AddressStats{}, ErrLookupFailed
+	}
+	return ka.stats(), nil
+}
+
+// LookupRecord returns the signed record of the node with the given public key.
+func (a *addrBook) LookupRecord(addr p2pcrypto.PublicKey) (*node.Record, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	ka := a.lookup(addr)
+	if ka == nil || ka.rec == nil {
+		return nil, ErrLookupFailed
+	}
+	return ka.rec, nil
+}
+
 func (a *addrBook) lookup(addr p2pcrypto.PublicKey) *KnownAddress {
 	return a.addrIndex[addr.Array()]
 }
 
 // Attempt increases the given address' attempt counter and updates
-// the last attempt time.
+// the last attempt time. Addresses move to the tried buckets only once
+// they're good, so that addresses we merely dialed can't push out the
+// addresses we successfully connected to.
 func (a *addrBook) Attempt(key p2pcrypto.PublicKey) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
 	// lookup address.
-	// Surely address will be in tried by now?
 	ka := a.lookup(key)
 	if ka == nil {
 		return
@@ -313,8 +437,23 @@ func (a *addrBook) Attempt(key p2pcrypto.PublicKey) {
 	// set last tried time to now
 	ka.attempts++
 	ka.lastattempt = time.Now()
+}
 
-	a.moveToTriedUnlocked(ka)
+// Anchor protects the given address from being evicted from the tried
+// buckets. To be called when connecting to a peer, so that a flood of
+// addresses can't replace the peers we're connected to.
+func (a *addrBook) Anchor(key p2pcrypto.PublicKey) {
+	a.mtx.Lock()
+	a.anchors[key.Array()] = struct{}{}
+	a.mtx.Unlock()
+}
+
+// Unanchor lets the given address be evicted from the tried buckets
+// again. To be called when disconnecting from a peer.
+func (a *addrBook) Unanchor(key p2pcrypto.PublicKey) {
+	a.mtx.Lock()
+	delete(a.anchors, key.Array())
+	a.mtx.Unlock()
 }
 
 // Good marks the given address as good.  To be called after a successful
@@ -382,6 +521,13 @@ func (a *addrBook) moveToTriedUnlocked(ka *KnownAddress) {
 	// No room, we have to evict something else.
 
 	rmka := a.pickTried(bucket)
+	if rmka == nil {
+		// all the bucket is anchored, the address stays new.
+		ka.refs++
+		a.addrNew[oldBucket][addrKey] = ka
+		a.nNew++
+		return
+	}
 
 	// First bucket it would have been put in.
 	newBucket := a.getNewBucket(rmka.na.IP, rmka.srcAddr.IP)
@@ -411,12 +557,19 @@ func (a *addrBook) moveToTriedUnlocked(ka *KnownAddress) {
 	a.addrNew[newBucket][rmkey] = rmka
 }
 
-// pickTried selects an address from the tried bucket to be evicted.
-// We just choose the eldest. Bitcoind selects 4 random entries and throws away
-// the older of them.
+// pickTried selects an address from the tried bucket to be evicted. Bad
+// addresses are evicted first, otherwise we just choose the eldest. Bitcoind
+// selects 4 random entries and throws away the older of them. Anchors are
+// never evicted, nil is returned if all the bucket is anchored.
 func (a *addrBook) pickTried(bucket int) *KnownAddress {
 	var oldest *KnownAddress
-	for _, ka := range a.addrTried[bucket] {
+	for k, ka := range a.addrTried[bucket] {
+		if _, ok := a.anchors[k]; ok {
+			continue
+		}
+		if ka.isBad() {
+			return ka
+		}
 		if oldest == nil || oldest.lastSeen.After(ka.lastSeen) {
 			oldest = ka
 		}
@@ -459,24 +612,50 @@ func (a *addrBook) AddressCache() []*node.Info {
 	// TODO : take from buckets
 
 	allAddr := a.getAddresses()
+	numAddresses := shuffleCache(len(allAddr), func(i, j int) {
+		allAddr[i], allAddr[j] = allAddr[j], allAddr[i]
+	})
 
-	numAddresses := len(allAddr) * getAddrPercent / 100
+	// slice off the limit we are willing to share.
+	return allAddr[0:numAddresses]
+}
+
+// RecordCache returns the current cache of signed records, like AddressCache
+// but limited to the addresses we have a record of.
+func (a *addrBook) RecordCache() []*node.Record {
+	a.mtx.Lock()
+	allRecs := make([]*node.Record, 0, len(a.addrIndex))
+	for _, v := range a.addrIndex {
+		if v.rec != nil {
+			allRecs = append(allRecs, v.rec)
+		}
+	}
+	a.mtx.Unlock()
+
+	numRecords := shuffleCache(len(allRecs), func(i, j int) {
+		allRecs[i], allRecs[j] = allRecs[j], allRecs[i]
+	})
+	return allRecs[0:numRecords]
+}
+
+// shuffleCache returns the number of addresses out of total we are willing to
+// share, and randomly moves that many addresses to the start using swap.
+func shuffleCache(total int, swap func(i, j int)) int {
+	numAddresses := total * getAddrPercent / 100
 	if numAddresses > getAddrMax {
 		numAddresses = getAddrMax
 	} else if numAddresses == 0 {
-		numAddresses = len(allAddr)
+		numAddresses = total
 	}
 
 	// Fisher-Yates shuffle the array. We only need to do the first
 	// `numAddresses' since we are throwing the rest.
 	for i := 0; i < numAddresses; i++ {
 		// pick a number between current index and the end
-		j := rand.Intn(len(allAddr)-i) + i
-		allAddr[i], allAddr[j] = allAddr[j], allAddr[i]
+		j := rand.Intn(total-i) + i
+		swap(i, j)
 	}
-
-	// slice off the limit we are willing to share.
-	return allAddr[0:numAddresses]
+	return numAddresses
 }
 
 // getAddresses returns all of the addresses currently found within the
@@ -622,7 +801,7 @@ func (a *addrBook) AddAddresses(addrs []*node.Info, srcAddr *node.Info) {
 	defer a.mtx.Unlock()
 
 	for _, na := range addrs {
-		a.updateAddress(na, srcAddr)
+		a.updateAddress(na, srcAddr, nil)
 	}
 }
 
@@ -633,7 +812,28 @@ func (a *addrBook) AddAddress(addr, srcAddr *node.Info) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
-	a.updateAddress(addr, srcAddr)
+	a.updateAddress(addr, srcAddr, nil)
+}
+
+// AddRecords adds the addresses of verified signed records to the address
+// manager, see AddRecord. It is safe for concurrent access.
+func (a *addrBook) AddRecords(recs []*node.Record, srcAddr *node.Info) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for _, rec := range recs {
+		a.updateAddress(&rec.Info, srcAddr, rec)
+	}
+}
+
+// AddRecord adds the address of a verified signed record to the address
+// manager. A known address is replaced only by a record that supersedes its
+// current one. It is safe for concurrent access.
+func (a *addrBook) AddRecord(rec *node.Record, srcAddr *node.Info) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.updateAddress(&rec.Info, srcAddr, rec)
 }
 
 // RemoveAddress
@@ -670,6 +870,7 @@ func (a *addrBook) RemoveAddress(key p2pcrypto.PublicKey) {
 func (a *addrBook) reset() {
 
 	a.addrIndex = make(map[node.ID]*KnownAddress)
+	a.anchors = make(map[node.ID]struct{})
 
 	// fill key with bytes from a good random source.
 	err := crypto.GetRandomBytesToBuffer(32, a.key[:])
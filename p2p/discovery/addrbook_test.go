@@ -2,9 +2,12 @@ package discovery
 
 import (
 	"github.com/spacemeshos/go-spacemesh/p2p/config"
+	"github.com/spacemeshos/go-spacemesh/p2p/node"
+	"github.com/spacemeshos/go-spacemesh/signing"
 	"github.com/stretchr/testify/require"
 
 	"testing"
+	"time"
 )
 
 func testAddrBook(name string) *addrBook {
@@ -150,3 +153,173 @@ func Test_LocalAddreses(t *testing.T) {
 	require.NotNil(t, nd)
 	require.Equal(t, nd.ID, addr3.ID)
 }
+
+func TestAttemptStaysNew(t *testing.T) {
+	n := testAddrBook(t.Name())
+
+	nd := generateDiscNode()
+	n.AddAddress(nd, n.localAddresses[0])
+	n.Attempt(nd.PublicKey())
+	require.Equal(t, 1, n.nNew)
+	require.Equal(t, 0, n.nTried)
+
+	n.Good(nd.PublicKey())
+	require.Equal(t, 0, n.nNew)
+	require.Equal(t, 1, n.nTried)
+}
+
+// fillTriedBucket adds triedBucketSize good addresses with the same ip, so that they all land in the same tried bucket.
+func fillTriedBucket(n *addrBook) []*node.Info {
+	nds := generateDiscNodes(triedBucketSize + 1)
+	for _, nd := range nds {
+		nd.IP = nds[0].IP
+	}
+	for _, nd := range nds[:triedBucketSize] {
+		n.AddAddress(nd, n.localAddresses[0])
+		n.Good(nd.PublicKey())
+	}
+	return nds
+}
+
+func TestGood_EvictsBadTried(t *testing.T) {
+	n := testAddrBook(t.Name())
+	nds := fillTriedBucket(n)
+	require.Equal(t, triedBucketSize, n.nTried)
+
+	bad := n.lookup(nds[triedBucketSize/2].PublicKey())
+	bad.attempts = maxFailures
+	bad.lastsuccess = time.Now().Add(-2 * minBadDays * 24 * time.Hour)
+	bad.lastattempt = time.Now().Add(-time.Hour)
+
+	n.AddAddress(nds[triedBucketSize], n.localAddresses[0])
+	n.Good(nds[triedBucketSize].PublicKey())
+	require.True(t, n.lookup(nds[triedBucketSize].PublicKey()).tried)
+	require.False(t, bad.tried)
+	require.Equal(t, triedBucketSize, n.nTried)
+}
+
+func TestGood_ProtectsAnchors(t *testing.T) {
+	n := testAddrBook(t.Name())
+	nds := fillTriedBucket(n)
+	for _, nd := range nds[:triedBucketSize] {
+		n.Anchor(nd.PublicKey())
+	}
+
+	// all the tried bucket is anchored, the new address stays new
+	n.AddAddress(nds[triedBucketSize], n.localAddresses[0])
+	n.Good(nds[triedBucketSize].PublicKey())
+	require.False(t, n.lookup(nds[triedBucketSize].PublicKey()).tried)
+	require.Equal(t, 1, n.nNew)
+
+	// the oldest address that isn't anchored is evicted
+	n.Unanchor(nds[0].PublicKey())
+	n.Good(nds[triedBucketSize].PublicKey())
+	require.True(t, n.lookup(nds[triedBucketSize].PublicKey()).tried)
+	require.False(t, n.lookup(nds[0].PublicKey()).tried)
+	require.Equal(t, 1, n.nNew)
+	require.Equal(t, triedBucketSize, n.nTried)
+}
+
+func TestSelectAddresses(t *testing.T) {
+	n := testAddrBook(t.Name())
+	require.Len(t, n.SelectAddresses(10, AddressBias{}), 0)
+
+	nds := generateDiscNodes(50)
+	n.AddAddresses(nds, n.localAddresses[0])
+	for _, nd := range nds[:10] {
+		n.Good(nd.PublicKey())
+	}
+
+	sel := n.SelectAddresses(20, AddressBias{})
+	require.Len(t, sel, 20)
+	set := make(map[node.ID]struct{})
+	tried := 0
+	for _, ka := range sel {
+		set[ka.na.ID] = struct{}{}
+		if ka.tried {
+			tried++
+		}
+	}
+	require.Len(t, set, 20)
+	require.Equal(t, 10, tried)
+
+	require.Len(t, n.SelectAddresses(100, AddressBias{}), 50)
+}
+
+func TestSelectAddresses_Bias(t *testing.T) {
+	n := testAddrBook(t.Name())
+
+	nds := generateDiscNodes(20)
+	n.AddAddresses(nds, n.localAddresses[0])
+	for _, nd := range nds[:5] {
+		n.Attempt(nd.PublicKey())
+	}
+
+	sel := n.SelectAddresses(20, AddressBias{NeverTried: true})
+	require.Len(t, sel, 15)
+	for _, ka := range sel {
+		require.True(t, ka.lastattempt.IsZero())
+	}
+
+	n.addrIndex[nds[0].ID].lastSeen = time.Now().Add(-time.Hour)
+	sel = n.SelectAddresses(20, AddressBias{SeenWithin: time.Minute})
+	require.Len(t, sel, 19)
+
+	skip := nds[1].PublicKey()
+	sel = n.SelectAddresses(20, AddressBias{Filter: func(info *node.Info) bool { return info.PublicKey() != skip }})
+	require.Len(t, sel, 19)
+	for _, ka := range sel {
+		require.NotEqual(t, skip, ka.na.PublicKey())
+	}
+}
+
+func TestAddrBook_AddRecord(t *testing.T) {
+	r := require.New(t)
+	n := testAddrBook("addrecord")
+	src := generateDiscNode()
+	signer := signing.NewEdSigner()
+
+	info := generateDiscNode()
+	n.AddAddress(info, src)
+	_, err := n.LookupRecord(info.PublicKey())
+	r.Equal(ErrLookupFailed, err)
+	r.Empty(n.RecordCache())
+
+	// a record is attached to a known address
+	first := node.NewRecord(*info, 2, signer)
+	n.AddRecord(first, src)
+	rec, err := n.LookupRecord(info.PublicKey())
+	r.NoError(err)
+	r.Equal(first, rec)
+	r.Equal([]*node.Record{first}, n.RecordCache())
+
+	// stale records are ignored, newer ones replace the address
+	moved := *info
+	moved.ProtocolPort++
+	n.AddRecord(node.NewRecord(moved, 1, signer), src)
+	got, err := n.Lookup(info.PublicKey())
+	r.NoError(err)
+	r.Equal(info.ProtocolPort, got.ProtocolPort)
+	newer := node.NewRecord(moved, 3, signer)
+	n.AddRecord(newer, src)
+	got, err = n.Lookup(info.PublicKey())
+	r.NoError(err)
+	r.Equal(moved.ProtocolPort, got.ProtocolPort)
+
+	// records signed by another key are ignored unless the node sends them itself
+	spoofed := node.NewRecord(*info, 4, signing.NewEdSigner())
+	n.AddRecord(spoofed, src)
+	rec, err = n.LookupRecord(info.PublicKey())
+	r.NoError(err)
+	r.Equal(newer, rec)
+	n.AddRecord(spoofed, info)
+	rec, err = n.LookupRecord(info.PublicKey())
+	r.NoError(err)
+	r.Equal(spoofed, rec)
+
+	// unsigned updates don't drop the record
+	n.AddAddress(info, src)
+	rec, err = n.LookupRecord(info.PublicKey())
+	r.NoError(err)
+	r.Equal(spoofed, rec)
+}
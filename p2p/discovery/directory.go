@@ -0,0 +1,176 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/node"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// directoryTimeout is the timeout of a request to the peer directory.
+const directoryTimeout = 10 * time.Second
+
+// maxDirectoryNodes is the maximal number of nodes taken from a single directory response.
+const maxDirectoryNodes = 100
+
+// DirectoryEntry is the registration of a node in a peer directory. Nodes POST their entry to <directory>/v1/nodes and
+// GET <directory>/v1/nodes returns a DirectoryNodes of the registered entries.
+type DirectoryEntry struct {
+	Record       []byte   `json:"record"` // the node's record, xdr encoded
+	Capabilities []string `json:"capabilities"`
+	Signature    []byte   `json:"signature"` // signature of the record's signer over the record and the capabilities
+}
+
+// DirectoryNodes is the response of the peer directory's node listing.
+type DirectoryNodes struct {
+	Nodes []DirectoryEntry `json:"nodes"`
+}
+
+// signedBytes returns the bytes the node signs: the record followed by every capability, each terminated by a zero.
+func (e *DirectoryEntry) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(e.Record)
+	for _, c := range e.Capabilities {
+		buf.WriteString(c)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+// newDirectoryEntry returns the entry of rec advertising capabilities, signed by signer.
+func newDirectoryEntry(rec *node.Record, capabilities []string, signer *signing.EdSigner) (*DirectoryEntry, error) {
+	b, err := types.InterfaceToBytes(rec)
+	if err != nil {
+		return nil, err
+	}
+	e := &DirectoryEntry{Record: b, Capabilities: capabilities}
+	e.Signature = signer.Sign(e.signedBytes())
+	return e, nil
+}
+
+// record decodes and verifies the entry's record and the entry's signature by the record's signer.
+func (e *DirectoryEntry) record() (*node.Record, error) {
+	rec := &node.Record{}
+	if err := types.BytesToInterface(e.Record, rec); err != nil {
+		return nil, err
+	}
+	if err := rec.Verify(); err != nil {
+		return nil, err
+	}
+	if !signing.Verify(signing.NewPublicKey(rec.Signer), e.signedBytes(), e.Signature) {
+		return nil, errors.New("invalid directory entry signature")
+	}
+	return rec, nil
+}
+
+// directory is a client of a peer directory service, a second bootstrap mechanism besides the configured bootnodes.
+type directory struct {
+	url          string
+	capabilities []string
+	signer       *signing.EdSigner
+	client       *http.Client
+	logger       log.Log
+}
+
+func newDirectory(url string, capabilities []string, signer *signing.EdSigner, logger log.Log) *directory {
+	return &directory{
+		url:          strings.TrimSuffix(url, "/"),
+		capabilities: capabilities,
+		signer:       signer,
+		client:       &http.Client{Timeout: directoryTimeout},
+		logger:       logger,
+	}
+}
+
+func (d *directory) req(ctx context.Context, method string, reqBody, resBody interface{}) error {
+	var body bytes.Buffer
+	if reqBody != nil {
+		if err := json.NewEncoder(&body).Encode(reqBody); err != nil {
+			return fmt.Errorf("request json marshal failure: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, d.url+"/v1/nodes", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("response status code: %d, body: %s", res.StatusCode, string(data))
+	}
+	if resBody != nil {
+		if err := json.NewDecoder(res.Body).Decode(resBody); err != nil {
+			return fmt.Errorf("response json decode failure: %v", err)
+		}
+	}
+	return nil
+}
+
+// register registers rec, the record of the local node, with the directory.
+func (d *directory) register(ctx context.Context, rec *node.Record) error {
+	e, err := newDirectoryEntry(rec, d.capabilities, d.signer)
+	if err != nil {
+		return err
+	}
+	return d.req(ctx, http.MethodPost, e, nil)
+}
+
+// records returns the verified records of the nodes registered in the directory. Invalid entries are skipped.
+func (d *directory) records(ctx context.Context) ([]*node.Record, error) {
+	res := &DirectoryNodes{}
+	if err := d.req(ctx, http.MethodGet, nil, res); err != nil {
+		return nil, err
+	}
+	recs := make([]*node.Record, 0, len(res.Nodes))
+	for i := range res.Nodes {
+		if len(recs) == maxDirectoryNodes {
+			break
+		}
+		rec, err := res.Nodes[i].record()
+		if err != nil {
+			d.logger.Warning("skipping invalid peer directory entry: %v", err)
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// registerLoop registers the local record with the directory every interval until stop is closed. Registration waits
+// until the local record is complete, i.e. the node learned its ip.
+func (d *directory) registerLoop(local func() *node.Record, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if rec := local(); rec.Verify() == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), directoryTimeout)
+			if err := d.register(ctx, rec); err != nil {
+				d.logger.Warning("failed to register with the peer directory: %v", err)
+			} else {
+				d.logger.With().Debug("registered with the peer directory", log.Uint64("seq", rec.Seq))
+			}
+			cancel()
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
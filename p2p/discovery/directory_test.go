@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/config"
+	"github.com/spacemeshos/go-spacemesh/p2p/node"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+// testDirectory is an in-memory peer directory that lists the entries registered with it, and extra entries.
+type testDirectory struct {
+	mu      sync.Mutex
+	entries []DirectoryEntry
+	extra   []DirectoryEntry
+}
+
+func (td *testDirectory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	switch r.Method {
+	case http.MethodPost:
+		var e DirectoryEntry
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := e.record(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		td.entries = append(td.entries, e)
+	case http.MethodGet:
+		_ = json.NewEncoder(w).Encode(DirectoryNodes{Nodes: append(append([]DirectoryEntry{}, td.entries...), td.extra...)})
+	}
+}
+
+func TestDirectory_RegisterAndRecords(t *testing.T) {
+	r := require.New(t)
+	td := &testDirectory{}
+	srv := httptest.NewServer(td)
+	defer srv.Close()
+
+	signer := signing.NewEdSigner()
+	dir := newDirectory(srv.URL+"/", []string{"bootnode"}, signer, GetTestLogger(t.Name()))
+	rec := node.NewRecord(*generateDiscNode(), 1, signer)
+	r.NoError(dir.register(context.TODO(), rec))
+	r.Len(td.entries, 1)
+	r.Equal([]string{"bootnode"}, td.entries[0].Capabilities)
+
+	// the directory rejects entries whose capabilities weren't signed by the node
+	forged, err := newDirectoryEntry(node.NewRecord(*generateDiscNode(), 1, signer), []string{"gossip"}, signer)
+	r.NoError(err)
+	forged.Capabilities = []string{"bootnode"}
+	r.Error(dir.req(context.TODO(), http.MethodPost, forged, nil))
+
+	// and the client skips them in listings
+	td.extra = append(td.extra, *forged)
+	recs, err := dir.records(context.TODO())
+	r.NoError(err)
+	r.Len(recs, 1)
+	r.Equal(rec.Info, recs[0].Info)
+	r.Equal(rec.Seq, recs[0].Seq)
+}
+
+func TestRefresher_BootstrapFromDirectory(t *testing.T) {
+	r := require.New(t)
+	td := &testDirectory{}
+	srv := httptest.NewServer(td)
+	defer srv.Close()
+
+	registered := generateDiscRecords(10)
+	signer := signing.NewEdSigner()
+	for i, info := range recordInfos(registered) {
+		registered[i] = node.NewRecord(*info, 1, signer)
+		e, err := newDirectoryEntry(registered[i], nil, signer)
+		r.NoError(err)
+		td.extra = append(td.extra, *e)
+	}
+
+	cfg := config.DefaultConfig()
+	local := generateDiscNode()
+	disc := &mockDisc{}
+	addrbk := newAddrBook(cfg.SwarmConfig, "", GetTestLogger("test.newRefresher.addrbook"))
+	ref := newRefresher(local.PublicKey(), addrbk, disc, []*node.Info{}, GetTestLogger("test.newRefresher"))
+	ref.directory = newDirectory(srv.URL, nil, signing.NewEdSigner(), GetTestLogger(t.Name()))
+	ref.backoffFunc = func(tries int) time.Duration { return time.Millisecond }
+
+	r.NoError(ref.Bootstrap(context.TODO(), 5))
+	for _, rec := range registered {
+		info, err := addrbk.Lookup(rec.PublicKey())
+		r.NoError(err)
+		r.Equal(rec.PublicKey(), info.PublicKey())
+	}
+}
@@ -5,12 +5,14 @@ package discovery
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/p2p/config"
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/server"
+	"github.com/spacemeshos/go-spacemesh/timesync"
 )
 
 // PeerStore is an interface to the discovery protocol
@@ -19,7 +21,7 @@ type PeerStore interface {
 	Lookup(pubkey p2pcrypto.PublicKey) (*node.Info, error)
 	Update(addr, src *node.Info)
 
-	SelectPeers(ctx context.Context, qty int) []*node.Info
+	SelectPeers(ctx context.Context, qty int, bias AddressBias) []*node.Info
 	Bootstrap(ctx context.Context) error
 	Size() int
 
@@ -30,12 +32,18 @@ type PeerStore interface {
 
 	Good(key p2pcrypto.PublicKey)
 	Attempt(key p2pcrypto.PublicKey)
+	Anchor(key p2pcrypto.PublicKey)
+	Unanchor(key p2pcrypto.PublicKey)
+
+	PeerTimeDrift() (time.Duration, int)
+	AddressStats(key p2pcrypto.PublicKey) (AddressStats, error)
 }
 
 // Protocol is the API of node messages used to discover new nodes.
 type Protocol interface {
 	Ping(p p2pcrypto.PublicKey) error
-	GetAddresses(server p2pcrypto.PublicKey) ([]*node.Info, error)
+	GetAddresses(server p2pcrypto.PublicKey) ([]*node.Record, error)
+	FindNode(server p2pcrypto.PublicKey, target p2pcrypto.PublicKey) ([]*node.Record, error)
 	SetLocalAddresses(tcp, udp int)
 	Close()
 }
@@ -43,16 +51,24 @@ type Protocol interface {
 type addressBook interface {
 	Good(key p2pcrypto.PublicKey)
 	Attempt(key p2pcrypto.PublicKey)
+	Anchor(key p2pcrypto.PublicKey)
+	Unanchor(key p2pcrypto.PublicKey)
 
 	RemoveAddress(key p2pcrypto.PublicKey)
 	AddAddress(addr, srcAddr *node.Info)
 	AddAddresses(addrs []*node.Info, srcAddr *node.Info)
+	AddRecord(rec *node.Record, srcAddr *node.Info)
+	AddRecords(recs []*node.Record, srcAddr *node.Info)
 
 	NeedNewAddresses() bool
 	Lookup(key p2pcrypto.PublicKey) (*node.Info, error)
+	LookupRecord(key p2pcrypto.PublicKey) (*node.Record, error)
+	AddressStats(key p2pcrypto.PublicKey) (AddressStats, error)
 	AddressCache() []*node.Info
+	RecordCache() []*node.Record
 	NumAddresses() int
 	GetAddress() *KnownAddress
+	SelectAddresses(n int, bias AddressBias) []*KnownAddress
 
 	AddLocalAddress(info *node.Info)
 	IsLocalAddress(info *node.Info) bool
@@ -82,6 +98,8 @@ type Discovery struct {
 	local        node.LocalNode
 	rt           addressBook
 	bootstrapper bootstrapper
+	drifts       *timesync.PeerDrifts
+	shutdown     chan struct{}
 }
 
 // Size returns the size of addrBook.
@@ -99,6 +117,26 @@ func (d *Discovery) Attempt(key p2pcrypto.PublicKey) {
 	d.rt.Attempt(key)
 }
 
+// Anchor protects the node from eviction from the addrBook while we're connected to it.
+func (d *Discovery) Anchor(key p2pcrypto.PublicKey) {
+	d.rt.Anchor(key)
+}
+
+// Unanchor lets the node be evicted from the addrBook again.
+func (d *Discovery) Unanchor(key p2pcrypto.PublicKey) {
+	d.rt.Unanchor(key)
+}
+
+// PeerTimeDrift returns the median drift of our clock from the clocks of the peers we pinged, and the number of peers.
+func (d *Discovery) PeerTimeDrift() (time.Duration, int) {
+	return d.drifts.Median()
+}
+
+// AddressStats returns the address book's statistics of the address of the node with the given public key.
+func (d *Discovery) AddressStats(key p2pcrypto.PublicKey) (AddressStats, error) {
+	return d.rt.AddressStats(key)
+}
+
 func (d *Discovery) refresh(ctx context.Context, peersToGet int) error {
 	err := d.bootstrapper.Bootstrap(ctx, peersToGet)
 	if err != nil {
@@ -108,8 +146,8 @@ func (d *Discovery) refresh(ctx context.Context, peersToGet int) error {
 	return nil
 }
 
-// SelectPeers asks routing table to randomly select a slice of nodes in size `qty`
-func (d *Discovery) SelectPeers(ctx context.Context, qty int) []*node.Info {
+// SelectPeers asks routing table to randomly select a slice of up to `qty` distinct nodes that match bias
+func (d *Discovery) SelectPeers(ctx context.Context, qty int, bias AddressBias) []*node.Info {
 
 	if d.rt.NeedNewAddresses() {
 		err := d.refresh(ctx, qty) // TODO: use ctx with timeout, check errors
@@ -118,28 +156,23 @@ func (d *Discovery) SelectPeers(ctx context.Context, qty int) []*node.Info {
 		}
 	}
 
-	out := make([]*node.Info, 0, qty)
-	set := make(map[p2pcrypto.PublicKey]struct{})
-	for i := 0; i < qty; i++ {
-		add := d.rt.GetAddress()
-		if add == nil {
-			// addrbook is empty
-			return out
-		}
-
-		if _, ok := set[add.na.PublicKey()]; ok {
-			continue
-		}
-		out = append(out, add.NodeInfo())
-		set[add.NodeInfo().PublicKey()] = struct{}{}
+	kas := d.rt.SelectAddresses(qty, bias)
+	out := make([]*node.Info, 0, len(kas))
+	for _, ka := range kas {
+		out = append(out, ka.NodeInfo())
 	}
 	return out
 }
 
-// Lookup searched a node in the address book. *NOTE* this returns a `Node` with the udpAddress as `Address()`.
-// this is because Lookup is only used in the udp mux.
+// Lookup searched a node in the address book, and in the network if it's not in the address book. *NOTE* this returns
+// a `Node` with the udpAddress as `Address()`. this is because Lookup is only used in the udp mux.
 func (d *Discovery) Lookup(key p2pcrypto.PublicKey) (*node.Info, error) {
-	return d.rt.Lookup(key)
+	if n, err := d.rt.Lookup(key); err == nil {
+		return n, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	return d.findNode(ctx, key)
 }
 
 // Update adds an addr to the addrBook
@@ -150,16 +183,23 @@ func (d *Discovery) Update(addr, src *node.Info) {
 // New creates a new Discovery
 func New(ln node.LocalNode, config config.SwarmConfig, service server.Service, path string, logger log.Log) *Discovery {
 	d := &Discovery{
-		config: config,
-		logger: logger,
-		local:  ln,
-		rt:     newAddrBook(config, path, logger),
+		config:   config,
+		logger:   logger,
+		local:    ln,
+		rt:       newAddrBook(config, path, logger),
+		shutdown: make(chan struct{}),
 	}
 
 	d.rt.Start()
 	d.rt.AddLocalAddress(&node.Info{ID: ln.PublicKey().Array()})
 
-	d.disc = newProtocol(ln.PublicKey(), d.rt, service, logger)
+	signer, err := ln.Signer()
+	if err != nil {
+		logger.Panic("cannot create the node record signer: %v", err)
+	}
+	p := newProtocol(ln.PublicKey(), signer, d.rt, service, logger)
+	d.disc = p
+	d.drifts = p.drifts
 
 	bn := make([]*node.Info, 0, len(config.BootstrapNodes))
 	for _, n := range config.BootstrapNodes {
@@ -173,13 +213,22 @@ func New(ln node.LocalNode, config config.SwarmConfig, service server.Service, p
 	}
 
 	//TODO: Return err if no bootstrap nodes were parsed.
-	d.bootstrapper = newRefresher(ln.PublicKey(), d.rt, d.disc, bn, logger)
+	ref := newRefresher(ln.PublicKey(), d.rt, d.disc, bn, logger)
+	if config.DirectoryURL != "" {
+		dir := newDirectory(config.DirectoryURL, config.DirectoryCapabilities, signer, logger)
+		ref.directory = dir
+		if config.DirectoryRegister {
+			go dir.registerLoop(p.localRecord, config.DirectoryInterval, d.shutdown)
+		}
+	}
+	d.bootstrapper = ref
 
 	return d
 }
 
 // Shutdown stops the discovery service
 func (d *Discovery) Shutdown() {
+	close(d.shutdown)
 	d.rt.Stop()
 }
 
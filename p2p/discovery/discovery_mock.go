@@ -2,6 +2,7 @@ package discovery
 
 import (
 	"context"
+	"time"
 
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
@@ -11,7 +12,7 @@ import (
 type MockPeerStore struct {
 	UpdateFunc      func(n, src *node.Info)
 	updateCount     int
-	SelectPeersFunc func(ctx context.Context, qty int) []*node.Info
+	SelectPeersFunc func(ctx context.Context, qty int, bias AddressBias) []*node.Info
 	bsres           error
 	bsCount         int
 	LookupFunc      func(p2pcrypto.PublicKey) (*node.Info, error)
@@ -20,9 +21,13 @@ type MockPeerStore struct {
 
 	IsLocalAddressFunc func(info *node.Info) bool
 
-	RemoveFunc  func(key p2pcrypto.PublicKey)
-	GoodFunc    func(key p2pcrypto.PublicKey)
-	AttemptFunc func(key p2pcrypto.PublicKey)
+	RemoveFunc   func(key p2pcrypto.PublicKey)
+	GoodFunc     func(key p2pcrypto.PublicKey)
+	AttemptFunc  func(key p2pcrypto.PublicKey)
+	AnchorFunc   func(key p2pcrypto.PublicKey)
+	UnanchorFunc func(key p2pcrypto.PublicKey)
+
+	PeerTimeDriftFunc func() (time.Duration, int)
 }
 
 // Remove mock
@@ -89,9 +94,9 @@ func (m *MockPeerStore) Bootstrap(ctx context.Context) error {
 }
 
 // SelectPeers mocks selecting peers.
-func (m *MockPeerStore) SelectPeers(ctx context.Context, qty int) []*node.Info {
+func (m *MockPeerStore) SelectPeers(ctx context.Context, qty int, bias AddressBias) []*node.Info {
 	if m.SelectPeersFunc != nil {
-		return m.SelectPeersFunc(ctx, qty)
+		return m.SelectPeersFunc(ctx, qty, bias)
 	}
 	return []*node.Info{}
 }
@@ -126,20 +131,53 @@ func (m *MockPeerStore) Attempt(key p2pcrypto.PublicKey) {
 	}
 }
 
+// Anchor is a mock.
+func (m *MockPeerStore) Anchor(key p2pcrypto.PublicKey) {
+	if m.AnchorFunc != nil {
+		m.AnchorFunc(key)
+	}
+}
+
+// Unanchor is a mock.
+func (m *MockPeerStore) Unanchor(key p2pcrypto.PublicKey) {
+	if m.UnanchorFunc != nil {
+		m.UnanchorFunc(key)
+	}
+}
+
+// PeerTimeDrift is a mock.
+func (m *MockPeerStore) PeerTimeDrift() (time.Duration, int) {
+	if m.PeerTimeDriftFunc != nil {
+		return m.PeerTimeDriftFunc()
+	}
+	return 0, 0
+}
+
+// AddressStats is a mock.
+func (m *MockPeerStore) AddressStats(key p2pcrypto.PublicKey) (AddressStats, error) {
+	return AddressStats{}, ErrLookupFailed
+}
+
 // mockAddrBook
 type mockAddrBook struct {
 	addAddressFunc func(n, src *node.Info)
+	AddRecordFunc  func(rec *node.Record, src *node.Info)
 
 	LookupFunc func(p2pcrypto.PublicKey) (*node.Info, error)
 	lookupRes  *node.Info
 	lookupErr  error
 
+	LookupRecordFunc func(p2pcrypto.PublicKey) (*node.Record, error)
+
 	GetAddressFunc func() *KnownAddress
 	GetAddressRes  *KnownAddress
 
+	SelectAddressesFunc func(n int, bias AddressBias) []*KnownAddress
+
 	NeedNewAddressesFunc func() bool
 
 	AddressCacheFunc func() []*node.Info
+	RecordCacheFunc  func() []*node.Record
 
 	GoodFunc    func(key p2pcrypto.PublicKey)
 	AttemptFunc func(key p2pcrypto.PublicKey)
@@ -186,6 +224,12 @@ func (m *mockAddrBook) Attempt(key p2pcrypto.PublicKey) {
 	}
 }
 
+func (m *mockAddrBook) Anchor(key p2pcrypto.PublicKey) {
+}
+
+func (m *mockAddrBook) Unanchor(key p2pcrypto.PublicKey) {
+}
+
 func (m *mockAddrBook) NeedNewAddresses() bool {
 	if m.NeedNewAddressesFunc != nil {
 		return m.NeedNewAddressesFunc()
@@ -220,6 +264,22 @@ func (m *mockAddrBook) AddAddresses(n []*node.Info, src *node.Info) {
 	}
 }
 
+// AddRecord mock
+func (m *mockAddrBook) AddRecord(rec *node.Record, src *node.Info) {
+	if m.AddRecordFunc != nil {
+		m.AddRecordFunc(rec, src)
+	}
+}
+
+// AddRecords mock
+func (m *mockAddrBook) AddRecords(recs []*node.Record, src *node.Info) {
+	if m.AddRecordFunc != nil {
+		for _, rec := range recs {
+			m.AddRecordFunc(rec, src)
+		}
+	}
+}
+
 // AddressCache mock
 func (m *mockAddrBook) AddressCache() []*node.Info {
 	if m.AddressCacheFunc != nil {
@@ -228,6 +288,27 @@ func (m *mockAddrBook) AddressCache() []*node.Info {
 	return nil
 }
 
+// RecordCache mock
+func (m *mockAddrBook) RecordCache() []*node.Record {
+	if m.RecordCacheFunc != nil {
+		return m.RecordCacheFunc()
+	}
+	return nil
+}
+
+// AddressStats mock
+func (m *mockAddrBook) AddressStats(pubkey p2pcrypto.PublicKey) (AddressStats, error) {
+	return AddressStats{}, ErrLookupFailed
+}
+
+// LookupRecord mock
+func (m *mockAddrBook) LookupRecord(pubkey p2pcrypto.PublicKey) (*node.Record, error) {
+	if m.LookupRecordFunc != nil {
+		return m.LookupRecordFunc(pubkey)
+	}
+	return nil, ErrLookupFailed
+}
+
 // Lookup mock
 func (m *mockAddrBook) Lookup(pubkey p2pcrypto.PublicKey) (*node.Info, error) {
 	if m.LookupFunc != nil {
@@ -244,6 +325,14 @@ func (m *mockAddrBook) GetAddress() *KnownAddress {
 	return m.GetAddressRes
 }
 
+// SelectAddresses mock
+func (m *mockAddrBook) SelectAddresses(n int, bias AddressBias) []*KnownAddress {
+	if m.SelectAddressesFunc != nil {
+		return m.SelectAddressesFunc(n, bias)
+	}
+	return nil
+}
+
 // NumAddresses mock
 func (m *mockAddrBook) NumAddresses() int {
 	//todo: mockAddrBook sizem
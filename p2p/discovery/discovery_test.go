@@ -9,6 +9,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"net"
 	"strconv"
 	"testing"
 	"time"
@@ -236,8 +237,17 @@ func Test_findNodeFailure(t *testing.T) {
 		realnode := sim.NewNodeFrom(bsinfo)
 		d := New(bsnode, config.DefaultConfig().SwarmConfig, realnode, "", log.NewDefault(t.Name()))
 		<-time.After(time.Second)
-		nd, _ := simNodeWithDHT(t, config.DefaultConfig().SwarmConfig, sim)
-		d.rt.AddAddress(nd.Info, bsinfo)
+		ndln, ndinfo := node.GenerateTestNode(t)
+		New(ndln, config.DefaultConfig().SwarmConfig, sim.NewNodeFrom(ndinfo), "", log.NewDefault(t.Name()))
+		// only addresses with a signed record of their ip are shared
+		signer, err := ndln.Signer()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		info := *ndinfo
+		info.IP = net.IPv4(127, 0, 0, 1)
+		d.rt.AddRecord(node.NewRecord(info, 1, signer), bsinfo)
 	}()
 
 	err := dht2.Bootstrap(context.TODO())
@@ -269,7 +279,7 @@ func Test_Refresh(t *testing.T) {
 	disc.rt = rt
 	disc.bootstrapper = refresher
 
-	prz := disc.SelectPeers(context.TODO(), 10)
+	prz := disc.SelectPeers(context.TODO(), 10, AddressBias{})
 	require.Len(t, prz, 0)
 	require.Equal(t, requsted, 10)
 
@@ -279,7 +289,7 @@ func Test_Refresh(t *testing.T) {
 		return false
 	}
 
-	prz = disc.SelectPeers(context.TODO(), 10)
+	prz = disc.SelectPeers(context.TODO(), 10, AddressBias{})
 	require.Len(t, prz, 0)
 	require.Equal(t, requsted, 0)
 }
@@ -0,0 +1,212 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/node"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/p2p/server"
+)
+
+const (
+	// lookupAlpha is the number of find node queries a lookup keeps in flight.
+	lookupAlpha = 3
+	// lookupK is the number of closest nodes a lookup keeps querying, and the max number of nodes in a find node response.
+	lookupK = 20
+	// lookupTimeout bounds the time of a whole lookup, each query is bounded by MessageTimeout.
+	lookupTimeout = 30 * time.Second
+)
+
+// closer returns true if a is closer than b to target, by xor distance.
+func closer(target, a, b [32]byte) bool {
+	var da, db [32]byte
+	for i := range target {
+		da[i] = a[i] ^ target[i]
+		db[i] = b[i] ^ target[i]
+	}
+	return bytes.Compare(da[:], db[:]) < 0
+}
+
+// closestNodes returns the (up to) k distinct nodes in nodes closest to target, from closest to farthest.
+func closestNodes(target [32]byte, nodes []*node.Info, k int) []*node.Info {
+	seen := make(map[[32]byte]struct{}, len(nodes))
+	out := make([]*node.Info, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := seen[n.ID]; ok {
+			continue
+		}
+		seen[n.ID] = struct{}{}
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool { return closer(target, out[i].ID, out[j].ID) })
+	if len(out) > k {
+		out = out[:k]
+	}
+	return out
+}
+
+// closestRecords returns the (up to) k distinct records in recs closest to target, from closest to farthest.
+func closestRecords(target [32]byte, recs []*node.Record, k int) []*node.Record {
+	infos := make([]*node.Info, len(recs))
+	byInfo := make(map[*node.Info]*node.Record, len(recs))
+	for i, rec := range recs {
+		infos[i] = &rec.Info
+		byInfo[infos[i]] = rec
+	}
+	closest := closestNodes(target, infos, k)
+	out := make([]*node.Record, len(closest))
+	for i, n := range closest {
+		out[i] = byInfo[n]
+	}
+	return out
+}
+
+func (p *protocol) newFindNodeRequestHandler() func(msg server.Message) []byte {
+	return func(msg server.Message) []byte {
+		plogger := p.logger.WithFields(log.String("type", "findnode"), log.String("from", msg.Sender().String()))
+		plogger.Debug("got request")
+
+		target, err := p2pcrypto.NewPubkeyFromBytes(msg.Bytes())
+		if err != nil {
+			plogger.Warning("got unreadable find node target err=%v", err)
+			return nil
+		}
+
+		var results []*node.Record
+		if rec, err := p.table.LookupRecord(target); err == nil && rec != nil {
+			results = []*node.Record{rec}
+		} else {
+			candidates := p.table.RecordCache()
+			for i, rec := range candidates {
+				if rec.PublicKey() == msg.Sender() {
+					candidates = append(candidates[:i], candidates[i+1:]...)
+					break
+				}
+			}
+			results = closestRecords(target.Array(), candidates, lookupK)
+		}
+
+		resp, err := types.InterfaceToBytes(results)
+		if err != nil {
+			plogger.Error("Error marshaling response message (FindNode) %v", err)
+			return nil
+		}
+
+		plogger.With().Debug("Sending response", log.Int("size", len(results)))
+		return resp
+	}
+}
+
+// FindNode asks server for the records of the nodes it knows that are closest to target. It blocks until the results
+// are returned. Records that fail verification are dropped from the results.
+func (p *protocol) FindNode(server p2pcrypto.PublicKey, target p2pcrypto.PublicKey) ([]*node.Record, error) {
+	plogger := p.logger.WithFields(log.String("type", "findnode"), log.String("to", server.String()))
+	plogger.Debug("sending request")
+
+	ch := make(chan []*node.Record)
+	resHandler := func(msg []byte) {
+		defer close(ch)
+		nodes := make([]*node.Record, 0, lookupK)
+		if err := types.BytesToInterface(msg, &nodes); err != nil {
+			plogger.Warning("could not deserialize bytes to Info, skipping packet err=", err)
+			return
+		}
+		if len(nodes) > lookupK {
+			plogger.Warning("find node response from %v is too large, ignoring. got: %v, expected: <= %v", server.String(), len(nodes), lookupK)
+			return
+		}
+		ch <- verifiedRecords(nodes, plogger)
+	}
+
+	if err := p.msgServer.SendRequest(FindNode, target.Bytes(), server, resHandler); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(MessageTimeout)
+	defer timeout.Stop()
+	select {
+	case nodes := <-ch:
+		if nodes == nil {
+			return nil, errors.New("empty result set")
+		}
+		return nodes, nil
+	case <-timeout.C:
+		return nil, errors.New("request timed out")
+	}
+}
+
+// findNode looks the target up in the network with a kademlia style iterative lookup. it keeps lookupAlpha find node
+// queries in flight to the lookupK nodes closest to the target that it knows about, and learns about closer nodes from
+// the responses. the lookup ends when the target is found, when all of the lookupK closest nodes were queried or when
+// ctx is done.
+func (d *Discovery) findNode(ctx context.Context, target p2pcrypto.PublicKey) (*node.Info, error) {
+	tid := target.Array()
+	shortlist := closestNodes(tid, d.rt.AddressCache(), lookupK)
+	if len(shortlist) == 0 {
+		return nil, ErrEmptyRoutingTable
+	}
+
+	queried := make(map[[32]byte]struct{})
+	results := make(chan queryResult, lookupAlpha)
+	pending := 0
+	for {
+		for _, n := range shortlist {
+			if pending >= lookupAlpha {
+				break
+			}
+			if _, ok := queried[n.ID]; ok {
+				continue
+			}
+			queried[n.ID] = struct{}{}
+			pending++
+			go func(n *node.Info) {
+				res, err := d.disc.FindNode(n.PublicKey(), target)
+				results <- queryResult{src: n, res: res, err: err}
+			}(n)
+		}
+
+		if pending == 0 {
+			return nil, ErrLookupFailed
+		}
+
+		select {
+		case qr := <-results:
+			pending--
+			if qr.err != nil {
+				d.logger.With().Debug("find node query failed", log.String("to", qr.src.String()), log.Err(qr.err))
+				continue
+			}
+			var learned []*node.Record
+			for _, rec := range qr.res {
+				if rec.ID == tid {
+					d.rt.AddRecord(rec, qr.src)
+					// return the address book's record, a stale one in the response doesn't replace a newer one
+					if n, err := d.rt.Lookup(target); err == nil {
+						return n, nil
+					}
+					return &rec.Info, nil
+				}
+				if d.rt.IsLocalAddress(&rec.Info) {
+					continue
+				}
+				learned = append(learned, rec)
+			}
+			d.rt.AddRecords(learned, qr.src)
+			for _, rec := range learned {
+				// queries are sent to nodes found in the address book, so that they don't trigger lookups themselves
+				if n, err := d.rt.Lookup(rec.PublicKey()); err == nil {
+					shortlist = append(shortlist, n)
+				}
+			}
+			shortlist = closestNodes(tid, shortlist, lookupK)
+		case <-ctx.Done():
+			return nil, ErrLookupFailed
+		}
+	}
+}
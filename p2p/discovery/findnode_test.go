@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/config"
+	"github.com/spacemeshos/go-spacemesh/p2p/node"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClosestNodes(t *testing.T) {
+	r := require.New(t)
+	target := [32]byte{0x0f}
+	far := &node.Info{ID: [32]byte{0xf0}}
+	near := &node.Info{ID: [32]byte{0x0e}}
+	nearest := &node.Info{ID: [32]byte{0x0f, 1}}
+
+	r.Equal([]*node.Info{nearest, near, far}, closestNodes(target, []*node.Info{far, near, nearest, near}, 5))
+	r.Equal([]*node.Info{nearest, near}, closestNodes(target, []*node.Info{far, near, nearest}, 2))
+	r.Empty(closestNodes(target, nil, 2))
+}
+
+func TestFindNodeProtocol_FindNodeClosest(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	n1 := newTestNode(sim)
+	n2 := newTestNode(sim)
+
+	gen := generateDiscRecords(2 * lookupK)
+	n2.d.RecordCacheFunc = func() []*node.Record {
+		return gen
+	}
+	target := p2pcrypto.NewRandomPubkey()
+
+	res, err := n1.dscv.FindNode(n2.svc.Info.PublicKey(), target)
+	r.NoError(err)
+	r.Equal(closestRecords(target.Array(), gen, lookupK), res)
+
+	// a known target is returned alone
+	n2.d.LookupRecordFunc = func(key p2pcrypto.PublicKey) (*node.Record, error) {
+		r.Equal(target, key)
+		return gen[0], nil
+	}
+	res, err = n1.dscv.FindNode(n2.svc.Info.PublicKey(), target)
+	r.NoError(err)
+	r.Equal([]*node.Record{gen[0]}, res)
+
+	// records that don't verify are dropped
+	spoofed := *gen[0]
+	spoofed.IP = net.ParseIP("1.2.3.4")
+	n2.d.LookupRecordFunc = func(key p2pcrypto.PublicKey) (*node.Record, error) {
+		return &spoofed, nil
+	}
+	res, err = n1.dscv.FindNode(n2.svc.Info.PublicKey(), target)
+	r.NoError(err)
+	r.Empty(res)
+}
+
+func TestDiscovery_LookupIterative(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	cfg := config.DefaultConfig().SwarmConfig
+
+	// a knows b, b knows c and c knows the target
+	_, da := simNodeWithDHT(t, cfg, sim)
+	b, db := simNodeWithDHT(t, cfg, sim)
+	c, dc := simNodeWithDHT(t, cfg, sim)
+	target, dt := simNodeWithDHT(t, cfg, sim)
+	// only signed records are relayed, and records must have an ip
+	record := func(n *service.Node, d *Discovery) *node.Record {
+		signer, err := d.local.Signer()
+		r.NoError(err)
+		info := *n.Info
+		info.IP = net.IPv4(127, 0, 0, 1)
+		return node.NewRecord(info, 1, signer)
+	}
+	da.rt.AddAddress(b.Info, b.Info)
+	db.rt.AddRecord(record(c, dc), c.Info)
+	dc.rt.AddRecord(record(target, dt), target.Info)
+
+	found, err := da.Lookup(target.PublicKey())
+	r.NoError(err)
+	r.Equal(target.Info.PublicKey(), found.PublicKey())
+
+	// the target and the nodes on the way are now in the address book
+	_, err = da.rt.Lookup(target.PublicKey())
+	r.NoError(err)
+	_, err = da.rt.Lookup(c.PublicKey())
+	r.NoError(err)
+
+	_, err = da.Lookup(p2pcrypto.NewRandomPubkey())
+	r.Equal(ErrLookupFailed, err)
+}
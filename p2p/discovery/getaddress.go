@@ -12,6 +12,19 @@ import (
 
 // todo : calculate real udp max message size
 
+// verifiedRecords returns the records in recs that pass verification, in order.
+func verifiedRecords(recs []*node.Record, logger log.Log) []*node.Record {
+	out := make([]*node.Record, 0, len(recs))
+	for _, rec := range recs {
+		if err := rec.Verify(); err != nil {
+			logger.With().Debug("dropping invalid record", log.String("node", rec.String()), log.Err(err))
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
 func (p *protocol) newGetAddressesRequestHandler() func(msg server.Message) []byte {
 	return func(msg server.Message) []byte {
 		t := time.Now()
@@ -21,10 +34,10 @@ func (p *protocol) newGetAddressesRequestHandler() func(msg server.Message) []by
 		// TODO: if we don't know who is that peer (a.k.a first time we hear from this address)
 		// 		 we must ensure that he's indeed listening on that address = check last pong
 
-		results := p.table.AddressCache()
+		results := p.table.RecordCache()
 		// remove the sender from the list
-		for i, addr := range results {
-			if addr.PublicKey() == msg.Sender() {
+		for i, rec := range results {
+			if rec.PublicKey() == msg.Sender() {
 				results[i] = results[len(results)-1]
 				results = results[:len(results)-1]
 				break
@@ -46,7 +59,8 @@ func (p *protocol) newGetAddressesRequestHandler() func(msg server.Message) []by
 }
 
 // GetAddresses Send a get address request to a remote node, it will block and return the results returned from the node.
-func (p *protocol) GetAddresses(server p2pcrypto.PublicKey) ([]*node.Info, error) {
+// Records that fail verification are dropped from the results.
+func (p *protocol) GetAddresses(server p2pcrypto.PublicKey) ([]*node.Record, error) {
 	start := time.Now()
 	var err error
 
@@ -55,10 +69,10 @@ func (p *protocol) GetAddresses(server p2pcrypto.PublicKey) ([]*node.Info, error
 	plogger.Debug("sending request")
 
 	// response handler
-	ch := make(chan []*node.Info)
+	ch := make(chan []*node.Record)
 	resHandler := func(msg []byte) {
 		defer close(ch)
-		nodes := make([]*node.Info, 0, getAddrMax)
+		nodes := make([]*node.Record, 0, getAddrMax)
 		err := types.BytesToInterface(msg, &nodes)
 		//todo: check that we're not pass max results ?
 		if err != nil {
@@ -71,7 +85,7 @@ func (p *protocol) GetAddresses(server p2pcrypto.PublicKey) ([]*node.Info, error
 			return
 		}
 
-		ch <- nodes
+		ch <- verifiedRecords(nodes, plogger)
 	}
 
 	err = p.msgServer.SendRequest(GetAddresses, []byte(""), server, resHandler)
@@ -11,6 +11,7 @@ import (
 type KnownAddress struct {
 	na          *node.Info
 	srcAddr     *node.Info
+	rec         *node.Record // the signed record of na, nil if we haven't got one
 	attempts    int
 	lastSeen    time.Time
 	lastattempt time.Time
@@ -29,6 +30,28 @@ func (ka *KnownAddress) LastAttempt() time.Time {
 	return ka.lastattempt
 }
 
+// AddressStats are the address book's statistics of a known address, by which it judges how reliable the node is.
+type AddressStats struct {
+	Attempts    int // failed connection attempts since the last success
+	LastSeen    time.Time
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Tried       bool    // whether the address is in a tried bucket
+	Chance      float64 // the relative chance of the address to be selected for a connection
+}
+
+// stats returns the statistics of the known address.
+func (ka *KnownAddress) stats() AddressStats {
+	return AddressStats{
+		Attempts:    ka.attempts,
+		LastSeen:    ka.lastSeen,
+		LastAttempt: ka.lastattempt,
+		LastSuccess: ka.lastsuccess,
+		Tried:       ka.tried,
+		Chance:      ka.chance(),
+	}
+}
+
 // chance returns the selection probability for a known address.  The priority
 // depends upon how recently the address has been seen, how recently it was last
 // attempted and how often attempts to connect to it have failed.
@@ -1,7 +1,6 @@
 package discovery
 
 import (
-	"bytes"
 	"errors"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/log"
@@ -11,27 +10,37 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/server"
+	"github.com/spacemeshos/go-spacemesh/timesync"
 )
 
+// pong is the response to a ping, it carries the record of the pinged node and the ip the pinger was seen coming
+// from, so that nodes learn the ip to put in their own records, and the pinged node's time in unix nanoseconds, so
+// that nodes sample the network's time.
+type pong struct {
+	Record   node.Record
+	Observed net.IP
+	Time     int64
+}
+
 func (p *protocol) newPingRequestHandler() func(msg server.Message) []byte {
 	return func(msg server.Message) []byte {
 		plogger := p.logger.WithFields(log.String("type", "ping"), log.String("from", msg.Sender().String()))
 		plogger.Debug("handle request")
-		pinged := &node.Info{}
-		err := types.BytesToInterface(msg.Bytes(), pinged)
+		pinger := &node.Record{}
+		err := types.BytesToInterface(msg.Bytes(), pinger)
 		if err != nil {
 			plogger.Error("failed to deserialize ping message err=", err)
 			return nil
 		}
 
-		if err := p.verifyPinger(msg.Metadata().FromAddress, pinged); err != nil {
+		observed, err := p.verifyPinger(msg.Metadata().FromAddress, pinger)
+		if err != nil {
 			plogger.Error("msg contents were not valid err=", err)
 			return nil
 		}
 
 		//pong
-		payload, err := types.InterfaceToBytes(p.local)
-		// TODO: include the resolved To address
+		payload, err := types.InterfaceToBytes(&pong{Record: *p.localRecord(), Observed: observed, Time: time.Now().UnixNano()})
 		if err != nil {
 			plogger.Error("Error marshaling response message (Ping)")
 			return nil
@@ -42,22 +51,28 @@ func (p *protocol) newPingRequestHandler() func(msg server.Message) []byte {
 	}
 }
 
-func (p *protocol) verifyPinger(from net.Addr, pi *node.Info) error {
-	// todo : Validate ToAddr or drop it.
+// verifyPinger adds the pinger to the routing table and returns the ip it was seen coming from. its record is kept
+// only if it's signed for the ip it came from, otherwise the pinger is added by its observed ip without a record.
+func (p *protocol) verifyPinger(from net.Addr, rec *node.Record) (net.IP, error) {
 	// todo: check the address provided with an extra ping before updating. ( if we haven't checked it for a while )
-	// todo: decide on best way to know our ext address
 
-	if err := pi.Valid(); err != nil {
-		return err
+	if err := rec.Valid(); err != nil {
+		return nil, err
 	}
 
 	//TODO: only accept local (unspecified/loopback) IPs from other local ips.
 	ipfrom, _, _ := net.SplitHostPort(from.String())
-	pi.IP = net.ParseIP(ipfrom)
+	observed := net.ParseIP(ipfrom)
 
 	// inbound ping is the actual source of this node info
-	p.table.AddAddress(pi, pi)
-	return nil
+	if rec.IP.Equal(observed) && rec.Verify() == nil {
+		p.table.AddRecord(rec, &rec.Info)
+		return observed, nil
+	}
+	pi := rec.Info
+	pi.IP = observed
+	p.table.AddAddress(&pi, &pi)
+	return observed, nil
 }
 
 // Ping notifies `peer` about our p2p identity.
@@ -66,28 +81,26 @@ func (p *protocol) Ping(peer p2pcrypto.PublicKey) error {
 
 	plogger.Debug("send request")
 
-	data, err := types.InterfaceToBytes(p.local)
+	data, err := types.InterfaceToBytes(p.localRecord())
 	if err != nil {
 		return err
 	}
-	ch := make(chan []byte)
+	ch := make(chan *pong)
 	foo := func(msg []byte) {
 		defer close(ch)
 		plogger.Debug("handle response")
-		sender := &node.Info{}
-		err := types.BytesToInterface(msg, sender)
+		res := &pong{}
+		err := types.BytesToInterface(msg, res)
 
 		if err != nil {
 			plogger.Warning("got unreadable pong. err=%v", err)
 			return
 		}
 
-		// todo: if we pinged it we already have id so no need to update
-		// todo : but what if id or listen address has changed ?
-
-		ch <- sender.ID.Bytes()
+		ch <- res
 	}
 
+	sent := time.Now()
 	err = p.msgServer.SendRequest(PingPong, data, peer, foo)
 
 	if err != nil {
@@ -96,13 +109,26 @@ func (p *protocol) Ping(peer p2pcrypto.PublicKey) error {
 
 	timeout := time.NewTimer(MessageTimeout) // todo: check whether this is useless because of `requestLifetime`
 	select {
-	case id := <-ch:
-		if id == nil {
+	case res := <-ch:
+		if res == nil {
 			return errors.New("failed sending message")
 		}
-		if !bytes.Equal(id, peer.Bytes()) {
+		if res.Record.ID != peer.Array() {
 			return errors.New("got pong with different public key")
 		}
+		learned := p.learnIP(res.Observed)
+		if res.Time != 0 {
+			p.drifts.Add(peer.String(), timesync.PeerDrift(sent, time.Now(), time.Unix(0, res.Time)))
+		}
+		// the peer signed its record itself, but nodes that don't know their ip yet can't sign a useful one
+		if err := res.Record.Verify(); err == nil {
+			p.table.AddRecord(&res.Record, &res.Record.Info)
+		}
+		if learned {
+			// the peer got our record without an ip, so it kept our address but not the record, and can't share us
+			// with others. ping it again with the record of the ip it saw us coming from
+			return p.Ping(peer)
+		}
 	case <-timeout.C:
 		return errors.New("ping timeouted")
 	}
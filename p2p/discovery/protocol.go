@@ -6,7 +6,10 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/server"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/timesync"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -14,19 +17,63 @@ type protocolRoutingTable interface {
 	GetAddress() *KnownAddress
 	AddAddresses(n []*node.Info, src *node.Info)
 	AddAddress(n *node.Info, src *node.Info)
+	AddRecord(rec *node.Record, src *node.Info)
 	AddressCache() []*node.Info
+	RecordCache() []*node.Record
+	Lookup(key p2pcrypto.PublicKey) (*node.Info, error)
+	LookupRecord(key p2pcrypto.PublicKey) (*node.Record, error)
 }
 
 type protocol struct {
-	local     *node.Info
+	localMtx sync.RWMutex
+	local    *node.Record
+	signer   *signing.EdSigner
+	drifts   *timesync.PeerDrifts // drifts of our clock from the peers' clocks, sampled by pings
+
 	table     protocolRoutingTable
 	logger    log.Log
 	msgServer *server.MessageServer
 }
 
+// localRecord returns the current record of the local node.
+func (p *protocol) localRecord() *node.Record {
+	p.localMtx.RLock()
+	defer p.localMtx.RUnlock()
+	return p.local
+}
+
+// updateLocal signs a new record of the local node with update applied to a copy of its info. records are
+// immutable once signed, the sequence number is the signing time so that it increases across restarts.
+func (p *protocol) updateLocal(update func(info *node.Info)) {
+	p.localMtx.Lock()
+	defer p.localMtx.Unlock()
+	info := p.local.Info
+	update(&info)
+	seq := uint64(time.Now().Unix())
+	if seq <= p.local.Seq {
+		seq = p.local.Seq + 1
+	}
+	p.local = node.NewRecord(info, seq, p.signer)
+}
+
 func (p *protocol) SetLocalAddresses(tcp, udp int) {
-	p.local.ProtocolPort = uint16(tcp)
-	p.local.DiscoveryPort = uint16(udp)
+	p.updateLocal(func(info *node.Info) {
+		info.ProtocolPort = uint16(tcp)
+		info.DiscoveryPort = uint16(udp)
+	})
+}
+
+// learnIP sets the ip of the local record to the ip a peer saw us coming from, if we don't know our ip yet. It returns
+// true if the ip was set.
+// todo: require several peers to agree before changing a known ip.
+func (p *protocol) learnIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || !p.localRecord().IP.IsUnspecified() {
+		return false
+	}
+	p.updateLocal(func(info *node.Info) {
+		info.IP = ip
+	})
+	return true
 }
 
 // Name is the name if the protocol.
@@ -44,11 +91,20 @@ const PingPong = 0
 // GetAddresses is the findnode protocol ID
 const GetAddresses = 1
 
-// newProtocol is a constructor for a protocol protocol provider.
-func newProtocol(local p2pcrypto.PublicKey, rt protocolRoutingTable, svc server.Service, log log.Log) *protocol {
+// FindNode is the protocol ID of requests for the nodes closest to a target
+const FindNode = 2
+
+// driftSamples is the number of peers whose clock drifts are kept to estimate the network's time
+const driftSamples = 100
+
+// newProtocol is a constructor for a protocol protocol provider. signer signs the records of the local node.
+func newProtocol(local p2pcrypto.PublicKey, signer *signing.EdSigner, rt protocolRoutingTable, svc server.Service, log log.Log) *protocol {
 	s := server.NewMsgServer(svc, Name, MessageTimeout, make(chan service.DirectMessage, MessageBufSize), log)
+	info := node.Info{ID: local.Array(), IP: net.IPv4zero, ProtocolPort: 7513, DiscoveryPort: 7513}
 	d := &protocol{
-		local:     &node.Info{ID: local.Array(), IP: net.IPv4zero, ProtocolPort: 7513, DiscoveryPort: 7513},
+		local:     node.NewRecord(info, uint64(time.Now().Unix()), signer),
+		signer:    signer,
+		drifts:    timesync.NewPeerDrifts(driftSamples),
 		table:     rt,
 		msgServer: s,
 		logger:    log,
@@ -58,6 +114,7 @@ func newProtocol(local p2pcrypto.PublicKey, rt protocolRoutingTable, svc server.
 
 	d.msgServer.RegisterMsgHandler(PingPong, d.newPingRequestHandler())
 	d.msgServer.RegisterMsgHandler(GetAddresses, d.newGetAddressesRequestHandler())
+	d.msgServer.RegisterMsgHandler(FindNode, d.newFindNodeRequestHandler())
 	return d
 }
 
@@ -5,7 +5,9 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
 	"github.com/stretchr/testify/require"
+	"net"
 	"testing"
 )
 
@@ -19,6 +21,24 @@ func generateDiscNodes(n int) []*node.Info {
 	return node.GenerateRandomNodesData(n)
 }
 
+// generateDiscRecords returns signed records of n random nodes.
+func generateDiscRecords(n int) []*node.Record {
+	signer := signing.NewEdSigner()
+	recs := make([]*node.Record, n)
+	for i, info := range generateDiscNodes(n) {
+		recs[i] = node.NewRecord(*info, 1, signer)
+	}
+	return recs
+}
+
+func recordInfos(recs []*node.Record) []*node.Info {
+	infos := make([]*node.Info, len(recs))
+	for i, rec := range recs {
+		infos[i] = &rec.Info
+	}
+	return infos
+}
+
 func GetTestLogger(name string) log.Log {
 	return log.New(name, "", "")
 }
@@ -32,7 +52,7 @@ type testNode struct {
 func newTestNode(simulator *service.Simulator) *testNode {
 	nd := simulator.NewNode()
 	d := &mockAddrBook{}
-	disc := newProtocol(nd.Info.PublicKey(), d, nd, log.New(nd.String(), "", ""))
+	disc := newProtocol(nd.Info.PublicKey(), signing.NewEdSigner(), d, nd, log.New(nd.String(), "", ""))
 	return &testNode{nd, d, disc}
 }
 
@@ -97,6 +117,38 @@ func TestPing_Ping_Concurrency(t *testing.T) {
 	<-done
 }
 
+func TestPing_Records(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	p1 := newTestNode(sim)
+	p2 := newTestNode(sim)
+
+	var added *node.Record
+	p2.d.AddRecordFunc = func(rec *node.Record, src *node.Info) {
+		added = rec
+	}
+
+	// the pinger learns its ip from the pong, it can't sign a useful record before. once it does, it pings again so
+	// that the peer gets its record
+	r.True(p1.dscv.localRecord().IP.IsUnspecified())
+	r.NoError(p1.dscv.Ping(p2.svc.PublicKey()))
+	r.True(p1.dscv.localRecord().IP.Equal(net.IPv4(127, 0, 0, 1)))
+	r.NotNil(added)
+	r.NoError(added.Verify())
+	r.Equal(p1.svc.Info.ID, added.ID)
+
+	added = nil
+	r.NoError(p1.dscv.Ping(p2.svc.PublicKey()))
+	r.NotNil(added)
+	r.Equal(p1.dscv.localRecord(), added)
+
+	// changing the local addresses signs a newer record
+	prev := p1.dscv.localRecord()
+	p1.dscv.SetLocalAddresses(1000, 1001)
+	r.True(p1.dscv.localRecord().Supersedes(prev))
+	r.NoError(p1.dscv.localRecord().Verify())
+}
+
 // todo : test verifypinger
 
 func TestFindNodeProtocol_FindNode(t *testing.T) {
@@ -110,7 +162,7 @@ func TestFindNodeProtocol_FindNode(t *testing.T) {
 	require.NoError(t, err, "Should not return error")
 	// when routing table is empty we get an empty result
 	// todo: maybe this should error ?
-	require.Equal(t, []*node.Info{}, idarr, "Should be an empty array")
+	require.Equal(t, []*node.Record{}, idarr, "Should be an empty array")
 }
 
 //
@@ -121,9 +173,9 @@ func TestFindNodeProtocol_FindNode2(t *testing.T) {
 	n1 := newTestNode(sim)
 	n2 := newTestNode(sim)
 
-	gen := generateDiscNodes(100)
+	gen := generateDiscRecords(100)
 
-	n2.d.AddressCacheFunc = func() []*node.Info {
+	n2.d.RecordCacheFunc = func() []*node.Record {
 		return gen
 	}
 
@@ -134,9 +186,9 @@ func TestFindNodeProtocol_FindNode2(t *testing.T) {
 	require.NoError(t, err, "Should not return error")
 	require.Equal(t, gen, idarr, "Should be array that contains the node")
 	//
-	gen = append(gen, generateDiscNodes(100)...)
+	gen = append(gen, generateDiscRecords(100)...)
 
-	n2.d.AddressCacheFunc = func() []*node.Info {
+	n2.d.RecordCacheFunc = func() []*node.Record {
 		return gen
 	}
 
@@ -154,13 +206,13 @@ func TestFindNodeProtocol_FindNode_Concurrency(t *testing.T) {
 
 	sim := service.NewSimulator()
 	n1 := newTestNode(sim)
-	gen := generateDiscNodes(100)
-	n1.d.AddressCacheFunc = func() []*node.Info {
+	gen := generateDiscRecords(100)
+	n1.d.RecordCacheFunc = func() []*node.Record {
 		return gen
 	}
 	n1.dscv.table = n1.d
 
-	retchans := make(chan []*node.Info)
+	retchans := make(chan []*node.Record)
 
 	for i := 0; i < concurrency; i++ {
 		go func() {
@@ -32,7 +32,12 @@ var ErrBootAbort = errors.New("bootstrap canceled by signal")
 
 type pingerGetAddresser interface {
 	Ping(p p2pcrypto.PublicKey) error
-	GetAddresses(server p2pcrypto.PublicKey) ([]*node.Info, error)
+	GetAddresses(server p2pcrypto.PublicKey) ([]*node.Record, error)
+}
+
+// peerDirectory returns the records of nodes registered in a peer directory.
+type peerDirectory interface {
+	records(ctx context.Context) ([]*node.Record, error)
 }
 
 // refresher is used to bootstrap and requestAddresses peers in the addrbook
@@ -42,6 +47,7 @@ type refresher struct {
 
 	book      addressBook
 	bootNodes []*node.Info
+	directory peerDirectory // optional, queried for more bootstrap nodes
 
 	backoffFunc func(tries int) time.Duration
 
@@ -63,7 +69,8 @@ func newRefresher(local p2pcrypto.PublicKey, book addressBook, disc pingerGetAdd
 }
 
 // Bootstrap tries to collect `numpeers` new peers into the routing table. it stops if ctx is cancelled,
-// otherwise it will keep trying. if the routing table is empty, bootnodes are loaded from provided config.
+// otherwise it will keep trying. if the routing table is empty, bootnodes are loaded from provided config and from the
+// peer directory, if there's one.
 func (r *refresher) Bootstrap(ctx context.Context, numpeers int) error {
 	var err error
 	var servers []*node.Info
@@ -83,6 +90,15 @@ func (r *refresher) Bootstrap(ctx context.Context, numpeers int) error {
 				r.book.RemoveAddress(b.PublicKey())
 			}
 		}()
+		if r.directory != nil {
+			// directory nodes are signed records of their full addresses, so they stay in the book
+			recs, err := r.directory.records(ctx)
+			if err != nil {
+				r.logger.Warning("Bootstrap: failed to query the peer directory: %v", err)
+			}
+			r.book.AddRecords(recs, r.localAddress)
+			size += len(recs)
+		}
 	}
 
 	r.logger.Info("Bootstrap: starting with %v sized table", size)
@@ -144,7 +160,7 @@ func expire(m map[p2pcrypto.PublicKey]time.Time) {
 
 type queryResult struct {
 	src *node.Info
-	res []*node.Info
+	res []*node.Record
 	err error
 }
 
@@ -225,8 +241,8 @@ func (r *refresher) requestAddresses(ctx context.Context, servers []*node.Info)
 					if _, ok := r.lastQueries[a.PublicKey()]; ok {
 						continue
 					}
-					out = append(out, a)
-					r.book.AddAddress(a, cr.src)
+					out = append(out, &a.Info)
+					r.book.AddRecord(a, cr.src)
 					seen[a.PublicKey()] = struct{}{}
 				}
 			}
@@ -13,7 +13,7 @@ import (
 
 type mockDisc struct {
 	pingres     error
-	findnoderes []*node.Info
+	findnoderes []*node.Record
 	findnoderr  error
 }
 
@@ -21,7 +21,7 @@ func (md *mockDisc) Ping(key p2pcrypto.PublicKey) error {
 	return md.pingres
 }
 
-func (md *mockDisc) GetAddresses(key p2pcrypto.PublicKey) ([]*node.Info, error) {
+func (md *mockDisc) GetAddresses(key p2pcrypto.PublicKey) ([]*node.Record, error) {
 	return md.findnoderes, md.findnoderr
 }
 
@@ -83,7 +83,7 @@ func Test_pingThenFindNode(t *testing.T) {
 	require.Equal(t, res.err, findnodeErr)
 
 	p.findnoderr = nil
-	p.findnoderes = []*node.Info{generateDiscNode()}
+	p.findnoderes = generateDiscRecords(1)
 
 	pingThenGetAddresses(p, n, c)
 
@@ -104,14 +104,14 @@ func TestRefresher_refresh(t *testing.T) {
 
 	addrbk.AddAddresses([]*node.Info{boot}, local)
 
-	some := generateDiscNodes(10)
+	some := generateDiscRecords(10)
 	disc.pingres = nil
 	disc.findnoderr = nil
 	disc.findnoderes = some
 
 	res := ref.requestAddresses(context.TODO(), []*node.Info{boot})
 
-	require.Equal(t, some, res)
+	require.Equal(t, recordInfos(some), res)
 
 	for _, s := range some {
 		d, err := addrbk.Lookup(s.PublicKey())
@@ -131,7 +131,7 @@ func TestRefresher_refresh2(t *testing.T) {
 
 	addrbk.AddAddresses(boot, local)
 
-	some := generateDiscNodes(10)
+	some := generateDiscRecords(10)
 	disc.pingres = errors.New("ping")
 	disc.findnoderr = nil
 	disc.findnoderes = some
@@ -159,19 +159,19 @@ func TestRefresher_refresh3(t *testing.T) {
 
 	addrbk.AddAddresses(boot, local)
 
-	some := generateDiscNodes(10)
+	some := generateDiscRecords(10)
 	disc.pingres = nil
 	disc.findnoderr = nil
 	disc.findnoderes = some
 
 	res := ref.requestAddresses(context.TODO(), boot)
 
-	require.Equal(t, res, some)
+	require.Equal(t, res, recordInfos(some))
 
 	for _, s := range some {
 		d, err := addrbk.Lookup(s.PublicKey())
 		require.NoError(t, err)
-		require.Equal(t, d, s)
+		require.Equal(t, d, &s.Info)
 	}
 }
 
@@ -191,7 +191,7 @@ func TestRefresher_Bootstrap(t *testing.T) {
 
 	disc.pingres = nil
 	disc.findnoderr = nil
-	disc.findnoderes = generateDiscNodes(10)
+	disc.findnoderes = generateDiscRecords(10)
 
 	err := ref.Bootstrap(context.TODO(), 10)
 
@@ -214,7 +214,7 @@ func TestRefresher_BootstrapAbort(t *testing.T) {
 
 	disc.pingres = nil
 	disc.findnoderr = nil
-	disc.findnoderes = generateDiscNodes(2)
+	disc.findnoderes = generateDiscRecords(2)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	done := make(chan struct{})
@@ -3,6 +3,7 @@ package discovery
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
 	"os"
@@ -19,6 +20,7 @@ type serializedKnownAddress struct {
 	LastSeen    int64
 	LastAttempt int64
 	LastSuccess int64
+	Record      []byte `json:",omitempty"` // the serialized signed record of Addr
 	// no refcount or tried, that is available from context.
 }
 
@@ -41,6 +43,7 @@ func (a *addrBook) savePeers(path string) {
 	copy(sam.Key[:], a.key[:])
 
 	sam.Addresses = make([]*serializedKnownAddress, len(a.addrIndex))
+	var err error
 	i := 0
 	for _, v := range a.addrIndex {
 		ska := new(serializedKnownAddress)
@@ -50,6 +53,11 @@ func (a *addrBook) savePeers(path string) {
 		ska.Attempts = v.attempts
 		ska.LastAttempt = v.lastattempt.Unix()
 		ska.LastSuccess = v.lastsuccess.Unix()
+		if v.rec != nil {
+			if ska.Record, err = types.InterfaceToBytes(v.rec); err != nil {
+				a.logger.Warning("failed to serialize the record of %v: %v", ska.Addr, err)
+			}
+		}
 		// Tried and refs are implicit in the rest of the structure
 		// and will be worked out from context on unserialisation.
 		sam.Addresses[i] = ska
@@ -144,6 +152,16 @@ func (a *addrBook) deserializePeers(filePath string) error {
 				"%s: %v", v.Src, err)
 		}
 
+		if v.Record != nil {
+			// a record that doesn't verify is dropped, the address stays without a record.
+			rec := &node.Record{}
+			if err := types.BytesToInterface(v.Record, rec); err != nil || rec.ID != ka.na.ID || rec.Verify() != nil {
+				a.logger.Warning("dropping invalid record of %v from peers file", v.Addr)
+			} else {
+				ka.rec = rec
+			}
+		}
+
 		ka.attempts = v.Attempts
 		ka.lastattempt = time.Unix(v.LastAttempt, 0)
 		ka.lastsuccess = time.Unix(v.LastSuccess, 0)
@@ -2,7 +2,11 @@
 package gossip
 
 import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
@@ -13,6 +17,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/peers"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/signing"
 )
 
 const oldMessageCacheSize = 10000
@@ -27,12 +32,50 @@ type peersManager interface {
 type baseNetwork interface {
 	SendMessage(peerPubkey p2pcrypto.PublicKey, protocol string, payload []byte) error
 	SubscribePeerEvents() (conn chan p2pcrypto.PublicKey, disc chan p2pcrypto.PublicKey)
-	ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protocol string, data service.Data, validationCompletedChan chan service.MessageValidation) error
+	ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protocol string, data service.Data, envelope []byte, validationCompletedChan chan service.MessageValidation) error
+}
+
+// ErrBadSignature is returned when relaying a message whose originator signature doesn't verify
+var ErrBadSignature = errors.New("bad gossip message originator signature")
+
+// Envelope is a gossip message as sent between peers. It carries the signature of the node that originated the
+// message over its payload, so that every hop authenticates the originator and not just the peer that relayed it.
+type Envelope struct {
+	Originator []byte // the ed25519 public key of the originating node
+	Timestamp  int64  // the unix time in nanoseconds when the message was originated, used for latency statistics
+	Payload    []byte
+	Signature  []byte
+}
+
+// envelopeDomain prefixes the bytes signed by originators, so that a gossip signature can't be taken for a signature
+// of another kind of object by the same key.
+const envelopeDomain = "spacemesh/gossip/1"
+
+// signedBytes returns the bytes signed by the originator of a message of protocol: the domain, the timestamp, the
+// length-prefixed protocol and the payload. The protocol is length-prefixed so that no two pairs of protocol and payload
+// sign the same bytes.
+func (e *Envelope) signedBytes(protocol string) []byte {
+	b := make([]byte, 0, len(envelopeDomain)+8+4+len(protocol)+len(e.Payload))
+	b = append(b, envelopeDomain...)
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(e.Timestamp))
+	b = append(b, n[:]...)
+	binary.LittleEndian.PutUint32(n[:4], uint32(len(protocol)))
+	b = append(b, n[:4]...)
+	b = append(b, protocol...)
+	return append(b, e.Payload...)
+}
+
+// hash returns the hash by which messages are deduplicated. It only covers the payload, since anyone can sign a payload
+// again with a key of their own, and a copy of a message shouldn't be processed and relayed again for each signer.
+func (e *Envelope) hash(protocol string) types.Hash12 {
+	return types.CalcMessageHash12(e.Payload, protocol)
 }
 
 type prioQ interface {
 	Write(prio priorityq.Priority, m interface{}) error
 	Read() (interface{}, error)
+	Lengths() []int
 	Close()
 }
 
@@ -43,55 +86,199 @@ type Protocol struct {
 	config          config.SwarmConfig
 	net             baseNetwork
 	localNodePubkey p2pcrypto.PublicKey
+	signer          *signing.EdSigner
 
 	peers peersManager
 
 	shutdown chan struct{}
 
 	oldMessageQ *types.DoubleCache
+	seen        *seenLog // nil if seen messages aren't persisted
+	seenPath    string
+	wg          sync.WaitGroup
 
 	propagateQ chan service.MessageValidation
 	pq         prioQ
 	priorities map[string]priorityq.Priority
+
+	stats *stats
+
+	flood         *flood
+	lazy          *lazyPush
+	lazyProtocols map[string]struct{}
 }
 
-// NewProtocol creates a new gossip protocol instance.
-func NewProtocol(config config.SwarmConfig, base baseNetwork, peersManager peersManager, localNodePubkey p2pcrypto.PublicKey, logger log.Log) *Protocol {
+// NewProtocol creates a new gossip protocol instance. Messages broadcast by the node are signed by signer.
+func NewProtocol(config config.SwarmConfig, base baseNetwork, peersManager peersManager, localNodePubkey p2pcrypto.PublicKey, signer *signing.EdSigner, logger log.Log) *Protocol {
+	dedupSize := config.DedupCacheSize
+	if dedupSize <= 0 {
+		dedupSize = oldMessageCacheSize
+	}
 	// intentionally not subscribing to peers events so that the channels won't block in case executing Start delays
-	return &Protocol{
+	p := &Protocol{
 		Log:             logger,
 		config:          config,
 		net:             base,
 		localNodePubkey: localNodePubkey,
+		signer:          signer,
 		peers:           peersManager,
 		shutdown:        make(chan struct{}),
-		oldMessageQ:     types.NewDoubleCache(oldMessageCacheSize), // todo : remember to drain this
+		oldMessageQ:     types.NewDoubleCache(uint(dedupSize)), // todo : remember to drain this
 		propagateQ:      make(chan service.MessageValidation, propagateHandleBufferSize),
 		pq:              priorityq.New(propagateHandleBufferSize),
 		priorities:      make(map[string]priorityq.Priority),
+		stats:           newStats(),
+		lazyProtocols:   make(map[string]struct{}),
 	}
+	if config.DedupPersistWindow > 0 {
+		p.seen = newSeenLog(config.DedupPersistWindow, dedupSize)
+	}
+	p.flood = &flood{p}
+	p.lazy = newLazyPush(p)
+	for _, protocol := range config.LazyPushProtocols {
+		p.lazyProtocols[protocol] = struct{}{}
+	}
+	return p
+}
+
+// PersistSeen saves the hashes of the messages seen in the last DedupPersistWindow in the p2p directory of datadir,
+// and loads them when the protocol starts, so that the messages aren't processed again after a restart. It must be
+// called before Start.
+func (p *Protocol) PersistSeen(datadir string) {
+	p.seenPath = filepath.Join(datadir, config.P2PDirectoryPath, SeenFileName)
 }
 
 // Start a loop that process peers events
 func (p *Protocol) Start() {
+	if p.seen != nil && p.seenPath != "" {
+		p.loadSeen()
+		p.wg.Add(1)
+		go p.saveSeenLoop()
+	}
 	go p.propagationEventLoop() // TODO consider running several consumers
 }
 
 // Close stops all protocol routines.
 func (p *Protocol) Close() {
 	close(p.shutdown)
+	p.wg.Wait()
+}
+
+// loadSeen marks the messages seen before the restart as old.
+func (p *Protocol) loadSeen() {
+	messages, err := p.seen.load(p.seenPath)
+	if err != nil {
+		p.With().Warning("could not load seen gossip messages", log.String("path", p.seenPath), log.Err(err))
+		return
+	}
+	for _, m := range messages {
+		p.oldMessageQ.GetOrInsert(m.Hash)
+	}
+	p.With().Info("loaded seen gossip messages", log.Int("count", len(messages)))
+}
+
+// saveSeenLoop saves the recently seen messages periodically and when the protocol is closed.
+func (p *Protocol) saveSeenLoop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(seenSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.shutdown:
+			if err := p.seen.save(p.seenPath); err != nil {
+				p.With().Warning("could not save seen gossip messages", log.String("path", p.seenPath), log.Err(err))
+			}
+			return
+		}
+		if err := p.seen.save(p.seenPath); err != nil {
+			p.With().Warning("could not save seen gossip messages", log.String("path", p.seenPath), log.Err(err))
+		}
+	}
 }
 
 // Broadcast is the actual broadcast procedure - process the message internally and loop on peers and add the message to their queues
 func (p *Protocol) Broadcast(payload []byte, nextProt string) error {
 	p.Log.Debug("Broadcasting message from type %s", nextProt)
-	return p.processMessage(p.localNodePubkey, nextProt, service.DataBytes{Payload: payload})
+	env := &Envelope{
+		Originator: p.signer.PublicKey().Bytes(),
+		Timestamp:  time.Now().UnixNano(),
+		Payload:    payload,
+	}
+	env.Signature = p.signer.Sign(env.signedBytes(nextProt))
+	bytes, err := types.InterfaceToBytes(env)
+	if err != nil {
+		return err
+	}
+	return p.processMessage(p.localNodePubkey, nextProt, env, bytes)
 	//todo: should this ever return error ? then when processMessage should return error ?. should it block?
 }
 
 // Relay processes a message, if the message is new, it is passed for the protocol to validate and then propagated.
+// An error is returned if the message isn't signed by its originator.
 func (p *Protocol) Relay(sender p2pcrypto.PublicKey, protocol string, msg service.Data) error {
-	return p.processMessage(sender, protocol, msg)
+	env := &Envelope{}
+	if err := types.BytesToInterface(msg.Bytes(), env); err != nil {
+		return err
+	}
+	// a message that was already processed was verified then, so its duplicates are dropped without verifying them. a
+	// new message is only marked as processed after its signature was verified.
+	if p.oldMessageQ.Get(env.hash(protocol)) {
+		return p.processMessage(sender, protocol, env, msg.Bytes())
+	}
+	if !signing.Verify(signing.NewPublicKey(env.Originator), env.signedBytes(protocol), env.Signature) {
+		metrics.InvalidGossipMessages.With(metrics.ProtocolLabel, protocol).Add(1)
+		p.stats.invalid(protocol)
+		p.Log.With().Warning("bad_gossip_signature", log.String("from", sender.String()), log.String("protocol", protocol),
+			log.String("originator", util.Bytes2Hex(env.Originator)))
+		return ErrBadSignature
+	}
+	return p.processMessage(sender, protocol, env, msg.Bytes())
+}
+
+// HandleControl handles a gossip control message from sender, used by peers to pull lazily pushed messages.
+func (p *Protocol) HandleControl(sender p2pcrypto.PublicKey, msg service.Data) error {
+	cm := &controlMessage{}
+	if err := types.BytesToInterface(msg.Bytes(), cm); err != nil {
+		return err
+	}
+	switch cm.Type {
+	case iHave:
+		return p.lazy.handleHave(sender, cm)
+	case iWant:
+		return p.lazy.handleWant(sender, cm)
+	}
+	return ErrBadControlMessage
+}
+
+// strategy returns the strategy that propagates the messages of protocol.
+func (p *Protocol) strategy(protocol string) strategy {
+	if _, ok := p.lazyProtocols[protocol]; ok {
+		return p.lazy
+	}
+	return p.flood
+}
+
+// ReportMissed records that count messages of protocol weren't delivered by gossip and had to be synced.
+func (p *Protocol) ReportMissed(protocol string, count int) {
+	metrics.MissedGossipMessages.With(metrics.ProtocolLabel, protocol).Add(float64(count))
+	p.stats.missed(protocol, count)
+}
+
+// Report returns a report of the delivery statistics of the gossip protocols and of the number of peers.
+func (p *Protocol) Report() Report {
+	return Report{Peers: int(p.peers.PeerCount()), Protocols: p.stats.report()}
+}
+
+// QueueDepths are the numbers of gossip messages waiting to be processed.
+type QueueDepths struct {
+	Validated int   // messages validated by their protocol, waiting to be queued for propagation
+	Propagate []int // messages waiting to be propagated to peers, by priority
+}
+
+// QueueDepths returns the numbers of messages waiting in the gossip queues.
+func (p *Protocol) QueueDepths() QueueDepths {
+	return QueueDepths{Validated: len(p.propagateQ), Propagate: p.pq.Lengths()}
 }
 
 // SetPriority sets the priority for protoName in the queue.
@@ -105,20 +292,39 @@ func (p *Protocol) markMessageAsOld(h types.Hash12) bool {
 	return p.oldMessageQ.GetOrInsert(h)
 }
 
-func (p *Protocol) processMessage(sender p2pcrypto.PublicKey, protocol string, msg service.Data) error {
-	h := types.CalcMessageHash12(msg.Bytes(), protocol)
+// processMessage passes the payload of env to protocol, envelope is the encoded env that's propagated once the payload
+// is valid.
+func (p *Protocol) processMessage(sender p2pcrypto.PublicKey, protocol string, env *Envelope, envelope []byte) error {
+	h := env.hash(protocol)
 	if p.markMessageAsOld(h) {
 		metrics.OldGossipMessages.With(metrics.ProtocolLabel, protocol).Add(1)
+		if sender != p.localNodePubkey {
+			p.stats.duplicate(protocol)
+		}
 		// todo : - have some more metrics for termination
 		// todo	: - maybe tell the peer we got this message already?
 		// todo : - maybe block this peer since he sends us old messages
-		p.Log.With().Debug("old_gossip_message", log.String("from", sender.String()), log.String("protocol", protocol), log.String("hash", util.Bytes2Hex(h[:])))
+		p.Log.With().Debug("old_gossip_message", log.String("from", sender.String()), log.String("protocol", protocol),
+			log.String("originator", util.Bytes2Hex(env.Originator)), log.String("hash", util.Bytes2Hex(h[:])))
 		return nil
 	}
 
-	p.Log.Event().Debug("new_gossip_message", log.String("from", sender.String()), log.String("protocol", protocol), log.String("hash", util.Bytes2Hex(h[:])))
+	p.Log.Event().Debug("new_gossip_message", log.String("from", sender.String()), log.String("protocol", protocol),
+		log.String("originator", util.Bytes2Hex(env.Originator)), log.String("hash", util.Bytes2Hex(h[:])))
 	metrics.NewGossipMessages.With("protocol", protocol).Add(1)
-	return p.net.ProcessGossipProtocolMessage(sender, protocol, msg, p.propagateQ)
+	p.lazy.delivered(h)
+	if p.seen != nil {
+		p.seen.add(h)
+	}
+	if sender != p.localNodePubkey {
+		latency := time.Since(time.Unix(0, env.Timestamp))
+		if latency < 0 {
+			latency = 0 // the originator's clock is ahead of ours
+		}
+		metrics.GossipLatency.With(metrics.ProtocolLabel, protocol).Observe(latency.Seconds())
+		p.stats.newMessage(protocol, sender, latency)
+	}
+	return p.net.ProcessGossipProtocolMessage(sender, protocol, service.DataBytes{Payload: env.Payload}, envelope, p.propagateQ)
 }
 
 // send a message to all the peers.
@@ -165,7 +371,7 @@ func (p *Protocol) handlePQ() {
 			m.Sender().Field("from"),
 			log.String("protocol", m.Protocol()),
 			h.Field("hash"))
-		p.propagateMessage(m.Message(), h, m.Protocol(), m.Sender())
+		p.strategy(m.Protocol()).propagate(m.Message(), h, m.Protocol(), m.Sender())
 	}
 }
 
@@ -117,17 +117,17 @@ func (mr *MockbaseNetworkMockRecorder) SubscribePeerEvents() *gomock.Call {
 }
 
 // ProcessGossipProtocolMessage mocks base method
-func (m *MockbaseNetwork) ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protocol string, data service.Data, validationCompletedChan chan service.MessageValidation) error {
+func (m *MockbaseNetwork) ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protocol string, data service.Data, envelope []byte, validationCompletedChan chan service.MessageValidation) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProcessGossipProtocolMessage", sender, protocol, data, validationCompletedChan)
+	ret := m.ctrl.Call(m, "ProcessGossipProtocolMessage", sender, protocol, data, envelope, validationCompletedChan)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ProcessGossipProtocolMessage indicates an expected call of ProcessGossipProtocolMessage
-func (mr *MockbaseNetworkMockRecorder) ProcessGossipProtocolMessage(sender, protocol, data, validationCompletedChan interface{}) *gomock.Call {
+func (mr *MockbaseNetworkMockRecorder) ProcessGossipProtocolMessage(sender, protocol, data, envelope, validationCompletedChan interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessGossipProtocolMessage", reflect.TypeOf((*MockbaseNetwork)(nil).ProcessGossipProtocolMessage), sender, protocol, data, validationCompletedChan)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessGossipProtocolMessage", reflect.TypeOf((*MockbaseNetwork)(nil).ProcessGossipProtocolMessage), sender, protocol, data, envelope, validationCompletedChan)
 }
 
 // MockprioQ is a mock of prioQ interface
@@ -182,6 +182,20 @@ func (mr *MockprioQMockRecorder) Read() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockprioQ)(nil).Read))
 }
 
+// Lengths mocks base method
+func (m *MockprioQ) Lengths() []int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lengths")
+	ret0, _ := ret[0].([]int)
+	return ret0
+}
+
+// Lengths indicates an expected call of Lengths
+func (mr *MockprioQMockRecorder) Lengths() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lengths", reflect.TypeOf((*MockprioQ)(nil).Lengths))
+}
+
 // Close mocks base method
 func (m *MockprioQ) Close() {
 	m.ctrl.T.Helper()
@@ -1,6 +1,9 @@
 package gossip
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -14,41 +17,154 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
 )
 
 //go:generate mockgen -package=gossip -destination=./protocol_mock_test.go -source=./protocol.go peersManager, baseNetwork, prioQ
 
 var logger = log.NewDefault("gossip-protocol-test")
 
+func signedEnvelope(t *testing.T, signer *signing.EdSigner, payload []byte, protocol string) service.Data {
+	env := &Envelope{
+		Originator: signer.PublicKey().Bytes(),
+		Timestamp:  time.Now().UnixNano(),
+		Payload:    payload,
+	}
+	env.Signature = signer.Sign(env.signedBytes(protocol))
+	bytes, err := types.InterfaceToBytes(env)
+	assert.NoError(t, err)
+	return service.DataBytes{Payload: bytes}
+}
+
 func TestProcessMessage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	net := NewMockbaseNetwork(ctrl)
-	protocol := NewProtocol(config.SwarmConfig{}, net, nil, nil, logger)
+	protocol := NewProtocol(config.SwarmConfig{}, net, nil, nil, signing.NewEdSigner(), logger)
 
 	isSent := false
 	net.EXPECT().
-		ProcessGossipProtocolMessage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		ProcessGossipProtocolMessage(gomock.Any(), gomock.Any(), service.DataBytes{Payload: []byte("test")}, gomock.Any(), gomock.Any()).
 		Do(func(...interface{}) { isSent = true })
 
-	err := protocol.processMessage(p2pcrypto.NewRandomPubkey(), "test", service.DataBytes{Payload: []byte("test")})
+	originator := signing.NewEdSigner()
+	err := protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, originator, []byte("test"), "test"))
 	assert.NoError(t, err, "err should be nil")
 	assert.Equal(t, true, isSent, "message should be sent")
 
 	isSent = false
-	err = protocol.processMessage(p2pcrypto.NewRandomPubkey(), "test", service.DataBytes{Payload: []byte("test")})
+	err = protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, originator, []byte("test"), "test"))
 	assert.NoError(t, err, "err  should be nil")
 	assert.Equal(t, false, isSent, "message shouldn't be sent, cause it's already done previously")
 }
 
+func TestRelay_Originators(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	net := NewMockbaseNetwork(ctrl)
+	protocol := NewProtocol(config.SwarmConfig{}, net, nil, nil, signing.NewEdSigner(), logger)
+
+	sent := 0
+	net.EXPECT().
+		ProcessGossipProtocolMessage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(...interface{}) { sent++ }).
+		AnyTimes()
+
+	// the same payload signed again by another originator is the same message
+	assert.NoError(t, protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, signing.NewEdSigner(), []byte("test"), "test")))
+	assert.NoError(t, protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, signing.NewEdSigner(), []byte("test"), "test")))
+	assert.Equal(t, 1, sent)
+	assert.NoError(t, protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, signing.NewEdSigner(), []byte("other"), "test")))
+	assert.Equal(t, 2, sent)
+
+	// signed for another protocol
+	err := protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, signing.NewEdSigner(), []byte("new"), "other"))
+	assert.Equal(t, ErrBadSignature, err)
+	// the message wasn't processed, so it's accepted once it's properly signed
+	assert.NoError(t, protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, signing.NewEdSigner(), []byte("new"), "test")))
+	assert.Equal(t, 3, sent)
+
+	// a duplicate of a processed message is dropped without verifying it
+	assert.NoError(t, protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, signing.NewEdSigner(), []byte("test"), "other")))
+
+	// not an envelope
+	err = protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", service.DataBytes{Payload: []byte("test")})
+	assert.Error(t, err)
+	assert.Equal(t, 3, sent)
+}
+
+func TestReport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	net := NewMockbaseNetwork(ctrl)
+	peersManager := NewMockpeersManager(ctrl)
+	peersManager.EXPECT().PeerCount().Return(uint64(2))
+	local := p2pcrypto.NewRandomPubkey()
+	protocol := NewProtocol(config.SwarmConfig{}, net, peersManager, local, signing.NewEdSigner(), logger)
+	net.EXPECT().
+		ProcessGossipProtocolMessage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	first, second := p2pcrypto.NewRandomPubkey(), p2pcrypto.NewRandomPubkey()
+	originator := signing.NewEdSigner()
+	msg := signedEnvelope(t, originator, []byte("a"), "test")
+	assert.NoError(t, protocol.Relay(first, "test", msg))
+	assert.NoError(t, protocol.Relay(second, "test", msg))
+	assert.NoError(t, protocol.Relay(first, "test", signedEnvelope(t, originator, []byte("b"), "test")))
+	assert.Equal(t, ErrBadSignature, protocol.Relay(second, "test", signedEnvelope(t, originator, []byte("c"), "other")))
+	protocol.ReportMissed("test", 2)
+	// messages broadcast by the node aren't deliveries
+	assert.NoError(t, protocol.Broadcast([]byte("d"), "test"))
+
+	report := protocol.Report()
+	assert.Equal(t, 2, report.Peers)
+	assert.Len(t, report.Protocols, 1)
+	st := report.Protocols[0]
+	assert.Equal(t, "test", st.Protocol)
+	assert.Equal(t, uint64(2), st.New)
+	assert.Equal(t, uint64(1), st.Duplicate)
+	assert.Equal(t, uint64(1), st.Invalid)
+	assert.Equal(t, uint64(2), st.Missed)
+	assert.Equal(t, 0.5, st.DuplicateRatio())
+	assert.Equal(t, 0.5, st.MissedRate())
+	assert.Equal(t, uint64(2), st.Latency[0])
+	assert.Equal(t, 1, st.FirstDeliverers)
+	assert.Equal(t, 1.0, st.TopDelivererShare)
+}
+
+func TestBroadcast(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	net := NewMockbaseNetwork(ctrl)
+	signer := signing.NewEdSigner()
+	protocol := NewProtocol(config.SwarmConfig{}, net, nil, p2pcrypto.NewRandomPubkey(), signer, logger)
+
+	var envelope []byte
+	net.EXPECT().
+		ProcessGossipProtocolMessage(gomock.Any(), "test", service.DataBytes{Payload: []byte("test")}, gomock.Any(), gomock.Any()).
+		Do(func(_, _, _ interface{}, env []byte, _ interface{}) { envelope = env })
+
+	assert.NoError(t, protocol.Broadcast([]byte("test"), "test"))
+
+	// the propagated envelope is accepted by the next hop
+	next := NewMockbaseNetwork(ctrl)
+	next.EXPECT().
+		ProcessGossipProtocolMessage(gomock.Any(), "test", service.DataBytes{Payload: []byte("test")}, envelope, gomock.Any())
+	nextProtocol := NewProtocol(config.SwarmConfig{}, next, nil, nil, signing.NewEdSigner(), logger)
+	assert.NoError(t, nextProtocol.Relay(p2pcrypto.NewRandomPubkey(), "test", service.DataBytes{Payload: envelope}))
+}
+
 func TestPropagateMessage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	net := NewMockbaseNetwork(ctrl)
 	peersManager := NewMockpeersManager(ctrl)
-	protocol := NewProtocol(config.SwarmConfig{}, net, peersManager, nil, logger)
+	protocol := NewProtocol(config.SwarmConfig{}, net, peersManager, nil, nil, logger)
 
 	peers := make([]p2ppeers.Peer, 30)
 	for i := range peers {
@@ -82,7 +198,7 @@ func TestPropagationEventLoop(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	protocol := NewProtocol(config.SwarmConfig{}, nil, nil, nil, logger)
+	protocol := NewProtocol(config.SwarmConfig{}, nil, nil, nil, nil, logger)
 	pq := NewMockprioQ(ctrl)
 	protocol.pq = pq
 
@@ -120,3 +236,39 @@ func TestPropagationEventLoop(t *testing.T) {
 	assert.Equal(t, true, isClosed, "listener should be shut down")
 
 }
+
+func TestProtocol_PersistSeen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, config.P2PDirectoryPath), 0700))
+
+	net := NewMockbaseNetwork(ctrl)
+	sent := 0
+	net.EXPECT().
+		ProcessGossipProtocolMessage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(...interface{}) { sent++ }).
+		AnyTimes()
+	cfg := config.SwarmConfig{DedupPersistWindow: time.Minute}
+	originator := signing.NewEdSigner()
+	seen := signedEnvelope(t, originator, []byte("seen"), "test")
+
+	protocol := NewProtocol(cfg, net, nil, nil, signing.NewEdSigner(), logger)
+	protocol.PersistSeen(dir)
+	protocol.Start()
+	assert.NoError(t, protocol.Relay(p2pcrypto.NewRandomPubkey(), "test", seen))
+	assert.Equal(t, 1, sent)
+	protocol.Close()
+
+	// after a restart, messages seen before it are old
+	restarted := NewProtocol(cfg, net, nil, nil, signing.NewEdSigner(), logger)
+	restarted.PersistSeen(dir)
+	restarted.Start()
+	defer restarted.Close()
+	assert.NoError(t, restarted.Relay(p2pcrypto.NewRandomPubkey(), "test", seen))
+	assert.Equal(t, 1, sent)
+	assert.NoError(t, restarted.Relay(p2pcrypto.NewRandomPubkey(), "test", signedEnvelope(t, originator, []byte("new"), "test")))
+	assert.Equal(t, 2, sent)
+}
@@ -0,0 +1,99 @@
+package gossip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// SeenFileName is the name of the file, under the p2p directory, that the hashes of recently seen messages are saved in
+const SeenFileName = "gossip-seen.json"
+
+// seenSaveInterval is how often the recently seen messages are saved, so that they're mostly saved if the node crashes
+const seenSaveInterval = time.Minute
+
+// seenMessage is a message's dedup hash and the unix time in nanoseconds when the node first saw it.
+type seenMessage struct {
+	Hash types.Hash12
+	Seen int64
+}
+
+// seenLog records when messages were first seen, so that the messages seen in the last window are saved and dropped
+// as duplicates after a restart, instead of being validated and relayed again. It keeps at most limit messages, the
+// dedup cache doesn't remember more.
+type seenLog struct {
+	mu       sync.Mutex
+	window   time.Duration
+	limit    int
+	messages []seenMessage // in the order they were seen
+	now      func() time.Time
+}
+
+func newSeenLog(window time.Duration, limit int) *seenLog {
+	return &seenLog{window: window, limit: limit, now: time.Now}
+}
+
+// add records that the message with hash h was first seen now.
+func (l *seenLog) add(h types.Hash12) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, seenMessage{Hash: h, Seen: l.now().UnixNano()})
+	if len(l.messages) > 2*l.limit {
+		l.messages = append([]seenMessage{}, l.messages[len(l.messages)-l.limit:]...)
+	}
+}
+
+// recent returns the messages seen in the last window, oldest first, and drops the older ones.
+func (l *seenLog) recent() []seenMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	from := l.now().Add(-l.window).UnixNano()
+	i := 0
+	for i < len(l.messages) && l.messages[i].Seen < from {
+		i++
+	}
+	l.messages = l.messages[i:]
+	if len(l.messages) > l.limit {
+		l.messages = l.messages[len(l.messages)-l.limit:]
+	}
+	return append([]seenMessage{}, l.messages...)
+}
+
+// save writes the messages seen in the last window to path. The file is replaced atomically, so a crash while saving
+// leaves the previous file.
+func (l *seenLog) save(path string) error {
+	bts, err := json.Marshal(l.recent())
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, bts, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// load reads the messages saved in path and returns those seen in the last window, they're recorded as seen at the
+// time they were first seen. A missing file has no messages.
+func (l *seenLog) load(path string) ([]seenMessage, error) {
+	bts, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var messages []seenMessage
+	if err := json.Unmarshal(bts, &messages); err != nil {
+		return nil, fmt.Errorf("could not parse %v: %v", path, err)
+	}
+	l.mu.Lock()
+	l.messages = append(messages, l.messages...)
+	l.mu.Unlock()
+	return l.recent(), nil
+}
@@ -0,0 +1,52 @@
+package gossip
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeenLog(t *testing.T) {
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, SeenFileName)
+
+	now := time.Unix(1000, 0)
+	l := newSeenLog(time.Minute, 2)
+	l.now = func() time.Time { return now }
+	l.add(types.Hash12{1})
+	now = now.Add(time.Minute)
+	l.add(types.Hash12{2})
+	l.add(types.Hash12{3})
+	now = now.Add(time.Second)
+	l.add(types.Hash12{4})
+
+	// the first message is out of the window, and the log keeps at most limit messages
+	r.NoError(l.save(path))
+	loaded := newSeenLog(time.Minute, 2)
+	loaded.now = l.now
+	messages, err := loaded.load(path)
+	r.NoError(err)
+	r.Len(messages, 2)
+	r.Equal(types.Hash12{3}, messages[0].Hash)
+	r.Equal(types.Hash12{4}, messages[1].Hash)
+
+	now = now.Add(time.Minute)
+	later := newSeenLog(time.Minute, 2)
+	later.now = l.now
+	messages, err = later.load(path)
+	r.NoError(err)
+	r.Len(messages, 1)
+	r.Equal(types.Hash12{4}, messages[0].Hash)
+
+	messages, err = newSeenLog(time.Minute, 2).load(filepath.Join(dir, "missing"))
+	r.NoError(err)
+	r.Empty(messages)
+}
@@ -0,0 +1,141 @@
+package gossip
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+)
+
+// LatencyBuckets are the upper bounds of the first seen latency buckets in a ProtocolStats. Latencies above the last
+// bound are counted in an extra bucket.
+var LatencyBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// ProtocolStats are the delivery statistics of a gossip protocol since the node started.
+type ProtocolStats struct {
+	Protocol string
+	// New is the number of new messages received, Duplicate the number of messages received again and Invalid the
+	// number of messages with a bad originator signature.
+	New, Duplicate, Invalid uint64
+	// Missed is the number of messages the node had to sync although it was listening to gossip.
+	Missed uint64
+	// Latency counts new messages by the time since they were originated, see LatencyBuckets.
+	Latency []uint64
+	// FirstDeliverers is the number of distinct peers that delivered new messages first, and TopDelivererShare the
+	// share of new messages delivered first by the most common one.
+	FirstDeliverers   int
+	TopDelivererShare float64
+}
+
+// DuplicateRatio returns the number of duplicates received per new message.
+func (s ProtocolStats) DuplicateRatio() float64 {
+	if s.New == 0 {
+		return 0
+	}
+	return float64(s.Duplicate) / float64(s.New)
+}
+
+// MissedRate returns the share of messages that gossip didn't deliver.
+func (s ProtocolStats) MissedRate() float64 {
+	if s.New+s.Missed == 0 {
+		return 0
+	}
+	return float64(s.Missed) / float64(s.New+s.Missed)
+}
+
+// Report is a report of the health of the gossip mesh.
+type Report struct {
+	Peers     int
+	Protocols []ProtocolStats
+}
+
+type protocolStats struct {
+	new, duplicate, invalid, missed uint64
+	latency                         []uint64
+	deliverers                      map[p2pcrypto.PublicKey]uint64
+}
+
+// stats tracks the delivery statistics of all the gossip protocols.
+type stats struct {
+	mu        sync.Mutex
+	protocols map[string]*protocolStats
+}
+
+func newStats() *stats {
+	return &stats{protocols: make(map[string]*protocolStats)}
+}
+
+func (s *stats) get(protocol string) *protocolStats {
+	ps, ok := s.protocols[protocol]
+	if !ok {
+		ps = &protocolStats{latency: make([]uint64, len(LatencyBuckets)+1), deliverers: make(map[p2pcrypto.PublicKey]uint64)}
+		s.protocols[protocol] = ps
+	}
+	return ps
+}
+
+func (s *stats) newMessage(protocol string, sender p2pcrypto.PublicKey, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps := s.get(protocol)
+	ps.new++
+	ps.latency[sort.Search(len(LatencyBuckets), func(i int) bool { return latency <= LatencyBuckets[i] })]++
+	ps.deliverers[sender]++
+}
+
+func (s *stats) duplicate(protocol string) {
+	s.mu.Lock()
+	s.get(protocol).duplicate++
+	s.mu.Unlock()
+}
+
+func (s *stats) invalid(protocol string) {
+	s.mu.Lock()
+	s.get(protocol).invalid++
+	s.mu.Unlock()
+}
+
+func (s *stats) missed(protocol string, count int) {
+	s.mu.Lock()
+	s.get(protocol).missed += uint64(count)
+	s.mu.Unlock()
+}
+
+// report returns the statistics of all the protocols, sorted by protocol name.
+func (s *stats) report() []ProtocolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ProtocolStats, 0, len(s.protocols))
+	for name, ps := range s.protocols {
+		st := ProtocolStats{
+			Protocol:        name,
+			New:             ps.new,
+			Duplicate:       ps.duplicate,
+			Invalid:         ps.invalid,
+			Missed:          ps.missed,
+			Latency:         append([]uint64{}, ps.latency...),
+			FirstDeliverers: len(ps.deliverers),
+		}
+		var top uint64
+		for _, n := range ps.deliverers {
+			if n > top {
+				top = n
+			}
+		}
+		if ps.new > 0 {
+			st.TopDelivererShare = float64(top) / float64(ps.new)
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Protocol < out[j].Protocol })
+	return out
+}
@@ -0,0 +1,155 @@
+package gossip
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+)
+
+// ControlProtocol is the protocol of the messages that peers exchange to pull lazily pushed messages.
+const ControlProtocol = "gossip/control"
+
+const (
+	lazyCacheSize   = 500             // number of announced messages kept for peers to pull
+	maxPendingWants = 1000            // number of messages we can be pulling at once
+	wantTimeout     = 3 * time.Second // time until a message we pulled can be pulled from another peer
+)
+
+const (
+	iHave byte = iota + 1
+	iWant
+)
+
+// ErrBadControlMessage is returned when a control message can't be handled.
+var ErrBadControlMessage = errors.New("bad gossip control message")
+
+// controlMessage announces (iHave) or requests (iWant) the message with Hash of Protocol.
+type controlMessage struct {
+	Type     byte
+	Protocol string
+	Hash     types.Hash12
+}
+
+// strategy propagates the messages that passed validation to the peers, except for exclude.
+type strategy interface {
+	propagate(envelope []byte, h types.Hash12, protocol string, exclude p2pcrypto.PublicKey)
+}
+
+// flood sends the whole message to all the peers. It has the lowest latency, but every peer receives the message from
+// most of its neighbors.
+type flood struct {
+	p *Protocol
+}
+
+func (f *flood) propagate(envelope []byte, h types.Hash12, protocol string, exclude p2pcrypto.PublicKey) {
+	f.p.propagateMessage(envelope, h, protocol, exclude)
+}
+
+type announced struct {
+	protocol string
+	envelope []byte
+}
+
+// lazyPush announces the hash of a message to all the peers and sends the message only to the peers that want it, so
+// that every peer receives large messages once at the cost of a round trip per hop.
+type lazyPush struct {
+	p *Protocol
+
+	mu    sync.Mutex
+	have  map[types.Hash12]announced
+	order []types.Hash12
+	wants map[types.Hash12]time.Time
+}
+
+func newLazyPush(p *Protocol) *lazyPush {
+	return &lazyPush{
+		p:     p,
+		have:  make(map[types.Hash12]announced),
+		wants: make(map[types.Hash12]time.Time),
+	}
+}
+
+func (l *lazyPush) propagate(envelope []byte, _ types.Hash12, protocol string, exclude p2pcrypto.PublicKey) {
+	env := &Envelope{}
+	if err := types.BytesToInterface(envelope, env); err != nil {
+		l.p.With().Error("could not decode a validated envelope", log.String("protocol", protocol), log.Err(err))
+		return
+	}
+	h := env.hash(protocol)
+
+	l.mu.Lock()
+	if _, ok := l.have[h]; !ok {
+		if len(l.order) >= lazyCacheSize {
+			delete(l.have, l.order[0])
+			l.order = l.order[1:]
+		}
+		l.have[h] = announced{protocol, envelope}
+		l.order = append(l.order, h)
+	}
+	l.mu.Unlock()
+
+	msg, err := types.InterfaceToBytes(&controlMessage{Type: iHave, Protocol: protocol, Hash: h})
+	if err != nil {
+		l.p.With().Error("could not encode announcement", log.String("protocol", protocol), log.Err(err))
+		return
+	}
+	l.p.propagateMessage(msg, h, ControlProtocol, exclude)
+}
+
+// handleHave pulls an announced message from sender, unless we have it or are already pulling it from another peer.
+func (l *lazyPush) handleHave(sender p2pcrypto.PublicKey, cm *controlMessage) error {
+	if l.p.oldMessageQ.Get(cm.Hash) {
+		return nil
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	if t, ok := l.wants[cm.Hash]; ok && now.Sub(t) < wantTimeout {
+		l.mu.Unlock()
+		return nil
+	}
+	if len(l.wants) >= maxPendingWants {
+		for h, t := range l.wants {
+			if now.Sub(t) >= wantTimeout {
+				delete(l.wants, h)
+			}
+		}
+		if len(l.wants) >= maxPendingWants {
+			l.mu.Unlock()
+			l.p.With().Warning("too many pending gossip pulls, ignoring announcement", log.String("protocol", cm.Protocol))
+			return nil
+		}
+	}
+	l.wants[cm.Hash] = now
+	l.mu.Unlock()
+
+	msg, err := types.InterfaceToBytes(&controlMessage{Type: iWant, Protocol: cm.Protocol, Hash: cm.Hash})
+	if err != nil {
+		return err
+	}
+	return l.p.net.SendMessage(sender, ControlProtocol, msg)
+}
+
+// handleWant sends sender a message we announced.
+func (l *lazyPush) handleWant(sender p2pcrypto.PublicKey, cm *controlMessage) error {
+	l.mu.Lock()
+	a, ok := l.have[cm.Hash]
+	l.mu.Unlock()
+	if !ok || a.protocol != cm.Protocol {
+		l.p.With().Debug("peer wants a message we don't have", log.String("from", sender.String()),
+			log.String("protocol", cm.Protocol), cm.Hash.Field("hash"))
+		return nil
+	}
+	return l.p.net.SendMessage(sender, cm.Protocol, a.envelope)
+}
+
+// delivered marks a message as received, so that it isn't pulled again.
+func (l *lazyPush) delivered(h types.Hash12) {
+	l.mu.Lock()
+	delete(l.wants, h)
+	l.mu.Unlock()
+}
@@ -0,0 +1,84 @@
+package gossip
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/p2p/config"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+func controlData(t *testing.T, typ byte, protocol string, h types.Hash12) service.Data {
+	bytes, err := types.InterfaceToBytes(&controlMessage{Type: typ, Protocol: protocol, Hash: h})
+	assert.NoError(t, err)
+	return service.DataBytes{Payload: bytes}
+}
+
+func TestStrategy(t *testing.T) {
+	protocol := NewProtocol(config.SwarmConfig{LazyPushProtocols: []string{"lazy"}}, nil, nil, nil, signing.NewEdSigner(), logger)
+	assert.Equal(t, protocol.lazy, protocol.strategy("lazy"))
+	assert.Equal(t, protocol.flood, protocol.strategy("test"))
+}
+
+func TestLazyPush_Propagate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	net := NewMockbaseNetwork(ctrl)
+	peersManager := NewMockpeersManager(ctrl)
+	protocol := NewProtocol(config.SwarmConfig{LazyPushProtocols: []string{"test"}}, net, peersManager, nil, signing.NewEdSigner(), logger)
+
+	sender, peer := p2pcrypto.NewRandomPubkey(), p2pcrypto.NewRandomPubkey()
+	peersManager.EXPECT().GetPeers().Return([]p2ppeers.Peer{sender, peer})
+
+	envelope := signedEnvelope(t, signing.NewEdSigner(), []byte("test"), "test").Bytes()
+	env := &Envelope{}
+	assert.NoError(t, types.BytesToInterface(envelope, env))
+	h := env.hash("test")
+
+	// only the hash is sent, and not to the sender
+	net.EXPECT().SendMessage(peer, ControlProtocol, controlData(t, iHave, "test", h).Bytes())
+	protocol.lazy.propagate(envelope, h, "test", sender)
+
+	// the message is sent to peers that want it
+	net.EXPECT().SendMessage(peer, "test", envelope)
+	assert.NoError(t, protocol.HandleControl(peer, controlData(t, iWant, "test", h)))
+
+	// but not for another protocol, or a message we don't have
+	assert.NoError(t, protocol.HandleControl(peer, controlData(t, iWant, "other", h)))
+	assert.NoError(t, protocol.HandleControl(peer, controlData(t, iWant, "test", types.CalcMessageHash12([]byte("x"), "test"))))
+}
+
+func TestLazyPush_HandleHave(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	net := NewMockbaseNetwork(ctrl)
+	protocol := NewProtocol(config.SwarmConfig{LazyPushProtocols: []string{"test"}}, net, nil, nil, signing.NewEdSigner(), logger)
+
+	first, second := p2pcrypto.NewRandomPubkey(), p2pcrypto.NewRandomPubkey()
+	h := types.CalcMessageHash12([]byte("test"), "test")
+
+	// a new message is pulled from the first peer that announces it
+	net.EXPECT().SendMessage(first, ControlProtocol, controlData(t, iWant, "test", h).Bytes())
+	assert.NoError(t, protocol.HandleControl(first, controlData(t, iHave, "test", h)))
+	assert.NoError(t, protocol.HandleControl(second, controlData(t, iHave, "test", h)))
+
+	// it's pulled from another peer if the first doesn't deliver
+	protocol.lazy.wants[h] = protocol.lazy.wants[h].Add(-wantTimeout)
+	net.EXPECT().SendMessage(second, ControlProtocol, controlData(t, iWant, "test", h).Bytes())
+	assert.NoError(t, protocol.HandleControl(second, controlData(t, iHave, "test", h)))
+
+	// messages we have aren't pulled
+	protocol.markMessageAsOld(h)
+	protocol.lazy.delivered(h)
+	assert.NoError(t, protocol.HandleControl(first, controlData(t, iHave, "test", h)))
+
+	assert.Equal(t, ErrBadControlMessage, protocol.HandleControl(first, controlData(t, 0, "test", h)))
+}
@@ -164,15 +164,17 @@ func createP2pInstance(t testing.TB, config config.Config) *Switch {
 
 // WaitForGossip waits that all nodes initialized gossip connections
 func (its *IntegrationTestSuite) WaitForGossip(ctx context.Context) error {
-	g, _ := errgroup.WithContext(ctx)
+	g, ctx := errgroup.WithContext(ctx)
 	for _, b := range its.boot {
+		b := b
 		g.Go(func() error {
-			return b.waitForGossip()
+			return b.waitForGossip(ctx)
 		})
 	}
 	for _, i := range its.Instances {
+		i := i
 		g.Go(func() error {
-			return i.waitForGossip()
+			return i.waitForGossip(ctx)
 		})
 	}
 	return g.Wait()
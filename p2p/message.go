@@ -32,6 +32,7 @@ func (pm directProtocolMessage) Bytes() []byte {
 type gossipProtocolMessage struct {
 	sender         p2pcrypto.PublicKey
 	data           service.Data
+	envelope       []byte // the message as received, signed by its originator
 	validationChan chan service.MessageValidation
 }
 
@@ -53,7 +54,7 @@ func (pm gossipProtocolMessage) ValidationCompletedChan() chan service.MessageVa
 
 func (pm gossipProtocolMessage) ReportValidation(protocol string) {
 	if pm.validationChan != nil {
-		pm.validationChan <- service.NewMessageValidation(pm.sender, pm.Bytes(), protocol)
+		pm.validationChan <- service.NewMessageValidation(pm.sender, pm.envelope, protocol)
 	}
 }
 
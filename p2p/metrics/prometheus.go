@@ -28,6 +28,8 @@ const (
 var (
 	// PropagationQueueLen is the current size of the gossip queue
 	PropagationQueueLen = mt.NewGauge("propagate_queue_len", MetricsSubsystem, "Number of messages in the gossip queue", nil)
+	// GossipLatency is the time since new gossip messages were originated, in seconds
+	GossipLatency = mt.NewHistogram("gossip_latency", MetricsSubsystem, "Time since new gossip messages were originated", []string{ProtocolLabel})
 	// QueueLength is the current size of protocol queues
 	QueueLength = mt.NewGauge("protocol_queue_len", MetricsSubsystem, "len of protocol queues", []string{ProtocolLabel})
 )
@@ -78,6 +80,8 @@ var (
 	OldGossipMessages = totalGossipMessages.With(messageTypeLabel, "old")
 	// InvalidGossipMessages is a metric for invalid messages received
 	InvalidGossipMessages = totalGossipMessages.With(messageTypeLabel, "invalid")
+	// MissedGossipMessages is a metric for messages that weren't received by gossip and were synced
+	MissedGossipMessages = totalGossipMessages.With(messageTypeLabel, "missed")
 
 	// AddrbookSize is the current size of the discovery
 	AddrbookSize = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
@@ -1,8 +1,11 @@
 package net
 
+import "github.com/spacemeshos/go-spacemesh/common/types"
+
 // HandshakeData is the handshake message struct
 type HandshakeData struct {
 	ClientVersion string
 	NetworkID     int32
 	Port          uint16
+	ProtocolHash  types.Hash32
 }
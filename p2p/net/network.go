@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/p2p/config"
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
@@ -228,7 +229,7 @@ func (n *Net) createSecuredConnection(ctx context.Context, address net.Addr, rem
 		return nil, err
 	}
 
-	handshakeMessage, err := generateHandshakeMessage(session, n.networkID, n.listenAddress.Port, n.localNode.PublicKey())
+	handshakeMessage, err := generateHandshakeMessage(session, n.networkID, n.config.ProtocolHash, n.listenAddress.Port, n.localNode.PublicKey())
 	if err != nil {
 		conn.Close()
 		return nil, err
@@ -342,19 +343,27 @@ func (n *Net) HandlePreSessionIncomingMessage(c Connection, message []byte) erro
 	// open message
 	protoMessage, err := session.OpenMessage(message)
 	if err != nil {
+		publishRejected(remotePubkey, events.ReasonBadHandshake)
 		return err
 	}
 
 	handshakeData := &HandshakeData{}
 	err = types.BytesToInterface(protoMessage, handshakeData)
 	if err != nil {
+		publishRejected(remotePubkey, events.ReasonBadHandshake)
 		return err
 	}
 
 	err = verifyNetworkIDAndClientVersion(n.networkID, handshakeData)
 	if err != nil {
+		publishRejected(remotePubkey, events.ReasonClientVersion)
 		return err
 	}
+	if handshakeData.ProtocolHash != n.config.ProtocolHash {
+		publishRejected(remotePubkey, events.ReasonProtocolConfig)
+		return fmt.Errorf("request protocol config hash (%v) is different than local protocol config hash (%v)",
+			handshakeData.ProtocolHash.ShortString(), n.config.ProtocolHash.ShortString())
+	}
 	// TODO: pass TO - IP:port and FROM - IP:port in handshake message.
 	remoteListeningPort := handshakeData.Port
 	remoteListeningAddress, err := replacePort(c.RemoteAddr().String(), remoteListeningPort)
@@ -367,6 +376,10 @@ func (n *Net) HandlePreSessionIncomingMessage(c Connection, message []byte) erro
 	return nil
 }
 
+func publishRejected(peer p2pcrypto.PublicKey, reason string) {
+	events.Publish(events.Peer{ID: peer.String(), Type: events.PeerRejected, Reason: reason})
+}
+
 func verifyNetworkIDAndClientVersion(networkID int8, handshakeData *HandshakeData) error {
 	// compare that version to the min client version in config
 	ok, err := version.CheckNodeVersion(handshakeData.ClientVersion, config.MinClientVersion)
@@ -385,11 +398,12 @@ func verifyNetworkIDAndClientVersion(networkID int8, handshakeData *HandshakeDat
 	return nil
 }
 
-func generateHandshakeMessage(session NetworkSession, networkID int8, localIncomingPort int, localPubkey p2pcrypto.PublicKey) ([]byte, error) {
+func generateHandshakeMessage(session NetworkSession, networkID int8, protocolHash types.Hash32, localIncomingPort int, localPubkey p2pcrypto.PublicKey) ([]byte, error) {
 	handshakeData := &HandshakeData{
 		ClientVersion: config.ClientVersion,
 		NetworkID:     int32(networkID),
 		Port:          uint16(localIncomingPort),
+		ProtocolHash:  protocolHash,
 	}
 	handshakeMessage, err := types.InterfaceToBytes(handshakeData)
 	if err != nil {
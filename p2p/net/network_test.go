@@ -3,6 +3,8 @@ package net
 import (
 	"encoding/hex"
 	"fmt"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/p2p/config"
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
@@ -165,7 +167,7 @@ func TestHandlePreSessionIncomingMessage2(t *testing.T) {
 	})
 
 	aliceSessionWithBob := createSession(aliceNode.PrivateKey(), bobNode.PublicKey())
-	aliceHandshakeMessageToBob, err := generateHandshakeMessage(aliceSessionWithBob, 1, 123, aliceNode.PublicKey())
+	aliceHandshakeMessageToBob, err := generateHandshakeMessage(aliceSessionWithBob, 1, types.Hash32{}, 123, aliceNode.PublicKey())
 	r.NoError(err)
 
 	wg.Add(1)
@@ -184,3 +186,37 @@ func TestHandlePreSessionIncomingMessage2(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestHandlePreSessionIncomingMessage_ProtocolHash(t *testing.T) {
+	r := require.New(t)
+
+	aliceNode, aliceNodeInfo := node.GenerateTestNode(t)
+	bobNode, _ := node.GenerateTestNode(t)
+
+	bobsAliceConn := NewConnectionMock(aliceNode.PublicKey())
+	bobsAliceConn.Addr = &net.TCPAddr{IP: aliceNodeInfo.IP, Port: int(aliceNodeInfo.ProtocolPort)}
+
+	cfg := config.DefaultConfig()
+	cfg.ProtocolHash = types.HexToHash32("0x1234")
+	bobsNet, err := NewNet(cfg, bobNode, log.NewDefault(t.Name()))
+	r.NoError(err)
+	connected := 0
+	bobsNet.SubscribeOnNewRemoteConnections(func(event NewConnectionEvent) {
+		connected++
+	})
+
+	peerEvents, unsubscribe := events.SubscribeLocal(events.EventPeer)
+	defer unsubscribe()
+
+	aliceSessionWithBob := createSession(aliceNode.PrivateKey(), bobNode.PublicKey())
+	aliceHandshakeMessageToBob, err := generateHandshakeMessage(aliceSessionWithBob, 1, types.HexToHash32("0x5678"), 123, aliceNode.PublicKey())
+	r.NoError(err)
+	r.Error(bobsNet.HandlePreSessionIncomingMessage(bobsAliceConn, aliceHandshakeMessageToBob))
+	r.Zero(connected)
+	r.Equal(events.Peer{ID: aliceNode.PublicKey().String(), Type: events.PeerRejected, Reason: events.ReasonProtocolConfig}, <-peerEvents)
+
+	aliceHandshakeMessageToBob, err = generateHandshakeMessage(aliceSessionWithBob, 1, cfg.ProtocolHash, 123, aliceNode.PublicKey())
+	r.NoError(err)
+	r.NoError(bobsNet.HandlePreSessionIncomingMessage(bobsAliceConn, aliceHandshakeMessageToBob))
+	r.Equal(1, connected)
+}
@@ -0,0 +1,215 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/filesystem"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/config"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Identity file - the node's identity is kept in a single file under the p2p directory, readable only by its owner.
+// Identities stored by older versions under the nodes directory are migrated to it.
+
+// ErrKeyMismatch is returned when the public key of an identity isn't derived from its private key.
+var ErrKeyMismatch = errors.New("public key doesn't match the private key")
+
+// ErrIdentityExists is returned when the identity of a node that already has one would be replaced without force.
+var ErrIdentityExists = errors.New("the node already has an identity")
+
+// IdentityFile returns the path of the identity file of the node with the data directory path.
+func IdentityFile(path string) string {
+	return filepath.Join(path, config.P2PDirectoryPath, config.IdentityFileName)
+}
+
+// LoadOrCreateIdentity returns the identity kept in the identity file under path. If there's no identity file, the
+// identity stored by older versions is migrated, or a new identity is created when there's none. nodeID, when not
+// empty, is the public key the identity must have. An identity file that can't be read is an error: the node must
+// not change its network identity silently, use NewIdentity or ImportIdentity with force to replace it.
+func LoadOrCreateIdentity(path, nodeID string) (LocalNode, error) {
+	n, err := ReadIdentity(path)
+	switch {
+	case err == nil:
+		if nodeID != "" && n.publicKey.String() != nodeID {
+			return emptyNode, fmt.Errorf("identity file %v holds node id %v, not %v. use `identity import --force` to replace it",
+				IdentityFile(path), n.publicKey.String(), nodeID)
+		}
+		return n, nil
+	case !os.IsNotExist(err):
+		return emptyNode, fmt.Errorf("cannot read identity file %v, refusing to create a new identity "+
+			"(use `identity new --force` to replace it): %v", IdentityFile(path), err)
+	}
+
+	if n, err = readLegacyIdentity(path, nodeID); err == nil {
+		if err := n.PersistIdentity(path); err != nil {
+			return emptyNode, err
+		}
+		log.Info("Migrated p2p identity %v to %v", n.publicKey.String(), IdentityFile(path))
+		return n, nil
+	} else if nodeID != "" || !os.IsNotExist(err) {
+		return emptyNode, fmt.Errorf("cannot migrate stored identity: %v", err)
+	}
+
+	return NewIdentity(path, false)
+}
+
+// readLegacyIdentity reads the identity with the given id, or the first one if id is empty, from the nodes directory
+// where older versions stored identities. The returned error satisfies os.IsNotExist if there are none.
+func readLegacyIdentity(path, nodeID string) (LocalNode, error) {
+	if nodeID == "" {
+		nds, err := getLocalNodes(path)
+		if err != nil || len(nds) == 0 {
+			return emptyNode, os.ErrNotExist
+		}
+		nodeID = nds[0]
+	}
+	return LoadIdentity(path, nodeID)
+}
+
+// ReadIdentity reads the identity file under path. The returned error satisfies os.IsNotExist if there's no
+// identity file. The permissions of a file that other users can access are restricted to its owner.
+func ReadIdentity(path string) (LocalNode, error) {
+	file := IdentityFile(path)
+	info, err := os.Stat(file)
+	if err != nil {
+		return emptyNode, err
+	}
+	if info.Mode().Perm()&^filesystem.OwnerReadWrite != 0 {
+		log.Warning("identity file %v is accessible by other users (%v), restricting it to its owner", file, info.Mode().Perm())
+		if err := os.Chmod(file, filesystem.OwnerReadWrite); err != nil {
+			return emptyNode, err
+		}
+	}
+	return readIdentityFile(file)
+}
+
+// readIdentityFile reads and validates the identity in file.
+func readIdentityFile(file string) (LocalNode, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return emptyNode, err
+	}
+	var nfd nodeFileData
+	if err := json.Unmarshal(data, &nfd); err != nil {
+		return emptyNode, err
+	}
+	n, err := newLocalNodeFromFile(&nfd)
+	if err != nil {
+		return emptyNode, err
+	}
+	var pub [32]byte
+	priv := n.privKey.Array()
+	curve25519.ScalarBaseMult(&pub, &priv)
+	if pub != n.publicKey.Array() {
+		return emptyNode, ErrKeyMismatch
+	}
+	return n, nil
+}
+
+// PersistIdentity writes the node's identity to the identity file under path, readable only by its owner. An
+// existing identity file is kept in a backup file next to it.
+func (n *LocalNode) PersistIdentity(path string) error {
+	data, err := json.MarshalIndent(nodeFileData{PubKey: n.publicKey.String(), PrivKey: n.privKey.String()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file := IdentityFile(path)
+	if err := filesystem.ExistOrCreate(filepath.Dir(file)); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so that a crash can't leave a partial identity file.
+	tmp := file + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if _, err := os.Stat(file); err == nil {
+		backup := fmt.Sprintf("%v.%v.bak", file, time.Now().UnixNano())
+		if err := os.Rename(file, backup); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		log.Warning("Replacing p2p identity, the previous identity file was moved to %v", backup)
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		return err
+	}
+
+	log.Info("Saved p2p identity %v to %v", n.publicKey.String(), file)
+	return nil
+}
+
+func writeSynced(file string, data []byte) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filesystem.OwnerReadWrite)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkNoIdentity returns ErrIdentityExists if there's an identity under path, in the identity file or stored by an
+// older version, unless force is set.
+func checkNoIdentity(path string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Stat(IdentityFile(path)); !os.IsNotExist(err) {
+		return ErrIdentityExists
+	}
+	if nds, err := getLocalNodes(path); err == nil && len(nds) > 0 {
+		return ErrIdentityExists
+	}
+	return nil
+}
+
+// NewIdentity creates a new identity and persists it under path. If there's an identity under path, it returns
+// ErrIdentityExists unless force is set, and then the existing identity file is kept in a backup file.
+func NewIdentity(path string, force bool) (LocalNode, error) {
+	if err := checkNoIdentity(path, force); err != nil {
+		return emptyNode, err
+	}
+	n, err := NewNodeIdentity()
+	if err != nil {
+		return emptyNode, err
+	}
+	if err := n.PersistIdentity(path); err != nil {
+		return emptyNode, err
+	}
+	return n, nil
+}
+
+// ImportIdentity reads the identity in file, in the format of the identity file, and persists it under path. If there's
+// an identity under path, it returns ErrIdentityExists unless force is set, and then the existing identity file is
+// kept in a backup file.
+func ImportIdentity(path, file string, force bool) (LocalNode, error) {
+	if err := checkNoIdentity(path, force); err != nil {
+		return emptyNode, err
+	}
+	n, err := readIdentityFile(file)
+	if err != nil {
+		return emptyNode, fmt.Errorf("cannot import identity from %v: %v", file, err)
+	}
+	if err := n.PersistIdentity(path); err != nil {
+		return emptyNode, err
+	}
+	return n, nil
+}
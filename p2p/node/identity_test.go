@@ -0,0 +1,115 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/filesystem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreateIdentity(t *testing.T) {
+	r := require.New(t)
+	path, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(path)
+
+	n, err := LoadOrCreateIdentity(path, "")
+	r.NoError(err)
+	info, err := os.Stat(IdentityFile(path))
+	r.NoError(err)
+	r.Equal(os.FileMode(filesystem.OwnerReadWrite), info.Mode().Perm())
+
+	// the identity is loaded on the next run
+	loaded, err := LoadOrCreateIdentity(path, "")
+	r.NoError(err)
+	r.Equal(n.PublicKey(), loaded.PublicKey())
+	_, err = LoadOrCreateIdentity(path, n.PublicKey().String())
+	r.NoError(err)
+	_, err = LoadOrCreateIdentity(path, "other")
+	r.Error(err)
+
+	// loose permissions are restricted
+	r.NoError(os.Chmod(IdentityFile(path), 0644))
+	_, err = LoadOrCreateIdentity(path, "")
+	r.NoError(err)
+	info, err = os.Stat(IdentityFile(path))
+	r.NoError(err)
+	r.Equal(os.FileMode(filesystem.OwnerReadWrite), info.Mode().Perm())
+
+	// an unreadable identity isn't replaced
+	r.NoError(ioutil.WriteFile(IdentityFile(path), []byte("garbage"), filesystem.OwnerReadWrite))
+	_, err = LoadOrCreateIdentity(path, "")
+	r.Error(err)
+	data, err := ioutil.ReadFile(IdentityFile(path))
+	r.NoError(err)
+	r.Equal("garbage", string(data))
+}
+
+func TestLoadOrCreateIdentity_Migrate(t *testing.T) {
+	r := require.New(t)
+	path, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(path)
+
+	legacy, err := NewNodeIdentity()
+	r.NoError(err)
+	r.NoError(legacy.PersistData(path))
+
+	n, err := LoadOrCreateIdentity(path, "")
+	r.NoError(err)
+	r.Equal(legacy.PublicKey(), n.PublicKey())
+	n, err = ReadIdentity(path)
+	r.NoError(err)
+	r.Equal(legacy.PublicKey(), n.PublicKey())
+}
+
+func TestNewIdentity_ImportIdentity(t *testing.T) {
+	r := require.New(t)
+	path, err := ioutil.TempDir("", t.Name())
+	r.NoError(err)
+	defer os.RemoveAll(path)
+
+	first, err := NewIdentity(path, false)
+	r.NoError(err)
+	exported, err := ioutil.ReadFile(IdentityFile(path))
+	r.NoError(err)
+
+	// the identity isn't replaced without force
+	_, err = NewIdentity(path, false)
+	r.Equal(ErrIdentityExists, err)
+	r.Len(backupsOf(r, path), 0)
+
+	// a new identity keeps the previous identity file in a backup
+	second, err := NewIdentity(path, true)
+	r.NoError(err)
+	r.NotEqual(first.PublicKey(), second.PublicKey())
+	r.Len(backupsOf(r, path), 1)
+
+	file := filepath.Join(path, "exported.json")
+	r.NoError(ioutil.WriteFile(file, exported, filesystem.OwnerReadWrite))
+	_, err = ImportIdentity(path, file, false)
+	r.Equal(ErrIdentityExists, err)
+	imported, err := ImportIdentity(path, file, true)
+	r.NoError(err)
+	r.Equal(first.PublicKey(), imported.PublicKey())
+	n, err := ReadIdentity(path)
+	r.NoError(err)
+	r.Equal(first.PublicKey(), n.PublicKey())
+
+	// an identity with mismatching keys isn't imported
+	other, err := NewNodeIdentity()
+	r.NoError(err)
+	mixed := LocalNode{publicKey: other.publicKey, privKey: first.privKey}
+	r.NoError(mixed.PersistIdentity(filepath.Join(path, "mixed")))
+	_, err = ImportIdentity(path, IdentityFile(filepath.Join(path, "mixed")), true)
+	r.Error(err)
+}
+
+func backupsOf(r *require.Assertions, path string) []string {
+	backups, err := filepath.Glob(IdentityFile(path) + ".*.bak")
+	r.NoError(err)
+	return backups
+}
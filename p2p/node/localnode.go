@@ -1,7 +1,9 @@
 package node
 
 import (
+	"github.com/spacemeshos/ed25519"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/signing"
 )
 
 // LocalNode is a public-private key pair used locally.
@@ -20,6 +22,12 @@ func (n LocalNode) PrivateKey() p2pcrypto.PrivateKey {
 	return n.privKey
 }
 
+// Signer returns an ed25519 signer derived from the node's private key. p2p keys can't sign, so the node signs its
+// gossip messages and its peer records with it.
+func (n LocalNode) Signer() (*signing.EdSigner, error) {
+	return signing.NewEdSignerFromBuffer(ed25519.NewKeyFromSeed(n.privKey.Bytes()))
+}
+
 var emptyNode LocalNode
 
 // NewNodeIdentity creates a new local node without attempting to restore node from local store.
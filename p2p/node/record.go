@@ -0,0 +1,66 @@
+package node
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// Record is a node's signed statement of its addresses, which nodes exchange in discovery instead of bare Infos so
+// that stale or spoofed addresses can be rejected. A node increases Seq whenever its addresses change. A record
+// supersedes the records of the same node with a lower Seq, and of two records with the same Seq the one with the
+// greater signature wins, so that every node settles on the same record.
+type Record struct {
+	Info
+	Seq       uint64
+	Signer    []byte // the ed25519 public key of the node, see LocalNode.Signer
+	Signature []byte
+}
+
+// NewRecord returns the record of info with sequence number seq, signed by signer.
+func NewRecord(info Info, seq uint64, signer *signing.EdSigner) *Record {
+	r := &Record{Info: info, Seq: seq, Signer: signer.PublicKey().Bytes()}
+	r.Signature = signer.Sign(r.signedBytes())
+	return r
+}
+
+// signedBytes returns the bytes that the node signs: its ID, IP, ports and the sequence number.
+func (r *Record) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(r.ID.Bytes())
+	ip := r.IP.To16()
+	if ip == nil {
+		ip = net.IPv6zero
+	}
+	buf.Write(ip)
+	_ = binary.Write(&buf, binary.BigEndian, r.ProtocolPort)
+	_ = binary.Write(&buf, binary.BigEndian, r.DiscoveryPort)
+	_ = binary.Write(&buf, binary.BigEndian, r.Seq)
+	return buf.Bytes()
+}
+
+// Verify returns an error if the record isn't a valid complete node signed by its signer. It doesn't check that the
+// signer is the node's key, since p2p keys can't sign: the address book pins the first signer it sees for a node.
+func (r *Record) Verify() error {
+	if err := r.Valid(); err != nil {
+		return err
+	}
+	if r.IP.IsUnspecified() {
+		return errors.New("no ip set to record")
+	}
+	if !signing.Verify(signing.NewPublicKey(r.Signer), r.signedBytes(), r.Signature) {
+		return errors.New("invalid record signature")
+	}
+	return nil
+}
+
+// Supersedes returns true if r replaces other, a record of the same node.
+func (r *Record) Supersedes(other *Record) bool {
+	if r.Seq != other.Seq {
+		return r.Seq > other.Seq
+	}
+	return bytes.Compare(r.Signature, other.Signature) > 0
+}
@@ -0,0 +1,65 @@
+package node
+
+import (
+	"net"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_Verify(t *testing.T) {
+	r := require.New(t)
+	ln, err := NewNodeIdentity()
+	r.NoError(err)
+	signer, err := ln.Signer()
+	r.NoError(err)
+	info := NewNode(ln.PublicKey(), net.ParseIP("1.2.3.4"), 7513, 7514)
+
+	rec := NewRecord(*info, 1, signer)
+	r.NoError(rec.Verify())
+
+	// the record survives serialization
+	b, err := types.InterfaceToBytes(rec)
+	r.NoError(err)
+	decoded := &Record{}
+	r.NoError(types.BytesToInterface(b, decoded))
+	r.NoError(decoded.Verify())
+	r.Equal(rec.Seq, decoded.Seq)
+	r.True(rec.IP.Equal(decoded.IP))
+
+	// a relayed record can't be changed
+	spoofed := *rec
+	spoofed.IP = net.ParseIP("5.6.7.8")
+	r.Error(spoofed.Verify())
+	spoofed = *rec
+	spoofed.Seq++
+	r.Error(spoofed.Verify())
+
+	// the signer is derived from the node's identity
+	other, err := ln.Signer()
+	r.NoError(err)
+	r.Equal(signer.PublicKey().Bytes(), other.PublicKey().Bytes())
+
+	// records without an ip aren't useful to other nodes
+	r.Error(NewRecord(*NewNode(ln.PublicKey(), net.IPv4zero, 7513, 7514), 1, signer).Verify())
+}
+
+func TestRecord_Supersedes(t *testing.T) {
+	r := require.New(t)
+	ln, err := NewNodeIdentity()
+	r.NoError(err)
+	signer, err := ln.Signer()
+	r.NoError(err)
+	info := NewNode(ln.PublicKey(), net.ParseIP("1.2.3.4"), 7513, 7514)
+
+	older, newer := NewRecord(*info, 1, signer), NewRecord(*info, 2, signer)
+	r.True(newer.Supersedes(older))
+	r.False(older.Supersedes(newer))
+	r.False(newer.Supersedes(newer))
+
+	// records with the same sequence number are ordered by signature
+	moved := NewRecord(*NewNode(ln.PublicKey(), net.ParseIP("5.6.7.8"), 7513, 7514), 1, signing.NewEdSigner())
+	r.NotEqual(older.Supersedes(moved), moved.Supersedes(older))
+}
@@ -0,0 +1,138 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+)
+
+// PeerProtocolStats are the statistics of the messages of a protocol exchanged with a connected peer.
+type PeerProtocolStats struct {
+	Peer     string
+	Protocol string
+	// Sent and Received are the number of messages sent to and received from the peer, BytesSent and BytesReceived
+	// their size on the wire.
+	Sent, Received           uint64
+	BytesSent, BytesReceived uint64
+	// Invalid is the number of received messages that failed to decode or didn't pass the gossip signature check.
+	Invalid uint64
+	// Responses is the number of responses the peer sent to requests of the node, and ResponseTime the total time the
+	// node waited for them.
+	Responses    uint64
+	ResponseTime time.Duration
+}
+
+// AvgResponseTime returns the average time the peer took to respond to requests.
+func (s PeerProtocolStats) AvgResponseTime() time.Duration {
+	if s.Responses == 0 {
+		return 0
+	}
+	return s.ResponseTime / time.Duration(s.Responses)
+}
+
+type peerProtocol struct {
+	peer     p2pcrypto.PublicKey
+	protocol string
+}
+
+// peerStats tracks the statistics of the messages exchanged with each connected peer, by protocol, and the client
+// version each peer announces.
+type peerStats struct {
+	mu       sync.Mutex
+	stats    map[peerProtocol]*PeerProtocolStats
+	versions map[p2pcrypto.PublicKey]string
+}
+
+func newPeerStats() *peerStats {
+	return &peerStats{stats: make(map[peerProtocol]*PeerProtocolStats), versions: make(map[p2pcrypto.PublicKey]string)}
+}
+
+// get returns the stats of peer and protocol, it must be called with mu held.
+func (ps *peerStats) get(peer p2pcrypto.PublicKey, protocol string) *PeerProtocolStats {
+	key := peerProtocol{peer, protocol}
+	st, ok := ps.stats[key]
+	if !ok {
+		st = &PeerProtocolStats{Peer: peer.String(), Protocol: protocol}
+		ps.stats[key] = st
+	}
+	return st
+}
+
+func (ps *peerStats) sent(peer p2pcrypto.PublicKey, protocol string, size int) {
+	ps.mu.Lock()
+	st := ps.get(peer, protocol)
+	st.Sent++
+	st.BytesSent += uint64(size)
+	ps.mu.Unlock()
+}
+
+func (ps *peerStats) received(peer p2pcrypto.PublicKey, protocol string, size int) {
+	ps.mu.Lock()
+	st := ps.get(peer, protocol)
+	st.Received++
+	st.BytesReceived += uint64(size)
+	ps.mu.Unlock()
+}
+
+func (ps *peerStats) invalid(peer p2pcrypto.PublicKey, protocol string) {
+	ps.mu.Lock()
+	ps.get(peer, protocol).Invalid++
+	ps.mu.Unlock()
+}
+
+func (ps *peerStats) response(peer p2pcrypto.PublicKey, protocol string, d time.Duration) {
+	ps.mu.Lock()
+	st := ps.get(peer, protocol)
+	st.Responses++
+	st.ResponseTime += d
+	ps.mu.Unlock()
+}
+
+// version records the client version announced in the metadata of a message of peer.
+func (ps *peerStats) version(peer p2pcrypto.PublicKey, ver string) {
+	ps.mu.Lock()
+	ps.versions[peer] = ver
+	ps.mu.Unlock()
+}
+
+// remove drops the stats of a peer that disconnected.
+func (ps *peerStats) remove(peer p2pcrypto.PublicKey) {
+	ps.mu.Lock()
+	delete(ps.versions, peer)
+	for key := range ps.stats {
+		if key.peer == peer {
+			delete(ps.stats, key)
+		}
+	}
+	ps.mu.Unlock()
+}
+
+// report returns the stats sorted by peer and protocol.
+func (ps *peerStats) report() []PeerProtocolStats {
+	ps.mu.Lock()
+	res := make([]PeerProtocolStats, 0, len(ps.stats))
+	for _, st := range ps.stats {
+		res = append(res, *st)
+	}
+	ps.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Peer != res[j].Peer {
+			return res[i].Peer < res[j].Peer
+		}
+		return res[i].Protocol < res[j].Protocol
+	})
+	return res
+}
+
+// peerVersions returns the client versions of the connected peers, one per peer.
+func (ps *peerStats) peerVersions() []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	res := make([]string, 0, len(ps.versions))
+	for _, ver := range ps.versions {
+		res = append(res, ver)
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerStats(t *testing.T) {
+	r := require.New(t)
+	ps := newPeerStats()
+	peer1, peer2 := p2pcrypto.NewRandomPubkey(), p2pcrypto.NewRandomPubkey()
+
+	ps.sent(peer1, "sync", 10)
+	ps.sent(peer1, "sync", 20)
+	ps.received(peer1, "sync", 100)
+	ps.response(peer1, "sync", 100*time.Millisecond)
+	ps.response(peer1, "sync", 300*time.Millisecond)
+	ps.received(peer1, "gossip", 50)
+	ps.invalid(peer1, "gossip")
+	ps.received(peer2, "sync", 5)
+
+	report := ps.report()
+	r.Len(report, 3)
+	for _, st := range report {
+		if st.Peer != peer1.String() {
+			r.Equal(PeerProtocolStats{Peer: peer2.String(), Protocol: "sync", Received: 1, BytesReceived: 5}, st)
+			continue
+		}
+		switch st.Protocol {
+		case "sync":
+			r.Equal(uint64(2), st.Sent)
+			r.Equal(uint64(30), st.BytesSent)
+			r.Equal(uint64(1), st.Received)
+			r.Equal(200*time.Millisecond, st.AvgResponseTime())
+		case "gossip":
+			r.Equal(uint64(1), st.Invalid)
+			r.Equal(time.Duration(0), st.AvgResponseTime())
+		default:
+			r.Failf("unexpected protocol", "%v", st.Protocol)
+		}
+	}
+
+	ps.version(peer1, "go-spacemesh/0.1.0")
+	ps.version(peer1, "go-spacemesh/0.2.0")
+	ps.version(peer2, "go-spacemesh/0.1.0")
+	r.ElementsMatch([]string{"go-spacemesh/0.2.0", "go-spacemesh/0.1.0"}, ps.peerVersions())
+
+	ps.remove(peer1)
+	report = ps.report()
+	r.Len(report, 1)
+	r.Equal(peer2.String(), report[0].Peer)
+	r.Equal([]string{"go-spacemesh/0.1.0"}, ps.peerVersions())
+}
@@ -0,0 +1,104 @@
+// Package replay detects gossip objects that peers send again shortly after they were first seen.
+package replay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	prmkit "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+)
+
+// DefaultWindow is how long an object is remembered after it was first seen, at least.
+const DefaultWindow = 10 * time.Minute
+
+// peerCacheSize is the number of peers whose replays are counted
+const peerCacheSize = 1000
+
+var (
+	seen = prmkit.NewCounterFrom(prometheus.CounterOpts{Namespace: "spacemesh", Subsystem: "p2p",
+		Name: "gossip_objects_seen", Help: "Number of gossip objects checked for replays"}, []string{"protocol"})
+	replays = prmkit.NewCounterFrom(prometheus.CounterOpts{Namespace: "spacemesh", Subsystem: "p2p",
+		Name: "gossip_object_replays", Help: "Number of gossip objects dropped as replays"}, []string{"protocol"})
+)
+
+// Window remembers the ids of the objects of a gossip protocol seen in the last window, and counts how many objects
+// each peer replayed. Ids are kept in two generations: the current one and the one before it, which is dropped when
+// the current one is a window old. An id is therefore remembered for between one and two windows.
+type Window struct {
+	mu       sync.Mutex
+	window   time.Duration
+	rotated  time.Time
+	current  map[string]struct{}
+	previous map[string]struct{}
+	peers    *lru.Cache
+	seen     metrics.Counter
+	replays  metrics.Counter
+	now      func() time.Time
+}
+
+// NewWindow returns a Window of the objects of protocol seen in the last window.
+func NewWindow(protocol string, window time.Duration) *Window {
+	peers, err := lru.New(peerCacheSize)
+	if err != nil {
+		log.Panic("could not create lru cache err=%v", err)
+	}
+	return &Window{
+		window:   window,
+		rotated:  time.Now(),
+		current:  make(map[string]struct{}),
+		previous: make(map[string]struct{}),
+		peers:    peers,
+		seen:     seen.With("protocol", protocol),
+		replays:  replays.With("protocol", protocol),
+		now:      time.Now,
+	}
+}
+
+// Replayed records that peer sent the object with id and returns true if the object was already seen in the window.
+// Replays are counted against the peer, they should be dropped without validating the object again. Since objects are
+// recorded before they're validated, id must cover everything the validation checks, e.g. the hash of the message,
+// or an invalid object would hide the valid one with the same id.
+func (w *Window) Replayed(id []byte, peer p2pcrypto.PublicKey) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seen.Add(1)
+
+	if now := w.now(); now.Sub(w.rotated) >= w.window {
+		w.previous, w.current = w.current, make(map[string]struct{})
+		if now.Sub(w.rotated) >= 2*w.window { // nothing was seen in the last window
+			w.previous = make(map[string]struct{})
+		}
+		w.rotated = now
+	}
+
+	key := string(id)
+	_, inCurrent := w.current[key]
+	_, inPrevious := w.previous[key]
+	if !inCurrent && !inPrevious {
+		w.current[key] = struct{}{}
+		return false
+	}
+
+	w.replays.Add(1)
+	if peer != nil {
+		count := uint64(0)
+		if c, ok := w.peers.Get(peer.String()); ok {
+			count = c.(uint64)
+		}
+		w.peers.Add(peer.String(), count+1)
+	}
+	return true
+}
+
+// PeerReplays returns the number of objects peer replayed, for peer scoring.
+func (w *Window) PeerReplays(peer p2pcrypto.PublicKey) uint64 {
+	if c, ok := w.peers.Get(peer.String()); ok {
+		return c.(uint64)
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindow_Replayed(t *testing.T) {
+	r := require.New(t)
+	w := NewWindow(t.Name(), time.Minute)
+	now := time.Now()
+	w.rotated = now
+	w.now = func() time.Time { return now }
+	first, second := p2pcrypto.NewRandomPubkey(), p2pcrypto.NewRandomPubkey()
+
+	r.False(w.Replayed([]byte("a"), first))
+	r.True(w.Replayed([]byte("a"), second))
+	r.True(w.Replayed([]byte("a"), second))
+	r.False(w.Replayed([]byte("b"), second))
+	r.Equal(uint64(0), w.PeerReplays(first))
+	r.Equal(uint64(2), w.PeerReplays(second))
+
+	// remembered for the next window
+	now = now.Add(time.Minute)
+	r.True(w.Replayed([]byte("a"), first))
+	r.False(w.Replayed([]byte("c"), first))
+	r.Equal(uint64(1), w.PeerReplays(first))
+
+	// and forgotten in the one after
+	now = now.Add(time.Minute)
+	r.False(w.Replayed([]byte("a"), first))
+	r.True(w.Replayed([]byte("c"), first))
+
+	// everything is forgotten after two idle windows
+	now = now.Add(2 * time.Minute)
+	r.False(w.Replayed([]byte("a"), first))
+	r.False(w.Replayed([]byte("c"), first))
+}
@@ -39,6 +39,7 @@ type MessageServer struct {
 	ReqID              uint64 //request id
 	name               string //server name
 	network            Service
+	responseReporter   ResponseTimeReporter // nil if the network doesn't keep response times
 	pendMutex          sync.RWMutex
 	pendingQueue       *list.List                                   //queue of pending messages
 	resHandlers        map[uint64]func(msg []byte)                  //response handlers by request ReqID
@@ -56,6 +57,11 @@ type Service interface {
 	SendWrappedMessage(nodeID p2pcrypto.PublicKey, protocol string, payload *service.DataMsgWrapper) error
 }
 
+// ResponseTimeReporter is implemented by networks that keep per peer statistics of the time peers take to respond.
+type ResponseTimeReporter interface {
+	ReportResponseTime(peer p2pcrypto.PublicKey, protocol string, d time.Duration)
+}
+
 // NewMsgServer registers a protocol and returns a new server to declare request and response handlers on.
 func NewMsgServer(network Service, name string, requestLifetime time.Duration, c chan service.DirectMessage, logger log.Log) *MessageServer {
 	p := &MessageServer{
@@ -70,6 +76,7 @@ func NewMsgServer(network Service, name string, requestLifetime time.Duration, c
 		exit:               make(chan struct{}),
 		workerLimiter:      make(chan struct{}, runtime.NumCPU()),
 	}
+	p.responseReporter, _ = network.(ResponseTimeReporter)
 
 	go p.readLoop()
 	return p
@@ -211,6 +218,13 @@ func (p *MessageServer) RegisterBytesMsgHandler(msgType MessageType, reqHandler
 // SendRequest sends a request of a specific message.
 func (p *MessageServer) SendRequest(msgType MessageType, payload []byte, address p2pcrypto.PublicKey, resHandler func(msg []byte)) error {
 	reqID := p.newReqID()
+	if p.responseReporter != nil {
+		sent, handler := time.Now(), resHandler
+		resHandler = func(msg []byte) {
+			p.responseReporter.ReportResponseTime(address, p.name, time.Since(sent))
+			handler(msg)
+		}
+	}
 	p.pendMutex.Lock()
 	p.resHandlers[reqID] = resHandler
 	p.pendingQueue.PushBack(Item{id: reqID, timestamp: time.Now()})
@@ -24,6 +24,7 @@ type Simulator struct {
 	protocolDirectHandler map[p2pcrypto.PublicKey]map[string]chan DirectMessage // maps peerPubkey -> protocol -> direct protocol handler
 	protocolGossipHandler map[p2pcrypto.PublicKey]map[string]chan GossipMessage // maps peerPubkey -> protocol -> gossip protocol handler
 	nodes                 map[p2pcrypto.PublicKey]*Node
+	partitions            map[p2pcrypto.PublicKey]int // maps peerPubkey -> partition, nil when the network is whole
 
 	subLock      sync.Mutex
 	newPeersSubs []chan p2pcrypto.PublicKey
@@ -51,6 +52,31 @@ func NewSimulator() *Simulator {
 	return s
 }
 
+// Partition splits the simulated network so that nodes can only exchange messages with nodes in the same group. Nodes
+// that are not listed in any group form a group of their own. A new call replaces the previous partitions.
+func (s *Simulator) Partition(groups ...[]p2pcrypto.PublicKey) {
+	s.mutex.Lock()
+	s.partitions = make(map[p2pcrypto.PublicKey]int)
+	for i, group := range groups {
+		for _, peer := range group {
+			s.partitions[peer] = i + 1
+		}
+	}
+	s.mutex.Unlock()
+}
+
+// Heal removes all partitions, reconnecting the simulated network.
+func (s *Simulator) Heal() {
+	s.mutex.Lock()
+	s.partitions = nil
+	s.mutex.Unlock()
+}
+
+// reachable must be called while holding the mutex.
+func (s *Simulator) reachable(from, to p2pcrypto.PublicKey) bool {
+	return s.partitions == nil || s.partitions[from] == s.partitions[to]
+}
+
 // SubscribeToPeerEvents starts listening to new peers and disconnected peers events.
 func (s *Simulator) SubscribeToPeerEvents(myid p2pcrypto.Key) (chan p2pcrypto.PublicKey, chan p2pcrypto.PublicKey) {
 	s.mutex.RLock()
@@ -247,7 +273,11 @@ func (sn *Node) SendMessage(peerPubkey p2pcrypto.PublicKey, protocol string, pay
 func (sn *Node) sendMessageImpl(nodeID p2pcrypto.PublicKey, protocol string, payload Data) error {
 	sn.sim.mutex.RLock()
 	thec, ok := sn.sim.protocolDirectHandler[nodeID][protocol]
+	reachable := sn.sim.reachable(sn.PublicKey(), nodeID)
 	sn.sim.mutex.RUnlock()
+	if !reachable {
+		return errors.New("node " + nodeID.String() + " is unreachable from this partition")
+	}
 	if ok {
 		thec <- simDirectMessage{simulatorMetadata(), payload, sn.Info.PublicKey()}
 		return nil
@@ -281,7 +311,7 @@ func (sn *Node) Broadcast(protocol string, payload []byte) error {
 		sendees := make([]chan GossipMessage, 0, len(sn.sim.protocolGossipHandler))
 
 		for n := range sn.sim.protocolGossipHandler {
-			if n == sn.PublicKey() {
+			if n == sn.PublicKey() || !sn.sim.reachable(sn.PublicKey(), n) {
 				continue
 			}
 			if c, ok := sn.sim.protocolGossipHandler[n][protocol]; ok {
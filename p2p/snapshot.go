@@ -0,0 +1,62 @@
+package p2p
+
+import (
+	"sort"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/discovery"
+	"github.com/spacemeshos/go-spacemesh/p2p/gossip"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+)
+
+// PeerSnapshot is the state of a connected peer.
+type PeerSnapshot struct {
+	ID       string
+	Outbound bool
+	Address  *discovery.AddressStats `json:",omitempty"` // nil if the peer isn't in the address book
+}
+
+// Snapshot is a snapshot of the p2p state, for diagnostic dumps of p2p related stalls.
+type Snapshot struct {
+	Peers          []PeerSnapshot
+	KnownAddresses int
+	Gossip         gossip.Report
+	GossipQueues   gossip.QueueDepths
+	ProtocolQueues map[string]int // messages waiting for the handler of each protocol
+}
+
+// Snapshot returns a snapshot of the connected peers, the address book and the gossip queues.
+func (s *Switch) Snapshot() Snapshot {
+	snap := Snapshot{
+		KnownAddresses: s.discover.Size(),
+		Gossip:         s.gossip.Report(),
+		GossipQueues:   s.gossip.QueueDepths(),
+		ProtocolQueues: make(map[string]int),
+	}
+
+	add := func(peers map[p2pcrypto.PublicKey]struct{}, outbound bool) {
+		for peer := range peers {
+			ps := PeerSnapshot{ID: peer.String(), Outbound: outbound}
+			if stats, err := s.discover.AddressStats(peer); err == nil {
+				ps.Address = &stats
+			}
+			snap.Peers = append(snap.Peers, ps)
+		}
+	}
+	s.outpeersMutex.RLock()
+	add(s.outpeers, true)
+	s.outpeersMutex.RUnlock()
+	s.inpeersMutex.RLock()
+	add(s.inpeers, false)
+	s.inpeersMutex.RUnlock()
+	sort.Slice(snap.Peers, func(i, j int) bool { return snap.Peers[i].ID < snap.Peers[j].ID })
+
+	s.protocolHandlerMutex.RLock()
+	for protocol, ch := range s.directProtocolHandlers {
+		snap.ProtocolQueues[protocol] = len(ch)
+	}
+	for protocol, ch := range s.gossipProtocolHandlers {
+		snap.ProtocolQueues[protocol] = len(ch)
+	}
+	s.protocolHandlerMutex.RUnlock()
+	return snap
+}
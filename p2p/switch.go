@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/nattraversal"
 	"github.com/spacemeshos/go-spacemesh/p2p/config"
@@ -78,6 +79,9 @@ type Switch struct {
 	// protocol used to gossip - disseminate messages.
 	gossip *gossip.Protocol
 
+	// statistics of the messages exchanged with each peer, by protocol.
+	peerStats *peerStats
+
 	// discover new peers and bootstrap the node connectivity.
 	discover discovery.PeerStore // peer addresses store
 
@@ -112,32 +116,41 @@ func (s *Switch) waitForBoot() error {
 	return nil
 }
 
-func (s *Switch) waitForGossip() error {
-	_, ok := <-s.gossipC
-	if !ok {
-		return s.gossipErr
+// waitForGossip waits until the switch connected its initial neighbors, or until it has peers and is connected to all
+// the peers it knows. The latter happens in small networks, where a node may only know peers that dialed it, which
+// aren't upgraded to outbound neighbors, while it still gets gossip from them.
+func (s *Switch) waitForGossip(ctx context.Context) error {
+	tm := time.NewTicker(NoResultsInterval)
+	defer tm.Stop()
+	for {
+		select {
+		case <-s.gossipC:
+			return s.gossipErr
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tm.C:
+		}
+		connected := s.connectedPeers()
+		if len(connected) > 0 && len(s.discover.SelectPeers(ctx, 1, s.notConnectedBias(connected))) == 0 {
+			return nil
+		}
 	}
-	return nil
 }
 
-// newSwarm creates a new P2P instance, configured by config, if a NodeID is given it tries to load if from `datadir`,
-// otherwise it loads the first found node from `datadir` or creates a new one and store it there. It creates
-// all the needed services.
-func newSwarm(ctx context.Context, config config.Config, logger log.Log, datadir string) (*Switch, error) {
-	var l node.LocalNode
-	var err error
-
-	// Load an existing identity from file if exists.
-	if config.NodeID != "" {
-		l, err = node.LoadIdentity(datadir, config.NodeID)
-	} else {
-		l, err = node.ReadFirstNodeData(datadir)
-		if err != nil {
-			logger.Warning("failed to load p2p identity from disk at %v. creating a new identity, err:%v", datadir, err)
-			l, err = node.NewNodeIdentity()
-		}
+// loadIdentity returns the node's identity persisted in `datadir`, which is created on the first run. Without a
+// `datadir` a new identity is created and not persisted. The identity is replaced with the identity command, not by
+// the node.
+func loadIdentity(config config.Config, datadir string) (node.LocalNode, error) {
+	if datadir == "" {
+		return node.NewNodeIdentity()
 	}
+	return node.LoadOrCreateIdentity(datadir, config.NodeID)
+}
 
+// newSwarm creates a new P2P instance, configured by config. It loads the node's identity from `datadir`, see
+// loadIdentity. It creates all the needed services.
+func newSwarm(ctx context.Context, config config.Config, logger log.Log, datadir string) (*Switch, error) {
+	l, err := loadIdentity(config, datadir)
 	if err != nil {
 		return nil, err
 	}
@@ -145,13 +158,6 @@ func newSwarm(ctx context.Context, config config.Config, logger log.Log, datadir
 	logger.Info("Local node identity >> %v", l.PublicKey().String())
 	logger = logger.WithFields(log.String("P2PID", l.PublicKey().String()))
 
-	if datadir != "" {
-		err := l.PersistData(datadir)
-		if err != nil {
-			logger.Warning("failed to persist p2p node data err=%v", err)
-		}
-	}
-
 	// Create networking
 
 	n, err := net.NewNet(config, l, logger.WithName("tcpnet"))
@@ -192,6 +198,7 @@ func newSwarm(ctx context.Context, config config.Config, logger log.Log, datadir
 
 		network:    n,
 		udpnetwork: udpnet,
+		peerStats:  newPeerStats(),
 	}
 
 	// Create the udp version of Switch
@@ -217,7 +224,14 @@ func newSwarm(ctx context.Context, config config.Config, logger log.Log, datadir
 
 	s.cPool = cpool
 
-	s.gossip = gossip.NewProtocol(config.SwarmConfig, s, peers.NewPeers(s, s.logger), s.LocalNode().PublicKey(), s.logger)
+	signer, err := l.Signer()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gossip signer: %v", err)
+	}
+	s.gossip = gossip.NewProtocol(config.SwarmConfig, s, peers.NewPeers(s, s.logger), s.LocalNode().PublicKey(), signer, s.logger)
+	if datadir != "" {
+		s.gossip.PersistSeen(datadir)
+	}
 
 	s.logger.Debug("Created newSwarm with key %s", l.PublicKey())
 	return s, nil
@@ -232,6 +246,7 @@ func (s *Switch) onNewConnection(nce net.NewConnectionEvent) {
 	err := s.addIncomingPeer(nce.Node.PublicKey())
 	if err != nil {
 		s.logger.Warning("Error adding new connection %v, err: %v", nce.Node.PublicKey(), err)
+		events.Publish(events.Peer{ID: nce.Node.PublicKey().String(), Type: events.PeerRejected, Reason: events.ReasonMaxInbound})
 		// todo: send rejection reason
 		s.cPool.CloseConnection(nce.Node.PublicKey())
 	}
@@ -391,6 +406,9 @@ func (s *Switch) sendMessageImpl(peerPubKey p2pcrypto.PublicKey, protocol string
 	}
 
 	err = conn.Send(final)
+	if err == nil {
+		s.peerStats.sent(peerPubKey, protocol, len(final))
+	}
 
 	s.logger.Debug("DirectMessage sent successfully")
 
@@ -468,7 +486,7 @@ func (s *Switch) processMessage(ime net.IncomingMessageEvent) {
 		s.logger.Error("Err reading message from %v, closing connection err=%v", ime.Conn.RemotePublicKey(), err)
 		if err := ime.Conn.Close(); err == nil {
 			s.cPool.CloseConnection(ime.Conn.RemotePublicKey())
-			s.Disconnect(ime.Conn.RemotePublicKey())
+			s.disconnect(ime.Conn.RemotePublicKey(), events.ReasonBadMessage)
 		}
 	}
 }
@@ -525,7 +543,7 @@ var (
 // onRemoteClientMessage pre-process a protocol message from a remote client handling decryption and authentication
 // authenticated messages are forwarded to corresponding protocol handlers
 // msg : an incoming message event that includes the raw message and the sending connection.
-func (s *Switch) onRemoteClientMessage(msg net.IncomingMessageEvent) error {
+func (s *Switch) onRemoteClientMessage(msg net.IncomingMessageEvent) (err error) {
 
 	if msg.Message == nil || msg.Conn == nil {
 		return ErrBadFormat1
@@ -551,6 +569,15 @@ func (s *Switch) onRemoteClientMessage(msg net.IncomingMessageEvent) error {
 		return ErrBadFormat2
 	}
 
+	peer, protocol := msg.Conn.RemotePublicKey(), pm.Metadata.NextProtocol
+	s.peerStats.received(peer, protocol, len(msg.Message))
+	s.peerStats.version(peer, pm.Metadata.ClientVersion)
+	defer func() {
+		if err != nil {
+			s.peerStats.invalid(peer, protocol)
+		}
+	}()
+
 	// check that the message was sent within a reasonable time
 	if ok := timesync.CheckMessageDrift(pm.Metadata.Timestamp); !ok {
 		// TODO: consider kill connection with this node and maybe blacklist
@@ -574,6 +601,10 @@ func (s *Switch) onRemoteClientMessage(msg net.IncomingMessageEvent) error {
 
 	s.logger.Debug("Handle %v message from << %v", pm.Metadata.NextProtocol, msg.Conn.RemotePublicKey().String())
 
+	if pm.Metadata.NextProtocol == gossip.ControlProtocol {
+		return s.gossip.HandleControl(msg.Conn.RemotePublicKey(), data)
+	}
+
 	if ok {
 		// if this message is tagged with a gossip protocol, relay it.
 		return s.gossip.Relay(msg.Conn.RemotePublicKey(), pm.Metadata.NextProtocol, data)
@@ -603,8 +634,9 @@ func (s *Switch) ProcessDirectProtocolMessage(sender p2pcrypto.PublicKey, protoc
 }
 
 // ProcessGossipProtocolMessage passes an already decrypted message to a protocol. It is expected that the protocol will send
-// the message syntactic validation result on the validationCompletedChan ASAP
-func (s *Switch) ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protocol string, data service.Data, validationCompletedChan chan service.MessageValidation) error {
+// the message syntactic validation result on the validationCompletedChan ASAP. envelope is propagated once the message is
+// valid.
+func (s *Switch) ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protocol string, data service.Data, envelope []byte, validationCompletedChan chan service.MessageValidation) error {
 	// route authenticated message to the registered protocol
 	s.protocolHandlerMutex.RLock()
 	msgchan := s.gossipProtocolHandlers[protocol]
@@ -617,7 +649,7 @@ func (s *Switch) ProcessGossipProtocolMessage(sender p2pcrypto.PublicKey, protoc
 	metrics.QueueLength.With(metrics.ProtocolLabel, protocol).Set(float64(len(msgchan)))
 
 	// TODO: check queue length
-	msgchan <- gossipProtocolMessage{sender, data, validationCompletedChan}
+	msgchan <- gossipProtocolMessage{sender, data, envelope, validationCompletedChan}
 
 	return nil
 }
@@ -628,12 +660,45 @@ func (s *Switch) Broadcast(protocol string, payload []byte) error {
 	return s.gossip.Broadcast(payload, protocol)
 }
 
+// GossipReport returns a report of the gossip delivery statistics, for tuning the number of peers.
+func (s *Switch) GossipReport() gossip.Report {
+	return s.gossip.Report()
+}
+
+// PeerStats returns the statistics of the messages exchanged with each connected peer, by protocol.
+func (s *Switch) PeerStats() []PeerProtocolStats {
+	return s.peerStats.report()
+}
+
+// PeerVersions returns the client versions that the connected peers announce in their messages, one per peer that
+// sent a message.
+func (s *Switch) PeerVersions() []string {
+	return s.peerStats.peerVersions()
+}
+
+// ReportResponseTime records that peer took d to respond to a request of protocol. It is used by `MessageServer`.
+func (s *Switch) ReportResponseTime(peer p2pcrypto.PublicKey, protocol string, d time.Duration) {
+	s.peerStats.response(peer, protocol, d)
+}
+
+// PeerTimeDrift returns the median drift of our clock from the clocks of peers, sampled by discovery pings, and the
+// number of peers sampled.
+func (s *Switch) PeerTimeDrift() (time.Duration, int) {
+	return s.discover.PeerTimeDrift()
+}
+
+// ReportMissedGossip records that count messages of protocol weren't delivered by gossip and had to be synced.
+func (s *Switch) ReportMissedGossip(protocol string, count int) {
+	s.gossip.ReportMissed(protocol, count)
+}
+
 // Neighborhood : a small circle of peers we try to keep connections to. if a connection
 // is closed we grab a new peer and connect it. we try to achieve a steady number of
 // outgoing connections. protocols can use these peers to send direct messages or gossip.
 
 // tells protocols  we connected to a new peer.
-func (s *Switch) publishNewPeer(peer p2pcrypto.PublicKey) {
+func (s *Switch) publishNewPeer(peer p2pcrypto.PublicKey, reason string) {
+	events.Publish(events.Peer{ID: peer.String(), Type: events.PeerConnected, Reason: reason})
 	s.peerLock.RLock()
 	for _, p := range s.newPeerSub {
 		select {
@@ -645,7 +710,9 @@ func (s *Switch) publishNewPeer(peer p2pcrypto.PublicKey) {
 }
 
 // tells protocols  we disconnected a peer.
-func (s *Switch) publishDelPeer(peer p2pcrypto.PublicKey) {
+func (s *Switch) publishDelPeer(peer p2pcrypto.PublicKey, reason string) {
+	events.Publish(events.Peer{ID: peer.String(), Type: events.PeerDisconnected, Reason: reason})
+	s.peerStats.remove(peer)
 	s.peerLock.RLock()
 	for _, p := range s.delPeerSub {
 		select {
@@ -745,6 +812,30 @@ func (s *Switch) askForMorePeers() {
 	}
 }
 
+// connectedPeers returns a set of the inbound and outbound peers we're connected to.
+func (s *Switch) connectedPeers() map[p2pcrypto.PublicKey]struct{} {
+	connected := make(map[p2pcrypto.PublicKey]struct{})
+	s.outpeersMutex.RLock()
+	for pk := range s.outpeers {
+		connected[pk] = struct{}{}
+	}
+	s.outpeersMutex.RUnlock()
+	s.inpeersMutex.RLock()
+	for pk := range s.inpeers {
+		connected[pk] = struct{}{}
+	}
+	s.inpeersMutex.RUnlock()
+	return connected
+}
+
+// notConnectedBias selects peers that aren't in connected, other than the local node.
+func (s *Switch) notConnectedBias(connected map[p2pcrypto.PublicKey]struct{}) discovery.AddressBias {
+	return discovery.AddressBias{Filter: func(info *node.Info) bool {
+		_, ok := connected[info.PublicKey()]
+		return !ok && info.PublicKey() != s.lNode.PublicKey()
+	}}
+}
+
 // getMorePeers tries to fill the `outpeers` slice with dialed outbound peers that we selected from the discovery.
 func (s *Switch) getMorePeers(numpeers int) int {
 
@@ -752,8 +843,9 @@ func (s *Switch) getMorePeers(numpeers int) int {
 		return 0
 	}
 
-	// discovery should provide us with random peers to connect to
-	nds := s.discover.SelectPeers(s.ctx, numpeers)
+	// discovery should provide us with random peers to connect to, skipping the ones we're connected to
+	connected := s.connectedPeers()
+	nds := s.discover.SelectPeers(s.ctx, numpeers, s.notConnectedBias(connected))
 	ndsLen := len(nds)
 	if ndsLen == 0 {
 		s.logger.Debug("Peer sampler returned nothing.")
@@ -820,7 +912,8 @@ loop:
 			s.outpeersMutex.Unlock()
 
 			s.discover.Good(cne.n.PublicKey())
-			s.publishNewPeer(cne.n.PublicKey())
+			s.discover.Anchor(cne.n.PublicKey())
+			s.publishNewPeer(cne.n.PublicKey(), events.ReasonOutbound)
 			metrics.OutboundPeers.Add(1)
 			s.logger.Debug("Neighborhood: Added peer to peer list %v", cne.n.PublicKey())
 		case <-tm.C:
@@ -839,11 +932,16 @@ loop:
 
 // Disconnect removes a peer from the neighborhood. It requests more peers if our outbound peer count is less than configured
 func (s *Switch) Disconnect(peer p2pcrypto.PublicKey) {
+	s.disconnect(peer, events.ReasonConnClosed)
+}
+
+// disconnect removes a peer from the neighborhood, reason is reported in the peer disconnected event.
+func (s *Switch) disconnect(peer p2pcrypto.PublicKey, reason string) {
 	s.inpeersMutex.Lock()
 	if _, ok := s.inpeers[peer]; ok {
 		delete(s.inpeers, peer)
 		s.inpeersMutex.Unlock()
-		s.publishDelPeer(peer)
+		s.publishDelPeer(peer, reason)
 		metrics.InboundPeers.Add(-1)
 		return
 	}
@@ -857,7 +955,8 @@ func (s *Switch) Disconnect(peer p2pcrypto.PublicKey) {
 		return
 	}
 	s.outpeersMutex.Unlock()
-	s.publishDelPeer(peer)
+	s.discover.Unanchor(peer)
+	s.publishDelPeer(peer, reason)
 	metrics.OutboundPeers.Add(-1)
 
 	// todo: don't remove if we know this is a valid peer for later
@@ -883,7 +982,7 @@ func (s *Switch) addIncomingPeer(n p2pcrypto.PublicKey) error {
 	s.inpeers[n] = struct{}{}
 	s.inpeersMutex.Unlock()
 	if !exist {
-		s.publishNewPeer(n)
+		s.publishNewPeer(n, events.ReasonInbound)
 		metrics.InboundPeers.Add(1)
 	}
 	return nil
@@ -20,6 +20,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/node"
 	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/priorityq"
 	"github.com/spacemeshos/go-spacemesh/rand"
 	"github.com/stretchr/testify/assert"
 	"sync"
@@ -144,6 +145,8 @@ func TestSwarm_processMessage(t *testing.T) {
 		outpeers:     make(map[p2pcrypto.PublicKey]struct{}),
 		delPeerSub:   make([]chan p2pcrypto.PublicKey, 0),
 		morePeersReq: make(chan struct{}, 1),
+		discover:     &discovery.MockPeerStore{},
+		peerStats:    newPeerStats(),
 	}
 	cpmock := newCpoolMock()
 	s.cPool = cpmock
@@ -706,7 +709,7 @@ func Test_Swarm_getMorePeers3(t *testing.T) {
 	mdht := new(discovery.MockPeerStore)
 	n.discover = mdht
 	testNode := node.GenerateRandomNodeData()
-	mdht.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	mdht.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		return []*node.Info{testNode}
 	}
 
@@ -738,7 +741,7 @@ func Test_Swarm_getMorePeers4(t *testing.T) {
 	n.discover = mdht
 
 	testNode := node.GenerateRandomNodeData()
-	mdht.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	mdht.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		return []*node.Info{testNode}
 	}
 
@@ -778,7 +781,7 @@ func Test_Swarm_getMorePeers5(t *testing.T) {
 
 	n.cPool = cpm
 
-	mdht.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	mdht.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		return node.GenerateRandomNodesData(qty)
 	}
 
@@ -811,7 +814,7 @@ func Test_Swarm_getMorePeers6(t *testing.T) {
 
 	n.cPool = cpm
 
-	mdht.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	mdht.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		return node.GenerateRandomNodesData(qty)
 	}
 
@@ -825,7 +828,7 @@ func Test_Swarm_getMorePeers6(t *testing.T) {
 
 	//test not replacing inc peer
 	//
-	mdht.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	mdht.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		some := node.GenerateRandomNodesData(qty - 1)
 		some = append(some, nd)
 		return some
@@ -881,7 +884,7 @@ func TestNeighborhood_Initial(t *testing.T) {
 
 	p := p2pTestNoStart(t, cfg)
 	mdht := new(discovery.MockPeerStore)
-	mdht.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	mdht.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		return node.GenerateRandomNodesData(qty)
 	}
 
@@ -987,7 +990,7 @@ func TestSwarm_AskPeersSerial(t *testing.T) {
 	timescalled := uint32(0)
 	block := make(chan struct{})
 
-	dsc.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	dsc.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		atomic.AddUint32(&timescalled, 1)
 		<-block
 		return node.GenerateRandomNodesData(qty) // will trigger sending on morepeersreq
@@ -1057,7 +1060,7 @@ func TestNeighborhood_ReportConnectionResult(t *testing.T) {
 		}
 	}
 
-	ps.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	ps.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		return rnds
 	}
 
@@ -1098,7 +1101,7 @@ func TestNeighborhood_ReportConnectionResult(t *testing.T) {
 
 	newrnds := node.GenerateRandomNodesData(PeerNum - 2)
 
-	ps.SelectPeersFunc = func(ctx context.Context, qty int) []*node.Info {
+	ps.SelectPeersFunc = func(ctx context.Context, qty int, bias discovery.AddressBias) []*node.Info {
 		return append([]*node.Info{realnodeinfo, realnode2info}, newrnds...)
 	}
 
@@ -1396,3 +1399,27 @@ func createDiscoverUpnpFunc(funcErr error, port uint16, gatewayForwardErr error)
 		return &UpnpGatewayMock{errs: map[uint16][]error{port: {gatewayForwardErr}}}, nil
 	}
 }
+
+func TestSwarm_Snapshot(t *testing.T) {
+	p := p2pTestNoStart(t, configWithPort(0))
+	p.RegisterGossipProtocol("snapshot", priorityq.High)
+	known := node.GenerateRandomNodeData()
+	p.discover.Update(known, known)
+	require.NoError(t, p.addIncomingPeer(known.PublicKey()))
+	unknown := node.GenerateRandomNodeData()
+	require.NoError(t, p.addIncomingPeer(unknown.PublicKey()))
+
+	snap := p.Snapshot()
+	require.Len(t, snap.Peers, 2)
+	for _, peer := range snap.Peers {
+		require.False(t, peer.Outbound)
+		if peer.ID == known.PublicKey().String() {
+			require.NotNil(t, peer.Address)
+		} else {
+			require.Nil(t, peer.Address)
+		}
+	}
+	require.Equal(t, p.discover.Size(), snap.KnownAddresses)
+	require.Contains(t, snap.ProtocolQueues, "snapshot")
+	require.Len(t, snap.GossipQueues.Propagate, 3)
+}
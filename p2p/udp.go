@@ -9,6 +9,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/p2p/connectionpool"
 	"github.com/spacemeshos/go-spacemesh/p2p/version"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/spacemeshos/go-spacemesh/log"
@@ -42,6 +43,9 @@ type UDPMux struct {
 
 	messages map[string]chan service.DirectMessage
 	shutdown chan struct{}
+
+	nextMsgID   uint32
+	reassembler *reassembler
 }
 
 // NewUDPMux creates a new udp protocol server
@@ -58,6 +62,8 @@ func NewUDPMux(localNode node.LocalNode, lookuper Lookuper, udpNet udpNetwork, n
 		cpool:     cpool,
 		messages:  make(map[string]chan service.DirectMessage),
 		shutdown:  make(chan struct{}, 1),
+
+		reassembler: newReassembler(),
 	}
 
 	udpNet.SubscribeOnNewRemoteConnections(func(event inet.NewConnectionEvent) {
@@ -193,16 +199,28 @@ func (mux *UDPMux) sendMessageImpl(peerPubkey p2pcrypto.PublicKey, protocol stri
 	// TODO: node.address should have IP address, UDP and TCP PORT.
 	// 		 for now assuming it's the same port for both.
 
-	final := session.SealMessage(data)
-
-	realfinal := p2pcrypto.PrependPubkey(final, mux.local.PublicKey())
-
-	err = conn.Send(realfinal)
+	// messages are sent in fragments that fit in a datagram, large messages would otherwise be dropped on the way
+	frags, err := fragment(atomic.AddUint32(&mux.nextMsgID, 1), data)
 	if err != nil {
 		return err
 	}
+	for _, f := range frags {
+		fdata, err := types.InterfaceToBytes(f)
+		if err != nil {
+			return fmt.Errorf("failed to encode udp fragment err: %v", err)
+		}
+
+		final := session.SealMessage(fdata)
+
+		realfinal := p2pcrypto.PrependPubkey(final, mux.local.PublicKey())
+
+		err = conn.Send(realfinal)
+		if err != nil {
+			return err
+		}
+	}
 
-	mux.logger.With().Debug("Sent UDP message", log.String("protocol", protocol), log.String("to", peer.String()), log.Int("len", len(realfinal)))
+	mux.logger.With().Debug("Sent UDP message", log.String("protocol", protocol), log.String("to", peer.String()), log.Int("len", len(data)), log.Int("fragments", len(frags)))
 	return nil
 }
 
@@ -248,12 +266,27 @@ func (mux *UDPMux) processUDPMessage(msg inet.IncomingMessageEvent) error {
 		return err
 	}
 
-	decPayload, err := session.OpenMessage(rawmsg)
+	decFragment, err := session.OpenMessage(rawmsg)
 	if err != nil {
 		mux.logger.Warning("failed decrypting message err=%v", err)
 		return ErrFailDecrypt
 	}
 
+	frag := &udpFragment{}
+	err = types.BytesToInterface(decFragment, frag)
+	if err != nil {
+		mux.logger.Error("deserialization err=", err)
+		return ErrBadFormat2
+	}
+
+	decPayload, err := mux.reassembler.add(msg.Conn.RemotePublicKey().Array(), frag, time.Now())
+	if err != nil {
+		return err
+	}
+	if decPayload == nil {
+		return nil // waiting for the rest of the fragments
+	}
+
 	pm := &ProtocolMessage{}
 	err = types.BytesToInterface(decPayload, pm)
 	if err != nil {
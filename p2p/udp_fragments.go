@@ -0,0 +1,159 @@
+package p2p
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// udpFragmentSize is the number of message bytes in a udp datagram. with the fragment header, the encryption
+	// overhead, the sender's public key and the ip and udp headers datagrams stay below the minimum ipv6 MTU (1280).
+	udpFragmentSize = 1100
+	// maxUDPFragments bounds the size of a udp message to maxUDPFragments * udpFragmentSize.
+	maxUDPFragments = 64
+	// maxPartialMessages is the number of messages that can be reassembled at once, from all senders.
+	maxPartialMessages = 256
+	// maxPartialPerSender is the number of messages from a single sender that can be reassembled at once.
+	maxPartialPerSender = 8
+	// fragmentTimeout is how long the fragments of a message are kept before the message is dropped.
+	fragmentTimeout = 10 * time.Second
+)
+
+var (
+	errBadFragment     = errors.New("bad udp fragment")
+	errTooManyPartials = errors.New("too many partial udp messages from sender")
+)
+
+// udpFragment is a part of a udp message, as sent in a single datagram. messages that fit in a single datagram are sent
+// as a single fragment.
+type udpFragment struct {
+	MsgID uint32
+	Index uint16
+	Count uint16
+	Data  []byte
+}
+
+// fragment splits data into fragments of message msgID.
+func fragment(msgID uint32, data []byte) ([]*udpFragment, error) {
+	count := (len(data) + udpFragmentSize - 1) / udpFragmentSize
+	if count == 0 {
+		count = 1
+	}
+	if count > maxUDPFragments {
+		return nil, errors.New("message is too big for udp")
+	}
+	frags := make([]*udpFragment, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * udpFragmentSize
+		if end > len(data) {
+			end = len(data)
+		}
+		frags = append(frags, &udpFragment{MsgID: msgID, Index: uint16(i), Count: uint16(count), Data: data[i*udpFragmentSize : end]})
+	}
+	return frags, nil
+}
+
+type partialKey struct {
+	sender [32]byte
+	msgID  uint32
+}
+
+type partialMessage struct {
+	created  time.Time
+	frags    [][]byte
+	received int
+}
+
+// reassembler collects the fragments of udp messages until all the fragments of a message are received. the number of
+// partial messages and the time they're kept are bounded, so that peers can't exhaust memory with fragments.
+type reassembler struct {
+	mu        sync.Mutex
+	partial   map[partialKey]*partialMessage
+	perSender map[[32]byte]int
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{
+		partial:   make(map[partialKey]*partialMessage),
+		perSender: make(map[[32]byte]int),
+	}
+}
+
+// add adds a fragment received from sender. it returns the message once all of its fragments were received and nil
+// before that.
+func (r *reassembler) add(sender [32]byte, f *udpFragment, now time.Time) ([]byte, error) {
+	if f.Count == 0 || f.Count > maxUDPFragments || f.Index >= f.Count || len(f.Data) > udpFragmentSize {
+		return nil, errBadFragment
+	}
+	if f.Count == 1 {
+		return f.Data, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := partialKey{sender, f.MsgID}
+	pm, ok := r.partial[key]
+	if ok && now.Sub(pm.created) > fragmentTimeout {
+		r.remove(key)
+		ok = false
+	}
+	if !ok {
+		r.evict(now)
+		if r.perSender[sender] >= maxPartialPerSender {
+			return nil, errTooManyPartials
+		}
+		pm = &partialMessage{created: now, frags: make([][]byte, f.Count)}
+		r.partial[key] = pm
+		r.perSender[sender]++
+	}
+	if int(f.Count) != len(pm.frags) {
+		r.remove(key)
+		return nil, errBadFragment
+	}
+	if pm.frags[f.Index] != nil {
+		return nil, nil // duplicate
+	}
+	pm.frags[f.Index] = f.Data
+	pm.received++
+	if pm.received < len(pm.frags) {
+		return nil, nil
+	}
+
+	r.remove(key)
+	var msg []byte
+	for _, d := range pm.frags {
+		msg = append(msg, d...)
+	}
+	return msg, nil
+}
+
+// evict removes the expired partial messages, and the oldest partial message if there's still no room for another one.
+func (r *reassembler) evict(now time.Time) {
+	var oldest partialKey
+	var oldestTime time.Time
+	for k, pm := range r.partial {
+		if now.Sub(pm.created) > fragmentTimeout {
+			r.remove(k)
+			continue
+		}
+		if oldestTime.IsZero() || pm.created.Before(oldestTime) {
+			oldest, oldestTime = k, pm.created
+		}
+	}
+	if len(r.partial) >= maxPartialMessages {
+		r.remove(oldest)
+	}
+}
+
+func (r *reassembler) remove(key partialKey) {
+	if _, ok := r.partial[key]; !ok {
+		return
+	}
+	delete(r.partial, key)
+	r.perSender[key.sender]--
+	if r.perSender[key.sender] == 0 {
+		delete(r.perSender, key.sender)
+	}
+}
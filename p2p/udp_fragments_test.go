@@ -0,0 +1,98 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFragment(t *testing.T) {
+	r := require.New(t)
+
+	frags, err := fragment(1, []byte("small"))
+	r.NoError(err)
+	r.Equal([]*udpFragment{{MsgID: 1, Index: 0, Count: 1, Data: []byte("small")}}, frags)
+
+	data := make([]byte, 2*udpFragmentSize+1)
+	frags, err = fragment(2, data)
+	r.NoError(err)
+	r.Len(frags, 3)
+	r.Len(frags[2].Data, 1)
+
+	_, err = fragment(3, make([]byte, maxUDPFragments*udpFragmentSize+1))
+	r.Error(err)
+}
+
+func TestReassembler_Add(t *testing.T) {
+	r := require.New(t)
+	ra := newReassembler()
+	sender := [32]byte{1}
+	now := time.Now()
+
+	data := make([]byte, 3*udpFragmentSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	frags, err := fragment(1, data)
+	r.NoError(err)
+
+	// out of order and duplicate fragments
+	for _, i := range []int{2, 0, 2} {
+		msg, err := ra.add(sender, frags[i], now)
+		r.NoError(err)
+		r.Nil(msg)
+	}
+	msg, err := ra.add(sender, frags[1], now)
+	r.NoError(err)
+	r.Equal(data, msg)
+	r.Empty(ra.partial)
+	r.Empty(ra.perSender)
+
+	// the same message id from another sender is another message
+	_, err = ra.add(sender, frags[0], now)
+	r.NoError(err)
+	msg, err = ra.add([32]byte{2}, frags[1], now)
+	r.NoError(err)
+	r.Nil(msg)
+	r.Len(ra.partial, 2)
+}
+
+func TestReassembler_Limits(t *testing.T) {
+	r := require.New(t)
+	ra := newReassembler()
+	sender := [32]byte{1}
+	now := time.Now()
+
+	_, err := ra.add(sender, &udpFragment{MsgID: 1, Index: 2, Count: 2}, now)
+	r.Equal(errBadFragment, err)
+	_, err = ra.add(sender, &udpFragment{MsgID: 1, Index: 0, Count: maxUDPFragments + 1}, now)
+	r.Equal(errBadFragment, err)
+	_, err = ra.add(sender, &udpFragment{MsgID: 1, Index: 0, Count: 2, Data: make([]byte, udpFragmentSize+1)}, now)
+	r.Equal(errBadFragment, err)
+
+	for i := 0; i < maxPartialPerSender; i++ {
+		_, err = ra.add(sender, &udpFragment{MsgID: uint32(i), Index: 0, Count: 2}, now)
+		r.NoError(err)
+	}
+	_, err = ra.add(sender, &udpFragment{MsgID: maxPartialPerSender, Index: 0, Count: 2}, now)
+	r.Equal(errTooManyPartials, err)
+
+	// a fragment with another count than the first one drops the message
+	_, err = ra.add(sender, &udpFragment{MsgID: 0, Index: 1, Count: 3}, now)
+	r.Equal(errBadFragment, err)
+	r.Len(ra.partial, maxPartialPerSender-1)
+
+	// expired messages are dropped
+	_, err = ra.add(sender, &udpFragment{MsgID: maxPartialPerSender, Index: 0, Count: 2}, now.Add(fragmentTimeout+time.Second))
+	r.NoError(err)
+	r.Len(ra.partial, 1)
+
+	// the oldest message is dropped when there are too many senders
+	for i := 0; i < maxPartialMessages; i++ {
+		_, err = ra.add([32]byte{2, byte(i)}, &udpFragment{MsgID: 1, Index: 0, Count: 2}, now.Add(fragmentTimeout+2*time.Second))
+		r.NoError(err)
+	}
+	r.Len(ra.partial, maxPartialMessages)
+	r.NotContains(ra.perSender, sender)
+}
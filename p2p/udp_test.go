@@ -197,8 +197,10 @@ func TestUDPMux_ProcessUDP(t *testing.T) {
 
 	themsgbuf, err := types.InterfaceToBytes(msg)
 	require.NoError(t, err)
+	thefragbuf, err := types.InterfaceToBytes(&udpFragment{MsgID: 1, Count: 1, Data: themsgbuf})
+	require.NoError(t, err)
 
-	msgbuf := p2pcrypto.PrependPubkey(themsgbuf, gotfrom.PublicKey())
+	msgbuf := p2pcrypto.PrependPubkey(thefragbuf, gotfrom.PublicKey())
 
 	addr := &net2.UDPAddr{IP: gotfrom.IP, Port: int(gotfrom.DiscoveryPort)}
 
@@ -299,4 +301,20 @@ func Test_RoundTrip(t *testing.T) {
 		t.Fatal("message timeout")
 
 	}
+
+	// a message larger than a datagram is fragmented and reassembled
+	big := make([]byte, 10*udpFragmentSize+1)
+	for i := range big {
+		big[i] = byte(i)
+	}
+	err = m.SendMessage(nd2.PublicKey(), testStr, big)
+	require.NoError(t, err)
+	tm = time.NewTimer(time.Second)
+
+	select {
+	case msg := <-c2:
+		require.Equal(t, big, msg.Bytes())
+	case <-tm.C:
+		t.Fatal("message timeout")
+	}
 }
@@ -56,3 +56,45 @@ func CheckNodeVersion(reqVersion string, minVersion string) (bool, error) {
 
 	return false, nil
 }
+
+// Parse returns the numbers of a "major.minor.patch" version, optionally prefixed by the client name as in
+// "go-spacemesh/0.1.0".
+func Parse(ver string) ([3]uint64, error) {
+	var nums [3]uint64
+	if i := strings.LastIndex(ver, "/"); i >= 0 {
+		ver = ver[i+1:]
+	}
+	split := strings.Split(ver, ".")
+	if len(split) != 3 {
+		return nums, fmt.Errorf("invalid version %q", ver)
+	}
+	for i, s := range split {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nums, fmt.Errorf("invalid version %q", ver)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// Compare returns -1, 0 or 1 when version a is lower than, equal to or higher than version b. Client names are ignored.
+func Compare(a, b string) (int, error) {
+	numa, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	numb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range numa {
+		if numa[i] < numb[i] {
+			return -1, nil
+		}
+		if numa[i] > numb[i] {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
@@ -72,3 +72,24 @@ func TestCheckNodeVersion(t *testing.T) {
 	}
 
 }
+
+func TestCompare(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		res  int
+	}{
+		{"0.0.1", "0.0.1", 0},
+		{"go-spacemesh/0.1.2", "0.1.2", 0},
+		{"0.1.2", "0.1.3", -1},
+		{"1.0.0", "0.9.9", 1},
+		{"someclient/0.10.0", "otherclient/0.9.0", 1},
+	} {
+		res, err := Compare(tc.a, tc.b)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.res, res, "%v vs %v", tc.a, tc.b)
+	}
+	for _, bad := range []string{"", "1.0", "1.x.0", "1.-1.0", "client/1.0.0.0"} {
+		_, err := Compare(bad, "0.0.1")
+		assert.Error(t, err, bad)
+	}
+}
@@ -62,6 +62,15 @@ func (pq *Queue) Write(prio Priority, m interface{}) error {
 	return nil
 }
 
+// Lengths returns the number of messages waiting in the queue of each priority, indexed by priority
+func (pq *Queue) Lengths() []int {
+	lens := make([]int, len(pq.queues))
+	for i, q := range pq.queues {
+		lens[i] = len(q)
+	}
+	return lens
+}
+
 // Read returns the next message by priority
 // An error is set iff the priority queue has been closed
 func (pq *Queue) Read() (interface{}, error) {
@@ -90,22 +90,25 @@ func TestPriorityQ_Read(t *testing.T) {
 	rg.Add(1)
 	i := 0
 	go func() {
+		defer rg.Done()
 		m, e := pq.Read()
 		for e == nil {
 			prio, ok := m.(int)
 			//fmt.Println("reading  ", m, e, i, len(pq.queues[0]), len(pq.queues[1]))
 			if !ok {
 				// should never happen
-				t.FailNow()
+				t.Errorf("read a message of type %T", m)
+				return
 			}
 
-			r.False(prio < maxPrio)
+			if prio < maxPrio {
+				t.Errorf("read priority %v after %v", prio, maxPrio)
+			}
 			maxPrio = prio
 			i++
 
 			m, e = pq.Read()
 		}
-		rg.Done()
 	}()
 
 	time.Sleep(1500 * time.Millisecond)
@@ -0,0 +1,184 @@
+package replication
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/state"
+)
+
+const (
+	dialTimeout   = 10 * time.Second
+	retryInterval = 5 * time.Second
+)
+
+type replicaMesh interface {
+	AddReplicatedLayer(layer types.LayerID, blocks []*types.Block, txs []*types.Transaction) error
+	LatestLayerInState() types.LayerID
+}
+
+type atxStore interface {
+	StoreAtx(ech types.EpochID, atx *types.ActivationTx) error
+}
+
+type replicaState interface {
+	ApplyAccountStates(layer types.LayerID, accounts []state.AccountUpdate) (types.Hash32, error)
+}
+
+// Follower replicates the layers streamed by a leader into the node's databases. It resumes from the layer after the
+// latest layer in state and reconnects to the leader until closed.
+type Follower struct {
+	leader         string
+	mesh           replicaMesh
+	atxs           atxStore
+	state          replicaState
+	layersPerEpoch uint16
+	lag            uint64
+	mu             sync.Mutex
+	conn           net.Conn
+	exit           chan struct{}
+	done           chan struct{}
+	log            log.Log
+}
+
+// NewFollower returns a follower that replicates the layers of the leader at the given address.
+func NewFollower(leader string, msh replicaMesh, atxs atxStore, st replicaState, layersPerEpoch uint16, logger log.Log) *Follower {
+	return &Follower{
+		leader:         leader,
+		mesh:           msh,
+		atxs:           atxs,
+		state:          st,
+		layersPerEpoch: layersPerEpoch,
+		exit:           make(chan struct{}),
+		done:           make(chan struct{}),
+		log:            logger,
+	}
+}
+
+// Start starts replicating in the background.
+func (f *Follower) Start() {
+	go f.run()
+}
+
+// Close stops replicating.
+func (f *Follower) Close() {
+	close(f.exit)
+	f.mu.Lock()
+	if f.conn != nil {
+		f.conn.Close()
+	}
+	f.mu.Unlock()
+	<-f.done
+}
+
+// Lag returns the number of layers the leader applied that were not replicated yet, as of the last replicated layer.
+func (f *Follower) Lag() uint64 {
+	return atomic.LoadUint64(&f.lag)
+}
+
+func (f *Follower) run() {
+	defer close(f.done)
+	for {
+		err := f.follow()
+		select {
+		case <-f.exit:
+			return
+		default:
+		}
+		f.log.With().Warning("lost connection to replication leader", log.String("leader", f.leader), log.Err(err))
+		select {
+		case <-f.exit:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (f *Follower) follow() error {
+	conn, err := net.DialTimeout("tcp", f.leader, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	f.mu.Lock()
+	select {
+	case <-f.exit:
+		f.mu.Unlock()
+		return nil
+	default:
+	}
+	f.conn = conn
+	f.mu.Unlock()
+
+	from := f.mesh.LatestLayerInState() + 1
+	if err := writeMessage(conn, &hello{Version: ProtocolVersion, FromLayer: from}); err != nil {
+		return fmt.Errorf("failed to send hello: %v", err)
+	}
+	var w welcome
+	if err := readMessage(conn, &w); err != nil {
+		return fmt.Errorf("failed to read welcome: %v", err)
+	}
+	if w.Error != "" {
+		return fmt.Errorf("leader refused replication (protocol version %d): %v", w.Version, w.Error)
+	}
+	f.log.With().Info("replicating from leader", log.String("leader", f.leader), log.LayerID(from.Uint64()))
+
+	for {
+		var update LayerUpdate
+		if err := readMessage(conn, &update); err != nil {
+			return fmt.Errorf("failed to read layer: %v", err)
+		}
+		if err := f.apply(&update); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *Follower) apply(update *LayerUpdate) error {
+	if expected := f.mesh.LatestLayerInState() + 1; update.Layer != expected {
+		return fmt.Errorf("received layer %v, expected %v", update.Layer, expected)
+	}
+	for i := range update.Atxs {
+		atx := &update.Atxs[i]
+		atx.CalcAndSetID()
+		if err := f.atxs.StoreAtx(atx.PubLayerID.GetEpoch(f.layersPerEpoch), atx); err != nil {
+			return fmt.Errorf("failed to store atx %v: %v", atx.ShortString(), err)
+		}
+	}
+	for _, tx := range update.Txs {
+		if err := tx.CalcAndSetOrigin(); err != nil {
+			return fmt.Errorf("failed to calc transaction origin (id: %s): %v", tx.ID().ShortString(), err)
+		}
+	}
+	blocks := make([]*types.Block, len(update.Blocks))
+	for i := range update.Blocks {
+		update.Blocks[i].Initialize()
+		blocks[i] = &update.Blocks[i]
+	}
+
+	root, err := f.state.ApplyAccountStates(update.Layer, update.Accounts)
+	if err != nil {
+		return fmt.Errorf("failed to apply state of layer %v: %v", update.Layer, err)
+	}
+	if root != update.StateRoot {
+		return fmt.Errorf("state root mismatch in layer %v: got %v, leader has %v", update.Layer, root.ShortString(),
+			update.StateRoot.ShortString())
+	}
+	if err := f.mesh.AddReplicatedLayer(update.Layer, blocks, update.Txs); err != nil {
+		return fmt.Errorf("failed to add layer %v: %v", update.Layer, err)
+	}
+
+	var lag uint64
+	if update.LeaderLayer > update.Layer {
+		lag = uint64(update.LeaderLayer - update.Layer)
+	}
+	atomic.StoreUint64(&f.lag, lag)
+	f.log.With().Info("replicated layer", log.LayerID(update.Layer.Uint64()), log.Uint64("lag", lag),
+		log.String("state_root", root.ShortString()))
+	return nil
+}
@@ -0,0 +1,286 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/state"
+)
+
+var latestKey = []byte("latest")
+
+type meshSource interface {
+	GetTransactions(transactions []types.TransactionID) ([]*types.Transaction, map[types.TransactionID]struct{})
+	GetFullAtx(id types.ATXID) (*types.ActivationTx, error)
+}
+
+type stateSource interface {
+	GetBalance(addr types.Address) uint64
+	GetNonce(addr types.Address) uint64
+	GetStateRoot() types.Hash32
+}
+
+// Leader records every layer that the node applies to state and streams the recorded layers to followers. It must be
+// set as the mesh's state observer (see mesh.Mesh.AddStateObserver). Followers can only replicate layers that were
+// applied while the leader was recording.
+type Leader struct {
+	listen   string
+	db       database.Database
+	mesh     meshSource
+	state    stateSource
+	listener net.Listener
+	mu       sync.Mutex
+	latest   types.LayerID
+	updated  chan struct{}
+	conns    map[net.Conn]struct{}
+	exit     chan struct{}
+	wg       sync.WaitGroup
+	log      log.Log
+}
+
+// NewLeader returns a leader that records layers in db and serves followers on the listen address.
+func NewLeader(listen string, db database.Database, msh meshSource, st stateSource, logger log.Log) *Leader {
+	l := &Leader{
+		listen:  listen,
+		db:      db,
+		mesh:    msh,
+		state:   st,
+		updated: make(chan struct{}),
+		conns:   make(map[net.Conn]struct{}),
+		exit:    make(chan struct{}),
+		log:     logger,
+	}
+	if bytes, err := db.Get(latestKey); err == nil {
+		l.latest = types.LayerID(util.BytesToUint64(bytes))
+	}
+	return l
+}
+
+// LayerApplied records the update of a layer that was applied to state. The state must not change before it returns.
+func (l *Leader) LayerApplied(layer *types.Layer) {
+	update, err := l.buildUpdate(layer)
+	if err != nil {
+		l.log.With().Error("failed to build replication update", log.LayerID(layer.Index().Uint64()), log.Err(err))
+		return
+	}
+	bytes, err := types.InterfaceToBytes(update)
+	if err != nil {
+		l.log.With().Error("failed to serialize replication update", log.LayerID(layer.Index().Uint64()), log.Err(err))
+		return
+	}
+	batch := l.db.NewBatch()
+	if err := batch.Put(layer.Index().Bytes(), bytes); err != nil {
+		l.log.With().Error("failed to store replication update", log.LayerID(layer.Index().Uint64()), log.Err(err))
+		return
+	}
+	if err := batch.Put(latestKey, layer.Index().Bytes()); err != nil {
+		l.log.With().Error("failed to store replication update", log.LayerID(layer.Index().Uint64()), log.Err(err))
+		return
+	}
+	if err := batch.Write(); err != nil {
+		l.log.With().Error("failed to store replication update", log.LayerID(layer.Index().Uint64()), log.Err(err))
+		return
+	}
+
+	l.mu.Lock()
+	l.latest = layer.Index()
+	close(l.updated)
+	l.updated = make(chan struct{})
+	l.mu.Unlock()
+	l.log.With().Debug("recorded layer for replication", log.LayerID(layer.Index().Uint64()),
+		log.Int("num_accounts", len(update.Accounts)))
+}
+
+func (l *Leader) buildUpdate(layer *types.Layer) (*LayerUpdate, error) {
+	update := &LayerUpdate{Layer: layer.Index(), StateRoot: l.state.GetStateRoot()}
+	touched := make(map[types.Address]struct{})
+	seenTxs := make(map[types.TransactionID]struct{})
+	atxs := make(map[types.ATXID]*types.ActivationTx)
+	var txIDs []types.TransactionID
+	for _, blk := range layer.Blocks() {
+		update.Blocks = append(update.Blocks, *blk)
+		for _, id := range blk.TxIDs {
+			if _, ok := seenTxs[id]; !ok {
+				seenTxs[id] = struct{}{}
+				txIDs = append(txIDs, id)
+			}
+		}
+		atxIDs := blk.ATXIDs
+		if blk.ATXID != *types.EmptyATXID {
+			atxIDs = append([]types.ATXID{blk.ATXID}, atxIDs...)
+		}
+		for _, id := range atxIDs {
+			atx, ok := atxs[id]
+			if !ok {
+				var err error
+				if atx, err = l.mesh.GetFullAtx(id); err != nil {
+					return nil, fmt.Errorf("failed to get atx %v: %v", id.ShortString(), err)
+				}
+				atxs[id] = atx
+				update.Atxs = append(update.Atxs, *atx)
+			}
+			if id == blk.ATXID {
+				// the block's miner is rewarded for the layer
+				touched[atx.Coinbase] = struct{}{}
+			}
+		}
+	}
+	txs, missing := l.mesh.GetTransactions(txIDs)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%d transactions are missing", len(missing))
+	}
+	for _, tx := range txs {
+		touched[tx.Origin()] = struct{}{}
+		touched[tx.Recipient] = struct{}{}
+	}
+	update.Txs = txs
+	for addr := range touched {
+		update.Accounts = append(update.Accounts, state.AccountUpdate{
+			Address: addr,
+			Balance: l.state.GetBalance(addr),
+			Nonce:   l.state.GetNonce(addr),
+		})
+	}
+	return update, nil
+}
+
+// Start listens for followers.
+func (l *Leader) Start() error {
+	listener, err := net.Listen("tcp", l.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %v", l.listen, err)
+	}
+	l.listener = listener
+	l.log.With().Info("serving read replicas", log.String("address", listener.Addr().String()),
+		log.LayerID(l.latestLayer().Uint64()))
+	l.wg.Add(1)
+	go l.acceptLoop()
+	return nil
+}
+
+// Addr returns the address the leader listens on, once started.
+func (l *Leader) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Close disconnects all followers and stops listening.
+func (l *Leader) Close() {
+	close(l.exit)
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	l.mu.Lock()
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+}
+
+func (l *Leader) latestLayer() types.LayerID {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.latest
+}
+
+func (l *Leader) acceptLoop() {
+	defer l.wg.Done()
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			select {
+			case <-l.exit:
+			default:
+				l.log.With().Error("failed to accept follower", log.Err(err))
+			}
+			return
+		}
+		l.mu.Lock()
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			follower := conn.RemoteAddr().String()
+			if err := l.serve(conn); err != nil {
+				l.log.With().Warning("stopped serving follower", log.String("follower", follower), log.Err(err))
+			}
+			l.mu.Lock()
+			delete(l.conns, conn)
+			l.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (l *Leader) serve(conn net.Conn) error {
+	var h hello
+	if err := readMessage(conn, &h); err != nil {
+		return fmt.Errorf("failed to read hello: %v", err)
+	}
+	if h.Version != ProtocolVersion {
+		err := fmt.Errorf("unsupported protocol version %d, expected %d", h.Version, ProtocolVersion)
+		return l.reject(conn, err)
+	}
+	if h.FromLayer <= l.latestLayer() {
+		if _, err := l.db.Get(h.FromLayer.Bytes()); err != nil {
+			return l.reject(conn, fmt.Errorf("layer %v is not available for replication", h.FromLayer))
+		}
+	}
+	if err := writeMessage(conn, &welcome{Version: ProtocolVersion}); err != nil {
+		return fmt.Errorf("failed to send welcome: %v", err)
+	}
+	l.log.With().Info("serving follower", log.String("follower", conn.RemoteAddr().String()),
+		log.LayerID(h.FromLayer.Uint64()))
+
+	for layer := h.FromLayer; ; layer++ {
+		latest, err := l.waitFor(layer)
+		if err != nil {
+			return err
+		}
+		bytes, err := l.db.Get(layer.Bytes())
+		if err != nil {
+			return fmt.Errorf("layer %v is not available for replication: %v", layer, err)
+		}
+		var update LayerUpdate
+		if err := types.BytesToInterface(bytes, &update); err != nil {
+			return fmt.Errorf("failed to deserialize layer %v: %v", layer, err)
+		}
+		update.LeaderLayer = latest
+		if err := writeMessage(conn, &update); err != nil {
+			return fmt.Errorf("failed to send layer %v: %v", layer, err)
+		}
+		l.log.With().Debug("sent layer to follower", log.String("follower", conn.RemoteAddr().String()),
+			log.LayerID(layer.Uint64()), log.Uint64("lag", uint64(latest-layer)))
+	}
+}
+
+// waitFor blocks until layer was recorded and returns the latest recorded layer.
+func (l *Leader) waitFor(layer types.LayerID) (types.LayerID, error) {
+	for {
+		l.mu.Lock()
+		latest, updated := l.latest, l.updated
+		l.mu.Unlock()
+		if layer <= latest {
+			return latest, nil
+		}
+		select {
+		case <-updated:
+		case <-l.exit:
+			return 0, errors.New("leader closed")
+		}
+	}
+}
+
+func (l *Leader) reject(conn net.Conn, reason error) error {
+	if err := writeMessage(conn, &welcome{Version: ProtocolVersion, Error: reason.Error()}); err != nil {
+		return fmt.Errorf("failed to reject follower (%v): %v", reason, err)
+	}
+	return reason
+}
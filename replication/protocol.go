@@ -0,0 +1,76 @@
+// Package replication streams the layers a leader node applies to state to read replicas over a trusted TCP channel.
+// Followers store the blocks, transactions and ATXs of every layer without validating them and overwrite the accounts
+// touched by the layer, so that they can serve the API without taking part in the p2p network.
+package replication
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/state"
+)
+
+// ProtocolVersion is the version of the replication protocol. Leaders refuse followers that speak another version.
+const ProtocolVersion uint32 = 1
+
+// maxMessageSize limits the size of a single message, to protect against corrupt length prefixes.
+const maxMessageSize = 64 << 20
+
+// hello is sent by a follower when it connects, requesting the layers starting at FromLayer.
+type hello struct {
+	Version   uint32
+	FromLayer types.LayerID
+}
+
+// welcome is the leader's reply to hello. A non empty Error means the leader won't serve the follower.
+type welcome struct {
+	Version uint32
+	Error   string
+}
+
+// LayerUpdate holds everything a follower needs to replicate a layer that the leader applied to state.
+type LayerUpdate struct {
+	Layer types.LayerID
+	// Blocks are the valid blocks of the layer.
+	Blocks []types.Block
+	// Txs are the transactions of the valid blocks.
+	Txs []*types.Transaction
+	// Atxs are the ATXs referenced by the valid blocks.
+	Atxs []types.ActivationTx
+	// Accounts are the accounts touched by the layer, as they were after the layer was applied.
+	Accounts  []state.AccountUpdate
+	StateRoot types.Hash32
+	// LeaderLayer is the latest layer the leader applied when it sent the update, used for lag reporting.
+	LeaderLayer types.LayerID
+}
+
+// writeMessage writes msg prefixed by its length.
+func writeMessage(w io.Writer, msg interface{}) error {
+	bytes, err := types.InterfaceToBytes(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message: %v", err)
+	}
+	buf := make([]byte, 4, 4+len(bytes))
+	binary.BigEndian.PutUint32(buf, uint32(len(bytes)))
+	_, err = w.Write(append(buf, bytes...))
+	return err
+}
+
+// readMessage reads a message written by writeMessage into msg.
+func readMessage(r io.Reader, msg interface{}) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxMessageSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum of %d", length, maxMessageSize)
+	}
+	bytes := make([]byte, length)
+	if _, err := io.ReadFull(r, bytes); err != nil {
+		return err
+	}
+	return types.BytesToInterface(bytes, msg)
+}
@@ -0,0 +1,158 @@
+package replication
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/go-spacemesh/state"
+	"github.com/stretchr/testify/require"
+)
+
+type meshMock struct {
+	mu     sync.Mutex
+	txs    map[types.TransactionID]*types.Transaction
+	latest types.LayerID
+	blocks map[types.LayerID][]*types.Block
+}
+
+func newMeshMock() *meshMock {
+	return &meshMock{txs: make(map[types.TransactionID]*types.Transaction), blocks: make(map[types.LayerID][]*types.Block)}
+}
+
+func (m *meshMock) GetTransactions(ids []types.TransactionID) ([]*types.Transaction, map[types.TransactionID]struct{}) {
+	var txs []*types.Transaction
+	missing := make(map[types.TransactionID]struct{})
+	for _, id := range ids {
+		if tx, ok := m.txs[id]; ok {
+			txs = append(txs, tx)
+		} else {
+			missing[id] = struct{}{}
+		}
+	}
+	return txs, missing
+}
+
+func (m *meshMock) GetFullAtx(types.ATXID) (*types.ActivationTx, error) {
+	return nil, errors.New("not found")
+}
+
+func (m *meshMock) AddReplicatedLayer(layer types.LayerID, blocks []*types.Block, txs []*types.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tx := range txs {
+		m.txs[tx.ID()] = tx
+	}
+	m.blocks[layer] = blocks
+	m.latest = layer
+	return nil
+}
+
+func (m *meshMock) LatestLayerInState() types.LayerID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latest
+}
+
+type atxStoreMock struct{}
+
+func (atxStoreMock) StoreAtx(types.EpochID, *types.ActivationTx) error { return nil }
+
+func newProcessor() *state.TransactionProcessor {
+	db := database.NewMemDatabase()
+	return state.NewTransactionProcessor(db, db, nil, log.NewDefault("state"))
+}
+
+func waitForLayer(r *require.Assertions, msh *meshMock, layer types.LayerID) {
+	deadline := time.Now().Add(5 * time.Second)
+	for msh.LatestLayerInState() < layer {
+		r.True(time.Now().Before(deadline), "timed out waiting for layer %v", layer)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	r := require.New(t)
+	leaderMesh := newMeshMock()
+	leaderState := newProcessor()
+	leader := NewLeader("127.0.0.1:0", database.NewMemDatabase(), leaderMesh, leaderState, log.NewDefault("leader"))
+	r.NoError(leader.Start())
+	defer leader.Close()
+
+	signer := signing.NewEdSigner()
+	origin := types.BytesToAddress(signer.PublicKey().Bytes())
+	recipient := types.BytesToAddress([]byte{0x01})
+	leaderState.SetBalance(origin, big.NewInt(100))
+	_, err := leaderState.ApplyTransactions(1, nil)
+	r.NoError(err)
+	leader.LayerApplied(types.NewExistingLayer(1, nil))
+
+	tx, err := mesh.NewSignedTx(0, recipient, 10, 100, 1, signer)
+	r.NoError(err)
+	leaderMesh.txs[tx.ID()] = tx
+	blk := types.NewExistingBlock(2, []byte("data"))
+	blk.TxIDs = []types.TransactionID{tx.ID()}
+	blk.Initialize()
+	_, err = leaderState.ApplyTransactions(2, []*types.Transaction{tx})
+	r.NoError(err)
+	leader.LayerApplied(types.NewExistingLayer(2, []*types.Block{blk}))
+
+	replicaMesh := newMeshMock()
+	replicaState := newProcessor()
+	follower := NewFollower(leader.Addr().String(), replicaMesh, atxStoreMock{}, replicaState, 3, log.NewDefault("follower"))
+	follower.Start()
+	defer follower.Close()
+
+	waitForLayer(r, replicaMesh, 2)
+	r.Equal(leaderState.GetStateRoot(), replicaState.GetStateRoot())
+	r.Equal(uint64(10), replicaState.GetBalance(recipient))
+	r.Equal(uint64(1), replicaState.GetNonce(origin))
+	r.Len(replicaMesh.blocks[2], 1)
+	r.Equal(blk.ID(), replicaMesh.blocks[2][0].ID())
+	r.Equal(uint64(0), follower.Lag())
+
+	// layers applied after the follower caught up are streamed
+	_, err = leaderState.ApplyTransactions(3, nil)
+	r.NoError(err)
+	leader.LayerApplied(types.NewExistingLayer(3, nil))
+	waitForLayer(r, replicaMesh, 3)
+}
+
+func TestLeader_RejectsFollower(t *testing.T) {
+	r := require.New(t)
+	leaderState := newProcessor()
+	leader := NewLeader("127.0.0.1:0", database.NewMemDatabase(), newMeshMock(), leaderState, log.NewDefault("leader"))
+	r.NoError(leader.Start())
+	defer leader.Close()
+	_, err := leaderState.ApplyTransactions(5, nil)
+	r.NoError(err)
+	leader.LayerApplied(types.NewExistingLayer(5, nil))
+
+	handshake := func(h hello) welcome {
+		conn, err := net.Dial("tcp", leader.Addr().String())
+		r.NoError(err)
+		defer conn.Close()
+		r.NoError(writeMessage(conn, &h))
+		var w welcome
+		r.NoError(readMessage(conn, &w))
+		return w
+	}
+
+	w := handshake(hello{Version: ProtocolVersion + 1, FromLayer: 5})
+	r.Equal(ProtocolVersion, w.Version)
+	r.Equal("unsupported protocol version 2, expected 1", w.Error)
+
+	w = handshake(hello{Version: ProtocolVersion, FromLayer: 1})
+	r.Equal("layer 1 is not available for replication", w.Error)
+
+	w = handshake(hello{Version: ProtocolVersion, FromLayer: 5})
+	r.Empty(w.Error)
+}
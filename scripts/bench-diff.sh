@@ -0,0 +1,30 @@
+#!/bin/bash -e
+# Runs the hot path benchmarks on a base revision and on the working tree, and fails if a benchmark regressed by more
+# than its threshold in scripts/bench-thresholds.json (see cmd/benchdiff).
+#
+# Usage: scripts/bench-diff.sh [base revision, default develop]
+
+BASE=${1:-develop}
+ROOT=$(git rev-parse --show-toplevel)
+PKGS="./activation ./common/types ./signing ./state"
+PATTERN='^Benchmark(DB_|Block_|Verify|TransactionProcessor_)'
+OUT=$(mktemp -d)
+BASE_TREE="$OUT/base"
+
+cleanup() {
+    git -C "$ROOT" worktree remove --force "$BASE_TREE" 2>/dev/null || true
+    rm -rf "$OUT"
+}
+trap cleanup EXIT
+
+run_benchmarks() {
+    (cd "$1" && go test -run='^$' -bench="$PATTERN" -benchmem -count=5 $PKGS) > "$2"
+}
+
+git -C "$ROOT" worktree add --detach "$BASE_TREE" "$BASE"
+echo "running benchmarks on $BASE"
+run_benchmarks "$BASE_TREE" "$OUT/old.txt"
+echo "running benchmarks on the working tree"
+run_benchmarks "$ROOT" "$OUT/new.txt"
+
+go run "$ROOT/cmd/benchdiff" -thresholds "$ROOT/scripts/bench-thresholds.json" "$OUT/old.txt" "$OUT/new.txt"
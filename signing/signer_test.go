@@ -50,3 +50,17 @@ func TestPublicKey_ShortString(t *testing.T) {
 	pub = NewPublicKey([]byte{1, 2})
 	assert.Equal(t, pub.String(), pub.ShortString())
 }
+
+func BenchmarkVerify(b *testing.B) {
+	ed := NewEdSigner()
+	m := make([]byte, 256)
+	rand.Read(m)
+	sig := ed.Sign(m)
+	pub := ed.PublicKey()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Verify(pub, m, sig) {
+			b.Fatal("signature not verified")
+		}
+	}
+}
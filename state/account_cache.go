@@ -0,0 +1,104 @@
+package state
+
+import (
+	"sync"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// DefaultAccountCacheSize is the default number of accounts whose state TransactionProcessor caches for reads.
+const DefaultAccountCacheSize = 10000
+
+// cachedAccount is the state of an account as read from the global state. Accounts that don't exist are cached too,
+// so that transactions of nonexistent accounts don't cost a trie lookup each.
+type cachedAccount struct {
+	exists  bool
+	balance uint64
+	nonce   uint64
+}
+
+// accountCache caches the accounts read by mempool validation and the API between state changes. It's purged when
+// the state starts and ends changing, and reads that overlap a change aren't cached, so it never serves an account
+// older than the last change.
+type accountCache struct {
+	mu         sync.Mutex
+	cache      *lru.Cache
+	generation uint64
+	updating   int
+}
+
+func newAccountCache(size int) *accountCache {
+	cache, err := lru.New(size)
+	if err != nil {
+		log.Panic("could not initialize account cache: %v", err)
+	}
+	return &accountCache{cache: cache}
+}
+
+// get returns the cached account of addr and the generation of the cache, which must be passed to add when the account
+// is not cached.
+func (c *accountCache) get(addr types.Address) (cachedAccount, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, ok := c.cache.Get(addr); ok {
+		return item.(cachedAccount), c.generation, true
+	}
+	return cachedAccount{}, c.generation, false
+}
+
+// add caches the account of addr that was read in generation, unless the state changed since.
+func (c *accountCache) add(addr types.Address, acc cachedAccount, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.updating > 0 || generation != c.generation {
+		return
+	}
+	c.cache.Add(addr, acc)
+}
+
+// startUpdate invalidates the cache before the state changes, until endUpdate is called.
+func (c *accountCache) startUpdate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updating++
+	c.generation++
+	c.cache.Purge()
+}
+
+// endUpdate invalidates the cache after the state changed.
+func (c *accountCache) endUpdate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updating--
+	c.generation++
+	c.cache.Purge()
+}
+
+// account returns the state of addr, from the cache if it was read since the state last changed.
+func (tp *TransactionProcessor) account(addr types.Address) cachedAccount {
+	acc, generation, ok := tp.accounts.get(addr)
+	if ok {
+		return acc
+	}
+	acc = cachedAccount{exists: tp.DB.Exist(addr)}
+	if acc.exists {
+		acc.balance = tp.DB.GetBalance(addr)
+		acc.nonce = tp.DB.GetNonce(addr)
+	}
+	tp.accounts.add(addr, acc, generation)
+	return acc
+}
+
+// GetBalance returns the balance of addr in the current state, or 0 if it doesn't exist. It's served from a cache
+// that's invalidated whenever a layer is applied.
+func (tp *TransactionProcessor) GetBalance(addr types.Address) uint64 {
+	return tp.account(addr).balance
+}
+
+// GetNonce returns the nonce of addr in the current state, or 0 if it doesn't exist. It's served from a cache that's
+// invalidated whenever a layer is applied.
+func (tp *TransactionProcessor) GetNonce(addr types.Address) uint64 {
+	return tp.account(addr).nonce
+}
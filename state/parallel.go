@@ -0,0 +1,217 @@
+package state
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/events"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// defaultParallelMinTxs is the number of transactions a layer must have for ApplyTransactions to apply them in
+// parallel, below it the overhead of grouping them outweighs the gain.
+const defaultParallelMinTxs = 64
+
+// groupIndependentTxs splits txs into groups such that no account is touched, as origin or recipient, by transactions
+// of two groups. Groups are ordered by their first transaction and keep the order of txs. Applying every group on its
+// own, in order, yields the same state as applying txs in order, since a transaction's outcome only depends on the
+// accounts it touches.
+func groupIndependentTxs(txs []*types.Transaction) [][]*types.Transaction {
+	parent := make(map[types.Address]types.Address)
+	var find func(addr types.Address) types.Address
+	find = func(addr types.Address) types.Address {
+		p, ok := parent[addr]
+		if !ok {
+			parent[addr] = addr
+			return addr
+		}
+		if p == addr {
+			return addr
+		}
+		root := find(p)
+		parent[addr] = root
+		return root
+	}
+	for _, tx := range txs {
+		origin, recipient := find(tx.Origin()), find(tx.Recipient)
+		if origin != recipient {
+			parent[recipient] = origin
+		}
+	}
+
+	var groups [][]*types.Transaction
+	index := make(map[types.Address]int)
+	for _, tx := range txs {
+		root := find(tx.Origin())
+		i, ok := index[root]
+		if !ok {
+			i = len(groups)
+			index[root] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], tx)
+	}
+	return groups
+}
+
+// simAccount is the state of an account while a group of transactions is applied to it.
+type simAccount struct {
+	exists  bool
+	balance *big.Int
+	nonce   uint64
+	dirty   bool
+}
+
+type txAttempt struct {
+	tx  *types.Transaction
+	err error
+}
+
+// txGroup applies a group of independent transactions to a copy of the accounts they touch, so that groups can be
+// applied concurrently. The results are written to the global state afterwards, see TransactionProcessor.commitGroup.
+type txGroup struct {
+	txs       []*types.Transaction
+	accounts  map[types.Address]*simAccount
+	attempts  []txAttempt
+	applied   []*types.Transaction
+	remaining int
+}
+
+func (g *txGroup) account(tp *TransactionProcessor, addr types.Address) *simAccount {
+	if acc, ok := g.accounts[addr]; ok {
+		return acc
+	}
+	acc := &simAccount{balance: new(big.Int)}
+	// getStateObj is safe for concurrent use, and the groups read disjoint accounts
+	if obj := tp.getStateObj(addr); obj != nil {
+		acc.exists = true
+		acc.balance.Set(obj.Balance())
+		acc.nonce = obj.Nonce()
+	}
+	g.accounts[addr] = acc
+	return acc
+}
+
+// process applies the transactions of the group like ApplyTransactions does: it retries the ones that failed until
+// none of them applies.
+func (g *txGroup) process(tp *TransactionProcessor) {
+	g.accounts = make(map[types.Address]*simAccount)
+	remaining := g.txs
+	for {
+		var failed []*types.Transaction
+		for _, tx := range remaining {
+			err := g.apply(tp, tx)
+			if err != nil {
+				failed = append(failed, tx)
+			}
+			g.attempts = append(g.attempts, txAttempt{tx: tx, err: err})
+		}
+		if len(failed) == len(remaining) {
+			break
+		}
+		remaining = failed
+	}
+	g.remaining = len(remaining)
+}
+
+// apply mirrors TransactionProcessor.ApplyTransaction on the accounts of the group.
+func (g *txGroup) apply(tp *TransactionProcessor, tx *types.Transaction) error {
+	origin := g.account(tp, tx.Origin())
+	if !origin.exists {
+		return fmt.Errorf(errOrigin)
+	}
+
+	amountWithFee := tx.Fee + tx.Amount
+	if origin.balance.Uint64() <= amountWithFee {
+		return fmt.Errorf(errFunds)
+	}
+	if origin.nonce != tx.AccountNonce {
+		return fmt.Errorf(errNonce)
+	}
+
+	origin.nonce++
+	origin.balance = new(big.Int).Sub(origin.balance, new(big.Int).SetUint64(tx.Amount))
+	origin.dirty = true
+	recipient := g.account(tp, tx.Recipient)
+	recipient.balance = new(big.Int).Add(recipient.balance, new(big.Int).SetUint64(tx.Amount))
+	recipient.exists = true
+	recipient.dirty = true
+	origin.balance = new(big.Int).Sub(origin.balance, new(big.Int).SetUint64(tx.Fee))
+	g.applied = append(g.applied, tx)
+	return nil
+}
+
+// processParallel applies the groups of independent transactions concurrently and writes their results to the global
+// state in the order of the groups. It returns the number of transactions that failed to apply.
+func (tp *TransactionProcessor) processParallel(groups [][]*types.Transaction, layerID types.LayerID) (int, error) {
+	txGroups := make([]*txGroup, len(groups))
+	work := make(chan *txGroup)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range work {
+				g.process(tp)
+			}
+		}()
+	}
+	for i, txs := range groups {
+		txGroups[i] = &txGroup{txs: txs}
+		work <- txGroups[i]
+	}
+	close(work)
+	wg.Wait()
+
+	remaining := 0
+	for _, g := range txGroups {
+		if err := tp.commitGroup(g, layerID); err != nil {
+			return remaining, err
+		}
+		remaining += g.remaining
+	}
+	return remaining, nil
+}
+
+// commitGroup writes the accounts that the group changed to the global state, marks its applied transactions and
+// publishes the events of its attempts, like Process does.
+func (tp *TransactionProcessor) commitGroup(g *txGroup, layerID types.LayerID) error {
+	for addr, acc := range g.accounts {
+		if !acc.dirty {
+			continue
+		}
+		tp.SetBalance(addr, acc.balance)
+		tp.SetNonce(addr, acc.nonce)
+	}
+	for _, tx := range g.applied {
+		if err := tp.processorDb.Put(tx.ID().Bytes(), layerID.Bytes()); err != nil {
+			return fmt.Errorf("failed to add to applied txs: %v", err)
+		}
+		tp.With().Info("transaction processed", log.String("transaction", tx.String()))
+	}
+	for _, attempt := range g.attempts {
+		tx, err := attempt.tx, attempt.err
+		if err != nil {
+			tp.With().Warning("failed to apply transaction", log.TxID(tx.ID().ShortString()), log.Err(err))
+		}
+		events.Publish(events.ValidTx{ID: tx.ID().String(), Valid: err == nil})
+		events.Publish(events.NewTx{
+			ID:          tx.ID().String(),
+			Origin:      tx.Origin().String(),
+			Destination: tx.Recipient.String(),
+			Amount:      tx.Amount,
+			Fee:         tx.Fee})
+		events.Publish(events.TxReceipt{
+			ID:          tx.ID().String(),
+			Origin:      tx.Origin().String(),
+			Destination: tx.Recipient.String(),
+			Amount:      tx.Amount,
+			Fee:         tx.Fee,
+			Layer:       layerID.Uint64(),
+			Valid:       err == nil})
+	}
+	return nil
+}
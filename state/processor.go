@@ -36,8 +36,11 @@ type TransactionProcessor struct {
 	stateQueue   list.List
 	projector    Projector
 	trie         *trie.Database
+	accounts     *accountCache
 	mu           sync.Mutex
 	rootMu       sync.RWMutex
+	// parallelMinTxs is the number of transactions a layer must have to be applied in parallel
+	parallelMinTxs int
 }
 
 const newRootKey = "root"
@@ -59,8 +62,11 @@ func NewTransactionProcessor(allStates, processorDb database.Database, projector
 		stateQueue:   list.List{},
 		projector:    projector,
 		trie:         stateDb.TrieDB(),
+		accounts:     newAccountCache(DefaultAccountCacheSize),
 		mu:           sync.Mutex{}, // sync between reset and apply mesh.Transactions
 		rootMu:       sync.RWMutex{},
+
+		parallelMinTxs: defaultParallelMinTxs,
 	}
 }
 
@@ -73,7 +79,7 @@ func PublicKeyToAccountAddress(pub ed25519.PublicKey) types.Address {
 
 // AddressExists checks if an account address exists in this node's global state
 func (tp *TransactionProcessor) AddressExists(addr types.Address) bool {
-	return tp.Exist(addr)
+	return tp.account(addr).exists
 }
 
 // GetLayerApplied gets the layer id at which this tx was applied
@@ -113,14 +119,26 @@ func (tp *TransactionProcessor) ApplyTransactions(layer types.LayerID, txs []*ty
 
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
-	remaining := txs
+	tp.accounts.startUpdate()
+	defer tp.accounts.endUpdate()
+	remaining := tp.uniqueTxs(txs)
 	remainingCount := len(remaining)
-	for { // Loop until there's nothing left to process
-		remaining = tp.Process(remaining, layer)
-		if remainingCount == len(remaining) {
-			break
+	// transactions that touch disjoint accounts are applied in parallel, when they're all dependent on each other
+	// they're applied serially
+	if groups := groupIndependentTxs(remaining); len(remaining) >= tp.parallelMinTxs && len(groups) > 1 {
+		var err error
+		remainingCount, err = tp.processParallel(groups, layer)
+		if err != nil {
+			return remainingCount, err
+		}
+	} else {
+		for { // Loop until there's nothing left to process
+			remaining = tp.Process(remaining, layer)
+			if remainingCount == len(remaining) {
+				break
+			}
+			remainingCount = len(remaining)
 		}
-		remainingCount = len(remaining)
 	}
 
 	newHash, err := tp.Commit()
@@ -134,6 +152,22 @@ func (tp *TransactionProcessor) ApplyTransactions(layer types.LayerID, txs []*ty
 	return remainingCount, err
 }
 
+// uniqueTxs returns txs without the repeated occurrences of transactions, so that every transaction is applied once
+// even if the caller passes it more than once.
+func (tp *TransactionProcessor) uniqueTxs(txs []*types.Transaction) []*types.Transaction {
+	seen := make(map[types.TransactionID]struct{}, len(txs))
+	unique := make([]*types.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if _, ok := seen[tx.ID()]; ok {
+			tp.With().Warning("ignoring repeated transaction", log.TxID(tx.ID().ShortString()))
+			continue
+		}
+		seen[tx.ID()] = struct{}{}
+		unique = append(unique, tx)
+	}
+	return unique
+}
+
 func (tp *TransactionProcessor) addStateToHistory(layer types.LayerID, newHash types.Hash32) error {
 	tp.trie.Reference(newHash, types.Hash32{})
 	err := tp.trie.Commit(newHash, false)
@@ -172,8 +206,15 @@ func (tp *TransactionProcessor) getLayerStateRoot(layer types.LayerID) (types.Ha
 	return x, nil
 }
 
+// GetLayerStateRoot returns the state root at the end of layer, as it was when the layer was applied.
+func (tp *TransactionProcessor) GetLayerStateRoot(layer types.LayerID) (types.Hash32, error) {
+	return tp.getLayerStateRoot(layer)
+}
+
 // ApplyRewards applies reward reward to miners vector miners in for layer
 func (tp *TransactionProcessor) ApplyRewards(layer types.LayerID, miners []types.Address, reward *big.Int) {
+	tp.accounts.startUpdate()
+	defer tp.accounts.endUpdate()
 	for _, account := range miners {
 		tp.Log.With().Info("Reward applied",
 			log.String("account", account.Short()),
@@ -196,17 +237,74 @@ func (tp *TransactionProcessor) ApplyRewards(layer types.LayerID, miners []types
 	}
 }
 
+// AccountUpdate is the balance and nonce of an account at the end of a layer.
+type AccountUpdate struct {
+	Address types.Address
+	Balance uint64
+	Nonce   uint64
+}
+
+// ApplyAccountStates overwrites the balance and nonce of the given accounts and commits the result as the state of
+// layer. It is used by read replicas, which receive the accounts touched in every layer from a trusted leader instead
+// of applying transactions and rewards themselves. It returns the new state root.
+func (tp *TransactionProcessor) ApplyAccountStates(layer types.LayerID, accounts []AccountUpdate) (types.Hash32, error) {
+	if len(accounts) == 0 {
+		// like ApplyTransactions, don't commit when the layer didn't change the state
+		root := tp.GetStateRoot()
+		return root, tp.addStateToHistory(layer, root)
+	}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.accounts.startUpdate()
+	defer tp.accounts.endUpdate()
+	for _, account := range accounts {
+		tp.SetBalance(account.Address, new(big.Int).SetUint64(account.Balance))
+		tp.SetNonce(account.Address, account.Nonce)
+	}
+	newHash, err := tp.Commit()
+	if err != nil {
+		return types.Hash32{}, fmt.Errorf("failed to commit global state: %v", err)
+	}
+	if err := tp.addStateToHistory(layer, newHash); err != nil {
+		return types.Hash32{}, err
+	}
+	return newHash, nil
+}
+
+// ImportState makes the state with the given root, which must already be stored in the state database (e.g. by
+// statesync), the state of layer. It's used to start from the state of a checkpoint layer instead of applying all
+// layers up to it.
+func (tp *TransactionProcessor) ImportState(layer types.LayerID, root types.Hash32) error {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.accounts.startUpdate()
+	defer tp.accounts.endUpdate()
+	newState, err := New(root, tp.db)
+	if err != nil {
+		return fmt.Errorf("could not load state root %v: %v", root.ShortString(), err)
+	}
+	tp.DB = newState
+	if err := tp.addState(root, layer); err != nil {
+		return err
+	}
+	tp.Log.With().Info("imported state", log.LayerID(uint64(layer)), log.String("state_root", root.String()))
+	return nil
+}
+
 // LoadState loads the last state from persistent storage
 func (tp *TransactionProcessor) LoadState(layer types.LayerID) error {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
+	tp.accounts.startUpdate()
+	defer tp.accounts.endUpdate()
 	state, err := tp.getLayerStateRoot(layer)
 	if err != nil {
 		return err
 	}
 	newState, err := New(state, tp.db)
 	if err != nil {
-		log.Panic("cannot revert- improper state: %v", err)
+		return fmt.Errorf("cannot load state root %v of layer %v: %v", state.ShortString(), layer, err)
 	}
 
 	tp.Log.Info("reverted, new root %x", newState.IntermediateRoot(false))
@@ -235,12 +333,20 @@ func (tp *TransactionProcessor) Process(txs []*types.Transaction, layerID types.
 			Destination: tx.Recipient.String(),
 			Amount:      tx.Amount,
 			Fee:         tx.Fee})
+		events.Publish(events.TxReceipt{
+			ID:          tx.ID().String(),
+			Origin:      tx.Origin().String(),
+			Destination: tx.Recipient.String(),
+			Amount:      tx.Amount,
+			Fee:         tx.Fee,
+			Layer:       layerID.Uint64(),
+			Valid:       err == nil})
 	}
 	return
 }
 
 func (tp *TransactionProcessor) checkNonce(trns *types.Transaction) bool {
-	return tp.GetNonce(trns.Origin()) == trns.AccountNonce
+	return tp.DB.GetNonce(trns.Origin()) == trns.AccountNonce
 }
 
 var (
@@ -268,11 +374,11 @@ func (tp *TransactionProcessor) ApplyTransaction(trans *types.Transaction, layer
 	}
 
 	if !tp.checkNonce(trans) {
-		tp.Log.Error(errNonce+" should be %v actual %v", tp.GetNonce(trans.Origin()), trans.AccountNonce)
+		tp.Log.Error(errNonce+" should be %v actual %v", tp.DB.GetNonce(trans.Origin()), trans.AccountNonce)
 		return fmt.Errorf(errNonce)
 	}
 
-	tp.SetNonce(trans.Origin(), tp.GetNonce(trans.Origin())+1) // TODO: Not thread-safe
+	tp.SetNonce(trans.Origin(), tp.DB.GetNonce(trans.Origin())+1) // TODO: Not thread-safe
 	transfer(tp, trans.Origin(), trans.Recipient, new(big.Int).SetUint64(trans.Amount))
 
 	// subtract fee from account, fee will be sent to miners in layers after
@@ -284,6 +390,43 @@ func (tp *TransactionProcessor) ApplyTransaction(trans *types.Transaction, layer
 	return nil
 }
 
+// RevertTransactions removes the applied markers of the transactions of layer, which is rolled back, so that
+// GetLayerApplied doesn't report them until they're applied again. The receipts of the transactions that were applied in
+// layer are published as reverted.
+func (tp *TransactionProcessor) RevertTransactions(layer types.LayerID, txs []*types.Transaction) error {
+	for _, tx := range txs {
+		applied := tp.GetLayerApplied(tx.ID())
+		if applied == nil || *applied != layer {
+			continue
+		}
+		if err := tp.processorDb.Delete(tx.ID().Bytes()); err != nil {
+			return fmt.Errorf("failed to remove applied tx %v: %v", tx.ID().ShortString(), err)
+		}
+		events.Publish(events.TxReceipt{
+			ID:          tx.ID().String(),
+			Origin:      tx.Origin().String(),
+			Destination: tx.Recipient.String(),
+			Amount:      tx.Amount,
+			Fee:         tx.Fee,
+			Layer:       layer.Uint64(),
+			Reverted:    true})
+	}
+	return nil
+}
+
+// GetLayerBalance returns the balance of addr in the state of layer, as it was when the layer was applied.
+func (tp *TransactionProcessor) GetLayerBalance(layer types.LayerID, addr types.Address) (uint64, error) {
+	root, err := tp.getLayerStateRoot(layer)
+	if err != nil {
+		return 0, fmt.Errorf("no state for layer %v: %v", layer, err)
+	}
+	layerState, err := New(root, tp.db)
+	if err != nil {
+		return 0, fmt.Errorf("could not load state root %v: %v", root.ShortString(), err)
+	}
+	return layerState.GetBalance(addr), nil
+}
+
 // GetStateRoot gets the current state root hash
 func (tp *TransactionProcessor) GetStateRoot() types.Hash32 {
 	tp.rootMu.RLock()
@@ -5,12 +5,14 @@ import (
 	"github.com/spacemeshos/ed25519"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/events"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/mesh"
 	"github.com/spacemeshos/go-spacemesh/signing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"math"
 	"math/big"
 	"math/rand"
 	"testing"
@@ -568,3 +570,230 @@ func TestTransactionProcessor_ApplyTransactions(t *testing.T) {
 	assert.NoError(t, err)
 
 }
+
+func TestTransactionProcessor_ApplyTransactions_Repeated(t *testing.T) {
+	r := require.New(t)
+	db := database.NewMemDatabase()
+	processor := NewTransactionProcessor(db, db, &ProjectorMock{}, log.New("proc_logger", "", ""))
+	signer := signing.NewEdSigner()
+	origin := SignerToAddr(signer)
+	createAccount(processor, origin, 100, 0)
+	_, err := processor.Commit()
+	r.NoError(err)
+
+	// a transaction that several blocks of the layer include is applied once
+	tx0, tx1 := newTx(t, 0, 10, signer), newTx(t, 1, 10, signer)
+	failed, err := processor.ApplyTransactions(1, []*types.Transaction{tx0, tx1, tx0, tx1})
+	r.NoError(err)
+	r.Zero(failed)
+	r.Equal(uint64(80), processor.GetBalance(origin))
+	r.Equal(uint64(2), processor.GetNonce(origin))
+}
+
+func TestTransactionProcessor_Process_TxReceipts(t *testing.T) {
+	r := require.New(t)
+	db := database.NewMemDatabase()
+	processor := NewTransactionProcessor(db, db, &ProjectorMock{}, log.New("proc_logger", "", ""))
+	signer := signing.NewEdSigner()
+	createAccount(processor, SignerToAddr(signer), 100, 0)
+	_, err := processor.Commit()
+	r.NoError(err)
+	receipts, unsubscribe := events.SubscribeLocal(events.EventTxReceipt)
+	defer unsubscribe()
+
+	applied, failed := newTx(t, 0, 10, signer), newTx(t, 5, 10, signer)
+	processor.Process([]*types.Transaction{applied, failed}, 3)
+	for _, tx := range []*types.Transaction{applied, failed} {
+		receipt := (<-receipts).(events.TxReceipt)
+		r.Equal(tx.ID().String(), receipt.ID)
+		r.Equal(tx.Origin().String(), receipt.Origin)
+		r.Equal(tx.Recipient.String(), receipt.Destination)
+		r.Equal(uint64(3), receipt.Layer)
+		r.Equal(tx == applied, receipt.Valid)
+	}
+}
+
+func TestTransactionProcessor_ApplyAccountStates(t *testing.T) {
+	r := require.New(t)
+	lg := log.New("proc_logger", "", "")
+	leaderDb := database.NewMemDatabase()
+	leader := NewTransactionProcessor(leaderDb, leaderDb, &ProjectorMock{}, lg)
+	replicaDb := database.NewMemDatabase()
+	replica := NewTransactionProcessor(replicaDb, replicaDb, &ProjectorMock{}, lg)
+
+	signer := signing.NewEdSigner()
+	origin := SignerToAddr(signer)
+	recipient := toAddr([]byte{0x01, 0x02})
+	createAccount(leader, origin, 21, 0)
+	createAccount(replica, origin, 21, 0)
+
+	_, err := leader.ApplyTransactions(1, []*types.Transaction{createTransaction(t, 0, recipient, 1, 5, signer)})
+	r.NoError(err)
+
+	accounts := []AccountUpdate{
+		{Address: origin, Balance: leader.GetBalance(origin), Nonce: leader.GetNonce(origin)},
+		{Address: recipient, Balance: leader.GetBalance(recipient), Nonce: leader.GetNonce(recipient)},
+	}
+	root, err := replica.ApplyAccountStates(1, accounts)
+	r.NoError(err)
+	r.Equal(leader.GetStateRoot(), root)
+	r.Equal(root, replica.GetStateRoot())
+	r.Equal(uint64(1), replica.GetBalance(recipient))
+	r.Equal(uint64(1), replica.GetNonce(origin))
+
+	stored, err := replica.getLayerStateRoot(1)
+	r.NoError(err)
+	r.Equal(root, stored)
+}
+
+func TestTransactionProcessor_ImportState(t *testing.T) {
+	r := require.New(t)
+	lg := log.New("proc_logger", "", "")
+	db := database.NewMemDatabase()
+	processor := NewTransactionProcessor(db, database.NewMemDatabase(), &ProjectorMock{}, lg)
+
+	// a state written to the state database by another processor, as statesync does
+	other := NewTransactionProcessor(db, database.NewMemDatabase(), &ProjectorMock{}, lg)
+	addr := toAddr([]byte{0x01, 0x02})
+	_, err := other.ApplyAccountStates(5, []AccountUpdate{{Address: addr, Balance: 100, Nonce: 3}})
+	r.NoError(err)
+	root := other.GetStateRoot()
+
+	r.NoError(processor.ImportState(5, root))
+	r.Equal(root, processor.GetStateRoot())
+	r.Equal(uint64(100), processor.GetBalance(addr))
+	r.Equal(uint64(3), processor.GetNonce(addr))
+	stored, err := processor.getLayerStateRoot(5)
+	r.NoError(err)
+	r.Equal(root, stored)
+
+	r.Error(processor.ImportState(6, types.Hash32{0x01}))
+}
+
+func TestTransactionProcessor_GetLayerBalance(t *testing.T) {
+	r := require.New(t)
+	lg := log.New("proc_logger", "", "")
+	processor := NewTransactionProcessor(database.NewMemDatabase(), database.NewMemDatabase(), &ProjectorMock{}, lg)
+
+	addr := toAddr([]byte{0x01, 0x02})
+	_, err := processor.ApplyAccountStates(5, []AccountUpdate{{Address: addr, Balance: 100}})
+	r.NoError(err)
+	_, err = processor.ApplyAccountStates(6, []AccountUpdate{{Address: addr, Balance: 70}})
+	r.NoError(err)
+
+	balance, err := processor.GetLayerBalance(5, addr)
+	r.NoError(err)
+	r.Equal(uint64(100), balance)
+	balance, err = processor.GetLayerBalance(6, addr)
+	r.NoError(err)
+	r.Equal(uint64(70), balance)
+	r.Equal(uint64(70), processor.GetBalance(addr))
+
+	_, err = processor.GetLayerBalance(7, addr)
+	r.Error(err)
+}
+
+func BenchmarkTransactionProcessor_ApplyTransactions(b *testing.B) {
+	const accounts = 100
+	lg := log.NewDefault("").WithOptions(log.Nop)
+	processor := NewTransactionProcessor(database.NewMemDatabase(), database.NewMemDatabase(), &ProjectorMock{}, lg)
+
+	signers := make([]*signing.EdSigner, accounts)
+	for i := range signers {
+		signers[i] = signing.NewEdSigner()
+		createAccount(processor, SignerToAddr(signers[i]), 1000000000, 0)
+	}
+	_, err := processor.Commit()
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		txs := make([]*types.Transaction, 0, accounts)
+		for j, signer := range signers {
+			tx, err := mesh.NewSignedTx(uint64(i), SignerToAddr(signers[(j+1)%accounts]), 10, 100, 1, signer)
+			require.NoError(b, err)
+			txs = append(txs, tx)
+		}
+		b.StartTimer()
+
+		failed, err := processor.ApplyTransactions(types.LayerID(i+1), txs)
+		require.NoError(b, err)
+		require.Zero(b, failed)
+	}
+}
+
+func TestTransactionProcessor_ApplyTransactions_Parallel(t *testing.T) {
+	r := require.New(t)
+	newProcessor := func() *TransactionProcessor {
+		db := database.NewMemDatabase()
+		return NewTransactionProcessor(db, db, &ProjectorMock{}, log.New("proc_logger", "", ""))
+	}
+	parallel, serial := newProcessor(), newProcessor()
+	serial.parallelMinTxs = math.MaxInt32
+
+	var signers []*signing.EdSigner
+	for i := 0; i < 20; i++ {
+		signer := signing.NewEdSigner()
+		signers = append(signers, signer)
+		createAccount(parallel, SignerToAddr(signer), 1000, 0)
+		createAccount(serial, SignerToAddr(signer), 1000, 0)
+	}
+	_, err := parallel.Commit()
+	r.NoError(err)
+	_, err = serial.Commit()
+	r.NoError(err)
+
+	// every signer pays a new account in reverse nonce order, so that the transactions are retried, the first signer
+	// also pays the second one, which then spends it, and a transaction with a nonce gap never applies
+	var txs []*types.Transaction
+	for i, signer := range signers {
+		for nonce := 3; nonce >= 0; nonce-- {
+			txs = append(txs, createTransaction(t, uint64(nonce), toAddr([]byte{0xaa, byte(i)}), 10, 1, signer))
+		}
+	}
+	txs = append(txs, createTransaction(t, 4, SignerToAddr(signers[1]), 500, 1, signers[0]))
+	txs = append(txs, createTransaction(t, 4, toAddr([]byte{0xbb}), 1400, 1, signers[1]))
+	txs = append(txs, createTransaction(t, 10, toAddr([]byte{0xcc}), 10, 1, signers[2]))
+	r.True(len(txs) >= parallel.parallelMinTxs)
+	r.Len(groupIndependentTxs(txs), len(signers)-1)
+
+	failed, err := parallel.ApplyTransactions(1, txs)
+	r.NoError(err)
+	serialFailed, err := serial.ApplyTransactions(1, txs)
+	r.NoError(err)
+	r.Equal(serialFailed, failed)
+	r.Equal(1, failed)
+	r.Equal(serial.GetStateRoot(), parallel.GetStateRoot())
+	for _, tx := range txs {
+		r.Equal(serial.GetLayerApplied(tx.ID()), parallel.GetLayerApplied(tx.ID()))
+	}
+}
+
+func TestTransactionProcessor_AccountCache(t *testing.T) {
+	r := require.New(t)
+	db := database.NewMemDatabase()
+	processor := NewTransactionProcessor(db, db, &ProjectorMock{}, log.New("proc_logger", "", ""))
+	addr := toAddr([]byte{0x01})
+
+	// accounts that don't exist are cached too
+	r.False(processor.AddressExists(addr))
+	acc, _, cached := processor.accounts.get(addr)
+	r.True(cached)
+	r.False(acc.exists)
+
+	// applying a layer invalidates the cache
+	processor.ApplyRewards(1, []types.Address{addr}, big.NewInt(1000))
+	_, _, cached = processor.accounts.get(addr)
+	r.False(cached)
+	r.True(processor.AddressExists(addr))
+	r.Equal(uint64(1000), processor.GetBalance(addr))
+
+	// reads that overlap a state change aren't cached
+	_, generation, _ := processor.accounts.get(toAddr([]byte{0x02}))
+	processor.accounts.startUpdate()
+	processor.accounts.add(toAddr([]byte{0x02}), cachedAccount{}, generation)
+	processor.accounts.endUpdate()
+	_, _, cached = processor.accounts.get(toAddr([]byte{0x02}))
+	r.False(cached)
+}
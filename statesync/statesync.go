@@ -0,0 +1,200 @@
+// Package statesync implements a p2p protocol for transferring the global state at a checkpoint, so that a fresh node
+// can import the state of a checkpoint layer instead of re-executing all historical transactions.
+//
+// The state is transferred as the nodes of the state trie, requested in chunks by their hash starting from the state
+// root. Every node is verified against the hash it was requested by before it's stored, so a node can download the
+// state from untrusted peers as long as it trusts the state root.
+package statesync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/crypto"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/config"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/p2p/server"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/trie"
+)
+
+const protocol = "/statesync/1.0/"
+
+const trieNodesMsg server.MessageType = 1
+
+// MaxNodesPerRequest is the maximal number of trie nodes requested from (and served to) a peer in a single request.
+const MaxNodesPerRequest = 256
+
+// ErrClosed is returned by Fetch when the state sync was closed before the state was fetched.
+var ErrClosed = errors.New("state sync closed")
+
+type nodeSource interface {
+	Node(hash types.Hash32) ([]byte, error)
+}
+
+type peers interface {
+	GetPeers() []p2ppeers.Peer
+	Close()
+}
+
+// StateSync serves the nodes of the local state trie to peers and fetches the state at a given root from them.
+type StateSync struct {
+	log.Log
+	*server.MessageServer
+	peers
+	db      database.Database
+	timeout time.Duration
+	exit    chan struct{}
+}
+
+// NewStateSync returns a StateSync that serves trie nodes from nodes and fetches trie nodes from the peers of srv into
+// db, giving up on a request after timeout.
+func NewStateSync(srv service.Service, nodes nodeSource, db database.Database, timeout time.Duration, logger log.Log) *StateSync {
+	s := &StateSync{
+		Log:           logger,
+		MessageServer: server.NewMsgServer(srv.(server.Service), protocol, timeout, make(chan service.DirectMessage, config.Values.BufferSize), logger),
+		peers:         p2ppeers.NewPeers(srv, logger.WithName("peers")),
+		db:            db,
+		timeout:       timeout,
+		exit:          make(chan struct{}),
+	}
+	s.RegisterBytesMsgHandler(trieNodesMsg, newTrieNodesRequestHandler(nodes, logger))
+	return s
+}
+
+// Close stops serving trie nodes and aborts a running Fetch.
+func (s *StateSync) Close() {
+	close(s.exit)
+	s.MessageServer.Close()
+	s.peers.Close()
+}
+
+func newTrieNodesRequestHandler(nodes nodeSource, logger log.Log) func(msg []byte) []byte {
+	return func(msg []byte) []byte {
+		var hashes []types.Hash32
+		if err := types.BytesToInterface(msg, &hashes); err != nil {
+			logger.Error("could not unmarshal trie nodes request: %v", err)
+			return nil
+		}
+		if len(hashes) > MaxNodesPerRequest {
+			logger.Warning("trie nodes request of %v nodes exceeds the limit, serving the first %v", len(hashes), MaxNodesPerRequest)
+			hashes = hashes[:MaxNodesPerRequest]
+		}
+		// missing nodes are answered with an empty blob, so that the requester can ask another peer for them
+		blobs := make([][]byte, len(hashes))
+		for i, hash := range hashes {
+			blob, err := nodes.Node(hash)
+			if err != nil {
+				logger.With().Debug("trie node not found", log.String("hash", hash.ShortString()))
+				continue
+			}
+			blobs[i] = blob
+		}
+		bts, err := types.InterfaceToBytes(blobs)
+		if err != nil {
+			logger.Error("could not marshal trie nodes response: %v", err)
+			return nil
+		}
+		return bts
+	}
+}
+
+// Fetch downloads the state trie rooted at root from peers, skipping nodes that are already stored. It returns
+// when the whole trie was stored, or with ErrClosed if the state sync was closed before that. Requests that fail or
+// return invalid nodes are retried with the next peer.
+func (s *StateSync) Fetch(root types.Hash32) error {
+	s.With().Info("fetching state", log.String("state_root", root.String()))
+	sched := trie.NewSync(root, s.db, nil)
+	var retry []types.Hash32
+	fetched, peerIdx := 0, 0
+	for sched.Pending() > 0 {
+		select {
+		case <-s.exit:
+			return ErrClosed
+		default:
+		}
+		hashes := retry
+		if len(hashes) < MaxNodesPerRequest { // Missing(0) returns all missing nodes
+			hashes = append(hashes, sched.Missing(MaxNodesPerRequest-len(hashes))...)
+		}
+		if len(hashes) == 0 {
+			return fmt.Errorf("state sync stalled with %v pending nodes", sched.Pending())
+		}
+		retry = hashes
+
+		peers := s.GetPeers()
+		if len(peers) == 0 {
+			s.Info("no peers to fetch state from, waiting")
+			s.wait(time.Second)
+			continue
+		}
+		peer := peers[peerIdx%len(peers)]
+		peerIdx++
+
+		blobs, err := s.requestNodes(peer, hashes)
+		if err != nil {
+			s.With().Warning("trie nodes request failed", log.String("peer", peer.String()), log.Err(err))
+			continue
+		}
+		results := make([]trie.SyncResult, 0, len(hashes))
+		retry = nil
+		for i, hash := range hashes {
+			if i >= len(blobs) || len(blobs[i]) == 0 || crypto.Keccak256Hash(blobs[i]) != hash {
+				retry = append(retry, hash)
+				continue
+			}
+			results = append(results, trie.SyncResult{Hash: hash, Data: blobs[i]})
+		}
+		if len(retry) > 0 {
+			s.With().Warning("peer did not serve all requested trie nodes", log.String("peer", peer.String()),
+				log.Int("requested", len(hashes)), log.Int("missing", len(retry)))
+		}
+		if _, i, err := sched.Process(results); err != nil {
+			return fmt.Errorf("could not process trie node %v: %v", results[i].Hash.ShortString(), err)
+		}
+		if _, err := sched.Commit(s.db); err != nil {
+			return fmt.Errorf("could not store trie nodes: %v", err)
+		}
+		fetched += len(results)
+		s.With().Info("fetched trie nodes", log.Int("fetched", fetched), log.Int("pending", sched.Pending()))
+	}
+	s.With().Info("done fetching state", log.String("state_root", root.String()), log.Int("fetched", fetched))
+	return nil
+}
+
+func (s *StateSync) requestNodes(peer p2ppeers.Peer, hashes []types.Hash32) ([][]byte, error) {
+	payload, err := types.InterfaceToBytes(hashes)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan [][]byte, 1)
+	resHandler := func(msg []byte) {
+		var blobs [][]byte
+		if err := types.BytesToInterface(msg, &blobs); err != nil {
+			s.Error("could not unmarshal trie nodes response: %v", err)
+		}
+		ch <- blobs
+	}
+	if err := s.SendRequest(trieNodesMsg, payload, peer, resHandler); err != nil {
+		return nil, err
+	}
+	select {
+	case blobs := <-ch:
+		return blobs, nil
+	case <-time.After(s.timeout):
+		return nil, errors.New("request timed out")
+	case <-s.exit:
+		return nil, ErrClosed
+	}
+}
+
+func (s *StateSync) wait(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-s.exit:
+	}
+}
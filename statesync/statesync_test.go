@@ -0,0 +1,108 @@
+package statesync
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/state"
+	"github.com/stretchr/testify/require"
+)
+
+func getPeersMock(peers []p2ppeers.Peer) *p2ppeers.Peers {
+	value := atomic.Value{}
+	value.Store(peers)
+	return p2ppeers.NewPeersImpl(&value, make(chan struct{}), log.NewDefault("peers"))
+}
+
+func createState(t *testing.T, accounts int) (*state.DB, types.Hash32) {
+	st, err := state.New(types.Hash32{}, state.NewDatabase(database.NewMemDatabase()))
+	require.NoError(t, err)
+	for i := 0; i < accounts; i++ {
+		st.SetBalance(types.BytesToAddress([]byte{byte(i >> 8), byte(i)}), big.NewInt(int64(i+1)))
+		st.SetNonce(types.BytesToAddress([]byte{byte(i >> 8), byte(i)}), uint64(i))
+	}
+	root, err := st.Commit()
+	require.NoError(t, err)
+	return st, root
+}
+
+func TestStateSync_Fetch(t *testing.T) {
+	r := require.New(t)
+	const accounts = 1000 // enough for several requests
+	src, root := createState(t, accounts)
+
+	sim := service.NewSimulator()
+	n1, n2 := sim.NewNode(), sim.NewNode()
+	server := NewStateSync(n1, src.TrieDB(), database.NewMemDatabase(), time.Second, log.NewDefault("server"))
+	defer server.Close()
+
+	db := database.NewMemDatabase()
+	stateDb := state.NewDatabase(db)
+	client := NewStateSync(n2, stateDb.TrieDB(), db, time.Second, log.NewDefault("client"))
+	defer client.Close()
+	client.peers = getPeersMock([]p2ppeers.Peer{n1.PublicKey()})
+
+	r.NoError(client.Fetch(root))
+
+	st, err := state.New(root, stateDb)
+	r.NoError(err)
+	for i := 0; i < accounts; i++ {
+		addr := types.BytesToAddress([]byte{byte(i >> 8), byte(i)})
+		r.Equal(uint64(i+1), st.GetBalance(addr))
+		r.Equal(uint64(i), st.GetNonce(addr))
+	}
+
+	// fetching a stored state requests nothing
+	client.peers = getPeersMock(nil)
+	r.NoError(client.Fetch(root))
+}
+
+type corruptNodes struct{}
+
+func (corruptNodes) Node(types.Hash32) ([]byte, error) {
+	return []byte("not the requested node"), nil
+}
+
+type missingNodes struct{}
+
+func (missingNodes) Node(types.Hash32) ([]byte, error) {
+	return nil, errors.New("not found")
+}
+
+func TestStateSync_FetchRejectsInvalidNodes(t *testing.T) {
+	r := require.New(t)
+	_, root := createState(t, 10)
+
+	sim := service.NewSimulator()
+	corrupt, missing, n := sim.NewNode(), sim.NewNode(), sim.NewNode()
+	corruptServer := NewStateSync(corrupt, corruptNodes{}, database.NewMemDatabase(), time.Second, log.NewDefault("corrupt"))
+	defer corruptServer.Close()
+	missingServer := NewStateSync(missing, missingNodes{}, database.NewMemDatabase(), time.Second, log.NewDefault("missing"))
+	defer missingServer.Close()
+
+	db := database.NewMemDatabase()
+	client := NewStateSync(n, missingNodes{}, db, time.Second, log.NewDefault("client"))
+	client.peers = getPeersMock([]p2ppeers.Peer{corrupt.PublicKey(), missing.PublicKey()})
+
+	done := make(chan error, 1)
+	go func() { done <- client.Fetch(root) }()
+	time.Sleep(500 * time.Millisecond)
+	client.Close()
+	select {
+	case err := <-done:
+		r.Equal(ErrClosed, err)
+	case <-time.After(2 * time.Second):
+		r.Fail("fetch did not return after close")
+	}
+	has, err := db.Has(root.Bytes())
+	r.NoError(err)
+	r.False(has, "invalid state stored")
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/config"
 	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/p2p/replay"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
 	"github.com/spacemeshos/go-spacemesh/priorityq"
 	"sync"
@@ -19,6 +21,7 @@ type BlockListener struct {
 	bufferSize           int
 	semaphore            chan struct{}
 	receivedGossipBlocks chan service.GossipMessage
+	replays              *replay.Window
 	startLock            types.TryMutex
 	timeout              time.Duration
 	exit                 chan struct{}
@@ -48,6 +51,7 @@ func NewBlockListener(net service.Service, sync *Syncer, concurrency int, logger
 		semaphore:            make(chan struct{}, concurrency),
 		exit:                 make(chan struct{}),
 		receivedGossipBlocks: net.RegisterGossipProtocol(config.NewBlockProtocol, priorityq.High),
+		replays:              replay.NewWindow(config.NewBlockProtocol, replay.DefaultWindow),
 	}
 	return &bl
 }
@@ -89,7 +93,16 @@ func (bl *BlockListener) handleBlock(data service.GossipMessage) {
 	}
 
 	//set the block id when received
-	blk.Initialize()
+	if err := blk.TryInitialize(); err != nil {
+		bl.With().Error("received block with a bad signature", log.Err(err))
+		return
+	}
+
+	// blocks seen recently were already handled, whether they were valid or not. They're keyed by the hash of the whole
+	// message, since the id doesn't cover everything in it, and an invalid block with the id of a valid one would hide it
+	if bl.replays.Replayed(types.CalcHash32(data.Bytes()).Bytes(), data.Sender()) {
+		return
+	}
 
 	bl.Log.With().Info("got new block", blk.Fields()...)
 	//check if known
@@ -97,6 +110,10 @@ func (bl *BlockListener) handleBlock(data service.GossipMessage) {
 		bl.With().Info("we already know this block", log.BlockID(blk.ID().String()))
 		return
 	}
+	if t, err := bl.GetTombstone(blk.ID()); err == nil {
+		bl.With().Info("block was found invalid before", log.BlockID(blk.ID().String()), log.String("reason", t.Reason.String()))
+		return
+	}
 	txs, atxs, err := bl.blockSyntacticValidation(&blk)
 	if err != nil {
 		bl.With().Error("failed to validate block", log.BlockID(blk.ID().String()), log.Err(err))
@@ -113,3 +130,8 @@ func (bl *BlockListener) handleBlock(data service.GossipMessage) {
 	}
 	return
 }
+
+// PeerReplays returns the number of blocks peer gossiped again shortly after they were seen.
+func (bl *BlockListener) PeerReplays(peer p2pcrypto.PublicKey) uint64 {
+	return bl.replays.PeerReplays(peer)
+}
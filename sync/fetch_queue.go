@@ -34,6 +34,7 @@ type fetchQueue struct {
 	checkLocal  checkLocalFunc
 	queue       chan []types.Hash32 //types.TransactionID //todo make buffered
 	name        string
+	missed      func(count int) // called with the number of items that are fetched, when set
 }
 
 func (fq *fetchQueue) Close() {
@@ -107,6 +108,9 @@ func (fq *fetchQueue) addToPending(ids []types.Hash32) []chan bool {
 	}
 	fq.Unlock()
 	if len(idsToAdd) > 0 {
+		if fq.missed != nil {
+			fq.missed(len(idsToAdd))
+		}
 		fq.queue <- idsToAdd
 	}
 	return deps
@@ -233,7 +237,7 @@ func newAtxQueue(s *Syncer, fetchPoetProof fetchPoetProofFunc) *atxQueue {
 		},
 	}
 
-	q.handleFetch = updateAtxDependencies(q.invalidate, s.SyntacticallyValidateAtx, s.atxpool, fetchPoetProof)
+	q.handleFetch = updateAtxDependencies(q.invalidate, s.validateAtx, s.atxpool, fetchPoetProof)
 	go q.work()
 	return q
 }
@@ -58,8 +58,35 @@ func newLayerBlockIdsRequestHandler(layers *mesh.Mesh, logger log.Log) func(msg
 	}
 }
 
+// txElement serializes a transaction as an element of a slice of transaction pointers.
+type txElement struct {
+	Tx *types.Transaction
+}
+
+// appendResponse serializes item into the response w. It returns false once the response is full, the items that
+// don't fit are left out and the requester fetches them again.
+func appendResponse(w *types.ArrayWriter, item interface{}, logger log.Log) bool {
+	err := w.Append(item)
+	if err == types.ErrSizeLimit {
+		logger.With().Warning("response size limit reached, sending partial response",
+			log.Int("items", w.Count()), log.Int("size", w.Len()))
+		truncatedResponses.Add(1)
+		return false
+	}
+	if err != nil {
+		logger.With().Error("failed to serialize response item", log.Err(err))
+	}
+	return true
+}
+
+// responseBytes returns the serialized response w.
+func responseBytes(w *types.ArrayWriter) []byte {
+	responseSize.Add(float64(w.Len()))
+	return w.Bytes()
+}
+
 //todo better logs
-func newBlockRequestHandler(msh *mesh.Mesh, logger log.Log) func(msg []byte) []byte {
+func newBlockRequestHandler(msh *mesh.Mesh, sizeLimit int, logger log.Log) func(msg []byte) []byte {
 	return func(msg []byte) []byte {
 		var blockids []types.Hash32
 		if err := types.BytesToInterface(msg, &blockids); err != nil {
@@ -67,13 +94,19 @@ func newBlockRequestHandler(msh *mesh.Mesh, logger log.Log) func(msg []byte) []b
 			return nil
 		}
 
-		var blocks []types.Block
+		// blocks are serialized one at a time, so that only one of them is held in memory with the response
+		w := types.NewArrayWriter(sizeLimit)
 		logger.Info("handle block request ids: %s", concatShortIds(blockids))
 		for _, bid := range blockids {
 			logger.Info("handle block %s request", bid.ShortString())
 			blk, err := msh.GetBlock(types.BlockID(bid.ToHash20()))
 			if err != nil {
 				if err == database.ErrNotFound {
+					if t, err := msh.GetTombstone(types.BlockID(bid.ToHash20())); err == nil {
+						logger.With().Info("block found invalid was requested", log.BlockID(bid.ShortString()),
+							log.String("reason", t.Reason.String()))
+						continue
+					}
 					logger.With().Warning("unfamiliar block was requested (id: %s)", log.BlockID(bid.ShortString()), log.Err(err))
 					continue
 				}
@@ -81,20 +114,17 @@ func newBlockRequestHandler(msh *mesh.Mesh, logger log.Log) func(msg []byte) []b
 				continue
 			}
 
-			blocks = append(blocks, *blk)
-		}
-		bbytes, err := types.InterfaceToBytes(blocks)
-		if err != nil {
-			logger.Error("Error marshaling response message (FetchBlockResp) ", err)
-			return nil
+			if !appendResponse(w, blk, logger) {
+				break
+			}
 		}
 
 		logger.Info("send block response")
-		return bbytes
+		return responseBytes(w)
 	}
 }
 
-func newTxsRequestHandler(s *Syncer, logger log.Log) func(msg []byte) []byte {
+func newTxsRequestHandler(s *Syncer, sizeLimit int, logger log.Log) func(msg []byte) []byte {
 	return func(msg []byte) []byte {
 		var txids []types.TransactionID
 		err := types.BytesToInterface(msg, &txids)
@@ -113,18 +143,20 @@ func newTxsRequestHandler(s *Syncer, logger log.Log) func(msg []byte) []byte {
 			}
 		}
 
-		bbytes, err := types.InterfaceToBytes(txs)
-		if err != nil {
-			logger.Error("Error marshaling transactions response message, with ids %v and err:", txs, err)
-			return nil
+		w := types.NewArrayWriter(sizeLimit)
+		for _, tx := range txs {
+			// the requester decodes a slice of pointers, which marks every element as present
+			if !appendResponse(w, &txElement{tx}, logger) {
+				break
+			}
 		}
 
 		logger.Info("send tx response")
-		return bbytes
+		return responseBytes(w)
 	}
 }
 
-func newAtxsRequestHandler(s *Syncer, logger log.Log) func(msg []byte) []byte {
+func newAtxsRequestHandler(s *Syncer, sizeLimit int, logger log.Log) func(msg []byte) []byte {
 	return func(msg []byte) []byte {
 		var atxids []types.ATXID
 		err := types.BytesToInterface(msg, &atxids)
@@ -133,29 +165,25 @@ func newAtxsRequestHandler(s *Syncer, logger log.Log) func(msg []byte) []byte {
 			return nil
 		}
 		logger.With().Info("handle atx request", types.AtxIdsField(atxids))
-		atxs, unknownAtx := s.GetATXs(atxids)
-		for _, t := range unknownAtx {
-			if tx, err := s.atxpool.Get(t); err == nil {
-				atxs[t] = tx
-			} else {
-				logger.With().Warning("unfamiliar atx requested", log.AtxID(t.ShortString()))
-			}
-		}
 
-		var transactions []types.ActivationTx
-		for _, value := range atxs {
-			tx := *value
-			transactions = append(transactions, tx)
-		}
-
-		bbytes, err := types.InterfaceToBytes(transactions)
-		if err != nil {
-			logger.Error("Unable to marshal atx response message", err)
-			return nil
+		// atxs carry their NIPSTs, they're loaded and serialized one at a time so that only one of them is held in
+		// memory with the response
+		w := types.NewArrayWriter(sizeLimit)
+		for _, id := range atxids {
+			atx, err := s.GetFullAtx(id)
+			if err != nil {
+				if atx, err = s.atxpool.Get(id); err != nil {
+					logger.With().Warning("unfamiliar atx requested", log.AtxID(id.ShortString()))
+					continue
+				}
+			}
+			if !appendResponse(w, atx, logger) {
+				break
+			}
 		}
 
 		logger.Info("send atx response ")
-		return bbytes
+		return responseBytes(w)
 	}
 }
 
@@ -37,6 +37,11 @@ func newFetchRequestTimer(msgtype server.MessageType) *prometheus.Timer {
 	return nil
 }
 
+var (
+	responseSize       = newCounter("response_bytes", "Bytes of block, tx and atx responses served to peers", nil)
+	truncatedResponses = newCounter("truncated_responses", "Number of responses cut short by the response size limit", nil)
+)
+
 var (
 	gossipBlockTime = prometheus.NewSummary(prometheus.SummaryOpts{Name: "gossip_block_request_durations",
 		Help:       "gossip block handle duration in milliseconds",
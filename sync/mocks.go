@@ -71,10 +71,18 @@ func (s mockState) LoadState(types.LayerID) error {
 	panic("implement me")
 }
 
+func (s mockState) RevertTransactions(types.LayerID, []*types.Transaction) error {
+	panic("implement me")
+}
+
 func (s mockState) GetStateRoot() types.Hash32 {
 	return [32]byte{}
 }
 
+func (s mockState) GetLayerStateRoot(types.LayerID) (types.Hash32, error) {
+	return [32]byte{}, nil
+}
+
 func (mockState) ValidateNonceAndBalance(*types.Transaction) error {
 	panic("implement me")
 }
@@ -113,6 +121,14 @@ func (*validatorMock) VerifyPost(signing.PublicKey, *types.PostProof, uint64) er
 	return nil
 }
 
+func (*validatorMock) NumOfTicks(*types.NIPST) (uint64, error) {
+	return 1, nil
+}
+
+func (*validatorMock) NumOfSpaceUnits(*types.NIPST) uint32 {
+	return 0
+}
+
 type mockTxMemPool struct{}
 
 func (mockTxMemPool) Get(types.TransactionID) (*types.Transaction, error) {
@@ -207,7 +223,7 @@ func NewSyncWithMocks(atxdbStore *database.LDBDatabase, mshdb *mesh.DB, txpool *
 	var msh *mesh.Mesh
 	if mshdb.PersistentData() {
 		lg.Info("persistent data found ")
-		msh = mesh.NewRecoveredMesh(mshdb, atxdb, configTst(), &meshValidatorMock{}, txpool, atxpool, &mockState{}, lg)
+		msh = mesh.NewRecoveredMesh(mshdb, atxdb, configTst(), &meshValidatorMock{}, txpool, atxpool, &mockState{}, nil, conf.LayersPerEpoch, lg)
 	} else {
 		lg.Info("no persistent data found ")
 		msh = mesh.NewMesh(mshdb, atxdb, configTst(), &meshValidatorMock{}, txpool, atxpool, &mockState{}, lg)
@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+)
+
+// maxNodeAtxChain is the maximum number of atxs in a response to a node atx request. A node that's further behind
+// than that on an identity's atxs syncs them with the layers that include them.
+const maxNodeAtxChain = 10
+
+// nodeAtxRequest asks a peer for the latest atx of the node with key NodeKey, with the chain of its previous atxs back
+// to, and excluding, Known.
+type nodeAtxRequest struct {
+	NodeKey string
+	Known   types.ATXID // an atx of the node the requester has, or the empty atx id for the node's first atx
+}
+
+// nodeAtxChain returns the atxs of the node in req, from its latest atx back to the atx req knows, or nil if the chain
+// back to it is longer than maxNodeAtxChain or doesn't reach it.
+func nodeAtxChain(s *Syncer, req *nodeAtxRequest) ([]*types.ActivationTx, error) {
+	id, err := s.GetNodeLastAtxID(types.NodeID{Key: req.NodeKey})
+	if err != nil {
+		return nil, err
+	}
+	var chain []*types.ActivationTx
+	for id != req.Known {
+		if id == *types.EmptyATXID || len(chain) == maxNodeAtxChain {
+			return nil, fmt.Errorf("no chain of at most %v atxs back to atx %v", maxNodeAtxChain, req.Known.ShortString())
+		}
+		atx, err := s.GetFullAtx(id)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, atx)
+		id = atx.PrevATXID
+	}
+	return chain, nil
+}
+
+func newNodeAtxRequestHandler(s *Syncer, sizeLimit int, logger log.Log) func(msg []byte) []byte {
+	return func(msg []byte) []byte {
+		var req nodeAtxRequest
+		if err := types.BytesToInterface(msg, &req); err != nil {
+			logger.With().Error("failed to unmarshal node atx request", log.Err(err))
+			return nil
+		}
+		chain, err := nodeAtxChain(s, &req)
+		if err != nil {
+			logger.With().Info("cannot serve node atx request", log.String("node_id", req.NodeKey), log.Err(err))
+			return nil
+		}
+		// the chain is sent whole or not at all, a partial chain doesn't prove the latest atx
+		w := types.NewArrayWriter(sizeLimit)
+		for _, atx := range chain {
+			if err := w.Append(atx); err != nil {
+				logger.With().Warning("cannot serve node atx request", log.String("node_id", req.NodeKey), log.Err(err))
+				return nil
+			}
+		}
+		logger.With().Info("send node atx response", log.String("node_id", req.NodeKey), log.Int("atxs", len(chain)))
+		return responseBytes(w)
+	}
+}
+
+// validateNodeAtxChain returns an error unless chain is a chain of atxs of the node with key nodeKey, from the latest
+// atx back to the atx after known.
+func validateNodeAtxChain(chain []types.ActivationTx, nodeKey string, known types.ATXID) error {
+	if len(chain) > maxNodeAtxChain {
+		return fmt.Errorf("chain of %v atxs is longer than %v", len(chain), maxNodeAtxChain)
+	}
+	for i := range chain {
+		atx := &chain[i]
+		if atx.NodeID.Key != nodeKey {
+			return fmt.Errorf("atx %v is of node %v", atx.ShortString(), atx.NodeID.ShortString())
+		}
+		prev := known
+		if i+1 < len(chain) {
+			prev = chain[i+1].ID()
+		}
+		if atx.PrevATXID != prev {
+			return fmt.Errorf("atx %v doesn't follow atx %v", atx.ShortString(), prev.ShortString())
+		}
+	}
+	return nil
+}
+
+func nodeAtxReqFactory(req nodeAtxRequest) requestFactory {
+	return func(s networker, peer p2ppeers.Peer) (chan interface{}, error) {
+		ch := make(chan interface{}, 1)
+		resHandler := func(msg []byte) {
+			defer close(ch)
+			if len(msg) == 0 {
+				s.Warning("peer %v responded with nil to node atx request", peer)
+				return
+			}
+			var chain []types.ActivationTx
+			if err := types.BytesToInterface(msg, &chain); err != nil {
+				s.Error("could not unmarshal node atx response: %v", err)
+				return
+			}
+			chain = calcAndSetIds(chain)
+			if err := validateNodeAtxChain(chain, req.NodeKey, req.Known); err != nil {
+				s.Error("peer %v sent invalid node atx response: %v", peer, err)
+				return
+			}
+			ch <- chain
+		}
+		msg, err := types.InterfaceToBytes(&req)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.SendRequest(nodeAtxMsg, msg, peer, resHandler); err != nil {
+			return nil, err
+		}
+		return ch, nil
+	}
+}
+
+// FetchNodeAtx fetches the latest atx of the node with key nodeKey from peers, together with the chain of its
+// previous atxs back to the node's latest atx this node has. The atxs are validated and stored, the latest is
+// returned.
+func (s *Syncer) FetchNodeAtx(nodeKey string) (*types.ActivationTx, error) {
+	known, err := s.GetNodeLastAtxID(types.NodeID{Key: nodeKey})
+	if err != nil {
+		known = *types.EmptyATXID
+	}
+	out := <-fetchWithFactory(newNeighborhoodWorker(s, 1, nodeAtxReqFactory(nodeAtxRequest{NodeKey: nodeKey, Known: known})))
+	if out == nil {
+		return nil, fmt.Errorf("could not fetch atx of node %v from any neighbor", nodeKey)
+	}
+	chain := out.([]types.ActivationTx)
+	if len(chain) == 0 {
+		if known == *types.EmptyATXID {
+			return nil, errors.New("node has no atxs")
+		}
+		return s.GetFullAtx(known)
+	}
+
+	// oldest first, each atx is validated against the previous one
+	for i := len(chain) - 1; i >= 0; i-- {
+		atx := &chain[i]
+		if err := s.FetchPoetProof(atx.GetPoetProofRef()); err != nil {
+			return nil, fmt.Errorf("missing PoET proof of atx %v: %v", atx.ShortString(), err)
+		}
+		if err := s.validateAtx(atx); err != nil {
+			return nil, fmt.Errorf("invalid atx %v: %v", atx.ShortString(), err)
+		}
+		if err := s.ProcessAtxs([]*types.ActivationTx{atx}); err != nil {
+			return nil, fmt.Errorf("failed to store atx %v: %v", atx.ShortString(), err)
+		}
+	}
+	return &chain[0], nil
+}
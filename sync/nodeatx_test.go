@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNodeAtxChain(t *testing.T) {
+	r := require.New(t)
+	node := types.NodeID{Key: "node"}
+	coinbase := types.HexToAddress("aaaa")
+	prev := *types.EmptyATXID
+	var atxs []types.ActivationTx
+	for i := 0; i < 3; i++ {
+		atx := newActivationTx(node, uint64(i), prev, types.LayerID(i*1000), 0, *types.EmptyATXID, coinbase, 0, nil, &types.NIPST{})
+		atx.CalcAndSetID()
+		atxs = append([]types.ActivationTx{*atx}, atxs...) // latest first
+		prev = atx.ID()
+	}
+
+	r.NoError(validateNodeAtxChain(atxs, node.Key, *types.EmptyATXID))
+	r.NoError(validateNodeAtxChain(atxs[:2], node.Key, atxs[2].ID()))
+	r.NoError(validateNodeAtxChain(nil, node.Key, atxs[0].ID()))
+
+	// the chain must reach the known atx
+	r.Error(validateNodeAtxChain(atxs[:2], node.Key, *types.EmptyATXID))
+	// without gaps
+	r.Error(validateNodeAtxChain([]types.ActivationTx{atxs[0], atxs[2]}, node.Key, *types.EmptyATXID))
+	// of the requested node
+	r.Error(validateNodeAtxChain(atxs, "other", *types.EmptyATXID))
+}
@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// RepairProgress is the progress of the data availability repair, which fetches blocks that are referenced by the
+// views of stored blocks and atxs but are missing locally.
+type RepairProgress struct {
+	Running bool
+	Runs    int           // completed runs
+	Layer   types.LayerID // the layer being scanned, or the last layer scanned
+	Latest  types.LayerID // the layer the run scans up to
+	Missing int           // missing blocks found by the current or last run
+	Fetched int           // missing blocks fetched by the current or last run
+}
+
+// repairJobID identifies the block queue job that fetches the missing blocks referenced by a layer.
+type repairJobID types.LayerID
+
+// repairProgress guards the progress of the repair.
+type repairProgress struct {
+	sync.Mutex
+	RepairProgress
+}
+
+// RepairProgress returns the progress of the current or last data availability repair run.
+func (s *Syncer) RepairProgress() RepairProgress {
+	s.repair.Lock()
+	defer s.repair.Unlock()
+	return s.repair.RepairProgress
+}
+
+func (s *Syncer) updateRepair(f func(p *RepairProgress)) {
+	s.repair.Lock()
+	f(&s.repair.RepairProgress)
+	s.repair.Unlock()
+}
+
+// repairLoop runs the repair every RepairInterval while the node is synced, until the syncer is closed.
+func (s *Syncer) repairLoop() {
+	tick := time.NewTicker(s.RepairInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.exit:
+			return
+		case <-tick.C:
+			if !s.IsSynced() {
+				s.Debug("skipping data availability repair, node is not synced")
+				continue
+			}
+			s.repairMissingBlocks()
+		}
+	}
+}
+
+// repairMissingBlocks scans the stored layers for blocks referenced by the views of their blocks, and of the atxs
+// those blocks include, that are missing locally, and fetches them. Missing blocks are left behind by interrupted or
+// failed syncs, and would fail the traversals of the views that reference them later on.
+func (s *Syncer) repairMissingBlocks() {
+	latest := s.LatestLayer()
+	s.updateRepair(func(p *RepairProgress) {
+		*p = RepairProgress{Running: true, Runs: p.Runs, Latest: latest}
+	})
+	s.With().Info("starting data availability repair", latest)
+
+	for layer := types.LayerID(1); layer <= latest; layer++ {
+		if s.shutdown() {
+			return
+		}
+		missing := s.missingViewBlocks(layer)
+		fetched := 0
+		if len(missing) > 0 {
+			s.With().Info("found missing blocks referenced by layer", layer, log.Int("missing", len(missing)))
+			fetched = s.fetchMissingBlocks(layer, missing)
+		}
+		s.updateRepair(func(p *RepairProgress) {
+			p.Layer = layer
+			p.Missing += len(missing)
+			p.Fetched += fetched
+		})
+	}
+
+	progress := s.RepairProgress()
+	s.updateRepair(func(p *RepairProgress) {
+		p.Running = false
+		p.Runs++
+	})
+	s.With().Info("finished data availability repair",
+		latest,
+		log.Int("missing", progress.Missing),
+		log.Int("fetched", progress.Fetched))
+}
+
+// missingViewBlocks returns the ids of the blocks that are referenced by the views of the blocks of layer, or of the
+// atxs they include, but are missing locally.
+func (s *Syncer) missingViewBlocks(layer types.LayerID) []types.BlockID {
+	ids, err := s.LayerBlockIds(layer)
+	if err != nil {
+		s.With().Debug("no blocks to repair in layer", layer, log.Err(err))
+		return nil
+	}
+
+	checked := make(map[types.BlockID]struct{})
+	var missing []types.BlockID
+	check := func(view []types.BlockID) {
+		for _, id := range view {
+			if _, ok := checked[id]; ok {
+				continue
+			}
+			checked[id] = struct{}{}
+			if _, err := s.GetBlock(id); err != nil {
+				missing = append(missing, id)
+			}
+		}
+	}
+	for _, id := range ids {
+		blk, err := s.GetBlock(id)
+		if err != nil {
+			s.With().Warning("failed to read block of layer", layer, id, log.Err(err))
+			continue
+		}
+		check(blk.View())
+		for _, atxID := range blk.ATXIDs {
+			atx, err := s.GetFullAtx(atxID)
+			if err != nil {
+				// the atx itself is missing, the block's data availability is the block validation's concern
+				continue
+			}
+			check(atx.View)
+		}
+	}
+	return missing
+}
+
+// fetchMissingBlocks fetches the missing blocks referenced by layer through the block queue, which validates and
+// stores them along with the missing blocks of their own views, and returns the number of blocks that were fetched.
+func (s *Syncer) fetchMissingBlocks(layer types.LayerID, missing []types.BlockID) int {
+	ch := make(chan bool, 1)
+	pending, err := s.blockQueue.addDependencies(repairJobID(layer), missing, func(res bool) error {
+		ch <- res
+		return nil
+	})
+	if err != nil {
+		s.With().Warning("failed to fetch missing blocks", layer, log.Err(err))
+		return 0
+	}
+	if pending {
+		select {
+		case <-ch:
+		case <-s.exit:
+			return 0
+		}
+	}
+
+	fetched := 0
+	for _, id := range missing {
+		if _, err := s.GetBlock(id); err == nil {
+			fetched++
+		} else {
+			s.With().Warning("failed to fetch missing block", layer, id)
+		}
+	}
+	return fetched
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/rand"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+func TestSyncer_RepairMissingBlocks(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	signer := signing.NewEdSigner()
+	n1 := sim.NewNode()
+	n2 := sim.NewNode()
+	s1 := SyncFactory("repair1", n1)
+	s2 := SyncFactory("repair2", n2)
+	s1.peers = PeersMock{func() []p2ppeers.Peer { return []p2ppeers.Peer{n2.PublicKey()} }}
+	s2.peers = PeersMock{func() []p2ppeers.Peer { return []p2ppeers.Peer{n1.PublicKey()} }}
+	defer s1.Close()
+	defer s2.Close()
+
+	missing := types.NewExistingBlock(1, []byte(rand.String(8)))
+	missing.Signature = signer.Sign(missing.Bytes())
+	missing.Initialize()
+	blk := types.NewExistingBlock(2, []byte(rand.String(8)))
+	blk.AddView(missing.ID())
+	blk.Signature = signer.Sign(blk.Bytes())
+	blk.Initialize()
+
+	r.NoError(s1.AddBlock(missing))
+	r.NoError(s1.AddBlock(blk))
+	// s2 stored blk without the block in its view
+	r.NoError(s2.AddBlock(blk))
+	r.Equal([]types.BlockID{missing.ID()}, s2.missingViewBlocks(2))
+
+	s2.repairMissingBlocks()
+	_, err := s2.GetBlock(missing.ID())
+	r.NoError(err)
+	r.Empty(s2.missingViewBlocks(2))
+
+	progress := s2.RepairProgress()
+	r.False(progress.Running)
+	r.Equal(1, progress.Runs)
+	r.Equal(types.LayerID(2), progress.Layer)
+	r.Equal(1, progress.Missing)
+	r.Equal(1, progress.Fetched)
+
+	// nothing is missing anymore
+	s2.repairMissingBlocks()
+	progress = s2.RepairProgress()
+	r.Equal(2, progress.Runs)
+	r.Zero(progress.Missing)
+}
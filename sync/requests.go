@@ -141,7 +141,9 @@ func blocksAsItems(msg []byte) ([]item, error) {
 	}
 	items := make([]item, len(blocks))
 	for i := range blocks {
-		blocks[i].Initialize()
+		if err := blocks[i].TryInitialize(); err != nil {
+			return nil, err
+		}
 		items[i] = &blocks[i]
 	}
 	return items, nil
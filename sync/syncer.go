@@ -1,21 +1,29 @@
 package sync
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/blockvalidation"
 	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/config"
 	"github.com/spacemeshos/go-spacemesh/database"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/miner"
 	p2pconf "github.com/spacemeshos/go-spacemesh/p2p/config"
 	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
 	"github.com/spacemeshos/go-spacemesh/p2p/server"
 	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/tasks"
 	"github.com/spacemeshos/go-spacemesh/timesync"
+	"github.com/spacemeshos/go-spacemesh/upgrade"
 )
 
 type forBlockInView func(view map[types.BlockID]struct{}, layer types.LayerID, blockHandler func(block *types.Block) (bool, error)) error
@@ -40,6 +48,11 @@ type blockEligibilityValidator interface {
 	BlockSignedAndEligible(block *types.Block) (bool, error)
 }
 
+// gossipStats is implemented by services that keep gossip delivery statistics.
+type gossipStats interface {
+	ReportMissedGossip(protocol string, count int)
+}
+
 type ticker interface {
 	Subscribe() timesync.LayerTimer
 	Unsubscribe(timer timesync.LayerTimer)
@@ -77,12 +90,11 @@ type Configuration struct {
 	ValidationDelta time.Duration
 	AtxsLimit       int
 	Hdist           int
+	MaxResponseSize int           // in bytes, 0 for unlimited
+	RepairInterval  time.Duration // interval of the data availability repair, 0 disables it
 }
 
 var (
-	errDupTx           = errors.New("duplicate TransactionID in block")
-	errDupAtx          = errors.New("duplicate ATXID in block")
-	errTooManyAtxs     = errors.New("too many atxs in blocks")
 	errNoBlocksInLayer = errors.New("layer has no blocks")
 
 	emptyLayer = types.Layer{}.Hash()
@@ -112,6 +124,8 @@ const (
 	txMsg               server.MessageType = 4
 	atxMsg              server.MessageType = 5
 	poetMsg             server.MessageType = 6
+	nodeAtxMsg          server.MessageType = 7
+	viewMsg             server.MessageType = 8
 	syncProtocol                           = "/sync/1.0/"
 	validatingLayerNone types.LayerID      = 0
 )
@@ -145,6 +159,12 @@ type Syncer struct {
 	blockQueue *blockQueue
 	txQueue    *txQueue
 	atxQueue   *atxQueue
+
+	gossipStats gossipStats
+
+	blockValidator *blockvalidation.Validator
+
+	repair repairProgress
 }
 
 //NewSync fires a sync every sm.SyncInterval or on force space from outside
@@ -179,24 +199,89 @@ func NewSync(srv service.Service, layers *mesh.Mesh, txpool txMemPool, atxpool a
 		awaitCh:                   make(chan struct{}),
 	}
 
+	s.blockValidator = blockvalidation.NewValidator(blockvalidation.Config{
+		LayersPerEpoch: conf.LayersPerEpoch,
+		MaxBlockSize:   blockvalidation.DefaultMaxBlockSize,
+		MaxTxs:         miner.MaxTransactionsPerBlock,
+		MaxAtxs:        conf.AtxsLimit,
+	}, bv, layers, logger.WithName("blockValidator"))
+
+	if gs, ok := srv.(gossipStats); ok {
+		s.gossipStats = gs
+	}
+
 	s.blockQueue = newValidationQueue(srvr, conf, s)
+	s.blockQueue.missed = s.missedGossip(config.NewBlockProtocol)
 	s.txQueue = newTxQueue(s)
+	s.txQueue.missed = s.missedGossip(miner.IncomingTxProtocol)
 	s.atxQueue = newAtxQueue(s, s.FetchPoetProof)
+	s.atxQueue.missed = s.missedGossip(activation.AtxProtocol)
 	srvr.RegisterBytesMsgHandler(layerHashMsg, newLayerHashRequestHandler(layers, logger))
-	srvr.RegisterBytesMsgHandler(blockMsg, newBlockRequestHandler(layers, logger))
+	srvr.RegisterBytesMsgHandler(blockMsg, newBlockRequestHandler(layers, conf.MaxResponseSize, logger))
 	srvr.RegisterBytesMsgHandler(layerIdsMsg, newLayerBlockIdsRequestHandler(layers, logger))
-	srvr.RegisterBytesMsgHandler(txMsg, newTxsRequestHandler(s, logger))
-	srvr.RegisterBytesMsgHandler(atxMsg, newAtxsRequestHandler(s, logger))
+	srvr.RegisterBytesMsgHandler(txMsg, newTxsRequestHandler(s, conf.MaxResponseSize, logger))
+	srvr.RegisterBytesMsgHandler(atxMsg, newAtxsRequestHandler(s, conf.MaxResponseSize, logger))
 	srvr.RegisterBytesMsgHandler(poetMsg, newPoetRequestHandler(s, logger))
+	srvr.RegisterBytesMsgHandler(nodeAtxMsg, newNodeAtxRequestHandler(s, conf.MaxResponseSize, logger))
+	srvr.RegisterBytesMsgHandler(viewMsg, newViewRequestHandler(s, logger))
 
 	return s
 }
 
+// SetUpgrades sets the schedule of the protocol upgrades that block validation branches on. It must be called before
+// the syncer starts.
+func (s *Syncer) SetUpgrades(upgrades *upgrade.Schedule) {
+	s.blockValidator.SetUpgrades(upgrades)
+}
+
+// missedGossip returns a function that reports items of protocol fetched by the syncer as missed by gossip. items are
+// only reported while listening to gossip, otherwise gossip isn't expected to deliver them.
+func (s *Syncer) missedGossip(protocol string) func(count int) {
+	return func(count int) {
+		if s.gossipStats != nil && s.ListenToGossip() {
+			s.gossipStats.ReportMissedGossip(protocol, count)
+		}
+	}
+}
+
 //ForceSync signals syncer to run the synchronise flow
 func (s *Syncer) ForceSync() {
 	s.forceSync <- true
 }
 
+// validateAtx syntactically validates atx, the traversal of its view is canceled when the syncer closes.
+func (s *Syncer) validateAtx(atx *types.ActivationTx) error {
+	ctx, cancel := util.StopContext(s.exit)
+	defer cancel()
+	return s.SyntacticallyValidateAtx(ctx, atx)
+}
+
+// FetchAtx fetches the atx id from peers, validates and stores it. It returns ctx's error if ctx is done first, the
+// fetch then completes in the background.
+func (s *Syncer) FetchAtx(ctx context.Context, id types.ATXID) error {
+	type result struct {
+		atxs []*types.ActivationTx
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		atxs, err := s.atxQueue.HandleAtxs([]types.ATXID{id})
+		done <- result{atxs, err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return res.err
+		}
+		if len(res.atxs) == 0 {
+			return fmt.Errorf("atx %v not found", id.ShortString())
+		}
+		return s.ProcessAtxs(res.atxs)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Close closes all running goroutines
 func (s *Syncer) Close() {
 	s.Info("Closing syncer")
@@ -280,6 +365,9 @@ func (s *Syncer) Start() {
 	if s.startLock.TryLock() {
 		s.Info("start syncer")
 		go s.run()
+		if s.RepairInterval > 0 {
+			go s.repairLoop()
+		}
 		s.forceSync <- true
 		return
 	}
@@ -400,7 +488,8 @@ func (s *Syncer) handleNotSynced(currentSyncLayer types.LayerID) {
 	// first, bring all the data of the prev layers
 	// Note: lastTicked() is not constant but updates as ticks are received
 	for ; currentSyncLayer < s.GetCurrentLayer(); currentSyncLayer++ {
-		s.With().Info("syncing layer", log.Uint64("current_sync_layer", uint64(currentSyncLayer)), log.Uint64("last_ticked_layer", uint64(s.GetCurrentLayer())))
+		lg := s.WithContext(types.LayerContext(context.Background(), currentSyncLayer, s.LayersPerEpoch))
+		lg.With().Info("syncing layer", log.Uint64("last_ticked_layer", uint64(s.GetCurrentLayer())))
 
 		if s.shutdown() {
 			return
@@ -408,13 +497,13 @@ func (s *Syncer) handleNotSynced(currentSyncLayer types.LayerID) {
 
 		lyr, err := s.getLayerFromNeighbors(currentSyncLayer)
 		if err != nil {
-			s.With().Info("could not get layer from neighbors", log.LayerID(currentSyncLayer.Uint64()), log.Err(err))
+			lg.With().Info("could not get layer from neighbors", log.Err(err))
 			return
 		}
 
 		if len(lyr.Blocks()) == 0 {
 			if err := s.SetZeroBlockLayer(currentSyncLayer); err != nil {
-				s.With().Error("handleNotSynced failed ", log.LayerID(currentSyncLayer.Uint64()), log.Err(err))
+				lg.With().Error("handleNotSynced failed ", log.Err(err))
 				return
 			}
 		}
@@ -497,8 +586,11 @@ func (s *Syncer) getLayerFromNeighbors(currentSyncLayer types.LayerID) (*types.L
 		return nil, fmt.Errorf("no peers ")
 	}
 
+	defer tasks.Start(tasks.SyncFetch, currentSyncLayer).Done()
+	lg := s.WithContext(types.LayerContext(context.Background(), currentSyncLayer, s.LayersPerEpoch))
+
 	//fetch layer hash from each peer
-	s.With().Info("fetch layer hash", log.LayerID(currentSyncLayer.Uint64()))
+	lg.Info("fetch layer hash")
 	m, err := s.fetchLayerHashes(currentSyncLayer)
 	if err != nil {
 		if err == errNoBlocksInLayer {
@@ -512,7 +604,7 @@ func (s *Syncer) getLayerFromNeighbors(currentSyncLayer types.LayerID) (*types.L
 	}
 
 	//fetch ids for each hash
-	s.With().Info("fetch layer ids", log.LayerID(currentSyncLayer.Uint64()))
+	lg.Info("fetch layer ids")
 	blockIds, err := s.fetchLayerBlockIds(m, currentSyncLayer)
 	if err != nil {
 		return nil, err
@@ -562,67 +654,39 @@ func (s *Syncer) syncLayer(layerID types.LayerID, blockIds []types.BlockID) ([]*
 }
 
 func (s *Syncer) fastValidation(block *types.Block) error {
+	return s.blockValidator.ValidateHeader(block)
+}
 
-	if len(block.ATXIDs) > s.AtxsLimit {
-		s.Error("Too many atxs in block expected<=%v actual=%v", s.AtxsLimit, len(block.ATXIDs))
-		return errTooManyAtxs
-	}
-
-	// block eligibility
-	if eligible, err := s.BlockSignedAndEligible(block); err != nil || !eligible {
-		return fmt.Errorf("block eligibiliy check failed - err %v", err)
-	}
-
-	// validate unique tx atx
-	if err := validateUniqueTxAtx(block); err != nil {
-		return err
-	}
-	return nil
+func (s *Syncer) validateContents(block *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error {
+	return s.blockValidator.ValidateContents(block, txs, atxs)
+}
 
+// blockFetcher fetches the data and the view of blocks for the block validator.
+type blockFetcher struct {
+	*Syncer
 }
 
-func validateUniqueTxAtx(b *types.Block) error {
-	// check for duplicate tx id
-	mt := make(map[types.TransactionID]struct{}, len(b.TxIDs))
-	for _, tx := range b.TxIDs {
-		if _, exist := mt[tx]; exist {
-			return errDupTx
-		}
-		mt[tx] = struct{}{}
-	}
+func (f blockFetcher) FetchData(blk *types.Block) ([]*types.Transaction, []*types.ActivationTx, error) {
+	return f.dataAvailability(blk)
+}
 
-	// check for duplicate atx id
-	ma := make(map[types.ATXID]struct{}, len(b.ATXIDs))
-	for _, atx := range b.ATXIDs {
-		if _, exist := ma[atx]; exist {
-			return errDupAtx
-		}
-		ma[atx] = struct{}{}
+func (f blockFetcher) FetchView(blk *types.Block) error {
+	if !f.validateBlockView(blk) {
+		return fmt.Errorf("block %v not syntacticly valid", blk.ID())
 	}
-
 	return nil
 }
 
 func (s *Syncer) blockSyntacticValidation(block *types.Block) ([]*types.Transaction, []*types.ActivationTx, error) {
-	// validate unique tx atx
-	if err := s.fastValidation(block); err != nil {
-		return nil, nil, err
-	}
-
-	//data availability
-	txs, atxs, err := s.dataAvailability(block)
+	txs, atxs, err := s.blockValidator.Validate(block, blockFetcher{s})
 	if err != nil {
-		return nil, nil, fmt.Errorf("DataAvailabilty failed for block %v err: %v", block.ID(), err)
-	}
-
-	//validate block's view
-	valid := s.validateBlockView(block)
-	if valid == false {
-		return nil, nil, fmt.Errorf("block %v not syntacticly valid", block.ID())
+		s.tombstone(block, err)
+		return nil, nil, err
 	}
 
 	//validate block's votes
 	if valid, err := validateVotes(block, s.ForBlockInView, s.Hdist, s.Log); valid == false || err != nil {
+		s.tombstone(block, err)
 		return nil, nil, fmt.Errorf("validate votes failed for block %v, %v", block.ID(), err)
 	}
 
@@ -640,7 +704,11 @@ func (s *Syncer) validateBlockView(blk *types.Block) bool {
 		ch <- res
 		return nil
 	}
-	if res, err := s.blockQueue.addDependencies(blk.ID(), blk.ViewEdges, foo); err != nil {
+	if err := s.expandView(blk); err != nil {
+		s.With().Error("failed to expand compact view", blk.ID(), log.Err(err))
+		return false
+	}
+	if res, err := s.blockQueue.addDependencies(blk.ID(), blk.View(), foo); err != nil {
 		s.Error(fmt.Sprintf("block %v not syntactically valid", blk.ID()), err)
 		return false
 	} else if res == false {
@@ -655,7 +723,7 @@ func (s *Syncer) validateBlockView(blk *types.Block) bool {
 
 func validateVotes(blk *types.Block, forBlockfunc forBlockInView, depth int, lg log.Log) (bool, error) {
 	view := map[types.BlockID]struct{}{}
-	for _, b := range blk.ViewEdges {
+	for _, b := range blk.View() {
 		view[b] = struct{}{}
 	}
 
@@ -678,7 +746,7 @@ func validateVotes(blk *types.Block, forBlockfunc forBlockInView, depth int, lg
 	}
 	err := forBlockfunc(view, lowestLayer, traverse)
 	if err == nil && len(vote) > 0 {
-		return false, fmt.Errorf("voting on blocks out of view (or out of Hdist), %v %s", vote, err)
+		return false, fmt.Errorf("%v: %v", errVotesOutOfView, vote)
 	}
 
 	if err != nil {
@@ -732,7 +800,7 @@ func (s *Syncer) fetchLayerBlockIds(m map[types.Hash32][]p2ppeers.Peer, lyr type
 	for h, peers := range m {
 	NextHash:
 		for _, peer := range peers {
-			s.Debug("send request Peer: %v", peer)
+			s.With().Debug("send layer ids request", lyr, log.PeerID(peer.String()))
 			ch, err := layerIdsReqFactory(lyr)(s, peer)
 			if err != nil {
 				return nil, err
@@ -744,16 +812,16 @@ func (s *Syncer) fetchLayerBlockIds(m map[types.Hash32][]p2ppeers.Peer, lyr type
 				s.Debug("worker received interrupt")
 				return nil, fmt.Errorf("interupt")
 			case <-timeout:
-				s.Error("layer ids request to %v timed out", peer)
+				s.With().Error("layer ids request timed out", lyr, log.PeerID(peer.String()))
 				continue
 			case v := <-ch:
 				if v != nil {
-					s.Debug("Peer: %v responded to layer ids request", peer)
+					s.With().Debug("peer responded to layer ids request", lyr, log.PeerID(peer.String()))
 					//peer returned set with bad hash ask next peer
 					res := types.CalcBlocksHash32(v.([]types.BlockID), nil)
 
 					if h != res {
-						s.Warning("Peer: %v layer ids hash does not match request", peer)
+						s.With().Warning("layer ids hash does not match request", lyr, log.PeerID(peer.String()))
 					}
 
 					for _, bid := range v.([]types.BlockID) {
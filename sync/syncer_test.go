@@ -19,6 +19,7 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/blockvalidation"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/common/util"
 	"github.com/spacemeshos/go-spacemesh/database"
@@ -33,7 +34,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/timesync"
 )
 
-var conf = Configuration{1000, 1, 300, 500 * time.Millisecond, 200 * time.Millisecond, 10 * time.Hour, 100, 5}
+var conf = Configuration{1000, 1, 300, 500 * time.Millisecond, 200 * time.Millisecond, 10 * time.Hour, 100, 5, 0, 0}
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
@@ -902,7 +903,7 @@ func atx(pubkey string) *types.ActivationTx {
 	poetRef := []byte{0xde, 0xad}
 	npst := activation.NewNIPSTWithChallenge(&chlng, poetRef)
 
-	atx := newActivationTx(types.NodeID{Key: pubkey, VRFPublicKey: []byte(rand.String(8))}, 0, *types.EmptyATXID, 5, 1, *types.EmptyATXID, coinbase, 0, nil, npst)
+	atx := newActivationTx(types.NodeID{Key: pubkey, VRFPublicKey: []byte(rand.String(8))}, 0, *types.EmptyATXID, 5, 0, *types.EmptyATXID, coinbase, 0, nil, npst)
 	atx.Commitment = commitment
 	atx.CommitmentMerkleRoot = commitment.MerkleRoot
 	atx.CalcAndSetID()
@@ -1558,26 +1559,6 @@ var atx1 = types.ATXID(one)
 var atx2 = types.ATXID(two)
 var atx3 = types.ATXID(three)
 
-func Test_validateUniqueTxAtx(t *testing.T) {
-	r := require.New(t)
-	b := &types.Block{}
-
-	// unique
-	b.TxIDs = []types.TransactionID{txid1, txid2, txid3}
-	b.ATXIDs = []types.ATXID{atx1, atx2, atx3}
-	r.Nil(validateUniqueTxAtx(b))
-
-	// dup txs
-	b.TxIDs = []types.TransactionID{txid1, txid2, txid1}
-	b.ATXIDs = []types.ATXID{atx1, atx2, atx3}
-	r.EqualError(validateUniqueTxAtx(b), errDupTx.Error())
-
-	// dup atxs
-	b.TxIDs = []types.TransactionID{txid1, txid2, txid3}
-	b.ATXIDs = []types.ATXID{atx1, atx2, atx1}
-	r.EqualError(validateUniqueTxAtx(b), errDupAtx.Error())
-}
-
 func TestSyncer_BlockSyntacticValidation(t *testing.T) {
 	r := require.New(t)
 	syncs, _, _ := SyncMockFactory(2, conf, "TestSyncProtocol_NilResponse", memoryDB, newMemPoetDb)
@@ -1586,13 +1567,13 @@ func TestSyncer_BlockSyntacticValidation(t *testing.T) {
 	b.TxIDs = []types.TransactionID{txid1, txid2, txid1}
 	b.ATXIDs = []types.ATXID{atx1, atx2, atx3}
 	_, _, err := s.blockSyntacticValidation(b)
-	r.EqualError(err, errDupTx.Error())
+	r.EqualError(err, blockvalidation.ErrDupTx.Error())
 
 	for i := 0; i <= miner.AtxsPerBlockLimit; i++ {
 		b.ATXIDs = append(b.ATXIDs, atx1)
 	}
 	_, _, err = s.blockSyntacticValidation(b)
-	r.EqualError(err, errTooManyAtxs.Error())
+	r.EqualError(err, blockvalidation.ErrTooManyAtxs.Error())
 
 	b.TxIDs = []types.TransactionID{}
 	b.ATXIDs = []types.ATXID{}
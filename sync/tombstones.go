@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/blockvalidation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+)
+
+var (
+	// errTombstoned is returned for blocks whose view has blocks that were found invalid.
+	errTombstoned = errors.New("blocks in view were found invalid")
+	// errVotesOutOfView is returned for blocks that vote for blocks out of their view, or out of Hdist.
+	errVotesOutOfView = errors.New("voting on blocks out of view (or out of Hdist)")
+)
+
+// tombstoneErrors are the validation errors that prove a block invalid, by the reason they prove it. Other errors, e.g.
+// of data or view that can't be fetched or of atxs that aren't known yet, don't: the block may be found valid later.
+var tombstoneErrors = []struct {
+	err    error
+	reason mesh.TombstoneReason
+}{
+	{blockvalidation.ErrTooLarge, mesh.InvalidHeader},
+	{blockvalidation.ErrTooManyTxs, mesh.InvalidHeader},
+	{blockvalidation.ErrTooManyAtxs, mesh.InvalidHeader},
+	{blockvalidation.ErrDupTx, mesh.InvalidHeader},
+	{blockvalidation.ErrDupAtx, mesh.InvalidHeader},
+	{blockvalidation.ErrAtxEpoch, mesh.InvalidHeader},
+	{blockvalidation.ErrCompactView, mesh.InvalidHeader},
+	{blockvalidation.ErrTxsMismatch, mesh.InvalidContents},
+	{blockvalidation.ErrAtxsMismatch, mesh.InvalidContents},
+	{blockvalidation.ErrGasLimit, mesh.InvalidContents},
+	{errTombstoned, mesh.InvalidView},
+	{errVotesOutOfView, mesh.InvalidVotes},
+}
+
+// tombstoneReason returns the reason that err proves a block invalid, and false if it doesn't.
+func tombstoneReason(err error) (mesh.TombstoneReason, bool) {
+	// the eligibility error is wrapped with the error that prevented checking eligibility, only the bare one proves it
+	if err == blockvalidation.ErrNotEligible {
+		return mesh.InvalidHeader, true
+	}
+	for _, e := range tombstoneErrors {
+		if strings.HasPrefix(err.Error(), e.err.Error()) {
+			return e.reason, true
+		}
+	}
+	return 0, false
+}
+
+// tombstone records the tombstone of blk if its validation failed with an error that proves it invalid.
+func (s *Syncer) tombstone(blk *types.Block, err error) {
+	reason, ok := tombstoneReason(err)
+	if !ok {
+		return
+	}
+	t := &mesh.Tombstone{Block: blk.ID(), Layer: blk.Layer(), Reason: reason, Detail: err.Error()}
+	if err := s.SaveTombstone(t); err != nil {
+		s.With().Error("failed to save block tombstone", blk.ID(), log.Err(err))
+		return
+	}
+	s.With().Info("block found invalid", blk.ID(), blk.Layer(), log.String("reason", reason.String()))
+}
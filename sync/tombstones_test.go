@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/blockvalidation"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTombstoneReason(t *testing.T) {
+	r := require.New(t)
+	for _, tc := range []struct {
+		err    error
+		reason mesh.TombstoneReason
+		ok     bool
+	}{
+		{blockvalidation.ErrNotEligible, mesh.InvalidHeader, true},
+		{fmt.Errorf("%v: %v", blockvalidation.ErrTooLarge, "2000 bytes, max 1000"), mesh.InvalidHeader, true},
+		{blockvalidation.ErrAtxsMismatch, mesh.InvalidContents, true},
+		{fmt.Errorf("%v: limit 100", blockvalidation.ErrGasLimit), mesh.InvalidContents, true},
+		{fmt.Errorf("%v: block 1234: invalid header", errTombstoned), mesh.InvalidView, true},
+		{fmt.Errorf("%v: map[]", errVotesOutOfView), mesh.InvalidVotes, true},
+		// the eligibility of the block couldn't be checked
+		{fmt.Errorf("%v: %v", blockvalidation.ErrNotEligible, "atx not found"), 0, false},
+		{fmt.Errorf("%v: %v", blockvalidation.ErrViewUnavailable, "timeout"), 0, false},
+		{errors.New("DataAvailabilty failed for block"), 0, false},
+	} {
+		reason, ok := tombstoneReason(tc.err)
+		r.Equal(tc.ok, ok, tc.err.Error())
+		r.Equal(tc.reason, reason, tc.err.Error())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/log"
 	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/tasks"
 	"reflect"
 	"sync"
 )
@@ -18,7 +19,11 @@ type syncer interface {
 	dataAvailability(blk *types.Block) ([]*types.Transaction, []*types.ActivationTx, error)
 	getValidatingLayer() types.LayerID
 	fastValidation(block *types.Block) error
+	validateContents(block *types.Block, txs []*types.Transaction, atxs []*types.ActivationTx) error
 	blockCheckLocal(blockIds []types.Hash32) (map[types.Hash32]item, map[types.Hash32]item, []types.Hash32)
+	expandView(blk *types.Block) error
+	GetTombstone(id types.BlockID) (*mesh.Tombstone, error)
+	tombstone(blk *types.Block, err error)
 }
 
 type blockQueue struct {
@@ -85,9 +90,11 @@ func (vq *blockQueue) handleBlocks(bjb fetchJob) {
 }
 
 func (vq *blockQueue) handleBlock(id types.Hash32, block *types.Block) {
+	defer tasks.Start(tasks.ValidationWorker, block.Layer()).Done()
 	vq.With().Info("start handling", block.ID(), block.MinerID())
 	if err := vq.fastValidation(block); err != nil {
 		vq.Error("block validation failed", block.ID(), log.Err(err))
+		vq.tombstone(block, err)
 		vq.updateDependencies(id, false)
 		return
 	}
@@ -99,11 +106,17 @@ func (vq *blockQueue) handleBlock(id types.Hash32, block *types.Block) {
 // if there are unknown blocks in the view they are added to the fetch queue
 func (vq *blockQueue) handleBlockDependencies(blk *types.Block) {
 	vq.With().Debug("handle dependencies", blk.ID())
-	res, err := vq.addDependencies(blk.ID(), blk.ViewEdges, vq.finishBlockCallback(blk))
+	if err := vq.expandView(blk); err != nil {
+		vq.updateDependencies(blk.Hash32(), false)
+		vq.With().Error("failed to expand compact view", blk.ID(), log.Err(err))
+		return
+	}
+	res, err := vq.addDependencies(blk.ID(), blk.View(), vq.finishBlockCallback(blk))
 
 	if err != nil {
 		vq.updateDependencies(blk.Hash32(), false)
 		vq.With().Error("failed to add dependencies", blk.ID(), log.Err(err))
+		vq.tombstone(blk, err)
 		return
 	}
 
@@ -126,9 +139,14 @@ func (vq *blockQueue) finishBlockCallback(block *types.Block) func(res bool) err
 		if err != nil {
 			return fmt.Errorf("DataAvailabilty failed for block: %v errmsg: %v", block.ID().String(), err)
 		}
+		if err := vq.validateContents(block, txs, atxs); err != nil {
+			vq.tombstone(block, err)
+			return fmt.Errorf("contents validation failed for block: %v errmsg: %v", block.ID().String(), err)
+		}
 
 		// validate block's votes
 		if valid, err := validateVotes(block, vq.ForBlockInView, vq.Hdist, vq.Log); valid == false || err != nil {
+			vq.tombstone(block, err)
 			return fmt.Errorf("validate votes failed for block: %s errmsg: %s", block.ID().String(), err)
 		}
 
@@ -208,6 +226,26 @@ func (vq *blockQueue) addDependencies(jobID interface{}, blks []types.BlockID, f
 		return false, fmt.Errorf("job %s already exsits", jobID)
 	}
 
+	// blocks that were found invalid aren't fetched again: a block that has them in its view is invalid, and a layer
+	// is synced without them
+	valid := make([]types.BlockID, 0, len(blks))
+	for _, id := range blks {
+		if !vq.inQueue(id.AsHash32()) {
+			if _, err := vq.GetBlock(id); err != nil {
+				if t, err := vq.GetTombstone(id); err == nil {
+					if _, isBlock := jobID.(types.BlockID); isBlock {
+						vq.Unlock()
+						return false, fmt.Errorf("%v: block %v: %v", errTombstoned, id, t.Reason)
+					}
+					vq.With().Info("skipping block found invalid", id, log.String("reason", t.Reason.String()))
+					continue
+				}
+			}
+		}
+		valid = append(valid, id)
+	}
+	blks = valid
+
 	dependencies := make(map[types.Hash32]struct{})
 	idsToPush := make([]types.Hash32, 0, len(blks))
 	for _, id := range blks {
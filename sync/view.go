@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/log"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+)
+
+// newViewRequestHandler serves the base sets of the compact view of a block, which peers whose blocks of the layers
+// the view references don't match the layers' hashes expand the view with, see types.ViewBaseSets.
+func newViewRequestHandler(s *Syncer, logger log.Log) func(msg []byte) []byte {
+	return func(msg []byte) []byte {
+		if len(msg) != types.Hash32Length {
+			logger.Error("received view request with a block id of length %v", len(msg))
+			return nil
+		}
+		id := types.BlockID(types.BytesToHash(msg).ToHash20())
+		blk, err := s.GetBlock(id)
+		if err != nil || len(blk.CompactView) == 0 {
+			logger.With().Info("cannot serve view request", id, log.Err(err))
+			return nil
+		}
+		bases, err := types.ViewBaseSets(blk.CompactView, blk.View(), s.blockLayer)
+		if err != nil {
+			logger.With().Error("failed to get base sets of compact view", id, log.Err(err))
+			return nil
+		}
+		b, err := types.InterfaceToBytes(bases)
+		if err != nil {
+			logger.With().Error("failed to marshal base sets of compact view", id, log.Err(err))
+			return nil
+		}
+		return b
+	}
+}
+
+func (s *Syncer) blockLayer(id types.BlockID) (types.LayerID, error) {
+	blk, err := s.GetBlock(id)
+	if err != nil {
+		return 0, err
+	}
+	return blk.Layer(), nil
+}
+
+// viewReqFactory requests the base sets of the compact view of blk, and outputs the view expanded with them. Base
+// sets that don't match the hashes of the view are dropped.
+func viewReqFactory(blk *types.Block) requestFactory {
+	return func(s networker, peer p2ppeers.Peer) (chan interface{}, error) {
+		ch := make(chan interface{}, 1)
+		resHandler := func(msg []byte) {
+			defer close(ch)
+			if len(msg) == 0 {
+				s.Warning("peer %v responded with nil to view request of block %v", peer, blk.ID())
+				return
+			}
+			var bases [][]types.BlockID
+			if err := types.BytesToInterface(msg, &bases); err != nil {
+				s.Error("could not unmarshal view response: %v", err)
+				return
+			}
+			if len(bases) != len(blk.CompactView) {
+				s.Error("peer %v sent %v base sets for a compact view of %v layers", peer, len(bases), len(blk.CompactView))
+				return
+			}
+			view, err := types.ExpandView(blk.CompactView, func(i int, _ types.LayerView) ([]types.BlockID, error) {
+				return bases[i], nil
+			})
+			if err != nil {
+				s.Error("peer %v sent invalid view of block %v: %v", peer, blk.ID(), err)
+				return
+			}
+			ch <- view
+		}
+		if err := s.SendRequest(viewMsg, blk.ID().Bytes(), peer, resHandler); err != nil {
+			return nil, err
+		}
+		return ch, nil
+	}
+}
+
+// expandView expands the compact view of blk with the blocks of the layers it references, or with the base sets of a
+// neighbor if the blocks of a layer don't match the layer's hash, e.g. because blocks of the layer arrived after blk
+// was created. Blocks that list their view, and blocks whose view was expanded already, are left as is.
+func (s *Syncer) expandView(blk *types.Block) error {
+	if len(blk.CompactView) == 0 || blk.View() != nil {
+		return nil
+	}
+	view, err := types.ExpandView(blk.CompactView, func(_ int, lv types.LayerView) ([]types.BlockID, error) {
+		return s.LayerBlockIds(lv.Layer)
+	})
+	if err != nil {
+		s.With().Info("fetching compact view from neighbors", blk.ID(), log.Err(err))
+		out := <-fetchWithFactory(newNeighborhoodWorker(s, 1, viewReqFactory(blk)))
+		if out == nil {
+			return fmt.Errorf("could not expand compact view of block %v: %v", blk.ID(), err)
+		}
+		view = out.([]types.BlockID)
+	}
+	blk.SetView(view)
+	return nil
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	p2ppeers "github.com/spacemeshos/go-spacemesh/p2p/peers"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+)
+
+func TestSyncer_ExpandView(t *testing.T) {
+	r := require.New(t)
+	sim := service.NewSimulator()
+	n1 := sim.NewNode()
+	n2 := sim.NewNode()
+	s1 := SyncFactory("view1", n1)
+	s2 := SyncFactory("view2", n2)
+	s1.peers = PeersMock{func() []p2ppeers.Peer { return []p2ppeers.Peer{n2.PublicKey()} }}
+	s2.peers = PeersMock{func() []p2ppeers.Peer { return []p2ppeers.Peer{n1.PublicKey()} }}
+	defer s1.Close()
+	defer s2.Close()
+
+	a := types.NewExistingBlock(1, []byte("a"))
+	b := types.NewExistingBlock(1, []byte("b"))
+	for _, s := range []*Syncer{s1, s2} {
+		r.NoError(s.AddBlock(a))
+		r.NoError(s.AddBlock(b))
+	}
+	view := types.SortBlockIDs([]types.BlockID{a.ID(), b.ID()})
+
+	blk := types.NewExistingBlock(2, []byte("c"))
+	blk.CompactView = []types.LayerView{{Layer: 1, Hash: types.CalcBlocksHash32(view, nil)}}
+	blk.Initialize()
+	received := func() *types.Block {
+		rcv := &types.Block{MiniBlock: blk.MiniBlock, Signature: blk.Signature}
+		rcv.Initialize()
+		return rcv
+	}
+
+	// s1 has the blocks of the layer the view references
+	rcv := received()
+	r.Nil(rcv.View())
+	r.NoError(s1.expandView(rcv))
+	r.Equal(view, rcv.View())
+	r.NoError(s1.AddBlock(rcv))
+
+	// s2 got a late block of the layer, and expands the view with s1's base sets
+	r.NoError(s2.AddBlock(types.NewExistingBlock(1, []byte("late"))))
+	rcv = received()
+	r.NoError(s2.expandView(rcv))
+	r.Equal(view, rcv.View())
+
+	// nobody can expand a view of unknown blocks
+	unknown := types.NewExistingBlock(2, []byte("d"))
+	unknown.CompactView = []types.LayerView{{Layer: 1, Hash: types.Hash32{1}}}
+	unknown.Initialize()
+	r.Error(s2.expandView(unknown))
+}
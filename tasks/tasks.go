@@ -0,0 +1,175 @@
+// Package tasks counts the goroutines that the node runs for a layer, e.g. the sync of a layer or its hare instance,
+// by layer and epoch, so that tasks that never terminate, e.g. hare instances of layers that failed, show as tasks of
+// old layers on the debug endpoint.
+package tasks
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// The kinds of tasks that are counted.
+const (
+	SyncFetch        = "sync-fetch"        // the fetch of a layer's blocks from peers
+	ValidationWorker = "validation-worker" // the validation of a fetched block
+	HareInstance     = "hare-instance"     // a hare consensus process, from its start to its output
+)
+
+// Task is a running task, Done ends it.
+type Task struct {
+	tracker *Tracker
+	kind    string
+	layer   types.LayerID
+	start   time.Time
+	done    int32
+}
+
+// Done ends the task. Only the first call counts, so it can be deferred on all paths.
+func (t *Task) Done() {
+	if t == nil || !atomic.CompareAndSwapInt32(&t.done, 0, 1) {
+		return
+	}
+	t.tracker.done(t)
+}
+
+// Tracker counts the tasks started and running by kind and layer.
+type Tracker struct {
+	mu      sync.Mutex
+	running map[*Task]struct{}
+	started map[string]uint64
+	ended   map[string]uint64
+	now     func() time.Time
+}
+
+// NewTracker returns a Tracker without tasks.
+func NewTracker() *Tracker {
+	return &Tracker{
+		running: make(map[*Task]struct{}),
+		started: make(map[string]uint64),
+		ended:   make(map[string]uint64),
+		now:     time.Now,
+	}
+}
+
+// Start starts a task of kind for layer, the caller calls Done when the task ends.
+func (tr *Tracker) Start(kind string, layer types.LayerID) *Task {
+	t := &Task{tracker: tr, kind: kind, layer: layer, start: tr.now()}
+	tr.mu.Lock()
+	tr.running[t] = struct{}{}
+	tr.started[kind]++
+	tr.mu.Unlock()
+	return t
+}
+
+func (tr *Tracker) done(t *Task) {
+	tr.mu.Lock()
+	delete(tr.running, t)
+	tr.ended[t.kind]++
+	tr.mu.Unlock()
+}
+
+// KindStats are the counts of a kind of task.
+type KindStats struct {
+	Started uint64 `json:"started"`
+	Done    uint64 `json:"done"`
+	Running int    `json:"running"`
+}
+
+// LayerStats are the tasks running for a layer.
+type LayerStats struct {
+	Layer   types.LayerID  `json:"layer"`
+	Epoch   types.EpochID  `json:"epoch"`
+	Running map[string]int `json:"running"` // by kind
+	Oldest  float64        `json:"oldest_seconds"`
+}
+
+// EpochStats are the tasks running for the layers of an epoch.
+type EpochStats struct {
+	Epoch   types.EpochID  `json:"epoch"`
+	Running map[string]int `json:"running"` // by kind
+}
+
+// Snapshot is the state of the tasks at a point in time. Layers and epochs are in ascending order, and only those with
+// running tasks are listed.
+type Snapshot struct {
+	Kinds  map[string]KindStats `json:"kinds"`
+	Epochs []EpochStats         `json:"epochs"`
+	Layers []LayerStats         `json:"layers"`
+}
+
+// Snapshot returns the counts of the tasks, grouped in epochs of layersPerEpoch layers.
+func (tr *Tracker) Snapshot(layersPerEpoch uint16) Snapshot {
+	now := tr.now()
+	snap := Snapshot{Kinds: make(map[string]KindStats)}
+	layers := make(map[types.LayerID]*LayerStats)
+	epochs := make(map[types.EpochID]*EpochStats)
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for kind, started := range tr.started {
+		snap.Kinds[kind] = KindStats{Started: started, Done: tr.ended[kind]}
+	}
+	for t := range tr.running {
+		kind := snap.Kinds[t.kind]
+		kind.Running++
+		snap.Kinds[t.kind] = kind
+
+		ls, ok := layers[t.layer]
+		if !ok {
+			ls = &LayerStats{Layer: t.layer, Epoch: t.layer.GetEpoch(layersPerEpoch), Running: make(map[string]int)}
+			layers[t.layer] = ls
+		}
+		ls.Running[t.kind]++
+		if age := now.Sub(t.start).Seconds(); age > ls.Oldest {
+			ls.Oldest = age
+		}
+
+		es, ok := epochs[ls.Epoch]
+		if !ok {
+			es = &EpochStats{Epoch: ls.Epoch, Running: make(map[string]int)}
+			epochs[ls.Epoch] = es
+		}
+		es.Running[t.kind]++
+	}
+
+	for _, ls := range layers {
+		snap.Layers = append(snap.Layers, *ls)
+	}
+	sort.Slice(snap.Layers, func(i, j int) bool { return snap.Layers[i].Layer < snap.Layers[j].Layer })
+	for _, es := range epochs {
+		snap.Epochs = append(snap.Epochs, *es)
+	}
+	sort.Slice(snap.Epochs, func(i, j int) bool { return snap.Epochs[i].Epoch < snap.Epochs[j].Epoch })
+	return snap
+}
+
+// Handler returns an http handler that writes the tracker's snapshot as json.
+func (tr *Tracker) Handler(layersPerEpoch uint16) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(tr.Snapshot(layersPerEpoch)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// defaultTracker is the tracker of the node's tasks, that Start adds to.
+var defaultTracker = NewTracker()
+
+// Start starts a task of kind for layer on the node's tracker, the caller calls Done when the task ends.
+func Start(kind string, layer types.LayerID) *Task {
+	return defaultTracker.Start(kind, layer)
+}
+
+// Handler returns an http handler that writes the snapshot of the node's tasks as json.
+func Handler(layersPerEpoch uint16) http.Handler {
+	return defaultTracker.Handler(layersPerEpoch)
+}
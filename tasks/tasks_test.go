@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker_Snapshot(t *testing.T) {
+	r := require.New(t)
+	tr := NewTracker()
+	now := time.Unix(1000, 0)
+	tr.now = func() time.Time { return now }
+
+	leaked := tr.Start(HareInstance, 3)
+	now = now.Add(time.Minute)
+	tr.Start(HareInstance, 12)
+	fetch := tr.Start(SyncFetch, 12)
+	validation := tr.Start(ValidationWorker, 13)
+	now = now.Add(time.Second)
+
+	fetch.Done()
+	fetch.Done()
+	validation.Done()
+
+	snap := tr.Snapshot(10)
+	r.Equal(KindStats{Started: 2, Running: 2}, snap.Kinds[HareInstance])
+	r.Equal(KindStats{Started: 1, Done: 1}, snap.Kinds[SyncFetch])
+	r.Equal(KindStats{Started: 1, Done: 1}, snap.Kinds[ValidationWorker])
+	r.Equal([]EpochStats{
+		{Epoch: 0, Running: map[string]int{HareInstance: 1}},
+		{Epoch: 1, Running: map[string]int{HareInstance: 1}},
+	}, snap.Epochs)
+	r.Equal([]LayerStats{
+		{Layer: 3, Epoch: 0, Running: map[string]int{HareInstance: 1}, Oldest: 61},
+		{Layer: 12, Epoch: 1, Running: map[string]int{HareInstance: 1}, Oldest: 1},
+	}, snap.Layers)
+
+	leaked.Done()
+	r.Len(tr.Snapshot(10).Layers, 1)
+}
+
+func TestTracker_Handler(t *testing.T) {
+	r := require.New(t)
+	tr := NewTracker()
+	tr.Start(HareInstance, 5)
+
+	rec := httptest.NewRecorder()
+	tr.Handler(10).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/tasks", nil))
+	r.Equal("application/json", rec.Header().Get("Content-Type"))
+	var snap Snapshot
+	r.NoError(json.Unmarshal(rec.Body.Bytes(), &snap))
+	r.Equal(1, snap.Kinds[HareInstance].Running)
+	r.Len(snap.Layers, 1)
+}
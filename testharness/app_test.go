@@ -0,0 +1,377 @@
+// +build !exclude_app_test
+
+package testharness
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/activation"
+	apicfg "github.com/spacemeshos/go-spacemesh/api/config"
+	"github.com/spacemeshos/go-spacemesh/cmd/node"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	"github.com/spacemeshos/go-spacemesh/pendingtxs"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	numOfAppNodes  = 5
+	numberOfEpochs = 5 // first 2 epochs are genesis
+)
+
+func appTestConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.POST = activation.DefaultConfig()
+	cfg.POST.Difficulty = 5
+	cfg.POST.NumProvenLabels = 10
+	cfg.POST.SpacePerUnit = 1 << 10 // 1KB.
+	cfg.POST.NumFiles = 1
+
+	cfg.HARE.N = 5
+	cfg.HARE.F = 2
+	cfg.HARE.RoundDuration = 3
+	cfg.HARE.WakeupDelta = 5
+	cfg.HARE.ExpectedLeaders = 5
+	cfg.HARE.SuperHare = true
+	cfg.LayerAvgSize = 5
+	cfg.LayersPerEpoch = 3
+	cfg.Hdist = 5
+
+	cfg.LayerDurationSec = 20
+	cfg.HareEligibility.ConfidenceParam = 4
+	cfg.HareEligibility.EpochOffset = 0
+	cfg.StartMining = true
+	cfg.SyncRequestTimeout = 2000
+	cfg.SyncInterval = 2
+	cfg.SyncValidationDelta = 5
+	return &cfg
+}
+
+// appScenario is a condition that the nodes are expected to reach. Criteria is polled until it returns true, after all
+// the scenarios it depends on are done.
+type appScenario struct {
+	Setup        func(h *Harness) error
+	Criteria     func(h *Harness) (bool, error)
+	Dependencies []int
+}
+
+func noSetup(*Harness) error {
+	return nil
+}
+
+// projectedNonce returns the nonce of addr on the given node, including transactions that weren't applied yet.
+func projectedNonce(app *node.SpacemeshApp, addr types.Address) (uint64, error) {
+	projector := pendingtxs.NewMeshAndPoolProjector(app.Mesh(), app.TxPool())
+	nonce, _, err := projector.GetProjection(addr, app.State().GetNonce(addr), app.State().GetBalance(addr))
+	return nonce, err
+}
+
+func txWithUnorderedNonceGenerator(dependencies []int) appScenario {
+	signer, err := signing.NewEdSignerFromBuffer(util.FromHex(apicfg.Account2Private))
+	addr := types.Address{}
+	dst := types.BytesToAddress([]byte{0x09})
+	txsSent := 25
+	setup := func(h *Harness) error {
+		if err != nil {
+			return fmt.Errorf("could not build ed signer: %v", err)
+		}
+		addr.SetBytes(signer.PublicKey().Bytes())
+		// none of the transactions has the next nonce, so the nodes are expected to drop all of them
+		for i := 0; i < txsSent; i++ {
+			tx, err := mesh.NewSignedTx(uint64(txsSent-i), dst, 10, 1, 1, signer)
+			if err != nil {
+				return fmt.Errorf("failed to create signed tx: %v", err)
+			}
+			if err := h.SubmitTx(0, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	criteria := func(h *Harness) (bool, error) {
+		for _, app := range h.Apps() {
+			balance, nonce := app.State().GetBalance(dst), app.State().GetNonce(addr)
+			if balance != 0 || nonce != 0 {
+				return false, fmt.Errorf("node %v applied an out of order tx, balance: %d nonce: %d",
+					app.NodeID().ShortString(), balance, nonce)
+			}
+		}
+		log.Info("zero addresses ok")
+		return true, nil
+	}
+
+	return appScenario{setup, criteria, dependencies}
+}
+
+func txWithRunningNonceGenerator(dependencies []int) appScenario {
+	signer, err := signing.NewEdSignerFromBuffer(util.FromHex(apicfg.Account1Private))
+	addr := types.Address{}
+	dst := types.BytesToAddress([]byte{0x02})
+	txsSent := 25
+	sent := 0
+	setup := func(h *Harness) error {
+		if err != nil {
+			return fmt.Errorf("could not build ed signer: %v", err)
+		}
+		addr.SetBytes(signer.PublicKey().Bytes())
+		return nil
+	}
+
+	criteria := func(h *Harness) (bool, error) {
+		// the next tx is sent once the previous one was accepted, so that every tx has a valid nonce when it arrives
+		if sent < txsSent {
+			nonce, err := projectedNonce(h.Apps()[0], addr)
+			if err != nil {
+				return false, err
+			}
+			if nonce == uint64(sent) {
+				tx, err := mesh.NewSignedTx(uint64(sent), dst, 10, 1, 1, signer)
+				if err != nil {
+					return false, fmt.Errorf("failed to create signed tx: %v", err)
+				}
+				if err := h.SubmitTx(0, tx); err != nil {
+					return false, err
+				}
+				sent++
+			}
+			return false, nil
+		}
+		for _, app := range h.Apps() {
+			balance, nonce := app.State().GetBalance(dst), app.State().GetNonce(addr)
+			log.Info("current balance: %d nonce %d", balance, nonce)
+			if balance < 250 || nonce != uint64(txsSent) {
+				return false, nil
+			}
+		}
+		log.Info("addresses ok")
+		return true, nil
+	}
+
+	return appScenario{setup, criteria, dependencies}
+}
+
+func reachedEpochTester(dependencies []int) appScenario {
+	criteria := func(h *Harness) (bool, error) {
+		apps := h.Apps()
+		for _, app := range apps {
+			if uint32(app.Mesh().LatestLayer()) < numberOfEpochs*uint32(app.Config.LayersPerEpoch) {
+				return false, nil
+			}
+		}
+		for _, app := range apps {
+			atx, err := lastAtx(app)
+			if err != nil {
+				return false, err
+			}
+			if int(atx.ActiveSetSize) != len(apps) {
+				return false, fmt.Errorf("atx %v of node %v has active set size %d, expected %d",
+					atx.ShortString(), app.NodeID().ShortString(), atx.ActiveSetSize, len(apps))
+			}
+		}
+		log.Info("epoch ok")
+		return true, nil
+	}
+	return appScenario{noSetup, criteria, dependencies}
+}
+
+func sameRootTester(dependencies []int) appScenario {
+	criteria := func(h *Harness) (bool, error) {
+		if err := h.CheckStateRoots(); err != nil {
+			log.Info("state roots don't match yet: %v", err)
+			return false, nil
+		}
+		return true, nil
+	}
+	return appScenario{noSetup, criteria, dependencies}
+}
+
+func lastAtx(app *node.SpacemeshApp) (*types.ActivationTxHeader, error) {
+	id, err := app.AtxDB().GetNodeLastAtxID(app.NodeID())
+	if err != nil {
+		return nil, fmt.Errorf("node %v has no atx: %v", app.NodeID().ShortString(), err)
+	}
+	return app.AtxDB().GetAtxHeader(id)
+}
+
+// runScenarios polls the criteria of the scenarios that aren't done and whose dependencies are done, it returns true
+// once all the scenarios are done.
+func runScenarios(h *Harness, scenarios []appScenario, finished map[int]bool) (bool, error) {
+	for i, sc := range scenarios {
+		if finished[i] {
+			continue
+		}
+		depsOk := true
+		for _, dep := range sc.Dependencies {
+			depsOk = depsOk && finished[dep]
+		}
+		if !depsOk {
+			continue
+		}
+		done, err := sc.Criteria(h)
+		if err != nil {
+			return false, fmt.Errorf("scenario %d failed: %v", i, err)
+		}
+		finished[i] = done
+	}
+	return len(finished) == len(scenarios) && allFinished(finished), nil
+}
+
+func allFinished(finished map[int]bool) bool {
+	for _, done := range finished {
+		if !done {
+			return false
+		}
+	}
+	return true
+}
+
+func validateBlocksAndATXs(t *testing.T, h *Harness, untilLayer types.LayerID) {
+	r := require.New(t)
+	apps := h.Apps()
+	layersPerEpoch := int(apps[0].Config.LayersPerEpoch)
+
+	for _, app := range apps {
+		r.True(untilLayer-1 <= app.Mesh().ProcessedLayer(), "node %v processed layer %v, expected %v",
+			app.NodeID().ShortString(), app.Mesh().ProcessedLayer(), untilLayer-1)
+	}
+
+	// all nodes have the same blocks, so the counts are taken from the first one
+	totalBlocks, firstEpochBlocks := 0, 0
+	for i := types.LayerID(0); i <= untilLayer; i++ {
+		r.NoError(h.CheckLayerBlocks(i))
+		lyr, err := apps[0].Mesh().GetLayer(i)
+		r.NoError(err)
+		totalBlocks += len(lyr.Blocks())
+		if int(i) < layersPerEpoch {
+			firstEpochBlocks += len(lyr.Blocks())
+		}
+	}
+
+	layerAvgSize := apps[0].Config.LayerAvgSize
+	totalEpochs := int(untilLayer.GetEpoch(uint16(layersPerEpoch))) + 1
+	allMiners := len(apps)
+	exp := (layerAvgSize * layersPerEpoch) / allMiners * allMiners * (totalEpochs - 1)
+	r.Equal(exp, totalBlocks-firstEpochBlocks, "totalBlocks: %v, firstEpochBlocks: %v, layersPerEpoch: %v, "+
+		"layerAvgSize: %v, totalEpochs: %v", totalBlocks, firstEpochBlocks, layersPerEpoch, layerAvgSize, totalEpochs)
+
+	atx, err := lastAtx(apps[0])
+	r.NoError(err)
+	totalAtxs := uint32(0)
+	for atx != nil {
+		totalAtxs += atx.ActiveSetSize
+		atx, _ = apps[0].AtxDB().GetAtxHeader(atx.PrevATXID)
+	}
+	r.Equal(totalEpochs*allMiners, int(totalAtxs), "number of atxs")
+}
+
+// travis has a 10 minutes timeout
+// this ensures we print something before the timeout
+func patchTravisTimeout(termchan chan struct{}) {
+	ticker := time.NewTimer(5 * time.Minute)
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Printf("Travis Patch\n")
+			ticker = time.NewTimer(5 * time.Minute)
+		case <-termchan:
+			return
+		}
+	}
+}
+
+func removePoetFiles() {
+	// poet should clean up after himself
+	matches, err := filepath.Glob("*.bin")
+	if err != nil {
+		log.Error("error while finding PoET bin files: %v", err)
+		return
+	}
+	for _, f := range matches {
+		if err := os.Remove(f); err != nil {
+			log.Error("error while cleaning up PoET bin files: %v", err)
+		}
+	}
+}
+
+func TestHarness_MultipleNodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	term := make(chan struct{})
+	go patchTravisTimeout(term)
+	defer close(term)
+
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "app_test")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	poetHarness, err := activation.NewHTTPPoetHarness(false)
+	r.NoError(err)
+	defer removePoetFiles()
+	defer func() {
+		if err := poetHarness.Teardown(true); err != nil {
+			log.Error("error while cleaning up PoET: %v", err)
+		}
+	}()
+
+	cfg := appTestConfig()
+	h, err := New(cfg, numOfAppNodes, dir, poetHarness.HTTPPoetClient)
+	r.NoError(err)
+	h.Start()
+	defer h.Close()
+	r.NoError(poetHarness.Start([]string{"127.0.0.1:9091"}))
+
+	scenarios := []appScenario{
+		txWithRunningNonceGenerator([]int{}),
+		sameRootTester([]int{0}),
+		reachedEpochTester([]int{}),
+		txWithUnorderedNonceGenerator([]int{1}),
+	}
+	for i, sc := range scenarios {
+		r.NoError(sc.Setup(h), "scenario %d setup", i)
+	}
+
+	layerDuration := time.Duration(cfg.LayerDurationSec) * time.Second
+	timeout := time.After(6 * time.Minute)
+	layerTicker := time.NewTicker(layerDuration)
+	defer layerTicker.Stop()
+	poll := time.NewTicker(500 * time.Millisecond)
+	defer poll.Stop()
+	finished := map[int]bool{}
+loop:
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("timed out at layer %v, finished scenarios: %v", h.CurrentLayer(), finished)
+		case <-layerTicker.C:
+			h.Tick()
+		case <-poll.C:
+			done, err := runScenarios(h, scenarios, finished)
+			r.NoError(err)
+			if done {
+				break loop
+			}
+		}
+	}
+	validateBlocksAndATXs(t, h, types.LayerID(numberOfEpochs*cfg.LayersPerEpoch)-1)
+
+	// the state that a node persisted is loaded when it's reopened
+	oldRoot := h.Apps()[0].State().GetStateRoot()
+	h.Close()
+	r.NoError(h.Reopen(0))
+	r.Equal(oldRoot, h.Apps()[0].State().GetStateRoot())
+	// start and stop and test for no panics
+	h.StartNode(0)
+	h.StopNode(0)
+}
@@ -0,0 +1,293 @@
+// Package testharness runs a number of spacemesh nodes in a single process for tests. Nodes are connected by a
+// simulated network that can be partitioned, advance layers on a manual clock, and can be queried for consistency
+// invariants. Failures are returned as errors rather than panics, so that tests can decide how to report them.
+package testharness
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spacemeshos/amcl"
+	"github.com/spacemeshos/amcl/BLS381"
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/cmd/node"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/spacemeshos/go-spacemesh/eligibility"
+	"github.com/spacemeshos/go-spacemesh/miner"
+	"github.com/spacemeshos/go-spacemesh/p2p/p2pcrypto"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+)
+
+// errNoPoet is returned by the PoET client that is used when the harness was not given one.
+var errNoPoet = errors.New("no PoET service configured for the test harness")
+
+type noPoetClient struct{}
+
+func (noPoetClient) Submit(types.Hash32) (*types.PoetRound, error) {
+	return nil, errNoPoet
+}
+
+func (noPoetClient) PoetServiceID() ([]byte, error) {
+	return nil, errNoPoet
+}
+
+func (noPoetClient) RoundTimes() (*activation.PoetRoundTimes, error) {
+	return nil, errNoPoet
+}
+
+// network records the simulated p2p node of every app, so that the harness can partition them.
+type network struct {
+	*service.Simulator
+	nodes []*service.Node
+}
+
+func (n *network) NewNode() *service.Node {
+	nd := n.Simulator.NewNode()
+	n.nodes = append(n.nodes, nd)
+	return nd
+}
+
+// Harness orchestrates in process nodes.
+type Harness struct {
+	apps    []*node.SpacemeshApp
+	running []bool
+	net     *network
+	clock   *node.ManualClock
+
+	// used to reopen nodes
+	cfg         config.Config
+	dir         string
+	genesisTime string
+	rng         *amcl.RAND
+	rolacle     *eligibility.FixedRolacle
+	poetClient  activation.PoetProvingServiceClient
+}
+
+// New initializes numOfNodes nodes with the given config, storing their databases under dir. The nodes are not
+// started. If poetClient is nil the nodes can't publish ATXs, which is only useful with proof-of-work eligibility
+// (see config.PowEligibilityOracle).
+func New(cfg *config.Config, numOfNodes int, dir string, poetClient activation.PoetProvingServiceClient) (*Harness, error) {
+	if numOfNodes < 1 {
+		return nil, fmt.Errorf("harness requires at least one node, got %d", numOfNodes)
+	}
+	if poetClient == nil {
+		poetClient = noPoetClient{}
+	}
+	genesisTime := time.Now()
+	h := &Harness{
+		apps:        make([]*node.SpacemeshApp, 0, numOfNodes),
+		running:     make([]bool, numOfNodes),
+		net:         &network{Simulator: service.NewSimulator()},
+		clock:       node.NewManualClock(genesisTime),
+		cfg:         *cfg,
+		dir:         dir,
+		genesisTime: genesisTime.Format(time.RFC3339),
+		rng:         BLS381.DefaultSeed(),
+		rolacle:     eligibility.New(),
+		poetClient:  poetClient,
+	}
+	for i := 0; i < numOfNodes; i++ {
+		app, err := h.initNode(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize node %d: %v", i, err)
+		}
+		h.apps = append(h.apps, app)
+	}
+	return h, nil
+}
+
+func (h *Harness) initNode(i int) (*node.SpacemeshApp, error) {
+	storePath := filepath.Join(h.dir, strconv.Itoa(i))
+	return node.InitSingleInstance(h.cfg, i, h.genesisTime, h.rng, storePath, h.rolacle, h.poetClient, h.clock, h.net)
+}
+
+// Apps returns the harness' apps, ordered by index.
+func (h *Harness) Apps() []*node.SpacemeshApp {
+	return h.apps
+}
+
+// Start starts all the nodes that are not running.
+func (h *Harness) Start() {
+	for i := range h.apps {
+		h.StartNode(i)
+	}
+}
+
+// StartNode starts the node with the given index, if it's not running. A node can't be restarted once stopped, it has to
+// be reopened first.
+func (h *Harness) StartNode(i int) {
+	if h.running[i] {
+		return
+	}
+	h.apps[i].StartServices()
+	h.running[i] = true
+}
+
+// StopNode stops the node with the given index, it is excluded from invariant checks from now on.
+func (h *Harness) StopNode(i int) {
+	if !h.running[i] {
+		return
+	}
+	h.apps[i].StopServices()
+	h.running[i] = false
+}
+
+// Reopen replaces the stopped node with the given index by a new node that loads its databases. The new node has a new
+// identity and is not started.
+func (h *Harness) Reopen(i int) error {
+	if h.running[i] {
+		return fmt.Errorf("node %d must be stopped before it's reopened", i)
+	}
+	app, err := h.initNode(i)
+	if err != nil {
+		return fmt.Errorf("failed to reopen node %d: %v", i, err)
+	}
+	h.apps[i] = app
+	// the new node's p2p node was appended, move it to the reopened node's index
+	last := len(h.net.nodes) - 1
+	h.net.nodes[i] = h.net.nodes[last]
+	h.net.nodes = h.net.nodes[:last]
+	return nil
+}
+
+// Close stops all running nodes.
+func (h *Harness) Close() {
+	for i := range h.apps {
+		h.StopNode(i)
+	}
+}
+
+// Partition splits the network so that nodes only communicate with nodes in the same group. Groups are given as node
+// indices, nodes that are not listed in any group are isolated together.
+func (h *Harness) Partition(groups ...[]int) {
+	keys := make([][]p2pcrypto.PublicKey, 0, len(groups))
+	for _, group := range groups {
+		groupKeys := make([]p2pcrypto.PublicKey, 0, len(group))
+		for _, i := range group {
+			groupKeys = append(groupKeys, h.net.nodes[i].PublicKey())
+		}
+		keys = append(keys, groupKeys)
+	}
+	h.net.Partition(keys...)
+}
+
+// Heal reconnects a partitioned network.
+func (h *Harness) Heal() {
+	h.net.Heal()
+}
+
+// Tick advances the clock by a single layer and returns the new layer.
+func (h *Harness) Tick() types.LayerID {
+	h.clock.Tick()
+	return h.clock.GetCurrentLayer()
+}
+
+// CurrentLayer returns the last layer that the clock ticked.
+func (h *Harness) CurrentLayer() types.LayerID {
+	return h.clock.GetCurrentLayer()
+}
+
+// SubmitTx gossips a transaction from the node with the given index.
+func (h *Harness) SubmitTx(i int, tx *types.Transaction) error {
+	bytes, err := types.InterfaceToBytes(tx)
+	if err != nil {
+		return fmt.Errorf("failed to serialize tx: %v", err)
+	}
+	return h.apps[i].P2P.Broadcast(miner.IncomingTxProtocol, bytes)
+}
+
+// WaitFor polls check until it returns nil or the timeout expires, in which case the last error is returned.
+func (h *Harness) WaitFor(timeout time.Duration, check func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v: %v", timeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (h *Harness) runningApps() []*node.SpacemeshApp {
+	apps := make([]*node.SpacemeshApp, 0, len(h.apps))
+	for i, app := range h.apps {
+		if h.running[i] {
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// CheckLatestLayer returns an error unless all running nodes received blocks up to the given layer.
+func (h *Harness) CheckLatestLayer(layer types.LayerID) error {
+	for _, app := range h.runningApps() {
+		if latest := app.Mesh().LatestLayer(); latest < layer {
+			return fmt.Errorf("node %v reached layer %v, expected %v", app.NodeID().ShortString(), latest, layer)
+		}
+	}
+	return nil
+}
+
+// CheckStateRoots returns an error unless all running nodes have the same state root.
+func (h *Harness) CheckStateRoots() error {
+	apps := h.runningApps()
+	if len(apps) == 0 {
+		return nil
+	}
+	expected := apps[0].State().GetStateRoot()
+	for _, app := range apps[1:] {
+		if root := app.State().GetStateRoot(); root != expected {
+			return fmt.Errorf("node %v has state root %v, node %v has %v", app.NodeID().ShortString(),
+				root.ShortString(), apps[0].NodeID().ShortString(), expected.ShortString())
+		}
+	}
+	return nil
+}
+
+// CheckLayerBlocks returns an error unless all running nodes have the same set of blocks in the given layer.
+func (h *Harness) CheckLayerBlocks(layer types.LayerID) error {
+	apps := h.runningApps()
+	if len(apps) == 0 {
+		return nil
+	}
+	expected, err := layerBlocks(apps[0], layer)
+	if err != nil {
+		return err
+	}
+	for _, app := range apps[1:] {
+		blocks, err := layerBlocks(app, layer)
+		if err != nil {
+			return err
+		}
+		if len(blocks) != len(expected) {
+			return fmt.Errorf("node %v has %d blocks in layer %v, node %v has %d", app.NodeID().ShortString(),
+				len(blocks), layer, apps[0].NodeID().ShortString(), len(expected))
+		}
+		for id := range blocks {
+			if _, ok := expected[id]; !ok {
+				return fmt.Errorf("node %v has block %v in layer %v, node %v doesn't", app.NodeID().ShortString(),
+					id, layer, apps[0].NodeID().ShortString())
+			}
+		}
+	}
+	return nil
+}
+
+func layerBlocks(app *node.SpacemeshApp, layer types.LayerID) (map[types.BlockID]struct{}, error) {
+	ids, err := app.Mesh().LayerBlockIds(layer)
+	if err != nil {
+		return nil, fmt.Errorf("node %v failed to read layer %v: %v", app.NodeID().ShortString(), layer, err)
+	}
+	blocks := make(map[types.BlockID]struct{}, len(ids))
+	for _, id := range ids {
+		blocks[id] = struct{}{}
+	}
+	return blocks, nil
+}
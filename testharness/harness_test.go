@@ -0,0 +1,63 @@
+package testharness
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/config"
+	"github.com/stretchr/testify/require"
+)
+
+func powConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.EligibilityOracle = config.PowEligibilityOracle
+	cfg.PowDifficulty = 4
+	cfg.HARE.SuperHare = true
+	cfg.LayerAvgSize = 3
+	cfg.LayersPerEpoch = 3
+	cfg.HareEligibility.EpochOffset = 0
+	cfg.SyncInterval = 2
+	cfg.SyncValidationDelta = 5
+	cfg.POST.SpacePerUnit = 1 << 10
+	cfg.POST.NumFiles = 1
+	return &cfg
+}
+
+func TestHarness_PartitionAndHeal(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	r := require.New(t)
+	dir, err := ioutil.TempDir("", "harness")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	h, err := New(powConfig(), 3, dir, nil)
+	r.NoError(err)
+	h.Start()
+	defer h.Close()
+
+	for i := 0; i < 3; i++ {
+		layer := h.Tick()
+		r.NoError(h.WaitFor(10*time.Second, func() error { return h.CheckLatestLayer(layer) }))
+		r.NoError(h.WaitFor(10*time.Second, func() error { return h.CheckLayerBlocks(layer) }))
+	}
+
+	// blocks produced while partitioned only reach the producer's side of the network
+	h.Partition([]int{0, 1}, []int{2})
+	layer := h.Tick()
+	r.NoError(h.WaitFor(10*time.Second, func() error { return h.CheckLatestLayer(layer) }))
+	r.Error(h.CheckLayerBlocks(layer))
+
+	h.Heal()
+	layer = h.Tick()
+	r.NoError(h.WaitFor(10*time.Second, func() error { return h.CheckLayerBlocks(layer) }))
+	r.NoError(h.WaitFor(10*time.Second, h.CheckStateRoots))
+}
+
+func TestNew_NoNodes(t *testing.T) {
+	_, err := New(powConfig(), 0, "", nil)
+	require.Error(t, err)
+}
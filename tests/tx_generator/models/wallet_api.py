@@ -2,7 +2,8 @@ from datetime import datetime
 import random
 import re
 
-from tests.tx_generator import config as conf
+from bech32 import bech32_encode, convertbits
+
 from tests.tx_generator.k8s_handler import api_call, aws_api_call
 
 
@@ -15,6 +16,8 @@ class WalletAPI:
     """
 
     ADDRESS_SIZE_HEX = 40
+    # the human readable part of the network's bech32 addresses, the api only accepts bech32 addresses
+    ADDRESS_HRP = 'sm'
     balance_api = 'v1/balance'
     get_tx_api = 'v1/gettransaction'
     nonce_api = 'v1/nonce'
@@ -77,9 +80,7 @@ class WalletAPI:
         # check balance/nonce
         print(f"\ngetting {resource} for", acc)
         pod_ip, pod_name = self.random_node()
-        data = '{"address":"' + acc[-self.ADDRESS_SIZE_HEX:] + '"}'
-        if acc == conf.acc_pub:
-            data = '{"address":"' + acc + '"}'
+        data = '{"address":"' + self.to_bech32(acc[-self.ADDRESS_SIZE_HEX:]) + '"}'
 
         print(f"querying for the {resource} of {acc}")
         out = self.send_api_call(pod_ip, data, api_res)
@@ -87,6 +88,14 @@ class WalletAPI:
         print(f"{resource} output={out}")
         return out
 
+    @classmethod
+    def to_bech32(cls, hex_addr):
+        """
+        :param hex_addr: string, the 20 bytes hex address
+        :return: string, the bech32 address
+        """
+        return bech32_encode(cls.ADDRESS_HRP, convertbits(bytearray.fromhex(hex_addr), 8, 5))
+
     def random_node(self):
         """
         gets a random node from nodes list
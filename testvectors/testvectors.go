@@ -0,0 +1,156 @@
+// Package testvectors generates canonical test vectors for external client implementations: the encodings of the
+// protocol's messages, with their ids and signatures, made from fixed fields and signed by a fixed key. An
+// implementation that produces the same bytes from the same fields is compatible with this node at the byte level.
+package testvectors
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/activation"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/hare"
+	"github.com/spacemeshos/go-spacemesh/mesh"
+	p2pConf "github.com/spacemeshos/go-spacemesh/p2p/config"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// Version is the version of the test vectors. Bump it whenever the encoding of a message changes, so that external
+// implementations can tell which protocol version the vectors they test against belong to.
+const Version = 1
+
+// seed is the seed of the ed25519 key that signs the vectors.
+var seed = bytes.Repeat([]byte{0x5a}, ed25519.SeedSize)
+
+// Vector is the encoding of a single message. Byte fields are hex encoded.
+type Vector struct {
+	Name      string `json:"name"`
+	Encoded   string `json:"encoded"`             // the xdr encoding of the message, as it's sent over the wire
+	ID        string `json:"id,omitempty"`        // the id of the message, derived from its encoding
+	Signed    string `json:"signed,omitempty"`    // the bytes that the signature is over
+	Signature string `json:"signature,omitempty"` // the signature by PublicKey
+}
+
+// Vectors are the test vectors of a protocol version.
+type Vectors struct {
+	Version       int      `json:"version"`
+	ClientVersion string   `json:"clientVersion"` // the version of the node that generated the vectors
+	Seed          string   `json:"seed"`          // the seed of the ed25519 key that signs the vectors
+	PublicKey     string   `json:"publicKey"`
+	Vectors       []Vector `json:"vectors"`
+}
+
+// Generate returns the test vectors. They're the same on every call.
+func Generate() (*Vectors, error) {
+	signer, err := signing.NewEdSignerFromBuffer(ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		return nil, err
+	}
+	pub := signer.PublicKey().Bytes()
+	res := &Vectors{
+		Version:       Version,
+		ClientVersion: p2pConf.ClientVersion,
+		Seed:          util.Bytes2Hex(seed),
+		PublicKey:     util.Bytes2Hex(pub),
+	}
+	add := func(name string, encoded, id, signed, sig []byte) {
+		res.Vectors = append(res.Vectors, Vector{Name: name, Encoded: util.Bytes2Hex(encoded), ID: util.Bytes2Hex(id),
+			Signed: util.Bytes2Hex(signed), Signature: util.Bytes2Hex(sig)})
+	}
+
+	origin := types.PublicKeyToAddress(pub)
+	add("address", origin.Bytes(), nil, nil, nil)
+
+	tx, err := mesh.NewSignedTx(7, types.HexToAddress("0x1122334455667788990011223344556677889900"), 1000, 10, 20, signer)
+	if err != nil {
+		return nil, err
+	}
+	txBytes, err := types.InterfaceToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	innerTx, err := types.InterfaceToBytes(&tx.InnerTransaction)
+	if err != nil {
+		return nil, err
+	}
+	add("transaction", txBytes, tx.ID().Bytes(), innerTx, tx.Signature[:])
+
+	nodeID := types.NodeID{Key: signer.PublicKey().String(), VRFPublicKey: bytes.Repeat([]byte{0x0b}, 32)}
+	challenge := types.NIPSTChallenge{
+		NodeID:         nodeID,
+		Sequence:       2,
+		PrevATXID:      types.ATXID(types.CalcHash32([]byte("prev atx"))),
+		PubLayerID:     100,
+		StartTick:      10,
+		EndTick:        20,
+		PositioningATX: types.ATXID(types.CalcHash32([]byte("positioning atx"))),
+	}
+	challengeBytes, err := types.NIPSTChallengeToBytes(&challenge)
+	if err != nil {
+		return nil, err
+	}
+	challengeHash, err := challenge.Hash()
+	if err != nil {
+		return nil, err
+	}
+	add("nipst_challenge", challengeBytes, challengeHash.Bytes(), nil, nil)
+
+	nipst := &types.NIPST{
+		Space:          1024,
+		NipstChallenge: challengeHash,
+		PostProof: &types.PostProof{
+			Challenge:    []byte("poet proof ref"),
+			MerkleRoot:   bytes.Repeat([]byte{0x0c}, 32),
+			ProofNodes:   [][]byte{bytes.Repeat([]byte{0x0d}, 32)},
+			ProvenLeaves: [][]byte{bytes.Repeat([]byte{0x0e}, 32)},
+		},
+	}
+	atx := types.NewActivationTx(challenge, origin, 10, []types.BlockID{{1}, {2}}, nipst, nil)
+	if err := activation.SignAtx(signer, atx); err != nil {
+		return nil, err
+	}
+	atxBytes, err := types.InterfaceToBytes(atx)
+	if err != nil {
+		return nil, err
+	}
+	innerAtx, err := atx.InnerBytes()
+	if err != nil {
+		return nil, err
+	}
+	add("atx", atxBytes, atx.ID().Bytes(), innerAtx, atx.Sig)
+
+	block := &types.Block{MiniBlock: types.MiniBlock{
+		BlockHeader: types.BlockHeader{
+			LayerIndex:       101,
+			ATXID:            atx.ID(),
+			EligibilityProof: types.BlockEligibilityProof{J: 1, Sig: bytes.Repeat([]byte{0x0f}, 64)},
+			Data:             []byte("data"),
+			Timestamp:        1600000000,
+			BlockVotes:       []types.BlockID{{1}},
+			ViewEdges:        []types.BlockID{{1}, {2}},
+		},
+		TxIDs:  []types.TransactionID{tx.ID()},
+		ATXIDs: []types.ATXID{atx.ID()},
+	}}
+	block.Signature = signer.Sign(block.Bytes())
+	block.Initialize()
+	blockBytes, err := types.InterfaceToBytes(block)
+	if err != nil {
+		return nil, err
+	}
+	id := block.ID()
+	add("block", blockBytes, id[:], block.Bytes(), block.Signature)
+
+	hareMsgs := hare.TestVectorMessages(signer)
+	names := make([]string, 0, len(hareMsgs))
+	for name := range hareMsgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("hare_"+name, hareMsgs[name], nil, nil, nil)
+	}
+	return res, nil
+}
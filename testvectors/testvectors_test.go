@@ -0,0 +1,54 @@
+package testvectors
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/go-spacemesh/hare"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	r := require.New(t)
+	vectors, err := Generate()
+	r.NoError(err)
+	again, err := Generate()
+	r.NoError(err)
+	r.Equal(vectors, again)
+	r.Equal(Version, vectors.Version)
+
+	pub := signing.NewPublicKey(util.FromHex(vectors.PublicKey))
+	byName := make(map[string]Vector)
+	for _, v := range vectors.Vectors {
+		byName[v.Name] = v
+		if v.Signature != "" {
+			r.True(signing.Verify(pub, util.FromHex(v.Signed), util.FromHex(v.Signature)), v.Name)
+		}
+	}
+
+	tx, err := types.BytesToTransaction(util.FromHex(byName["transaction"].Encoded))
+	r.NoError(err)
+	r.NoError(tx.CalcAndSetOrigin())
+	r.Equal(byName["transaction"].ID, util.Bytes2Hex(tx.ID().Bytes()))
+	r.Equal(byName["address"].Encoded, util.Bytes2Hex(tx.Origin().Bytes()))
+
+	atx, err := types.BytesToAtx(util.FromHex(byName["atx"].Encoded))
+	r.NoError(err)
+	atx.CalcAndSetID()
+	r.Equal(byName["atx"].ID, util.Bytes2Hex(atx.ID().Bytes()))
+
+	block := &types.Block{}
+	r.NoError(types.BytesToInterface(util.FromHex(byName["block"].Encoded), block))
+	r.NoError(block.TryInitialize())
+	id := block.ID()
+	r.Equal(byName["block"].ID, util.Bytes2Hex(id[:]))
+
+	for _, name := range []string{"PreRound", "Status", "Proposal", "Commit", "Notify"} {
+		v, ok := byName["hare_"+name]
+		r.True(ok, name)
+		_, err := hare.MessageFromBuffer(util.FromHex(v.Encoded))
+		r.NoError(err, name)
+	}
+}
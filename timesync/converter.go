@@ -11,6 +11,11 @@ type LayerConv struct {
 	genesis  time.Time     // the genesis time
 }
 
+// NewLayerConv returns a LayerConv for layers of the given duration, where layer 1 starts at genesis
+func NewLayerConv(duration time.Duration, genesis time.Time) LayerConv {
+	return LayerConv{duration: duration, genesis: genesis}
+}
+
 // TimeToLayer returns the layer of the provided time
 func (lc LayerConv) TimeToLayer(t time.Time) types.LayerID {
 	if t.Before(lc.genesis) { // the genesis is in the future
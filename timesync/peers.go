@@ -0,0 +1,92 @@
+package timesync
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/timesync/config"
+)
+
+// MinPeerSamples is the minimum number of peers that must have sampled our clock before their median drift is used.
+const MinPeerSamples = 5
+
+// PeerDrifts estimates the drift of our clock from the network's time by the median of the drifts sampled from peers.
+// Only the latest sample of every peer is kept, so that a single peer can't outweigh the others, and only the samples
+// of the last size peers.
+type PeerDrifts struct {
+	mu      sync.Mutex
+	size    int
+	samples map[string]time.Duration
+	order   []string // peers by the time of their latest sample, oldest first
+}
+
+// NewPeerDrifts returns a PeerDrifts that keeps the samples of up to size peers.
+func NewPeerDrifts(size int) *PeerDrifts {
+	return &PeerDrifts{size: size, samples: make(map[string]time.Duration, size)}
+}
+
+// PeerDrift returns the drift of our clock from the clock of a peer, as sampled by a request sent at sent whose
+// response arrived at received, with the peer's time peerTime. The peer is assumed to have answered in the middle of
+// the round trip.
+func PeerDrift(sent, received, peerTime time.Time) time.Duration {
+	mid := sent.Add(received.Sub(sent) / 2)
+	return mid.Sub(peerTime)
+}
+
+// Add adds a sample of the drift from peer, replacing its previous sample.
+func (p *PeerDrifts) Add(peer string, drift time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.samples[peer]; ok {
+		for i, o := range p.order {
+			if o == peer {
+				p.order = append(p.order[:i], p.order[i+1:]...)
+				break
+			}
+		}
+	} else if len(p.order) == p.size {
+		delete(p.samples, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.samples[peer] = drift
+	p.order = append(p.order, peer)
+}
+
+// Median returns the median of the peers' drifts and the number of peers sampled.
+func (p *PeerDrifts) Median() (time.Duration, int) {
+	p.mu.Lock()
+	res := make(sortableDurations, 0, len(p.samples))
+	for _, d := range p.samples {
+		res = append(res, d)
+	}
+	p.mu.Unlock()
+	if len(res) == 0 {
+		return 0, 0
+	}
+	sort.Sort(res)
+	mid := len(res) / 2
+	if len(res)%2 == 0 {
+		return (res[mid-1] + res[mid]) / 2, len(res)
+	}
+	return res[mid], len(res)
+}
+
+// CheckPeerDrift compares our clock to the network's time, whose drift is the median drift peerDrift of samples peers,
+// and the network's time to ntp, whose drift is ntpDrift. It returns an error if our clock is more than the max allowed
+// drift away from the network's time, or if the network's time is that far from ntp. It returns no error with less
+// than MinPeerSamples samples.
+func CheckPeerDrift(peerDrift time.Duration, samples int, ntpDrift time.Duration) error {
+	if samples < MinPeerSamples {
+		return nil
+	}
+	max := config.TimeConfigValues.MaxAllowedDrift
+	if peerDrift < -max || peerDrift > max {
+		return fmt.Errorf("system clock is %v away from the median of %v peers", peerDrift, samples)
+	}
+	if diff := peerDrift - ntpDrift; diff < -max || diff > max {
+		return fmt.Errorf("the median clock of %v peers is %v away from ntp", samples, -diff)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package timesync
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/timesync/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerDrift(t *testing.T) {
+	sent := time.Now()
+	received := sent.Add(200 * time.Millisecond)
+	// the peer answered in the middle of the round trip with a clock a second ahead of ours
+	require.Equal(t, -time.Second, PeerDrift(sent, received, sent.Add(100*time.Millisecond+time.Second)))
+}
+
+func TestPeerDrifts_Median(t *testing.T) {
+	r := require.New(t)
+	p := NewPeerDrifts(3)
+	_, n := p.Median()
+	r.Zero(n)
+
+	p.Add("a", time.Second)
+	p.Add("b", 3*time.Second)
+	p.Add("c", 2*time.Second)
+	m, n := p.Median()
+	r.Equal(2*time.Second, m)
+	r.Equal(3, n)
+
+	// a new sample replaces the peer's previous one
+	p.Add("a", 10*time.Second)
+	m, n = p.Median()
+	r.Equal(3*time.Second, m)
+	r.Equal(3, n)
+
+	// the peer sampled longest ago, b, is evicted
+	p.Add("d", 0)
+	m, n = p.Median()
+	r.Equal(3, n)
+	r.Equal(2*time.Second, m)
+	p.Add("e", 0)
+	m, n = p.Median()
+	r.Equal(time.Duration(0), m)
+}
+
+func TestCheckPeerDrift(t *testing.T) {
+	r := require.New(t)
+	max := config.TimeConfigValues.MaxAllowedDrift
+	r.NoError(CheckPeerDrift(2*max, MinPeerSamples-1, 0))
+	r.NoError(CheckPeerDrift(max/2, MinPeerSamples, max/4))
+	r.Error(CheckPeerDrift(-2*max, MinPeerSamples, 0))
+	// our clock agrees with the peers but ntp doesn't
+	err := CheckPeerDrift(max/2, MinPeerSamples, -max)
+	r.Error(err)
+	r.Contains(err.Error(), fmt.Sprintf("%v peers", MinPeerSamples))
+}
@@ -0,0 +1,80 @@
+package tortoisebeacon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/common/util"
+	"github.com/spacemeshos/sha256-simd"
+)
+
+// Proposal proposes a value for the beacon of the epoch after Epoch. The value is the hash of VRFSig, the VRF
+// signature of the epoch by the identity that published AtxID, so the proposer can't choose it.
+type Proposal struct {
+	Epoch  types.EpochID
+	AtxID  types.ATXID // the proposer's atx, targeting Epoch
+	VRFSig []byte
+}
+
+// Value returns the value proposed.
+func (p *Proposal) Value() types.Hash32 {
+	return sha256.Sum256(p.VRFSig)
+}
+
+// Vote lists the values of the proposals of Epoch that the voter received during the proposal phase. It's signed by
+// the identity that published AtxID.
+type Vote struct {
+	Epoch     types.EpochID
+	AtxID     types.ATXID // the voter's atx, targeting Epoch
+	Proposals []types.Hash32
+	Signature []byte
+}
+
+// Bytes returns the signed bytes of the vote.
+func (v *Vote) Bytes() []byte {
+	b := make([]byte, 0, 8+types.Hash32Length*(len(v.Proposals)+1))
+	b = append(b, proposalMessage(v.Epoch)...)
+	b = append(b, v.AtxID.Bytes()...)
+	for _, p := range v.Proposals {
+		b = append(b, p.Bytes()...)
+	}
+	return b
+}
+
+// proposalMessage returns the message that proposers sign with their VRF key in epoch.
+func proposalMessage(epoch types.EpochID) []byte {
+	msg := make([]byte, 3+8)
+	copy(msg, "TBP")
+	binary.BigEndian.PutUint64(msg[3:], uint64(epoch))
+	return msg
+}
+
+// eligibleProposal returns true if value is in the expected count proposals of an epoch with activeSetSize active
+// identities.
+func eligibleProposal(value types.Hash32, activeSetSize uint32, count int) bool {
+	if activeSetSize <= uint32(count) {
+		return true
+	}
+	threshold := ^uint64(0) / uint64(activeSetSize) * uint64(count)
+	return binary.BigEndian.Uint64(value[:8]) < threshold
+}
+
+// calcBeacon returns the beacon derived from the proposal values that were accepted in epoch.
+func calcBeacon(epoch types.EpochID, values []types.Hash32) types.Hash32 {
+	sorted := append([]types.Hash32{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+	h := sha256.New()
+	h.Write(proposalMessage(epoch))
+	for _, v := range sorted {
+		h.Write(v.Bytes())
+	}
+	var beacon types.Hash32
+	copy(beacon[:], h.Sum(nil))
+	return beacon
+}
+
+func getBeaconKey(epoch types.EpochID) []byte {
+	return append([]byte("b_"), util.Uint64ToBytesBigEndian(uint64(epoch))...)
+}
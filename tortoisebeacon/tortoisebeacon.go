@@ -0,0 +1,398 @@
+// Package tortoisebeacon implements the tortoise beacon: a random value the nodes agree on for every epoch, that seeds
+// eligibility in the epoch. During an epoch, the identities active in it propose values derived from their VRF
+// signatures of the epoch, which they can't choose. The proposals received during the proposal phase at the start of
+// the epoch are voted for by the active identities, and the beacon of the next epoch is the hash of the proposals that
+// more than half of the voters received in time.
+package tortoisebeacon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/spacemeshos/ed25519"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/priorityq"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+const (
+	// ProposalProtocol is the name of the beacon proposals gossip protocol.
+	ProposalProtocol = "TortoiseBeaconProposal"
+	// VoteProtocol is the name of the beacon votes gossip protocol.
+	VoteProtocol = "TortoiseBeaconVote"
+)
+
+// Config is the configuration of the tortoise beacon.
+type Config struct {
+	ProposalCount  int    // the expected number of proposals in an epoch
+	ProposalLayers uint16 // the layers at the start of an epoch that proposals are accepted in
+	VotingLayers   uint16 // the layers after the proposal phase that votes are accepted in
+}
+
+type atxProvider interface {
+	GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error)
+	GetNodeAtxIDForEpoch(nodeID types.NodeID, targetEpoch types.EpochID) (types.ATXID, error)
+}
+
+type vrfSigner interface {
+	Sign(msg []byte) ([]byte, error)
+}
+
+type vrfVerifier func(msg, sig, pub []byte) (bool, error)
+
+type signer interface {
+	Sign(m []byte) []byte
+}
+
+// the proposals and votes received for an epoch
+type epochState struct {
+	proposers map[types.ATXID]struct{}
+	proposals map[types.Hash32]struct{} // the values of the proposals received during the proposal phase
+	voters    map[string]struct{}
+	votes     map[types.Hash32]int // the number of votes for each proposal value
+}
+
+func newEpochState() *epochState {
+	return &epochState{
+		proposers: make(map[types.ATXID]struct{}),
+		proposals: make(map[types.Hash32]struct{}),
+		voters:    make(map[string]struct{}),
+		votes:     make(map[types.Hash32]int),
+	}
+}
+
+// TortoiseBeacon runs the beacon protocol in every epoch, and stores the beacon it calculates for the next epoch.
+type TortoiseBeacon struct {
+	log.Log
+	cfg            Config
+	net            service.Service
+	atxs           atxProvider
+	db             database.Database
+	nodeID         types.NodeID
+	vrfSigner      vrfSigner
+	verifyVRF      vrfVerifier
+	signer         signer
+	layersPerEpoch uint16
+	layers         chan types.LayerID
+	proposalMsgs   chan service.GossipMessage
+	voteMsgs       chan service.GossipMessage
+
+	mu     sync.Mutex
+	layer  types.LayerID
+	epochs map[types.EpochID]*epochState
+
+	exit chan struct{}
+}
+
+// NewTortoiseBeacon returns a new TortoiseBeacon that runs the protocol as nodeID, on the layers ticked by layers.
+// Beacons are stored in db.
+func NewTortoiseBeacon(cfg Config, net service.Service, atxs atxProvider, db database.Database, nodeID types.NodeID,
+	vrfSigner vrfSigner, verifyVRF vrfVerifier, signer signer, layersPerEpoch uint16, layers chan types.LayerID,
+	logger log.Log) *TortoiseBeacon {
+	return &TortoiseBeacon{
+		Log:            logger,
+		cfg:            cfg,
+		net:            net,
+		atxs:           atxs,
+		db:             db,
+		nodeID:         nodeID,
+		vrfSigner:      vrfSigner,
+		verifyVRF:      verifyVRF,
+		signer:         signer,
+		layersPerEpoch: layersPerEpoch,
+		layers:         layers,
+		proposalMsgs:   net.RegisterGossipProtocol(ProposalProtocol, priorityq.Mid),
+		voteMsgs:       net.RegisterGossipProtocol(VoteProtocol, priorityq.Mid),
+		epochs:         make(map[types.EpochID]*epochState),
+		exit:           make(chan struct{}),
+	}
+}
+
+// Start starts running the protocol.
+func (tb *TortoiseBeacon) Start() {
+	go tb.loop()
+}
+
+// Close stops running the protocol.
+func (tb *TortoiseBeacon) Close() {
+	close(tb.exit)
+}
+
+func (tb *TortoiseBeacon) loop() {
+	for {
+		select {
+		case <-tb.exit:
+			tb.Info("tortoise beacon stopped")
+			return
+		case layer := <-tb.layers:
+			tb.onLayer(layer)
+		case msg := <-tb.proposalMsgs:
+			if msg == nil {
+				tb.Error("nil beacon proposal received!")
+				continue
+			}
+			go tb.handleGossipProposal(msg)
+		case msg := <-tb.voteMsgs:
+			if msg == nil {
+				tb.Error("nil beacon vote received!")
+				continue
+			}
+			go tb.handleGossipVote(msg)
+		}
+	}
+}
+
+func (tb *TortoiseBeacon) onLayer(layer types.LayerID) {
+	tb.mu.Lock()
+	if layer > tb.layer {
+		tb.layer = layer
+	}
+	tb.mu.Unlock()
+
+	epoch := layer.GetEpoch(tb.layersPerEpoch)
+	if epoch.IsGenesis() {
+		return // there are no active identities to run the protocol
+	}
+	switch layer - epoch.FirstLayer(tb.layersPerEpoch) {
+	case 0:
+		tb.propose(epoch)
+	case types.LayerID(tb.cfg.ProposalLayers):
+		tb.vote(epoch)
+	case types.LayerID(tb.cfg.ProposalLayers + tb.cfg.VotingLayers):
+		tb.finish(epoch)
+	}
+}
+
+// returns the state of epoch, creating it if needed. Must be called with tb.mu held.
+func (tb *TortoiseBeacon) epochState(epoch types.EpochID) *epochState {
+	state, ok := tb.epochs[epoch]
+	if !ok {
+		state = newEpochState()
+		tb.epochs[epoch] = state
+	}
+	return state
+}
+
+// returns this node's atx targeting epoch, or false if this node isn't active in epoch
+func (tb *TortoiseBeacon) activeAtx(epoch types.EpochID) (*types.ActivationTxHeader, bool) {
+	id, err := tb.atxs.GetNodeAtxIDForEpoch(tb.nodeID, epoch)
+	if err != nil {
+		tb.With().Debug("not active in epoch, not taking part in the tortoise beacon", epoch, log.Err(err))
+		return nil, false
+	}
+	atx, err := tb.atxs.GetAtxHeader(id)
+	if err != nil {
+		tb.With().Error("could not get own atx", epoch, id, log.Err(err))
+		return nil, false
+	}
+	return atx, true
+}
+
+func (tb *TortoiseBeacon) propose(epoch types.EpochID) {
+	atx, ok := tb.activeAtx(epoch)
+	if !ok {
+		return
+	}
+	sig, err := tb.vrfSigner.Sign(proposalMessage(epoch))
+	if err != nil {
+		tb.With().Error("could not sign beacon proposal", epoch, log.Err(err))
+		return
+	}
+	proposal := &Proposal{Epoch: epoch, AtxID: atx.ID(), VRFSig: sig}
+	if !eligibleProposal(proposal.Value(), atx.ActiveSetSize, tb.cfg.ProposalCount) {
+		tb.With().Debug("not eligible to propose a beacon", epoch)
+		return
+	}
+	tb.broadcast(ProposalProtocol, epoch, proposal)
+	tb.With().Info("proposed beacon value", epoch, proposal.Value().Field("value"))
+}
+
+func (tb *TortoiseBeacon) vote(epoch types.EpochID) {
+	atx, ok := tb.activeAtx(epoch)
+	if !ok {
+		return
+	}
+	tb.mu.Lock()
+	state := tb.epochState(epoch)
+	proposals := make([]types.Hash32, 0, len(state.proposals))
+	for value := range state.proposals {
+		proposals = append(proposals, value)
+	}
+	tb.mu.Unlock()
+	sort.Slice(proposals, func(i, j int) bool { return bytes.Compare(proposals[i][:], proposals[j][:]) < 0 })
+
+	vote := &Vote{Epoch: epoch, AtxID: atx.ID(), Proposals: proposals}
+	vote.Signature = tb.signer.Sign(vote.Bytes())
+	tb.broadcast(VoteProtocol, epoch, vote)
+	tb.With().Info("voted for beacon proposals", epoch, log.Int("num_proposals", len(proposals)))
+}
+
+func (tb *TortoiseBeacon) broadcast(protocol string, epoch types.EpochID, msg interface{}) {
+	bts, err := types.InterfaceToBytes(msg)
+	if err != nil {
+		tb.With().Error("could not serialize tortoise beacon message", epoch, log.Err(err))
+		return
+	}
+	// like other gossip messages, our own message is delivered back to us and counted once validated
+	if err := tb.net.Broadcast(protocol, bts); err != nil {
+		tb.With().Error("could not broadcast tortoise beacon message", epoch, log.Err(err))
+	}
+}
+
+// finish calculates the beacon of the epoch after epoch from the proposals that more than half of the voters voted
+// for, and stores it.
+func (tb *TortoiseBeacon) finish(epoch types.EpochID) {
+	tb.mu.Lock()
+	state := tb.epochState(epoch)
+	var accepted []types.Hash32
+	for value, votes := range state.votes {
+		if 2*votes > len(state.voters) {
+			accepted = append(accepted, value)
+		}
+	}
+	voters := len(state.voters)
+	for e := range tb.epochs {
+		if e <= epoch {
+			delete(tb.epochs, e)
+		}
+	}
+	tb.mu.Unlock()
+
+	beacon := calcBeacon(epoch, accepted)
+	if err := tb.db.Put(getBeaconKey(epoch+1), beacon.Bytes()); err != nil {
+		tb.With().Error("could not store beacon", epoch+1, log.Err(err))
+		return
+	}
+	tb.With().Info("calculated beacon", epoch+1, beacon.Field("beacon"),
+		log.Int("num_proposals", len(accepted)), log.Int("num_voters", voters))
+}
+
+// GetBeacon returns the beacon of epoch, or database.ErrNotFound if this node didn't calculate it.
+func (tb *TortoiseBeacon) GetBeacon(epoch types.EpochID) ([]byte, error) {
+	return tb.db.Get(getBeaconKey(epoch))
+}
+
+var (
+	errWrongPhase  = errors.New("message received outside of its phase")
+	errWrongEpoch  = errors.New("atx doesn't target the epoch")
+	errDuplicate   = errors.New("duplicate message")
+	errNotEligible = errors.New("not eligible to propose")
+)
+
+// returns true if the current layer is in the proposal phase of epoch, or in its voting phase if voting is true
+func (tb *TortoiseBeacon) inPhase(epoch types.EpochID, voting bool) bool {
+	tb.mu.Lock()
+	layer := tb.layer
+	tb.mu.Unlock()
+	start := epoch.FirstLayer(tb.layersPerEpoch)
+	end := start + types.LayerID(tb.cfg.ProposalLayers)
+	if voting {
+		start, end = end, end+types.LayerID(tb.cfg.VotingLayers)
+	}
+	return layer >= start && layer < end
+}
+
+func (tb *TortoiseBeacon) handleGossipProposal(gossipMessage service.GossipMessage) {
+	var proposal Proposal
+	if err := types.BytesToInterface(gossipMessage.Bytes(), &proposal); err != nil {
+		tb.Error("could not deserialize beacon proposal: %v", err)
+		return
+	}
+	if err := tb.handleProposal(&proposal); err != nil {
+		tb.With().Warning("invalid beacon proposal", proposal.Epoch, proposal.AtxID, log.Err(err))
+		return
+	}
+	gossipMessage.ReportValidation(ProposalProtocol)
+}
+
+// validates proposal and adds it to the proposals of its epoch
+func (tb *TortoiseBeacon) handleProposal(proposal *Proposal) error {
+	if !tb.inPhase(proposal.Epoch, false) {
+		return errWrongPhase
+	}
+	atx, err := tb.atxs.GetAtxHeader(proposal.AtxID)
+	if err != nil {
+		return fmt.Errorf("unknown atx: %v", err)
+	}
+	if atx.TargetEpoch(tb.layersPerEpoch) != proposal.Epoch {
+		return errWrongEpoch
+	}
+	ok, err := tb.verifyVRF(proposalMessage(proposal.Epoch), proposal.VRFSig, atx.NodeID.VRFPublicKey)
+	if err != nil {
+		return fmt.Errorf("could not verify vrf signature: %v", err)
+	}
+	if !ok {
+		return errors.New("invalid vrf signature")
+	}
+	value := proposal.Value()
+	if !eligibleProposal(value, atx.ActiveSetSize, tb.cfg.ProposalCount) {
+		return errNotEligible
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	state := tb.epochState(proposal.Epoch)
+	if _, ok := state.proposers[proposal.AtxID]; ok {
+		return errDuplicate
+	}
+	state.proposers[proposal.AtxID] = struct{}{}
+	state.proposals[value] = struct{}{}
+	return nil
+}
+
+func (tb *TortoiseBeacon) handleGossipVote(gossipMessage service.GossipMessage) {
+	var vote Vote
+	if err := types.BytesToInterface(gossipMessage.Bytes(), &vote); err != nil {
+		tb.Error("could not deserialize beacon vote: %v", err)
+		return
+	}
+	if err := tb.handleVote(&vote); err != nil {
+		tb.With().Warning("invalid beacon vote", vote.Epoch, vote.AtxID, log.Err(err))
+		return
+	}
+	gossipMessage.ReportValidation(VoteProtocol)
+}
+
+// validates vote and counts it. Every active identity votes once in an epoch.
+func (tb *TortoiseBeacon) handleVote(vote *Vote) error {
+	if !tb.inPhase(vote.Epoch, true) {
+		return errWrongPhase
+	}
+	pubKey, err := ed25519.ExtractPublicKey(vote.Bytes(), vote.Signature)
+	if err != nil {
+		return fmt.Errorf("could not extract public key: %v", err)
+	}
+	atx, err := tb.atxs.GetAtxHeader(vote.AtxID)
+	if err != nil {
+		return fmt.Errorf("unknown atx: %v", err)
+	}
+	if atx.TargetEpoch(tb.layersPerEpoch) != vote.Epoch {
+		return errWrongEpoch
+	}
+	if signing.NewPublicKey(pubKey).String() != atx.NodeID.Key {
+		return errors.New("vote is not signed by the atx's identity")
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	state := tb.epochState(vote.Epoch)
+	if _, ok := state.voters[atx.NodeID.Key]; ok {
+		return errDuplicate
+	}
+	state.voters[atx.NodeID.Key] = struct{}{}
+	voted := make(map[types.Hash32]struct{}, len(vote.Proposals))
+	for _, value := range vote.Proposals {
+		if _, ok := voted[value]; ok {
+			continue
+		}
+		voted[value] = struct{}{}
+		state.votes[value]++
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package tortoisebeacon
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/database"
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/service"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/spacemeshos/sha256-simd"
+	"github.com/stretchr/testify/require"
+)
+
+const layersPerEpoch = 10
+const epoch = types.EpochID(2)
+
+var testConfig = Config{ProposalCount: 10, ProposalLayers: 1, VotingLayers: 1}
+
+// a VRF whose signature of a message is the hash of the key and the message
+type vrfMock struct {
+	key []byte
+}
+
+func (v vrfMock) Sign(msg []byte) ([]byte, error) {
+	sig := sha256.Sum256(append(append([]byte{}, v.key...), msg...))
+	return sig[:], nil
+}
+
+func verifyVRFMock(msg, sig, pub []byte) (bool, error) {
+	expected, _ := vrfMock{key: pub}.Sign(msg)
+	return bytes.Equal(expected, sig), nil
+}
+
+type atxsMock struct {
+	atxs map[types.ATXID]*types.ActivationTxHeader
+}
+
+func (m *atxsMock) GetAtxHeader(id types.ATXID) (*types.ActivationTxHeader, error) {
+	atx, ok := m.atxs[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return atx, nil
+}
+
+func (m *atxsMock) GetNodeAtxIDForEpoch(nodeID types.NodeID, targetEpoch types.EpochID) (types.ATXID, error) {
+	for id, atx := range m.atxs {
+		if atx.NodeID.Key == nodeID.Key && atx.TargetEpoch(layersPerEpoch) == targetEpoch {
+			return id, nil
+		}
+	}
+	return *types.EmptyATXID, database.ErrNotFound
+}
+
+func (m *atxsMock) add(nodeID types.NodeID, targetEpoch types.EpochID, activeSetSize uint32) types.ATXID {
+	atx := types.NewActivationTx(types.NIPSTChallenge{NodeID: nodeID, PubLayerID: (targetEpoch - 1).FirstLayer(layersPerEpoch)},
+		types.Address{}, activeSetSize, nil, &types.NIPST{}, nil)
+	atx.CalcAndSetID()
+	m.atxs[atx.ID()] = atx.ActivationTxHeader
+	return atx.ID()
+}
+
+func createBeacons(t *testing.T, n int) ([]*TortoiseBeacon, *atxsMock) {
+	sim := service.NewSimulator()
+	atxs := &atxsMock{atxs: make(map[types.ATXID]*types.ActivationTxHeader)}
+	beacons := make([]*TortoiseBeacon, 0, n)
+	for i := 0; i < n; i++ {
+		sgn := signing.NewEdSigner()
+		nodeID := types.NodeID{Key: sgn.PublicKey().String(), VRFPublicKey: []byte{byte(i)}}
+		atxs.add(nodeID, epoch, uint32(n))
+		tb := NewTortoiseBeacon(testConfig, sim.NewNode(), atxs, database.NewMemDatabase(), nodeID,
+			vrfMock{key: nodeID.VRFPublicKey}, verifyVRFMock, sgn, layersPerEpoch, make(chan types.LayerID), log.NewDefault(t.Name()))
+		tb.Start()
+		beacons = append(beacons, tb)
+	}
+	return beacons, atxs
+}
+
+// ticks layer on all the beacons, after all of them reached it
+func tick(beacons []*TortoiseBeacon, layer types.LayerID) {
+	for _, tb := range beacons {
+		tb.mu.Lock()
+		tb.layer = layer
+		tb.mu.Unlock()
+	}
+	for _, tb := range beacons {
+		tb.onLayer(layer)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	timeout := time.After(5 * time.Second)
+	for !cond() {
+		select {
+		case <-timeout:
+			require.FailNow(t, "timed out")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestTortoiseBeacon_Agree(t *testing.T) {
+	r := require.New(t)
+	beacons, _ := createBeacons(t, 4)
+	for _, tb := range beacons {
+		defer tb.Close()
+	}
+
+	first := epoch.FirstLayer(layersPerEpoch)
+	tick(beacons, first)
+	waitFor(t, func() bool {
+		for _, tb := range beacons {
+			tb.mu.Lock()
+			n := len(tb.epochState(epoch).proposals)
+			tb.mu.Unlock()
+			if n < len(beacons) {
+				return false
+			}
+		}
+		return true
+	})
+	tick(beacons, first+1)
+	waitFor(t, func() bool {
+		for _, tb := range beacons {
+			tb.mu.Lock()
+			n := len(tb.epochState(epoch).voters)
+			tb.mu.Unlock()
+			if n < len(beacons) {
+				return false
+			}
+		}
+		return true
+	})
+	tick(beacons, first+2)
+
+	expected, err := beacons[0].GetBeacon(epoch + 1)
+	r.NoError(err)
+	r.Len(expected, types.Hash32Length)
+	for _, tb := range beacons[1:] {
+		beacon, err := tb.GetBeacon(epoch + 1)
+		r.NoError(err)
+		r.Equal(expected, beacon)
+	}
+	_, err = beacons[0].GetBeacon(epoch + 2)
+	r.Equal(database.ErrNotFound, err)
+}
+
+func TestTortoiseBeacon_RejectsInvalidMessages(t *testing.T) {
+	r := require.New(t)
+	beacons, atxs := createBeacons(t, 2)
+	for _, tb := range beacons {
+		defer tb.Close()
+	}
+	tb := beacons[0]
+	other := beacons[1]
+	otherAtx, err := atxs.GetNodeAtxIDForEpoch(other.nodeID, epoch)
+	r.NoError(err)
+	sig, err := other.vrfSigner.Sign(proposalMessage(epoch))
+	r.NoError(err)
+	proposal := &Proposal{Epoch: epoch, AtxID: otherAtx, VRFSig: sig}
+
+	// proposals are only accepted during the proposal phase
+	first := epoch.FirstLayer(layersPerEpoch)
+	tb.layer = first - 1
+	r.Equal(errWrongPhase, tb.handleProposal(proposal))
+	tb.layer = first
+	r.Error(tb.handleProposal(&Proposal{Epoch: epoch, AtxID: otherAtx, VRFSig: []byte("forged")}))
+	r.Error(tb.handleProposal(&Proposal{Epoch: epoch + 1, AtxID: otherAtx, VRFSig: sig}))
+	r.NoError(tb.handleProposal(proposal))
+	r.Equal(errDuplicate, tb.handleProposal(proposal))
+
+	// votes are only accepted during the voting phase, signed by the identity of their atx
+	vote := &Vote{Epoch: epoch, AtxID: otherAtx, Proposals: []types.Hash32{proposal.Value()}}
+	vote.Signature = tb.signer.Sign(vote.Bytes())
+	r.Equal(errWrongPhase, tb.handleVote(vote))
+	tb.layer = first + 1
+	r.Error(tb.handleVote(vote))
+	vote.Signature = other.signer.Sign(vote.Bytes())
+	r.NoError(tb.handleVote(vote))
+	r.Equal(errDuplicate, tb.handleVote(vote))
+	r.Equal(1, tb.epochState(epoch).votes[proposal.Value()])
+}
+
+func TestEligibleProposal(t *testing.T) {
+	r := require.New(t)
+	var low, high types.Hash32
+	high[0] = 0xff
+	r.True(eligibleProposal(high, 10, 10))
+	r.True(eligibleProposal(low, 1000, 10))
+	r.False(eligibleProposal(high, 1000, 10))
+}
@@ -0,0 +1,229 @@
+// Package updater checks whether the node runs an outdated version of the software. It compares the node's version
+// with the client versions its peers announce, and optionally with the versions announced by a signed release feed,
+// and warns when the node falls below the network's minimum recommended version. It never updates the node itself.
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/version"
+	"github.com/spacemeshos/go-spacemesh/signing"
+)
+
+// feedTimeout is the timeout of a request to the release feed.
+const feedTimeout = 10 * time.Second
+
+// minPeers is the number of peers whose versions must be known to derive the minimum recommended version from them.
+const minPeers = 3
+
+// Config is the configuration of the updater.
+type Config struct {
+	Interval time.Duration // the interval between checks
+	FeedURL  string        // the url of the release feed, empty to compare with peers only
+	FeedKey  []byte        // the ed25519 public key that signs the release feed
+}
+
+// Release is the release feed's announcement of the latest version, and of the minimum version nodes should run.
+type Release struct {
+	Latest     string `json:"latest"`
+	MinVersion string `json:"min"`
+	Signature  []byte `json:"signature"` // signature of the feed key over Latest and MinVersion
+}
+
+// signedBytes returns the bytes the feed key signs: the latest and the minimum version, each terminated by a zero.
+func (r *Release) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(r.Latest)
+	buf.WriteByte(0)
+	buf.WriteString(r.MinVersion)
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+// Status is the result of the latest version check.
+type Status struct {
+	Version    string // the version of the node
+	Latest     string // the highest version run by a peer or announced by the release feed, empty if unknown
+	MinVersion string // the network's minimum recommended version, empty if unknown
+	Outdated   bool   // the node's version is lower than MinVersion
+}
+
+// Peers is implemented by networks that know the client versions of their peers.
+type Peers interface {
+	// PeerVersions returns the client versions of the connected peers, one per peer.
+	PeerVersions() []string
+}
+
+// Updater periodically checks the version of the node against the versions of the network.
+type Updater struct {
+	cfg    Config
+	peers  Peers
+	client *http.Client
+	logger log.Log
+
+	mu     sync.RWMutex
+	status Status
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// New returns an updater that checks the node's version ver against the versions of peers and the release feed.
+func New(cfg Config, ver string, peers Peers, logger log.Log) (*Updater, error) {
+	if _, err := version.Parse(ver); err != nil {
+		return nil, err
+	}
+	if cfg.FeedURL != "" && len(cfg.FeedKey) != 32 {
+		return nil, errors.New("a release feed requires a 32 byte ed25519 public key")
+	}
+	return &Updater{
+		cfg:    cfg,
+		peers:  peers,
+		client: &http.Client{Timeout: feedTimeout},
+		logger: logger,
+		status: Status{Version: ver},
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+// Start checks the node's version every configured interval until Close is called.
+func (u *Updater) Start() {
+	go func() {
+		ticker := time.NewTicker(u.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), feedTimeout)
+				u.check(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// Close stops the periodic checks.
+func (u *Updater) Close() {
+	u.once.Do(func() { close(u.stop) })
+}
+
+// Status returns the result of the latest check.
+func (u *Updater) Status() Status {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.status
+}
+
+// check derives the latest and the minimum recommended version from the peers and the release feed, and warns when the
+// node is below the minimum. The release feed is trusted over the peers: when both announce a minimum, the higher one
+// is recommended.
+func (u *Updater) check(ctx context.Context) {
+	st := Status{Version: u.Status().Version}
+	st.Latest, st.MinVersion = peersVersions(u.peers.PeerVersions())
+	if u.cfg.FeedURL != "" {
+		rel, err := u.fetchRelease(ctx)
+		if err != nil {
+			u.logger.Warning("failed to fetch the release feed: %v", err)
+		} else {
+			st.Latest = highest(st.Latest, rel.Latest)
+			st.MinVersion = highest(st.MinVersion, rel.MinVersion)
+		}
+	}
+	if st.MinVersion != "" {
+		cmp, _ := version.Compare(st.Version, st.MinVersion)
+		st.Outdated = cmp < 0
+	}
+	u.mu.Lock()
+	u.status = st
+	u.mu.Unlock()
+
+	if st.Outdated {
+		u.logger.With().Warning("the node runs a version below the network's minimum recommended version, please update",
+			log.String("version", st.Version), log.String("min_version", st.MinVersion), log.String("latest", st.Latest))
+	} else if cmp, err := version.Compare(st.Version, st.Latest); err == nil && cmp < 0 {
+		u.logger.With().Info("a newer version is available",
+			log.String("version", st.Version), log.String("latest", st.Latest))
+	}
+}
+
+// peersVersions returns the highest valid version of peers, and the highest version that at least two thirds of peers
+// run or exceed, which is the minimum recommended version. The minimum is empty when there are too few peers to tell.
+func peersVersions(peers []string) (latest, min string) {
+	type parsed struct {
+		ver  string
+		nums [3]uint64
+	}
+	vers := make([]parsed, 0, len(peers))
+	for _, ver := range peers {
+		nums, err := version.Parse(ver)
+		if err != nil {
+			continue
+		}
+		vers = append(vers, parsed{ver, nums})
+	}
+	if len(vers) == 0 {
+		return "", ""
+	}
+	sort.Slice(vers, func(i, j int) bool {
+		for k := range vers[i].nums {
+			if vers[i].nums[k] != vers[j].nums[k] {
+				return vers[i].nums[k] > vers[j].nums[k]
+			}
+		}
+		return false
+	})
+	latest = vers[0].ver
+	if len(vers) >= minPeers {
+		min = vers[(2*len(vers)+2)/3-1].ver
+	}
+	return latest, min
+}
+
+// highest returns the higher of versions a and b, ignoring an empty or invalid one.
+func highest(a, b string) string {
+	if _, err := version.Parse(b); err != nil {
+		return a
+	}
+	if cmp, err := version.Compare(a, b); err == nil && cmp >= 0 {
+		return a
+	}
+	return b
+}
+
+// fetchRelease returns the release announced by the feed, after verifying its signature.
+func (u *Updater) fetchRelease(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequest(http.MethodGet, u.cfg.FeedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := u.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(res.Body)
+		return nil, fmt.Errorf("response status code: %d, body: %s", res.StatusCode, string(data))
+	}
+	rel := &Release{}
+	if err := json.NewDecoder(res.Body).Decode(rel); err != nil {
+		return nil, fmt.Errorf("response json decode failure: %v", err)
+	}
+	if !signing.Verify(signing.NewPublicKey(u.cfg.FeedKey), rel.signedBytes(), rel.Signature) {
+		return nil, errors.New("invalid release signature")
+	}
+	return rel, nil
+}
@@ -0,0 +1,83 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+)
+
+type peersMock []string
+
+func (p peersMock) PeerVersions() []string {
+	return p
+}
+
+func TestPeersVersions(t *testing.T) {
+	r := require.New(t)
+	latest, min := peersVersions(nil)
+	r.Equal("", latest)
+	r.Equal("", min)
+
+	// too few peers to recommend a minimum
+	latest, min = peersVersions([]string{"go-spacemesh/0.1.0", "go-spacemesh/0.3.0"})
+	r.Equal("go-spacemesh/0.3.0", latest)
+	r.Equal("", min)
+
+	// two thirds of the valid versions are 0.2.0 or higher
+	latest, min = peersVersions([]string{"go-spacemesh/0.2.0", "go-spacemesh/0.10.0", "go-spacemesh/0.1.0",
+		"go-spacemesh/0.2.1", "bad", "go-spacemesh/0.1.5", "go-spacemesh/0.2.0"})
+	r.Equal("go-spacemesh/0.10.0", latest)
+	r.Equal("go-spacemesh/0.2.0", min)
+}
+
+func TestUpdater_CheckPeers(t *testing.T) {
+	r := require.New(t)
+	peers := peersMock{"go-spacemesh/0.2.0", "go-spacemesh/0.2.0", "go-spacemesh/0.1.0"}
+	u, err := New(Config{Interval: time.Hour}, "go-spacemesh/0.1.0", peers, log.NewDefault(t.Name()))
+	r.NoError(err)
+	r.Equal(Status{Version: "go-spacemesh/0.1.0"}, u.Status())
+
+	u.check(context.TODO())
+	r.Equal(Status{Version: "go-spacemesh/0.1.0", Latest: "go-spacemesh/0.2.0", MinVersion: "go-spacemesh/0.2.0",
+		Outdated: true}, u.Status())
+
+	u.peers = peersMock{"go-spacemesh/0.3.0", "go-spacemesh/0.1.0", "go-spacemesh/0.1.0"}
+	u.check(context.TODO())
+	r.False(u.Status().Outdated)
+	r.Equal("go-spacemesh/0.3.0", u.Status().Latest)
+}
+
+func TestUpdater_CheckFeed(t *testing.T) {
+	r := require.New(t)
+	signer := signing.NewEdSigner()
+	rel := Release{Latest: "0.4.0", MinVersion: "0.3.0"}
+	rel.Signature = signer.Sign(rel.signedBytes())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(rel)
+	}))
+	defer srv.Close()
+
+	_, err := New(Config{Interval: time.Hour, FeedURL: srv.URL}, "go-spacemesh/0.2.0", peersMock{}, log.NewDefault(t.Name()))
+	r.Error(err)
+
+	// the feed's minimum is higher than the one of the peers
+	peers := peersMock{"go-spacemesh/0.2.0", "go-spacemesh/0.2.0", "go-spacemesh/0.2.0"}
+	u, err := New(Config{Interval: time.Hour, FeedURL: srv.URL, FeedKey: signer.PublicKey().Bytes()},
+		"go-spacemesh/0.2.0", peers, log.NewDefault(t.Name()))
+	r.NoError(err)
+	u.check(context.TODO())
+	r.Equal(Status{Version: "go-spacemesh/0.2.0", Latest: "0.4.0", MinVersion: "0.3.0", Outdated: true}, u.Status())
+
+	// a release signed by another key is ignored
+	rel.Signature = signing.NewEdSigner().Sign(rel.signedBytes())
+	u.check(context.TODO())
+	r.Equal(Status{Version: "go-spacemesh/0.2.0", Latest: "go-spacemesh/0.2.0", MinVersion: "go-spacemesh/0.2.0"},
+		u.Status())
+}
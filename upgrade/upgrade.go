@@ -0,0 +1,99 @@
+// Package upgrade schedules protocol upgrades: changes of consensus behavior that activate at a configured epoch on all
+// the nodes of a network, so that nodes can run a new version before the change takes effect instead of switching
+// simultaneously.
+package upgrade
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// Name identifies an upgrade in the config.
+type Name string
+
+const (
+	// AtxCoinbaseRequired rejects ATXs that don't declare a coinbase. Rewards of the blocks of an identity without a
+	// coinbase are paid to the zero address and lost.
+	AtxCoinbaseRequired Name = "atx-coinbase-required"
+	// CanonicalLayerTxOrder applies the transactions of a layer to the state in the canonical order specified in
+	// mesh/layertxs.go: the blocks are sorted by sha256 keys instead of shuffled with math/rand, and the transactions of
+	// each origin are sorted by nonce, so that the order of the blocks can't make them fail on a wrong nonce.
+	CanonicalLayerTxOrder Name = "canonical-layer-tx-order"
+	// CompactViews makes blocks encode their views as layer hashes with exception lists (types.LayerView) instead of
+	// listing every block. Blocks with compact views are rejected before the upgrade.
+	CompactViews Name = "compact-views"
+	// FirstSeenActiveSet makes the active set declared by an ATX the ATXs included in the blocks of the previous epoch
+	// and a grace period after it, instead of the ATXs found by traversing a view the ATX carries. ATXs with a view
+	// are rejected after the upgrade.
+	FirstSeenActiveSet Name = "first-seen-active-set"
+	// HeaderFirstAtxGossip makes ATXs sign the hash of their NIPST instead of the NIPST, and gossips them without it.
+	// Nodes fetch the NIPST of a gossiped ATX from the peer that sent it only when they include the ATX in a block.
+	HeaderFirstAtxGossip Name = "header-first-atx-gossip"
+	// TortoiseBeacon seeds block eligibility with the tortoise beacon of the epoch, instead of the epoch ID, which
+	// anyone could grind eligibility against in advance.
+	TortoiseBeacon Name = "tortoise-beacon"
+)
+
+// Known are the upgrades this version of the node implements. A node refuses to start with an upgrade it doesn't
+// know scheduled, since it would keep validating with the old rules after the upgrade activates.
+var Known = []Name{AtxCoinbaseRequired, CanonicalLayerTxOrder, CompactViews, FirstSeenActiveSet, HeaderFirstAtxGossip, TortoiseBeacon}
+
+// Upgrade is a scheduled upgrade and the epoch it activates at.
+type Upgrade struct {
+	Name  Name
+	Epoch types.EpochID
+}
+
+// List returns the upgrades scheduled in epochs, a map of upgrade names to activation epochs as found in the config,
+// ordered by epoch and then by name.
+func List(epochs map[string]int) []Upgrade {
+	upgrades := make([]Upgrade, 0, len(epochs))
+	for name, epoch := range epochs {
+		upgrades = append(upgrades, Upgrade{Name: Name(name), Epoch: types.EpochID(epoch)})
+	}
+	sort.Slice(upgrades, func(i, j int) bool {
+		if upgrades[i].Epoch != upgrades[j].Epoch {
+			return upgrades[i].Epoch < upgrades[j].Epoch
+		}
+		return upgrades[i].Name < upgrades[j].Name
+	})
+	return upgrades
+}
+
+// Schedule holds the activation epochs of the upgrades of a network. A nil Schedule has no upgrades scheduled, so
+// validation with it follows the original protocol.
+type Schedule struct {
+	epochs map[Name]types.EpochID
+}
+
+// NewSchedule returns the schedule of the upgrades in epochs, a map of upgrade names to activation epochs as found in
+// the config. It returns an error if an upgrade isn't known or is scheduled at a negative epoch.
+func NewSchedule(epochs map[string]int) (*Schedule, error) {
+	known := make(map[Name]struct{}, len(Known))
+	for _, name := range Known {
+		known[name] = struct{}{}
+	}
+	s := &Schedule{epochs: make(map[Name]types.EpochID, len(epochs))}
+	for _, u := range List(epochs) {
+		if _, ok := known[u.Name]; !ok {
+			return nil, fmt.Errorf("unknown upgrade %v", u.Name)
+		}
+		if epochs[string(u.Name)] < 0 {
+			return nil, fmt.Errorf("upgrade %v scheduled at negative epoch %v", u.Name, epochs[string(u.Name)])
+		}
+		s.epochs[u.Name] = u.Epoch
+	}
+	return s, nil
+}
+
+// Active returns true if the upgrade is scheduled at epoch or before it. Validation of objects of an epoch branches on
+// the upgrades active in that epoch.
+func (s *Schedule) Active(name Name, epoch types.EpochID) bool {
+	if s == nil {
+		return false
+	}
+	activation, ok := s.epochs[name]
+	return ok && epoch >= activation
+}
@@ -0,0 +1,39 @@
+package upgrade
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	r := require.New(t)
+	r.Empty(List(nil))
+	r.Equal([]Upgrade{{"b", 1}, {"a", 3}, {"c", 3}}, List(map[string]int{"c": 3, "a": 3, "b": 1}))
+}
+
+func TestNewSchedule(t *testing.T) {
+	r := require.New(t)
+	_, err := NewSchedule(map[string]int{"no-such-upgrade": 3})
+	r.Error(err)
+	_, err = NewSchedule(map[string]int{string(AtxCoinbaseRequired): -1})
+	r.Error(err)
+
+	s, err := NewSchedule(nil)
+	r.NoError(err)
+	r.False(s.Active(AtxCoinbaseRequired, 100))
+}
+
+func TestSchedule_Active(t *testing.T) {
+	r := require.New(t)
+	var none *Schedule
+	r.False(none.Active(AtxCoinbaseRequired, 5))
+
+	s, err := NewSchedule(map[string]int{string(AtxCoinbaseRequired): 5})
+	r.NoError(err)
+	r.False(s.Active(AtxCoinbaseRequired, 4))
+	r.True(s.Active(AtxCoinbaseRequired, 5))
+	r.True(s.Active(AtxCoinbaseRequired, types.EpochID(6)))
+	r.False(s.Active("other", 6))
+}